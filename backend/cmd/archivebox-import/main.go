@@ -0,0 +1,188 @@
+// Command archivebox-import copies the snapshots of an ArchiveBox data
+// directory into a running WebArchive server through POST /api/archives.
+//
+// It reads each archive/<timestamp>/index.json rather than index.sqlite, so
+// it needs no SQLite driver; ArchiveBox keeps both in sync. The singlefile
+// output is uploaded as a packaged snapshot, falling back to the DOM dump and
+// finally to the extracted text only.
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxScreenshotBytes = 8 << 20
+
+type snapshotIndex struct {
+	URL       string          `json:"url"`
+	Title     string          `json:"title"`
+	Timestamp string          `json:"timestamp"`
+	Tags      json.RawMessage `json:"tags"`
+}
+
+type archiveRequest struct {
+	URL            string     `json:"url"`
+	Title          string     `json:"title"`
+	HTML           string     `json:"html,omitempty"`
+	Content        string     `json:"content,omitempty"`
+	CapturedAt     *time.Time `json:"capturedAt,omitempty"`
+	Tags           []string   `json:"tags"`
+	HierarchyPaths []string   `json:"hierarchyPaths,omitempty"`
+	Source         string     `json:"source"`
+	Mode           string     `json:"mode,omitempty"`
+	Screenshot     string     `json:"screenshot,omitempty"`
+	Snapshot       string     `json:"snapshot,omitempty"`
+	SnapshotFormat string     `json:"snapshotFormat,omitempty"`
+}
+
+func main() {
+	dataDir := flag.String("data", ".", "ArchiveBox data directory (the one holding index.sqlite)")
+	server := flag.String("server", "http://localhost:8080", "WebArchive server base URL")
+	token := flag.String("token", os.Getenv("WEBARCHIVE_TOKEN"), "API token with write scope")
+	path := flag.String("path", "", "taxonomy path for every imported archive, e.g. Imported/ArchiveBox")
+	dryRun := flag.Bool("dry-run", false, "list what would be imported without uploading")
+	flag.Parse()
+
+	dirs, err := filepath.Glob(filepath.Join(*dataDir, "archive", "*", "index.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(dirs) == 0 {
+		log.Fatalf("no snapshots found under %s", filepath.Join(*dataDir, "archive"))
+	}
+	sort.Strings(dirs)
+
+	client := &http.Client{Timeout: 2 * time.Minute}
+	imported, failed := 0, 0
+	for _, indexPath := range dirs {
+		req, err := loadSnapshot(filepath.Dir(indexPath))
+		if err != nil {
+			log.Printf("skip %s: %v", indexPath, err)
+			failed++
+			continue
+		}
+		if *path != "" {
+			req.HierarchyPaths = []string{strings.Trim(*path, "/")}
+		}
+		kind := req.SnapshotFormat
+		if kind == "" {
+			kind = "text"
+			if req.HTML != "" {
+				kind = "dom"
+			}
+		}
+		if *dryRun {
+			fmt.Printf("%s\t%s\t%s\n", kind, req.URL, req.Title)
+			continue
+		}
+		if err := post(client, *server, *token, req); err != nil {
+			log.Printf("import %s failed: %v", req.URL, err)
+			failed++
+			continue
+		}
+		imported++
+		log.Printf("imported %s (%s)", req.URL, kind)
+	}
+	log.Printf("done: %d imported, %d failed", imported, failed)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func loadSnapshot(dir string) (archiveRequest, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if err != nil {
+		return archiveRequest{}, err
+	}
+	var idx snapshotIndex
+	if err := json.Unmarshal(raw, &idx); err != nil {
+		return archiveRequest{}, err
+	}
+	if idx.URL == "" {
+		return archiveRequest{}, fmt.Errorf("index.json has no url")
+	}
+	req := archiveRequest{
+		URL:    idx.URL,
+		Title:  idx.Title,
+		Tags:   parseTags(idx.Tags),
+		Source: "importer:archivebox",
+	}
+	if ts, err := strconv.ParseFloat(idx.Timestamp, 64); err == nil {
+		t := time.Unix(int64(ts), 0).UTC()
+		req.CapturedAt = &t
+	}
+	if text, err := os.ReadFile(filepath.Join(dir, "readability", "content.txt")); err == nil {
+		req.Content = string(text)
+	}
+	if html, err := os.ReadFile(filepath.Join(dir, "singlefile.html")); err == nil && len(html) > 0 {
+		req.Snapshot = string(html)
+		req.SnapshotFormat = "singlefile"
+	} else if html, err := os.ReadFile(filepath.Join(dir, "output.html")); err == nil && len(html) > 0 {
+		req.HTML = string(html)
+	} else if req.Content != "" {
+		req.Mode = "metadata"
+	} else {
+		return archiveRequest{}, fmt.Errorf("no singlefile, dom or text output")
+	}
+	if png, err := os.ReadFile(filepath.Join(dir, "screenshot.png")); err == nil && len(png) > 0 && len(png) <= maxScreenshotBytes {
+		req.Screenshot = "data:image/png;base64," + base64.StdEncoding.EncodeToString(png)
+	}
+	return req, nil
+}
+
+// parseTags accepts both the comma-separated string older ArchiveBox
+// versions write and a JSON list.
+func parseTags(raw json.RawMessage) []string {
+	var list []string
+	if err := json.Unmarshal(raw, &list); err != nil {
+		var joined string
+		if json.Unmarshal(raw, &joined) == nil {
+			list = strings.Split(joined, ",")
+		}
+	}
+	out := []string{}
+	for _, t := range list {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func post(client *http.Client, server, token string, req archiveRequest) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, strings.TrimRight(server, "/")+"/api/archives", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
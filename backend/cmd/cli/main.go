@@ -0,0 +1,330 @@
+// Command webarchive is a small client for the WebArchive HTTP API, meant for
+// scripts and cron jobs. Build it with
+//
+//	go build -o webarchive ./cmd/cli
+//
+// The server and token come from -server/-token or WEBARCHIVE_URL and
+// WEBARCHIVE_TOKEN.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const usage = `usage: webarchive [-server URL] [-token TOKEN] <command> [args]
+
+commands:
+  add [-tags a,b] [-path A/B] [-autotag] URL...   capture pages (server-side fetch)
+  list [-q text] [-tag t] [-category c] [-json]   list archives, newest first
+  search [-semantic] [-limit n] [-json] QUERY     keyword or semantic search
+  export [-o DIR] [ID...]                         BagIt zips for IDs, or all metadata as JSON lines
+  analyze [-dry-run] [-entities] [ID...]          run AI analysis on IDs, or start the batch analyzer
+`
+
+type client struct {
+	server string
+	token  string
+	http   *http.Client
+}
+
+type archive struct {
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	URL        string     `json:"url"`
+	Tags       []string   `json:"tags"`
+	CapturedAt *time.Time `json:"capturedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func main() {
+	server := flag.String("server", envOr("WEBARCHIVE_URL", "http://localhost:8080"), "server base URL")
+	token := flag.String("token", os.Getenv("WEBARCHIVE_TOKEN"), "API token")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	cl := &client{server: strings.TrimRight(*server, "/"), token: *token, http: &http.Client{Timeout: 5 * time.Minute}}
+
+	var err error
+	args := flag.Args()[1:]
+	switch flag.Arg(0) {
+	case "add":
+		err = cl.add(args)
+	case "list":
+		err = cl.list(args)
+	case "search":
+		err = cl.search(args)
+	case "export":
+		err = cl.export(args)
+	case "analyze":
+		err = cl.analyze(args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "webarchive:", err)
+		os.Exit(1)
+	}
+}
+
+func (cl *client) add(args []string) error {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	tags := fs.String("tags", "", "comma-separated tags")
+	path := fs.String("path", "", "taxonomy path, e.g. Tech/Go")
+	autoTag := fs.Bool("autotag", false, "queue AI tagging after capture")
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		return errors.New("add needs at least one URL")
+	}
+	failed := 0
+	for _, u := range fs.Args() {
+		body := map[string]any{"url": u, "client": "webarchive-cli", "autoTag": *autoTag, "tags": splitList(*tags)}
+		if *path != "" {
+			body["hierarchyPaths"] = []string{*path}
+		}
+		var out archive
+		if err := cl.do(http.MethodPost, "/api/archives", body, &out); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", u, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s\t%s\n", out.ID, out.Title)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d captures failed", failed, fs.NArg())
+	}
+	return nil
+}
+
+func (cl *client) list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	q := fs.String("q", "", "text filter")
+	tag := fs.String("tag", "", "tag filter")
+	category := fs.String("category", "", "category filter")
+	asJSON := fs.Bool("json", false, "print raw JSON")
+	_ = fs.Parse(args)
+	params := url.Values{}
+	for k, v := range map[string]string{"q": *q, "tag": *tag, "category": *category} {
+		if v != "" {
+			params.Set(k, v)
+		}
+	}
+	var raw json.RawMessage
+	if err := cl.do(http.MethodGet, "/api/archives?"+params.Encode(), nil, &raw); err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(raw)
+	}
+	var items []archive
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return err
+	}
+	printArchives(items)
+	return nil
+}
+
+func (cl *client) search(args []string) error {
+	fs := flag.NewFlagSet("search", flag.ExitOnError)
+	semantic := fs.Bool("semantic", false, "use embedding search")
+	limit := fs.Int("limit", 10, "semantic search result count")
+	asJSON := fs.Bool("json", false, "print raw JSON")
+	_ = fs.Parse(args)
+	query := strings.Join(fs.Args(), " ")
+	if query == "" {
+		return errors.New("search needs a query")
+	}
+	if !*semantic {
+		return cl.list(append([]string{"-q", query}, jsonFlag(*asJSON)...))
+	}
+	var raw json.RawMessage
+	path := fmt.Sprintf("/api/search/semantic?q=%s&limit=%d", url.QueryEscape(query), *limit)
+	if err := cl.do(http.MethodGet, path, nil, &raw); err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(raw)
+	}
+	var resp struct {
+		Items []struct {
+			Archive archive `json:"archive"`
+			Score   float64 `json:"score"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, hit := range resp.Items {
+		fmt.Fprintf(w, "%.3f\t%s\t%s\t%s\n", hit.Score, hit.Archive.ID, hit.Archive.Title, hit.Archive.URL)
+	}
+	return w.Flush()
+}
+
+func (cl *client) export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	dir := fs.String("o", ".", "directory for BagIt zips")
+	_ = fs.Parse(args)
+	if fs.NArg() == 0 {
+		var items []json.RawMessage
+		if err := cl.do(http.MethodGet, "/api/archives", nil, &items); err != nil {
+			return err
+		}
+		for _, item := range items {
+			fmt.Println(string(item))
+		}
+		return nil
+	}
+	for _, id := range fs.Args() {
+		target := filepath.Join(*dir, id+".zip")
+		if err := cl.download("/api/archives/"+url.PathEscape(id)+"/bagit", target); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+		fmt.Println(target)
+	}
+	return nil
+}
+
+func (cl *client) analyze(args []string) error {
+	fs := flag.NewFlagSet("analyze", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "stage results for review instead of applying them")
+	entities := fs.Bool("entities", false, "re-extract entities, relations and summaries only, keeping tags and paths")
+	_ = fs.Parse(args)
+	scope := "all"
+	if *entities {
+		scope = "entities"
+	}
+	var status json.RawMessage
+	if err := cl.do(http.MethodPost, "/api/ai/analyze/start", map[string]any{"ids": fs.Args(), "dryRun": *dryRun, "scope": scope}, &status); err != nil {
+		return err
+	}
+	return printJSON(status)
+}
+
+func (cl *client) do(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	resp, err := cl.request(method, path, reader)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (cl *client) download(path, target string) error {
+	resp, err := cl.request(http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	f, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// request sends the call and turns non-2xx replies into errors carrying
+// the server's {"error": ...} message.
+func (cl *client) request(method, path string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest(method, cl.server+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if cl.token != "" {
+		req.Header.Set("Authorization", "Bearer "+cl.token)
+	}
+	resp, err := cl.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var msg struct {
+			Error string `json:"error"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&msg)
+		if msg.Error == "" {
+			msg.Error = resp.Status
+		}
+		return nil, errors.New(msg.Error)
+	}
+	return resp, nil
+}
+
+func printArchives(items []archive) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, item := range items {
+		at := item.CreatedAt
+		if item.CapturedAt != nil {
+			at = *item.CapturedAt
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", item.ID, at.Format("2006-01-02"), item.Title, item.URL, strings.Join(item.Tags, ","))
+	}
+	_ = w.Flush()
+}
+
+func printJSON(raw json.RawMessage) error {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return err
+	}
+	fmt.Println(buf.String())
+	return nil
+}
+
+func jsonFlag(on bool) []string {
+	if on {
+		return []string{"-json"}
+	}
+	return nil
+}
+
+func splitList(s string) []string {
+	out := []string{}
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
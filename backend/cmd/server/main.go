@@ -1,35 +1,256 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"flag"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
 	"webarchive/internal/ai"
 	"webarchive/internal/api"
+	"webarchive/internal/cache"
 	"webarchive/internal/config"
 	"webarchive/internal/db"
+	"webarchive/internal/extractor"
 	"webarchive/internal/graphflow"
+	"webarchive/internal/notify"
 	"webarchive/internal/processor"
+	"webarchive/internal/proxy"
+	"webarchive/internal/queue"
+	"webarchive/internal/searchindex"
 	"webarchive/internal/settings"
 	"webarchive/internal/storage"
+	"webarchive/internal/vectorstore"
 )
 
 func main() {
-	cfg := config.Load()
+	configPath := flag.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML config file")
+	printConfig := flag.Bool("print-config", false, "print the effective configuration and exit")
+	flag.Parse()
 
-	gdb, err := db.Connect(cfg.MySQLDSN)
+	cfg, err := config.LoadFile(*configPath)
+	if *printConfig {
+		cfg.Print(os.Stdout)
+	}
 	if err != nil {
-		log.Fatalf("db connect failed: %v", err)
+		log.Fatalf("%v", err)
+	}
+	if *printConfig {
+		return
+	}
+	settings.SetEncryptionKey(cfg.SettingsKey)
+	if cfg.SettingsKey == "" {
+		log.Printf("SETTINGS_ENCRYPTION_KEY not set, LLM API key will be stored in plaintext")
 	}
 
-	store, err := storage.NewMinioStore(cfg.MinIOEndpoint, cfg.MinIOAccessKey, cfg.MinIOSecretKey, cfg.MinIOSecure, cfg.MinIOBucket)
+	var einoAnalyzer *graphflow.Analyzer
+	if cfg.EinoEnabled {
+		analyzer, err := graphflow.NewAnalyzer()
+		if err != nil {
+			log.Printf("eino disabled: %v", err)
+		} else {
+			einoAnalyzer = analyzer
+		}
+	}
+
+	r := gin.Default()
+	r.Use(corsMiddleware())
+
+	srv := &api.Server{
+		AutoTag:       cfg.AutoTagOnCapture,
+		Eino:          einoAnalyzer,
+		AuthEnabled:   cfg.AuthEnabled,
+		PublicEnabled: cfg.PublicEnabled,
+		ViewerOrigin:  cfg.ViewerOrigin,
+		ViewerSecret:  viewerSecret(cfg.ViewerSecret),
+		BaseURL:       cfg.BaseURL,
+	}
+	srv.RegisterRoutes(r)
+
+	gdb, store, err := connectDependencies(cfg, cfg.StartupRetries)
+	if err != nil {
+		// Keep serving in degraded mode (API answers 503) and keep trying in
+		// the background, so compose ordering or a slow MySQL doesn't kill us.
+		log.Printf("dependencies unavailable, starting degraded: %v", err)
+		go func() {
+			for {
+				gdb, store, err := connectDependencies(cfg, cfg.StartupRetries)
+				if err == nil {
+					attachDependencies(srv, cfg, gdb, store)
+					log.Printf("dependencies recovered, leaving degraded mode")
+					return
+				}
+				log.Printf("dependencies still unavailable: %v", err)
+			}
+		}()
+	} else {
+		attachDependencies(srv, cfg, gdb, store)
+	}
+
+	go reloadOnSIGHUP(srv, *configPath)
+
+	log.Printf("listening on %s", cfg.Addr)
+	if err := r.Run(cfg.Addr); err != nil {
+		log.Fatalf("server error: %v", err)
+	}
+}
+
+func connectDependencies(cfg config.Config, attempts int) (*gorm.DB, *storage.MinioStore, error) {
+	gdb, err := retry("mysql", attempts, func() (*gorm.DB, error) {
+		return db.Connect(cfg.MySQLDSN, mysqlPool(cfg))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	store, err := retry("minio", attempts, func() (*storage.MinioStore, error) {
+		return storage.NewMinioStore(cfg.MinIOEndpoint, cfg.MinIOAccessKey, cfg.MinIOSecretKey, cfg.MinIOSecure, cfg.MinIOBucket)
+	})
 	if err != nil {
-		log.Fatalf("minio connect failed: %v", err)
+		return nil, nil, err
 	}
+	if cfg.ColdBucket != "" {
+		// An empty cold endpoint means another bucket on the same server.
+		endpoint, accessKey, secretKey, secure := cfg.ColdEndpoint, cfg.ColdAccessKey, cfg.ColdSecretKey, cfg.ColdSecure
+		if endpoint == "" {
+			endpoint, secure = cfg.MinIOEndpoint, cfg.MinIOSecure
+		}
+		if accessKey == "" {
+			accessKey, secretKey = cfg.MinIOAccessKey, cfg.MinIOSecretKey
+		}
+		cold, err := retry("minio cold tier", attempts, func() (*storage.MinioStore, error) {
+			return storage.NewMinioStore(endpoint, accessKey, secretKey, secure, cfg.ColdBucket)
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		store.Cold = cold
+	}
+	if cfg.ArchiveKey != "" {
+		// Already validated in config.LoadFile.
+		cipher, _ := storage.NewCipher(cfg.ArchiveKey)
+		store.Cipher = cipher
+		if store.Cold != nil {
+			store.Cold.Cipher = cipher
+		}
+	}
+	return gdb, store, nil
+}
+
+// loadBlocklist extends the built-in tracker hosts with a filter list file;
+// an unreadable file leaves the built-in list in place.
+func loadBlocklist(path string) *processor.Blocklist {
+	blocklist := processor.NewBlocklist()
+	if path == "" {
+		return blocklist
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		log.Printf("blocklist %s unavailable, using built-in trackers: %v", path, err)
+		return blocklist
+	}
+	defer f.Close()
+	added, err := blocklist.Load(f)
+	if err != nil {
+		log.Printf("blocklist %s: read failed after %d rules: %v", path, added, err)
+	}
+	log.Printf("blocklist %s: %d host rules", path, added)
+	return blocklist
+}
+
+// loadScrubber builds the personal data scrubber; nil when nothing is
+// configured. A patterns file that can't be read is logged and skipped.
+func loadScrubber(cfg config.Config) *processor.Scrubber {
+	// The rules already passed validation in config.LoadFile.
+	scrubber, _ := processor.NewScrubber(strings.Split(cfg.ScrubRules, ","))
+	scrubber.AddTerms(strings.Split(cfg.ScrubTerms, ","))
+	if cfg.ScrubPatternFile != "" {
+		f, err := os.Open(cfg.ScrubPatternFile)
+		if err != nil {
+			log.Printf("scrub patterns %s unavailable: %v", cfg.ScrubPatternFile, err)
+		} else {
+			added, err := scrubber.Load(f)
+			f.Close()
+			if err != nil {
+				log.Printf("scrub patterns %s: %v", cfg.ScrubPatternFile, err)
+			}
+			log.Printf("scrub patterns %s: %d patterns", cfg.ScrubPatternFile, added)
+		}
+	}
+	if scrubber.Empty() {
+		return nil
+	}
+	return scrubber
+}
+
+// viewerSecret is the key viewer links are signed with. Without
+// ARCHIVE_VIEWER_SECRET a random one is used, so links stop working on
+// restart and differ between replicas.
+func viewerSecret(secret string) []byte {
+	if secret != "" {
+		return []byte(secret)
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		log.Fatalf("generate viewer secret: %v", err)
+	}
+	return key
+}
+
+func mysqlPool(cfg config.Config) db.Pool {
+	return db.Pool{MaxOpen: cfg.MySQLMaxOpen, MaxIdle: cfg.MySQLMaxIdle, MaxLifetime: cfg.MySQLMaxLifetime}
+}
+
+// connectReplica opens the read replica, falling back to the primary when it
+// is not configured or cannot be reached: a lagging or missing replica should
+// not keep the server from starting.
+func connectReplica(cfg config.Config, primary *gorm.DB) *gorm.DB {
+	if cfg.MySQLReadDSN == "" {
+		return primary
+	}
+	replica, err := db.ConnectReplica(cfg.MySQLReadDSN, mysqlPool(cfg))
+	if err != nil {
+		log.Printf("mysql read replica unavailable, reading from primary: %v", err)
+		return primary
+	}
+	return replica
+}
 
-	proc := processor.New(store, cfg.HTTPTimeout)
+func retry[T any](name string, attempts int, connect func() (T, error)) (T, error) {
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := time.Second
+	var out T
+	var err error
+	for i := 1; i <= attempts; i++ {
+		out, err = connect()
+		if err == nil {
+			return out, nil
+		}
+		log.Printf("%s connect failed (attempt %d/%d): %v", name, i, attempts, err)
+		if i == attempts {
+			break
+		}
+		time.Sleep(backoff)
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+	return out, err
+}
+
+func attachDependencies(srv *api.Server, cfg config.Config, gdb *gorm.DB, store *storage.MinioStore) {
 	var llmClient *ai.Client
 	llmCfg, err := settings.LoadLLM(gdb)
 	if err != nil {
@@ -49,40 +270,245 @@ func main() {
 	}
 	if cfg.LLMEnabled || apiKey != "" {
 		llmClient = ai.NewClient(baseURL, apiKey, model, cfg.LLMTimeout)
+		llmClient.SetBreaker(cfg.LLMBreakerFails, cfg.LLMBreakerReset)
+		llmClient.EmbeddingModel = cfg.LLMEmbedModel
+		if cfg.LLMFallbackURL != "" && cfg.LLMFallbackKey != "" && cfg.LLMFallbackModel != "" {
+			llmClient.Fallback = ai.NewClient(cfg.LLMFallbackURL, cfg.LLMFallbackKey, cfg.LLMFallbackModel, cfg.LLMTimeout)
+			llmClient.Fallback.SetBreaker(cfg.LLMBreakerFails, cfg.LLMBreakerReset)
+		}
+	}
+	// Both already passed validation in config.LoadFile.
+	fetchProxy, _ := proxy.Parse(cfg.FetchProxy, cfg.FetchProxyRules)
+	llmProxy, _ := proxy.Parse(cfg.LLMProxy, cfg.LLMProxyRules)
+	extractors, _ := extractor.New(cfg.ExtractorBuiltin, cfg.ExtractorURLs)
+	llmLimiter := ai.NewLimiter(cfg.LLMConcurrency)
+	if llmClient != nil {
+		llmClient.SetProxy(llmProxy)
+		llmClient.SetLimiter(llmLimiter)
 	}
 
-	var einoAnalyzer *graphflow.Analyzer
-	if cfg.EinoEnabled {
-		analyzer, err := graphflow.NewAnalyzer()
-		if err != nil {
-			log.Printf("eino disabled: %v", err)
-		} else {
-			einoAnalyzer = analyzer
+	srv.DB = gdb
+	srv.ReadDB = connectReplica(cfg, gdb)
+	srv.Store = store
+	store.Sealed = srv.ArchiveEncrypted
+	srv.Cache = newCache(cfg)
+	srv.TieringMonths = cfg.TieringMonths
+	srv.QuotaBytes = int64(cfg.QuotaBytes)
+	srv.MaxPayloadBytes = int64(cfg.MaxPayloadBytes)
+	srv.UserQuotaBytes = int64(cfg.UserQuotaBytes)
+	srv.PriceWebhookURL = cfg.PriceWebhookURL
+	srv.WatchWebhookURL = cfg.WatchWebhookURL
+	srv.WatchMinChangePercent = float64(cfg.WatchMinChange)
+	srv.ReminderWebhookURL = cfg.RemindWebhookURL
+	srv.SMTP = smtpConfig(cfg)
+	for _, to := range strings.Split(cfg.DeviceEmailTo, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			srv.DeviceEmails = append(srv.DeviceEmails, to)
 		}
 	}
+	srv.NotifyWebhookURL = cfg.NotifyWebhookURL
+	for _, to := range strings.Split(cfg.NotifyEmailTo, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			srv.NotifyEmails = append(srv.NotifyEmails, to)
+		}
+	}
+	srv.RecaptureKeepVersions = cfg.RecaptureKeep
+	srv.Processor = processor.New(store, cfg.HTTPTimeout)
+	srv.Processor.SetProxy(fetchProxy)
+	srv.Processor.SetMemoryLimit(int64(cfg.AssetMemoryBytes))
+	srv.Processor.SetParseLimits(processor.ParseLimits{
+		MaxBytes: int64(cfg.ParseMaxBytes),
+		MaxNodes: cfg.ParseMaxNodes,
+		MaxDepth: cfg.ParseMaxDepth,
+		Timeout:  cfg.ParseTimeout,
+	})
+	srv.Processor.SetPoliteness(processor.Politeness{
+		RespectRobots: cfg.FetchRobots,
+		HostDelay:     cfg.FetchHostDelay,
+		MaxConcurrent: cfg.FetchConcurrency,
+	})
+	srv.Processor.SetBlocklist(loadBlocklist(cfg.BlocklistFile))
+	srv.Processor.SetScrubber(loadScrubber(cfg))
+	srv.BlockTrackers = cfg.BlockTrackers
+	srv.TitleCleanup, srv.TitleCleanupLLM = cfg.TitleCleanup, cfg.TitleCleanupLLM
+	srv.ArchiveSlugs = cfg.ArchiveIDFormat == "slug"
+	srv.Extractors = extractors
+	srv.PDFTools = processor.PDFTools{TextCommand: cfg.PDFTextCommand, ThumbnailCommand: cfg.PDFThumbCommand}
+	srv.LLM = llmClient
+	srv.LLMProxy = llmProxy
+	srv.LLMLimiter = llmLimiter
+	if cfg.VectorStore == "qdrant" {
+		srv.Vectors = vectorstore.NewQdrantStore(cfg.QdrantURL, cfg.QdrantAPIKey, cfg.QdrantPrefix)
+	} else {
+		srv.Vectors = vectorstore.NewDBStore(gdb)
+	}
+	if cfg.SearchIndex == "meilisearch" {
+		srv.SearchIndex = searchindex.NewMeiliIndex(cfg.MeiliURL, cfg.MeiliAPIKey, cfg.MeiliIndex)
+	} else {
+		ngram := cfg.SearchNgram
+		if ngram {
+			if err := db.EnsureNgramIndex(gdb); err != nil {
+				log.Printf("ngram fulltext index unavailable, searching with LIKE: %v", err)
+				ngram = false
+			}
+		}
+		srv.SearchIndex = searchindex.NewDBIndex(gdb, ngram)
+	}
+	if cfg.AuthEnabled {
+		if err := srv.BootstrapAdmin(cfg.AdminUsername, cfg.AdminPassword); err != nil {
+			log.Printf("bootstrap admin failed: %v", err)
+		}
+	}
+	// Workers start only once the database is up: with a shared backend
+	// they would otherwise take other replicas' jobs they can't run.
+	srv.StartTagQueue(context.Background(), newTagQueue(cfg), cfg.AutoTagWorkers, cfg.AutoTagRetries)
+	applyRuntime(srv, cfg)
+	srv.SetReady(true)
+	go srv.BackfillContentStats()
+	go srv.BackfillCanonicalURLs()
+	go srv.RelativizeAssetURLs()
+	go srv.BackfillStorageBytes()
+	go srv.BackfillAuthors()
+	go srv.BackfillSlugs()
+	srv.StartDigestScheduler(context.Background(), digestOptions(cfg))
+	srv.StartRetentionScheduler(context.Background(), cfg.RetentionEvery)
+	srv.StartTieringScheduler(context.Background(), cfg.TieringEvery)
+	srv.StartMetaRefreshScheduler(context.Background(), cfg.MetaRefreshEvery)
+	srv.StartPriceTrackScheduler(context.Background(), cfg.PriceTrackEvery)
+	srv.StartWatchScheduler(context.Background(), cfg.WatchEvery)
+	srv.StartRecaptureScheduler(context.Background(), cfg.RecaptureEvery)
+	srv.StartResurfaceScheduler(context.Background(), cfg.ResurfaceEvery)
+	srv.StartConsistencyScheduler(context.Background(), cfg.ConsistencyEvery, cfg.ConsistencyRepair)
+	srv.StartStatsSnapshotScheduler(context.Background(), cfg.SnapshotEvery, cfg.SnapshotKeep)
+	srv.StartTaxonomyWebhookScheduler(context.Background())
+	srv.StartReminderScheduler(context.Background(), cfg.RemindEvery)
+}
 
-	r := gin.Default()
-	r.Use(corsMiddleware())
+func newCache(cfg config.Config) *cache.Cache {
+	switch cfg.CacheBackend {
+	case "memory":
+		return cache.New(cache.NewMemory(cfg.CacheMaxEntries), cfg.CacheTTL)
+	case "redis":
+		redis := cache.NewRedis(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := redis.Ping(ctx); err != nil {
+			log.Printf("redis cache unavailable, serving uncached until it recovers: %v", err)
+		}
+		return cache.New(redis, cfg.CacheTTL)
+	}
+	return nil
+}
 
-	srv := &api.Server{
-		DB:        gdb,
-		Store:     store,
-		Processor: proc,
-		LLM:       llmClient,
-		AutoTag:   cfg.AutoTagOnCapture,
-		Eino:      einoAnalyzer,
+func newTagQueue(cfg config.Config) queue.Queue {
+	if cfg.AutoTagBackend == "redis" {
+		client := cache.NewRedis(cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB)
+		return queue.NewRedis(client, "webarchive:queue:autotag", cfg.AutoTagQueueSize)
 	}
-	srv.RegisterRoutes(r)
+	return queue.NewMemory(cfg.AutoTagQueueSize)
+}
 
-	log.Printf("listening on %s", cfg.Addr)
-	if err := r.Run(cfg.Addr); err != nil {
-		log.Fatalf("server error: %v", err)
+func smtpConfig(cfg config.Config) notify.SMTPConfig {
+	return notify.SMTPConfig{
+		Addr:     cfg.SMTPAddr,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	}
+}
+
+func digestOptions(cfg config.Config) api.DigestOptions {
+	opts := api.DigestOptions{
+		WebhookURL: cfg.DigestWebhookURL,
+		SMTP:       smtpConfig(cfg),
+	}
+	if cfg.DigestSchedule != "off" {
+		opts.Schedule = cfg.DigestSchedule
+	}
+	for _, to := range strings.Split(cfg.DigestEmailTo, ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			opts.EmailTo = append(opts.EmailTo, to)
+		}
+	}
+	return opts
+}
+
+// applyRuntime layers the runtime overrides saved through /api/settings on
+// top of the file/env configuration and pushes the result into the server.
+func applyRuntime(srv *api.Server, cfg config.Config) {
+	rt, err := settings.LoadRuntime(srv.DB, runtimeFromConfig(cfg))
+	if err != nil {
+		log.Printf("load runtime settings failed: %v", err)
+	}
+	srv.ApplyRuntime(rt)
+}
+
+func runtimeFromConfig(cfg config.Config) settings.RuntimeSettings {
+	return settings.RuntimeSettings{
+		HTTPTimeoutSeconds:     int(cfg.HTTPTimeout / time.Second),
+		MaxAssetBytes:          int64(cfg.MaxAssetBytes),
+		AutoTagOnCapture:       cfg.AutoTagOnCapture,
+		AutoTagWorkers:         cfg.AutoTagWorkers,
+		LLMTimeoutSeconds:      int(cfg.LLMTimeout / time.Second),
+		LLMConcurrency:         cfg.LLMConcurrency,
+		UserAgent:              cfg.FetchUserAgent,
+		TaxonomyRouter:         cfg.TaxonomyRouter,
+		TaxonomyMaxDepth:       cfg.TaxonomyDepth,
+		TaxonomyMaxOptions:     cfg.TaxonomyOptions,
+		TaxonomyMaxPathLength:  cfg.TaxonomyPathLen,
+		TaxonomyMaxLabelLength: cfg.TaxonomyLabelLen,
+		TaxonomyMaxLabelWords:  cfg.TaxonomyWords,
+		TaxonomyBannedChars:    cfg.TaxonomyBanned,
+		TaxonomyLabelCase:      cfg.TaxonomyCase,
+		TaxonomyMergeSimilar:   cfg.TaxonomyMerge,
+		LLMTrace:               cfg.LLMTrace,
+		LLMTraceRetentionDays:  cfg.LLMTraceDays,
+		CaptureHosts:           processor.HostRules{Allow: splitHosts(cfg.FetchAllowHosts), Deny: splitHosts(cfg.FetchDenyHosts)},
 	}
 }
 
+func splitHosts(list string) []string {
+	out := []string{}
+	for _, host := range strings.Split(list, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			out = append(out, host)
+		}
+	}
+	return out
+}
+
+// reloadOnSIGHUP re-reads the config file and reapplies runtime settings.
+// Connection settings (DSN, MinIO endpoint, listen address) still need a
+// restart.
+func reloadOnSIGHUP(srv *api.Server, path string) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		cfg, err := config.LoadFile(path)
+		if err != nil {
+			log.Printf("reload config failed, keeping current settings: %v", err)
+			continue
+		}
+		if !srv.Ready() {
+			log.Printf("reload skipped: dependencies unavailable")
+			continue
+		}
+		applyRuntime(srv, cfg)
+	}
+}
+
+// corsMiddleware opens the API to other origins. Only an origin on the API's
+// own host, such as the frontend dev server on another port, may send the
+// login cookie: the browser treats it as the same site anyway.
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
+		if origin := c.GetHeader("Origin"); sameHostOrigin(origin, c.Request.Host) {
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Access-Control-Allow-Credentials", "true")
+			c.Header("Vary", "Origin")
+		} else {
+			c.Header("Access-Control-Allow-Origin", "*")
+		}
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PATCH, DELETE, OPTIONS")
 		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		if c.Request.Method == http.MethodOptions {
@@ -92,3 +518,14 @@ func corsMiddleware() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+func sameHostOrigin(origin, host string) bool {
+	u, err := url.Parse(origin)
+	if origin == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.EqualFold(u.Hostname(), strings.Trim(host, "[]"))
+}
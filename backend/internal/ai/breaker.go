@@ -0,0 +1,115 @@
+package ai
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// Breaker is a consecutive-failure circuit breaker. After threshold failures
+// it opens and rejects calls for cooldown, then lets a single trial call
+// through (half-open); success closes it again, failure reopens it.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	cooldown  time.Duration
+	state     string
+	failures  int
+	openedAt  time.Time
+	trial     bool
+	stats     BreakerStatus
+}
+
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutiveFailures"`
+	Requests            int64      `json:"requests"`
+	Failures            int64      `json:"failures"`
+	LastError           string     `json:"lastError,omitempty"`
+	LastFailureAt       *time.Time `json:"lastFailureAt"`
+	LastSuccessAt       *time.Time `json:"lastSuccessAt"`
+	OpenUntil           *time.Time `json:"openUntil,omitempty"`
+}
+
+func NewBreaker(threshold int, cooldown time.Duration) *Breaker {
+	if threshold < 1 {
+		threshold = 1
+	}
+	return &Breaker{threshold: threshold, cooldown: cooldown, state: BreakerClosed}
+}
+
+func (b *Breaker) Allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return true
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	}
+	return true
+}
+
+func (b *Breaker) Success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.state = BreakerClosed
+	b.failures = 0
+	b.trial = false
+	b.stats.Requests++
+	b.stats.LastSuccessAt = &now
+}
+
+func (b *Breaker) Failure(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := time.Now()
+	b.failures++
+	b.trial = false
+	b.stats.Requests++
+	b.stats.Failures++
+	b.stats.LastFailureAt = &now
+	if err != nil {
+		b.stats.LastError = err.Error()
+	}
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = now
+	}
+}
+
+// Abort releases a half-open trial without judging the provider, e.g. when
+// the caller's context was cancelled.
+func (b *Breaker) Abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := b.stats
+	out.State = b.state
+	out.ConsecutiveFailures = b.failures
+	if b.state == BreakerOpen {
+		until := b.openedAt.Add(b.cooldown)
+		out.OpenUntil = &until
+	}
+	return out
+}
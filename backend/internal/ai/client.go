@@ -9,14 +9,38 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
+
+	"webarchive/internal/proxy"
+	"webarchive/internal/textutil"
 )
 
 type Client struct {
 	BaseURL string
 	APIKey  string
 	Model   string
-	HTTP    *http.Client
+	// HTTP is replaced rather than modified once the client is in use;
+	// read it through httpClient.
+	HTTP *http.Client
+	// EmbeddingModel enables Embed; empty disables semantic features.
+	EmbeddingModel string
+	// Fallback receives calls while this client's breaker is open or after
+	// a call to this client fails.
+	Fallback *Client
+	// RepairAttempts bounds DecodeJSON's re-prompts after a malformed reply.
+	RepairAttempts int
+	breaker        *Breaker
+	limiter        *Limiter
+	httpMu         sync.RWMutex
+}
+
+type ProviderStatus struct {
+	Role    string `json:"role"`
+	BaseURL string `json:"baseUrl"`
+	Model   string `json:"model"`
+	Enabled bool   `json:"enabled"`
+	BreakerStatus
 }
 
 type TagInput struct {
@@ -59,6 +83,96 @@ func NewClient(baseURL, apiKey, model string, timeout time.Duration) *Client {
 		HTTP: &http.Client{
 			Timeout: timeout,
 		},
+		RepairAttempts: DefaultRepairAttempts,
+		breaker:        NewBreaker(5, time.Minute),
+	}
+}
+
+// SetProxy routes LLM requests, including the fallback provider's, through
+// rules.
+func (c *Client) SetProxy(rules *proxy.Rules) {
+	c.swapHTTP(func(h *http.Client) { h.Transport = rules.Transport() })
+	if c.Fallback != nil {
+		c.Fallback.SetProxy(rules)
+	}
+}
+
+// SetLimiter makes this client and its fallback wait for a slot in l before
+// each call.
+func (c *Client) SetLimiter(l *Limiter) {
+	c.limiter = l
+	if c.Fallback != nil {
+		c.Fallback.SetLimiter(l)
+	}
+}
+
+// SetTimeout bounds each provider request. Calls already in flight keep
+// the timeout they started with.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.swapHTTP(func(h *http.Client) { h.Timeout = timeout })
+	if c.Fallback != nil {
+		c.Fallback.SetTimeout(timeout)
+	}
+}
+
+// swapHTTP replaces the HTTP client with a copy changed by edit, so requests
+// using the old one are not raced.
+func (c *Client) swapHTTP(edit func(*http.Client)) {
+	c.httpMu.Lock()
+	defer c.httpMu.Unlock()
+	if c.HTTP == nil {
+		return
+	}
+	next := *c.HTTP
+	edit(&next)
+	c.HTTP = &next
+}
+
+func (c *Client) httpClient() *http.Client {
+	c.httpMu.RLock()
+	defer c.httpMu.RUnlock()
+	return c.HTTP
+}
+
+// Standalone returns a client for the same provider using model, with no
+// fallback and a breaker of its own, so every answer comes from exactly that
+// model and its failures do not trip the live client.
+func (c *Client) Standalone(model string) *Client {
+	if model == "" {
+		model = c.Model
+	}
+	return &Client{
+		BaseURL:        c.BaseURL,
+		APIKey:         c.APIKey,
+		Model:          model,
+		HTTP:           c.httpClient(),
+		EmbeddingModel: c.EmbeddingModel,
+		RepairAttempts: c.RepairAttempts,
+		breaker:        NewBreaker(5, time.Minute),
+		limiter:        c.limiter,
+	}
+}
+
+func (c *Client) SetBreaker(threshold int, cooldown time.Duration) {
+	c.breaker = NewBreaker(threshold, cooldown)
+}
+
+// Providers reports the health of this client and its fallback.
+func (c *Client) Providers() []ProviderStatus {
+	out := []ProviderStatus{c.providerStatus("primary")}
+	if c.Fallback != nil {
+		out = append(out, c.Fallback.providerStatus("fallback"))
+	}
+	return out
+}
+
+func (c *Client) providerStatus(role string) ProviderStatus {
+	return ProviderStatus{
+		Role:          role,
+		BaseURL:       c.BaseURL,
+		Model:         c.Model,
+		Enabled:       c.Enabled(),
+		BreakerStatus: c.breaker.Status(),
 	}
 }
 
@@ -71,10 +185,7 @@ func (c *Client) Tag(ctx context.Context, input TagInput) (TagResult, error) {
 		return TagResult{}, errors.New("llm not configured")
 	}
 
-	content := strings.TrimSpace(input.Content)
-	if len(content) > 6000 {
-		content = content[:6000]
-	}
+	content := textutil.Truncate(strings.TrimSpace(input.Content), 6000)
 
 	system := "You are a taxonomy assistant. Return strict JSON only."
 	user := fmt.Sprintf(
@@ -84,17 +195,8 @@ func (c *Client) Tag(ctx context.Context, input TagInput) (TagResult, error) {
 		input.Title, input.URL, input.Excerpt, content,
 	)
 
-	raw, err := c.ChatJSON(ctx, system, user, 0.2)
-	if err != nil {
-		return TagResult{}, err
-	}
-	raw = extractJSON(raw)
-	if raw == "" {
-		return TagResult{}, errors.New("llm invalid json")
-	}
-
 	var out TagResult
-	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+	if err := c.DecodeJSON(ctx, system, user, 0.2, &out, out.validate); err != nil {
 		return TagResult{}, err
 	}
 	out.Tags = normalizeList(out.Tags)
@@ -102,10 +204,59 @@ func (c *Client) Tag(ctx context.Context, input TagInput) (TagResult, error) {
 	return out, nil
 }
 
+func (r *TagResult) validate() error {
+	if strings.TrimSpace(r.Category) == "" && len(normalizeList(r.Path)) == 0 {
+		return errors.New("category or path is required")
+	}
+	return nil
+}
+
+// ChatJSON sends the prompt to the primary provider, falling back to
+// c.Fallback when the primary's breaker is open or the call fails.
 func (c *Client) ChatJSON(ctx context.Context, system, user string, temperature float64) (string, error) {
 	if !c.Enabled() {
 		return "", errors.New("llm not configured")
 	}
+	fallback := c.Fallback.Enabled()
+	if !c.breaker.Allow() {
+		if !fallback {
+			return "", errors.New("llm primary unavailable (circuit open)")
+		}
+		return c.Fallback.chatTracked(ctx, system, user, temperature)
+	}
+	out, err := c.chat(ctx, system, user, temperature)
+	if err == nil {
+		c.breaker.Success()
+		return out, nil
+	}
+	if ctx.Err() != nil {
+		// The caller gave up; that says nothing about provider health.
+		c.breaker.Abort()
+		return "", err
+	}
+	c.breaker.Failure(err)
+	if !fallback {
+		return "", err
+	}
+	return c.Fallback.chatTracked(ctx, system, user, temperature)
+}
+
+func (c *Client) chatTracked(ctx context.Context, system, user string, temperature float64) (string, error) {
+	out, err := c.chat(ctx, system, user, temperature)
+	switch {
+	case err == nil:
+		c.breaker.Success()
+	case ctx.Err() != nil:
+		c.breaker.Abort()
+	default:
+		c.breaker.Failure(err)
+	}
+	return out, err
+}
+
+func (c *Client) chat(ctx context.Context, system, user string, temperature float64) (reply string, err error) {
+	start := time.Now()
+	defer func() { traceCall(ctx, c.Model, system, user, reply, err, start) }()
 	reqBody := chatRequest{
 		Model: c.Model,
 		Messages: []chatMessage{
@@ -126,7 +277,12 @@ func (c *Client) ChatJSON(ctx context.Context, system, user string, temperature
 	req.Header.Set("Authorization", "Bearer "+c.APIKey)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.HTTP.Do(req)
+	release, err := c.limiter.acquire(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+	resp, err := c.httpClient().Do(req)
 	if err != nil {
 		return "", err
 	}
@@ -147,6 +303,29 @@ func (c *Client) ChatJSON(ctx context.Context, system, user string, temperature
 	return strings.TrimSpace(res.Choices[0].Message.Content), nil
 }
 
+// Ping checks that the provider is reachable and accepts the API key by
+// listing models, which every OpenAI-compatible server supports cheaply.
+func (c *Client) Ping(ctx context.Context) error {
+	if !c.Enabled() {
+		return errors.New("llm not configured")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiBase()+"/models", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("llm status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
 func (c *Client) endpoint() string {
 	base := strings.TrimRight(c.BaseURL, "/")
 	if strings.HasSuffix(base, "/chat/completions") {
@@ -158,6 +337,10 @@ func (c *Client) endpoint() string {
 	return base + "/v1/chat/completions"
 }
 
+func (c *Client) apiBase() string {
+	return strings.TrimSuffix(c.endpoint(), "/chat/completions")
+}
+
 func extractJSON(text string) string {
 	start := strings.Index(text, "{")
 	end := strings.LastIndex(text, "}")
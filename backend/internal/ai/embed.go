@@ -0,0 +1,79 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+}
+
+func (c *Client) EmbeddingsEnabled() bool {
+	return c.Enabled() && c.EmbeddingModel != ""
+}
+
+// Embed returns one vector per input, in input order. Embeddings never go
+// to the fallback provider: vectors from different models are not
+// comparable.
+func (c *Client) Embed(ctx context.Context, inputs []string) ([][]float32, error) {
+	if !c.EmbeddingsEnabled() {
+		return nil, errors.New("embedding model not configured")
+	}
+	payload, err := json.Marshal(embeddingRequest{Model: c.EmbeddingModel, Input: inputs})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.apiBase()+"/embeddings", bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	release, err := c.limiter.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("embedding error: %s", strings.TrimSpace(string(body)))
+	}
+
+	var res embeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, err
+	}
+	out := make([][]float32, len(inputs))
+	for _, d := range res.Data {
+		if d.Index >= 0 && d.Index < len(out) {
+			out[d.Index] = d.Embedding
+		}
+	}
+	for i, v := range out {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("embedding missing for input %d", i)
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,82 @@
+package ai
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Limiter caps the provider calls in flight across every feature sharing
+// it (auto-tagging at capture, manual tagging, the analyzer, embeddings), so
+// overlapping work queues here instead of tripping the provider's rate
+// limits. A nil *Limiter never waits.
+type Limiter struct {
+	// slots is nil while calls are not limited. SetLimit swaps in a new
+	// channel; a call releases its slot to the channel it took it from.
+	slots   atomic.Pointer[chan struct{}]
+	waiting atomic.Int64
+}
+
+type LimiterStatus struct {
+	// Limit is 0 when calls are not limited.
+	Limit    int `json:"limit"`
+	InFlight int `json:"inFlight"`
+	Waiting  int `json:"waiting"`
+}
+
+// NewLimiter allows n concurrent calls; n <= 0 means no limit.
+func NewLimiter(n int) *Limiter {
+	l := &Limiter{}
+	l.SetLimit(n)
+	return l
+}
+
+// SetLimit changes the limit to n, or lifts it when n <= 0. Calls already
+// holding or waiting for a slot finish under the old limit; InFlight only
+// counts calls started after the change.
+func (l *Limiter) SetLimit(n int) {
+	if l == nil {
+		return
+	}
+	if n <= 0 {
+		l.slots.Store(nil)
+		return
+	}
+	slots := make(chan struct{}, n)
+	l.slots.Store(&slots)
+}
+
+// acquire waits for a free slot or for ctx to end.
+func (l *Limiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	slots := l.slots.Load()
+	if slots == nil {
+		return func() {}, nil
+	}
+	release := func() { <-*slots }
+	select {
+	case *slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+	l.waiting.Add(1)
+	defer l.waiting.Add(-1)
+	select {
+	case *slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *Limiter) Status() LimiterStatus {
+	if l == nil {
+		return LimiterStatus{}
+	}
+	slots := l.slots.Load()
+	if slots == nil {
+		return LimiterStatus{Waiting: int(l.waiting.Load())}
+	}
+	return LimiterStatus{Limit: cap(*slots), InFlight: len(*slots), Waiting: int(l.waiting.Load())}
+}
@@ -0,0 +1,56 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"webarchive/internal/textutil"
+)
+
+// DefaultRepairAttempts is how many times DecodeJSON re-prompts after a
+// reply that fails to parse or validate.
+const DefaultRepairAttempts = 2
+
+// DecodeJSON asks for a JSON reply and decodes it into out. When the reply
+// is not valid JSON or validate rejects it, the model is shown its reply and
+// the error and asked again, up to c.RepairAttempts times. validate may be
+// nil.
+func (c *Client) DecodeJSON(ctx context.Context, system, user string, temperature float64, out any, validate func() error) error {
+	prompt := user
+	var lastErr error
+	for attempt := 0; attempt <= c.RepairAttempts; attempt++ {
+		raw, err := c.ChatJSON(ctx, system, prompt, temperature)
+		if err != nil {
+			// Transport failures are the breaker's business, not ours.
+			return err
+		}
+		lastErr = decodeReply(raw, out, validate)
+		if lastErr == nil {
+			return nil
+		}
+		prompt = fmt.Sprintf("%s\n\nYour previous reply was rejected: %v\nPrevious reply:\n%s\n"+
+			"Reply again with corrected strict JSON only.", user, lastErr, textutil.Truncate(raw, 2000))
+	}
+	return fmt.Errorf("llm invalid json: %w", lastErr)
+}
+
+func decodeReply(raw string, out any, validate func() error) error {
+	body := extractJSON(raw)
+	if body == "" {
+		return errors.New("no JSON object found")
+	}
+	// Start each attempt from zero so fields from a rejected reply don't leak
+	// into the next one.
+	v := reflect.ValueOf(out).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	if err := json.Unmarshal([]byte(body), out); err != nil {
+		return err
+	}
+	if validate != nil {
+		return validate()
+	}
+	return nil
+}
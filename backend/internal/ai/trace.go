@@ -0,0 +1,65 @@
+package ai
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// TraceCall is one chat exchange exactly as sent and received.
+type TraceCall struct {
+	Model      string    `json:"model"`
+	System     string    `json:"system"`
+	User       string    `json:"user"`
+	Response   string    `json:"response"`
+	Error      string    `json:"error,omitempty"`
+	DurationMS int64     `json:"durationMs"`
+	At         time.Time `json:"at"`
+}
+
+// Trace collects the chat calls made with a context it is attached to,
+// including repair re-prompts and fallback calls.
+type Trace struct {
+	mu    sync.Mutex
+	calls []TraceCall
+}
+
+type traceKey struct{}
+
+// WithTrace returns a context whose chat calls are recorded in t.
+func WithTrace(ctx context.Context, t *Trace) context.Context {
+	return context.WithValue(ctx, traceKey{}, t)
+}
+
+// Calls returns the calls recorded so far, oldest first.
+func (t *Trace) Calls() []TraceCall {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]TraceCall(nil), t.calls...)
+}
+
+func (t *Trace) add(call TraceCall) {
+	t.mu.Lock()
+	t.calls = append(t.calls, call)
+	t.mu.Unlock()
+}
+
+// traceCall records a finished chat call when ctx carries a trace.
+func traceCall(ctx context.Context, model, system, user, reply string, err error, start time.Time) {
+	t, _ := ctx.Value(traceKey{}).(*Trace)
+	if t == nil {
+		return
+	}
+	call := TraceCall{
+		Model:      model,
+		System:     system,
+		User:       user,
+		Response:   reply,
+		DurationMS: time.Since(start).Milliseconds(),
+		At:         start,
+	}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	t.add(call)
+}
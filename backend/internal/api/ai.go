@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -14,6 +16,7 @@ import (
 	"webarchive/internal/graphflow"
 	"webarchive/internal/models"
 	"webarchive/internal/settings"
+	"webarchive/internal/textutil"
 )
 
 type AIConfigRequest struct {
@@ -29,8 +32,11 @@ func (s *Server) updateAIConfig(c *gin.Context) {
 		return
 	}
 
+	before := s.llmAuditSnapshot()
 	if s.LLM == nil {
 		s.LLM = ai.NewClient(req.BaseURL, req.APIKey, req.Model, 30*time.Second)
+		s.LLM.SetProxy(s.LLMProxy)
+		s.LLM.SetLimiter(s.LLMLimiter)
 	} else {
 		if req.BaseURL != "" {
 			s.LLM.BaseURL = req.BaseURL
@@ -42,8 +48,12 @@ func (s *Server) updateAIConfig(c *gin.Context) {
 			s.LLM.Model = req.Model
 		}
 	}
-	if s.LLM != nil && s.LLM.HTTP != nil {
-		s.LLM.HTTP.Timeout = 90 * time.Second
+	if s.LLM != nil {
+		timeout := 90 * time.Second
+		if rt := s.runtimeSettings(); rt.LLMTimeoutSeconds > 0 {
+			timeout = time.Duration(rt.LLMTimeoutSeconds) * time.Second
+		}
+		s.LLM.SetTimeout(timeout)
 	}
 
 	if s.LLM != nil {
@@ -56,11 +66,14 @@ func (s *Server) updateAIConfig(c *gin.Context) {
 			return
 		}
 	}
+	s.recordAdminAudit(c, AuditAIConfig, "llm", before, s.llmAuditSnapshot())
 
 	c.JSON(http.StatusOK, gin.H{
-		"baseUrl": s.LLM.BaseURL,
-		"model":   s.LLM.Model,
-		"enabled": s.LLM.Enabled(),
+		"baseUrl":   s.LLM.BaseURL,
+		"model":     s.LLM.Model,
+		"apiKey":    settings.MaskSecret(s.LLM.APIKey),
+		"encrypted": settings.EncryptionEnabled(),
+		"enabled":   s.LLM.Enabled(),
 	})
 }
 
@@ -79,125 +92,182 @@ func (s *Server) aiTagArchive(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 90*time.Second)
 	defer cancel()
 
+	before := s.snapshotArchive(item)
 	updated, err := s.classifyArchive(ctx, item)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	s.recordArchiveEvent(updated.ID, EventAITag, "llm", before, s.snapshotArchive(updated))
+	// A manual run that succeeds supersedes a queued job that gave up.
+	if updated.AITaskState == AITaskError {
+		s.setAITaskState(updated.ID, AITaskDone, "")
+		updated.AITaskState, updated.AITaskError = AITaskDone, ""
+	}
 	paths, _ := s.loadArchivePaths(updated.ID)
 	c.JSON(http.StatusOK, toArchiveResponse(updated, paths))
 }
 
-func (s *Server) tagArchive(ctx context.Context, item models.Archive) (models.Archive, error) {
-	input := ai.TagInput{
-		Title:   item.Title,
-		URL:     item.URL,
-		Content: item.ContentText,
-		Excerpt: item.Excerpt,
-	}
-	result, err := s.LLM.Tag(ctx, input)
-	if err != nil {
-		return item, err
-	}
+// classifyColumns are what the tag-only path sets; graph analysis adds the
+// entity, relation and summary columns, which entity-only runs set alone.
+var (
+	classifyColumns = []string{"category", "tags_json", "hierarchy_json", "hierarchy_path"}
+	entityColumns   = []string{"entities_json", "entity_types_json", "relations_json", "summary"}
+	graphColumns    = append(append([]string{}, classifyColumns...), entityColumns...)
+)
 
-	tagsJSON, _ := json.Marshal(result.Tags)
-	hierarchyJSON, _ := json.Marshal(result.Path)
-	hierarchyPath := strings.Join(result.Path, "/")
-	if hierarchyPath == "" && result.Category != "" {
-		hierarchyPath = result.Category
-		hierarchyJSON, _ = json.Marshal([]string{result.Category})
-	}
+// errNoGraphAnalyzer is returned for entity-only runs when the graphflow
+// analyzer, the only source of entities, isn't available.
+var errNoGraphAnalyzer = errors.New("graph analyzer not available")
 
-	if err := s.DB.Model(&models.Archive{}).
-		Where("id = ?", item.ID).
-		Updates(map[string]any{
-			"category":       result.Category,
-			"tags_json":      tagsJSON,
-			"hierarchy_json": hierarchyJSON,
-			"hierarchy_path": hierarchyPath,
-		}).Error; err != nil {
+func (s *Server) classifyArchive(ctx context.Context, item models.Archive) (models.Archive, error) {
+	proposed, columns, err := s.proposeClassification(ctx, s.LLM, item)
+	if err != nil {
 		return item, err
 	}
+	return s.saveClassification(proposed, columns)
+}
 
-	item.Category = result.Category
-	item.TagsJSON = tagsJSON
-	item.HierarchyJSON = hierarchyJSON
-	item.HierarchyPath = hierarchyPath
-	if hierarchyPath != "" {
-		_ = s.replaceArchivePaths(item.ID, []string{hierarchyPath})
+// extractEntities re-runs entity extraction on item without touching its
+// classification.
+func (s *Server) extractEntities(ctx context.Context, item models.Archive) (models.Archive, error) {
+	proposed, columns, err := s.proposeEntities(ctx, s.LLM, item)
+	if err != nil {
+		return item, err
 	}
-	return item, nil
+	return s.saveClassification(proposed, columns)
 }
 
-func (s *Server) classifyArchive(ctx context.Context, item models.Archive) (models.Archive, error) {
+// proposeClassification runs llm over item and returns it with the
+// classification filled in, plus the columns that were set. Nothing is
+// written, so dry runs can stage the result for review and evaluations can
+// try other models.
+func (s *Server) proposeClassification(ctx context.Context, llm *ai.Client, item models.Archive) (_ models.Archive, _ []string, err error) {
+	ctx, done := s.startAITrace(ctx, item.ID, AITraceClassify, llm.Model)
+	defer func() { done(err) }()
 	nodes, err := s.loadTaxonomyNodes()
 	if err != nil {
-		return s.tagArchive(ctx, item)
+		return s.proposeTags(ctx, llm, item)
 	}
-	if s.Eino != nil && s.LLM != nil && s.LLM.Enabled() {
+	if s.Eino != nil && llm.Enabled() {
 		rootLabels := buildRootLabels(nodes)
 		out, err := s.Eino.Analyze(ctx, graphflow.GraphInput{
 			Archive:  item,
 			Taxonomy: rootLabels,
-			LLM:      s.LLM,
+			LLM:      llm,
 		})
 		if err == nil {
-			return s.applyGraphOutput(item, out)
+			return s.proposeGraphOutput(item, out), graphColumns, nil
 		}
 	}
 
 	path := []string{}
 	if len(nodes) > 0 {
-		path, _ = s.pickPath(ctx, item, nodes)
+		if s.runtimeSettings().TaxonomyRouter == settings.RouterSingle {
+			path, err = s.pickPathSingle(ctx, llm, item, nodes)
+			if err != nil {
+				// Fall back to the stepwise router rather than losing the path.
+				path, _ = s.pickPath(ctx, llm, item, nodes)
+			}
+		} else {
+			path, _ = s.pickPath(ctx, llm, item, nodes)
+		}
 	}
 
-	tagged, err := s.LLM.Tag(ctx, ai.TagInput{
+	tagged, err := llm.Tag(ctx, ai.TagInput{
 		Title:   item.Title,
 		URL:     item.URL,
 		Content: item.ContentText,
 		Excerpt: item.Excerpt,
 	})
 	if err != nil {
-		return item, err
+		return item, nil, err
 	}
 
 	if len(path) == 0 && len(tagged.Path) > 0 {
 		path = tagged.Path
 	}
-	var chosenPath string
+	setClassifiedPath(&item, s.taxonomyLimits().clamp(path), tagged.Category)
+	item.TagsJSON, _ = json.Marshal(tagged.Tags)
+	return item, classifyColumns, nil
+}
+
+// proposeEntities runs only the graphflow extraction over item and returns it
+// with new entities, relations and summary; the tags and path the graph also
+// produces are dropped so curated classification stays as it is.
+func (s *Server) proposeEntities(ctx context.Context, llm *ai.Client, item models.Archive) (_ models.Archive, _ []string, err error) {
+	if s.Eino == nil || !llm.Enabled() {
+		return item, nil, errNoGraphAnalyzer
+	}
+	ctx, done := s.startAITrace(ctx, item.ID, AITraceEntities, llm.Model)
+	defer func() { done(err) }()
+	nodes, _ := s.loadTaxonomyNodes()
+	out, err := s.Eino.Analyze(ctx, graphflow.GraphInput{
+		Archive:  item,
+		Taxonomy: buildRootLabels(nodes),
+		LLM:      llm,
+	})
+	if err != nil {
+		return item, nil, err
+	}
+	setGraphEntities(&item, out)
+	return item, entityColumns, nil
+}
+
+// proposeTags is the fallback when the taxonomy cannot be loaded: the model
+// picks tags and a path on its own.
+func (s *Server) proposeTags(ctx context.Context, llm *ai.Client, item models.Archive) (models.Archive, []string, error) {
+	result, err := llm.Tag(ctx, ai.TagInput{
+		Title:   item.Title,
+		URL:     item.URL,
+		Content: item.ContentText,
+		Excerpt: item.Excerpt,
+	})
+	if err != nil {
+		return item, nil, err
+	}
+
+	path := s.taxonomyLimits().clamp(result.Path)
+	item.Category = result.Category
+	item.TagsJSON, _ = json.Marshal(result.Tags)
+	item.HierarchyJSON, _ = json.Marshal(path)
+	item.HierarchyPath = strings.Join(path, "/")
+	if item.HierarchyPath == "" && result.Category != "" {
+		item.HierarchyPath = result.Category
+		item.HierarchyJSON, _ = json.Marshal([]string{result.Category})
+	}
+	return item, classifyColumns, nil
+}
+
+// setClassifiedPath files item under path, or under category alone when the
+// model gave no path.
+func setClassifiedPath(item *models.Archive, path []string, category string) {
 	if len(path) > 0 {
 		item.Category = path[0]
-		if err := s.ensureTaxonomyPath(path); err != nil {
-			return item, err
-		}
-		hierarchyJSON, _ := json.Marshal(path)
-		item.HierarchyJSON = hierarchyJSON
-		chosenPath = strings.Join(path, "/")
-		item.HierarchyPath = chosenPath
-	} else if tagged.Category != "" {
-		item.Category = tagged.Category
-		chosenPath = tagged.Category
-		item.HierarchyPath = chosenPath
-		item.HierarchyJSON, _ = json.Marshal([]string{tagged.Category})
+		item.HierarchyJSON, _ = json.Marshal(path)
+		item.HierarchyPath = strings.Join(path, "/")
+	} else if category != "" {
+		item.Category = category
+		item.HierarchyPath = category
+		item.HierarchyJSON, _ = json.Marshal([]string{category})
 	}
-	tagsJSON, _ := json.Marshal(tagged.Tags)
-	item.TagsJSON = tagsJSON
+}
 
-	if chosenPath != "" {
-		_ = s.replaceArchivePaths(item.ID, []string{chosenPath})
+// saveClassification writes the given columns of a proposed classification
+// and, when the path is among them, files the archive under it.
+func (s *Server) saveClassification(item models.Archive, columns []string) (models.Archive, error) {
+	if item.HierarchyPath != "" && slices.Contains(columns, "hierarchy_path") {
+		if err := s.ensureTaxonomyPath(jsonStrings(item.HierarchyJSON)); err != nil {
+			return item, err
+		}
+		_ = s.replaceArchivePaths(item.ID, []string{item.HierarchyPath})
 	}
-
 	if err := s.DB.Model(&models.Archive{}).
 		Where("id = ?", item.ID).
-		Updates(map[string]any{
-			"category":       item.Category,
-			"tags_json":      item.TagsJSON,
-			"hierarchy_json": item.HierarchyJSON,
-			"hierarchy_path": item.HierarchyPath,
-		}).Error; err != nil {
+		Select(columns).
+		Updates(&item).Error; err != nil {
 		return item, err
 	}
-
 	return item, nil
 }
 
@@ -218,52 +288,18 @@ func buildRootLabels(nodes []models.TaxonomyNode) []string {
 	return out
 }
 
-func (s *Server) applyGraphOutput(item models.Archive, out graphflow.GraphOutput) (models.Archive, error) {
-	path := out.Path
-	var chosenPath string
-	if len(path) > 0 {
-		item.Category = path[0]
-		if err := s.ensureTaxonomyPath(path); err != nil {
-			return item, err
-		}
-		hierarchyJSON, _ := json.Marshal(path)
-		item.HierarchyJSON = hierarchyJSON
-		chosenPath = strings.Join(path, "/")
-		item.HierarchyPath = chosenPath
-	} else if out.Category != "" {
-		item.Category = out.Category
-		chosenPath = out.Category
-		item.HierarchyPath = chosenPath
-		item.HierarchyJSON, _ = json.Marshal([]string{out.Category})
-	}
-
-	tagsJSON, _ := json.Marshal(out.Tags)
-	item.TagsJSON = tagsJSON
-	entitiesJSON, _ := json.Marshal(out.Entities)
-	relationsJSON, _ := json.Marshal(out.Relations)
-	item.EntitiesJSON = entitiesJSON
-	item.RelationsJSON = relationsJSON
-	item.Summary = strings.TrimSpace(out.Summary)
-
-	if chosenPath != "" {
-		_ = s.replaceArchivePaths(item.ID, []string{chosenPath})
-	}
-
-	if err := s.DB.Model(&models.Archive{}).
-		Where("id = ?", item.ID).
-		Updates(map[string]any{
-			"category":       item.Category,
-			"tags_json":      item.TagsJSON,
-			"hierarchy_json": item.HierarchyJSON,
-			"hierarchy_path": item.HierarchyPath,
-			"entities_json":  item.EntitiesJSON,
-			"relations_json": item.RelationsJSON,
-			"summary":        item.Summary,
-		}).Error; err != nil {
-		return item, err
-	}
+func (s *Server) proposeGraphOutput(item models.Archive, out graphflow.GraphOutput) models.Archive {
+	setClassifiedPath(&item, s.taxonomyLimits().clamp(out.Path), out.Category)
+	item.TagsJSON, _ = json.Marshal(out.Tags)
+	setGraphEntities(&item, out)
+	return item
+}
 
-	return item, nil
+func setGraphEntities(item *models.Archive, out graphflow.GraphOutput) {
+	item.EntitiesJSON, _ = json.Marshal(out.Entities)
+	item.EntityTypesJSON, _ = json.Marshal(out.EntityTypes)
+	item.RelationsJSON, _ = json.Marshal(out.Relations)
+	item.Summary = strings.TrimSpace(out.Summary)
 }
 
 type pickResponse struct {
@@ -272,7 +308,22 @@ type pickResponse struct {
 	Stop   bool   `json:"stop"`
 }
 
-func (s *Server) pickPath(ctx context.Context, item models.Archive, nodes []models.TaxonomyNode) ([]string, error) {
+// validate requires an existing label unless the model asked for a new one
+// or to stop.
+func (r *pickResponse) validate(options []string) error {
+	choice := strings.TrimSpace(r.Choice)
+	if r.Stop || r.New || choice == "" {
+		return nil
+	}
+	for _, opt := range options {
+		if opt == choice {
+			return nil
+		}
+	}
+	return fmt.Errorf("choice %q is not one of the available labels; pick one of them or set new=true", choice)
+}
+
+func (s *Server) pickPath(ctx context.Context, llm *ai.Client, item models.Archive, nodes []models.TaxonomyNode) ([]string, error) {
 	children := map[string][]string{}
 	root := []string{}
 	for _, n := range nodes {
@@ -286,9 +337,10 @@ func (s *Server) pickPath(ctx context.Context, item models.Archive, nodes []mode
 	path := []string{}
 	parentID := ""
 	options := root
+	limits := s.taxonomyLimits()
 
-	for depth := 0; depth < 4; depth++ {
-		choice, isNew, stop, err := s.pickFromOptions(ctx, item, options, depth == 0)
+	for depth := 0; depth < limits.maxDepth; depth++ {
+		choice, isNew, stop, err := s.pickFromOptions(ctx, llm, item, options, limits.maxOptions)
 		if err != nil {
 			return path, err
 		}
@@ -322,14 +374,14 @@ func (s *Server) pickPath(ctx context.Context, item models.Archive, nodes []mode
 	return path, nil
 }
 
-func (s *Server) pickFromOptions(ctx context.Context, item models.Archive, options []string, topLevel bool) (string, bool, bool, error) {
+func (s *Server) pickFromOptions(ctx context.Context, llm *ai.Client, item models.Archive, options []string, maxOptions int) (string, bool, bool, error) {
 	if len(options) == 0 {
 		return "", true, false, nil
 	}
 
 	limited := options
-	if len(limited) > 30 {
-		limited = options[:30]
+	if len(limited) > maxOptions {
+		limited = options[:maxOptions]
 	}
 
 	system := "You are a taxonomy router. Return strict JSON only."
@@ -340,16 +392,9 @@ func (s *Server) pickFromOptions(ctx context.Context, item models.Archive, optio
 		"Available labels: " + strings.Join(limited, ", ") + "\n" +
 		"Title: " + item.Title + "\nURL: " + item.URL + "\nExcerpt: " + item.Excerpt + "\nContent: " + trimContent(item.ContentText)
 
-	raw, err := s.LLM.ChatJSON(ctx, system, user, 0.1)
-	if err != nil {
-		return "", false, false, err
-	}
-	raw = extractJSON(raw)
-	if raw == "" {
-		return "", false, false, errors.New("invalid json")
-	}
 	var resp pickResponse
-	if err := json.Unmarshal([]byte(raw), &resp); err != nil {
+	validate := func() error { return resp.validate(limited) }
+	if err := llm.DecodeJSON(ctx, system, user, 0.1, &resp, validate); err != nil {
 		return "", false, false, err
 	}
 	resp.Choice = strings.TrimSpace(resp.Choice)
@@ -365,12 +410,10 @@ func (s *Server) pickFromOptions(ctx context.Context, item models.Archive, optio
 	return resp.Choice, false, false, nil
 }
 
+// trimContent keeps the prompt for taxonomy routing short. Routing only needs
+// the gist, so the head of the article is enough.
 func trimContent(content string) string {
-	content = strings.TrimSpace(content)
-	if len(content) > 1800 {
-		return content[:1800]
-	}
-	return content
+	return textutil.Truncate(strings.TrimSpace(content), 1800)
 }
 
 func extractJSON(text string) string {
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+const (
+	aiExportBatchSize = 200
+	aiImportMaxBytes  = 256 << 20
+	aiImportMaxLine   = 4 << 20
+)
+
+// AIRecord is one line of the AI metadata export: everything classification
+// derives from an archive, keyed by ID and URL so it can be loaded back.
+type AIRecord struct {
+	ID             string              `json:"id"`
+	URL            string              `json:"url"`
+	Title          string              `json:"title,omitempty"`
+	Category       string              `json:"category"`
+	Tags           []string            `json:"tags"`
+	Hierarchy      []string            `json:"hierarchy"`
+	HierarchyPaths []string            `json:"hierarchyPaths"`
+	Entities       []string            `json:"entities"`
+	EntityTypes    map[string]string   `json:"entityTypes"`
+	Relations      []knowledgeRelation `json:"relations"`
+	Summary        string              `json:"summary"`
+}
+
+// aiImportRecord mirrors AIRecord; fields left out of a line are not touched.
+type aiImportRecord struct {
+	ID             string               `json:"id"`
+	URL            string               `json:"url"`
+	Category       *string              `json:"category"`
+	Tags           *[]string            `json:"tags"`
+	Hierarchy      *[]string            `json:"hierarchy"`
+	HierarchyPaths *[]string            `json:"hierarchyPaths"`
+	Entities       *[]string            `json:"entities"`
+	EntityTypes    *map[string]string   `json:"entityTypes"`
+	Relations      *[]knowledgeRelation `json:"relations"`
+	Summary        *string              `json:"summary"`
+}
+
+type AIImportFailure struct {
+	Line  int    `json:"line"`
+	ID    string `json:"id,omitempty"`
+	Error string `json:"error"`
+}
+
+type AIImportResponse struct {
+	Updated  int               `json:"updated"`
+	NotFound int               `json:"notFound"`
+	Failed   []AIImportFailure `json:"failed"`
+}
+
+// exportAIMetadata streams the AI-derived fields of every archive as JSON
+// Lines, without content or stored HTML.
+func (s *Server) exportAIMetadata(c *gin.Context) {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", `attachment; filename="webarchive-ai.jsonl"`)
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	db := s.reader()
+	lastID := ""
+	for {
+		var items []models.Archive
+		if err := db.Select("id", "url", "title", "category", "tags_json", "hierarchy_json", "entities_json", "entity_types_json", "relations_json", "summary").
+			Where("id > ?", lastID).Order("id asc").Limit(aiExportBatchSize).Find(&items).Error; err != nil {
+			// Headers are gone; a truncated stream is all we can signal.
+			_ = c.Error(err)
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		ids := make([]string, 0, len(items))
+		for _, item := range items {
+			ids = append(ids, item.ID)
+		}
+		var rows []models.ArchivePath
+		if err := db.Select("archive_id", "path").Where("archive_id IN ?", ids).Order("path asc").Find(&rows).Error; err != nil {
+			_ = c.Error(err)
+			return
+		}
+		paths := map[string][]string{}
+		for _, row := range rows {
+			paths[row.ArchiveID] = append(paths[row.ArchiveID], row.Path)
+		}
+		for _, item := range items {
+			record := AIRecord{
+				ID:             item.ID,
+				URL:            item.URL,
+				Title:          item.Title,
+				Category:       item.Category,
+				Tags:           jsonStrings(item.TagsJSON),
+				Hierarchy:      jsonStrings(item.HierarchyJSON),
+				HierarchyPaths: paths[item.ID],
+				Entities:       jsonStrings(item.EntitiesJSON),
+				EntityTypes:    archiveEntityTypes(item),
+				Relations:      []knowledgeRelation{},
+				Summary:        item.Summary,
+			}
+			if record.HierarchyPaths == nil {
+				record.HierarchyPaths = []string{}
+			}
+			if len(item.RelationsJSON) > 0 {
+				_ = json.Unmarshal(item.RelationsJSON, &record.Relations)
+			}
+			if err := enc.Encode(record); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+		lastID = items[len(items)-1].ID
+	}
+}
+
+// importAIMetadata loads JSON Lines produced by exportAIMetadata, typically
+// after re-running classification elsewhere. Lines are matched by ID, or by
+// canonical URL when the ID is unknown, and applied one by one so a bad line
+// only fails itself.
+func (s *Server) importAIMetadata(c *gin.Context) {
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, aiImportMaxBytes)
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64<<10), aiImportMaxLine)
+	actor := currentPrincipal(c).Username
+	limits := s.taxonomyLimits()
+	resp := AIImportResponse{Failed: []AIImportFailure{}}
+	pathsChanged := false
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := strings.TrimSpace(scanner.Text())
+		if raw == "" {
+			continue
+		}
+		var record aiImportRecord
+		if err := json.Unmarshal([]byte(raw), &record); err != nil {
+			resp.Failed = append(resp.Failed, AIImportFailure{Line: line, Error: "invalid json"})
+			continue
+		}
+		item, err := s.findImportTarget(record)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			resp.NotFound++
+			continue
+		}
+		if err != nil {
+			resp.Failed = append(resp.Failed, AIImportFailure{Line: line, ID: record.ID, Error: "db query failed"})
+			continue
+		}
+		before := s.snapshotArchive(item)
+		changedPaths, err := s.applyAIRecord(item.ID, record, limits)
+		if err != nil {
+			resp.Failed = append(resp.Failed, AIImportFailure{Line: line, ID: item.ID, Error: err.Error()})
+			continue
+		}
+		pathsChanged = pathsChanged || changedPaths
+		var updated models.Archive
+		if err := s.DB.First(&updated, "id = ?", item.ID).Error; err == nil {
+			s.recordArchiveEvent(item.ID, EventAIImport, actor, before, s.snapshotArchive(updated))
+		}
+		resp.Updated++
+	}
+	if err := scanner.Err(); err != nil {
+		resp.Failed = append(resp.Failed, AIImportFailure{Line: line + 1, Error: err.Error()})
+	}
+	if pathsChanged {
+		s.publishTaxonomyChanged("ai_import")
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) findImportTarget(record aiImportRecord) (models.Archive, error) {
+	var item models.Archive
+	if record.ID != "" {
+		err := s.DB.Omit("content_text").First(&item, "id = ?", record.ID).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) || record.URL == "" {
+			return item, err
+		}
+	}
+	canonical := processor.NormalizeURL(record.URL)
+	if canonical == "" {
+		return item, gorm.ErrRecordNotFound
+	}
+	err := s.DB.Omit("content_text").Where("canonical_url = ?", canonical).Order("created_at desc").First(&item).Error
+	return item, err
+}
+
+// applyAIRecord writes the fields present in record. When only one of
+// hierarchy and hierarchyPaths is given the other follows it, the same way
+// captures keep them in step.
+func (s *Server) applyAIRecord(archiveID string, record aiImportRecord, limits taxonomyLimits) (bool, error) {
+	updates := map[string]any{}
+	if record.Category != nil {
+		updates["category"] = truncate(strings.TrimSpace(*record.Category), 255)
+	}
+	if record.Tags != nil {
+		tagsJSON, _ := json.Marshal(patchTags(*record.Tags, nil, nil))
+		updates["tags_json"] = tagsJSON
+	}
+	if record.Entities != nil {
+		entitiesJSON, _ := json.Marshal(patchTags(*record.Entities, nil, nil))
+		updates["entities_json"] = entitiesJSON
+	}
+	if record.EntityTypes != nil {
+		typesJSON, _ := json.Marshal(*record.EntityTypes)
+		updates["entity_types_json"] = typesJSON
+	}
+	if record.Relations != nil {
+		for _, rel := range *record.Relations {
+			if strings.TrimSpace(rel.Source) == "" || strings.TrimSpace(rel.Target) == "" {
+				return false, errors.New("relations need source and target")
+			}
+		}
+		relationsJSON, _ := json.Marshal(*record.Relations)
+		updates["relations_json"] = relationsJSON
+	}
+	if record.Summary != nil {
+		updates["summary"] = strings.TrimSpace(*record.Summary)
+	}
+
+	var paths []string
+	switch {
+	case record.HierarchyPaths != nil:
+		for _, p := range *record.HierarchyPaths {
+			paths = append(paths, limits.clampPath(p))
+		}
+		paths = normalizePaths(paths)
+	case record.Hierarchy != nil:
+		if path := strings.Join(limits.clamp(*record.Hierarchy), "/"); path != "" {
+			paths = []string{path}
+		}
+	}
+	setPaths := record.HierarchyPaths != nil || record.Hierarchy != nil
+	if setPaths {
+		hierarchy := limits.clamp(derefStrings(record.Hierarchy))
+		if len(hierarchy) == 0 && len(paths) > 0 {
+			hierarchy = strings.Split(paths[0], "/")
+		}
+		hierarchyJSON, _ := json.Marshal(hierarchy)
+		updates["hierarchy_json"] = hierarchyJSON
+		updates["hierarchy_path"] = strings.Join(hierarchy, "/")
+	}
+	if len(updates) == 0 {
+		return false, errors.New("no ai fields in record")
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Archive{}).Where("id = ?", archiveID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if setPaths {
+			return replaceArchivePathsDB(tx, archiveID, paths, limits)
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("update failed: %w", err)
+	}
+	return setPaths, nil
+}
+
+func derefStrings(p *[]string) []string {
+	if p == nil {
+		return nil
+	}
+	return *p
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/ai"
+)
+
+type AIStatusResponse struct {
+	Enabled   bool                `json:"enabled"`
+	Active    string              `json:"active"`
+	Providers []ai.ProviderStatus `json:"providers"`
+	// Concurrency shows the calls in flight and queued behind
+	// LLM_MAX_CONCURRENT.
+	Concurrency ai.LimiterStatus `json:"concurrency"`
+}
+
+// aiStatus reports which provider is serving calls and each provider's
+// breaker state. ?format=prometheus returns the same data as text metrics.
+func (s *Server) aiStatus(c *gin.Context) {
+	resp := AIStatusResponse{Providers: []ai.ProviderStatus{}, Concurrency: s.LLMLimiter.Status()}
+	if s.LLM != nil {
+		resp.Enabled = s.LLM.Enabled()
+		resp.Providers = s.LLM.Providers()
+		resp.Active = activeProvider(resp.Providers)
+	}
+	if c.Query("format") == "prometheus" {
+		c.Data(http.StatusOK, "text/plain; version=0.0.4", []byte(providerMetrics(resp.Providers)+concurrencyMetrics(resp.Concurrency)))
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func activeProvider(providers []ai.ProviderStatus) string {
+	for _, p := range providers {
+		if p.Enabled && p.State != ai.BreakerOpen {
+			return p.Role
+		}
+	}
+	return ""
+}
+
+func providerMetrics(providers []ai.ProviderStatus) string {
+	var b strings.Builder
+	b.WriteString("# TYPE webarchive_llm_provider_up gauge\n")
+	for _, p := range providers {
+		up := 0
+		if p.Enabled && p.State != ai.BreakerOpen {
+			up = 1
+		}
+		fmt.Fprintf(&b, "webarchive_llm_provider_up{provider=%q,model=%q} %d\n", p.Role, p.Model, up)
+	}
+	b.WriteString("# TYPE webarchive_llm_provider_consecutive_failures gauge\n")
+	for _, p := range providers {
+		fmt.Fprintf(&b, "webarchive_llm_provider_consecutive_failures{provider=%q} %d\n", p.Role, p.ConsecutiveFailures)
+	}
+	b.WriteString("# TYPE webarchive_llm_requests_total counter\n")
+	for _, p := range providers {
+		fmt.Fprintf(&b, "webarchive_llm_requests_total{provider=%q} %d\n", p.Role, p.Requests)
+	}
+	b.WriteString("# TYPE webarchive_llm_failures_total counter\n")
+	for _, p := range providers {
+		fmt.Fprintf(&b, "webarchive_llm_failures_total{provider=%q} %d\n", p.Role, p.Failures)
+	}
+	return b.String()
+}
+
+func concurrencyMetrics(st ai.LimiterStatus) string {
+	var b strings.Builder
+	b.WriteString("# TYPE webarchive_llm_calls_in_flight gauge\n")
+	fmt.Fprintf(&b, "webarchive_llm_calls_in_flight %d\n", st.InFlight)
+	b.WriteString("# TYPE webarchive_llm_calls_waiting gauge\n")
+	fmt.Fprintf(&b, "webarchive_llm_calls_waiting %d\n", st.Waiting)
+	return b.String()
+}
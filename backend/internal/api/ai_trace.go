@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"webarchive/internal/ai"
+	"webarchive/internal/models"
+)
+
+// AI trace kinds: a classification run (tags, path and, through the graph
+// analyzer, entities), an entity-only extraction or a summary.
+const (
+	AITraceClassify = "classify"
+	AITraceEntities = "entities"
+	AITraceSummary  = "summary"
+)
+
+// aiTraceKeep is how many traces are kept per archive on top of the
+// retention period, newest first.
+const aiTraceKeep = 20
+
+type AITraceList struct {
+	ArchiveID string            `json:"archiveId"`
+	Enabled   bool              `json:"enabled"`
+	Traces    []AITraceResponse `json:"traces"`
+}
+
+type AITraceResponse struct {
+	ID        string         `json:"id"`
+	Kind      string         `json:"kind"`
+	Model     string         `json:"model"`
+	Error     string         `json:"error,omitempty"`
+	Calls     []ai.TraceCall `json:"calls"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// startAITrace attaches a trace to ctx when LLM tracing is on; the returned
+// function stores what was recorded, with the run's outcome, against the
+// archive. With tracing off both are no-ops.
+func (s *Server) startAITrace(ctx context.Context, archiveID, kind, model string) (context.Context, func(error)) {
+	rt := s.runtimeSettings()
+	if !rt.LLMTrace || archiveID == "" || s.DB == nil {
+		return ctx, func(error) {}
+	}
+	trace := &ai.Trace{}
+	return ai.WithTrace(ctx, trace), func(err error) {
+		calls := trace.Calls()
+		if len(calls) == 0 {
+			return
+		}
+		raw, _ := json.Marshal(calls)
+		row := models.AITrace{
+			ID:        uuid.New().String(),
+			ArchiveID: archiveID,
+			Kind:      kind,
+			Model:     model,
+			CallsJSON: raw,
+			CreatedAt: time.Now(),
+		}
+		if err != nil {
+			row.Error = err.Error()
+		}
+		if err := s.DB.Create(&row).Error; err != nil {
+			log.Printf("ai trace %s: %v", archiveID, err)
+			return
+		}
+		s.pruneAITraces(archiveID, rt.LLMTraceRetentionDays)
+	}
+}
+
+// pruneAITraces drops traces past the retention period and all but the
+// newest aiTraceKeep of the archive's.
+func (s *Server) pruneAITraces(archiveID string, days int) {
+	_ = s.DB.Where("created_at < ?", time.Now().AddDate(0, 0, -days)).Delete(&models.AITrace{}).Error
+	var ids []string
+	if err := s.DB.Model(&models.AITrace{}).Where("archive_id = ?", archiveID).
+		Order("created_at desc").Pluck("id", &ids).Error; err != nil || len(ids) <= aiTraceKeep {
+		return
+	}
+	_ = s.DB.Where("id IN ?", ids[aiTraceKeep:]).Delete(&models.AITrace{}).Error
+}
+
+// getArchiveAITrace lists the archive's recorded LLM exchanges, newest run
+// first.
+func (s *Server) getArchiveAITrace(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.Select("id").First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	rt := s.runtimeSettings()
+	var rows []models.AITrace
+	if err := s.DB.Where("archive_id = ? AND created_at >= ?", item.ID, time.Now().AddDate(0, 0, -rt.LLMTraceRetentionDays)).
+		Order("created_at desc").Limit(parseLimit(c.Query("limit"), aiTraceKeep)).Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	out := AITraceList{ArchiveID: item.ID, Enabled: rt.LLMTrace, Traces: make([]AITraceResponse, 0, len(rows))}
+	for _, row := range rows {
+		trace := AITraceResponse{
+			ID:        row.ID,
+			Kind:      row.Kind,
+			Model:     row.Model,
+			Error:     row.Error,
+			Calls:     []ai.TraceCall{},
+			CreatedAt: row.CreatedAt,
+		}
+		_ = json.Unmarshal(row.CallsJSON, &trace.Calls)
+		out.Traces = append(out.Traces, trace)
+	}
+	c.JSON(http.StatusOK, out)
+}
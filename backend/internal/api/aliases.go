@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+type MergeArchiveRequest struct {
+	Into string `json:"into"`
+}
+
+// followAliases answers reads of a merged archive's routes with a 301 to the
+// archive it was merged into, so share links and old bookmarks keep working.
+// Only archive and asset routes are checked; other :id params name other
+// resources.
+func (s *Server) followAliases() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if id == "" || (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) {
+			return
+		}
+		route := c.FullPath()
+		if !strings.Contains(route, "/archives/:id") && !strings.Contains(route, "/assets/:id/") {
+			return
+		}
+		var alias models.ArchiveAlias
+		if err := s.DB.Select("archive_id").First(&alias, "alias_id = ?", id).Error; err != nil {
+			return
+		}
+		target := *c.Request.URL
+		target.Path = strings.Replace(target.Path, "/"+id, "/"+alias.ArchiveID, 1)
+		target.RawPath = ""
+		c.Redirect(http.StatusMovedPermanently, target.RequestURI())
+		c.Abort()
+	}
+}
+
+// mergeArchive folds the archive into another one: paths, tags, provenance,
+// flashcards and views move over, the source is deleted and its ID becomes an alias of
+// the target. Aliases that pointed at the source follow it.
+func (s *Server) mergeArchive(c *gin.Context) {
+	var req MergeArchiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Into) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if req.Into == c.Param("id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot merge an archive into itself"})
+		return
+	}
+	var source, target models.Archive
+	if err := s.DB.First(&source, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err := s.DB.First(&target, "id = ?", req.Into).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "merge target not found"})
+		return
+	}
+
+	actor := currentPrincipal(c).Username
+	before := s.snapshotArchive(target)
+	sourcePaths, _ := s.loadArchivePaths(source.ID)
+	targetPaths, _ := s.loadArchivePaths(target.ID)
+	if len(sourcePaths) > 0 {
+		if err := s.replaceArchivePaths(target.ID, mergeStrings(targetPaths, sourcePaths)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+			return
+		}
+	}
+	tags := mergeStrings(jsonStrings(target.TagsJSON), jsonStrings(source.TagsJSON))
+	tagsJSON, _ := json.Marshal(tags)
+	provenance := mergeProvenance(provenanceChain(target.ProvenanceJSON), provenanceChain(source.ProvenanceJSON))
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Archive{}).Where("id = ?", target.ID).Updates(map[string]any{
+			"tags_json":       tagsJSON,
+			"provenance_json": marshalProvenance(provenance),
+		}).Error; err != nil {
+			return err
+		}
+		for _, model := range []any{&models.Flashcard{}, &models.ViewEvent{}, &models.ArchiveAlias{}} {
+			if err := tx.Model(model).Where("archive_id = ?", source.ID).Update("archive_id", target.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	if err := s.removeArchive(c.Request.Context(), source, actor); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	alias := models.ArchiveAlias{AliasID: source.ID, ArchiveID: target.ID, CreatedBy: actor, CreatedAt: time.Now()}
+	if err := s.DB.Create(&alias).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	// Links by the source's slug lead to the target when it has none.
+	if source.Slug != nil {
+		if err := s.DB.Model(&models.Archive{}).Where("id = ? AND slug IS NULL", target.ID).
+			UpdateColumn("slug", *source.Slug).Error; err != nil {
+			log.Printf("merge %s: move slug: %v", source.ID, err)
+		}
+	}
+
+	var updated models.Archive
+	if err := s.DB.First(&updated, "id = ?", target.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	s.recordArchiveEvent(updated.ID, EventMerge, actor, before, s.snapshotArchive(updated))
+	paths, _ := s.loadArchivePaths(updated.ID)
+	c.JSON(http.StatusOK, toArchiveResponse(updated, paths))
+}
+
+// mergeStrings appends the values of extra missing from base.
+func mergeStrings(base, extra []string) []string {
+	seen := make(map[string]bool, len(base))
+	out := append([]string{}, base...)
+	for _, v := range base {
+		seen[v] = true
+	}
+	for _, v := range extra {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
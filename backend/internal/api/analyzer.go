@@ -2,6 +2,8 @@ package api
 
 import (
 	"context"
+	"encoding/json"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -19,10 +21,32 @@ type AnalysisStatus struct {
 	LastLoopScanned   int        `json:"lastLoopScanned"`
 	LastLoopProcessed int        `json:"lastLoopProcessed"`
 	TotalProcessed    int        `json:"totalProcessed"`
+	DryRun            bool       `json:"dryRun"`
+	Scope             string     `json:"scope,omitempty"`
+	// Instance is the backend instance running, or last to run, the analyzer.
+	Instance string `json:"instance,omitempty"`
 }
 
+// analyzerLease keeps bulk analysis to one replica at a time; its state is
+// the AnalysisStatus every replica reports.
+const analyzerLease = "analyzer"
+
+// Analysis scopes: a full run classifies and extracts entities; an entities
+// run re-extracts entities, relations and summaries only, leaving tags and
+// paths alone, and covers every selected archive.
+const (
+	AnalysisScopeAll      = "all"
+	AnalysisScopeEntities = "entities"
+)
+
+// AnalysisRequest selects archives to analyze, all of them when IDs is
+// empty. DryRun stages each result as a proposal for review instead of
+// writing it, and covers already classified archives too, so a new model can
+// be judged on the whole collection.
 type AnalysisRequest struct {
-	IDs []string `json:"ids"`
+	IDs    []string `json:"ids"`
+	DryRun bool     `json:"dryRun"`
+	Scope  string   `json:"scope"`
 }
 
 func (s *Server) analysisStatus(c *gin.Context) {
@@ -37,45 +61,133 @@ func (s *Server) startAnalysis(c *gin.Context) {
 
 	var req AnalysisRequest
 	_ = c.ShouldBindJSON(&req)
+	switch req.Scope {
+	case "":
+		req.Scope = AnalysisScopeAll
+	case AnalysisScopeAll:
+	case AnalysisScopeEntities:
+		if s.Eino == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "entity extraction needs the graph analyzer"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be all or entities"})
+		return
+	}
 
 	s.analyzeMu.Lock()
-	if s.analyzeStatus.Running {
+	// A stopped run holds on to the lease until it has wound down.
+	if s.analyzeStatus.Running || s.analyzeCancel != nil {
 		status := s.analyzeStatus
 		s.analyzeMu.Unlock()
 		c.JSON(http.StatusOK, status)
 		return
 	}
+	held, err := s.acquireLease(analyzerLease)
+	if err != nil {
+		s.analyzeMu.Unlock()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "acquire analyzer lock failed"})
+		return
+	}
+	if !held {
+		// Another replica is running it; report its progress.
+		s.analyzeMu.Unlock()
+		c.JSON(http.StatusOK, s.getAnalysisStatus())
+		return
+	}
+	// Counters carry on from whichever replica ran last.
+	if shared, ok := s.sharedAnalysisStatus(); ok {
+		s.analyzeStatus = shared
+	}
 	ctx, cancel := context.WithCancel(context.Background())
 	s.analyzeCancel = cancel
 	s.analyzeStatus.Running = true
 	s.analyzeStatus.LastError = ""
 	s.analyzeStatus.LastLoopScanned = 0
 	s.analyzeStatus.LastLoopProcessed = 0
+	s.analyzeStatus.DryRun = req.DryRun
+	s.analyzeStatus.Scope = req.Scope
+	s.analyzeStatus.Instance = s.instanceID()
+	status := s.analyzeStatus
 	s.analyzeMu.Unlock()
+	s.publishAnalysisStatus(status)
 
-	go s.runAnalyzerOnce(ctx, req.IDs)
+	go s.analyzerHeartbeat(ctx, cancel)
+	go s.runAnalyzerOnce(ctx, req.IDs, req.DryRun, req.Scope)
 	c.JSON(http.StatusOK, s.getAnalysisStatus())
 }
 
+// stopAnalysis cancels a run on this replica, or asks the replica running
+// it to stop at its next heartbeat.
 func (s *Server) stopAnalysis(c *gin.Context) {
 	s.analyzeMu.Lock()
-	if s.analyzeCancel != nil {
-		s.analyzeCancel()
-		s.analyzeCancel = nil
+	if s.analyzeCancel == nil {
+		s.analyzeMu.Unlock()
+		if _, err := s.requestLeaseStop(analyzerLease); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "stop analyzer failed"})
+			return
+		}
+		c.JSON(http.StatusOK, s.getAnalysisStatus())
+		return
 	}
+	s.analyzeCancel()
 	s.analyzeStatus.Running = false
 	status := s.analyzeStatus
 	s.analyzeMu.Unlock()
+	s.publishEvent(LiveAnalysisProgress, status)
 	c.JSON(http.StatusOK, status)
 }
 
+// getAnalysisStatus reports the run on this replica if there is one, and
+// otherwise the state the last replica to run the analyzer shared.
 func (s *Server) getAnalysisStatus() AnalysisStatus {
 	s.analyzeMu.Lock()
-	defer s.analyzeMu.Unlock()
-	return s.analyzeStatus
+	local := s.analyzeStatus
+	s.analyzeMu.Unlock()
+	if local.Running || s.DB == nil {
+		return local
+	}
+	if shared, ok := s.sharedAnalysisStatus(); ok {
+		return shared
+	}
+	return local
 }
 
-func (s *Server) runAnalyzerOnce(ctx context.Context, ids []string) {
+func (s *Server) sharedAnalysisStatus() (AnalysisStatus, bool) {
+	var status AnalysisStatus
+	lease, live, err := s.loadLease(analyzerLease)
+	if err != nil || len(lease.State) == 0 || json.Unmarshal(lease.State, &status) != nil {
+		return status, false
+	}
+	// A holder that died mid-run left Running set.
+	status.Running = status.Running && live
+	return status, true
+}
+
+// analyzerHeartbeat keeps the lease while the run lasts and cancels the run
+// when the lease is lost or another replica asks it to stop.
+func (s *Server) analyzerHeartbeat(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(leaseHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		held, stop, err := s.renewLease(analyzerLease)
+		if err != nil {
+			log.Printf("renew analyzer lease: %v", err)
+			continue
+		}
+		if !held || stop {
+			cancel()
+			return
+		}
+	}
+}
+
+func (s *Server) runAnalyzerOnce(ctx context.Context, ids []string, dryRun bool, scope string) {
 	loopStart := time.Now()
 	scanned := 0
 	processed := 0
@@ -92,8 +204,14 @@ func (s *Server) runAnalyzerOnce(ctx context.Context, ids []string) {
 			st.TotalProcessed += processed
 		})
 		s.analyzeMu.Lock()
-		s.analyzeCancel = nil
+		if s.analyzeCancel != nil {
+			s.analyzeCancel()
+			s.analyzeCancel = nil
+		}
 		s.analyzeMu.Unlock()
+		if err := s.releaseLease(analyzerLease); err != nil {
+			log.Printf("release analyzer lease: %v", err)
+		}
 	}()
 
 	var items []models.Archive
@@ -114,7 +232,7 @@ func (s *Server) runAnalyzerOnce(ctx context.Context, ids []string) {
 			return
 		}
 		scanned++
-		if !needsAnalysis(item) {
+		if !dryRun && scope == AnalysisScopeAll && !needsAnalysis(item) {
 			s.withAnalysisStatus(func(st *AnalysisStatus) {
 				st.LastLoopScanned = scanned
 				st.LastLoopProcessed = processed
@@ -123,7 +241,20 @@ func (s *Server) runAnalyzerOnce(ctx context.Context, ids []string) {
 		}
 
 		taskCtx, cancel := context.WithTimeout(ctx, 90*time.Second)
-		_, err := s.classifyArchive(taskCtx, item)
+		if dryRun {
+			err = s.stageProposal(taskCtx, item, scope)
+		} else {
+			before := s.snapshotArchive(item)
+			var updated models.Archive
+			if scope == AnalysisScopeEntities {
+				updated, err = s.extractEntities(taskCtx, item)
+			} else {
+				updated, err = s.classifyArchive(taskCtx, item)
+			}
+			if err == nil {
+				s.recordArchiveEvent(updated.ID, EventAnalyzer, "analyzer", before, s.snapshotArchive(updated))
+			}
+		}
 		cancel()
 		if err != nil {
 			lastErr = err.Error()
@@ -146,8 +277,19 @@ func (s *Server) runAnalyzerOnce(ctx context.Context, ids []string) {
 
 func (s *Server) withAnalysisStatus(update func(*AnalysisStatus)) {
 	s.analyzeMu.Lock()
-	defer s.analyzeMu.Unlock()
 	update(&s.analyzeStatus)
+	status := s.analyzeStatus
+	s.analyzeMu.Unlock()
+	s.publishAnalysisStatus(status)
+}
+
+// publishAnalysisStatus tells this replica's live clients and, through the
+// lease, the other replicas.
+func (s *Server) publishAnalysisStatus(status AnalysisStatus) {
+	if err := s.saveLeaseState(analyzerLease, status); err != nil {
+		log.Printf("save analyzer status: %v", err)
+	}
+	s.publishEvent(LiveAnalysisProgress, status)
 }
 
 func needsAnalysis(item models.Archive) bool {
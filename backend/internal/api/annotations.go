@@ -0,0 +1,108 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+const (
+	maxCaptureAnnotations = 100
+	maxAnnotationQuote    = 5000
+	maxAnnotationContext  = 64
+)
+
+// CaptureAnnotation is a selection made on the page before capture. Prefix
+// and Suffix are the text just before and after it, used to pick the right
+// occurrence when the quote appears more than once.
+type CaptureAnnotation struct {
+	Quote  string `json:"quote"`
+	Prefix string `json:"prefix"`
+	Suffix string `json:"suffix"`
+	Note   string `json:"note"`
+}
+
+// createCaptureAnnotations stores the selections sent with a capture,
+// locating each in the archive's extracted text.
+func createCaptureAnnotations(tx *gorm.DB, archive models.Archive, in []CaptureAnnotation, actor string) error {
+	rows := []models.Annotation{}
+	for _, a := range in {
+		quote := truncate(strings.TrimSpace(a.Quote), maxAnnotationQuote)
+		if quote == "" {
+			continue
+		}
+		if len(rows) == maxCaptureAnnotations {
+			break
+		}
+		row := models.Annotation{
+			ID:        uuid.New().String(),
+			ArchiveID: archive.ID,
+			Quote:     quote,
+			Prefix:    lastRunes(a.Prefix, maxAnnotationContext),
+			Suffix:    truncate(a.Suffix, maxAnnotationContext),
+			Note:      strings.TrimSpace(a.Note),
+			CreatedBy: truncate(actor, 128),
+		}
+		row.Start, row.End = processor.LocateQuote(archive.ContentText, annotationQuote(row))
+		rows = append(rows, row)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Create(&rows).Error
+}
+
+// lastRunes keeps the end of s, the part of a prefix next to the quote.
+func lastRunes(s string, max int) string {
+	r := []rune(s)
+	if len(r) <= max {
+		return s
+	}
+	return string(r[len(r)-max:])
+}
+
+func annotationQuote(a models.Annotation) processor.Quote {
+	return processor.Quote{Exact: a.Quote, Prefix: a.Prefix, Suffix: a.Suffix, ID: a.ID, Note: a.Note}
+}
+
+// annotationQuotes returns the archive's annotations in text order, as the
+// snapshot marks them.
+func (s *Server) annotationQuotes(archiveID string) ([]processor.Quote, error) {
+	var rows []models.Annotation
+	if err := s.DB.Where("archive_id = ?", archiveID).Order("start asc, created_at asc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	quotes := make([]processor.Quote, 0, len(rows))
+	for _, row := range rows {
+		quotes = append(quotes, annotationQuote(row))
+	}
+	return quotes, nil
+}
+
+func (s *Server) listAnnotations(c *gin.Context) {
+	var rows []models.Annotation
+	if err := s.DB.Where("archive_id = ?", c.Param("id")).Order("start asc, created_at asc").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+func (s *Server) deleteAnnotation(c *gin.Context) {
+	res := s.DB.Where("id = ? AND archive_id = ?", c.Param("annotationId"), c.Param("id")).Delete(&models.Annotation{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
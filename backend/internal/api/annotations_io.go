@@ -0,0 +1,220 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+const (
+	annotationImportMaxBytes = 16 << 20
+	// annotationUnmatchedList caps the unmatched pages echoed back.
+	annotationUnmatchedList = 50
+)
+
+type AnnotationImportResponse struct {
+	Imported int `json:"imported"`
+	// Duplicates were already saved on the archive with the same quote.
+	Duplicates int `json:"duplicates"`
+	// Unmatched highlights belong to pages that aren't archived here.
+	Unmatched      int      `json:"unmatched"`
+	UnmatchedPages []string `json:"unmatchedPages"`
+}
+
+// importAnnotations loads highlights exported from Hypothes.is or Readwise
+// onto the archives of the same pages, matched by canonical URL or, for
+// exports without URLs, by exact title. Importing the same export twice
+// adds nothing the second time.
+func (s *Server) importAnnotations(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, annotationImportMaxBytes)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "read upload failed"})
+		return
+	}
+	defer f.Close()
+	var highlights []processor.HighlightRecord
+	switch c.PostForm("format") {
+	case "hypothesis":
+		highlights, err = processor.ParseHypothesis(f)
+	case "readwise":
+		highlights, err = processor.ParseReadwise(f)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be hypothesis or readwise"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid export: " + err.Error()})
+		return
+	}
+
+	type page struct {
+		archive models.Archive
+		found   bool
+		quotes  map[string]bool
+	}
+	pages := map[string]*page{}
+	lookup := func(h processor.HighlightRecord) (*page, string, error) {
+		key, db := "", s.DB.Select("id", "content_text").Order("created_at desc")
+		if h.URL != "" {
+			key = processor.NormalizeURL(h.URL)
+			db = db.Where("canonical_url = ?", key)
+		} else {
+			key = h.Title
+			db = db.Where("title = ?", h.Title)
+		}
+		if p, ok := pages[key]; ok {
+			return p, key, nil
+		}
+		p := &page{quotes: map[string]bool{}}
+		pages[key] = p
+		if key == "" {
+			return p, key, nil
+		}
+		var found []models.Archive
+		if err := db.Limit(1).Find(&found).Error; err != nil {
+			return nil, key, err
+		}
+		if len(found) == 0 {
+			return p, key, nil
+		}
+		p.archive, p.found = found[0], true
+		var existing []string
+		if err := s.DB.Model(&models.Annotation{}).Where("archive_id = ?", p.archive.ID).Pluck("quote", &existing).Error; err != nil {
+			return nil, key, err
+		}
+		for _, q := range existing {
+			p.quotes[q] = true
+		}
+		return p, key, nil
+	}
+
+	actor := truncate(currentPrincipal(c).Username, 128)
+	resp := AnnotationImportResponse{UnmatchedPages: []string{}}
+	unmatched := map[string]bool{}
+	rows := []models.Annotation{}
+	for _, h := range highlights {
+		p, key, err := lookup(h)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		if !p.found {
+			resp.Unmatched++
+			if !unmatched[key] && len(resp.UnmatchedPages) < annotationUnmatchedList {
+				resp.UnmatchedPages = append(resp.UnmatchedPages, key)
+			}
+			unmatched[key] = true
+			continue
+		}
+		quote := truncate(strings.TrimSpace(h.Quote), maxAnnotationQuote)
+		if p.quotes[quote] {
+			resp.Duplicates++
+			continue
+		}
+		p.quotes[quote] = true
+		row := models.Annotation{
+			ID:        uuid.New().String(),
+			ArchiveID: p.archive.ID,
+			Quote:     quote,
+			Prefix:    lastRunes(h.Prefix, maxAnnotationContext),
+			Suffix:    truncate(h.Suffix, maxAnnotationContext),
+			Note:      h.Note,
+			CreatedBy: actor,
+		}
+		if h.HighlightedAt != nil {
+			row.CreatedAt = *h.HighlightedAt
+		}
+		row.Start, row.End = processor.LocateQuote(p.archive.ContentText, annotationQuote(row))
+		rows = append(rows, row)
+	}
+	if len(rows) > 0 {
+		if err := s.DB.CreateInBatches(rows, 200).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+			return
+		}
+	}
+	resp.Imported = len(rows)
+	c.JSON(http.StatusOK, resp)
+}
+
+// exportAnnotations writes every annotation, or one archive's with
+// ?archive=, for Readwise: format=csv is its CSV import template and
+// format=markdown mirrors its Markdown export.
+func (s *Server) exportAnnotations(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "markdown" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or markdown"})
+		return
+	}
+	db := s.reader().Order("archive_id asc, start asc, created_at asc")
+	if id := c.Query("archive"); id != "" {
+		db = db.Where("archive_id = ?", id)
+	}
+	var rows []models.Annotation
+	if err := db.Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	ids := []string{}
+	for _, row := range rows {
+		if len(ids) == 0 || ids[len(ids)-1] != row.ArchiveID {
+			ids = append(ids, row.ArchiveID)
+		}
+	}
+	archives := map[string]models.Archive{}
+	if len(ids) > 0 {
+		var items []models.Archive
+		if err := s.reader().Select("id", "title", "url", "byline").Where("id IN ?", ids).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		for _, item := range items {
+			archives[item.ID] = item
+		}
+	}
+	highlights := make([]processor.HighlightRecord, 0, len(rows))
+	for _, row := range rows {
+		item, ok := archives[row.ArchiveID]
+		if !ok {
+			continue
+		}
+		created := row.CreatedAt
+		highlights = append(highlights, processor.HighlightRecord{
+			URL:           item.URL,
+			Title:         item.Title,
+			Author:        item.Byline,
+			Quote:         row.Quote,
+			Note:          row.Note,
+			Location:      row.Start,
+			HighlightedAt: &created,
+		})
+	}
+
+	var buf bytes.Buffer
+	var err error
+	contentType, name := "text/csv; charset=utf-8", "webarchive-highlights.csv"
+	if format == "markdown" {
+		contentType, name = "text/markdown; charset=utf-8", "webarchive-highlights.md"
+		err = processor.WriteHighlightsMarkdown(&buf, highlights)
+	} else {
+		err = processor.WriteReadwiseCSV(&buf, highlights)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "export failed"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="`+name+`"`)
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
@@ -1,9 +1,18 @@
 package api
 
 import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"webarchive/internal/models"
 )
@@ -23,31 +32,86 @@ func (s *Server) loadArchivePaths(archiveID string) ([]string, error) {
 }
 
 func (s *Server) replaceArchivePaths(archiveID string, rawPaths []string) error {
-	if err := s.DB.Where("archive_id = ?", archiveID).Delete(&models.ArchivePath{}).Error; err != nil {
-		return err
+	return replaceArchivePathsDB(s.DB, archiveID, rawPaths, s.taxonomyLimits())
+}
+
+func replaceArchivePathsDB(db *gorm.DB, archiveID string, rawPaths []string, limits taxonomyLimits) error {
+	return replaceArchivePathsBatchDB(db, map[string][]string{archiveID: rawPaths}, limits)
+}
+
+// replaceArchivePathsBatchDB sets the paths of many archives in one
+// transaction: the node lookup and the row inserts are batched, so the query
+// count does not grow with the number of archives or paths.
+func replaceArchivePathsBatchDB(db *gorm.DB, rawPaths map[string][]string, limits taxonomyLimits) error {
+	archiveIDs := make([]string, 0, len(rawPaths))
+	paths := map[string][]string{}
+	all := []string{}
+	for archiveID, raw := range rawPaths {
+		clamped := make([]string, 0, len(raw))
+		for _, path := range raw {
+			clamped = append(clamped, limits.clampPath(path))
+		}
+		archiveIDs = append(archiveIDs, archiveID)
+		paths[archiveID] = normalizePaths(clamped)
+		all = append(all, paths[archiveID]...)
 	}
+	if len(archiveIDs) == 0 {
+		return nil
+	}
+	// A stable order keeps concurrent batches from locking rows in opposite
+	// orders.
+	sort.Strings(archiveIDs)
 
-	paths := normalizePaths(rawPaths)
-	for _, path := range paths {
-		parts := strings.Split(path, "/")
-		if err := s.ensureTaxonomyPath(parts); err != nil {
+	return db.Transaction(func(tx *gorm.DB) error {
+		nodeIDs, err := taxonomyNodeIDsDB(tx, all)
+		if err != nil {
 			return err
 		}
-		node, err := s.getNodeByPath(path)
-		if err != nil {
-			continue
+		// Rows for paths an archive keeps are left in place, so CreatedAt
+		// stays the time it was filed there (see the path feeds and
+		// taxonomy webhooks).
+		kept := map[string]bool{}
+		stale := []string{}
+		for start := 0; start < len(archiveIDs); start += taxonomyBatchSize {
+			end := min(start+taxonomyBatchSize, len(archiveIDs))
+			var existing []models.ArchivePath
+			if err := tx.Where("archive_id IN ?", archiveIDs[start:end]).Find(&existing).Error; err != nil {
+				return err
+			}
+			for _, row := range existing {
+				key := row.ArchiveID + "\x00" + row.Path
+				if !kept[key] && row.NodeID == nodeIDs[row.Path] && slices.Contains(paths[row.ArchiveID], row.Path) {
+					kept[key] = true
+					continue
+				}
+				stale = append(stale, row.ID)
+			}
 		}
-		row := models.ArchivePath{
-			ID:        uuid.New().String(),
-			ArchiveID: archiveID,
-			NodeID:    node.ID,
-			Path:      path,
+		for start := 0; start < len(stale); start += taxonomyBatchSize {
+			end := min(start+taxonomyBatchSize, len(stale))
+			if err := tx.Where("id IN ?", stale[start:end]).Delete(&models.ArchivePath{}).Error; err != nil {
+				return err
+			}
 		}
-		if err := s.DB.Create(&row).Error; err != nil {
-			return err
+		rows := []models.ArchivePath{}
+		for _, archiveID := range archiveIDs {
+			for _, path := range paths[archiveID] {
+				if kept[archiveID+"\x00"+path] {
+					continue
+				}
+				rows = append(rows, models.ArchivePath{
+					ID:        uuid.New().String(),
+					ArchiveID: archiveID,
+					NodeID:    nodeIDs[path],
+					Path:      path,
+				})
+			}
 		}
-	}
-	return nil
+		if len(rows) == 0 {
+			return nil
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&rows, taxonomyBatchSize).Error
+	})
 }
 
 func normalizePaths(raw []string) []string {
@@ -67,3 +131,170 @@ func normalizePaths(raw []string) []string {
 	}
 	return out
 }
+
+type ArchivePathRequest struct {
+	Path   string `json:"path"`
+	NodeID string `json:"nodeId"`
+}
+
+var errPathNotFound = errors.New("path not found")
+
+// addArchivePath pins an archive to one more taxonomy node without touching
+// its other paths. The first path an archive gets also becomes its primary
+// hierarchy.
+func (s *Server) addArchivePath(c *gin.Context) {
+	var req ArchivePathRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	current, ok := s.loadArchiveForPaths(c)
+	if !ok {
+		return
+	}
+	limits, warnings := s.taxonomyLimits().reporting()
+	path, ok := s.resolvePathRequest(c, limits, req.Path, req.NodeID)
+	if !ok {
+		return
+	}
+
+	before := s.snapshotArchive(current)
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureTaxonomyPathDB(tx, strings.Split(path, "/"), limits); err != nil {
+			return err
+		}
+		node, err := getNodeByPathDB(tx, path)
+		if err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&models.ArchivePath{}).Where("archive_id = ? AND path = ?", current.ID, path).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		row := models.ArchivePath{
+			ID:        uuid.New().String(),
+			ArchiveID: current.ID,
+			NodeID:    node.ID,
+			Path:      path,
+		}
+		if err := tx.Create(&row).Error; err != nil {
+			return err
+		}
+		if current.HierarchyPath != "" {
+			return touchArchive(tx, current.ID)
+		}
+		return setPrimaryHierarchy(tx, current.ID, path)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update paths failed"})
+		return
+	}
+	s.respondArchivePaths(c, current.ID, before, *warnings)
+}
+
+// removeArchivePath unpins an archive from a single node. When the primary
+// hierarchy is removed the next remaining path takes its place.
+func (s *Server) removeArchivePath(c *gin.Context) {
+	current, ok := s.loadArchiveForPaths(c)
+	if !ok {
+		return
+	}
+	path, ok := s.resolvePathRequest(c, s.taxonomyLimits(), c.Query("path"), c.Query("nodeId"))
+	if !ok {
+		return
+	}
+
+	before := s.snapshotArchive(current)
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("archive_id = ? AND path = ?", current.ID, path).Delete(&models.ArchivePath{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errPathNotFound
+		}
+		if current.HierarchyPath != path {
+			return touchArchive(tx, current.ID)
+		}
+		var next models.ArchivePath
+		if err := tx.Where("archive_id = ?", current.ID).Order("path asc").Limit(1).Find(&next).Error; err != nil {
+			return err
+		}
+		return setPrimaryHierarchy(tx, current.ID, next.Path)
+	})
+	if errors.Is(err, errPathNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "path not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update paths failed"})
+		return
+	}
+	s.respondArchivePaths(c, current.ID, before, nil)
+}
+
+func (s *Server) loadArchiveForPaths(c *gin.Context) (models.Archive, bool) {
+	var item models.Archive
+	if err := s.DB.First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return item, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return item, false
+	}
+	return item, true
+}
+
+// resolvePathRequest turns either a node ID or a slash-separated path into a
+// clamped taxonomy path.
+func (s *Server) resolvePathRequest(c *gin.Context, limits taxonomyLimits, rawPath, nodeID string) (string, bool) {
+	if nodeID = strings.TrimSpace(nodeID); nodeID != "" {
+		var node models.TaxonomyNode
+		if err := s.DB.First(&node, "id = ?", nodeID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+			return "", false
+		}
+		return node.Path, true
+	}
+	path := limits.clampPath(strings.Trim(strings.TrimSpace(rawPath), "/"))
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path or nodeId required"})
+		return "", false
+	}
+	return path, true
+}
+
+func (s *Server) respondArchivePaths(c *gin.Context, archiveID string, before *archiveSnapshot, warnings []string) {
+	var updated models.Archive
+	if err := s.DB.First(&updated, "id = ?", archiveID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	s.recordArchiveEvent(updated.ID, EventEdit, currentPrincipal(c).Username, before, s.snapshotArchive(updated))
+	paths, _ := s.loadArchivePaths(updated.ID)
+	resp := toArchiveResponse(updated, paths)
+	resp.TaxonomyWarnings = warnings
+	c.Header("ETag", archiveETag(updated))
+	c.JSON(http.StatusOK, resp)
+}
+
+func setPrimaryHierarchy(db *gorm.DB, archiveID, path string) error {
+	hierarchy := []string{}
+	if path != "" {
+		hierarchy = strings.Split(path, "/")
+	}
+	hierarchyJSON, _ := json.Marshal(hierarchy)
+	return db.Model(&models.Archive{}).Where("id = ?", archiveID).Updates(map[string]any{
+		"hierarchy_json": hierarchyJSON,
+		"hierarchy_path": path,
+	}).Error
+}
+
+// touchArchive bumps updated_at so ETags held by other clients go stale.
+func touchArchive(db *gorm.DB, archiveID string) error {
+	return db.Model(&models.Archive{}).Where("id = ?", archiveID).Update("updated_at", time.Now()).Error
+}
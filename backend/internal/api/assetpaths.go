@@ -0,0 +1,157 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+)
+
+const keyRelativeAssetURLs = "migration.relative_asset_urls"
+
+// RelativizeAssetURLs rewrites archives captured with absolute /api/assets/
+// URLs to the relative form, so they load under any base path. Stored hashes
+// are updated alongside; objects that no longer match their recorded hash
+// are left untouched so fixity checks still report them. It runs once per
+// database.
+func (s *Server) RelativizeAssetURLs() {
+	var done int64
+	if err := s.DB.Model(&models.AppSetting{}).Where("setting_key = ?", keyRelativeAssetURLs).Count(&done).Error; err != nil {
+		log.Printf("asset url migration failed: %v", err)
+		return
+	}
+	if done > 0 {
+		return
+	}
+
+	ctx := context.Background()
+	lastID := ""
+	updated, failed := 0, 0
+	for {
+		var items []models.Archive
+		if err := s.DB.Select("id", "html_path", "html_sha256", "assets_json").
+			Where("id > ? AND html_path <> ''", lastID).Order("id asc").Limit(200).Find(&items).Error; err != nil {
+			log.Printf("asset url migration failed: %v", err)
+			return
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			lastID = item.ID
+			changed, err := s.relativizeArchive(ctx, item)
+			if err != nil {
+				log.Printf("asset url migration failed for %s: %v", item.ID, err)
+				failed++
+				continue
+			}
+			if changed {
+				updated++
+			}
+		}
+	}
+	if updated > 0 {
+		log.Printf("asset urls made relative for %d archives", updated)
+	}
+	// Leave the marker unset after failures so the next start retries; the
+	// rewrite skips archives that are already relative.
+	if failed > 0 {
+		return
+	}
+	if err := s.DB.Create(&models.AppSetting{Key: keyRelativeAssetURLs, Value: "1"}).Error; err != nil {
+		log.Printf("asset url migration failed: %v", err)
+	}
+}
+
+func (s *Server) relativizeArchive(ctx context.Context, item models.Archive) (bool, error) {
+	prefix := storage.ArchivePrefix(item.ID)
+	legacy := []byte(processor.LegacyAssetPrefix(item.ID))
+	updates := map[string]any{}
+	htmlChanged := false
+
+	body, err := s.readObject(ctx, path.Join(prefix, item.HTMLPath))
+	if err != nil {
+		if storage.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if bytes.Contains(body, legacy) && hashMatches(body, item.HTMLSHA256) {
+		body = bytes.ReplaceAll(body, legacy, []byte(processor.AssetPrefix(item.ID)))
+		if err := s.Store.PutBytes(ctx, path.Join(prefix, item.HTMLPath), body, "text/html; charset=utf-8"); err != nil {
+			return false, err
+		}
+		if item.HTMLSHA256 != "" {
+			updates["html_sha256"] = contentHash(string(body))
+		}
+		htmlChanged = true
+	}
+
+	var assets []processor.Asset
+	if len(item.AssetsJSON) > 0 {
+		_ = json.Unmarshal(item.AssetsJSON, &assets)
+	}
+	// Stylesheets refer to sibling assets by bare file name.
+	legacyCSS := []byte(processor.LegacyAssetPrefix(item.ID) + "assets/")
+	assetsChanged := false
+	rewritten := map[string]processor.Asset{}
+	for i, asset := range assets {
+		if done, ok := rewritten[asset.Stored]; ok {
+			assets[i].SHA256, assets[i].Size = done.SHA256, done.Size
+			continue
+		}
+		if !strings.EqualFold(path.Ext(asset.Stored), ".css") && !strings.Contains(asset.Type, "text/css") {
+			continue
+		}
+		css, err := s.readObject(ctx, path.Join(prefix, asset.Stored))
+		if err != nil || !bytes.Contains(css, legacyCSS) || !hashMatches(css, asset.SHA256) {
+			continue
+		}
+		css = bytes.ReplaceAll(css, legacyCSS, nil)
+		if err := s.Store.PutBytes(ctx, path.Join(prefix, asset.Stored), css, storage.GuessContentType(asset.Stored, asset.Type)); err != nil {
+			return false, err
+		}
+		if asset.SHA256 != "" {
+			assets[i].SHA256 = contentHash(string(css))
+			assets[i].Size = int64(len(css))
+		}
+		rewritten[asset.Stored] = assets[i]
+		assetsChanged = true
+	}
+	if assetsChanged {
+		raw, err := json.Marshal(assets)
+		if err != nil {
+			return false, err
+		}
+		updates["assets_json"] = raw
+	}
+
+	if len(updates) > 0 {
+		if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).UpdateColumns(updates).Error; err != nil {
+			return false, err
+		}
+	}
+	return htmlChanged || assetsChanged, nil
+}
+
+func (s *Server) readObject(ctx context.Context, objectPath string) ([]byte, error) {
+	obj, err := s.Store.Get(ctx, objectPath)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+// hashMatches reports whether data still matches its recorded SHA-256; an
+// empty hash (captures from before hashing) always matches.
+func hashMatches(data []byte, sha string) bool {
+	return sha == "" || contentHash(string(data)) == sha
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+const (
+	AssetStored = "stored"
+	AssetFailed = "failed"
+)
+
+// AssetEntry is one asset referenced by an archived page. Failed assets
+// have only an original URL; see repairArchive.
+type AssetEntry struct {
+	Original string `json:"original"`
+	Status   string `json:"status"`
+	Stored   string `json:"stored,omitempty"`
+	URL      string `json:"url,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256,omitempty"`
+}
+
+type AssetManifest struct {
+	ArchiveID string       `json:"archiveId"`
+	Stored    int          `json:"stored"`
+	Failed    int          `json:"failed"`
+	Bytes     int64        `json:"bytes"`
+	Assets    []AssetEntry `json:"assets"`
+}
+
+// getArchiveAssets returns the archive's assets, each listed once, with the
+// references that failed at capture. status=stored or status=failed keeps
+// one kind; the counts always cover both.
+func (s *Server) getArchiveAssets(c *gin.Context) {
+	var item models.Archive
+	if err := s.reader().Select("id", "assets_json", "missing_json").First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	status := c.Query("status")
+	if status != "" && status != AssetStored && status != AssetFailed {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be stored or failed"})
+		return
+	}
+	var assets []processor.Asset
+	if len(item.AssetsJSON) > 0 {
+		_ = json.Unmarshal(item.AssetsJSON, &assets)
+	}
+	manifest := AssetManifest{ArchiveID: item.ID, Assets: []AssetEntry{}}
+	seen := map[string]bool{}
+	for _, a := range assets {
+		if seen[a.Original] {
+			continue
+		}
+		seen[a.Original] = true
+		manifest.Stored++
+		manifest.Bytes += a.Size
+		if status == "" || status == AssetStored {
+			manifest.Assets = append(manifest.Assets, AssetEntry{
+				Original: a.Original,
+				Status:   AssetStored,
+				Stored:   a.Stored,
+				URL:      "/api/assets/" + item.ID + "/" + a.Stored,
+				Type:     a.Type,
+				Size:     a.Size,
+				SHA256:   a.SHA256,
+			})
+		}
+	}
+	for _, u := range jsonStrings(item.MissingJSON) {
+		if seen[u] {
+			continue
+		}
+		seen[u] = true
+		manifest.Failed++
+		if status == "" || status == AssetFailed {
+			manifest.Assets = append(manifest.Assets, AssetEntry{Original: u, Status: AssetFailed})
+		}
+	}
+	c.JSON(http.StatusOK, manifest)
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"webarchive/internal/processor"
+)
+
+// CaptureAttachment is an inline part of a mail message, referenced from its
+// HTML as cid:<contentId>.
+type CaptureAttachment struct {
+	ContentID   string `json:"contentId"`
+	ContentType string `json:"contentType"`
+	Data        string `json:"data"`
+}
+
+// attachmentResources keys decoded attachments the way MHTML parts are, so
+// the processor stores them like any packaged asset.
+func attachmentResources(into map[string]processor.Resource, attachments []CaptureAttachment) (map[string]processor.Resource, error) {
+	if len(attachments) == 0 {
+		return into, nil
+	}
+	if into == nil {
+		into = map[string]processor.Resource{}
+	}
+	for _, a := range attachments {
+		cid := strings.Trim(a.ContentID, "<> ")
+		if cid == "" {
+			return nil, errors.New("attachment contentId required")
+		}
+		body, err := base64.StdEncoding.DecodeString(a.Data)
+		if err != nil {
+			return nil, errors.New("attachment " + cid + " is not valid base64")
+		}
+		into["cid:"+cid] = processor.Resource{ContentType: a.ContentType, Body: body}
+	}
+	return into, nil
+}
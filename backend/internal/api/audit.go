@@ -0,0 +1,106 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"webarchive/internal/models"
+	"webarchive/internal/settings"
+)
+
+const (
+	AuditAIConfig        = "ai_config"
+	AuditSettings        = "settings"
+	AuditTokenCreate     = "token_create"
+	AuditTokenRevoke     = "token_revoke"
+	AuditUserCreate      = "user_create"
+	AuditUserUpdate      = "user_update"
+	AuditUserDelete      = "user_delete"
+	AuditCookiesSave     = "cookies_save"
+	AuditCookiesDelete   = "cookies_delete"
+	AuditRetentionSave   = "retention_save"
+	AuditRetentionDelete = "retention_delete"
+	AuditRetentionRun    = "retention_run"
+	AuditRecaptureSave   = "recapture_save"
+	AuditRecaptureDelete = "recapture_delete"
+	AuditRuleSave        = "rule_save"
+	AuditRuleDelete      = "rule_delete"
+	AuditWebhookSave     = "webhook_save"
+	AuditWebhookDelete   = "webhook_delete"
+	AuditProfileImport   = "profile_import"
+)
+
+type AdminAuditResponse struct {
+	ID        string          `json:"id"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	Target    string          `json:"target"`
+	ClientIP  string          `json:"clientIp"`
+	Before    json.RawMessage `json:"before"`
+	After     json.RawMessage `json:"after"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// recordAdminAudit stores a before/after pair for a configuration change.
+// Callers must pass values with secrets already masked.
+func (s *Server) recordAdminAudit(c *gin.Context, action, target string, before, after any) {
+	beforeJSON, _ := json.Marshal(before)
+	afterJSON, _ := json.Marshal(after)
+	entry := models.AdminAudit{
+		ID:         uuid.New().String(),
+		Action:     action,
+		Actor:      currentPrincipal(c).Username,
+		Target:     target,
+		ClientIP:   c.ClientIP(),
+		BeforeJSON: beforeJSON,
+		AfterJSON:  afterJSON,
+	}
+	if err := s.DB.Create(&entry).Error; err != nil {
+		log.Printf("record %s audit entry failed: %v", action, err)
+	}
+}
+
+func (s *Server) llmAuditSnapshot() gin.H {
+	if s.LLM == nil {
+		return nil
+	}
+	return gin.H{
+		"baseUrl": s.LLM.BaseURL,
+		"model":   s.LLM.Model,
+		"apiKey":  settings.MaskSecret(s.LLM.APIKey),
+	}
+}
+
+func (s *Server) listAdminAudit(c *gin.Context) {
+	query := s.DB.Order("created_at desc").Limit(parseLimit(c.Query("limit"), 200))
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if actor := c.Query("actor"); actor != "" {
+		query = query.Where("actor = ?", actor)
+	}
+	var entries []models.AdminAudit
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	out := make([]AdminAuditResponse, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, AdminAuditResponse{
+			ID:        e.ID,
+			Action:    e.Action,
+			Actor:     e.Actor,
+			Target:    e.Target,
+			ClientIP:  e.ClientIP,
+			Before:    json.RawMessage(e.BeforeJSON),
+			After:     json.RawMessage(e.AfterJSON),
+			CreatedAt: e.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,433 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/auth"
+	"webarchive/internal/models"
+	"webarchive/internal/settings"
+)
+
+const (
+	principalKey    = "principal"
+	tokenCookieName = "wa_token"
+	sessionTTL      = 30 * 24 * time.Hour
+)
+
+type Principal struct {
+	UserID   string   `json:"userId"`
+	Username string   `json:"username"`
+	Role     string   `json:"role"`
+	Scopes   []string `json:"scopes"`
+	TokenID  string   `json:"tokenId,omitempty"`
+}
+
+// localPrincipal is used for every request when auth is disabled, keeping
+// the single-user deployment behaviour unchanged.
+var localPrincipal = Principal{Username: "local", Role: auth.RoleAdmin, Scopes: []string{auth.ScopeAdmin}}
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type TokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+type TokenResponse struct {
+	models.APIToken
+	Scopes []string `json:"scopes"`
+	// Token is only returned once, when the token is created.
+	Token string `json:"token,omitempty"`
+}
+
+type UserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+func (s *Server) authenticate() gin.HandlerFunc {
+	return s.authenticateWith(requestToken)
+}
+
+// authenticateExplicit ignores the login cookie, for GET routes with side
+// effects: any site can make the browser send the cookie along with a link,
+// but not a header or a token in the URL it doesn't know.
+func (s *Server) authenticateExplicit() gin.HandlerFunc {
+	return s.authenticateWith(explicitToken)
+}
+
+func (s *Server) authenticateWith(tokenOf func(*gin.Context) string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.AuthEnabled {
+			c.Set(principalKey, localPrincipal)
+			c.Next()
+			return
+		}
+		token := tokenOf(c)
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+		principal, err := s.lookupToken(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+			return
+		}
+		c.Set(principalKey, principal)
+		c.Next()
+	}
+}
+
+func (s *Server) requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.RoleAllows(currentPrincipal(c).Role, role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": role + " role required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireScope rejects credentials that carry none of the accepted scopes.
+func (s *Server) requireScope(accepted ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.ScopeAllows(currentPrincipal(c).Scopes, accepted...) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "token scope does not allow this request"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func currentPrincipal(c *gin.Context) Principal {
+	if v, ok := c.Get(principalKey); ok {
+		if p, ok := v.(Principal); ok {
+			return p
+		}
+	}
+	return Principal{}
+}
+
+// requestToken accepts the Authorization header, the login cookie, or an
+// access_token query parameter; the latter is needed for iframes and <img>
+// tags pointing at archived HTML and assets, which cannot send headers.
+func requestToken(c *gin.Context) string {
+	if h := c.GetHeader("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	if cookie, err := c.Cookie(tokenCookieName); err == nil && cookie != "" {
+		return cookie
+	}
+	return c.Query("access_token")
+}
+
+// explicitToken is requestToken without the login cookie.
+func explicitToken(c *gin.Context) string {
+	if h := c.GetHeader("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	return c.Query("access_token")
+}
+
+func (s *Server) lookupToken(token string) (Principal, error) {
+	var row models.APIToken
+	if err := s.DB.First(&row, "token_hash = ?", auth.HashToken(token)).Error; err != nil {
+		return Principal{}, err
+	}
+	now := time.Now()
+	if row.ExpiresAt != nil && row.ExpiresAt.Before(now) {
+		return Principal{}, errors.New("token expired")
+	}
+	var user models.User
+	if err := s.DB.First(&user, "id = ?", row.UserID).Error; err != nil {
+		return Principal{}, err
+	}
+	_ = s.DB.Model(&models.APIToken{}).Where("id = ?", row.ID).Update("last_used_at", now).Error
+	scopes := auth.ParseScopes(row.Scopes)
+	if len(scopes) == 0 {
+		// Sessions issued before scopes existed keep full access.
+		scopes = []string{auth.ScopeAdmin}
+	}
+	return Principal{UserID: user.ID, Username: user.Username, Role: user.Role, Scopes: scopes, TokenID: row.ID}, nil
+}
+
+// BootstrapAdmin creates the first admin account when the users table is
+// empty, so an auth-enabled deployment is never locked out.
+func (s *Server) BootstrapAdmin(username, password string) error {
+	var count int64
+	if err := s.DB.Model(&models.User{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if password == "" {
+		return errors.New("no users exist and ADMIN_PASSWORD is not set")
+	}
+	_, err := s.createUserRecord(username, password, auth.RoleAdmin)
+	return err
+}
+
+func (s *Server) createUserRecord(username, password, role string) (models.User, error) {
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+	user := models.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+	}
+	return user, s.DB.Create(&user).Error
+}
+
+// setSessionCookie writes the login cookie. SameSite=Lax keeps it off form
+// posts from other sites; it is Secure when BaseURL is https.
+func (s *Server) setSessionCookie(c *gin.Context, token string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(tokenCookieName, token, maxAge, "/", "", strings.HasPrefix(strings.ToLower(s.BaseURL), "https://"), true)
+}
+
+func (s *Server) login(c *gin.Context) {
+	var req LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	var user models.User
+	if err := s.DB.First(&user, "username = ?", strings.TrimSpace(req.Username)).Error; err != nil || !auth.CheckPassword(user.PasswordHash, req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+		return
+	}
+	expires := time.Now().Add(sessionTTL)
+	token, _, err := s.issueToken(user.ID, "session", []string{auth.ScopeAdmin}, &expires)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "token generation failed"})
+		return
+	}
+	s.setSessionCookie(c, token, int(sessionTTL.Seconds()))
+	c.JSON(http.StatusOK, gin.H{"token": token, "expiresAt": expires, "user": user})
+}
+
+func (s *Server) issueToken(userID, name string, scopes []string, expires *time.Time) (string, models.APIToken, error) {
+	token, hash, err := auth.NewToken()
+	if err != nil {
+		return "", models.APIToken{}, err
+	}
+	row := models.APIToken{
+		ID:        uuid.New().String(),
+		UserID:    userID,
+		Name:      name,
+		TokenHash: hash,
+		Prefix:    token[:8],
+		Scopes:    strings.Join(scopes, ","),
+		ExpiresAt: expires,
+	}
+	return token, row, s.DB.Create(&row).Error
+}
+
+func toTokenResponse(row models.APIToken) TokenResponse {
+	return TokenResponse{APIToken: row, Scopes: auth.ParseScopes(row.Scopes)}
+}
+
+func (s *Server) listTokens(c *gin.Context) {
+	var rows []models.APIToken
+	if err := s.DB.Where("user_id = ?", currentPrincipal(c).UserID).Order("created_at desc").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	out := make([]TokenResponse, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, toTokenResponse(row))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) createToken(c *gin.Context) {
+	var req TokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+	if len(req.Scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one scope required"})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !auth.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scopes must be read, capture, write or admin"})
+			return
+		}
+	}
+	var expires *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expires = &t
+	}
+	token, row, err := s.issueToken(currentPrincipal(c).UserID, req.Name, req.Scopes, expires)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	resp := toTokenResponse(row)
+	s.recordAdminAudit(c, AuditTokenCreate, row.ID, nil, resp)
+	resp.Token = token
+	c.JSON(http.StatusOK, resp)
+}
+
+// deleteToken revokes one of the caller's tokens; admins may revoke any
+// token, e.g. after a leak.
+func (s *Server) deleteToken(c *gin.Context) {
+	p := currentPrincipal(c)
+	query := s.DB.Where("id = ?", c.Param("id"))
+	if p.Role != auth.RoleAdmin {
+		query = query.Where("user_id = ?", p.UserID)
+	}
+	var row models.APIToken
+	if err := query.First(&row).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if err := s.DB.Delete(&models.APIToken{}, "id = ?", row.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditTokenRevoke, row.ID, toTokenResponse(row), nil)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *Server) logout(c *gin.Context) {
+	if p := currentPrincipal(c); p.TokenID != "" {
+		_ = s.DB.Delete(&models.APIToken{}, "id = ?", p.TokenID).Error
+	}
+	s.setSessionCookie(c, "", -1)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *Server) me(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"authEnabled": s.AuthEnabled, "principal": currentPrincipal(c)})
+}
+
+func (s *Server) listUsers(c *gin.Context) {
+	var users []models.User
+	if err := s.DB.Order("username asc").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, users)
+}
+
+func (s *Server) createUser(c *gin.Context) {
+	var req UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" || len(req.Password) < 8 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username and a password of at least 8 characters required"})
+		return
+	}
+	if !auth.ValidRole(req.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role must be admin, editor or viewer"})
+		return
+	}
+	user, err := s.createUserRecord(req.Username, req.Password, req.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditUserCreate, user.ID, nil, user)
+	c.JSON(http.StatusOK, user)
+}
+
+func (s *Server) updateUser(c *gin.Context) {
+	var req UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	var user models.User
+	if err := s.DB.First(&user, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	updates := map[string]any{}
+	if req.Role != "" {
+		if !auth.ValidRole(req.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "role must be admin, editor or viewer"})
+			return
+		}
+		updates["role"] = req.Role
+	}
+	if req.Password != "" {
+		if len(req.Password) < 8 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 8 characters"})
+			return
+		}
+		hash, err := auth.HashPassword(req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "hash password failed"})
+			return
+		}
+		updates["password_hash"] = hash
+	}
+	if len(updates) > 0 {
+		before := user
+		if err := s.DB.Model(&user).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+			return
+		}
+		// PasswordHash is never serialized, so only note that it changed.
+		_, passwordChanged := updates["password_hash"]
+		s.recordAdminAudit(c, AuditUserUpdate, user.ID, before, gin.H{"user": user, "passwordChanged": passwordChanged})
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+func (s *Server) deleteUser(c *gin.Context) {
+	id := c.Param("id")
+	if id == currentPrincipal(c).UserID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot delete yourself"})
+		return
+	}
+	var user models.User
+	if err := s.DB.First(&user, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err := s.DB.Delete(&models.User{}, "id = ?", id).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	_ = s.DB.Where("user_id = ?", id).Delete(&models.APIToken{}).Error
+	_ = s.DB.Where("setting_key = ?", settings.PreferencesKey(id)).Delete(&models.AppSetting{}).Error
+	s.recordAdminAudit(c, AuditUserDelete, id, user, nil)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
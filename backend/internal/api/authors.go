@@ -0,0 +1,259 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"webarchive/internal/models"
+)
+
+// LinkWrote connects an author node to the archives they wrote.
+const LinkWrote = "wrote"
+
+// maxBylineAuthors bounds how many authors one byline is split into.
+const maxBylineAuthors = 10
+
+var (
+	bylinePrefix    = regexp.MustCompile(`(?i)^(?:(?:written\s+)?by\b[:：]?|作者\s*[:：]|撰文\s*[:：]|文\s*[/／|:：])\s*`)
+	bylineSeparator = regexp.MustCompile(`(?i)\s*(?:[,;，；、/|&]|\s+and\s+)\s*`)
+	bylineLink      = regexp.MustCompile(`\S+://\S*|@\w+`)
+)
+
+type AuthorSummary struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	Count           int64     `json:"count"`
+	FirstCapturedAt time.Time `json:"firstCapturedAt"`
+	LastCapturedAt  time.Time `json:"lastCapturedAt"`
+}
+
+type AuthorResponse struct {
+	AuthorSummary
+	TopSites []map[string]any  `json:"topSites"`
+	Archives []ArchiveResponse `json:"archives"`
+}
+
+// splitByline names the authors of a byline such as "By Jane Doe and John
+// Smith" or "作者：张三、李四", dropping links, handles and parts that are not
+// names.
+func splitByline(byline string) []string {
+	byline = bylineLink.ReplaceAllString(byline, "")
+	byline = bylinePrefix.ReplaceAllString(strings.TrimSpace(byline), "")
+	seen := map[string]bool{}
+	out := []string{}
+	for _, part := range bylineSeparator.Split(byline, -1) {
+		name := strings.Join(strings.Fields(bylinePrefix.ReplaceAllString(part, "")), " ")
+		name = strings.TrimFunc(name, func(r rune) bool { return unicode.IsPunct(r) && r != '.' })
+		key := authorKey(name)
+		if key == "" || seen[key] || utf8.RuneCountInString(name) > 100 ||
+			strings.IndexFunc(name, unicode.IsLetter) < 0 {
+			continue
+		}
+		seen[key] = true
+		out = append(out, name)
+		if len(out) == maxBylineAuthors {
+			break
+		}
+	}
+	return out
+}
+
+// authorKey folds a name to what bylines are matched on.
+func authorKey(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// linkArchiveAuthors replaces the archive's author links with the authors of
+// byline, creating the ones not seen before.
+func linkArchiveAuthors(tx *gorm.DB, archiveID, byline string) error {
+	if err := tx.Where("archive_id = ?", archiveID).Delete(&models.ArchiveAuthor{}).Error; err != nil {
+		return err
+	}
+	for i, name := range splitByline(byline) {
+		author := models.Author{ID: uuid.New().String(), NameKey: truncate(authorKey(name), 255), Name: truncate(name, 255), CreatedAt: time.Now()}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&author).Error; err != nil {
+			return err
+		}
+		if err := tx.Select("id").First(&author, "name_key = ?", author.NameKey).Error; err != nil {
+			return err
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.ArchiveAuthor{
+			ArchiveID: archiveID,
+			AuthorID:  author.ID,
+			Position:  i,
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BackfillAuthors links the bylines of archives saved before authors were
+// tracked. Archives whose byline names nobody are looked at again on each
+// start, which costs a parse and no writes.
+func (s *Server) BackfillAuthors() {
+	lastID := ""
+	linked := 0
+	for {
+		var items []models.Archive
+		if err := s.DB.Select("id", "byline").
+			Where("id > ? AND byline <> '' AND NOT EXISTS (SELECT 1 FROM archive_authors WHERE archive_authors.archive_id = archives.id)", lastID).
+			Order("id asc").Limit(500).Find(&items).Error; err != nil {
+			log.Printf("author backfill failed: %v", err)
+			return
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			lastID = item.ID
+			if len(splitByline(item.Byline)) == 0 {
+				continue
+			}
+			if err := linkArchiveAuthors(s.DB, item.ID, item.Byline); err != nil {
+				log.Printf("author backfill failed: %v", err)
+				return
+			}
+			linked++
+		}
+	}
+	if linked > 0 {
+		log.Printf("authors linked for %d archives", linked)
+	}
+}
+
+func authorSummaries(db *gorm.DB) *gorm.DB {
+	return db.Table("authors").
+		Select("authors.id, authors.name, COUNT(*) AS count, " +
+			"MIN(COALESCE(archives.captured_at, archives.created_at)) AS first_captured_at, " +
+			"MAX(COALESCE(archives.captured_at, archives.created_at)) AS last_captured_at").
+		Joins("JOIN archive_authors ON archive_authors.author_id = authors.id").
+		Joins("JOIN archives ON archives.id = archive_authors.archive_id").
+		Group("authors.id, authors.name")
+}
+
+// listAuthors lists the writers with at least one archive. sort is count
+// (default), recent (latest capture first) or name.
+func (s *Server) listAuthors(c *gin.Context) {
+	query := authorSummaries(s.reader())
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		query = query.Where("authors.name_key LIKE ?", "%"+authorKey(q)+"%")
+	}
+	switch c.Query("sort") {
+	case "recent":
+		query = query.Order("last_captured_at desc")
+	case "name":
+		query = query.Order("authors.name_key asc")
+	default:
+		query = query.Order("count desc").Order("authors.name_key asc")
+	}
+	var out []AuthorSummary
+	if err := query.Limit(parseLimit(c.Query("limit"), 100)).Offset(parseLimit(c.Query("offset"), 0)).
+		Scan(&out).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if out == nil {
+		out = []AuthorSummary{}
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// getAuthor returns everything saved by the named writer, newest first, and
+// the sites that published them. The name matches case-insensitively.
+func (s *Server) getAuthor(c *gin.Context) {
+	db := s.reader()
+	var author models.Author
+	if err := db.First(&author, "name_key = ?", authorKey(c.Param("name"))).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	var summaries []AuthorSummary
+	if err := authorSummaries(db).Where("authors.id = ?", author.ID).Scan(&summaries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if len(summaries) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	written := db.Model(&models.ArchiveAuthor{}).Select("archive_id").Where("author_id = ?", author.ID)
+
+	var domains []string
+	if err := db.Model(&models.Archive{}).Where("id IN (?) AND domain <> ''", written).Pluck("domain", &domains).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	counts := map[string]int{}
+	for _, domain := range domains {
+		counts[domain]++
+	}
+
+	var items []models.Archive
+	if err := db.Omit("content_text").Where("id IN (?)", written).Order("created_at desc").
+		Limit(parseLimit(c.Query("limit"), 50)).Offset(parseLimit(c.Query("offset"), 0)).
+		Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	archives := make([]ArchiveResponse, 0, len(items))
+	for _, item := range items {
+		archives = append(archives, toArchiveResponse(item, nil))
+	}
+	c.JSON(http.StatusOK, AuthorResponse{
+		AuthorSummary: summaries[0],
+		TopSites:      rankCounts(counts, nil, parseLimit(c.Query("sites"), 10)),
+		Archives:      archives,
+	})
+}
+
+// authorLinks returns the author nodes of the archives in items and a wrote
+// edge from each to the archives they wrote.
+func (s *Server) authorLinks(items []models.Archive) ([]GraphNode, []GraphLink, error) {
+	nodes, links := []GraphNode{}, []GraphLink{}
+	if len(items) == 0 {
+		return nodes, links, nil
+	}
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	var rows []struct {
+		ArchiveID string
+		AuthorID  string
+		Name      string
+	}
+	if err := s.reader().Table("archive_authors").
+		Select("archive_authors.archive_id, archive_authors.author_id, authors.name").
+		Joins("JOIN authors ON authors.id = archive_authors.author_id").
+		Where("archive_authors.archive_id IN ?", ids).
+		Order("archive_authors.archive_id, archive_authors.position").
+		Scan(&rows).Error; err != nil {
+		return nil, nil, err
+	}
+	seen := map[string]bool{}
+	for _, row := range rows {
+		nodeID := "author:" + row.AuthorID
+		if !seen[nodeID] {
+			seen[nodeID] = true
+			nodes = append(nodes, GraphNode{ID: nodeID, Label: row.Name, Group: "author", RefID: row.AuthorID})
+		}
+		links = append(links, GraphLink{Source: nodeID, Target: "arc:" + row.ArchiveID, Value: 1, Type: LinkWrote})
+	}
+	return nodes, links, nil
+}
@@ -0,0 +1,167 @@
+package api
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+)
+
+// exportBagIt streams a BagIt 1.0 bag for one archive as a zip: the HTML,
+// stored assets and a metadata.json under data/, a SHA-256 payload
+// manifest, bag-info.txt and a tag manifest.
+func (s *Server) exportBagIt(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	paths, _ := s.loadArchivePaths(item.ID)
+	metadata, err := json.MarshalIndent(toArchiveResponse(item, paths), "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encode metadata failed"})
+		return
+	}
+
+	objects := []string{}
+	if item.HTMLPath != "" {
+		objects = append(objects, item.HTMLPath)
+	}
+	var assets []processor.Asset
+	if len(item.AssetsJSON) > 0 {
+		_ = json.Unmarshal(item.AssetsJSON, &assets)
+	}
+	seen := map[string]bool{item.HTMLPath: true}
+	for _, asset := range assets {
+		if asset.Stored != "" && !seen[asset.Stored] {
+			seen[asset.Stored] = true
+			objects = append(objects, asset.Stored)
+		}
+	}
+
+	root := "bag-" + item.ID
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, root))
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	defer zw.Close()
+	bag := &bagWriter{zip: zw, root: root}
+
+	ctx := c.Request.Context()
+	prefix := storage.ArchivePrefix(item.ID)
+	for _, object := range objects {
+		obj, err := s.Store.Get(ctx, path.Join(prefix, object))
+		if err != nil {
+			bag.missing = append(bag.missing, object)
+			continue
+		}
+		// GetObject is lazy; Stat surfaces missing objects before a zip
+		// entry is created for them.
+		if _, err := obj.Stat(); err != nil {
+			bag.missing = append(bag.missing, object)
+		} else if err := bag.payload(object, obj); err != nil {
+			bag.missing = append(bag.missing, object)
+		}
+		obj.Close()
+	}
+	_ = bag.payload("metadata.json", strings.NewReader(string(metadata)))
+
+	title := strings.TrimSpace(item.Title)
+	info := []string{
+		"Bagging-Date: " + time.Now().Format("2006-01-02"),
+		"External-Identifier: " + item.ID,
+		"Source-URL: " + item.URL,
+		fmt.Sprintf("Payload-Oxum: %d.%d", bag.bytes, len(bag.manifest)),
+		"Bag-Software-Agent: WebArchive",
+	}
+	if title != "" {
+		info = append(info, "External-Description: "+strings.ReplaceAll(title, "\n", " "))
+	}
+	if item.CapturedAt != nil {
+		info = append(info, "Capture-Date: "+item.CapturedAt.UTC().Format(time.RFC3339))
+	}
+	for _, object := range bag.missing {
+		info = append(info, "Missing-Object: "+object)
+	}
+	_ = bag.tag("bagit.txt", "BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n")
+	_ = bag.tag("bag-info.txt", strings.Join(info, "\n")+"\n")
+	_ = bag.tag("manifest-sha256.txt", bag.manifest.String())
+	_, _, _ = bag.file("tagmanifest-sha256.txt", strings.NewReader(bag.tags.String()))
+}
+
+type bagManifest map[string]string
+
+// String renders "checksum  path" lines sorted by path.
+func (m bagManifest) String() string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s  %s\n", m[name], name)
+	}
+	return b.String()
+}
+
+type bagWriter struct {
+	zip      *zip.Writer
+	root     string
+	manifest bagManifest
+	tags     bagManifest
+	bytes    int64
+	missing  []string
+}
+
+func (b *bagWriter) file(name string, r io.Reader) (string, int64, error) {
+	w, err := b.zip.Create(b.root + "/" + name)
+	if err != nil {
+		return "", 0, err
+	}
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(w, h), r)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+func (b *bagWriter) payload(name string, r io.Reader) error {
+	sum, n, err := b.file("data/"+name, r)
+	if err != nil {
+		return err
+	}
+	if b.manifest == nil {
+		b.manifest = bagManifest{}
+	}
+	b.manifest["data/"+name] = sum
+	b.bytes += n
+	return nil
+}
+
+func (b *bagWriter) tag(name, content string) error {
+	sum, _, err := b.file(name, strings.NewReader(content))
+	if err != nil {
+		return err
+	}
+	if b.tags == nil {
+		b.tags = bagManifest{}
+	}
+	b.tags[name] = sum
+	return nil
+}
@@ -0,0 +1,108 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+)
+
+const maxBulkDelete = 1000
+
+type BulkDeleteRequest struct {
+	IDs []string `json:"ids"`
+	// Scope is as for DELETE /archives/:id: all (default), snapshot or ai.
+	Scope string `json:"scope"`
+	// Confirm is the token from the dry run. Without it nothing is deleted.
+	Confirm string `json:"confirm"`
+}
+
+type BulkDeletePreview struct {
+	ConfirmPreview
+	Scope        string   `json:"scope"`
+	Archives     int      `json:"archives"`
+	StorageBytes int64    `json:"storageBytes"`
+	Missing      []string `json:"missing"`
+}
+
+// bulkDeleteArchives deletes many archives in two steps: the first call
+// only reports what would go and returns a confirmation token, and the
+// same request repeated with that token deletes them.
+func (s *Server) bulkDeleteArchives(c *gin.Context) {
+	var req BulkDeleteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if req.Scope == "" {
+		req.Scope = DeleteScopeAll
+	}
+	if !validDeleteScope(req.Scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be all, snapshot or ai"})
+		return
+	}
+	ids := []string{}
+	seen := map[string]bool{}
+	for _, id := range req.IDs {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ids required"})
+		return
+	}
+	if len(ids) > maxBulkDelete {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many ids"})
+		return
+	}
+
+	var items []models.Archive
+	if err := s.DB.Where("id IN ?", ids).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	found := make([]string, 0, len(items))
+	var bytes int64
+	for _, item := range items {
+		found = append(found, item.ID)
+		bytes += item.StorageBytes
+		delete(seen, item.ID)
+	}
+	action := "archives.delete." + req.Scope
+	if req.Confirm == "" {
+		missing := []string{}
+		for _, id := range ids {
+			if seen[id] {
+				missing = append(missing, id)
+			}
+		}
+		c.JSON(http.StatusOK, BulkDeletePreview{
+			ConfirmPreview: s.confirmPreview(c, action, found),
+			Scope:          req.Scope,
+			Archives:       len(items),
+			StorageBytes:   bytes,
+			Missing:        missing,
+		})
+		return
+	}
+	if !s.checkConfirm(c, req.Confirm, action, found) {
+		return
+	}
+
+	ctx, actor := c.Request.Context(), currentPrincipal(c).Username
+	deleted, failed := 0, []string{}
+	for _, item := range items {
+		if err := s.deleteArchiveScoped(ctx, item, actor, req.Scope); err != nil {
+			log.Printf("bulk delete %s (%s): %v", item.ID, req.Scope, err)
+			failed = append(failed, item.ID)
+			continue
+		}
+		deleted++
+	}
+	c.JSON(http.StatusOK, gin.H{"deleted": deleted, "failed": failed})
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/cache"
+)
+
+type cacheWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+}
+
+func (w *cacheWriter) Write(b []byte) (int, error) {
+	w.buf.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	w.buf.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// cached serves a GET route from the response cache, keyed by path and
+// query. Only successful responses are stored; the namespace is invalidated
+// by the writes that can change them.
+func (s *Server) cached(ns string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.Cache == nil {
+			c.Next()
+			return
+		}
+		ctx := c.Request.Context()
+		key := cacheKey(c)
+		if raw, ok := s.Cache.Get(ctx, ns, key); ok {
+			if i := bytes.IndexByte(raw, '\n'); i >= 0 {
+				c.Header("X-Cache", "hit")
+				c.Data(http.StatusOK, string(raw[:i]), raw[i+1:])
+				c.Abort()
+				return
+			}
+		}
+		w := &cacheWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		if w.Status() == http.StatusOK && len(c.Errors) == 0 {
+			entry := append([]byte(w.Header().Get("Content-Type")+"\n"), w.buf.Bytes()...)
+			s.Cache.Set(ctx, ns, key, entry)
+		}
+	}
+}
+
+// invalidates drops the given namespaces after a successful write.
+func (s *Server) invalidates(namespaces ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		if c.Writer.Status() < http.StatusBadRequest {
+			s.Cache.Invalidate(context.Background(), namespaces...)
+		}
+	}
+}
+
+// invalidateForEvent keeps cached reads in step with the live events, which
+// every archive and taxonomy write already publishes.
+func (s *Server) invalidateForEvent(typ string) {
+	if typ == LiveTaxonomyChanged || strings.HasPrefix(typ, "archive.") {
+		s.Cache.Invalidate(context.Background(), cache.Taxonomy, cache.Graph, cache.ClientConfig, cache.Search)
+	}
+}
+
+// cacheKey leaves out access_token so credentials don't end up in keys.
+func cacheKey(c *gin.Context) string {
+	query := c.Request.URL.Query()
+	query.Del("access_token")
+	return c.Request.URL.Path + "?" + query.Encode()
+}
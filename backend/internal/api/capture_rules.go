@@ -0,0 +1,319 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+// maxRuleKeywords bounds the title keywords, and separately the tags, of one
+// capture rule.
+const maxRuleKeywords = 50
+
+type CaptureRuleRequest struct {
+	Name          string   `json:"name"`
+	Priority      int      `json:"priority"`
+	Domain        string   `json:"domain"`
+	URLPattern    string   `json:"urlPattern"`
+	TitleKeywords []string `json:"titleKeywords"`
+	Tags          []string `json:"tags"`
+	Path          string   `json:"path"`
+	Preset        string   `json:"preset"`
+	SkipAI        bool     `json:"skipAi"`
+	Stop          bool     `json:"stop"`
+	Enabled       bool     `json:"enabled"`
+}
+
+// CaptureRuleTest is a page to try the rules on: POST /api/rules/test.
+type CaptureRuleTest struct {
+	URL   string `json:"url"`
+	Title string `json:"title"`
+}
+
+// CaptureRuleOutcome is what the rules matching a capture add to it.
+type CaptureRuleOutcome struct {
+	Rules  []string `json:"rules"`
+	Tags   []string `json:"tags"`
+	Path   string   `json:"path,omitempty"`
+	Preset string   `json:"preset,omitempty"`
+	SkipAI bool     `json:"skipAi"`
+}
+
+func (r CaptureRuleRequest) validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("name required")
+	}
+	if normalizeRuleDomain(r.Domain) == "" && strings.TrimSpace(r.URLPattern) == "" && len(cleanKeywords(r.TitleKeywords)) == 0 {
+		return errors.New("domain, urlPattern or titleKeywords required")
+	}
+	if len(patchTags(r.Tags, nil, nil)) == 0 && strings.Trim(strings.TrimSpace(r.Path), "/") == "" &&
+		strings.TrimSpace(r.Preset) == "" && !r.SkipAI {
+		return errors.New("tags, path, preset or skipAi required")
+	}
+	if len(r.TitleKeywords) > maxRuleKeywords || len(r.Tags) > maxRuleKeywords {
+		return fmt.Errorf("at most %d titleKeywords and %d tags", maxRuleKeywords, maxRuleKeywords)
+	}
+	if pattern := strings.TrimSpace(r.URLPattern); pattern != "" {
+		if len(pattern) > 512 {
+			return errors.New("urlPattern is at most 512 bytes")
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid urlPattern: %v", err)
+		}
+	}
+	return nil
+}
+
+func applyCaptureRuleRequest(rule *models.CaptureRule, req CaptureRuleRequest) {
+	rule.Name = strings.TrimSpace(req.Name)
+	rule.Priority = req.Priority
+	rule.Domain = normalizeRuleDomain(req.Domain)
+	rule.URLPattern = strings.TrimSpace(req.URLPattern)
+	rule.TitleKeywordsJSON, _ = json.Marshal(cleanKeywords(req.TitleKeywords))
+	rule.TagsJSON, _ = json.Marshal(patchTags(req.Tags, nil, nil))
+	rule.Path = strings.Trim(strings.TrimSpace(req.Path), "/")
+	rule.Preset = strings.TrimSpace(req.Preset)
+	rule.SkipAI = req.SkipAI
+	rule.Stop = req.Stop
+	rule.Enabled = req.Enabled
+}
+
+// normalizeRuleDomain reduces "https://www.Example.com/", "*.example.com"
+// and the like to the host they name.
+func normalizeRuleDomain(raw string) string {
+	domain := strings.ToLower(strings.TrimSpace(raw))
+	if u, err := url.Parse(domain); err == nil && u.Host != "" {
+		domain = u.Hostname()
+	}
+	domain = strings.TrimPrefix(strings.TrimLeft(domain, "*."), "www.")
+	return strings.TrimSuffix(domain, "/")
+}
+
+func cleanKeywords(keywords []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, kw := range keywords {
+		kw = strings.Join(strings.Fields(kw), " ")
+		if kw != "" && !seen[strings.ToLower(kw)] {
+			seen[strings.ToLower(kw)] = true
+			out = append(out, kw)
+		}
+	}
+	return out
+}
+
+func (s *Server) listCaptureRules(c *gin.Context) {
+	var rules []models.CaptureRule
+	if err := s.DB.Order("priority asc").Order("name asc").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (s *Server) createCaptureRule(c *gin.Context) {
+	var req CaptureRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if !s.validCaptureRule(c, req) {
+		return
+	}
+	rule := models.CaptureRule{ID: uuid.New().String()}
+	applyCaptureRuleRequest(&rule, req)
+	if err := s.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditRuleSave, rule.ID, nil, rule)
+	c.JSON(http.StatusOK, rule)
+}
+
+func (s *Server) updateCaptureRule(c *gin.Context) {
+	var req CaptureRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if !s.validCaptureRule(c, req) {
+		return
+	}
+	var rule models.CaptureRule
+	if err := s.DB.First(&rule, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	before := rule
+	applyCaptureRuleRequest(&rule, req)
+	if err := s.DB.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditRuleSave, rule.ID, before, rule)
+	c.JSON(http.StatusOK, rule)
+}
+
+func (s *Server) deleteCaptureRule(c *gin.Context) {
+	var rule models.CaptureRule
+	if err := s.DB.First(&rule, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err := s.DB.Delete(&models.CaptureRule{}, "id = ?", rule.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditRuleDelete, rule.ID, rule, nil)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// testCaptureRules reports what the enabled rules would do to a capture of
+// the page, without counting it as a match.
+func (s *Server) testCaptureRules(c *gin.Context) {
+	var req CaptureRuleTest
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.URL) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url required"})
+		return
+	}
+	rules, err := s.enabledCaptureRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	outcome, _ := matchCaptureRules(rules, []string{req.URL}, req.Title)
+	c.JSON(http.StatusOK, outcome)
+}
+
+// validCaptureRule checks a rule request and that its preset exists. It
+// writes the response on failure.
+func (s *Server) validCaptureRule(c *gin.Context, req CaptureRuleRequest) bool {
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if _, err := s.findPreset(req.Preset); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown preset"})
+		return false
+	}
+	return true
+}
+
+func (s *Server) enabledCaptureRules() ([]models.CaptureRule, error) {
+	var rules []models.CaptureRule
+	err := s.DB.Where("enabled = ?", true).Order("priority asc").Order("name asc").Find(&rules).Error
+	return rules, err
+}
+
+// matchCaptureRules runs rules, in order, against a page known by urls (the
+// requested and the final URL) and its title. Tags add up; the first rule
+// with a path or preset sets it. It also returns the IDs of the rules that
+// matched.
+func matchCaptureRules(rules []models.CaptureRule, urls []string, title string) (CaptureRuleOutcome, []string) {
+	outcome := CaptureRuleOutcome{Rules: []string{}, Tags: []string{}}
+	ids := []string{}
+	for _, rule := range rules {
+		if !captureRuleMatches(rule, urls, title) {
+			continue
+		}
+		ids = append(ids, rule.ID)
+		outcome.Rules = append(outcome.Rules, rule.Name)
+		outcome.Tags = patchTags(outcome.Tags, jsonStrings(rule.TagsJSON), nil)
+		if outcome.Path == "" {
+			outcome.Path = rule.Path
+		}
+		if outcome.Preset == "" {
+			outcome.Preset = rule.Preset
+		}
+		outcome.SkipAI = outcome.SkipAI || rule.SkipAI
+		if rule.Stop {
+			break
+		}
+	}
+	return outcome, ids
+}
+
+func captureRuleMatches(rule models.CaptureRule, urls []string, title string) bool {
+	if rule.Domain != "" && !anyURL(urls, func(u *url.URL) bool {
+		host := strings.ToLower(u.Hostname())
+		return host == rule.Domain || strings.HasSuffix(host, "."+rule.Domain)
+	}) {
+		return false
+	}
+	if rule.URLPattern != "" {
+		re, err := regexp.Compile(rule.URLPattern)
+		if err != nil || !anyURL(urls, func(u *url.URL) bool { return re.MatchString(u.String()) }) {
+			return false
+		}
+	}
+	if keywords := jsonStrings(rule.TitleKeywordsJSON); len(keywords) > 0 {
+		lower := strings.ToLower(title)
+		found := false
+		for _, kw := range keywords {
+			if strings.Contains(lower, strings.ToLower(kw)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func anyURL(urls []string, match func(*url.URL) bool) bool {
+	for _, raw := range urls {
+		if u, err := url.Parse(strings.TrimSpace(raw)); err == nil && u.Host != "" && match(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyCaptureRules runs the enabled rules on a capture request before its
+// preset is resolved: it adds the rules' tags, files the capture under the
+// rules' path and picks their preset when the request names neither. A rule
+// that can't be loaded is logged and the capture goes ahead without rules.
+func (s *Server) applyCaptureRules(req *CreateArchiveRequest, finalURL string) CaptureRuleOutcome {
+	rules, err := s.enabledCaptureRules()
+	if err != nil {
+		log.Printf("capture rules: %v", err)
+		return CaptureRuleOutcome{}
+	}
+	outcome, ids := matchCaptureRules(rules, []string{req.URL, finalURL}, req.Title)
+	if len(ids) == 0 {
+		return outcome
+	}
+	req.Tags = patchTags(req.Tags, outcome.Tags, nil)
+	if outcome.Path != "" && len(req.Hierarchy) == 0 && len(req.HierarchyPaths) == 0 && req.Category == "" {
+		req.HierarchyPaths = []string{outcome.Path}
+	}
+	// A rule may outlive its preset; the capture then goes ahead without.
+	if req.Preset == "" && outcome.Preset != "" {
+		if _, err := s.findPreset(outcome.Preset); err != nil {
+			log.Printf("capture rules: preset %q: %v", outcome.Preset, err)
+		} else {
+			req.Preset = outcome.Preset
+		}
+	}
+	if err := s.DB.Model(&models.CaptureRule{}).Where("id IN ?", ids).Updates(map[string]any{
+		"matches":         gorm.Expr("matches + 1"),
+		"last_matched_at": time.Now(),
+	}).Error; err != nil {
+		log.Printf("capture rules: %v", err)
+	}
+	return outcome
+}
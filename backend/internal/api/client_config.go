@@ -0,0 +1,90 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+)
+
+type ClientCapabilities struct {
+	AuthRequired    bool  `json:"authRequired"`
+	AIEnabled       bool  `json:"aiEnabled"`
+	AutoTag         bool  `json:"autoTag"`
+	GraphAnalysis   bool  `json:"graphAnalysis"`
+	MaxPayloadBytes int64 `json:"maxPayloadBytes"`
+}
+
+type ClientConfigResponse struct {
+	Taxonomy     []TaxonomyNodeResponse  `json:"taxonomy"`
+	RecentTags   []string                `json:"recentTags"`
+	Presets      []CapturePresetResponse `json:"presets"`
+	Capabilities ClientCapabilities      `json:"capabilities"`
+}
+
+// getClientConfig bundles everything the extension popup needs into a single
+// response. The taxonomy is trimmed to the top two levels to keep it small.
+func (s *Server) getClientConfig(c *gin.Context) {
+	nodes, err := s.loadTaxonomyNodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	shallow := make([]models.TaxonomyNode, 0, len(nodes))
+	for _, n := range nodes {
+		if n.Level <= 1 {
+			shallow = append(shallow, n)
+		}
+	}
+
+	presets, err := s.loadPresets()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	presetResp := make([]CapturePresetResponse, 0, len(presets))
+	for _, p := range presets {
+		presetResp = append(presetResp, toCapturePresetResponse(p))
+	}
+
+	c.JSON(http.StatusOK, ClientConfigResponse{
+		Taxonomy:   buildTaxonomyTree(shallow),
+		RecentTags: s.recentTags(50, 20),
+		Presets:    presetResp,
+		Capabilities: ClientCapabilities{
+			AuthRequired:    s.AuthEnabled,
+			AIEnabled:       s.LLM != nil && s.LLM.Enabled(),
+			AutoTag:         s.autoTagOnCapture(),
+			GraphAnalysis:   s.Eino != nil,
+			MaxPayloadBytes: s.MaxPayloadBytes,
+		},
+	})
+}
+
+func (s *Server) recentTags(scan, limit int) []string {
+	var items []models.Archive
+	out := []string{}
+	if err := s.DB.Select("id", "tags_json").Order("created_at desc").Limit(scan).Find(&items).Error; err != nil {
+		return out
+	}
+	seen := map[string]bool{}
+	for _, item := range items {
+		tags := []string{}
+		if len(item.TagsJSON) > 0 {
+			_ = json.Unmarshal(item.TagsJSON, &tags)
+		}
+		for _, t := range tags {
+			if t == "" || seen[t] {
+				continue
+			}
+			seen[t] = true
+			out = append(out, t)
+			if len(out) >= limit {
+				return out
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/cluster"
+	"webarchive/internal/models"
+	"webarchive/internal/vectorstore"
+)
+
+const clusterSampleSize = 5
+
+type ClusterJobStatus struct {
+	Running    bool       `json:"running"`
+	Model      string     `json:"model,omitempty"`
+	Archives   int        `json:"archives"`
+	Clusters   int        `json:"clusters"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+type ClusterRequest struct {
+	K int `json:"k"`
+}
+
+type ClusterResponse struct {
+	ID        string            `json:"id"`
+	Label     string            `json:"label"`
+	Size      int               `json:"size"`
+	Samples   []ArchiveResponse `json:"samples"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+func (s *Server) listClusters(c *gin.Context) {
+	var rows []models.ArchiveCluster
+	if err := s.DB.Order("size desc").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	out := make([]ClusterResponse, 0, len(rows))
+	for _, row := range rows {
+		ids := clusterArchiveIDs(row)
+		if len(ids) > clusterSampleSize {
+			ids = ids[:clusterSampleSize]
+		}
+		samples, err := s.loadArchivesOrdered(ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		out = append(out, ClusterResponse{ID: row.ID, Label: row.Label, Size: row.Size, Samples: samples, CreatedAt: row.CreatedAt})
+	}
+	c.JSON(http.StatusOK, gin.H{"clusters": out, "job": s.getClusterStatus()})
+}
+
+func (s *Server) getCluster(c *gin.Context) {
+	var row models.ArchiveCluster
+	if err := s.DB.First(&row, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	items, err := s.loadArchivesOrdered(clusterArchiveIDs(row))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": row.ID, "label": row.Label, "size": row.Size, "items": items})
+}
+
+func clusterArchiveIDs(row models.ArchiveCluster) []string {
+	ids := []string{}
+	if len(row.ArchiveIDsJSON) > 0 {
+		_ = json.Unmarshal(row.ArchiveIDsJSON, &ids)
+	}
+	return ids
+}
+
+func (s *Server) loadArchivesOrdered(ids []string) ([]ArchiveResponse, error) {
+	out := []ArchiveResponse{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+	var items []models.Archive
+	if err := s.DB.Omit("content_text").Where("id IN ?", ids).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	byID := map[string]models.Archive{}
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	for _, id := range ids {
+		if item, ok := byID[id]; ok {
+			out = append(out, toArchiveResponse(item, nil))
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) startClustering(c *gin.Context) {
+	if s.LLM == nil || !s.LLM.EmbeddingsEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "embedding model not configured"})
+		return
+	}
+	var req ClusterRequest
+	_ = c.ShouldBindJSON(&req)
+
+	s.clusterMu.Lock()
+	if s.clusterStatus.Running {
+		status := s.clusterStatus
+		s.clusterMu.Unlock()
+		c.JSON(http.StatusOK, status)
+		return
+	}
+	now := time.Now()
+	s.clusterStatus = ClusterJobStatus{Running: true, Model: s.LLM.EmbeddingModel, StartedAt: &now}
+	s.clusterMu.Unlock()
+
+	go s.runClustering(req.K)
+	c.JSON(http.StatusOK, s.getClusterStatus())
+}
+
+func (s *Server) getClusterStatus() ClusterJobStatus {
+	s.clusterMu.Lock()
+	defer s.clusterMu.Unlock()
+	return s.clusterStatus
+}
+
+// runClustering groups every embedded archive with k-means over the cached
+// vectors, labels each group with the LLM (falling back to the most common
+// tag) and replaces the stored clusters.
+func (s *Server) runClustering(k int) {
+	model := s.LLM.EmbeddingModel
+	var lastErr string
+	var archives, clusters int
+	defer func() {
+		now := time.Now()
+		s.clusterMu.Lock()
+		s.clusterStatus.Running = false
+		s.clusterStatus.FinishedAt = &now
+		s.clusterStatus.Archives = archives
+		s.clusterStatus.Clusters = clusters
+		s.clusterStatus.LastError = lastErr
+		s.clusterMu.Unlock()
+	}()
+
+	var rows []models.ArchiveEmbedding
+	if err := s.DB.Where("model = ?", model).Find(&rows).Error; err != nil {
+		lastErr = err.Error()
+		return
+	}
+	if len(rows) < 2 {
+		lastErr = "not enough embedded archives, run the embedding backfill first"
+		return
+	}
+	archives = len(rows)
+	if k <= 0 {
+		k = cluster.DefaultK(len(rows))
+	}
+	vectors := make([][]float32, len(rows))
+	for i, row := range rows {
+		vectors[i] = vectorstore.DecodeVector(row.Vector)
+	}
+	assign := cluster.KMeans(vectors, k, 50)
+
+	groups := map[int][]string{}
+	for i, g := range assign {
+		groups[g] = append(groups[g], rows[i].ArchiveID)
+	}
+	out := make([]models.ArchiveCluster, 0, len(groups))
+	for _, ids := range groups {
+		idsJSON, _ := json.Marshal(ids)
+		out = append(out, models.ArchiveCluster{
+			ID:             uuid.New().String(),
+			Model:          model,
+			Label:          s.labelCluster(ids),
+			Size:           len(ids),
+			ArchiveIDsJSON: idsJSON,
+		})
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.ArchiveCluster{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&out).Error
+	})
+	if err != nil {
+		lastErr = err.Error()
+		return
+	}
+	clusters = len(out)
+}
+
+func (s *Server) labelCluster(ids []string) string {
+	sample := ids
+	if len(sample) > 12 {
+		sample = sample[:12]
+	}
+	var items []models.Archive
+	if err := s.DB.Select("id", "title", "tags_json").Where("id IN ?", sample).Find(&items).Error; err != nil || len(items) == 0 {
+		return "未命名"
+	}
+	fallback := commonTag(items)
+
+	if s.LLM == nil || !s.LLM.Enabled() {
+		return fallback
+	}
+	titles := make([]string, 0, len(items))
+	for _, item := range items {
+		titles = append(titles, "- "+item.Title)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	system := "You name clusters of saved web pages. Return strict JSON only."
+	user := fmt.Sprintf("Give a short topic label (2-6 words, same language as the titles) for this group of pages.\n"+
+		"Return JSON: {\"label\": string}.\nTitles:\n%s", strings.Join(titles, "\n"))
+	raw, err := s.LLM.ChatJSON(ctx, system, user, 0.2)
+	if err != nil {
+		return fallback
+	}
+	var resp struct {
+		Label string `json:"label"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(raw)), &resp); err != nil || strings.TrimSpace(resp.Label) == "" {
+		return fallback
+	}
+	return truncate(strings.TrimSpace(resp.Label), 255)
+}
+
+func commonTag(items []models.Archive) string {
+	counts := map[string]int{}
+	for _, item := range items {
+		tags := []string{}
+		if len(item.TagsJSON) > 0 {
+			_ = json.Unmarshal(item.TagsJSON, &tags)
+		}
+		for _, t := range tags {
+			counts[t]++
+		}
+	}
+	if len(counts) == 0 {
+		return "未命名"
+	}
+	tags := make([]string, 0, len(counts))
+	for t := range counts {
+		tags = append(tags, t)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if counts[tags[i]] != counts[tags[j]] {
+			return counts[tags[i]] > counts[tags[j]]
+		}
+		return tags[i] < tags[j]
+	})
+	return tags[0]
+}
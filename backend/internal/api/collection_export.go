@@ -0,0 +1,91 @@
+package api
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+// exportCollection bundles a collection pin's archives, in the
+// collection's order, into one EPUB with a chapter per archive built from
+// its reader text. epub is the only format.
+func (s *Server) exportCollection(c *gin.Context) {
+	if format := c.DefaultQuery("format", "epub"); format != "epub" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be epub"})
+		return
+	}
+	book, ok := s.collectionBook(c)
+	if !ok {
+		return
+	}
+	c.Header("Content-Type", "application/epub+zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": epubFilename(book)}))
+	c.Status(http.StatusOK)
+	_ = processor.WriteEPUB(c.Writer, book)
+}
+
+const epubArchiveColumns = "id, title, url, byline, site_name, excerpt, content_text, captured_at, created_at"
+
+func epubFilename(book processor.EPUBBook) string {
+	return safeFilename(book.Title, book.ID) + ".epub"
+}
+
+func epubChapterOf(item models.Archive) processor.EPUBChapter {
+	text := item.ContentText
+	if strings.TrimSpace(text) == "" {
+		text = item.Excerpt
+	}
+	date := item.CapturedAt
+	if date == nil {
+		date = &item.CreatedAt
+	}
+	return processor.EPUBChapter{
+		Title:    item.Title,
+		Byline:   item.Byline,
+		SiteName: item.SiteName,
+		URL:      item.URL,
+		Date:     date,
+		Text:     text,
+	}
+}
+
+// collectionBook loads the current user's collection pin :id as a book,
+// answering the request itself when it can't.
+func (s *Server) collectionBook(c *gin.Context) (processor.EPUBBook, bool) {
+	var pin models.DashboardPin
+	if err := s.DB.Where("id = ? AND owner = ? AND kind = ?", c.Param("id"), dashboardOwner(c), PinCollection).First(&pin).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return processor.EPUBBook{}, false
+	}
+	ids := jsonStrings(pin.ArchiveIDsJSON)
+	var items []models.Archive
+	if len(ids) > 0 {
+		if err := s.reader().Select(epubArchiveColumns).Where("id IN ?", ids).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return processor.EPUBBook{}, false
+		}
+	}
+	byID := make(map[string]models.Archive, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	book := processor.EPUBBook{ID: pin.ID, Title: pin.Label, Modified: pin.UpdatedAt}
+	if strings.TrimSpace(book.Title) == "" {
+		book.Title = "WebArchive"
+	}
+	for _, id := range ids {
+		if item, ok := byID[id]; ok {
+			book.Chapters = append(book.Chapters, epubChapterOf(item))
+		}
+	}
+	if len(book.Chapters) == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "collection is empty"})
+		return processor.EPUBBook{}, false
+	}
+	return book, true
+}
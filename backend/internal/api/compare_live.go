@@ -0,0 +1,102 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/textutil"
+)
+
+type LiveComparison struct {
+	ArchiveID  string     `json:"archiveId"`
+	URL        string     `json:"url"`
+	FinalURL   string     `json:"finalUrl"`
+	Status     int        `json:"status"`
+	LiveTitle  string     `json:"liveTitle"`
+	CapturedAt *time.Time `json:"capturedAt"`
+	ComparedAt time.Time  `json:"comparedAt"`
+	// Similarity is the share of lines the snapshot and live page have in
+	// common, from 0 to 100; ChangePercent is the rest.
+	Similarity    float64  `json:"similarity"`
+	ChangePercent float64  `json:"changePercent"`
+	SnapshotWords int      `json:"snapshotWords"`
+	LiveWords     int      `json:"liveWords"`
+	AddedLines    int      `json:"addedLines"`
+	RemovedLines  int      `json:"removedLines"`
+	Added         []string `json:"added"`
+	Removed       []string `json:"removed"`
+}
+
+// compareLive fetches the archive's page as it is now and diffs its text
+// against the snapshot, the same way page watching does, without storing
+// anything. Both sides are extracted from HTML so reader-view captures
+// don't show up as changed.
+func (s *Server) compareLive(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.Omit("content_text").First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	pageURL := item.FinalURL
+	if pageURL == "" {
+		pageURL = item.URL
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), time.Minute)
+	defer cancel()
+	jar, err := s.captureJar(pageURL, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load cookies failed"})
+		return
+	}
+	page, err := s.Processor.FetchPage(ctx, pageURL, processor.Options{Jar: jar, HostRules: s.userHostRules(item.CapturedBy)})
+	if errors.Is(err, processor.ErrHostNotAllowed) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "host not allowed for capture"})
+		return
+	}
+	if errors.Is(err, processor.ErrRobotsDisallowed) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "url disallowed by robots.txt"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "fetch failed: " + err.Error()})
+		return
+	}
+	liveTitle, live := processor.ExtractText(page.HTML)
+	if strings.TrimSpace(live) == "" {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "live page has no text"})
+		return
+	}
+	snapshot, err := s.snapshotText(ctx, item)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load snapshot failed"})
+		return
+	}
+
+	added, removed, percent := textutil.LineDiff(snapshot, live)
+	percent = math.Round(percent*10) / 10
+	c.JSON(http.StatusOK, LiveComparison{
+		ArchiveID:     item.ID,
+		URL:           pageURL,
+		FinalURL:      page.FinalURL,
+		Status:        page.Status,
+		LiveTitle:     liveTitle,
+		CapturedAt:    item.CapturedAt,
+		ComparedAt:    time.Now(),
+		Similarity:    100 - percent,
+		ChangePercent: percent,
+		SnapshotWords: len(strings.Fields(snapshot)),
+		LiveWords:     len(strings.Fields(live)),
+		AddedLines:    len(added),
+		RemovedLines:  len(removed),
+		Added:         sampleLines(added),
+		Removed:       sampleLines(removed),
+	})
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// confirmTTL is how long a dry run's confirmation token can be redeemed.
+const confirmTTL = 10 * time.Minute
+
+// ConfirmPreview is the part of a dry-run response shared by destructive
+// bulk operations: repeating the request with Token carries it out.
+type ConfirmPreview struct {
+	DryRun    bool      `json:"dryRun"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// confirmPreview signs a token for action by the current user over the
+// exact set of IDs the dry run found. The token is stateless, so any
+// replica can redeem it, and it stops matching as soon as the set changes:
+// a client that confirms after the selection grew, or that sends someone
+// else's token, gets a 409 rather than deleting more than it was shown.
+func (s *Server) confirmPreview(c *gin.Context, action string, affected []string) ConfirmPreview {
+	expires := time.Now().Add(confirmTTL).Truncate(time.Second)
+	exp := strconv.FormatInt(expires.Unix(), 36)
+	return ConfirmPreview{
+		DryRun:    true,
+		Token:     exp + "-" + s.confirmSignature(c, action, affected, exp),
+		ExpiresAt: expires,
+	}
+}
+
+// checkConfirm answers the request itself when token doesn't confirm
+// action over affected.
+func (s *Server) checkConfirm(c *gin.Context, token, action string, affected []string) bool {
+	exp, sig, ok := strings.Cut(strings.TrimSpace(token), "-")
+	unix, err := strconv.ParseInt(exp, 36, 64)
+	if !ok || err != nil || time.Now().Unix() > unix || !hmac.Equal([]byte(sig), []byte(s.confirmSignature(c, action, affected, exp))) {
+		c.JSON(http.StatusConflict, gin.H{"error": "confirmation token expired or the affected items changed; repeat the dry run"})
+		return false
+	}
+	return true
+}
+
+// confirmSignature shares the viewer ticket key; the "confirm" prefix keeps
+// the two kinds of signature from ever being interchangeable.
+func (s *Server) confirmSignature(c *gin.Context, action string, affected []string, exp string) string {
+	ids := append([]string(nil), affected...)
+	sort.Strings(ids)
+	set := sha256.Sum256([]byte(strings.Join(ids, "\n")))
+	mac := hmac.New(sha256.New, s.ViewerSecret)
+	mac.Write([]byte("confirm\n" + action + "\n" + currentPrincipal(c).Username + "\n" + hex.EncodeToString(set[:]) + "\n" + exp))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
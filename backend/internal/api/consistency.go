@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+const (
+	AuditConsistencyRepair = "consistency_repair"
+
+	consistencySample = 100
+)
+
+// ConsistencyIssue lists the rows affected by one kind of dangling reference.
+// IDs is capped at consistencySample; Count is the full total.
+type ConsistencyIssue struct {
+	Count int      `json:"count"`
+	IDs   []string `json:"ids"`
+}
+
+type ConsistencyReport struct {
+	// ArchivePath rows whose archive no longer exists.
+	PathsMissingArchive ConsistencyIssue `json:"pathsMissingArchive"`
+	// ArchivePath rows whose taxonomy node no longer exists.
+	PathsMissingNode ConsistencyIssue `json:"pathsMissingNode"`
+	// TaxonomyNode rows whose parent no longer exists.
+	NodesMissingParent ConsistencyIssue `json:"nodesMissingParent"`
+	// Archives with a hierarchy path but no ArchivePath rows.
+	ArchivesMissingPaths ConsistencyIssue `json:"archivesMissingPaths"`
+	Repaired             bool             `json:"repaired"`
+	CheckedAt            time.Time        `json:"checkedAt"`
+}
+
+func (r ConsistencyReport) empty() bool {
+	return r.PathsMissingArchive.Count == 0 && r.PathsMissingNode.Count == 0 &&
+		r.NodesMissingParent.Count == 0 && r.ArchivesMissingPaths.Count == 0
+}
+
+func (s *Server) getConsistency(c *gin.Context) {
+	report, err := s.checkConsistency(c.Request.Context(), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func (s *Server) repairConsistency(c *gin.Context) {
+	report, err := s.checkConsistency(c.Request.Context(), true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "repair failed: " + err.Error()})
+		return
+	}
+	if !report.empty() {
+		s.recordAdminAudit(c, AuditConsistencyRepair, "taxonomy", report, nil)
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// StartConsistencyScheduler checks for dangling references every interval,
+// repairing them when repair is set.
+func (s *Server) StartConsistencyScheduler(ctx context.Context, interval time.Duration, repair bool) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			report, err := s.checkConsistency(ctx, repair)
+			if err != nil {
+				log.Printf("consistency: check failed: %v", err)
+				continue
+			}
+			if !report.empty() {
+				log.Printf("consistency: %d paths without archive, %d paths without node, %d nodes without parent, %d archives without paths (repaired=%v)",
+					report.PathsMissingArchive.Count, report.PathsMissingNode.Count,
+					report.NodesMissingParent.Count, report.ArchivesMissingPaths.Count, report.Repaired)
+			}
+		}
+	}()
+}
+
+func (s *Server) checkConsistency(ctx context.Context, repair bool) (ConsistencyReport, error) {
+	db := s.DB.WithContext(ctx)
+	report := ConsistencyReport{
+		PathsMissingArchive:  ConsistencyIssue{IDs: []string{}},
+		PathsMissingNode:     ConsistencyIssue{IDs: []string{}},
+		NodesMissingParent:   ConsistencyIssue{IDs: []string{}},
+		ArchivesMissingPaths: ConsistencyIssue{IDs: []string{}},
+		CheckedAt:            time.Now(),
+	}
+
+	var missingArchive []models.ArchivePath
+	err := db.Where("NOT EXISTS (SELECT 1 FROM archives WHERE archives.id = archive_paths.archive_id)").
+		Find(&missingArchive).Error
+	if err != nil {
+		return report, err
+	}
+	var missingNode []models.ArchivePath
+	err = db.Where("NOT EXISTS (SELECT 1 FROM taxonomy_nodes WHERE taxonomy_nodes.id = archive_paths.node_id)").
+		Find(&missingNode).Error
+	if err != nil {
+		return report, err
+	}
+	var orphanNodes []models.TaxonomyNode
+	err = db.Where("parent_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM taxonomy_nodes AS p WHERE p.id = taxonomy_nodes.parent_id)").
+		Find(&orphanNodes).Error
+	if err != nil {
+		return report, err
+	}
+	var unpathed []models.Archive
+	err = db.Select("id", "hierarchy_path").
+		Where("hierarchy_path <> '' AND NOT EXISTS (SELECT 1 FROM archive_paths WHERE archive_paths.archive_id = archives.id)").
+		Find(&unpathed).Error
+	if err != nil {
+		return report, err
+	}
+
+	for _, row := range missingArchive {
+		report.PathsMissingArchive.add(row.ID)
+	}
+	for _, row := range missingNode {
+		report.PathsMissingNode.add(row.ID)
+	}
+	for _, node := range orphanNodes {
+		report.NodesMissingParent.add(node.ID)
+	}
+	for _, item := range unpathed {
+		report.ArchivesMissingPaths.add(item.ID)
+	}
+	if !repair || report.empty() {
+		return report, nil
+	}
+
+	limits := s.taxonomyLimits()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if len(missingArchive) > 0 {
+			ids := make([]string, 0, len(missingArchive))
+			for _, row := range missingArchive {
+				ids = append(ids, row.ID)
+			}
+			if err := tx.Where("id IN ?", ids).Delete(&models.ArchivePath{}).Error; err != nil {
+				return err
+			}
+		}
+		// Parents first, so repointed paths below land in a connected tree.
+		for _, node := range orphanNodes {
+			parentPath := ""
+			if i := strings.LastIndex(node.Path, "/"); i > 0 {
+				parentPath = node.Path[:i]
+			}
+			var parentID *string
+			if parentPath != "" {
+				if err := ensureTaxonomyPathDB(tx, strings.Split(parentPath, "/"), limits); err != nil {
+					return err
+				}
+				parent, err := getNodeByPathDB(tx, parentPath)
+				if err != nil {
+					return err
+				}
+				if parent.ID != "" {
+					parentID = &parent.ID
+				}
+			}
+			if err := tx.Model(&models.TaxonomyNode{}).Where("id = ?", node.ID).Update("parent_id", parentID).Error; err != nil {
+				return err
+			}
+		}
+		for _, row := range missingNode {
+			if err := repointArchivePath(tx, row, limits); err != nil {
+				return err
+			}
+		}
+		batch := make(map[string][]string, len(unpathed))
+		for _, item := range unpathed {
+			batch[item.ID] = []string{item.HierarchyPath}
+		}
+		return replaceArchivePathsBatchDB(tx, batch, limits)
+	})
+	if err != nil {
+		return report, err
+	}
+	report.Repaired = true
+	s.publishTaxonomyChanged("repair")
+	return report, nil
+}
+
+// repointArchivePath recreates the node a path row refers to, or drops the
+// row when the archive is already filed there.
+func repointArchivePath(tx *gorm.DB, row models.ArchivePath, limits taxonomyLimits) error {
+	path := limits.clampPath(row.Path)
+	if path == "" {
+		return tx.Delete(&models.ArchivePath{}, "id = ?", row.ID).Error
+	}
+	if err := ensureTaxonomyPathDB(tx, strings.Split(path, "/"), limits); err != nil {
+		return err
+	}
+	node, err := getNodeByPathDB(tx, path)
+	if err != nil {
+		return err
+	}
+	var dup int64
+	if err := tx.Model(&models.ArchivePath{}).Where("archive_id = ? AND node_id = ?", row.ArchiveID, node.ID).Count(&dup).Error; err != nil {
+		return err
+	}
+	if dup > 0 {
+		return tx.Delete(&models.ArchivePath{}, "id = ?", row.ID).Error
+	}
+	return tx.Model(&models.ArchivePath{}).Where("id = ?", row.ID).
+		Updates(map[string]any{"node_id": node.ID, "path": path}).Error
+}
+
+func (i *ConsistencyIssue) add(id string) {
+	i.Count++
+	if len(i.IDs) < consistencySample {
+		i.IDs = append(i.IDs, id)
+	}
+}
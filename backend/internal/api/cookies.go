@@ -0,0 +1,210 @@
+package api
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"golang.org/x/net/publicsuffix"
+	"gorm.io/gorm/clause"
+
+	"webarchive/internal/models"
+	"webarchive/internal/settings"
+)
+
+// CaptureCookie mirrors chrome.cookies.Cookie; the extension sends the
+// page's cookies with a capture so assets behind a login download too.
+// They are used for that capture only and never stored.
+type CaptureCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Domain   string `json:"domain"`
+	Path     string `json:"path"`
+	Secure   bool   `json:"secure"`
+	HostOnly bool   `json:"hostOnly"`
+}
+
+type DomainCookieRequest struct {
+	Domain  string `json:"domain"`
+	Cookies string `json:"cookies"`
+}
+
+type DomainCookieResponse struct {
+	ID        string    `json:"id"`
+	Domain    string    `json:"domain"`
+	Names     []string  `json:"names"`
+	Encrypted bool      `json:"encrypted"`
+	CreatedBy string    `json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// toDomainCookieResponse only reveals cookie names; values never leave the
+// server once saved.
+func toDomainCookieResponse(row models.DomainCookie) DomainCookieResponse {
+	names := []string{}
+	if header, err := settings.OpenCookies(row.Cookies); err == nil {
+		for _, ck := range parseCookieHeader(header) {
+			names = append(names, ck.Name)
+		}
+	}
+	return DomainCookieResponse{
+		ID:        row.ID,
+		Domain:    row.Domain,
+		Names:     names,
+		Encrypted: strings.HasPrefix(row.Cookies, "enc:"),
+		CreatedBy: row.CreatedBy,
+		CreatedAt: row.CreatedAt,
+		UpdatedAt: row.UpdatedAt,
+	}
+}
+
+func parseCookieHeader(header string) []*http.Cookie {
+	req := http.Request{Header: http.Header{"Cookie": {header}}}
+	return req.Cookies()
+}
+
+func normalizeCookieDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if u, err := url.Parse(domain); err == nil && u.Host != "" {
+		domain = u.Hostname()
+	}
+	return strings.TrimPrefix(domain, ".")
+}
+
+func (s *Server) listDomainCookies(c *gin.Context) {
+	var rows []models.DomainCookie
+	if err := s.DB.Order("domain asc").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	out := make([]DomainCookieResponse, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, toDomainCookieResponse(row))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// saveDomainCookies creates or replaces the cookies for a domain.
+func (s *Server) saveDomainCookies(c *gin.Context) {
+	var req DomainCookieRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	domain := normalizeCookieDomain(req.Domain)
+	if domain == "" || strings.ContainsAny(domain, "/ ") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "domain required"})
+		return
+	}
+	if len(parseCookieHeader(req.Cookies)) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cookies must be a Cookie header like \"a=1; b=2\""})
+		return
+	}
+	sealed, err := settings.SealCookies(strings.TrimSpace(req.Cookies))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "encrypt failed"})
+		return
+	}
+
+	var before *DomainCookieResponse
+	var existing models.DomainCookie
+	if tx := s.DB.Where("domain = ?", domain).Limit(1).Find(&existing); tx.Error == nil && tx.RowsAffected > 0 {
+		resp := toDomainCookieResponse(existing)
+		before = &resp
+	}
+	row := models.DomainCookie{
+		ID:        uuid.New().String(),
+		Domain:    domain,
+		Cookies:   sealed,
+		CreatedBy: currentPrincipal(c).Username,
+	}
+	if before != nil {
+		row.ID = existing.ID
+		row.CreatedAt = existing.CreatedAt
+	}
+	if err := s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "domain"}},
+		DoUpdates: clause.AssignmentColumns([]string{"cookies", "created_by", "updated_at"}),
+	}).Create(&row).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	resp := toDomainCookieResponse(row)
+	s.recordAdminAudit(c, AuditCookiesSave, domain, before, resp)
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) deleteDomainCookies(c *gin.Context) {
+	var row models.DomainCookie
+	if err := s.DB.First(&row, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err := s.DB.Delete(&models.DomainCookie{}, "id = ?", row.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditCookiesDelete, row.Domain, toDomainCookieResponse(row), nil)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// captureJar collects the cookies a fetch for pageURL may use: the ones
+// configured for the page's domain (or any parent domain) plus any the
+// extension sent along. It returns nil when there are none.
+func (s *Server) captureJar(pageURL string, sent []CaptureCookie) (http.CookieJar, error) {
+	page, err := url.Parse(pageURL)
+	if err != nil || page.Hostname() == "" {
+		return nil, nil
+	}
+	host := strings.ToLower(page.Hostname())
+	candidates := []string{host}
+	for rest := host; strings.Contains(rest, "."); {
+		rest = rest[strings.Index(rest, ".")+1:]
+		candidates = append(candidates, rest)
+	}
+	var rows []models.DomainCookie
+	if err := s.DB.Where("domain IN ?", candidates).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 && len(sent) == 0 {
+		return nil, nil
+	}
+
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		header, err := settings.OpenCookies(row.Cookies)
+		if err != nil {
+			return nil, err
+		}
+		cookies := parseCookieHeader(header)
+		for _, ck := range cookies {
+			ck.Domain = row.Domain
+			ck.Path = "/"
+		}
+		jar.SetCookies(&url.URL{Scheme: page.Scheme, Host: row.Domain}, cookies)
+	}
+	// Cookies from the browser go last so a fresh session wins over a
+	// stale configured one.
+	for _, sc := range sent {
+		if sc.Name == "" {
+			continue
+		}
+		ck := &http.Cookie{Name: sc.Name, Value: sc.Value, Path: sc.Path, Secure: sc.Secure}
+		if !sc.HostOnly {
+			ck.Domain = strings.TrimPrefix(sc.Domain, ".")
+		}
+		if ck.Path == "" {
+			ck.Path = "/"
+		}
+		jar.SetCookies(page, []*http.Cookie{ck})
+	}
+	return jar, nil
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+)
+
+// ThemeDark selects the dark variant in ?theme= on the HTML routes.
+const ThemeDark = "dark"
+
+// darkObject is the dark variant stored next to index.html, built the first
+// time it is asked for.
+const darkObject = "dark.html"
+
+var errNoSnapshot = errors.New("archive has no stored snapshot")
+
+// darkVariant returns the stored dark variant of an archive, building and
+// storing it from the snapshot when there is none yet.
+func (s *Server) darkVariant(ctx context.Context, id string) (string, error) {
+	var item models.Archive
+	if err := s.DB.Select("id", "html_path", "dark_path", "storage_bytes").First(&item, "id = ?", id).Error; err != nil {
+		return "", err
+	}
+	if item.DarkPath != "" {
+		return item.DarkPath, nil
+	}
+	if item.HTMLPath == "" {
+		return "", errNoSnapshot
+	}
+	prefix := storage.ArchivePrefix(item.ID)
+	obj, err := s.Store.Get(ctx, prefix+"/"+item.HTMLPath)
+	if err != nil {
+		return "", err
+	}
+	page, err := io.ReadAll(obj)
+	obj.Close()
+	if err != nil {
+		return "", err
+	}
+	variant, err := processor.DarkVariant(page)
+	if err != nil {
+		return "", err
+	}
+	if err := s.Store.PutBytes(ctx, prefix+"/"+darkObject, variant, "text/html; charset=utf-8"); err != nil {
+		return "", err
+	}
+	if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).Updates(map[string]any{
+		"dark_path":     darkObject,
+		"storage_bytes": item.StorageBytes + int64(len(variant)),
+	}).Error; err != nil {
+		return "", err
+	}
+	return darkObject, nil
+}
+
+// dropDarkVariant removes a stored dark variant so it is rebuilt from the
+// current snapshot on its next view, returning the bytes it freed.
+func (s *Server) dropDarkVariant(ctx context.Context, item models.Archive) (int64, error) {
+	if item.DarkPath == "" {
+		return 0, nil
+	}
+	objectPath := storage.ArchivePrefix(item.ID) + "/" + item.DarkPath
+	var size int64
+	if obj, err := s.Store.Get(ctx, objectPath); err == nil {
+		if info, err := obj.Stat(); err == nil {
+			size = info.Size
+		}
+		obj.Close()
+	}
+	if err := s.Store.Remove(ctx, objectPath); err != nil {
+		return 0, err
+	}
+	return size, nil
+}
@@ -0,0 +1,338 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+const (
+	PinNode       = "node"
+	PinTag        = "tag"
+	PinSearch     = "search"
+	PinCollection = "collection"
+)
+
+const (
+	dashboardMaxPins       = 50
+	dashboardMaxCollection = 500
+)
+
+type DashboardPinRequest struct {
+	Kind       string   `json:"kind"`
+	Ref        string   `json:"ref"`
+	Label      string   `json:"label"`
+	ArchiveIDs []string `json:"archiveIds"`
+	Position   *int     `json:"position"`
+}
+
+type DashboardRecent struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	URL       string    `json:"url"`
+	SiteName  string    `json:"siteName"`
+	Favicon   string    `json:"favicon"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+type DashboardPinResponse struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Label      string            `json:"label"`
+	Ref        string            `json:"ref"`
+	ArchiveIDs []string          `json:"archiveIds,omitempty"`
+	Position   int               `json:"position"`
+	Count      int64             `json:"count"`
+	Missing    bool              `json:"missing,omitempty"`
+	Recent     []DashboardRecent `json:"recent"`
+}
+
+func toDashboardPinResponse(pin models.DashboardPin) DashboardPinResponse {
+	resp := DashboardPinResponse{
+		ID:       pin.ID,
+		Kind:     pin.Kind,
+		Label:    pin.Label,
+		Ref:      pin.Ref,
+		Position: pin.Position,
+		Recent:   []DashboardRecent{},
+	}
+	if pin.Kind == PinCollection {
+		resp.ArchiveIDs = jsonStrings(pin.ArchiveIDsJSON)
+	}
+	return resp
+}
+
+// dashboardOwner keys pins by user ID, or by name for the single local user
+// when authentication is off.
+func dashboardOwner(c *gin.Context) string {
+	p := currentPrincipal(c)
+	if p.UserID != "" {
+		return p.UserID
+	}
+	return p.Username
+}
+
+// pinFilter is the SQL condition on archives that a pin stands for.
+type pinFilter struct {
+	cond    string
+	args    []any
+	missing bool
+}
+
+// pinFilters builds one condition per pin; node pins are resolved to their
+// paths with a single lookup.
+func (s *Server) pinFilters(db *gorm.DB, pins []models.DashboardPin) ([]pinFilter, error) {
+	nodeIDs := []string{}
+	for _, pin := range pins {
+		if pin.Kind == PinNode {
+			nodeIDs = append(nodeIDs, pin.Ref)
+		}
+	}
+	paths := map[string]string{}
+	if len(nodeIDs) > 0 {
+		var nodes []models.TaxonomyNode
+		if err := db.Select("id", "path").Where("id IN ?", nodeIDs).Find(&nodes).Error; err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			paths[node.ID] = node.Path
+		}
+	}
+
+	out := make([]pinFilter, 0, len(pins))
+	for _, pin := range pins {
+		f := pinFilter{cond: "1 = 0"}
+		switch pin.Kind {
+		case PinNode:
+			if path, ok := paths[pin.Ref]; ok {
+				f = pinFilter{cond: "id IN (SELECT archive_id FROM archive_paths WHERE path = ? OR path LIKE ?)", args: []any{path, path + "/%"}}
+			} else {
+				f.missing = true
+			}
+		case PinTag:
+			f = pinFilter{cond: "JSON_CONTAINS(tags_json, JSON_QUOTE(?))", args: []any{pin.Ref}}
+		case PinSearch:
+			cond, args := s.textCondition(pin.Ref)
+			f = pinFilter{cond: cond, args: args}
+		case PinCollection:
+			if ids := jsonStrings(pin.ArchiveIDsJSON); len(ids) > 0 {
+				f = pinFilter{cond: "id IN ?", args: []any{ids}}
+			}
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+// getDashboard resolves every pin of the current user: all counts come from
+// one aggregate over archives and all recent additions from one UNION query,
+// however many pins there are.
+func (s *Server) getDashboard(c *gin.Context) {
+	var pins []models.DashboardPin
+	if err := s.DB.Where("owner = ?", dashboardOwner(c)).Order("position asc, created_at asc").Find(&pins).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	out := make([]DashboardPinResponse, 0, len(pins))
+	for _, pin := range pins {
+		out = append(out, toDashboardPinResponse(pin))
+	}
+	if len(pins) == 0 {
+		c.JSON(http.StatusOK, gin.H{"pins": out})
+		return
+	}
+
+	db := s.reader()
+	filters, err := s.pinFilters(db, pins)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+
+	sums := make([]string, 0, len(filters))
+	var args []any
+	for i, f := range filters {
+		sums = append(sums, fmt.Sprintf("COALESCE(SUM(CASE WHEN %s THEN 1 ELSE 0 END), 0) AS c%d", f.cond, i))
+		args = append(args, f.args...)
+	}
+	counts := make([]int64, len(filters))
+	dest := make([]any, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	if err := db.Raw("SELECT "+strings.Join(sums, ", ")+" FROM archives", args...).Row().Scan(dest...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+
+	recentLimit := parseLimit(c.Query("recent"), 5)
+	if recentLimit > 20 {
+		recentLimit = 20
+	}
+	if recentLimit > 0 {
+		parts := make([]string, 0, len(filters))
+		args = args[:0]
+		for i, f := range filters {
+			parts = append(parts, fmt.Sprintf("(SELECT %d AS pin, id, title, url, site_name, favicon, created_at FROM archives WHERE %s ORDER BY created_at DESC LIMIT %d)", i, f.cond, recentLimit))
+			args = append(args, f.args...)
+		}
+		var rows []struct {
+			Pin int
+			DashboardRecent
+		}
+		if err := db.Raw(strings.Join(parts, " UNION ALL "), args...).Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		for _, row := range rows {
+			if row.Pin >= 0 && row.Pin < len(out) {
+				out[row.Pin].Recent = append(out[row.Pin].Recent, row.DashboardRecent)
+			}
+		}
+	}
+	for i := range out {
+		out[i].Count = counts[i]
+		out[i].Missing = filters[i].missing
+	}
+	c.JSON(http.StatusOK, gin.H{"pins": out})
+}
+
+func (s *Server) createDashboardPin(c *gin.Context) {
+	var req DashboardPinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	owner := dashboardOwner(c)
+	var count int64
+	if err := s.DB.Model(&models.DashboardPin{}).Where("owner = ?", owner).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if count >= dashboardMaxPins {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d pins", dashboardMaxPins)})
+		return
+	}
+	pin := models.DashboardPin{ID: uuid.New().String(), Owner: owner, Kind: req.Kind, Position: int(count)}
+	if err := s.applyPinRequest(&pin, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.DB.Create(&pin).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	c.JSON(http.StatusOK, toDashboardPinResponse(pin))
+}
+
+// updateDashboardPin changes a pin's label, target or position; its kind is
+// fixed once created.
+func (s *Server) updateDashboardPin(c *gin.Context) {
+	var req DashboardPinRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	var pin models.DashboardPin
+	if err := s.DB.First(&pin, "id = ? AND owner = ?", c.Param("id"), dashboardOwner(c)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if req.Kind != "" && req.Kind != pin.Kind {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind cannot be changed"})
+		return
+	}
+	if req.Ref == "" {
+		req.Ref = pin.Ref
+	}
+	if req.ArchiveIDs == nil {
+		req.ArchiveIDs = jsonStrings(pin.ArchiveIDsJSON)
+	}
+	if strings.TrimSpace(req.Label) == "" {
+		req.Label = pin.Label
+	}
+	if err := s.applyPinRequest(&pin, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.DB.Save(&pin).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, toDashboardPinResponse(pin))
+}
+
+func (s *Server) deleteDashboardPin(c *gin.Context) {
+	res := s.DB.Delete(&models.DashboardPin{}, "id = ? AND owner = ?", c.Param("id"), dashboardOwner(c))
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// applyPinRequest validates the target for the pin's kind and fills in a
+// label when none is given.
+func (s *Server) applyPinRequest(pin *models.DashboardPin, req DashboardPinRequest) error {
+	ref := strings.TrimSpace(req.Ref)
+	label := strings.TrimSpace(req.Label)
+	ids := []string{}
+	switch pin.Kind {
+	case PinNode:
+		var node models.TaxonomyNode
+		if err := s.DB.First(&node, "id = ?", ref).Error; err != nil {
+			return errors.New("node not found")
+		}
+		if label == "" {
+			label = node.Path
+		}
+	case PinTag, PinSearch:
+		if ref == "" {
+			return errors.New("ref required")
+		}
+		if label == "" {
+			label = ref
+		}
+	case PinCollection:
+		ids = patchTags(req.ArchiveIDs, nil, nil)
+		if len(ids) == 0 {
+			return errors.New("archiveIds required")
+		}
+		if len(ids) > dashboardMaxCollection {
+			return fmt.Errorf("at most %d archives per collection", dashboardMaxCollection)
+		}
+		var found int64
+		if err := s.DB.Model(&models.Archive{}).Where("id IN ?", ids).Count(&found).Error; err != nil {
+			return err
+		}
+		if int(found) != len(ids) {
+			return errors.New("unknown archive id")
+		}
+		if label == "" {
+			return errors.New("label required")
+		}
+		ref = ""
+	default:
+		return errors.New("kind must be node, tag, search or collection")
+	}
+	pin.Ref = truncate(ref, 512)
+	pin.Label = truncate(label, 255)
+	pin.ArchiveIDsJSON, _ = json.Marshal(ids)
+	if req.Position != nil {
+		pin.Position = *req.Position
+	}
+	return nil
+}
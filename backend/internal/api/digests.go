@@ -0,0 +1,425 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"webarchive/internal/models"
+	"webarchive/internal/notify"
+)
+
+const (
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+
+	digestMaxItems     = 80
+	digestMaxReminders = 20
+)
+
+type DigestOptions struct {
+	// Schedule is "daily", "weekly" or empty to only generate on demand.
+	Schedule   string
+	WebhookURL string
+	EmailTo    []string
+	SMTP       notify.SMTPConfig
+}
+
+type DigestTheme struct {
+	Name       string   `json:"name"`
+	ArchiveIDs []string `json:"archiveIds"`
+}
+
+type DigestNotable struct {
+	ArchiveID string `json:"archiveId"`
+	Title     string `json:"title"`
+	Reason    string `json:"reason"`
+}
+
+type DigestResponse struct {
+	ID           string          `json:"id"`
+	Period       string          `json:"period"`
+	PeriodStart  time.Time       `json:"periodStart"`
+	PeriodEnd    time.Time       `json:"periodEnd"`
+	ArchiveCount int             `json:"archiveCount"`
+	Summary      string          `json:"summary"`
+	Themes       []DigestTheme   `json:"themes"`
+	Notable      []DigestNotable `json:"notable"`
+	FollowUps    []string        `json:"followUps"`
+	Reminders    []DigestNotable `json:"reminders"`
+	Generator    string          `json:"generator"`
+	CreatedAt    time.Time       `json:"createdAt"`
+}
+
+type DigestRequest struct {
+	Period string     `json:"period"`
+	Start  *time.Time `json:"start"`
+}
+
+func toDigestResponse(d models.Digest) DigestResponse {
+	resp := DigestResponse{
+		ID:           d.ID,
+		Period:       d.Period,
+		PeriodStart:  d.PeriodStart,
+		PeriodEnd:    d.PeriodEnd,
+		ArchiveCount: d.ArchiveCount,
+		Summary:      d.Summary,
+		Themes:       []DigestTheme{},
+		Notable:      []DigestNotable{},
+		FollowUps:    []string{},
+		Reminders:    []DigestNotable{},
+		Generator:    d.Generator,
+		CreatedAt:    d.CreatedAt,
+	}
+	_ = json.Unmarshal(d.ThemesJSON, &resp.Themes)
+	_ = json.Unmarshal(d.NotableJSON, &resp.Notable)
+	_ = json.Unmarshal(d.FollowUpsJSON, &resp.FollowUps)
+	_ = json.Unmarshal(d.RemindersJSON, &resp.Reminders)
+	return resp
+}
+
+// digestWindow returns the period containing t: a calendar day, or an ISO
+// week starting on Monday, in local time.
+func digestWindow(period string, t time.Time) (time.Time, time.Time) {
+	start := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if period == DigestWeekly {
+		offset := (int(start.Weekday()) + 6) % 7
+		start = start.AddDate(0, 0, -offset)
+		return start, start.AddDate(0, 0, 7)
+	}
+	return start, start.AddDate(0, 0, 1)
+}
+
+func (s *Server) listDigests(c *gin.Context) {
+	query := s.DB.Order("period_start desc").Limit(parseLimit(c.Query("limit"), 30))
+	if period := c.Query("period"); period != "" {
+		query = query.Where("period = ?", period)
+	}
+	var rows []models.Digest
+	if err := query.Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	out := make([]DigestResponse, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, toDigestResponse(row))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) getDigest(c *gin.Context) {
+	var row models.Digest
+	if err := s.DB.First(&row, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, toDigestResponse(row))
+}
+
+// createDigest (re)generates the digest for the period containing start,
+// defaulting to the current period.
+func (s *Server) createDigest(c *gin.Context) {
+	var req DigestRequest
+	_ = c.ShouldBindJSON(&req)
+	if req.Period == "" {
+		req.Period = DigestWeekly
+	}
+	if req.Period != DigestDaily && req.Period != DigestWeekly {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be daily or weekly"})
+		return
+	}
+	at := time.Now()
+	if req.Start != nil {
+		at = *req.Start
+	}
+	start, end := digestWindow(req.Period, at.In(time.Local))
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Minute)
+	defer cancel()
+	digest, err := s.generateDigest(ctx, req.Period, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, toDigestResponse(digest))
+}
+
+func (s *Server) generateDigest(ctx context.Context, period string, start, end time.Time) (models.Digest, error) {
+	var items []models.Archive
+	if err := s.DB.Select("id", "title", "url", "site_name", "excerpt", "summary", "tags_json", "category").
+		Where("created_at >= ? AND created_at < ?", start, end).
+		Order("created_at desc").
+		Limit(digestMaxItems).
+		Find(&items).Error; err != nil {
+		return models.Digest{}, err
+	}
+	var count int64
+	if err := s.DB.Model(&models.Archive{}).Where("created_at >= ? AND created_at < ?", start, end).Count(&count).Error; err != nil {
+		return models.Digest{}, err
+	}
+
+	resp := DigestResponse{Themes: []DigestTheme{}, Notable: []DigestNotable{}, FollowUps: []string{}}
+	generator := "basic"
+	if len(items) > 0 && s.LLM != nil && s.LLM.Enabled() {
+		if out, err := s.llmDigest(ctx, period, items); err == nil {
+			resp = out
+			generator = "llm"
+		} else {
+			log.Printf("digest llm failed, using basic digest: %v", err)
+		}
+	}
+	if generator == "basic" {
+		resp = basicDigest(items)
+	}
+	reminders, err := s.digestReminders(end)
+	if err != nil {
+		return models.Digest{}, err
+	}
+
+	themesJSON, _ := json.Marshal(resp.Themes)
+	notableJSON, _ := json.Marshal(resp.Notable)
+	followJSON, _ := json.Marshal(resp.FollowUps)
+	remindersJSON, _ := json.Marshal(reminders)
+	digest := models.Digest{
+		ID:            uuid.New().String(),
+		Period:        period,
+		PeriodStart:   start,
+		PeriodEnd:     end,
+		ArchiveCount:  int(count),
+		Summary:       resp.Summary,
+		ThemesJSON:    themesJSON,
+		NotableJSON:   notableJSON,
+		FollowUpsJSON: followJSON,
+		RemindersJSON: remindersJSON,
+		Generator:     generator,
+	}
+	err = s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "period"}, {Name: "period_start"}},
+		DoUpdates: clause.AssignmentColumns([]string{"period_end", "archive_count", "summary", "themes_json", "notable_json", "follow_ups_json", "reminders_json", "generator", "created_at"}),
+	}).Create(&digest).Error
+	if err != nil {
+		return models.Digest{}, err
+	}
+	// On conflict the existing row keeps its ID.
+	if err := s.DB.First(&digest, "period = ? AND period_start = ?", period, start).Error; err != nil {
+		return models.Digest{}, err
+	}
+	return digest, nil
+}
+
+// digestReminders lists reminders due before end that are still set,
+// overdue ones included, so a digest doubles as a to-revisit list.
+func (s *Server) digestReminders(end time.Time) ([]DigestNotable, error) {
+	var items []models.Archive
+	if err := s.DB.Select("id", "title", "remind_at", "remind_note").
+		Where("remind_at < ?", end).
+		Order("remind_at asc").
+		Limit(digestMaxReminders).
+		Find(&items).Error; err != nil {
+		return nil, err
+	}
+	out := make([]DigestNotable, 0, len(items))
+	for _, item := range items {
+		reason := item.RemindNote
+		if reason == "" {
+			reason = "due " + item.RemindAt.In(time.Local).Format("2006-01-02")
+		}
+		out = append(out, DigestNotable{ArchiveID: item.ID, Title: item.Title, Reason: reason})
+	}
+	return out, nil
+}
+
+func (s *Server) llmDigest(ctx context.Context, period string, items []models.Archive) (DigestResponse, error) {
+	var b strings.Builder
+	for _, item := range items {
+		tags := []string{}
+		if len(item.TagsJSON) > 0 {
+			_ = json.Unmarshal(item.TagsJSON, &tags)
+		}
+		note := item.Summary
+		if note == "" {
+			note = item.Excerpt
+		}
+		fmt.Fprintf(&b, "- id=%s | %s | %s | tags: %s | %s\n", item.ID, item.Title, item.SiteName, strings.Join(tags, ", "), truncate(note, 300))
+	}
+	system := "You write reading digests for a personal web archive. Return strict JSON only."
+	user := "Summarize this " + period + " batch of saved pages. Answer in the same language as most titles.\n" +
+		"Return JSON: {\"summary\": string, \"themes\": [{\"name\": string, \"archiveIds\": [string]}], " +
+		"\"notable\": [{\"archiveId\": string, \"title\": string, \"reason\": string}], \"followUps\": [string]}.\n" +
+		"Use 2-6 themes, at most 5 notable items and 3 follow-up suggestions. Only use ids from the list.\n" +
+		"Pages:\n" + b.String()
+	raw, err := s.LLM.ChatJSON(ctx, system, user, 0.3)
+	if err != nil {
+		return DigestResponse{}, err
+	}
+	raw = extractJSON(raw)
+	if raw == "" {
+		return DigestResponse{}, errors.New("invalid json")
+	}
+	var out DigestResponse
+	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+		return DigestResponse{}, err
+	}
+	known := map[string]bool{}
+	for _, item := range items {
+		known[item.ID] = true
+	}
+	for i := range out.Themes {
+		ids := out.Themes[i].ArchiveIDs[:0]
+		for _, id := range out.Themes[i].ArchiveIDs {
+			if known[id] {
+				ids = append(ids, id)
+			}
+		}
+		out.Themes[i].ArchiveIDs = ids
+	}
+	notable := out.Notable[:0]
+	for _, n := range out.Notable {
+		if known[n.ArchiveID] {
+			notable = append(notable, n)
+		}
+	}
+	out.Notable = notable
+	if out.Themes == nil {
+		out.Themes = []DigestTheme{}
+	}
+	if out.FollowUps == nil {
+		out.FollowUps = []string{}
+	}
+	return out, nil
+}
+
+// basicDigest groups by top tags and lists the newest items; used when the
+// LLM is unavailable so scheduled digests still go out.
+func basicDigest(items []models.Archive) DigestResponse {
+	resp := DigestResponse{Themes: []DigestTheme{}, Notable: []DigestNotable{}, FollowUps: []string{}}
+	resp.Summary = fmt.Sprintf("%d archives saved.", len(items))
+	byTag := map[string][]string{}
+	order := []string{}
+	for _, item := range items {
+		tags := []string{}
+		if len(item.TagsJSON) > 0 {
+			_ = json.Unmarshal(item.TagsJSON, &tags)
+		}
+		for _, t := range tags {
+			if _, ok := byTag[t]; !ok {
+				order = append(order, t)
+			}
+			byTag[t] = append(byTag[t], item.ID)
+		}
+	}
+	for _, t := range order {
+		if len(byTag[t]) >= 2 && len(resp.Themes) < 6 {
+			resp.Themes = append(resp.Themes, DigestTheme{Name: t, ArchiveIDs: byTag[t]})
+		}
+	}
+	for i, item := range items {
+		if i >= 5 {
+			break
+		}
+		resp.Notable = append(resp.Notable, DigestNotable{ArchiveID: item.ID, Title: item.Title})
+	}
+	return resp
+}
+
+// StartDigestScheduler generates the digest for each period once it has
+// ended and delivers it via webhook/email when configured.
+func (s *Server) StartDigestScheduler(ctx context.Context, opts DigestOptions) {
+	if opts.Schedule != DigestDaily && opts.Schedule != DigestWeekly {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			s.runScheduledDigest(ctx, opts)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *Server) runScheduledDigest(ctx context.Context, opts DigestOptions) {
+	if !s.Ready() {
+		return
+	}
+	current, _ := digestWindow(opts.Schedule, time.Now())
+	prevStart, prevEnd := digestWindow(opts.Schedule, current.Add(-time.Second))
+	var existing int64
+	if err := s.DB.Model(&models.Digest{}).Where("period = ? AND period_start = ?", opts.Schedule, prevStart).Count(&existing).Error; err != nil || existing > 0 {
+		return
+	}
+	taskCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	digest, err := s.generateDigest(taskCtx, opts.Schedule, prevStart, prevEnd)
+	if err != nil {
+		log.Printf("scheduled digest failed: %v", err)
+		return
+	}
+	s.deliverDigest(taskCtx, opts, toDigestResponse(digest))
+}
+
+func (s *Server) deliverDigest(ctx context.Context, opts DigestOptions, d DigestResponse) {
+	if opts.WebhookURL != "" {
+		if err := notify.Webhook(ctx, opts.WebhookURL, gin.H{"type": "digest", "digest": d}); err != nil {
+			log.Printf("digest webhook failed: %v", err)
+		}
+	}
+	if len(opts.EmailTo) > 0 && opts.SMTP.Enabled() {
+		subject := fmt.Sprintf("WebArchive %s digest %s", d.Period, d.PeriodStart.Format("2006-01-02"))
+		if err := notify.Email(opts.SMTP, opts.EmailTo, subject, digestText(d)); err != nil {
+			log.Printf("digest email failed: %v", err)
+		}
+	}
+}
+
+func digestText(d DigestResponse) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s - %s (%d archives)\n\n%s\n", d.PeriodStart.Format("2006-01-02"), d.PeriodEnd.Add(-time.Second).Format("2006-01-02"), d.ArchiveCount, d.Summary)
+	if len(d.Themes) > 0 {
+		b.WriteString("\nThemes:\n")
+		for _, t := range d.Themes {
+			fmt.Fprintf(&b, "- %s (%d)\n", t.Name, len(t.ArchiveIDs))
+		}
+	}
+	if len(d.Notable) > 0 {
+		b.WriteString("\nNotable:\n")
+		for _, n := range d.Notable {
+			line := "- " + n.Title
+			if n.Reason != "" {
+				line += ": " + n.Reason
+			}
+			b.WriteString(line + "\n")
+		}
+	}
+	if len(d.Reminders) > 0 {
+		b.WriteString("\nReminders:\n")
+		for _, r := range d.Reminders {
+			b.WriteString("- " + r.Title + ": " + r.Reason + "\n")
+		}
+	}
+	if len(d.FollowUps) > 0 {
+		b.WriteString("\nFollow-ups:\n")
+		for _, f := range d.FollowUps {
+			b.WriteString("- " + f + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+type DomainStat struct {
+	Domain string `json:"domain"`
+	Count  int64  `json:"count"`
+}
+
+// domainStats counts archives per canonical domain.
+func (s *Server) domainStats(c *gin.Context) {
+	var out []DomainStat
+	if err := s.DB.Model(&models.Archive{}).
+		Select("domain, COUNT(*) AS count").
+		Where("domain <> ''").
+		Group("domain").
+		Order("count desc").
+		Limit(parseLimit(c.Query("limit"), 100)).
+		Scan(&out).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if out == nil {
+		out = []DomainStat{}
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// BackfillCanonicalURLs derives canonical URLs and domains for archives
+// captured before normalization existed. The stored HTML is not re-parsed,
+// so only the capture URL is normalized.
+func (s *Server) BackfillCanonicalURLs() {
+	lastID := ""
+	updated := 0
+	for {
+		var items []models.Archive
+		if err := s.DB.Select("id", "url").Where("id > ? AND canonical_url = ''", lastID).
+			Order("id asc").Limit(500).Find(&items).Error; err != nil {
+			log.Printf("canonical url backfill failed: %v", err)
+			return
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			lastID = item.ID
+			canonical := truncate(processor.NormalizeURL(item.URL), 2000)
+			if canonical == "" {
+				continue
+			}
+			if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).UpdateColumns(map[string]any{
+				"canonical_url": canonical,
+				"domain":        truncate(processor.URLDomain(canonical), 255),
+			}).Error; err != nil {
+				log.Printf("canonical url backfill failed: %v", err)
+				return
+			}
+			updated++
+		}
+	}
+	if updated > 0 {
+		log.Printf("canonical urls backfilled for %d archives", updated)
+	}
+}
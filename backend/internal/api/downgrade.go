@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+// Delete scopes accepted by DELETE /archives/:id?scope=.
+const (
+	DeleteScopeAll      = "all"
+	DeleteScopeSnapshot = "snapshot"
+	DeleteScopeAI       = "ai"
+)
+
+// clearArchiveAI removes what the LLM derived for an archive (the fields
+// covered by the AI export, taxonomy paths, embeddings, flashcards and
+// pending proposals) so it can be re-analysed from scratch.
+func (s *Server) clearArchiveAI(ctx context.Context, item models.Archive, actor string) error {
+	before := s.snapshotArchive(item)
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Archive{}).Where("id = ?", item.ID).Updates(map[string]any{
+			"category":          "",
+			"tags_json":         nil,
+			"hierarchy_json":    nil,
+			"hierarchy_path":    "",
+			"entities_json":     nil,
+			"entity_types_json": nil,
+			"relations_json":    nil,
+			"summary":           "",
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("archive_id = ?", item.ID).Delete(&models.ArchivePath{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	var updated models.Archive
+	if err := s.DB.First(&updated, "id = ?", item.ID).Error; err == nil {
+		s.recordArchiveEvent(item.ID, EventDowngrade, actor, before, s.snapshotArchive(updated))
+	}
+	s.publishTaxonomyChanged("downgrade")
+
+	if s.LLM != nil && s.LLM.EmbeddingModel != "" {
+		_ = s.vectors().Delete(ctx, s.LLM.EmbeddingModel, []string{item.ID})
+	}
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.ArchiveEmbedding{}).Error
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.Flashcard{}).Error
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.AnalysisProposal{}).Error
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.AITrace{}).Error
+	return nil
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+const maxFilenameRunes = 100
+
+// safeFilename turns a page title into a file name every OS accepts:
+// separators, reserved and control characters go, whitespace collapses, and
+// the result is capped. An empty result falls back to fallback.
+func safeFilename(name, fallback string) string {
+	cleaned := strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r), strings.ContainsRune(`<>:"/\|?*`, r):
+			return ' '
+		}
+		return r
+	}, name)
+	cleaned = strings.Join(strings.Fields(cleaned), " ")
+	cleaned = strings.Trim(truncate(cleaned, maxFilenameRunes), " .")
+	if cleaned == "" {
+		return fallback
+	}
+	return cleaned
+}
+
+// wantsDownload sets Content-Disposition when the request asks for
+// ?download=1, so browsers save the response under filename.
+func wantsDownload(c *gin.Context, filename func() string) {
+	if c.Query("download") != "1" && c.Query("download") != "true" {
+		return
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename()}))
+}
+
+func (s *Server) archiveHTMLFilename(id string) string {
+	var item models.Archive
+	if err := s.DB.Select("id", "title").First(&item, "id = ?", id).Error; err != nil {
+		return safeFilename(id, "archive") + ".html"
+	}
+	return safeFilename(item.Title, item.ID) + ".html"
+}
+
+// assetFilename names an asset after the file in its original URL, falling
+// back to the stored name.
+func (s *Server) assetFilename(id, stored string) string {
+	var item models.Archive
+	if err := s.DB.Select("id", "assets_json").First(&item, "id = ?", id).Error; err == nil && len(item.AssetsJSON) > 0 {
+		var assets []processor.Asset
+		_ = json.Unmarshal(item.AssetsJSON, &assets)
+		for _, a := range assets {
+			if a.Stored != stored {
+				continue
+			}
+			if u, err := url.Parse(a.Original); err == nil {
+				if base := path.Base(u.Path); base != "/" && base != "." && path.Ext(base) != "" {
+					return safeFilename(base, path.Base(stored))
+				}
+			}
+			break
+		}
+	}
+	return safeFilename(path.Base(stored), "asset")
+}
@@ -0,0 +1,349 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+
+	"webarchive/internal/models"
+	"webarchive/internal/vectorstore"
+)
+
+const (
+	embeddingBatchSize = 16
+	embeddingPageSize  = 200
+	embeddingMaxChars  = 8000
+)
+
+type EmbeddingStatus struct {
+	Running    bool       `json:"running"`
+	Model      string     `json:"model"`
+	Total      int64      `json:"total"`
+	Scanned    int        `json:"scanned"`
+	Embedded   int        `json:"embedded"`
+	Skipped    int        `json:"skipped"`
+	Failed     int        `json:"failed"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+type EmbeddingBackfillRequest struct {
+	IDs   []string `json:"ids"`
+	Force bool     `json:"force"`
+}
+
+type SemanticSearchHit struct {
+	Archive ArchiveResponse `json:"archive"`
+	Score   float64         `json:"score"`
+}
+
+func embeddingText(item models.Archive) string {
+	text := strings.TrimSpace(item.Title + "\n" + item.Excerpt + "\n" + item.ContentText)
+	return truncate(text, embeddingMaxChars)
+}
+
+func contentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *Server) vectors() vectorstore.Store {
+	if s.Vectors != nil {
+		return s.Vectors
+	}
+	return vectorstore.NewDBStore(s.DB)
+}
+
+// embedArchives embeds the archives whose text changed since their cached
+// embedding for the current model (or all of them with force) and returns
+// how many were embedded and skipped.
+func (s *Server) embedArchives(ctx context.Context, items []models.Archive, force bool) (int, int, error) {
+	model := s.LLM.EmbeddingModel
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	cached := map[string]string{}
+	if !force && len(ids) > 0 {
+		var rows []models.ArchiveEmbedding
+		if err := s.DB.Select("archive_id", "content_hash").Where("model = ? AND archive_id IN ?", model, ids).Find(&rows).Error; err != nil {
+			return 0, 0, err
+		}
+		for _, row := range rows {
+			cached[row.ArchiveID] = row.ContentHash
+		}
+	}
+
+	type pending struct {
+		id, text, hash string
+	}
+	var stale []pending
+	skipped := 0
+	for _, item := range items {
+		text := embeddingText(item)
+		hash := contentHash(text)
+		if text == "" || cached[item.ID] == hash {
+			skipped++
+			continue
+		}
+		stale = append(stale, pending{id: item.ID, text: text, hash: hash})
+	}
+
+	embedded := 0
+	for start := 0; start < len(stale); start += embeddingBatchSize {
+		end := start + embeddingBatchSize
+		if end > len(stale) {
+			end = len(stale)
+		}
+		batch := stale[start:end]
+		inputs := make([]string, 0, len(batch))
+		for _, p := range batch {
+			inputs = append(inputs, p.text)
+		}
+		vectors, err := s.LLM.Embed(ctx, inputs)
+		if err != nil {
+			return embedded, skipped, err
+		}
+		rows := make([]models.ArchiveEmbedding, 0, len(batch))
+		points := make([]vectorstore.Point, 0, len(batch))
+		for i, p := range batch {
+			rows = append(rows, models.ArchiveEmbedding{
+				ArchiveID:   p.id,
+				Model:       model,
+				ContentHash: p.hash,
+				Dims:        len(vectors[i]),
+				Vector:      vectorstore.EncodeVector(vectors[i]),
+			})
+			points = append(points, vectorstore.Point{ArchiveID: p.id, Vector: vectors[i]})
+		}
+		// Index first: a cache row claims the archive is indexed, so it must
+		// not be written if the external store rejected the vectors.
+		if err := s.vectors().Upsert(ctx, model, points); err != nil {
+			return embedded, skipped, err
+		}
+		if err := s.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&rows).Error; err != nil {
+			return embedded, skipped, err
+		}
+		embedded += len(rows)
+	}
+	return embedded, skipped, nil
+}
+
+func (s *Server) embeddingStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.getEmbeddingStatus())
+}
+
+func (s *Server) startEmbeddingBackfill(c *gin.Context) {
+	if s.LLM == nil || !s.LLM.EmbeddingsEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "embedding model not configured"})
+		return
+	}
+	var req EmbeddingBackfillRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var total int64
+	query := s.DB.Model(&models.Archive{})
+	if len(req.IDs) > 0 {
+		query = query.Where("id IN ?", req.IDs)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+
+	s.embedMu.Lock()
+	if s.embedStatus.Running {
+		status := s.embedStatus
+		s.embedMu.Unlock()
+		c.JSON(http.StatusOK, status)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	s.embedCancel = cancel
+	s.embedStatus = EmbeddingStatus{Running: true, Model: s.LLM.EmbeddingModel, Total: total, StartedAt: &now}
+	s.embedMu.Unlock()
+
+	go s.runEmbeddingBackfill(ctx, req)
+	c.JSON(http.StatusOK, s.getEmbeddingStatus())
+}
+
+func (s *Server) stopEmbeddingBackfill(c *gin.Context) {
+	s.embedMu.Lock()
+	if s.embedCancel != nil {
+		s.embedCancel()
+		s.embedCancel = nil
+	}
+	status := s.embedStatus
+	s.embedMu.Unlock()
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) getEmbeddingStatus() EmbeddingStatus {
+	s.embedMu.Lock()
+	defer s.embedMu.Unlock()
+	return s.embedStatus
+}
+
+func (s *Server) withEmbeddingStatus(update func(*EmbeddingStatus)) {
+	s.embedMu.Lock()
+	defer s.embedMu.Unlock()
+	update(&s.embedStatus)
+}
+
+func (s *Server) runEmbeddingBackfill(ctx context.Context, req EmbeddingBackfillRequest) {
+	lastErr := ""
+	defer func() {
+		now := time.Now()
+		s.withEmbeddingStatus(func(st *EmbeddingStatus) {
+			st.Running = false
+			st.FinishedAt = &now
+			if lastErr != "" {
+				st.LastError = lastErr
+			}
+		})
+		s.embedMu.Lock()
+		s.embedCancel = nil
+		s.embedMu.Unlock()
+	}()
+
+	for offset := 0; ; offset += embeddingPageSize {
+		if ctx.Err() != nil {
+			lastErr = "canceled"
+			return
+		}
+		var items []models.Archive
+		query := s.DB.Select("id", "title", "excerpt", "content_text").Order("created_at asc").Offset(offset).Limit(embeddingPageSize)
+		if len(req.IDs) > 0 {
+			query = query.Where("id IN ?", req.IDs)
+		}
+		if err := query.Find(&items).Error; err != nil {
+			lastErr = err.Error()
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		embedded, skipped, err := s.embedArchives(ctx, items, req.Force)
+		s.withEmbeddingStatus(func(st *EmbeddingStatus) {
+			st.Scanned += len(items)
+			st.Embedded += embedded
+			st.Skipped += skipped
+			if err != nil {
+				st.Failed += len(items) - embedded - skipped
+				st.LastError = err.Error()
+			}
+		})
+		if err != nil && errors.Is(ctx.Err(), context.Canceled) {
+			lastErr = "canceled"
+			return
+		}
+	}
+}
+
+// semanticSearch ranks archives by similarity to the query through the
+// configured vector store. Archives that were never embedded are not
+// searched (see coverage) until the backfill job has run.
+func (s *Server) semanticSearch(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q required"})
+		return
+	}
+	if s.LLM == nil || !s.LLM.EmbeddingsEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "embedding model not configured"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+	vectors, err := s.LLM.Embed(ctx, []string{q})
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	matches, err := s.vectors().Search(ctx, s.LLM.EmbeddingModel, vectors[0], parseLimit(c.Query("limit"), 10))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "vector search failed"})
+		return
+	}
+	hits, err := s.loadSearchHits(matches, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+
+	var embedded, total int64
+	_ = s.reader().Model(&models.ArchiveEmbedding{}).Where("model = ?", s.LLM.EmbeddingModel).Count(&embedded).Error
+	_ = s.reader().Model(&models.Archive{}).Count(&total).Error
+	c.JSON(http.StatusOK, gin.H{
+		"items":    hits,
+		"model":    s.LLM.EmbeddingModel,
+		"store":    s.vectors().Name(),
+		"coverage": gin.H{"embedded": embedded, "total": total},
+	})
+}
+
+// relatedArchives ranks other archives by similarity to this archive's
+// cached embedding.
+func (s *Server) relatedArchives(c *gin.Context) {
+	if s.LLM == nil || !s.LLM.EmbeddingsEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "embedding model not configured"})
+		return
+	}
+	id := c.Param("id")
+	var row models.ArchiveEmbedding
+	if err := s.DB.First(&row, "archive_id = ? AND model = ?", id, s.LLM.EmbeddingModel).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "archive has no embedding yet"})
+		return
+	}
+	limit := parseLimit(c.Query("limit"), 10)
+	matches, err := s.vectors().Search(c.Request.Context(), row.Model, vectorstore.DecodeVector(row.Vector), limit+1)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "vector search failed"})
+		return
+	}
+	hits, err := s.loadSearchHits(matches, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if len(hits) > limit {
+		hits = hits[:limit]
+	}
+	c.JSON(http.StatusOK, hits)
+}
+
+func (s *Server) loadSearchHits(matches []vectorstore.Match, exclude string) ([]SemanticSearchHit, error) {
+	ids := make([]string, 0, len(matches))
+	for _, m := range matches {
+		ids = append(ids, m.ArchiveID)
+	}
+	var items []models.Archive
+	if len(ids) > 0 {
+		if err := s.reader().Omit("content_text").Where("id IN ?", ids).Find(&items).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := map[string]models.Archive{}
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	hits := make([]SemanticSearchHit, 0, len(matches))
+	for _, m := range matches {
+		item, ok := byID[m.ArchiveID]
+		if !ok || m.ArchiveID == exclude {
+			continue
+		}
+		hits = append(hits, SemanticSearchHit{Archive: toArchiveResponse(item, nil), Score: m.Score})
+	}
+	return hits, nil
+}
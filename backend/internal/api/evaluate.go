@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/ai"
+	"webarchive/internal/models"
+)
+
+const (
+	evalDefaultSample = 5
+	evalMaxSample     = 20
+)
+
+// EvalModel picks a configured provider, primary or fallback, and optionally
+// another model served by it.
+type EvalModel struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+}
+
+type EvaluateRequest struct {
+	A      EvalModel `json:"a"`
+	B      EvalModel `json:"b"`
+	Sample int       `json:"sample"`
+	IDs    []string  `json:"ids"`
+}
+
+type EvalOutput struct {
+	Classification
+	Millis int64  `json:"millis"`
+	Error  string `json:"error,omitempty"`
+}
+
+type EvalItem struct {
+	ArchiveID    string     `json:"archiveId"`
+	Title        string     `json:"title"`
+	URL          string     `json:"url"`
+	A            EvalOutput `json:"a"`
+	B            EvalOutput `json:"b"`
+	TagAgreement float64    `json:"tagAgreement"`
+	SamePath     bool       `json:"samePath"`
+}
+
+// EvalMetrics averages agreement over the archives both models classified.
+// Tag and entity agreement are Jaccard overlaps; path prefix agreement is the
+// shared leading depth over the deeper of the two paths.
+type EvalMetrics struct {
+	Compared            int     `json:"compared"`
+	ErrorsA             int     `json:"errorsA"`
+	ErrorsB             int     `json:"errorsB"`
+	CategoryAgreement   float64 `json:"categoryAgreement"`
+	PathAgreement       float64 `json:"pathAgreement"`
+	PathPrefixAgreement float64 `json:"pathPrefixAgreement"`
+	TagAgreement        float64 `json:"tagAgreement"`
+	EntityAgreement     float64 `json:"entityAgreement"`
+	AvgMillisA          int64   `json:"avgMillisA"`
+	AvgMillisB          int64   `json:"avgMillisB"`
+}
+
+type EvaluateResponse struct {
+	A       EvalModel   `json:"a"`
+	B       EvalModel   `json:"b"`
+	Metrics EvalMetrics `json:"metrics"`
+	Items   []EvalItem  `json:"items"`
+}
+
+// evaluateModels classifies a sample of archives with two models side by
+// side. Nothing is written; it only shows how far a cheaper model strays
+// from the current one.
+func (s *Server) evaluateModels(c *gin.Context) {
+	if s.LLM == nil || !s.LLM.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "llm not configured"})
+		return
+	}
+	var req EvaluateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if req.B.Provider == "" && req.B.Model == "" {
+		req.B.Provider = "fallback"
+	}
+	clientA, err := s.evalClient(&req.A)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a: " + err.Error()})
+		return
+	}
+	clientB, err := s.evalClient(&req.B)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "b: " + err.Error()})
+		return
+	}
+	if clientA.BaseURL == clientB.BaseURL && clientA.Model == clientB.Model {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "a and b are the same model"})
+		return
+	}
+
+	sample := req.Sample
+	if sample <= 0 {
+		sample = evalDefaultSample
+	}
+	if sample > evalMaxSample {
+		sample = evalMaxSample
+	}
+	var items []models.Archive
+	query := s.DB.Where("content_text <> ''")
+	if ids := patchTags(req.IDs, nil, nil); len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	} else {
+		query = query.Order("RAND()")
+	}
+	if err := query.Limit(sample).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no archives to evaluate"})
+		return
+	}
+
+	resp := EvaluateResponse{A: req.A, B: req.B, Items: make([]EvalItem, 0, len(items))}
+	for _, item := range items {
+		if c.Request.Context().Err() != nil {
+			return
+		}
+		out := EvalItem{ArchiveID: item.ID, Title: item.Title, URL: item.URL}
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() { defer wg.Done(); out.A = s.evalClassify(c.Request.Context(), clientA, item) }()
+		go func() { defer wg.Done(); out.B = s.evalClassify(c.Request.Context(), clientB, item) }()
+		wg.Wait()
+		if out.A.Error == "" && out.B.Error == "" {
+			out.TagAgreement = jaccard(out.A.Tags, out.B.Tags)
+			out.SamePath = out.A.HierarchyPath == out.B.HierarchyPath
+		}
+		resp.Items = append(resp.Items, out)
+	}
+	resp.Metrics = evalMetrics(resp.Items)
+	c.JSON(http.StatusOK, resp)
+}
+
+// evalClient resolves spec to a standalone client and fills in the model
+// name it resolved to.
+func (s *Server) evalClient(spec *EvalModel) (*ai.Client, error) {
+	var base *ai.Client
+	switch spec.Provider {
+	case "", "primary":
+		spec.Provider = "primary"
+		base = s.LLM
+	case "fallback":
+		base = s.LLM.Fallback
+	default:
+		return nil, errors.New("provider must be primary or fallback")
+	}
+	if !base.Enabled() {
+		return nil, errors.New(spec.Provider + " provider not configured")
+	}
+	client := base.Standalone(strings.TrimSpace(spec.Model))
+	spec.Model = client.Model
+	return client, nil
+}
+
+func (s *Server) evalClassify(ctx context.Context, llm *ai.Client, item models.Archive) EvalOutput {
+	ctx, cancel := context.WithTimeout(ctx, 90*time.Second)
+	defer cancel()
+	start := time.Now()
+	proposed, _, err := s.proposeClassification(ctx, llm, item)
+	out := EvalOutput{Millis: time.Since(start).Milliseconds()}
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	out.Classification = classificationOf(proposed)
+	return out
+}
+
+func evalMetrics(items []EvalItem) EvalMetrics {
+	var m EvalMetrics
+	var sumA, sumB int64
+	for _, item := range items {
+		sumA += item.A.Millis
+		sumB += item.B.Millis
+		if item.A.Error != "" {
+			m.ErrorsA++
+		}
+		if item.B.Error != "" {
+			m.ErrorsB++
+		}
+		if item.A.Error != "" || item.B.Error != "" {
+			continue
+		}
+		m.Compared++
+		if strings.EqualFold(item.A.Category, item.B.Category) {
+			m.CategoryAgreement++
+		}
+		if item.SamePath {
+			m.PathAgreement++
+		}
+		m.PathPrefixAgreement += prefixAgreement(splitPath(item.A.HierarchyPath), splitPath(item.B.HierarchyPath))
+		m.TagAgreement += item.TagAgreement
+		m.EntityAgreement += jaccard(item.A.Entities, item.B.Entities)
+	}
+	if n := int64(len(items)); n > 0 {
+		m.AvgMillisA = sumA / n
+		m.AvgMillisB = sumB / n
+	}
+	if m.Compared > 0 {
+		n := float64(m.Compared)
+		m.CategoryAgreement /= n
+		m.PathAgreement /= n
+		m.PathPrefixAgreement /= n
+		m.TagAgreement /= n
+		m.EntityAgreement /= n
+	}
+	return m
+}
+
+// jaccard compares two lists case-insensitively; two empty lists agree.
+func jaccard(a, b []string) float64 {
+	set := map[string]int{}
+	for _, v := range a {
+		set[strings.ToLower(strings.TrimSpace(v))] |= 1
+	}
+	for _, v := range b {
+		set[strings.ToLower(strings.TrimSpace(v))] |= 2
+	}
+	delete(set, "")
+	if len(set) == 0 {
+		return 1
+	}
+	both := 0
+	for _, bits := range set {
+		if bits == 3 {
+			both++
+		}
+	}
+	return float64(both) / float64(len(set))
+}
+
+func prefixAgreement(a, b []string) float64 {
+	longest := len(a)
+	if len(b) > longest {
+		longest = len(b)
+	}
+	if longest == 0 {
+		return 1
+	}
+	shared := 0
+	for shared < len(a) && shared < len(b) && a[shared] == b[shared] {
+		shared++
+	}
+	return float64(shared) / float64(longest)
+}
+
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
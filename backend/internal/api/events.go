@@ -0,0 +1,120 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+// Live event types pushed over /api/ws. Archive events carry the archive ID
+// and history action; clients should also refresh the taxonomy on them, since
+// captures and edits can add paths. taxonomy.changed covers node operations
+// that touch no single archive.
+const (
+	LiveArchiveCreated   = "archive.created"
+	LiveArchiveUpdated   = "archive.updated"
+	LiveArchiveDeleted   = "archive.deleted"
+	LiveAnalysisProgress = "analysis.progress"
+	LiveTaxonomyChanged  = "taxonomy.changed"
+)
+
+const (
+	liveBuffer    = 64
+	liveHeartbeat = 30 * time.Second
+)
+
+type liveEvent struct {
+	Type string    `json:"type"`
+	Data any       `json:"data,omitempty"`
+	At   time.Time `json:"at"`
+}
+
+// eventHub fans events out to connected sockets. A subscriber that falls
+// behind by more than liveBuffer events misses the overflow rather than
+// stalling the publisher.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan liveEvent]struct{}
+}
+
+func (h *eventHub) subscribe() chan liveEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs == nil {
+		h.subs = map[chan liveEvent]struct{}{}
+	}
+	ch := make(chan liveEvent, liveBuffer)
+	h.subs[ch] = struct{}{}
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan liveEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs, ch)
+}
+
+func (h *eventHub) publish(ev liveEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (s *Server) publishEvent(typ string, data any) {
+	s.invalidateForEvent(typ)
+	s.syncSearchIndex(typ, data)
+	s.events.publish(liveEvent{Type: typ, Data: data, At: time.Now()})
+}
+
+func (s *Server) publishTaxonomyChanged(action string) {
+	s.publishEvent(LiveTaxonomyChanged, map[string]string{"action": action})
+}
+
+// liveEvents upgrades to a WebSocket and streams events until the client
+// goes away. Authentication happens in the route middleware; browsers send
+// the login cookie, other clients can use ?access_token=.
+func (s *Server) liveEvents(c *gin.Context) {
+	websocket.Server{Handler: s.serveLiveEvents}.ServeHTTP(c.Writer, c.Request)
+}
+
+func (s *Server) serveLiveEvents(ws *websocket.Conn) {
+	defer ws.Close()
+	ch := s.events.subscribe()
+	defer s.events.unsubscribe(ch)
+
+	// Incoming messages are ignored; reading only tells us when the client
+	// closes the connection.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var discard string
+		for websocket.Message.Receive(ws, &discard) == nil {
+		}
+	}()
+
+	ticker := time.NewTicker(liveHeartbeat)
+	defer ticker.Stop()
+	if websocket.JSON.Send(ws, liveEvent{Type: "hello", At: time.Now()}) != nil {
+		return
+	}
+	for {
+		var ev liveEvent
+		select {
+		case <-closed:
+			return
+		case ev = <-ch:
+		case <-ticker.C:
+			ev = liveEvent{Type: "ping", At: time.Now()}
+		}
+		if err := websocket.JSON.Send(ws, ev); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+const maxFeedItems = 200
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID         string         `xml:"id"`
+	Title      string         `xml:"title"`
+	Updated    string         `xml:"updated"`
+	Published  string         `xml:"published,omitempty"`
+	Links      []atomLink     `xml:"link"`
+	Author     *atomPerson    `xml:"author,omitempty"`
+	Categories []atomCategory `xml:"category"`
+	Summary    string         `xml:"summary,omitempty"`
+	Content    *atomContent   `xml:"content,omitempty"`
+}
+
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	FeedURL     string         `json:"feed_url"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url"`
+	ExternalURL   string           `json:"external_url,omitempty"`
+	Title         string           `json:"title"`
+	Summary       string           `json:"summary,omitempty"`
+	ContentText   string           `json:"content_text"`
+	DatePublished time.Time        `json:"date_published"`
+	DateModified  time.Time        `json:"date_modified"`
+	Tags          []string         `json:"tags,omitempty"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+// tagFeed serves /feeds/tag/:tag as Atom, or as JSON Feed when the tag ends
+// in ".json" or format=json is given.
+func (s *Server) tagFeed(c *gin.Context) {
+	tag, asJSON := feedFormat(c, c.Param("tag"))
+	if tag == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag is required"})
+		return
+	}
+	name, _ := json.Marshal(tag)
+	s.serveFeed(c, "标签："+tag, asJSON, func(db *gorm.DB) *gorm.DB {
+		return db.Where("JSON_CONTAINS(tags_json, ?)", string(name)).Order("created_at desc")
+	})
+}
+
+// pathFeed serves /feeds/path/*path, covering the node and its descendants,
+// newest filing first: an older archive moved under the node shows up as
+// new there.
+func (s *Server) pathFeed(c *gin.Context) {
+	path, asJSON := feedFormat(c, strings.Trim(c.Param("path"), "/"))
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path is required"})
+		return
+	}
+	s.serveFeed(c, "分类："+path, asJSON, func(db *gorm.DB) *gorm.DB {
+		filed := s.reader().Model(&models.ArchivePath{}).Select("archive_id, MIN(created_at) AS filed_at").
+			Where("path = ? OR path LIKE ?", path, path+"/%").Group("archive_id")
+		return db.Joins("JOIN (?) AS filed ON filed.archive_id = archives.id", filed).Order("filed.filed_at desc")
+	})
+}
+
+func feedFormat(c *gin.Context, name string) (string, bool) {
+	asJSON := c.Query("format") == "json"
+	switch {
+	case strings.HasSuffix(name, ".json"):
+		name, asJSON = strings.TrimSuffix(name, ".json"), true
+	case strings.HasSuffix(name, ".atom"):
+		name, asJSON = strings.TrimSuffix(name, ".atom"), false
+	}
+	return strings.TrimSpace(name), asJSON
+}
+
+// serveFeed renders the archives scope selects, in the order it sets.
+func (s *Server) serveFeed(c *gin.Context, title string, asJSON bool, scope func(*gorm.DB) *gorm.DB) {
+	limit := parseLimit(c.Query("limit"), 50)
+	if limit == 0 || limit > maxFeedItems {
+		limit = maxFeedItems
+	}
+	full := c.Query("full") == "1"
+	var items []models.Archive
+	if err := scope(s.reader().Model(&models.Archive{})).Limit(limit).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+
+	base := requestBaseURL(c)
+	self := base + c.Request.URL.RequestURI()
+	title = "WebArchive · " + title
+	if asJSON {
+		feed := jsonFeed{
+			Version:     "https://jsonfeed.org/version/1.1",
+			Title:       title,
+			HomePageURL: base + "/",
+			FeedURL:     self,
+			Items:       make([]jsonFeedItem, 0, len(items)),
+		}
+		for _, item := range items {
+			entry := jsonFeedItem{
+				ID:            item.ID,
+				URL:           base + "/archive/" + url.PathEscape(archiveRef(item)),
+				ExternalURL:   item.URL,
+				Title:         feedTitle(item),
+				Summary:       feedSummary(item),
+				ContentText:   feedSummary(item),
+				DatePublished: feedPublished(item),
+				DateModified:  item.UpdatedAt,
+				Tags:          jsonStrings(item.TagsJSON),
+			}
+			if full && item.ContentText != "" {
+				entry.ContentText = item.ContentText
+			}
+			if item.Byline != "" {
+				entry.Authors = []jsonFeedAuthor{{Name: item.Byline}}
+			}
+			feed.Items = append(feed.Items, entry)
+		}
+		c.Header("Content-Type", "application/feed+json; charset=utf-8")
+		c.JSON(http.StatusOK, feed)
+		return
+	}
+
+	updated := time.Now()
+	if len(items) > 0 {
+		updated = items[0].UpdatedAt
+	}
+	feed := atomFeed{
+		ID:      self,
+		Title:   title,
+		Updated: updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Rel: "self", Type: "application/atom+xml", Href: self},
+			{Rel: "alternate", Type: "text/html", Href: base + "/"},
+		},
+	}
+	for _, item := range items {
+		entry := atomEntry{
+			ID:        "urn:uuid:" + item.ID,
+			Title:     feedTitle(item),
+			Updated:   item.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: feedPublished(item).UTC().Format(time.RFC3339),
+			Links: []atomLink{
+				{Rel: "alternate", Type: "text/html", Href: base + "/archive/" + url.PathEscape(archiveRef(item))},
+				{Rel: "related", Href: item.URL},
+			},
+			Summary: feedSummary(item),
+		}
+		if full && item.ContentText != "" {
+			entry.Content = &atomContent{Type: "text", Body: item.ContentText}
+		}
+		if item.Byline != "" {
+			entry.Author = &atomPerson{Name: item.Byline}
+		}
+		for _, tag := range jsonStrings(item.TagsJSON) {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+	out, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "feed encoding failed"})
+		return
+	}
+	c.Data(http.StatusOK, "application/atom+xml; charset=utf-8", append([]byte(xml.Header), out...))
+}
+
+func feedTitle(item models.Archive) string {
+	if item.Title != "" {
+		return item.Title
+	}
+	return item.URL
+}
+
+func feedSummary(item models.Archive) string {
+	if item.Summary != "" {
+		return item.Summary
+	}
+	return item.Excerpt
+}
+
+func feedPublished(item models.Archive) time.Time {
+	if item.CapturedAt != nil {
+		return *item.CapturedAt
+	}
+	return item.CreatedAt
+}
+
+// requestBaseURL rebuilds the origin the client used, honouring the
+// X-Forwarded-* headers set by the frontend proxy.
+func requestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	host := c.Request.Host
+	if fwd := c.GetHeader("X-Forwarded-Host"); fwd != "" {
+		host = strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	return scheme + "://" + host
+}
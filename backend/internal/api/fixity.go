@@ -0,0 +1,229 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+)
+
+const (
+	fixityPageSize    = 100
+	fixityMaxProblems = 200
+)
+
+const (
+	FixityMissing    = "missing"
+	FixityCorrupt    = "corrupt"
+	FixityUnreadable = "unreadable"
+)
+
+type FixityProblem struct {
+	ArchiveID string `json:"archiveId"`
+	Object    string `json:"object"`
+	Problem   string `json:"problem"`
+	Expected  string `json:"expected,omitempty"`
+	Actual    string `json:"actual,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+type FixityReport struct {
+	ArchiveID string `json:"archiveId"`
+	Objects   int    `json:"objects"`
+	OK        int    `json:"ok"`
+	// Unhashed objects exist but were stored before hashes were recorded.
+	Unhashed int             `json:"unhashed"`
+	Problems []FixityProblem `json:"problems"`
+}
+
+type FixityStatus struct {
+	Running    bool            `json:"running"`
+	Total      int64           `json:"total"`
+	Checked    int             `json:"checked"`
+	Objects    int             `json:"objects"`
+	OK         int             `json:"ok"`
+	Unhashed   int             `json:"unhashed"`
+	Missing    int             `json:"missing"`
+	Corrupt    int             `json:"corrupt"`
+	Unreadable int             `json:"unreadable"`
+	Problems   []FixityProblem `json:"problems"`
+	StartedAt  *time.Time      `json:"startedAt,omitempty"`
+	FinishedAt *time.Time      `json:"finishedAt,omitempty"`
+	LastError  string          `json:"lastError,omitempty"`
+}
+
+type FixityRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// verifyArchive re-reads the archived HTML and every stored asset from
+// object storage and compares them with the hashes recorded at capture.
+func (s *Server) verifyArchive(ctx context.Context, item models.Archive) FixityReport {
+	report := FixityReport{ArchiveID: item.ID, Problems: []FixityProblem{}}
+	expected := map[string]string{}
+	order := []string{}
+	if item.HTMLPath != "" {
+		expected[item.HTMLPath] = item.HTMLSHA256
+		order = append(order, item.HTMLPath)
+	}
+	var assets []processor.Asset
+	if len(item.AssetsJSON) > 0 {
+		_ = json.Unmarshal(item.AssetsJSON, &assets)
+	}
+	for _, asset := range assets {
+		if _, seen := expected[asset.Stored]; seen || asset.Stored == "" {
+			continue
+		}
+		expected[asset.Stored] = asset.SHA256
+		order = append(order, asset.Stored)
+	}
+
+	prefix := storage.ArchivePrefix(item.ID)
+	for _, object := range order {
+		report.Objects++
+		want := expected[object]
+		sum, _, err := s.Store.Checksum(ctx, path.Join(prefix, object))
+		switch {
+		case err != nil && storage.IsNotFound(err):
+			report.Problems = append(report.Problems, FixityProblem{ArchiveID: item.ID, Object: object, Problem: FixityMissing})
+		case err != nil:
+			report.Problems = append(report.Problems, FixityProblem{ArchiveID: item.ID, Object: object, Problem: FixityUnreadable, Error: err.Error()})
+		case want == "":
+			report.Unhashed++
+		case sum != want:
+			report.Problems = append(report.Problems, FixityProblem{ArchiveID: item.ID, Object: object, Problem: FixityCorrupt, Expected: want, Actual: sum})
+		default:
+			report.OK++
+		}
+	}
+	return report
+}
+
+func (s *Server) checkArchiveFixity(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.Select("id", "html_path", "html_sha256", "assets_json").First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, s.verifyArchive(c.Request.Context(), item))
+}
+
+func (s *Server) fixityJobStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.getFixityStatus())
+}
+
+func (s *Server) startFixityCheck(c *gin.Context) {
+	var req FixityRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var total int64
+	query := s.DB.Model(&models.Archive{})
+	if len(req.IDs) > 0 {
+		query = query.Where("id IN ?", req.IDs)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+
+	s.fixityMu.Lock()
+	if s.fixityStatus.Running {
+		status := s.fixityStatus
+		s.fixityMu.Unlock()
+		c.JSON(http.StatusOK, status)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	s.fixityCancel = cancel
+	s.fixityStatus = FixityStatus{Running: true, Total: total, Problems: []FixityProblem{}, StartedAt: &now}
+	s.fixityMu.Unlock()
+
+	go s.runFixityCheck(ctx, req)
+	c.JSON(http.StatusOK, s.getFixityStatus())
+}
+
+func (s *Server) stopFixityCheck(c *gin.Context) {
+	s.fixityMu.Lock()
+	if s.fixityCancel != nil {
+		s.fixityCancel()
+		s.fixityCancel = nil
+	}
+	status := s.fixityStatus
+	s.fixityMu.Unlock()
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) getFixityStatus() FixityStatus {
+	s.fixityMu.Lock()
+	defer s.fixityMu.Unlock()
+	status := s.fixityStatus
+	status.Problems = append([]FixityProblem{}, s.fixityStatus.Problems...)
+	return status
+}
+
+func (s *Server) runFixityCheck(ctx context.Context, req FixityRequest) {
+	lastErr := ""
+	defer func() {
+		now := time.Now()
+		s.fixityMu.Lock()
+		s.fixityStatus.Running = false
+		s.fixityStatus.FinishedAt = &now
+		if lastErr != "" {
+			s.fixityStatus.LastError = lastErr
+		}
+		s.fixityCancel = nil
+		s.fixityMu.Unlock()
+	}()
+
+	lastID := ""
+	for {
+		var items []models.Archive
+		query := s.DB.Select("id", "html_path", "html_sha256", "assets_json").Where("id > ?", lastID).Order("id asc").Limit(fixityPageSize)
+		if len(req.IDs) > 0 {
+			query = query.Where("id IN ?", req.IDs)
+		}
+		if err := query.Find(&items).Error; err != nil {
+			lastErr = err.Error()
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		for _, item := range items {
+			if ctx.Err() != nil {
+				lastErr = "canceled"
+				return
+			}
+			report := s.verifyArchive(ctx, item)
+			s.fixityMu.Lock()
+			st := &s.fixityStatus
+			st.Checked++
+			st.Objects += report.Objects
+			st.OK += report.OK
+			st.Unhashed += report.Unhashed
+			for _, problem := range report.Problems {
+				switch problem.Problem {
+				case FixityMissing:
+					st.Missing++
+				case FixityCorrupt:
+					st.Corrupt++
+				default:
+					st.Unreadable++
+				}
+				if len(st.Problems) < fixityMaxProblems {
+					st.Problems = append(st.Problems, problem)
+				}
+			}
+			s.fixityMu.Unlock()
+		}
+		lastID = items[len(items)-1].ID
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/textutil"
+)
+
+const maxFlashcards = 30
+
+type FlashcardRequest struct {
+	Count int `json:"count"`
+}
+
+type flashcardPair struct {
+	Question string `json:"question"`
+	Answer   string `json:"answer"`
+}
+
+// generateFlashcards asks the LLM for Q/A pairs over the archive's text and
+// replaces any cards previously generated for it.
+func (s *Server) generateFlashcards(c *gin.Context) {
+	if s.LLM == nil || !s.LLM.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "llm not configured"})
+		return
+	}
+	var req FlashcardRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+	}
+	if req.Count <= 0 {
+		req.Count = 10
+	}
+	if req.Count > maxFlashcards {
+		req.Count = maxFlashcards
+	}
+
+	var item models.Archive
+	if err := s.DB.First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if strings.TrimSpace(item.ContentText) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archive has no text content"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 90*time.Second)
+	defer cancel()
+	pairs, err := s.askFlashcards(ctx, item, req.Count)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	cards := make([]models.Flashcard, 0, len(pairs))
+	for _, p := range pairs {
+		cards = append(cards, models.Flashcard{
+			ID:        uuid.New().String(),
+			ArchiveID: item.ID,
+			Question:  p.Question,
+			Answer:    p.Answer,
+		})
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("archive_id = ?", item.ID).Delete(&models.Flashcard{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&cards).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	c.JSON(http.StatusOK, cards)
+}
+
+func (s *Server) askFlashcards(ctx context.Context, item models.Archive, count int) ([]flashcardPair, error) {
+	content := textutil.Truncate(strings.TrimSpace(item.ContentText), 8000)
+	system := "You write study flashcards. Return strict JSON only."
+	user := fmt.Sprintf("Write up to %d flashcards covering the key facts and ideas of the text, in the same language as the text.\n"+
+		"Each question must be answerable from the text alone; keep answers short.\n"+
+		"Return JSON: {\"cards\": [{\"question\": string, \"answer\": string}]}.\n"+
+		"Title: %s\nContent: %s", count, item.Title, content)
+	raw, err := s.LLM.ChatJSON(ctx, system, user, 0.3)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Cards []flashcardPair `json:"cards"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(raw)), &resp); err != nil {
+		return nil, errors.New("llm invalid json")
+	}
+	out := make([]flashcardPair, 0, len(resp.Cards))
+	for _, p := range resp.Cards {
+		p.Question = strings.TrimSpace(p.Question)
+		p.Answer = strings.TrimSpace(p.Answer)
+		if p.Question == "" || p.Answer == "" {
+			continue
+		}
+		out = append(out, p)
+		if len(out) == count {
+			break
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("llm returned no flashcards")
+	}
+	return out, nil
+}
+
+func (s *Server) listFlashcards(c *gin.Context) {
+	var cards []models.Flashcard
+	if err := s.DB.Where("archive_id = ?", c.Param("id")).Order("created_at asc").Find(&cards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, cards)
+}
+
+func (s *Server) deleteFlashcard(c *gin.Context) {
+	if err := s.DB.Delete(&models.Flashcard{}, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// exportFlashcards writes cards as an Anki "Import File" text file: one
+// tab-separated note per line with the archive's tags in the third column.
+// Pass archive=<id>[,<id>...] to limit the export.
+func (s *Server) exportFlashcards(c *gin.Context) {
+	db := s.DB
+	if ids := splitList(c.Query("archive")); len(ids) > 0 {
+		db = db.Where("archive_id IN ?", ids)
+	}
+	var cards []models.Flashcard
+	if err := db.Order("archive_id asc, created_at asc").Find(&cards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	archiveIDs := []string{}
+	for _, card := range cards {
+		archiveIDs = append(archiveIDs, card.ArchiveID)
+	}
+	var items []models.Archive
+	if len(archiveIDs) > 0 {
+		if err := s.DB.Select("id", "tags_json").Where("id IN ?", archiveIDs).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+	}
+	tagsByArchive := map[string]string{}
+	for _, item := range items {
+		tags := []string{}
+		if len(item.TagsJSON) > 0 {
+			_ = json.Unmarshal(item.TagsJSON, &tags)
+		}
+		for i, t := range tags {
+			// Anki tags are space separated.
+			tags[i] = strings.Join(strings.Fields(t), "_")
+		}
+		tagsByArchive[item.ID] = strings.Join(tags, " ")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("#separator:tab\n#html:false\n#tags column:3\n")
+	for _, card := range cards {
+		fmt.Fprintf(&buf, "%s\t%s\t%s\n", ankiField(card.Question), ankiField(card.Answer), tagsByArchive[card.ArchiveID])
+	}
+	c.Header("Content-Disposition", `attachment; filename="webarchive-flashcards.txt"`)
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", buf.Bytes())
+}
+
+func ankiField(s string) string {
+	s = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ").Replace(s)
+	return strings.TrimSpace(s)
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"sort"
 	"strconv"
 	"strings"
@@ -32,15 +33,70 @@ type GraphResponse struct {
 }
 
 func (s *Server) getGraph(c *gin.Context) {
-	if c.Query("mode") == "knowledge" {
-		s.getKnowledgeGraph(c)
+	filter, err := parseGraphFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	var items []models.Archive
-	if err := s.DB.Order("created_at desc").Find(&items).Error; err != nil {
+	s.serveGraph(c, filter)
+}
+
+func (s *Server) serveGraph(c *gin.Context, filter graphFilter) {
+	opts, err := parseGraphOptions(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	out, err := s.buildGraph(filter, opts)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
 		return
 	}
+	s.writeGraph(c, out)
+}
+
+// graphOptions are the /api/graph query parameters beyond the archive
+// filter.
+type graphOptions struct {
+	knowledge bool
+	// Archives sharing at least coCite entities/tags get a weighted edge;
+	// cocite=0 turns the computed edges off.
+	coCite  int
+	similar similarEdges
+	// limit caps the entity nodes and archives the archives loaded for the
+	// knowledge graph.
+	limit    int
+	archives int
+}
+
+func parseGraphOptions(c *gin.Context) (graphOptions, error) {
+	return graphOptionsFromQuery(c.Request.URL.Query())
+}
+
+func graphOptionsFromQuery(q url.Values) (graphOptions, error) {
+	similar, err := parseSimilarEdges(q)
+	if err != nil {
+		return graphOptions{}, err
+	}
+	return graphOptions{
+		knowledge: q.Get("mode") == "knowledge",
+		coCite:    parseLimit(q.Get("cocite"), 2),
+		similar:   similar,
+		limit:     parseLimit(q.Get("limit"), 600),
+		archives:  parseLimit(q.Get("archives"), 200),
+	}, nil
+}
+
+// buildGraph computes the graph /api/graph serves, pruned to the filter's
+// groups.
+func (s *Server) buildGraph(filter graphFilter, opts graphOptions) (GraphResponse, error) {
+	if opts.knowledge {
+		return s.buildKnowledgeGraph(filter, opts)
+	}
+	var items []models.Archive
+	if err := filter.apply(s.reader()).Order("created_at desc").Find(&items).Error; err != nil {
+		return GraphResponse{}, err
+	}
 
 	nodes := map[string]GraphNode{}
 	links := make([]GraphLink, 0)
@@ -110,6 +166,18 @@ func (s *Server) getGraph(c *gin.Context) {
 		}
 	}
 
+	links = append(links, coCitationLinks(items, opts.coCite)...)
+	similarLinks, err := s.similarityLinks(items, opts.similar)
+	if err != nil {
+		return GraphResponse{}, err
+	}
+	links = append(links, similarLinks...)
+	if !filter.published {
+		if links, err = s.attachNotes(nodes, links); err != nil {
+			return GraphResponse{}, err
+		}
+	}
+
 	out := GraphResponse{
 		Nodes: make([]GraphNode, 0, len(nodes)),
 		Links: links,
@@ -117,7 +185,7 @@ func (s *Server) getGraph(c *gin.Context) {
 	for _, node := range nodes {
 		out.Nodes = append(out.Nodes, node)
 	}
-	c.JSON(http.StatusOK, out)
+	return filter.prune(out), nil
 }
 
 type knowledgeRelation struct {
@@ -126,18 +194,14 @@ type knowledgeRelation struct {
 	Type   string `json:"type"`
 }
 
-func (s *Server) getKnowledgeGraph(c *gin.Context) {
-	limit := parseLimit(c.Query("limit"), 600)
-	archiveLimit := parseLimit(c.Query("archives"), 200)
-
+func (s *Server) buildKnowledgeGraph(filter graphFilter, opts graphOptions) (GraphResponse, error) {
 	var items []models.Archive
-	query := s.DB.Order("created_at desc")
-	if archiveLimit > 0 {
-		query = query.Limit(archiveLimit)
+	query := filter.apply(s.reader()).Order("created_at desc")
+	if opts.archives > 0 {
+		query = query.Limit(opts.archives)
 	}
 	if err := query.Find(&items).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
-		return
+		return GraphResponse{}, err
 	}
 
 	type graphItem struct {
@@ -149,6 +213,7 @@ func (s *Server) getKnowledgeGraph(c *gin.Context) {
 	}
 	itemData := make([]graphItem, 0, len(items))
 	entityCounts := map[string]int{}
+	typeVotes := map[string]map[string]int{}
 
 	for _, item := range items {
 		label := item.Title
@@ -170,6 +235,12 @@ func (s *Server) getKnowledgeGraph(c *gin.Context) {
 			}
 			entityCounts[ent]++
 		}
+		for ent, t := range archiveEntityTypes(item) {
+			if typeVotes[ent] == nil {
+				typeVotes[ent] = map[string]int{}
+			}
+			typeVotes[ent][t]++
+		}
 		for _, rel := range relations {
 			src := strings.TrimSpace(rel.Source)
 			tgt := strings.TrimSpace(rel.Target)
@@ -188,7 +259,16 @@ func (s *Server) getKnowledgeGraph(c *gin.Context) {
 		})
 	}
 
-	allowedEntities := buildTopEntities(entityCounts, limit)
+	allowedEntities := buildTopEntities(entityCounts, opts.limit)
+	groupOf := func(ent string) string {
+		best, votes := "", 0
+		for t, n := range typeVotes[ent] {
+			if n > votes || (n == votes && t < best) {
+				best, votes = t, n
+			}
+		}
+		return entityGroup(best)
+	}
 
 	nodes := map[string]GraphNode{}
 	links := make([]GraphLink, 0)
@@ -219,7 +299,7 @@ func (s *Server) getKnowledgeGraph(c *gin.Context) {
 				continue
 			}
 			entID := "ent:" + ent
-			addNode(entID, ent, "entity", "")
+			addNode(entID, ent, groupOf(ent), "")
 			addLink(entID, archiveNodeID, "mentions")
 		}
 
@@ -234,12 +314,38 @@ func (s *Server) getKnowledgeGraph(c *gin.Context) {
 			}
 			srcID := "ent:" + src
 			tgtID := "ent:" + tgt
-			addNode(srcID, src, "entity", "")
-			addNode(tgtID, tgt, "entity", "")
+			addNode(srcID, src, groupOf(src), "")
+			addNode(tgtID, tgt, groupOf(tgt), "")
 			addLink(srcID, tgtID, rel.Type)
 		}
 	}
 
+	authors, wrote, err := s.authorLinks(items)
+	if err != nil {
+		return GraphResponse{}, err
+	}
+	for _, node := range authors {
+		addNode(node.ID, node.Label, node.Group, node.RefID)
+	}
+	links = append(links, wrote...)
+
+	links = append(links, coCitationLinks(items, opts.coCite)...)
+	stances, err := s.stanceLinks(items)
+	if err != nil {
+		return GraphResponse{}, err
+	}
+	links = append(links, stances...)
+	similarLinks, err := s.similarityLinks(items, opts.similar)
+	if err != nil {
+		return GraphResponse{}, err
+	}
+	links = append(links, similarLinks...)
+	if !filter.published {
+		if links, err = s.attachNotes(nodes, links); err != nil {
+			return GraphResponse{}, err
+		}
+	}
+
 	out := GraphResponse{
 		Nodes: make([]GraphNode, 0, len(nodes)),
 		Links: links,
@@ -247,7 +353,7 @@ func (s *Server) getKnowledgeGraph(c *gin.Context) {
 	for _, node := range nodes {
 		out.Nodes = append(out.Nodes, node)
 	}
-	c.JSON(http.StatusOK, out)
+	return filter.prune(out), nil
 }
 
 func parseLimit(raw string, def int) int {
@@ -290,3 +396,21 @@ func buildTopEntities(counts map[string]int, limit int) map[string]bool {
 	}
 	return out
 }
+
+// archiveEntityTypes returns the classified type of each entity, if any.
+func archiveEntityTypes(item models.Archive) map[string]string {
+	types := map[string]string{}
+	if len(item.EntityTypesJSON) > 0 {
+		_ = json.Unmarshal(item.EntityTypesJSON, &types)
+	}
+	return types
+}
+
+// entityGroup is the node group for an entity: its type when classified,
+// "entity" otherwise.
+func entityGroup(entityType string) string {
+	if entityType == "" {
+		return "entity"
+	}
+	return entityType
+}
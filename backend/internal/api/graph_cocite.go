@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"webarchive/internal/models"
+)
+
+// maxCoCiteFanout skips entities/tags shared by more archives than this;
+// ubiquitous terms say little about a theme and would make pairing quadratic.
+const maxCoCiteFanout = 100
+
+// coCitationLinks connects archives that share at least min entities or tags,
+// weighted by the size of the overlap.
+func coCitationLinks(items []models.Archive, min int) []GraphLink {
+	if min <= 0 {
+		return nil
+	}
+	holders := map[string][]int{}
+	for i, item := range items {
+		seen := map[string]bool{}
+		var tags, entities []string
+		if len(item.TagsJSON) > 0 {
+			_ = json.Unmarshal(item.TagsJSON, &tags)
+		}
+		if len(item.EntitiesJSON) > 0 {
+			_ = json.Unmarshal(item.EntitiesJSON, &entities)
+		}
+		for _, key := range append(prefixAll("tag:", tags), prefixAll("ent:", entities)...) {
+			if !seen[key] {
+				seen[key] = true
+				holders[key] = append(holders[key], i)
+			}
+		}
+	}
+
+	type pair struct{ a, b int }
+	overlap := map[pair]int{}
+	for _, idx := range holders {
+		if len(idx) < 2 || len(idx) > maxCoCiteFanout {
+			continue
+		}
+		for i := 0; i < len(idx); i++ {
+			for j := i + 1; j < len(idx); j++ {
+				overlap[pair{idx[i], idx[j]}]++
+			}
+		}
+	}
+
+	links := make([]GraphLink, 0)
+	for p, n := range overlap {
+		if n < min {
+			continue
+		}
+		links = append(links, GraphLink{
+			Source: "arc:" + items[p.a].ID,
+			Target: "arc:" + items[p.b].ID,
+			Value:  n,
+			Type:   "co-citation",
+		})
+	}
+	sort.Slice(links, func(i, j int) bool {
+		if links[i].Value != links[j].Value {
+			return links[i].Value > links[j].Value
+		}
+		if links[i].Source != links[j].Source {
+			return links[i].Source < links[j].Source
+		}
+		return links[i].Target < links[j].Target
+	})
+	return links
+}
+
+func prefixAll(prefix string, values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, prefix+v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/graphflow"
+)
+
+// graphFilter narrows /api/graph to a slice of the archive: the archive
+// conditions run in SQL, groups prune the resulting nodes.
+type graphFilter struct {
+	pathPrefix string
+	tags       []string
+	from       time.Time
+	to         time.Time
+	groups     map[string]bool
+	// published limits the graph to published archives and leaves notes
+	// out; it is set by the public graph, never from the query string.
+	published bool
+}
+
+func parseGraphFilter(c *gin.Context) (graphFilter, error) {
+	return graphFilterFromQuery(c.Request.URL.Query())
+}
+
+// graphFilterFromQuery reads the filter from a /api/graph query string, as
+// kept with graph snapshots.
+func graphFilterFromQuery(q url.Values) (graphFilter, error) {
+	f := graphFilter{pathPrefix: strings.Trim(strings.TrimSpace(q.Get("path")), "/")}
+	f.tags = splitList(q.Get("tags"))
+	if tag := strings.TrimSpace(q.Get("tag")); tag != "" {
+		f.tags = append(f.tags, tag)
+	}
+	var err error
+	if f.from, err = parseTimelineDate(q.Get("from")); err != nil {
+		return f, errors.New("invalid from")
+	}
+	if f.to, err = parseTimelineDate(q.Get("to")); err != nil {
+		return f, errors.New("invalid to")
+	}
+	if !f.to.IsZero() && len(strings.TrimSpace(q.Get("to"))) == len("2006-01-02") {
+		f.to = f.to.Add(24*time.Hour - time.Nanosecond)
+	}
+	if groups := splitList(q.Get("groups")); len(groups) > 0 {
+		f.groups = map[string]bool{}
+		for _, g := range groups {
+			if !validGraphGroup(g) {
+				return f, errors.New("unknown group " + g)
+			}
+			f.groups[g] = true
+		}
+	}
+	return f, nil
+}
+
+func (f graphFilter) apply(db *gorm.DB) *gorm.DB {
+	if f.published {
+		db = db.Where("published = ?", true)
+	}
+	if f.pathPrefix != "" {
+		db = db.Where("hierarchy_path = ? OR hierarchy_path LIKE ?", f.pathPrefix, f.pathPrefix+"/%")
+	}
+	if len(f.tags) > 0 {
+		conds := make([]string, 0, len(f.tags))
+		args := make([]any, 0, len(f.tags))
+		for _, tag := range f.tags {
+			conds = append(conds, "JSON_CONTAINS(tags_json, JSON_QUOTE(?))")
+			args = append(args, tag)
+		}
+		db = db.Where("("+strings.Join(conds, " OR ")+")", args...)
+	}
+	if !f.from.IsZero() {
+		db = db.Where("(captured_at >= ? OR (captured_at IS NULL AND created_at >= ?))", f.from, f.from)
+	}
+	if !f.to.IsZero() {
+		db = db.Where("(captured_at <= ? OR (captured_at IS NULL AND created_at <= ?))", f.to, f.to)
+	}
+	return db
+}
+
+// prune drops nodes outside the requested groups and any links left dangling.
+func (f graphFilter) prune(out GraphResponse) GraphResponse {
+	if len(f.groups) == 0 {
+		return out
+	}
+	keep := map[string]bool{}
+	nodes := make([]GraphNode, 0, len(out.Nodes))
+	for _, node := range out.Nodes {
+		// "entity" selects every entity node whatever its type.
+		if f.groups[node.Group] || (f.groups["entity"] && strings.HasPrefix(node.ID, "ent:")) {
+			keep[node.ID] = true
+			nodes = append(nodes, node)
+		}
+	}
+	links := make([]GraphLink, 0, len(out.Links))
+	for _, link := range out.Links {
+		if keep[link.Source] && keep[link.Target] {
+			links = append(links, link)
+		}
+	}
+	return GraphResponse{Nodes: nodes, Links: links}
+}
+
+func validGraphGroup(group string) bool {
+	switch group {
+	case "archive", "category", "tag", "path", "entity", "note", "author":
+		return true
+	}
+	for _, t := range graphflow.EntityTypes {
+		if group == t {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(raw string) []string {
+	out := []string{}
+	for _, part := range strings.Split(raw, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+)
+
+type GraphNodeMetric struct {
+	ID         string  `json:"id"`
+	Label      string  `json:"label"`
+	Group      string  `json:"group"`
+	RefID      string  `json:"refId,omitempty"`
+	Degree     int     `json:"degree"`
+	Centrality float64 `json:"centrality"`
+	Community  int     `json:"community"`
+}
+
+type GraphCommunity struct {
+	ID      int      `json:"id"`
+	Size    int      `json:"size"`
+	Members []string `json:"members"`
+}
+
+type GraphMetricsResponse struct {
+	Nodes            int               `json:"nodes"`
+	Links            int               `json:"links"`
+	TopNodes         []GraphNodeMetric `json:"topNodes"`
+	TopEntities      []GraphNodeMetric `json:"topEntities"`
+	IsolatedArchives []GraphNode       `json:"isolatedArchives"`
+	Communities      []GraphCommunity  `json:"communities"`
+	Membership       map[string]int    `json:"membership"`
+}
+
+// getGraphMetrics summarizes the graph server-side (degree centrality, hubs,
+// isolated archives, communities) so clients can highlight structure without
+// downloading every node. It accepts the same filters as /api/graph.
+func (s *Server) getGraphMetrics(c *gin.Context) {
+	filter, err := parseGraphFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	top := parseLimit(c.Query("top"), 20)
+	var items []models.Archive
+	query := filter.apply(s.reader()).Order("created_at desc")
+	if archiveLimit := parseLimit(c.Query("archives"), 2000); archiveLimit > 0 {
+		query = query.Limit(archiveLimit)
+	}
+	if err := query.Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+
+	nodes := map[string]GraphNode{}
+	links := make([]GraphLink, 0)
+	for _, item := range items {
+		itemNodes, itemLinks := archiveGraph(item)
+		for id, node := range itemNodes {
+			if _, ok := nodes[id]; !ok {
+				nodes[id] = node
+			}
+		}
+		links = append(links, itemLinks...)
+	}
+	if links, err = s.attachNotes(nodes, links); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	graph := filter.prune(GraphResponse{Nodes: mapValues(nodes), Links: links})
+
+	adjacency := map[string]map[string]bool{}
+	for _, node := range graph.Nodes {
+		adjacency[node.ID] = map[string]bool{}
+	}
+	edges := 0
+	for _, link := range graph.Links {
+		if link.Source == link.Target || adjacency[link.Source][link.Target] {
+			continue
+		}
+		adjacency[link.Source][link.Target] = true
+		adjacency[link.Target][link.Source] = true
+		edges++
+	}
+
+	labels := labelPropagation(adjacency)
+	communities, index := rankCommunities(labels, adjacency)
+	membership := make(map[string]int, len(labels))
+	for id, label := range labels {
+		membership[id] = index[label]
+	}
+
+	metrics := make([]GraphNodeMetric, 0, len(graph.Nodes))
+	isolated := make([]GraphNode, 0)
+	for _, node := range graph.Nodes {
+		degree := len(adjacency[node.ID])
+		centrality := 0.0
+		if len(graph.Nodes) > 1 {
+			centrality = float64(degree) / float64(len(graph.Nodes)-1)
+		}
+		metrics = append(metrics, GraphNodeMetric{
+			ID:         node.ID,
+			Label:      node.Label,
+			Group:      node.Group,
+			RefID:      node.RefID,
+			Degree:     degree,
+			Centrality: centrality,
+			Community:  membership[node.ID],
+		})
+		if node.Group == "archive" && degree == 0 {
+			isolated = append(isolated, node)
+		}
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		if metrics[i].Degree != metrics[j].Degree {
+			return metrics[i].Degree > metrics[j].Degree
+		}
+		return metrics[i].ID < metrics[j].ID
+	})
+	sort.Slice(isolated, func(i, j int) bool { return isolated[i].ID < isolated[j].ID })
+
+	out := GraphMetricsResponse{
+		Nodes:            len(graph.Nodes),
+		Links:            edges,
+		TopNodes:         make([]GraphNodeMetric, 0, top),
+		TopEntities:      make([]GraphNodeMetric, 0, top),
+		IsolatedArchives: isolated,
+		Communities:      communities,
+		Membership:       membership,
+	}
+	for _, m := range metrics {
+		if len(out.TopNodes) < top {
+			out.TopNodes = append(out.TopNodes, m)
+		}
+		if len(out.TopEntities) < top && strings.HasPrefix(m.ID, "ent:") {
+			out.TopEntities = append(out.TopEntities, m)
+		}
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// labelPropagation assigns every node the label most common among its
+// neighbors until nothing changes. Nodes are visited in sorted order and ties
+// go to the smallest label, so the result is deterministic.
+func labelPropagation(adjacency map[string]map[string]bool) map[string]string {
+	ids := make([]string, 0, len(adjacency))
+	labels := make(map[string]string, len(adjacency))
+	for id := range adjacency {
+		ids = append(ids, id)
+		labels[id] = id
+	}
+	sort.Strings(ids)
+	for iter := 0; iter < 30; iter++ {
+		changed := false
+		for _, id := range ids {
+			if len(adjacency[id]) == 0 {
+				continue
+			}
+			counts := map[string]int{}
+			for neighbor := range adjacency[id] {
+				counts[labels[neighbor]]++
+			}
+			best, bestCount := labels[id], counts[labels[id]]
+			for label, n := range counts {
+				if n > bestCount || (n == bestCount && label < best) {
+					best, bestCount = label, n
+				}
+			}
+			if best != labels[id] {
+				labels[id] = best
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+	return labels
+}
+
+// rankCommunities numbers communities by size (largest first) and lists each
+// one's best-connected members.
+func rankCommunities(labels map[string]string, adjacency map[string]map[string]bool) ([]GraphCommunity, map[string]int) {
+	members := map[string][]string{}
+	for id, label := range labels {
+		members[label] = append(members[label], id)
+	}
+	keys := make([]string, 0, len(members))
+	for label := range members {
+		keys = append(keys, label)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(members[keys[i]]) != len(members[keys[j]]) {
+			return len(members[keys[i]]) > len(members[keys[j]])
+		}
+		return keys[i] < keys[j]
+	})
+	index := make(map[string]int, len(keys))
+	out := make([]GraphCommunity, 0, len(keys))
+	for i, label := range keys {
+		index[label] = i
+		list := members[label]
+		if len(list) < 2 {
+			continue
+		}
+		sort.Slice(list, func(a, b int) bool {
+			if len(adjacency[list[a]]) != len(adjacency[list[b]]) {
+				return len(adjacency[list[a]]) > len(adjacency[list[b]])
+			}
+			return list[a] < list[b]
+		})
+		if len(list) > 10 {
+			list = list[:10]
+		}
+		out = append(out, GraphCommunity{ID: i, Size: len(members[label]), Members: list})
+	}
+	return out, index
+}
+
+func mapValues(nodes map[string]GraphNode) []GraphNode {
+	out := make([]GraphNode, 0, len(nodes))
+	for _, node := range nodes {
+		out = append(out, node)
+	}
+	return out
+}
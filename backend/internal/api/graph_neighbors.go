@@ -0,0 +1,265 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+const maxNeighborDepth = 3
+
+// getGraphNeighbors returns the neighborhood of a single node instead of the
+// whole graph, so clients can expand large graphs progressively. Node IDs use
+// the same prefixes as /api/graph (arc:, tag:, cat:, path:, ent:).
+func (s *Server) getGraphNeighbors(c *gin.Context) {
+	root := strings.TrimSpace(c.Query("id"))
+	if root == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id required"})
+		return
+	}
+	depth := parseLimit(c.Query("depth"), 1)
+	if depth < 1 {
+		depth = 1
+	}
+	if depth > maxNeighborDepth {
+		depth = maxNeighborDepth
+	}
+	perNode := parseLimit(c.Query("limit"), 200)
+
+	nodes := map[string]GraphNode{}
+	linkSeen := map[string]bool{}
+	links := make([]GraphLink, 0)
+	visited := map[string]bool{}
+	frontier := []string{root}
+
+	for level := 0; level < depth && len(frontier) > 0; level++ {
+		next := []string{}
+		for _, id := range frontier {
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+			items, err := s.nodeArchives(id, perNode)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+				return
+			}
+			notes, err := s.nodeNotes(id, perNode)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+				return
+			}
+			known := map[string]GraphNode{}
+			candidates := make([]GraphLink, 0)
+			for _, item := range items {
+				itemNodes, itemLinks := archiveGraph(item)
+				for nodeID, node := range itemNodes {
+					known[nodeID] = node
+				}
+				candidates = append(candidates, itemLinks...)
+			}
+			for _, note := range notes {
+				node, noteLinks := noteGraph(note)
+				known[node.ID] = node
+				candidates = append(candidates, noteLinks...)
+			}
+			for _, link := range candidates {
+				other := ""
+				switch id {
+				case link.Source:
+					other = link.Target
+				case link.Target:
+					other = link.Source
+				default:
+					continue
+				}
+				key := link.Source + "\x00" + link.Target + "\x00" + link.Type
+				if !linkSeen[key] {
+					linkSeen[key] = true
+					links = append(links, link)
+				}
+				for _, nodeID := range []string{id, other} {
+					if _, ok := nodes[nodeID]; ok {
+						continue
+					}
+					node, ok := known[nodeID]
+					if !ok && strings.HasPrefix(nodeID, "ent:") {
+						// Entities only named by a note have no archive to describe them.
+						node = GraphNode{ID: nodeID, Label: strings.TrimPrefix(nodeID, "ent:"), Group: "entity"}
+					}
+					nodes[nodeID] = node
+				}
+				if !visited[other] {
+					next = append(next, other)
+				}
+			}
+		}
+		frontier = next
+	}
+
+	if len(nodes) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return
+	}
+	out := GraphResponse{
+		Nodes: make([]GraphNode, 0, len(nodes)),
+		Links: links,
+	}
+	for _, node := range nodes {
+		out.Nodes = append(out.Nodes, node)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// nodeArchives loads the archives that can contribute edges to the node.
+func (s *Server) nodeArchives(id string, limit int) ([]models.Archive, error) {
+	prefix, value, ok := strings.Cut(id, ":")
+	if !ok || value == "" {
+		return nil, nil
+	}
+	var query *gorm.DB
+	switch prefix {
+	case "arc":
+		query = s.reader().Where("id = ?", value)
+	case "note":
+		var note models.Note
+		if err := s.reader().Limit(1).Find(&note, "id = ?", value).Error; err != nil {
+			return nil, err
+		}
+		query = s.reader().Where("id IN ?", noteArchiveIDs(note))
+	case "cat":
+		query = s.reader().Where("category = ?", value)
+	case "tag":
+		query = s.reader().Where("JSON_CONTAINS(tags_json, JSON_QUOTE(?))", value)
+	case "path":
+		query = s.reader().Where("hierarchy_path = ? OR hierarchy_path LIKE ?", value, value+"/%")
+	case "ent":
+		query = s.reader().Where("JSON_CONTAINS(entities_json, JSON_QUOTE(?)) OR JSON_SEARCH(relations_json, 'one', ?) IS NOT NULL", value, value)
+	default:
+		return nil, nil
+	}
+	query = query.Order("created_at desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var items []models.Archive
+	err := query.Find(&items).Error
+	return items, err
+}
+
+// nodeNotes loads the notes that can contribute edges to the node.
+func (s *Server) nodeNotes(id string, limit int) ([]models.Note, error) {
+	prefix, value, ok := strings.Cut(id, ":")
+	if !ok || value == "" {
+		return nil, nil
+	}
+	var query *gorm.DB
+	switch prefix {
+	case "note":
+		query = s.reader().Where("id = ?", value)
+	case "arc":
+		query = s.reader().Where("JSON_CONTAINS(archive_ids_json, JSON_QUOTE(?))", value)
+	case "ent":
+		query = s.reader().Where("JSON_CONTAINS(entities_json, JSON_QUOTE(?))", value)
+	default:
+		return nil, nil
+	}
+	query = query.Order("updated_at desc")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var notes []models.Note
+	err := query.Find(&notes).Error
+	return notes, err
+}
+
+// archiveGraph returns every node and edge a single archive contributes to
+// either graph mode.
+func archiveGraph(item models.Archive) (map[string]GraphNode, []GraphLink) {
+	nodes := map[string]GraphNode{}
+	links := make([]GraphLink, 0)
+	addNode := func(id, label, group, refID string) {
+		if _, ok := nodes[id]; !ok {
+			nodes[id] = GraphNode{ID: id, Label: label, Group: group, RefID: refID}
+		}
+	}
+	addLink := func(source, target, relType string) {
+		links = append(links, GraphLink{Source: source, Target: target, Value: 1, Type: relType})
+	}
+
+	archiveNodeID := "arc:" + item.ID
+	label := item.Title
+	if label == "" {
+		label = item.URL
+	}
+	addNode(archiveNodeID, label, "archive", item.ID)
+
+	if item.Category != "" {
+		addNode("cat:"+item.Category, item.Category, "category", "")
+		addLink("cat:"+item.Category, archiveNodeID, "")
+	}
+
+	tags := []string{}
+	if len(item.TagsJSON) > 0 {
+		_ = json.Unmarshal(item.TagsJSON, &tags)
+	}
+	for _, tag := range tags {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			addNode("tag:"+tag, tag, "tag", "")
+			addLink("tag:"+tag, archiveNodeID, "")
+		}
+	}
+
+	path := []string{}
+	if len(item.HierarchyJSON) > 0 {
+		_ = json.Unmarshal(item.HierarchyJSON, &path)
+	}
+	prev := ""
+	for idx, p := range path {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		pathID := "path:" + strings.Join(path[:idx+1], "/")
+		addNode(pathID, p, "path", "")
+		if prev != "" {
+			addLink(prev, pathID, "")
+		}
+		prev = pathID
+	}
+	if prev != "" {
+		addLink(prev, archiveNodeID, "")
+	}
+
+	types := archiveEntityTypes(item)
+	entities := []string{}
+	if len(item.EntitiesJSON) > 0 {
+		_ = json.Unmarshal(item.EntitiesJSON, &entities)
+	}
+	for _, ent := range entities {
+		if ent = strings.TrimSpace(ent); ent != "" {
+			addNode("ent:"+ent, ent, entityGroup(types[ent]), "")
+			addLink("ent:"+ent, archiveNodeID, "mentions")
+		}
+	}
+	relations := []knowledgeRelation{}
+	if len(item.RelationsJSON) > 0 {
+		_ = json.Unmarshal(item.RelationsJSON, &relations)
+	}
+	for _, rel := range relations {
+		src := strings.TrimSpace(rel.Source)
+		tgt := strings.TrimSpace(rel.Target)
+		if src == "" || tgt == "" {
+			continue
+		}
+		addNode("ent:"+src, src, entityGroup(types[src]), "")
+		addNode("ent:"+tgt, tgt, entityGroup(types[tgt]), "")
+		addLink("ent:"+src, "ent:"+tgt, rel.Type)
+	}
+	return nodes, links
+}
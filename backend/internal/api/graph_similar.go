@@ -0,0 +1,134 @@
+package api
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"webarchive/internal/models"
+	"webarchive/internal/vectorstore"
+)
+
+// LinkSimilar connects archives whose embeddings are close, so archives the
+// LLM never extracted entities from still join the graph.
+const LinkSimilar = "similar_to"
+
+// similarGraphMax caps how many archives similar_to edges are computed
+// among; pairing is quadratic, so larger graphs only link their newest
+// archives.
+const similarGraphMax = 1000
+
+// similarEdges asks for similar_to edges between archives whose cosine
+// similarity is at least threshold, at most degree per archive. A zero
+// threshold turns them off.
+type similarEdges struct {
+	threshold float64
+	degree    int
+}
+
+func parseSimilarEdges(q url.Values) (similarEdges, error) {
+	out := similarEdges{degree: parseLimit(q.Get("similarDegree"), 5)}
+	raw := q.Get("similar")
+	if raw == "" {
+		return out, nil
+	}
+	threshold, err := strconv.ParseFloat(raw, 64)
+	if err != nil || threshold < 0 || threshold > 1 {
+		return out, errors.New("similar must be a similarity between 0 and 1")
+	}
+	out.threshold = threshold
+	return out, nil
+}
+
+// similarityLinks returns the similar_to edges among items, weighted by the
+// similarity in percent. Pairs are taken best first, skipping any that would
+// give an archive more than the allowed degree. Archives without an
+// embedding for the current model get none.
+func (s *Server) similarityLinks(items []models.Archive, opts similarEdges) ([]GraphLink, error) {
+	links := make([]GraphLink, 0)
+	if opts.threshold <= 0 || opts.degree <= 0 || len(items) < 2 || s.LLM == nil || s.LLM.EmbeddingModel == "" {
+		return links, nil
+	}
+	ids := make([]string, 0, min(len(items), similarGraphMax))
+	for _, item := range items {
+		if len(ids) == similarGraphMax {
+			break
+		}
+		ids = append(ids, item.ID)
+	}
+	var rows []models.ArchiveEmbedding
+	if err := s.reader().Select("archive_id", "vector").
+		Where("model = ? AND archive_id IN ?", s.LLM.EmbeddingModel, ids).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	vectors := make([][]float32, len(rows))
+	for i, row := range rows {
+		vectors[i] = normalizeVector(vectorstore.DecodeVector(row.Vector))
+	}
+	type pair struct {
+		a, b  int
+		score float64
+	}
+	var pairs []pair
+	for i := range vectors {
+		for j := i + 1; j < len(vectors); j++ {
+			if len(vectors[i]) == 0 || len(vectors[i]) != len(vectors[j]) {
+				continue
+			}
+			var dot float64
+			for k, v := range vectors[i] {
+				dot += float64(v) * float64(vectors[j][k])
+			}
+			if dot >= opts.threshold {
+				pairs = append(pairs, pair{i, j, dot})
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].score != pairs[j].score {
+			return pairs[i].score > pairs[j].score
+		}
+		if pairs[i].a != pairs[j].a {
+			return rows[pairs[i].a].ArchiveID < rows[pairs[j].a].ArchiveID
+		}
+		return rows[pairs[i].b].ArchiveID < rows[pairs[j].b].ArchiveID
+	})
+
+	degree := make([]int, len(rows))
+	for _, p := range pairs {
+		if degree[p.a] >= opts.degree || degree[p.b] >= opts.degree {
+			continue
+		}
+		degree[p.a]++
+		degree[p.b]++
+		links = append(links, GraphLink{
+			Source: "arc:" + rows[p.a].ArchiveID,
+			Target: "arc:" + rows[p.b].ArchiveID,
+			Value:  int(math.Round(p.score * 100)),
+			Type:   LinkSimilar,
+		})
+	}
+	return links, nil
+}
+
+// normalizeVector scales v to unit length so a dot product is the cosine
+// similarity; a zero vector comes back empty.
+func normalizeVector(v []float32) []float32 {
+	var norm float64
+	for _, f := range v {
+		norm += float64(f) * float64(f)
+	}
+	if norm == 0 {
+		return nil
+	}
+	norm = math.Sqrt(norm)
+	out := make([]float32, len(v))
+	for i, f := range v {
+		out[i] = float32(float64(f) / norm)
+	}
+	return out
+}
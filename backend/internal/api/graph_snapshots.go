@@ -0,0 +1,249 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+// GraphSnapshotCurrent, as the "to" of a comparison, stands for the graph
+// as it is now, computed with the snapshot's own query.
+const GraphSnapshotCurrent = "current"
+
+type GraphSnapshotRequest struct {
+	Label string `json:"label"`
+}
+
+type GraphSnapshotResponse struct {
+	models.GraphSnapshot
+	Graph *GraphResponse `json:"graph,omitempty"`
+}
+
+// GraphSnapshotDiff lists what changed from one graph to another. Links are
+// matched by source, target and type; a changed weight alone is not a
+// change.
+type GraphSnapshotDiff struct {
+	From         string      `json:"from"`
+	To           string      `json:"to"`
+	FromNodes    int         `json:"fromNodes"`
+	ToNodes      int         `json:"toNodes"`
+	FromLinks    int         `json:"fromLinks"`
+	ToLinks      int         `json:"toLinks"`
+	AddedNodes   []GraphNode `json:"addedNodes"`
+	RemovedNodes []GraphNode `json:"removedNodes"`
+	AddedLinks   []GraphLink `json:"addedLinks"`
+	RemovedLinks []GraphLink `json:"removedLinks"`
+}
+
+// snapshotQuery drops the parameters that only shape how /api/graph is
+// delivered, keeping those that decide what the graph holds.
+func snapshotQuery(q url.Values) string {
+	out := url.Values{}
+	for key, values := range q {
+		switch key {
+		case "format", "chunk", "cursor":
+			continue
+		}
+		out[key] = values
+	}
+	return out.Encode()
+}
+
+// createGraphSnapshot computes the graph for the request's query string, the
+// same parameters /api/graph takes, and saves it under the given label.
+func (s *Server) createGraphSnapshot(c *gin.Context) {
+	var req GraphSnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
+		return
+	}
+	req.Label = strings.TrimSpace(req.Label)
+	if req.Label == "" || len(req.Label) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "label is required and at most 255 bytes"})
+		return
+	}
+	query := snapshotQuery(c.Request.URL.Query())
+	if len(query) > 1024 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query too long"})
+		return
+	}
+	graph, status, err := s.graphForQuery(query)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	sortGraph(graph)
+	raw, _ := json.Marshal(graph)
+	snap := models.GraphSnapshot{
+		ID:        uuid.New().String(),
+		Label:     req.Label,
+		Query:     query,
+		NodeCount: len(graph.Nodes),
+		LinkCount: len(graph.Links),
+		GraphJSON: raw,
+		CreatedBy: currentPrincipal(c).Username,
+		CreatedAt: time.Now(),
+	}
+	if err := s.DB.Create(&snap).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, GraphSnapshotResponse{GraphSnapshot: snap})
+}
+
+// graphForQuery builds the graph a stored query string describes; a bad
+// query is reported with 400 and a failed build with 500.
+func (s *Server) graphForQuery(query string) (GraphResponse, int, error) {
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return GraphResponse{}, http.StatusBadRequest, errors.New("invalid query")
+	}
+	filter, err := graphFilterFromQuery(q)
+	if err != nil {
+		return GraphResponse{}, http.StatusBadRequest, err
+	}
+	opts, err := graphOptionsFromQuery(q)
+	if err != nil {
+		return GraphResponse{}, http.StatusBadRequest, err
+	}
+	graph, err := s.buildGraph(filter, opts)
+	if err != nil {
+		return GraphResponse{}, http.StatusInternalServerError, errors.New("db query failed")
+	}
+	return graph, http.StatusOK, nil
+}
+
+// listGraphSnapshots returns the snapshots newest first, without their
+// graphs.
+func (s *Server) listGraphSnapshots(c *gin.Context) {
+	var rows []models.GraphSnapshot
+	if err := s.DB.Omit("graph_json").Order("created_at desc").
+		Limit(parseLimit(c.Query("limit"), 50)).Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rows)
+}
+
+func (s *Server) getGraphSnapshot(c *gin.Context) {
+	snap, graph, ok := s.loadGraphSnapshot(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, GraphSnapshotResponse{GraphSnapshot: snap, Graph: &graph})
+}
+
+// compareGraphSnapshots diffs the snapshot against another one named by
+// ?to=, or against the current graph when to is empty or "current".
+func (s *Server) compareGraphSnapshots(c *gin.Context) {
+	from, fromGraph, ok := s.loadGraphSnapshot(c, c.Param("id"))
+	if !ok {
+		return
+	}
+	to := strings.TrimSpace(c.Query("to"))
+	var toGraph GraphResponse
+	if to == "" || to == GraphSnapshotCurrent {
+		to = GraphSnapshotCurrent
+		graph, status, err := s.graphForQuery(from.Query)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		toGraph = graph
+	} else if _, toGraph, ok = s.loadGraphSnapshot(c, to); !ok {
+		return
+	}
+	c.JSON(http.StatusOK, diffGraphs(from.ID, to, fromGraph, toGraph))
+}
+
+func (s *Server) deleteGraphSnapshot(c *gin.Context) {
+	res := s.DB.Delete(&models.GraphSnapshot{}, "id = ?", c.Param("id"))
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// loadGraphSnapshot reads a snapshot and its graph, answering the request
+// itself when it can't.
+func (s *Server) loadGraphSnapshot(c *gin.Context, id string) (models.GraphSnapshot, GraphResponse, bool) {
+	var snap models.GraphSnapshot
+	if err := s.DB.First(&snap, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return snap, GraphResponse{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return snap, GraphResponse{}, false
+	}
+	graph := GraphResponse{Nodes: []GraphNode{}, Links: []GraphLink{}}
+	if err := json.Unmarshal(snap.GraphJSON, &graph); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "snapshot unreadable"})
+		return snap, GraphResponse{}, false
+	}
+	snap.GraphJSON = nil
+	return snap, graph, true
+}
+
+func diffGraphs(fromID, toID string, from, to GraphResponse) GraphSnapshotDiff {
+	out := GraphSnapshotDiff{
+		From:         fromID,
+		To:           toID,
+		FromNodes:    len(from.Nodes),
+		ToNodes:      len(to.Nodes),
+		FromLinks:    len(from.Links),
+		ToLinks:      len(to.Links),
+		AddedNodes:   []GraphNode{},
+		RemovedNodes: []GraphNode{},
+		AddedLinks:   []GraphLink{},
+		RemovedLinks: []GraphLink{},
+	}
+	linkKey := func(l GraphLink) string { return l.Source + "\x00" + l.Target + "\x00" + l.Type }
+	fromNodes, fromLinks := map[string]bool{}, map[string]bool{}
+	for _, node := range from.Nodes {
+		fromNodes[node.ID] = true
+	}
+	for _, link := range from.Links {
+		fromLinks[linkKey(link)] = true
+	}
+	toNodes, toLinks := map[string]bool{}, map[string]bool{}
+	for _, node := range to.Nodes {
+		toNodes[node.ID] = true
+		if !fromNodes[node.ID] {
+			out.AddedNodes = append(out.AddedNodes, node)
+		}
+	}
+	for _, link := range to.Links {
+		toLinks[linkKey(link)] = true
+		if !fromLinks[linkKey(link)] {
+			out.AddedLinks = append(out.AddedLinks, link)
+		}
+	}
+	for _, node := range from.Nodes {
+		if !toNodes[node.ID] {
+			out.RemovedNodes = append(out.RemovedNodes, node)
+		}
+	}
+	for _, link := range from.Links {
+		if !toLinks[linkKey(link)] {
+			out.RemovedLinks = append(out.RemovedLinks, link)
+		}
+	}
+	sortGraph(GraphResponse{Nodes: out.AddedNodes, Links: out.AddedLinks})
+	sortGraph(GraphResponse{Nodes: out.RemovedNodes, Links: out.RemovedLinks})
+	return out
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// graphChunkMax caps ?chunk=, counting nodes and links together.
+	graphChunkMax = 5000
+	// graphFlushLines is how many NDJSON lines go out between flushes.
+	graphFlushLines = 500
+)
+
+// GraphChunk is one page of a graph: the nodes come first, then the links,
+// so a client that renders pages as they arrive never sees a link before
+// both of its ends.
+type GraphChunk struct {
+	Nodes      []GraphNode `json:"nodes"`
+	Links      []GraphLink `json:"links"`
+	TotalNodes int         `json:"totalNodes"`
+	TotalLinks int         `json:"totalLinks"`
+	// NextCursor is empty on the last chunk.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+type graphLine struct {
+	Type string `json:"type"`
+	Data any    `json:"data"`
+}
+
+// writeGraph sends a built graph as one JSON document, or, for large
+// graphs, as NDJSON (format=ndjson or Accept: application/x-ndjson) and/or
+// in cursor-addressed chunks (chunk=N&cursor=...).
+func (s *Server) writeGraph(c *gin.Context, out GraphResponse) {
+	sortGraph(out)
+	ndjson := c.Query("format") == "ndjson" || strings.Contains(c.GetHeader("Accept"), "application/x-ndjson")
+	if c.Query("chunk") == "" {
+		if ndjson {
+			streamGraph(c, GraphChunk{Nodes: out.Nodes, Links: out.Links, TotalNodes: len(out.Nodes), TotalLinks: len(out.Links)})
+			return
+		}
+		c.JSON(http.StatusOK, out)
+		return
+	}
+
+	size, err := strconv.Atoi(c.Query("chunk"))
+	if err != nil || size <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "chunk must be a positive number"})
+		return
+	}
+	if size > graphChunkMax {
+		size = graphChunkMax
+	}
+	// The cursor is the offset into nodes-then-links plus a fingerprint of
+	// the graph it was issued for: the graph is rebuilt on every request,
+	// and an offset into a graph that has since changed would skip or
+	// repeat entries without anyone noticing.
+	fp := graphFingerprint(out)
+	offset := 0
+	if cursor := c.Query("cursor"); cursor != "" {
+		pos, sum, ok := strings.Cut(cursor, ".")
+		n, err := strconv.ParseInt(pos, 36, 64)
+		if !ok || err != nil || n < 0 || int(n) > len(out.Nodes)+len(out.Links) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid cursor"})
+			return
+		}
+		if sum != fp {
+			c.JSON(http.StatusConflict, gin.H{"error": "graph changed; restart from the first chunk"})
+			return
+		}
+		offset = int(n)
+	}
+
+	chunk := GraphChunk{TotalNodes: len(out.Nodes), TotalLinks: len(out.Links)}
+	end := offset + size
+	if offset < len(out.Nodes) {
+		chunk.Nodes = out.Nodes[offset:min(end, len(out.Nodes))]
+	}
+	if end > len(out.Nodes) {
+		chunk.Links = out.Links[max(offset-len(out.Nodes), 0):min(end-len(out.Nodes), len(out.Links))]
+	}
+	if chunk.Nodes == nil {
+		chunk.Nodes = []GraphNode{}
+	}
+	if chunk.Links == nil {
+		chunk.Links = []GraphLink{}
+	}
+	if end < len(out.Nodes)+len(out.Links) {
+		chunk.NextCursor = strconv.FormatInt(int64(end), 36) + "." + fp
+	}
+	if ndjson {
+		streamGraph(c, chunk)
+		return
+	}
+	c.JSON(http.StatusOK, chunk)
+}
+
+// streamGraph writes a "meta" line with the totals and cursor, then one
+// "node" line per node and one "link" line per link.
+func streamGraph(c *gin.Context, chunk GraphChunk) {
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	if err := enc.Encode(graphLine{Type: "meta", Data: gin.H{
+		"totalNodes": chunk.TotalNodes,
+		"totalLinks": chunk.TotalLinks,
+		"nextCursor": chunk.NextCursor,
+	}}); err != nil {
+		return
+	}
+	lines := 0
+	emit := func(typ string, data any) bool {
+		if err := enc.Encode(graphLine{Type: typ, Data: data}); err != nil {
+			return false
+		}
+		if lines++; lines%graphFlushLines == 0 {
+			c.Writer.Flush()
+		}
+		return true
+	}
+	for _, node := range chunk.Nodes {
+		if !emit("node", node) {
+			return
+		}
+	}
+	for _, link := range chunk.Links {
+		if !emit("link", link) {
+			return
+		}
+	}
+	c.Writer.Flush()
+}
+
+// sortGraph fixes the order of nodes and links, which are otherwise
+// collected through maps, so chunk offsets mean the same thing on every
+// request for an unchanged graph.
+func sortGraph(out GraphResponse) {
+	sort.Slice(out.Nodes, func(i, j int) bool { return out.Nodes[i].ID < out.Nodes[j].ID })
+	sort.SliceStable(out.Links, func(i, j int) bool {
+		a, b := out.Links[i], out.Links[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Target != b.Target {
+			return a.Target < b.Target
+		}
+		return a.Type < b.Type
+	})
+}
+
+func graphFingerprint(out GraphResponse) string {
+	h := sha256.New()
+	for _, node := range out.Nodes {
+		h.Write([]byte(node.ID + "\x00" + node.Label + "\x00" + node.Group + "\n"))
+	}
+	for _, link := range out.Links {
+		h.Write([]byte(link.Source + "\x00" + link.Target + "\x00" + link.Type + "\x00" + strconv.Itoa(link.Value) + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
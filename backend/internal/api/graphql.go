@@ -0,0 +1,425 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/graphql"
+	"webarchive/internal/models"
+)
+
+const graphqlMaxLimit = 500
+
+// graphqlQuery serves read-only GraphQL over archives, taxonomy, tags,
+// entities and relations. GET takes query/operationName/variables as query
+// parameters; POST takes the usual JSON body.
+func (s *Server) graphqlQuery(c *gin.Context) {
+	var req graphql.Request
+	if c.Request.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if raw := c.Query("variables"); raw != "" {
+			if err := json.Unmarshal([]byte(raw), &req.Variables); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variables"})
+				return
+			}
+		}
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query is required"})
+		return
+	}
+	ctx := context.WithValue(c.Request.Context(), graphqlRowsKey{}, graphqlRows{})
+	c.JSON(http.StatusOK, graphql.Execute(ctx, s.graphqlSchema(), req))
+}
+
+type graphqlRowsKey struct{}
+
+// graphqlRows caches the whole-table scans behind tags, entities and
+// relations, keyed by the columns selected, so one request loads each at
+// most once however often a query nests or aliases those fields. The
+// executor resolves fields one at a time, so it needs no lock.
+type graphqlRows map[string][]models.Archive
+
+// graphqlScan loads columns of every archive, newest first, reusing the
+// request's earlier scan of the same columns.
+func (s *Server) graphqlScan(p graphql.Params, columns string) ([]models.Archive, error) {
+	cache, _ := p.Context.Value(graphqlRowsKey{}).(graphqlRows)
+	if items, ok := cache[columns]; ok {
+		return items, nil
+	}
+	var items []models.Archive
+	if err := s.reader().Select(columns).Order("created_at desc").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	if cache != nil {
+		cache[columns] = items
+	}
+	return items, nil
+}
+
+func (s *Server) graphqlSchema() *graphql.Object {
+	archive := &graphql.Object{Name: "Archive"}
+	node := &graphql.Object{Name: "TaxonomyNode"}
+	tag := &graphql.Object{Name: "Tag"}
+	entity := &graphql.Object{Name: "Entity"}
+	relation := &graphql.Object{Name: "Relation"}
+
+	archive.Fields = map[string]*graphql.Field{
+		"id":            archiveField(func(a models.Archive) any { return a.ID }),
+		"title":         archiveField(func(a models.Archive) any { return a.Title }),
+		"url":           archiveField(func(a models.Archive) any { return a.URL }),
+		"canonicalUrl":  archiveField(func(a models.Archive) any { return a.CanonicalURL }),
+		"domain":        archiveField(func(a models.Archive) any { return a.Domain }),
+		"siteName":      archiveField(func(a models.Archive) any { return a.SiteName }),
+		"byline":        archiveField(func(a models.Archive) any { return a.Byline }),
+		"excerpt":       archiveField(func(a models.Archive) any { return a.Excerpt }),
+		"favicon":       archiveField(func(a models.Archive) any { return a.Favicon }),
+		"category":      archiveField(func(a models.Archive) any { return a.Category }),
+		"summary":       archiveField(func(a models.Archive) any { return a.Summary }),
+		"contentText":   archiveField(func(a models.Archive) any { return a.ContentText }),
+		"hierarchyPath": archiveField(func(a models.Archive) any { return a.HierarchyPath }),
+		"wordCount":     archiveField(func(a models.Archive) any { return a.WordCount }),
+		"readMinutes":   archiveField(func(a models.Archive) any { return a.ReadMinutes }),
+		"viewCount":     archiveField(func(a models.Archive) any { return a.ViewCount }),
+		"captureMode":   archiveField(func(a models.Archive) any { return captureModeOf(a) }),
+		"captureSource": archiveField(func(a models.Archive) any { return a.CaptureSource }),
+		"capturedAt":    archiveField(func(a models.Archive) any { return a.CapturedAt }),
+		"createdAt":     archiveField(func(a models.Archive) any { return a.CreatedAt }),
+		"updatedAt":     archiveField(func(a models.Archive) any { return a.UpdatedAt }),
+		"tags":          archiveField(func(a models.Archive) any { return jsonStrings(a.TagsJSON) }),
+		"hierarchy":     archiveField(func(a models.Archive) any { return jsonStrings(a.HierarchyJSON) }),
+		"paths": {Resolve: func(p graphql.Params) (any, error) {
+			return s.loadArchivePaths(p.Source.(models.Archive).ID)
+		}},
+		"nodes": {Type: node, Resolve: func(p graphql.Params) (any, error) {
+			var nodes []models.TaxonomyNode
+			err := s.reader().Where("id IN (?)", s.reader().Model(&models.ArchivePath{}).
+				Select("node_id").Where("archive_id = ?", p.Source.(models.Archive).ID)).
+				Order("path asc").Find(&nodes).Error
+			return nodes, err
+		}},
+		"entities": {Type: entity, Resolve: func(p graphql.Params) (any, error) {
+			item := p.Source.(models.Archive)
+			types := archiveEntityTypes(item)
+			out := []map[string]any{}
+			for _, name := range jsonStrings(item.EntitiesJSON) {
+				if name = strings.TrimSpace(name); name != "" {
+					out = append(out, map[string]any{"name": name, "type": types[name]})
+				}
+			}
+			return out, nil
+		}},
+		"relations": {Type: relation, Resolve: func(p graphql.Params) (any, error) {
+			return archiveRelations(p.Source.(models.Archive)), nil
+		}},
+	}
+
+	node.Fields = map[string]*graphql.Field{
+		"id":          nodeField(func(n models.TaxonomyNode) any { return n.ID }),
+		"label":       nodeField(func(n models.TaxonomyNode) any { return n.Label }),
+		"path":        nodeField(func(n models.TaxonomyNode) any { return n.Path }),
+		"level":       nodeField(func(n models.TaxonomyNode) any { return n.Level }),
+		"parentId":    nodeField(func(n models.TaxonomyNode) any { return n.ParentID }),
+		"description": nodeField(func(n models.TaxonomyNode) any { return n.Description }),
+		"overview":    nodeField(func(n models.TaxonomyNode) any { return s.freshOverview(n) }),
+		"parent": {Type: node, Resolve: func(p graphql.Params) (any, error) {
+			parentID := p.Source.(models.TaxonomyNode).ParentID
+			if parentID == nil {
+				return nil, nil
+			}
+			return s.graphqlNode(*parentID, "")
+		}},
+		"children": {Type: node, Resolve: func(p graphql.Params) (any, error) {
+			var children []models.TaxonomyNode
+			err := s.reader().Where("parent_id = ?", p.Source.(models.TaxonomyNode).ID).Order("label asc").Find(&children).Error
+			return children, err
+		}},
+		"archives": {Type: archive, Resolve: func(p graphql.Params) (any, error) {
+			n := p.Source.(models.TaxonomyNode)
+			paths := s.reader().Model(&models.ArchivePath{}).Select("archive_id")
+			if p.Bool("descendants") {
+				paths = paths.Where("path = ? OR path LIKE ?", n.Path, n.Path+"/%")
+			} else {
+				paths = paths.Where("node_id = ?", n.ID)
+			}
+			var items []models.Archive
+			err := s.reader().Where("id IN (?)", paths).Order("created_at desc").
+				Limit(graphqlLimit(p, 50)).Offset(p.Int("offset", 0)).Find(&items).Error
+			return items, err
+		}},
+	}
+
+	tag.Fields = map[string]*graphql.Field{
+		"name":  {},
+		"count": {},
+		"archives": {Type: archive, Resolve: func(p graphql.Params) (any, error) {
+			name, _ := json.Marshal(p.Source.(map[string]any)["name"])
+			var items []models.Archive
+			err := s.reader().Where("JSON_CONTAINS(tags_json, ?)", string(name)).Order("created_at desc").
+				Limit(graphqlLimit(p, 50)).Find(&items).Error
+			return items, err
+		}},
+	}
+
+	entity.Fields = map[string]*graphql.Field{
+		"name":  {},
+		"type":  {},
+		"count": {},
+		"archives": {Type: archive, Resolve: func(p graphql.Params) (any, error) {
+			name, _ := json.Marshal(p.Source.(map[string]any)["name"])
+			var items []models.Archive
+			err := s.reader().Where("JSON_CONTAINS(entities_json, ?)", string(name)).Order("created_at desc").
+				Limit(graphqlLimit(p, 50)).Find(&items).Error
+			return items, err
+		}},
+		"relations": {Type: relation, Resolve: func(p graphql.Params) (any, error) {
+			name, _ := p.Source.(map[string]any)["name"].(string)
+			return s.graphqlRelations(p, name, p.String("type"), graphqlLimit(p, 100))
+		}},
+	}
+
+	relation.Fields = map[string]*graphql.Field{
+		"source": {},
+		"target": {},
+		"type":   {},
+		"archive": {Type: archive, Resolve: func(p graphql.Params) (any, error) {
+			id, _ := p.Source.(map[string]any)["archiveId"].(string)
+			return s.graphqlArchive(id)
+		}},
+	}
+
+	return &graphql.Object{Name: "Query", Fields: map[string]*graphql.Field{
+		"archives": {Type: archive, Resolve: s.graphqlArchives},
+		"archive": {Type: archive, Resolve: func(p graphql.Params) (any, error) {
+			return s.graphqlArchive(p.String("id"))
+		}},
+		"taxonomy": {Type: node, Resolve: func(p graphql.Params) (any, error) {
+			var roots []models.TaxonomyNode
+			err := s.reader().Where("parent_id IS NULL").Order("label asc").Find(&roots).Error
+			return roots, err
+		}},
+		"node": {Type: node, Resolve: func(p graphql.Params) (any, error) {
+			return s.graphqlNode(p.String("id"), p.String("path"))
+		}},
+		"tags":     {Type: tag, Resolve: s.graphqlTags},
+		"entities": {Type: entity, Resolve: s.graphqlEntities},
+		"relations": {Type: relation, Resolve: func(p graphql.Params) (any, error) {
+			return s.graphqlRelations(p, p.String("entity"), p.String("type"), graphqlLimit(p, 100))
+		}},
+	}}
+}
+
+func archiveField(get func(models.Archive) any) *graphql.Field {
+	return &graphql.Field{Resolve: func(p graphql.Params) (any, error) {
+		return get(p.Source.(models.Archive)), nil
+	}}
+}
+
+func nodeField(get func(models.TaxonomyNode) any) *graphql.Field {
+	return &graphql.Field{Resolve: func(p graphql.Params) (any, error) {
+		return get(p.Source.(models.TaxonomyNode)), nil
+	}}
+}
+
+func graphqlLimit(p graphql.Params, def int) int {
+	n := p.Int("limit", def)
+	if n <= 0 {
+		return def
+	}
+	if n > graphqlMaxLimit {
+		return graphqlMaxLimit
+	}
+	return n
+}
+
+func jsonStrings(raw []byte) []string {
+	out := []string{}
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &out)
+	}
+	return out
+}
+
+func archiveRelations(item models.Archive) []map[string]any {
+	var relations []knowledgeRelation
+	if len(item.RelationsJSON) > 0 {
+		_ = json.Unmarshal(item.RelationsJSON, &relations)
+	}
+	out := make([]map[string]any, 0, len(relations))
+	for _, rel := range relations {
+		src, tgt := strings.TrimSpace(rel.Source), strings.TrimSpace(rel.Target)
+		if src == "" || tgt == "" {
+			continue
+		}
+		out = append(out, map[string]any{"source": src, "target": tgt, "type": rel.Type, "archiveId": item.ID})
+	}
+	return out
+}
+
+// graphqlArchive resolves a missing archive to null rather than an error.
+func (s *Server) graphqlArchive(id string) (any, error) {
+	var item models.Archive
+	if err := s.reader().First(&item, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+func (s *Server) graphqlNode(id, path string) (any, error) {
+	var node models.TaxonomyNode
+	db := s.reader().Where("id = ?", id)
+	if id == "" {
+		db = s.reader().Where("path = ?", strings.Trim(path, "/"))
+	}
+	if err := db.First(&node).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return node, nil
+}
+
+func (s *Server) graphqlArchives(p graphql.Params) (any, error) {
+	db := s.reader()
+	if q := p.String("q"); q != "" {
+		cond, args := s.textCondition(q)
+		db = db.Where(cond, args...)
+	}
+	if category := p.String("category"); category != "" {
+		db = db.Where("category = ?", category)
+	}
+	if tag := p.String("tag"); tag != "" {
+		name, _ := json.Marshal(tag)
+		db = db.Where("JSON_CONTAINS(tags_json, ?)", string(name))
+	}
+	if domain := p.String("domain"); domain != "" {
+		db = db.Where("domain = ?", strings.TrimPrefix(strings.ToLower(domain), "www."))
+	}
+	if path := strings.Trim(p.String("path"), "/"); path != "" {
+		db = db.Where("id IN (?)", s.reader().Model(&models.ArchivePath{}).Select("archive_id").
+			Where("path = ? OR path LIKE ?", path, path+"/%"))
+	}
+	var items []models.Archive
+	err := db.Order("created_at desc").Limit(graphqlLimit(p, 50)).Offset(p.Int("offset", 0)).Find(&items).Error
+	return items, err
+}
+
+func (s *Server) graphqlTags(p graphql.Params) (any, error) {
+	items, err := s.graphqlScan(p, "id, tags_json")
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, item := range items {
+		for _, t := range jsonStrings(item.TagsJSON) {
+			if t = strings.TrimSpace(t); t != "" {
+				counts[t]++
+			}
+		}
+	}
+	return rankCounts(counts, nil, graphqlLimit(p, 100)), nil
+}
+
+func (s *Server) graphqlEntities(p graphql.Params) (any, error) {
+	items, err := s.graphqlScan(p, "id, entities_json, entity_types_json")
+	if err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	typeVotes := map[string]map[string]int{}
+	for _, item := range items {
+		for _, ent := range jsonStrings(item.EntitiesJSON) {
+			if ent = strings.TrimSpace(ent); ent != "" {
+				counts[ent]++
+			}
+		}
+		for ent, t := range archiveEntityTypes(item) {
+			if typeVotes[ent] == nil {
+				typeVotes[ent] = map[string]int{}
+			}
+			typeVotes[ent][t]++
+		}
+	}
+	types := map[string]string{}
+	for ent, votes := range typeVotes {
+		best := 0
+		for t, n := range votes {
+			if n > best || (n == best && t < types[ent]) {
+				best, types[ent] = n, t
+			}
+		}
+	}
+	if want := p.String("type"); want != "" {
+		for ent := range counts {
+			if types[ent] != want {
+				delete(counts, ent)
+			}
+		}
+	}
+	return rankCounts(counts, types, graphqlLimit(p, 100)), nil
+}
+
+// graphqlRelations lists relations touching entity (any when empty),
+// optionally of one type, newest archives first.
+func (s *Server) graphqlRelations(p graphql.Params, entity, relType string, limit int) (any, error) {
+	items, err := s.graphqlScan(p, "id, relations_json")
+	if err != nil {
+		return nil, err
+	}
+	out := []map[string]any{}
+	for _, item := range items {
+		for _, rel := range archiveRelations(item) {
+			if entity != "" && rel["source"] != entity && rel["target"] != entity {
+				continue
+			}
+			if relType != "" && rel["type"] != relType {
+				continue
+			}
+			out = append(out, rel)
+			if len(out) >= limit {
+				return out, nil
+			}
+		}
+	}
+	return out, nil
+}
+
+func rankCounts(counts map[string]int, types map[string]string, limit int) []map[string]any {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > limit {
+		names = names[:limit]
+	}
+	out := make([]map[string]any, 0, len(names))
+	for _, name := range names {
+		row := map[string]any{"name": name, "count": counts[name]}
+		if types != nil {
+			row["type"] = types[name]
+		}
+		out = append(out, row)
+	}
+	return out
+}
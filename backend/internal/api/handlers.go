@@ -5,10 +5,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -16,67 +22,289 @@ import (
 	"gorm.io/gorm"
 
 	"webarchive/internal/ai"
+	"webarchive/internal/auth"
+	"webarchive/internal/cache"
+	"webarchive/internal/extractor"
 	"webarchive/internal/graphflow"
 	"webarchive/internal/models"
+	"webarchive/internal/notify"
 	"webarchive/internal/processor"
+	"webarchive/internal/proxy"
+	"webarchive/internal/searchindex"
+	"webarchive/internal/settings"
 	"webarchive/internal/storage"
+	"webarchive/internal/textutil"
+	"webarchive/internal/vectorstore"
 )
 
 type Server struct {
-	DB            *gorm.DB
-	Store         *storage.MinioStore
-	Processor     *processor.Processor
-	LLM           *ai.Client
-	AutoTag       bool
-	Eino          *graphflow.Analyzer
-	analyzeMu     sync.Mutex
-	analyzeCancel context.CancelFunc
-	analyzeStatus AnalysisStatus
+	DB        *gorm.DB
+	Store     *storage.MinioStore
+	Processor *processor.Processor
+	LLM       *ai.Client
+	AutoTag   bool
+	Eino      *graphflow.Analyzer
+	TagQueue  *TagQueue
+	Vectors   vectorstore.Store
+	// SearchIndex answers the q filter of list and search requests; nil
+	// means LIKE over the archives table.
+	SearchIndex searchindex.Index
+	// ReadDB serves list, search and graph reads; it may be a replica that
+	// lags behind DB. Use reader() rather than the field.
+	ReadDB *gorm.DB
+	// LLMProxy is applied to LLM clients created at runtime via /api/ai/config.
+	LLMProxy *proxy.Rules
+	// LLMLimiter caps concurrent LLM calls across all features; it is
+	// shared by clients created at runtime too.
+	LLMLimiter *ai.Limiter
+	// AuthEnabled turns on token authentication and role checks; when false
+	// every request is treated as an admin.
+	AuthEnabled bool
+	// PublicEnabled exposes published archives under /api/public without
+	// authentication.
+	PublicEnabled bool
+	// Cache holds taxonomy, graph and client config responses; nil disables
+	// caching.
+	Cache *cache.Cache
+	// QuotaBytes caps the storage used by all archives and UserQuotaBytes
+	// the storage used by each user's captures; 0 means unlimited.
+	QuotaBytes     int64
+	UserQuotaBytes int64
+	// TieringMonths moves archives captured longer ago than this to the
+	// cold storage tier; 0 disables tiering.
+	TieringMonths int
+	// PriceWebhookURL receives a POST whenever a tracked product's price
+	// changes.
+	PriceWebhookURL string
+	// WatchWebhookURL receives a POST whenever a watched page changes by at
+	// least WatchMinChangePercent of its lines.
+	WatchWebhookURL       string
+	WatchMinChangePercent float64
+	// ReminderWebhookURL receives a POST when an archive's reminder is due.
+	ReminderWebhookURL string
+	// SMTP sends mail outside the digest, such as send-to-device exports
+	// to the DeviceEmails addresses.
+	SMTP         notify.SMTPConfig
+	DeviceEmails []string
+	// NotifyWebhookURL and NotifyEmails also receive every notification
+	// about a background failure.
+	NotifyWebhookURL string
+	NotifyEmails     []string
+	// RecaptureKeepVersions bounds the versions kept per archive; older
+	// ones are deleted after each recapture.
+	RecaptureKeepVersions int
+	// BlockTrackers is the default for captures whose request and preset
+	// don't say whether to drop ad and analytics elements.
+	BlockTrackers bool
+	// TitleCleanup strips site names and boilerplate from titles at capture;
+	// TitleCleanupLLM also asks the LLM about the ones the rules leave with a
+	// separator in them.
+	TitleCleanup    bool
+	TitleCleanupLLM bool
+	// ArchiveSlugs gives every archive a slug made from its title, which
+	// links then use instead of the ID. Slugs set by hand work either way.
+	ArchiveSlugs bool
+	// Extractors pull title, byline and text out of pages from sites the
+	// generic extraction handles poorly; nil disables them.
+	Extractors *extractor.Registry
+	// PDFTools extracts text and thumbnails from uploaded PDFs.
+	PDFTools processor.PDFTools
+	// ViewerOrigin, when set, is the separate origin archived pages and
+	// their assets are served from, with links signed by ViewerSecret.
+	// Links between archives lead from there back to BaseURL.
+	ViewerOrigin string
+	ViewerSecret []byte
+	BaseURL      string
+	// MaxPayloadBytes caps capture request bodies; 0 means unlimited.
+	MaxPayloadBytes    int64
+	ready              atomic.Bool
+	runtimeMu          sync.Mutex
+	runtime            settings.RuntimeSettings
+	instanceOnce       sync.Once
+	instance           string
+	analyzeMu          sync.Mutex
+	analyzeCancel      context.CancelFunc
+	analyzeStatus      AnalysisStatus
+	embedMu            sync.Mutex
+	embedCancel        context.CancelFunc
+	embedStatus        EmbeddingStatus
+	clusterMu          sync.Mutex
+	clusterStatus      ClusterJobStatus
+	fixityMu           sync.Mutex
+	fixityCancel       context.CancelFunc
+	fixityStatus       FixityStatus
+	tieringMu          sync.Mutex
+	tieringCancel      context.CancelFunc
+	tieringStatus      TieringStatus
+	metaRefreshMu      sync.Mutex
+	metaRefreshCancel  context.CancelFunc
+	metaRefreshStatus  MetaRefreshStatus
+	titleCleanupMu     sync.Mutex
+	titleCleanupCancel context.CancelFunc
+	titleCleanupStatus TitleCleanupStatus
+	priceMu            sync.Mutex
+	priceCancel        context.CancelFunc
+	priceStatus        PriceTrackStatus
+	watchMu            sync.Mutex
+	watchCancel        context.CancelFunc
+	watchStatus        WatchStatus
+	recaptureMu        sync.Mutex
+	recaptureCancel    context.CancelFunc
+	recaptureStatus    RecaptureStatus
+	stanceMu           sync.Mutex
+	stanceCancel       context.CancelFunc
+	stanceStatus       StanceStatus
+	bookmarkMu         sync.Mutex
+	bookmarkCancel     context.CancelFunc
+	bookmarkStatus     BookmarkImportStatus
+	shareMu            sync.Mutex
+	shareJobs          map[string]*shareJob
+	shareSlots         chan struct{}
+	sealing            sync.Map
+	events             eventHub
 }
 
-type CreateArchiveRequest struct {
-	URL            string     `json:"url"`
-	Title          string     `json:"title"`
-	HTML           string     `json:"html"`
-	Content        string     `json:"content"`
-	Excerpt        string     `json:"excerpt"`
-	Byline         string     `json:"byline"`
-	SiteName       string     `json:"siteName"`
-	Favicon        string     `json:"favicon"`
-	CapturedAt     *time.Time `json:"capturedAt"`
-	Category       string     `json:"category"`
-	Tags           []string   `json:"tags"`
-	Hierarchy      []string   `json:"hierarchy"`
-	HierarchyPaths []string   `json:"hierarchyPaths"`
-	AutoTag        bool       `json:"autoTag"`
-}
-
-type UpdateArchiveRequest struct {
-	Category       string   `json:"category"`
-	Tags           []string `json:"tags"`
-	Hierarchy      []string `json:"hierarchy"`
-	HierarchyPaths []string `json:"hierarchyPaths"`
-}
+const (
+	SnapshotSingleFile = "singlefile"
+	SnapshotMHTML      = "mhtml"
+)
 
-type ArchiveResponse struct {
-	ID             string          `json:"id"`
-	Title          string          `json:"title"`
+type CreateArchiveRequest struct {
 	URL            string          `json:"url"`
-	SiteName       string          `json:"siteName"`
-	Byline         string          `json:"byline"`
+	Title          string          `json:"title"`
+	HTML           string          `json:"html"`
+	Content        string          `json:"content"`
 	Excerpt        string          `json:"excerpt"`
+	Byline         string          `json:"byline"`
+	SiteName       string          `json:"siteName"`
 	Favicon        string          `json:"favicon"`
+	CapturedAt     *time.Time      `json:"capturedAt"`
 	Category       string          `json:"category"`
 	Tags           []string        `json:"tags"`
 	Hierarchy      []string        `json:"hierarchy"`
-	HierarchyPath  string          `json:"hierarchyPath"`
 	HierarchyPaths []string        `json:"hierarchyPaths"`
-	ContentText    string          `json:"contentText,omitempty"`
-	CapturedAt     *time.Time      `json:"capturedAt"`
-	HTMLPath       string          `json:"htmlPath"`
-	AssetsJSON     json.RawMessage `json:"assets"`
-	CreatedAt      time.Time       `json:"createdAt"`
-	UpdatedAt      time.Time       `json:"updatedAt"`
+	AutoTag        bool            `json:"autoTag"`
+	Source         string          `json:"source"`
+	Client         string          `json:"client"`
+	Preset         string          `json:"preset"`
+	Cookies        []CaptureCookie `json:"cookies"`
+	// IgnoreRobots and Unthrottled skip robots.txt and the rate limits for
+	// sites the user runs themselves.
+	IgnoreRobots bool `json:"ignoreRobots"`
+	Unthrottled  bool `json:"unthrottled"`
+	// Mode "metadata" keeps only metadata, extracted text and the
+	// screenshot; no HTML snapshot or assets are stored.
+	Mode       string `json:"mode"`
+	Screenshot string `json:"screenshot"`
+	// Print also stores a print-media variant of the snapshot.
+	Print bool `json:"print"`
+	// Snapshot is a complete page packaged by the browser ("singlefile"
+	// HTML with inlined resources, or "mhtml"); no assets are fetched by the
+	// server for it.
+	Snapshot       string `json:"snapshot"`
+	SnapshotFormat string `json:"snapshotFormat"`
+	// Profile "email" cleans up mail HTML (see processor.ProfileEmail);
+	// Attachments supply the inline images it references by cid:.
+	Profile     string              `json:"profile"`
+	Attachments []CaptureAttachment `json:"attachments"`
+	// BlockTrackers overrides the preset and server default for dropping
+	// ad and analytics elements.
+	BlockTrackers *bool `json:"blockTrackers"`
+	// RemoveSelectors are CSS selectors dropped from the page before it is
+	// stored, added to those of the preset and the domain's removal rules.
+	RemoveSelectors []string `json:"removeSelectors"`
+	// Annotations are passages the user selected on the page before
+	// capturing it.
+	Annotations []CaptureAnnotation `json:"annotations"`
+	// Encrypt seals the archive's stored objects with ARCHIVE_ENCRYPTION_KEY.
+	Encrypt bool `json:"encrypt"`
+	// SummaryStyles are generated once the archive is stored; empty takes
+	// the preset's.
+	SummaryStyles []string `json:"summaryStyles"`
+	// Provenance is where an imported item was saved before, oldest first;
+	// migrations pass along the chain an export carries.
+	Provenance []Provenance `json:"provenance"`
+}
+
+// UpdateArchiveRequest has PATCH semantics: nil fields are left untouched,
+// while an explicit empty value clears the field.
+type UpdateArchiveRequest struct {
+	Category       *string    `json:"category"`
+	Tags           *[]string  `json:"tags"`
+	AddTags        []string   `json:"addTags"`
+	RemoveTags     []string   `json:"removeTags"`
+	Hierarchy      *[]string  `json:"hierarchy"`
+	HierarchyPaths *[]string  `json:"hierarchyPaths"`
+	Published      *bool      `json:"published"`
+	Watched        *bool      `json:"watched"`
+	RecaptureDays  *int       `json:"recaptureDays"`
+	RemindAt       *string    `json:"remindAt"` // RFC 3339 time or YYYY-MM-DD
+	RemindNote     *string    `json:"remindNote"`
+	Slug           *string    `json:"slug"` // "" removes the slug
+	UpdatedAt      *time.Time `json:"updatedAt"`
+}
+
+type ArchiveResponse struct {
+	ID             string            `json:"id"`
+	Slug           *string           `json:"slug,omitempty"`
+	Title          string            `json:"title"`
+	URL            string            `json:"url"`
+	CanonicalURL   string            `json:"canonicalUrl"`
+	FinalURL       string            `json:"finalUrl,omitempty"`
+	Redirects      json.RawMessage   `json:"redirects,omitempty"`
+	Domain         string            `json:"domain"`
+	SiteName       string            `json:"siteName"`
+	Byline         string            `json:"byline"`
+	Excerpt        string            `json:"excerpt"`
+	Favicon        string            `json:"favicon"`
+	Category       string            `json:"category"`
+	Tags           []string          `json:"tags"`
+	Hierarchy      []string          `json:"hierarchy"`
+	HierarchyPath  string            `json:"hierarchyPath"`
+	HierarchyPaths []string          `json:"hierarchyPaths"`
+	ContentText    string            `json:"contentText,omitempty"`
+	WordCount      int               `json:"wordCount"`
+	ReadMinutes    int               `json:"readMinutes"`
+	CapturedAt     *time.Time        `json:"capturedAt"`
+	HTMLPath       string            `json:"htmlPath"`
+	HTMLSHA256     string            `json:"htmlSha256,omitempty"`
+	AssetsJSON     json.RawMessage   `json:"assets"`
+	CaptureMode    string            `json:"captureMode"`
+	ScreenshotPath string            `json:"screenshotPath,omitempty"`
+	PrintPath      string            `json:"printPath,omitempty"`
+	DarkPath       string            `json:"darkPath,omitempty"`
+	DocumentPath   string            `json:"documentPath,omitempty"`
+	StorageTier    string            `json:"storageTier,omitempty"`
+	StorageBytes   int64             `json:"storageBytes,omitempty"`
+	Encrypted      bool              `json:"encrypted,omitempty"`
+	CapturedBy     string            `json:"capturedBy,omitempty"`
+	CaptureSource  string            `json:"captureSource"`
+	CaptureClient  string            `json:"captureClient"`
+	Provenance     []Provenance      `json:"provenance,omitempty"`
+	Published      bool              `json:"published"`
+	Watched        bool              `json:"watched"`
+	RecaptureDays  int               `json:"recaptureDays,omitempty"`
+	RecapturedAt   *time.Time        `json:"recapturedAt,omitempty"`
+	RemindAt       *time.Time        `json:"remindAt,omitempty"`
+	RemindNote     string            `json:"remindNote,omitempty"`
+	RemindedAt     *time.Time        `json:"remindedAt,omitempty"`
+	ClientIP       string            `json:"clientIp"`
+	UserAgent      string            `json:"userAgent"`
+	CreatedAt      time.Time         `json:"createdAt"`
+	UpdatedAt      time.Time         `json:"updatedAt"`
+	Structured     *StructuredCard   `json:"structured,omitempty"`
+	Completeness   *int              `json:"completeness,omitempty"`
+	MissingAssets  []string          `json:"missingAssets,omitempty"`
+	Report         *processor.Report `json:"report,omitempty"`
+	DuplicateOf    []string          `json:"duplicateOf,omitempty"`
+	AITask         *AITaskStatus     `json:"aiTask,omitempty"`
+	// The summary styles; lists leave the abstract out unless asked for.
+	SummaryOneLiner string   `json:"summaryOneLiner,omitempty"`
+	SummaryPoints   []string `json:"summaryPoints,omitempty"`
+	SummaryAbstract string   `json:"summaryAbstract,omitempty"`
+	// TaxonomyWarnings note labels the taxonomy label rules changed in the
+	// request that produced this response.
+	TaxonomyWarnings []string `json:"taxonomyWarnings,omitempty"`
 }
 
 func toArchiveResponse(item models.Archive, paths []string) ArchiveResponse {
@@ -96,84 +324,638 @@ func toArchiveResponse(item models.Archive, paths []string) ArchiveResponse {
 		}
 	}
 	return ArchiveResponse{
-		ID:             item.ID,
-		Title:          item.Title,
-		URL:            item.URL,
-		SiteName:       item.SiteName,
-		Byline:         item.Byline,
-		Excerpt:        item.Excerpt,
-		Favicon:        item.Favicon,
-		Category:       item.Category,
-		Tags:           tags,
-		Hierarchy:      hierarchy,
-		HierarchyPath:  item.HierarchyPath,
-		HierarchyPaths: paths,
-		ContentText:    item.ContentText,
-		CapturedAt:     item.CapturedAt,
-		HTMLPath:       item.HTMLPath,
-		AssetsJSON:     json.RawMessage(item.AssetsJSON),
-		CreatedAt:      item.CreatedAt,
-		UpdatedAt:      item.UpdatedAt,
+		ID:              item.ID,
+		Slug:            item.Slug,
+		Title:           item.Title,
+		URL:             item.URL,
+		CanonicalURL:    item.CanonicalURL,
+		FinalURL:        item.FinalURL,
+		Redirects:       json.RawMessage(item.RedirectsJSON),
+		Domain:          item.Domain,
+		SiteName:        item.SiteName,
+		Byline:          item.Byline,
+		Excerpt:         item.Excerpt,
+		Favicon:         item.Favicon,
+		Category:        item.Category,
+		Tags:            tags,
+		Hierarchy:       hierarchy,
+		HierarchyPath:   item.HierarchyPath,
+		HierarchyPaths:  paths,
+		ContentText:     item.ContentText,
+		WordCount:       item.WordCount,
+		ReadMinutes:     item.ReadMinutes,
+		CapturedAt:      item.CapturedAt,
+		HTMLPath:        item.HTMLPath,
+		HTMLSHA256:      item.HTMLSHA256,
+		CaptureMode:     captureModeOf(item),
+		ScreenshotPath:  item.ScreenshotPath,
+		PrintPath:       item.PrintPath,
+		DarkPath:        item.DarkPath,
+		DocumentPath:    item.DocumentPath,
+		StorageTier:     item.StorageTier,
+		StorageBytes:    item.StorageBytes,
+		Encrypted:       item.Encrypted,
+		CapturedBy:      item.CapturedBy,
+		AssetsJSON:      json.RawMessage(item.AssetsJSON),
+		CaptureSource:   item.CaptureSource,
+		CaptureClient:   item.CaptureClient,
+		Provenance:      provenanceChain(item.ProvenanceJSON),
+		Published:       item.Published,
+		Watched:         item.Watched,
+		RecaptureDays:   item.RecaptureDays,
+		RecapturedAt:    item.RecapturedAt,
+		RemindAt:        item.RemindAt,
+		RemindNote:      item.RemindNote,
+		RemindedAt:      item.RemindedAt,
+		ClientIP:        item.ClientIP,
+		UserAgent:       item.UserAgent,
+		CreatedAt:       item.CreatedAt,
+		UpdatedAt:       item.UpdatedAt,
+		Structured:      structuredCard(item),
+		Completeness:    item.Completeness,
+		MissingAssets:   jsonStrings(item.MissingJSON),
+		Report:          processingReport(item.ReportJSON),
+		AITask:          aiTaskStatus(item),
+		SummaryOneLiner: item.SummaryOneLiner,
+		SummaryPoints:   jsonStrings(item.SummaryPointsJSON),
+		SummaryAbstract: item.SummaryAbstract,
 	}
 }
 
 func (s *Server) RegisterRoutes(r *gin.Engine) {
+	r.Use(s.viewerHost())
 	r.GET("/healthz", func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"ok": true}) })
+	r.GET("/readyz", s.readyz)
+	// Rewritten links inside archived pages point here.
+	r.GET("/archive/:id", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, "/api/archives/"+url.PathEscape(c.Param("id"))+"/html")
+	})
+
+	// The viewer origin; see viewerHost.
+	view := r.Group("/view/:ticket", s.requireReady())
+	view.GET("/archives/:id/html", s.viewArchiveHTML)
+	view.GET("/assets/:id/*path", s.viewAsset)
+	view.HEAD("/assets/:id/*path", s.viewAsset)
+
+	// Share-sheet captures answer with HTML, so they live outside /api. A
+	// GET capture must carry its token itself, never in the login cookie, so
+	// a link on another site can't capture as the signed-in user.
+	share := r.Group("/capture", s.requireReady())
+	share.GET("", s.authenticateExplicit(), s.requireRole(auth.RoleEditor), s.requireScope(auth.ScopeCapture, auth.ScopeWrite), s.shareCapture)
+	share.POST("", s.limitPayload(), s.authenticate(), s.requireRole(auth.RoleEditor), s.requireScope(auth.ScopeCapture, auth.ScopeWrite), s.shareCapture)
+	share.GET("/status/:id", s.authenticate(), s.requireRole(auth.RoleViewer), s.shareCaptureStatus)
+	s.registerWallabagRoutes(r)
+
+	// Feed readers can't send headers; they authenticate with ?access_token=.
+	feeds := r.Group("/feeds", s.requireReady(), s.authenticate(), s.requireRole(auth.RoleViewer), s.requireScope(auth.ScopeRead))
+	feeds.GET("/tag/:tag", s.tagFeed)
+	feeds.GET("/path/*path", s.pathFeed)
+
+	// Browser search integration; the UI does the searching.
+	r.GET("/opensearch.xml", s.openSearchDescription)
+	r.GET("/search", s.searchRedirect)
+
+	api := r.Group("/api", s.requireReady(), compressMiddleware(), projectFields(), s.resolveSlugs())
+	api.POST("/auth/login", s.login)
+	api.POST("/pair", s.pair)
+
+	public := api.Group("/public", s.requirePublic(), s.followAliases())
+	public.GET("/archives", s.publicArchives)
+	public.GET("/archives/:id", s.publicArchive)
+	public.GET("/archives/:id/html", s.publicArchiveHTML)
+	public.GET("/archives/:id/unfurl", s.publicArchiveUnfurl)
+	public.GET("/archives/:id/screenshot", s.publicScreenshot)
+	public.GET("/assets/:id/*path", s.publicAsset)
+	public.HEAD("/assets/:id/*path", s.publicAsset)
+	public.GET("/graph", s.cached(cache.Graph), s.publicGraph)
+
+	authed := api.Group("", s.authenticate())
+	authed.GET("/auth/me", s.me)
+	authed.POST("/auth/logout", s.logout)
+	authed.GET("/preferences", s.getPreferences)
+	authed.PATCH("/preferences", s.updatePreferences)
+	authed.GET("/notifications", s.listNotifications)
+	authed.POST("/notifications/read-all", s.readAllNotifications)
+	authed.POST("/notifications/:id/read", s.readNotification)
+
+	// Any authenticated user may manage their own tokens, but only with a
+	// full-access credential so a leaked scoped token can't mint new ones.
+	tokens := authed.Group("", s.requireScope(auth.ScopeAdmin))
+	tokens.GET("/tokens", s.listTokens)
+	tokens.POST("/tokens", s.createToken)
+	tokens.DELETE("/tokens/:id", s.deleteToken)
+	tokens.POST("/pair/codes", s.createPairingCode)
 
-	api := r.Group("/api")
-	api.POST("/archives", s.createArchive)
-	api.GET("/archives", s.listArchives)
-	api.GET("/archives/:id", s.getArchive)
-	api.PATCH("/archives/:id", s.updateArchive)
-	api.DELETE("/archives/:id", s.deleteArchive)
-	api.POST("/archives/:id/ai-tag", s.aiTagArchive)
-	api.POST("/ai/config", s.updateAIConfig)
-	api.POST("/ai/analyze/start", s.startAnalysis)
-	api.POST("/ai/analyze/stop", s.stopAnalysis)
-	api.GET("/ai/analyze/status", s.analysisStatus)
-	api.GET("/taxonomy", s.getTaxonomy)
-	api.GET("/taxonomy/:id", s.getTaxonomyNode)
-	api.GET("/graph", s.getGraph)
-	api.GET("/archives/:id/html", s.getArchiveHTML)
-	api.GET("/assets/:id/*path", s.getAsset)
+	viewer := authed.Group("", s.requireRole(auth.RoleViewer), s.requireScope(auth.ScopeRead), s.followAliases())
+	viewer.GET("/archives", s.listArchives)
+	viewer.GET("/search", s.searchArchives)
+	viewer.GET("/archives/:id", s.getArchive)
+	viewer.GET("/archives/:id/history", s.getArchiveHistory)
+	viewer.GET("/archives/:id/related", s.relatedArchives)
+	viewer.GET("/archives/:id/similar-content", s.similarContent)
+	viewer.GET("/archives/:id/fixity", s.checkArchiveFixity)
+	viewer.GET("/archives/:id/bagit", s.exportBagIt)
+	viewer.GET("/archives/:id/screenshot", s.getArchiveScreenshot)
+	viewer.GET("/archives/:id/unfurl", s.getArchiveUnfurl)
+	viewer.GET("/archives/:id/html", s.getArchiveHTML)
+	viewer.GET("/archives/:id/assets", s.getArchiveAssets)
+	viewer.GET("/archives/:id/toc", s.getArchiveTOC)
+	viewer.GET("/archives/:id/print", s.getArchivePrint)
+	viewer.GET("/archives/:id/flashcards", s.listFlashcards)
+	viewer.GET("/archives/:id/annotations", s.listAnnotations)
+	viewer.GET("/archives/:id/stances", s.getArchiveStances)
+	viewer.GET("/flashcards/export", s.exportFlashcards)
+	viewer.GET("/annotations/export", s.exportAnnotations)
+	viewer.POST("/archives/:id/read", s.markArchiveRead)
+	viewer.GET("/resurface", s.listResurface)
+	viewer.GET("/recent-views", s.listRecentViews)
+	viewer.GET("/assets/:id/*path", s.getAsset)
+	viewer.HEAD("/assets/:id/*path", s.getAsset)
+	viewer.GET("/taxonomy", s.cached(cache.Taxonomy), s.getTaxonomy)
+	viewer.GET("/taxonomy/health", s.getTaxonomyHealth)
+	viewer.GET("/taxonomy/seeds", s.listTaxonomySeeds)
+	viewer.GET("/taxonomy/:id", s.getTaxonomyNode)
+	viewer.GET("/taxonomy/:id/export", s.exportTaxonomyBranch)
+	viewer.GET("/graph", s.cached(cache.Graph), s.getGraph)
+	viewer.GET("/graph/neighbors", s.cached(cache.Graph), s.getGraphNeighbors)
+	viewer.GET("/graph/metrics", s.cached(cache.Graph), s.getGraphMetrics)
+	viewer.GET("/graph/snapshots", s.listGraphSnapshots)
+	viewer.GET("/graph/snapshots/:id", s.getGraphSnapshot)
+	viewer.GET("/graph/snapshots/:id/compare", s.compareGraphSnapshots)
+	viewer.GET("/notes", s.listNotes)
+	viewer.GET("/notes/:id", s.getNote)
+	viewer.GET("/ai/analyze/status", s.analysisStatus)
+	viewer.GET("/ai/queue", s.tagQueueStatus)
+	viewer.GET("/ai/status", s.aiStatus)
+	viewer.GET("/ai/embeddings/status", s.embeddingStatus)
+	viewer.GET("/ai/stances/status", s.stanceJobStatus)
+	viewer.GET("/fixity/status", s.fixityJobStatus)
+	viewer.GET("/search/semantic", s.semanticSearch)
+	viewer.GET("/graphql", s.graphqlQuery)
+	viewer.GET("/ws", s.liveEvents)
+	viewer.POST("/graphql", s.graphqlQuery)
+	viewer.GET("/clusters", s.listClusters)
+	viewer.GET("/timeline", s.getTimeline)
+	viewer.GET("/trends", s.getTrends)
+	viewer.GET("/stats/domains", s.domainStats)
+	viewer.GET("/sites", s.listSites)
+	viewer.GET("/sites/:domain", s.getSite)
+	viewer.GET("/authors", s.listAuthors)
+	viewer.GET("/authors/:name", s.getAuthor)
+	viewer.GET("/archives/:id/prices", s.getArchivePrices)
+	viewer.GET("/archives/:id/changes", s.getArchiveChanges)
+	viewer.GET("/archives/:id/compare-live", s.compareLive)
+	viewer.GET("/archives/:id/ai-trace", s.getArchiveAITrace)
+	viewer.GET("/archives/:id/versions", s.listArchiveVersions)
+	viewer.GET("/versions/:id/html", s.getVersionHTML)
+	viewer.GET("/ai/export", s.exportAIMetadata)
+	viewer.GET("/dashboard", s.getDashboard)
+	viewer.GET("/quota", s.quotaStatus)
+	viewer.POST("/dashboard/pins", s.createDashboardPin)
+	viewer.PATCH("/dashboard/pins/:id", s.updateDashboardPin)
+	viewer.DELETE("/dashboard/pins/:id", s.deleteDashboardPin)
+	viewer.GET("/collections/:id/export", s.exportCollection)
+	viewer.GET("/digests", s.listDigests)
+	viewer.GET("/digests/:id", s.getDigest)
+	viewer.GET("/clusters/:id", s.getCluster)
+	viewer.GET("/quick/:id", s.quickCaptureStatus)
+	viewer.GET("/quick-search", s.cached(cache.Search), s.quickSearch)
+
+	// The extension bootstraps from these, so capture-only tokens can read them.
+	bootstrap := authed.Group("", s.requireRole(auth.RoleViewer), s.requireScope(auth.ScopeRead, auth.ScopeCapture))
+	bootstrap.GET("/client/config", s.cached(cache.ClientConfig), s.getClientConfig)
+	bootstrap.GET("/presets", s.listPresets)
+
+	capture := authed.Group("", s.requireRole(auth.RoleEditor), s.requireScope(auth.ScopeCapture, auth.ScopeWrite), s.limitPayload())
+	capture.POST("/archives", s.createArchive)
+	capture.POST("/archives/:id/ai-tag", s.aiTagArchive)
+	capture.POST("/archives/:id/summarize", s.summarizeArchiveHandler)
+	capture.POST("/quick", s.quickCapture)
+	// PDF uploads are bounded by pdfMaxBytes instead of MAX_PAYLOAD_BYTES.
+	upload := authed.Group("", s.requireRole(auth.RoleEditor), s.requireScope(auth.ScopeCapture, auth.ScopeWrite))
+	upload.POST("/archives/upload", s.uploadArchive)
+
+	editor := authed.Group("", s.requireRole(auth.RoleEditor), s.requireScope(auth.ScopeWrite))
+	editor.PATCH("/archives/:id", s.updateArchive)
+	editor.DELETE("/archives/:id", s.deleteArchive)
+	editor.POST("/archives/:id/merge", s.mergeArchive)
+	editor.POST("/import/warc", s.importWARC)
+	editor.POST("/import/annotations", s.importAnnotations)
+	editor.POST("/import/bookmarks", s.importBookmarks)
+	editor.GET("/import/bookmarks/status", s.bookmarkImportStatus)
+	editor.POST("/import/bookmarks/stop", s.stopBookmarkImport)
+	editor.POST("/archives/:id/paths", s.addArchivePath)
+	editor.DELETE("/archives/:id/paths", s.removeArchivePath)
+	editor.POST("/archives/:id/structured", s.extractArchiveStructured)
+	editor.POST("/archives/:id/repair", s.repairArchive)
+	editor.POST("/archives/:id/print", s.renderArchivePrint)
+	editor.POST("/archives/:id/recapture", s.recaptureArchiveNow)
+	editor.POST("/archives/:id/send-to-device", s.sendArchiveToDevice)
+	editor.POST("/archives/:id/encryption", s.encryptArchive)
+	editor.DELETE("/archives/:id/encryption", s.decryptArchive)
+	editor.POST("/collections/:id/send-to-device", s.sendCollectionToDevice)
+	editor.DELETE("/archives/:id/annotations/:annotationId", s.deleteAnnotation)
+	editor.POST("/taxonomy/:id/move-archives", s.moveTaxonomyArchives)
+	editor.PATCH("/taxonomy/:id", s.updateTaxonomyNode)
+	editor.POST("/taxonomy/:id/overview", s.taxonomyOverview)
+	editor.POST("/ai/queue/retry", s.retryTagQueue)
+	editor.POST("/ai/quiz", s.createQuiz)
+	editor.POST("/ai/import", s.invalidates(cache.Graph), s.importAIMetadata)
+	editor.POST("/presets", s.invalidates(cache.ClientConfig), s.createPreset)
+	editor.PATCH("/presets/:id", s.invalidates(cache.ClientConfig), s.updatePreset)
+	editor.DELETE("/presets/:id", s.invalidates(cache.ClientConfig), s.deletePreset)
+	editor.POST("/archives/:id/flashcards", s.generateFlashcards)
+	editor.DELETE("/flashcards/:id", s.deleteFlashcard)
+	editor.POST("/notes", s.invalidates(cache.Graph), s.createNote)
+	editor.PATCH("/notes/:id", s.invalidates(cache.Graph), s.updateNote)
+	editor.DELETE("/notes/:id", s.invalidates(cache.Graph), s.deleteNote)
+	editor.POST("/graph/snapshots", s.createGraphSnapshot)
+	editor.DELETE("/graph/snapshots/:id", s.deleteGraphSnapshot)
+
+	admin := authed.Group("", s.requireRole(auth.RoleAdmin), s.requireScope(auth.ScopeAdmin))
+	admin.POST("/ai/config", s.invalidates(cache.ClientConfig), s.updateAIConfig)
+	admin.GET("/settings", s.getRuntimeSettings)
+	admin.PATCH("/settings", s.updateRuntimeSettings)
+	admin.POST("/ai/analyze/start", s.startAnalysis)
+	admin.POST("/ai/analyze/stop", s.stopAnalysis)
+	admin.GET("/ai/proposals", s.listProposals)
+	admin.POST("/ai/evaluate", s.evaluateModels)
+	admin.POST("/ai/proposals/:id/apply", s.invalidates(cache.Graph), s.applyProposal)
+	admin.DELETE("/ai/proposals/:id", s.discardProposal)
+	admin.POST("/ai/embeddings/backfill", s.startEmbeddingBackfill)
+	admin.POST("/ai/embeddings/backfill/stop", s.stopEmbeddingBackfill)
+	admin.POST("/ai/stances/start", s.startStances)
+	admin.POST("/ai/stances/stop", s.stopStances)
+	admin.POST("/clusters/rebuild", s.startClustering)
+	admin.POST("/fixity/check", s.startFixityCheck)
+	admin.POST("/fixity/check/stop", s.stopFixityCheck)
+	admin.POST("/digests", s.createDigest)
+	admin.GET("/users", s.listUsers)
+	admin.POST("/users", s.createUser)
+	admin.PATCH("/users/:id", s.updateUser)
+	admin.DELETE("/users/:id", s.deleteUser)
+	admin.GET("/admin/audit", s.listAdminAudit)
+	admin.GET("/cookies", s.listDomainCookies)
+	admin.POST("/cookies", s.saveDomainCookies)
+	admin.DELETE("/cookies/:id", s.deleteDomainCookies)
+	admin.GET("/retention/rules", s.listRetentionRules)
+	admin.POST("/retention/rules", s.createRetentionRule)
+	admin.PATCH("/retention/rules/:id", s.updateRetentionRule)
+	admin.DELETE("/retention/rules/:id", s.deleteRetentionRule)
+	admin.GET("/retention/preview", s.previewRetention)
+	admin.POST("/retention/run", s.runRetentionNow)
+	admin.GET("/recapture/rules", s.listRecaptureRules)
+	admin.POST("/recapture/rules", s.createRecaptureRule)
+	admin.PATCH("/recapture/rules/:id", s.updateRecaptureRule)
+	admin.DELETE("/recapture/rules/:id", s.deleteRecaptureRule)
+	admin.GET("/rules", s.listCaptureRules)
+	admin.POST("/rules", s.createCaptureRule)
+	admin.POST("/rules/test", s.testCaptureRules)
+	admin.PATCH("/rules/:id", s.updateCaptureRule)
+	admin.DELETE("/rules/:id", s.deleteCaptureRule)
+	admin.GET("/profile/export", s.exportProfile)
+	admin.POST("/profile/import", s.importProfile)
+	admin.GET("/recapture", s.recaptureJobStatus)
+	admin.POST("/recapture/run", s.runRecaptureNow)
+	admin.POST("/recapture/stop", s.stopRecapture)
+	admin.GET("/storage/tiering", s.tieringJobStatus)
+	admin.POST("/storage/tiering/run", s.runTieringNow)
+	admin.POST("/storage/tiering/stop", s.stopTiering)
+	admin.GET("/metadata/refresh", s.metaRefreshJobStatus)
+	admin.POST("/metadata/refresh/run", s.runMetaRefreshNow)
+	admin.POST("/metadata/refresh/stop", s.stopMetaRefresh)
+	admin.GET("/titles/cleanup", s.titleCleanupJobStatus)
+	admin.POST("/titles/cleanup/run", s.runTitleCleanupNow)
+	admin.POST("/titles/cleanup/stop", s.stopTitleCleanup)
+	admin.GET("/prices/track", s.priceTrackJobStatus)
+	admin.POST("/prices/track/run", s.runPriceTrackNow)
+	admin.POST("/prices/track/stop", s.stopPriceTrack)
+	admin.GET("/watch", s.watchJobStatus)
+	admin.POST("/watch/run", s.runWatchNow)
+	admin.POST("/watch/stop", s.stopWatch)
+	admin.POST("/resurface/rebuild", s.rebuildResurfaceNow)
+	admin.DELETE("/taxonomy/:id", s.deleteTaxonomySubtree)
+	admin.POST("/archives/bulk-delete", s.bulkDeleteArchives)
+	admin.POST("/taxonomy/prune", s.pruneTaxonomy)
+	admin.POST("/taxonomy/seed", s.seedTaxonomy)
+	admin.POST("/search/reindex", s.reindexSearch)
+	admin.GET("/admin/consistency", s.getConsistency)
+	admin.POST("/admin/consistency/repair", s.repairConsistency)
+	admin.GET("/admin/consistency/snapshots", s.listStatsSnapshots)
+	admin.POST("/admin/consistency/snapshots", s.takeStatsSnapshotNow)
+	admin.GET("/webhooks/taxonomy", s.listTaxonomyWebhooks)
+	admin.POST("/webhooks/taxonomy", s.createTaxonomyWebhook)
+	admin.DELETE("/webhooks/taxonomy/:id", s.deleteTaxonomyWebhook)
+}
+
+// limitPayload caps capture request bodies at MaxPayloadBytes, the limit
+// /api/client/config advertises.
+func (s *Server) limitPayload() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.MaxPayloadBytes <= 0 || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if c.Request.ContentLength > s.MaxPayloadBytes {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "payload too large"})
+			return
+		}
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, s.MaxPayloadBytes)
+		c.Next()
+	}
 }
 
 func (s *Server) createArchive(c *gin.Context) {
 	var req CreateArchiveRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "payload too large"})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
 		return
 	}
-	if req.URL == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "url required"})
+	archive, err := s.captureArchive(c.Request.Context(), req, captureMetaFrom(c))
+	var capErr *captureError
+	if errors.As(err, &capErr) {
+		c.JSON(capErr.status, gin.H{"error": capErr.msg})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "capture failed"})
+		return
+	}
+
+	resp := toArchiveResponse(archive, nil)
+	resp.DuplicateOf = s.duplicateArchiveIDs(archive.CanonicalURL, archive.ID)
+	c.JSON(http.StatusOK, resp)
+}
+
+// captureMeta describes who asked for a capture; it is recorded on the
+// archive and in its history.
+type captureMeta struct {
+	Actor     string
+	ClientIP  string
+	UserAgent string
+}
+
+func captureMetaFrom(c *gin.Context) captureMeta {
+	return captureMeta{Actor: currentPrincipal(c).Username, ClientIP: c.ClientIP(), UserAgent: c.Request.UserAgent()}
+}
+
+// captureError carries the HTTP status a failed capture should be reported
+// with.
+type captureError struct {
+	status int
+	msg    string
+}
+
+func (e *captureError) Error() string { return e.msg }
+
+// pageLimitError reports a page over the processor's parse limits: 413 when
+// it is too big, 422 when it is too deeply nested or complex.
+func pageLimitError(err error) *captureError {
+	status := http.StatusUnprocessableEntity
+	var limit *processor.LimitError
+	if errors.As(err, &limit) && limit.Limit == "bytes" {
+		status = http.StatusRequestEntityTooLarge
+	}
+	return &captureError{status: status, msg: "page rejected: " + err.Error()}
+}
+
+// captureArchive runs the whole capture pipeline for req: packaging or
+// fetching the page, storing HTML and assets, and inserting the archive.
+func (s *Server) captureArchive(ctx context.Context, req CreateArchiveRequest, meta captureMeta) (models.Archive, error) {
+	if req.URL == "" {
+		return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: "url required"}
+	}
+	if !validCaptureMode(req.Mode) {
+		return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: "mode must be full or metadata"}
+	}
+	if req.Encrypt && s.Store.Cipher == nil {
+		return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: "encryption not configured"}
+	}
+	if !processor.ValidProfile(req.Profile) {
+		return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: "profile must be email"}
+	}
+	provenance, err := normalizeProvenance(req.Provenance)
+	if err != nil {
+		return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: err.Error()}
+	}
+	hostRules := s.userHostRules(meta.Actor)
+	if u, err := url.Parse(req.URL); err == nil && !s.Processor.HostAllowed(u.Hostname(), hostRules) {
+		return models.Archive{}, &captureError{status: http.StatusForbidden, msg: "host not allowed for capture"}
+	}
+	if err := s.checkQuota(meta.Actor, 0); err != nil {
+		return models.Archive{}, err
+	}
+	var screenshot []byte
+	var screenshotType, screenshotName string
+	if req.Screenshot != "" {
+		var err error
+		screenshot, screenshotType, screenshotName, err = decodeScreenshot(req.Screenshot)
+		if err != nil {
+			return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: err.Error()}
+		}
+	}
+
+	id := uuid.New().String()
+	ctx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	defer cancel()
+	// The row is inserted last; until then ArchiveEncrypted learns from
+	// here that the objects must be sealed as they are written.
+	if req.Encrypt {
+		s.sealing.Store(id, true)
+		defer s.sealing.Delete(id)
+	}
 
 	html := req.HTML
+	var packaged *processor.Snapshot
+	switch req.SnapshotFormat {
+	case "":
+	case SnapshotSingleFile:
+		html = req.Snapshot
+	case SnapshotMHTML:
+		snap, err := processor.ParseMHTML([]byte(req.Snapshot))
+		if err != nil {
+			return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: "invalid mhtml: " + err.Error()}
+		}
+		packaged = snap
+		html = string(snap.HTML)
+	default:
+		return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: "snapshotFormat must be singlefile or mhtml"}
+	}
+	if req.SnapshotFormat != "" && html == "" {
+		return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: "snapshot required"}
+	}
+	// Text sent without a page is stored, and parsed, as the page.
 	if html == "" {
 		html = req.Content
 	}
+	// Submitted pages are checked before anything parses them; fetched
+	// ones once they arrive.
+	if html != "" {
+		if err := s.Processor.CheckHTML([]byte(html)); err != nil {
+			return models.Archive{}, pageLimitError(err)
+		}
+	}
+	if req.SnapshotFormat != "" && (req.Content == "" || req.Title == "") {
+		title, text := processor.ExtractText([]byte(html))
+		if req.Title == "" {
+			req.Title = title
+		}
+		if req.Content == "" {
+			req.Content = text
+		}
+	}
+	jar, err := s.captureJar(req.URL, req.Cookies)
+	if err != nil {
+		return models.Archive{}, &captureError{status: http.StatusInternalServerError, msg: "load cookies failed"}
+	}
+	fetchOpts := processor.Options{Jar: jar, IgnoreRobots: req.IgnoreRobots, Unthrottled: req.Unthrottled, HostRules: hostRules}
+	baseURL := req.URL
+	if req.SnapshotFormat != "" {
+		fetchOpts.Offline = true
+	}
+	if packaged != nil {
+		fetchOpts.Resources = packaged.Resources
+		if packaged.Location != "" {
+			baseURL = packaged.Location
+		}
+	}
+	var page *processor.Page
+	// Without any HTML the server fetches the page itself, following
+	// redirects so the archive is keyed to the final URL.
 	if html == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "html required"})
-		return
+		fetched, err := s.Processor.FetchPage(ctx, req.URL, fetchOpts)
+		if errors.Is(err, processor.ErrRobotsDisallowed) {
+			return models.Archive{}, &captureError{status: http.StatusForbidden, msg: "url disallowed by robots.txt"}
+		}
+		if errors.Is(err, processor.ErrHostNotAllowed) {
+			return models.Archive{}, &captureError{status: http.StatusForbidden, msg: "redirected to a host not allowed for capture"}
+		}
+		if err != nil {
+			return models.Archive{}, &captureError{status: http.StatusBadGateway, msg: "fetch failed: " + err.Error()}
+		}
+		if err := s.Processor.CheckHTML(fetched.HTML); err != nil {
+			return models.Archive{}, pageLimitError(err)
+		}
+		page = fetched
+		html = string(page.HTML)
+		baseURL = page.FinalURL
+		title, text := processor.ExtractText(page.HTML)
+		if req.Title == "" {
+			req.Title = title
+		}
+		req.Content = text
+		if req.Source == "" {
+			req.Source = "server-fetch"
+		}
+	}
+	extractedBy := ""
+	if html != req.Content {
+		extractedBy = s.applySiteExtractor(ctx, &req, baseURL, []byte(html))
 	}
 
-	id := uuid.New().String()
-	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
-	defer cancel()
-
-	result, err := s.Processor.Process(ctx, id, req.URL, []byte(html))
+	rules := s.applyCaptureRules(&req, baseURL)
+	preset, err := s.findPreset(req.Preset)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "processing failed"})
-		return
+		return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: "unknown preset"}
+	}
+	opts := applyPreset(&req, preset)
+	if req.SummaryStyles, err = normalizeSummaryStyles(req.SummaryStyles); err != nil {
+		return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: err.Error()}
+	}
+	opts.Jar, opts.IgnoreRobots, opts.Unthrottled = fetchOpts.Jar, fetchOpts.IgnoreRobots, fetchOpts.Unthrottled
+	opts.Resources, opts.Offline = fetchOpts.Resources, fetchOpts.Offline
+	opts.HostRules = fetchOpts.HostRules
+	if req.Profile != "" {
+		opts.Profile = req.Profile
+	}
+	opts.BlockTrackers = s.BlockTrackers
+	if preset != nil && preset.BlockTrackers != nil {
+		opts.BlockTrackers = *preset.BlockTrackers
+	}
+	if req.BlockTrackers != nil {
+		opts.BlockTrackers = *req.BlockTrackers
+	}
+	removal, err := processor.ParseSelectors(req.RemoveSelectors)
+	if err != nil {
+		return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: "removeSelectors: " + err.Error()}
+	}
+	opts.RemoveSelectors = append(opts.RemoveSelectors, removal...)
+	if opts.Resources, err = attachmentResources(opts.Resources, req.Attachments); err != nil {
+		return models.Archive{}, &captureError{status: http.StatusBadRequest, msg: err.Error()}
+	}
+	if req.Mode != CaptureModeMetadata {
+		opts.ArchivedLinks = s.archivedLinks(processor.LinkURLs([]byte(html), baseURL))
 	}
 
-	htmlObject := storage.ArchivePrefix(id) + "/index.html"
-	if err := s.Store.PutBytes(ctx, htmlObject, result.HTML, "text/html; charset=utf-8"); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "store html failed"})
-		return
+	result := &processor.Result{Assets: []processor.Asset{}}
+	htmlPath := ""
+	textOnly := false
+	printPath, printBytes := "", int64(0)
+	if req.Mode != CaptureModeMetadata {
+		result, err = s.Processor.ProcessWithOptions(ctx, id, baseURL, []byte(html), opts)
+		var limit *processor.LimitError
+		if errors.As(err, &limit) {
+			return models.Archive{}, pageLimitError(err)
+		}
+		if err != nil {
+			s.discardArchiveObjects(id)
+			return models.Archive{}, &captureError{status: http.StatusInternalServerError, msg: "processing failed"}
+		}
+		// A CDN refusing most assets, typically behind a paywall, would leave
+		// a broken snapshot; the reader text the client sent is kept instead.
+		if assetsRefused(result) && page == nil && !opts.Offline && strings.TrimSpace(req.Content) != "" && req.Content != html {
+			log.Printf("capture %s: %d of %d assets refused, keeping text only", req.URL, result.AssetsDenied, result.AssetsRequested)
+			s.discardArchiveObjects(id)
+			result = &processor.Result{Assets: []processor.Asset{}, CanonicalURL: result.CanonicalURL}
+			textOnly = true
+		}
+	}
+	if req.Mode != CaptureModeMetadata && !textOnly {
+		htmlObject := storage.ArchivePrefix(id) + "/index.html"
+		if err := s.Store.PutBytes(ctx, htmlObject, result.HTML, "text/html; charset=utf-8"); err != nil {
+			s.discardArchiveObjects(id)
+			return models.Archive{}, &captureError{status: http.StatusInternalServerError, msg: "store html failed"}
+		}
+		htmlPath = "index.html"
+		// Mail clients rarely send a reader view; the cleaned message is the
+		// better source for the text than the raw HTML.
+		if opts.Profile == processor.ProfileEmail && (req.Content == "" || req.Content == html) {
+			title, text := processor.ExtractText(result.HTML)
+			if req.Title == "" {
+				req.Title = title
+			}
+			req.Content = text
+		}
+		if req.Print {
+			if page, err := processor.PrintVariant(result.HTML); err == nil {
+				if err := s.Store.PutBytes(ctx, storage.ArchivePrefix(id)+"/"+printObject, page, "text/html; charset=utf-8"); err == nil {
+					printPath = printObject
+					printBytes = int64(len(page))
+				}
+			}
+		}
+	}
+	if screenshot != nil {
+		if err := s.Store.PutBytes(ctx, storage.ArchivePrefix(id)+"/"+screenshotName, screenshot, screenshotType); err != nil {
+			s.discardArchiveObjects(id)
+			return models.Archive{}, &captureError{status: http.StatusInternalServerError, msg: "store screenshot failed"}
+		}
+	}
+	// The capture is measured once stored, so it is removed again when it
+	// would not fit.
+	stored := captureBytes(result.HTML, result.Assets, screenshot) + printBytes
+	if err := s.checkQuota(meta.Actor, stored); err != nil {
+		s.discardArchiveObjects(id)
+		return models.Archive{}, err
 	}
 
+	req.Title = s.cleanCaptureTitle(req.Title, req.SiteName, baseURL)
+	s.scrubText(&req.Title, &req.Content, &req.Excerpt, &req.Byline)
 	assetsJSON, _ := json.Marshal(result.Assets)
 	if req.Tags == nil {
 		req.Tags = []string{}
@@ -184,6 +966,11 @@ func (s *Server) createArchive(c *gin.Context) {
 	if req.Hierarchy == nil {
 		req.Hierarchy = []string{}
 	}
+	limits := s.taxonomyLimits()
+	req.Hierarchy = limits.clamp(req.Hierarchy)
+	for i, p := range req.HierarchyPaths {
+		req.HierarchyPaths[i] = limits.clampPath(p)
+	}
 	if len(req.HierarchyPaths) == 0 && len(req.Hierarchy) > 0 {
 		req.HierarchyPaths = []string{strings.Join(req.Hierarchy, "/")}
 	}
@@ -200,58 +987,166 @@ func (s *Server) createArchive(c *gin.Context) {
 	}
 
 	archive := models.Archive{
-		ID:            id,
-		Title:         req.Title,
-		URL:           req.URL,
-		SiteName:      req.SiteName,
-		Byline:        req.Byline,
-		Excerpt:       req.Excerpt,
-		Favicon:       req.Favicon,
-		Category:      req.Category,
-		TagsJSON:      tagsJSON,
-		HierarchyJSON: hierarchyJSON,
-		HierarchyPath: hierarchyPath,
-		ContentText:   req.Content,
-		CapturedAt:    req.CapturedAt,
-		HTMLPath:      "index.html",
-		AssetsJSON:    assetsJSON,
-	}
-
-	if err := s.DB.Create(&archive).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
-		return
+		ID:             id,
+		Title:          req.Title,
+		URL:            req.URL,
+		SiteName:       req.SiteName,
+		Byline:         req.Byline,
+		Excerpt:        req.Excerpt,
+		Favicon:        req.Favicon,
+		Category:       req.Category,
+		StorageBytes:   stored,
+		Encrypted:      req.Encrypt,
+		CapturedBy:     meta.Actor,
+		TagsJSON:       tagsJSON,
+		HierarchyJSON:  hierarchyJSON,
+		HierarchyPath:  hierarchyPath,
+		ContentText:    req.Content,
+		CapturedAt:     req.CapturedAt,
+		HTMLPath:       htmlPath,
+		ScreenshotPath: screenshotName,
+		PrintPath:      printPath,
+		CaptureMode:    CaptureModeFull,
+		AssetsJSON:     assetsJSON,
+		CaptureSource:  captureSource(req.Source),
+		CaptureClient:  truncate(strings.TrimSpace(req.Client), 255),
+		ProvenanceJSON: marshalProvenance(provenance),
+		ClientIP:       meta.ClientIP,
+		UserAgent:      truncate(meta.UserAgent, 512),
 	}
+	switch {
+	case htmlPath != "":
+		archive.HTMLSHA256 = contentHash(string(result.HTML))
+	case textOnly:
+		archive.CaptureMode = CaptureModeTextOnly
+	default:
+		archive.CaptureMode = CaptureModeMetadata
+	}
+	applyContentStats(&archive)
+	setCompleteness(&archive, result.Assets, result.Missing)
+	setStructured(&archive, processor.DetectStructured([]byte(html)))
+	if htmlPath != "" {
+		result.Report.Extractor = extractedBy
+		archive.ReportJSON, _ = json.Marshal(result.Report)
+	}
+	canonical := result.CanonicalURL
+	if canonical == "" {
+		canonical = baseURL
+	}
+	if page != nil {
+		archive.FinalURL = truncate(page.FinalURL, 2000)
+		archive.RedirectsJSON, _ = json.Marshal(page.Redirects)
+	}
+	archive.CanonicalURL = truncate(processor.NormalizeURL(canonical), 2000)
+	archive.Domain = truncate(processor.URLDomain(archive.CanonicalURL), 255)
 
-	if len(req.HierarchyPaths) > 0 {
-		_ = s.replaceArchivePaths(archive.ID, req.HierarchyPaths)
-	} else if len(req.Hierarchy) > 0 {
-		_ = s.replaceArchivePaths(archive.ID, []string{strings.Join(req.Hierarchy, "/")})
-	} else if req.Category != "" {
-		_ = s.replaceArchivePaths(archive.ID, []string{req.Category})
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&archive).Error; err != nil {
+			return err
+		}
+		if err := createCaptureAnnotations(tx, archive, req.Annotations, meta.Actor); err != nil {
+			return err
+		}
+		if err := linkArchiveAuthors(tx, archive.ID, archive.Byline); err != nil {
+			return err
+		}
+		if len(req.HierarchyPaths) > 0 {
+			return replaceArchivePathsDB(tx, archive.ID, req.HierarchyPaths, limits)
+		} else if len(req.Hierarchy) > 0 {
+			return replaceArchivePathsDB(tx, archive.ID, []string{strings.Join(req.Hierarchy, "/")}, limits)
+		} else if req.Category != "" {
+			return replaceArchivePathsDB(tx, archive.ID, []string{req.Category}, limits)
+		}
+		return nil
+	})
+	if err != nil {
+		s.discardArchiveObjects(id)
+		return models.Archive{}, &captureError{status: http.StatusInternalServerError, msg: "db insert failed"}
 	}
+	s.recordArchiveEvent(archive.ID, EventCapture, meta.Actor, nil, s.snapshotArchive(archive))
+	s.slugAfterCapture(&archive)
 
-	if (req.AutoTag || s.AutoTag) && s.LLM != nil && s.LLM.Enabled() {
-		item := archive
-		go func() {
-			ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-			defer cancel()
-			_, _ = s.classifyArchive(ctx, item)
-		}()
+	if (req.AutoTag || s.autoTagOnCapture()) && !rules.SkipAI && s.LLM != nil && s.LLM.Enabled() && s.TagQueue != nil {
+		s.TagQueue.Enqueue(archive.ID, req.AutoTag)
+	}
+	if len(req.SummaryStyles) > 0 && s.LLM != nil && s.LLM.Enabled() {
+		go s.summarizeAfterCapture(archive, req.SummaryStyles)
 	}
+	if s.TitleCleanup && s.TitleCleanupLLM && !rules.SkipAI && processor.TitleHasSeparator(archive.Title) && s.LLM != nil && s.LLM.Enabled() {
+		go s.cleanTitleAfterCapture(archive)
+	}
+	return archive, nil
+}
 
-	c.JSON(http.StatusOK, toArchiveResponse(archive, nil))
+// assetsRefused reports a capture where at least half of a handful or more
+// of asset requests were answered 401 or 403.
+func assetsRefused(result *processor.Result) bool {
+	return result.AssetsRequested >= 5 && result.AssetsDenied*2 >= result.AssetsRequested
 }
 
-func (s *Server) listArchives(c *gin.Context) {
-	var items []models.Archive
+// duplicateArchiveIDs lists other archives captured from the same canonical
+// URL, so clients can tell the user the page was saved before.
+func (s *Server) duplicateArchiveIDs(canonicalURL, exclude string) []string {
+	ids := []string{}
+	if canonicalURL == "" {
+		return ids
+	}
+	_ = s.DB.Model(&models.Archive{}).Where("canonical_url = ? AND id <> ?", canonicalURL, exclude).
+		Order("created_at desc").Limit(20).Pluck("id", &ids).Error
+	return ids
+}
+
+// captureSource normalizes the client-declared origin of a capture. Known
+// values are extension, api, server-fetch and importer:<name>; anything empty
+// is treated as a plain API call.
+func captureSource(raw string) string {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		return "api"
+	}
+	return truncate(raw, 64)
+}
+
+// truncate clips s to max characters so it fits a varchar(max) column.
+func truncate(s string, max int) string {
+	return textutil.TruncateRunes(s, max)
+}
+
+// discardArchiveObjects is the compensation step for a failed capture: it
+// removes whatever the processor already uploaded for the archive. It runs on
+// a fresh context because the request context may be what just expired.
+func (s *Server) discardArchiveObjects(archiveID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.Store.RemovePrefix(ctx, storage.ArchivePrefix(archiveID)); err != nil {
+		log.Printf("cleanup archive %s objects failed: %v", archiveID, err)
+	}
+}
+
+// reader returns the handle for list, search and graph reads: the replica
+// when one is configured, otherwise the primary.
+func (s *Server) reader() *gorm.DB {
+	if s.ReadDB != nil {
+		return s.ReadDB
+	}
+	return s.DB
+}
+
+// filterArchives applies the archive list query parameters to db; the
+// search endpoint shares them.
+func (s *Server) filterArchives(db *gorm.DB, c *gin.Context) *gorm.DB {
 	query := c.Query("q")
 	category := c.Query("category")
 	tag := c.Query("tag")
+	source := c.Query("source")
 
-	db := s.DB
 	if query != "" {
-		like := "%" + query + "%"
-		db = db.Where("title LIKE ? OR url LIKE ? OR content_text LIKE ?", like, like, like)
+		if ids, ok := s.indexMatches(c, query); ok {
+			db = db.Where("id IN ?", ids)
+		} else {
+			cond, args := s.textCondition(query)
+			db = db.Where(cond, args...)
+		}
 	}
 	if category != "" {
 		db = db.Where("category = ?", category)
@@ -259,8 +1154,68 @@ func (s *Server) listArchives(c *gin.Context) {
 	if tag != "" {
 		db = db.Where("JSON_CONTAINS(tags_json, ?)", fmt.Sprintf("\"%s\"", tag))
 	}
+	if source != "" {
+		db = db.Where("capture_source = ?", source)
+	}
+	switch c.Query("mode") {
+	case CaptureModeMetadata, CaptureModeTextOnly:
+		db = db.Where("capture_mode = ?", c.Query("mode"))
+	case CaptureModeFull:
+		db = db.Where("capture_mode NOT IN ?", []string{CaptureModeMetadata, CaptureModeTextOnly})
+	}
+	if rawURL := c.Query("url"); rawURL != "" {
+		db = db.Where("canonical_url = ?", processor.NormalizeURL(rawURL))
+	}
+	if domain := c.Query("domain"); domain != "" {
+		db = db.Where("domain = ?", strings.TrimPrefix(strings.ToLower(domain), "www."))
+	}
+	if structured := c.Query("structured"); structured != "" {
+		db = db.Where("structured_type = ?", structured)
+	}
+	if broken, err := strconv.ParseBool(c.Query("broken")); err == nil {
+		if broken {
+			db = db.Where("completeness < ?", 100)
+		} else {
+			db = db.Where("completeness = ?", 100)
+		}
+	}
+	if watched, err := strconv.ParseBool(c.Query("watched")); err == nil {
+		db = db.Where("watched = ?", watched)
+	}
+	switch c.Query("reminder") {
+	case "overdue":
+		db = db.Where("remind_at <= ?", time.Now())
+	case "upcoming":
+		db = db.Where("remind_at > ?", time.Now())
+	case "set":
+		db = db.Where("remind_at IS NOT NULL")
+	}
+	if n, err := strconv.Atoi(c.Query("maxReadMinutes")); err == nil && n > 0 {
+		db = db.Where("read_minutes > 0 AND read_minutes <= ?", n)
+	}
+	if n, err := strconv.Atoi(c.Query("minReadMinutes")); err == nil && n > 0 {
+		db = db.Where("read_minutes >= ?", n)
+	}
+	if path := strings.Trim(c.Query("path"), "/"); path != "" {
+		db = db.Where("id IN (?)", s.DB.Model(&models.ArchivePath{}).Select("archive_id").
+			Where("path = ? OR path LIKE ?", path, path+"/%"))
+	}
+	if year, err := strconv.Atoi(c.Query("year")); err == nil && year > 0 {
+		db = db.Where("YEAR(COALESCE(captured_at, created_at)) = ?", year)
+	}
+	return db
+}
 
-	if err := db.Order("created_at desc").Find(&items).Error; err != nil {
+func (s *Server) listArchives(c *gin.Context) {
+	var items []models.Archive
+	db := s.filterArchives(s.reader(), c)
+	if !wantsField(c, "contentText", true) {
+		db = db.Omit("content_text")
+	}
+	if !wantsField(c, "summaryAbstract", true) {
+		db = db.Omit("summary_abstract")
+	}
+	if err := db.Order(archiveOrder(c.Query("sort"))).Find(&items).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
 		return
 	}
@@ -282,6 +1237,7 @@ func (s *Server) getArchive(c *gin.Context) {
 		return
 	}
 	paths, _ := s.loadArchivePaths(item.ID)
+	c.Header("ETag", archiveETag(item))
 	c.JSON(http.StatusOK, toArchiveResponse(item, paths))
 }
 
@@ -293,68 +1249,202 @@ func (s *Server) updateArchive(c *gin.Context) {
 	}
 
 	var current models.Archive
-	if err := s.DB.First(&current, "id = ?", c.Param("id")).Error; err == nil {
-		if req.Tags == nil && len(current.TagsJSON) > 0 {
-			_ = json.Unmarshal(current.TagsJSON, &req.Tags)
-		}
-		if req.Hierarchy == nil && len(current.HierarchyJSON) > 0 {
-			_ = json.Unmarshal(current.HierarchyJSON, &req.Hierarchy)
+	if err := s.DB.First(&current, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
 	}
-	if req.Tags == nil {
-		req.Tags = []string{}
+
+	if !archivePreconditionOK(c.GetHeader("If-Match"), req.UpdatedAt, current) {
+		paths, _ := s.loadArchivePaths(current.ID)
+		c.JSON(http.StatusConflict, gin.H{"error": "archive was modified", "current": toArchiveResponse(current, paths)})
+		return
 	}
-	if req.HierarchyPaths == nil {
-		req.HierarchyPaths = []string{}
+
+	before := s.snapshotArchive(current)
+	updates := map[string]any{}
+	if req.Category != nil {
+		updates["category"] = strings.TrimSpace(*req.Category)
 	}
-	if req.Hierarchy == nil {
-		req.Hierarchy = []string{}
+	if req.Published != nil {
+		updates["published"] = *req.Published
 	}
-	if len(req.HierarchyPaths) == 0 && len(req.Hierarchy) > 0 {
-		req.HierarchyPaths = []string{strings.Join(req.Hierarchy, "/")}
+	if req.Watched != nil {
+		updates["watched"] = *req.Watched
 	}
-	tagsJSON, _ := json.Marshal(req.Tags)
-	hierarchyJSON, _ := json.Marshal(req.Hierarchy)
-	hierarchyPath := strings.Join(req.Hierarchy, "/")
-	if hierarchyPath == "" && len(req.HierarchyPaths) > 0 {
-		hierarchyPath = req.HierarchyPaths[0]
-		hierarchyJSON, _ = json.Marshal(strings.Split(req.HierarchyPaths[0], "/"))
+	if req.RecaptureDays != nil {
+		if *req.RecaptureDays < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "recaptureDays must not be negative"})
+			return
+		}
+		updates["recapture_days"] = *req.RecaptureDays
 	}
-	if hierarchyPath == "" && req.Category != "" {
-		hierarchyPath = req.Category
-		hierarchyJSON, _ = json.Marshal([]string{req.Category})
+	if req.RemindAt != nil {
+		remindAt, err := parseTimelineDate(*req.RemindAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "remindAt must be an RFC 3339 time or YYYY-MM-DD"})
+			return
+		}
+		if remindAt.IsZero() {
+			updates["remind_at"] = nil
+		} else {
+			updates["remind_at"] = remindAt
+		}
+		// A new date re-arms a reminder that already fired.
+		updates["reminded_at"] = nil
+	}
+	if req.RemindNote != nil {
+		updates["remind_note"] = truncate(strings.TrimSpace(*req.RemindNote), 500)
+	}
+	if req.Slug != nil {
+		slug, ok := s.slugUpdate(c, *req.Slug, current.ID)
+		if !ok {
+			return
+		}
+		updates["slug"] = slug
 	}
 
-	if err := s.DB.Model(&models.Archive{}).
-		Where("id = ?", c.Param("id")).
-		Updates(map[string]any{
-			"category":       req.Category,
-			"tags_json":      tagsJSON,
-			"hierarchy_json": hierarchyJSON,
-			"hierarchy_path": hierarchyPath,
-		}).Error; err != nil {
+	if req.Tags != nil || len(req.AddTags) > 0 || len(req.RemoveTags) > 0 {
+		tags := []string{}
+		if req.Tags != nil {
+			tags = *req.Tags
+		} else if len(current.TagsJSON) > 0 {
+			_ = json.Unmarshal(current.TagsJSON, &tags)
+		}
+		tagsJSON, _ := json.Marshal(patchTags(tags, req.AddTags, req.RemoveTags))
+		updates["tags_json"] = tagsJSON
+	}
+
+	var newPaths []string
+	warnings := &[]string{}
+	if req.Hierarchy != nil || req.HierarchyPaths != nil {
+		var limits taxonomyLimits
+		limits, warnings = s.taxonomyLimits().reporting()
+		// Segments are trimmed and cleaned but not de-duplicated: a path may
+		// repeat a label at different levels.
+		hierarchy := []string{}
+		if req.Hierarchy != nil {
+			hierarchy = limits.clamp(*req.Hierarchy)
+		}
+		newPaths = []string{}
+		if req.HierarchyPaths != nil {
+			newPaths = normalizePaths(*req.HierarchyPaths)
+			for i, p := range newPaths {
+				newPaths[i] = limits.clampPath(p)
+			}
+		} else if len(hierarchy) > 0 {
+			newPaths = []string{strings.Join(hierarchy, "/")}
+		}
+		if len(hierarchy) == 0 && len(newPaths) > 0 {
+			hierarchy = strings.Split(newPaths[0], "/")
+		}
+		hierarchyJSON, _ := json.Marshal(hierarchy)
+		updates["hierarchy_json"] = hierarchyJSON
+		updates["hierarchy_path"] = strings.Join(hierarchy, "/")
+	} else if req.Category != nil && current.HierarchyPath == "" {
+		if category := strings.TrimSpace(*req.Category); category != "" {
+			hierarchyJSON, _ := json.Marshal([]string{category})
+			updates["hierarchy_json"] = hierarchyJSON
+			updates["hierarchy_path"] = category
+			newPaths = []string{category}
+		}
+	}
+
+	// The paths are only replaced if the conditional update went through,
+	// so a conflicting edit cannot leave them out of step with the row.
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			res := tx.Model(&models.Archive{}).
+				Where("id = ? AND updated_at = ?", current.ID, current.UpdatedAt).
+				Updates(updates)
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return errArchiveModified
+			}
+		}
+		if newPaths != nil {
+			return replaceArchivePathsDB(tx, current.ID, newPaths, s.taxonomyLimits())
+		}
+		return nil
+	})
+	if errors.Is(err, errArchiveModified) {
+		c.JSON(http.StatusConflict, gin.H{"error": "archive was modified"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
 		return
 	}
 
 	var updated models.Archive
-	if err := s.DB.First(&updated, "id = ?", c.Param("id")).Error; err == nil {
-		if len(req.HierarchyPaths) > 0 {
-			_ = s.replaceArchivePaths(updated.ID, req.HierarchyPaths)
-		} else if len(req.Hierarchy) > 0 {
-			_ = s.replaceArchivePaths(updated.ID, []string{strings.Join(req.Hierarchy, "/")})
-		} else if req.Category != "" {
-			_ = s.replaceArchivePaths(updated.ID, []string{req.Category})
-		}
-		paths, _ := s.loadArchivePaths(updated.ID)
-		c.JSON(http.StatusOK, toArchiveResponse(updated, paths))
+	if err := s.DB.First(&updated, "id = ?", current.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"ok": true})
+	s.recordArchiveEvent(updated.ID, EventEdit, currentPrincipal(c).Username, before, s.snapshotArchive(updated))
+	paths, _ := s.loadArchivePaths(updated.ID)
+	resp := toArchiveResponse(updated, paths)
+	resp.TaxonomyWarnings = *warnings
+	c.Header("ETag", archiveETag(updated))
+	c.JSON(http.StatusOK, resp)
+}
+
+var errArchiveModified = errors.New("archive was modified")
+
+func archiveETag(item models.Archive) string {
+	return fmt.Sprintf(`W/"%d"`, item.UpdatedAt.UnixMilli())
+}
+
+// archivePreconditionOK accepts either an If-Match header carrying the ETag
+// from GET, or the updatedAt value echoed back in the body. Requests that
+// send neither are treated as unconditional.
+func archivePreconditionOK(ifMatch string, updatedAt *time.Time, current models.Archive) bool {
+	if strings.TrimSpace(ifMatch) != "" {
+		want := strings.TrimPrefix(archiveETag(current), "W/")
+		for _, candidate := range strings.Split(ifMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == want {
+				return true
+			}
+		}
+		return false
+	}
+	if updatedAt != nil {
+		return updatedAt.UnixMilli() == current.UpdatedAt.UnixMilli()
+	}
+	return true
+}
+
+func patchTags(tags, add, remove []string) []string {
+	drop := map[string]bool{}
+	for _, t := range remove {
+		drop[strings.TrimSpace(t)] = true
+	}
+	out := []string{}
+	seen := map[string]bool{}
+	for _, t := range append(append([]string{}, tags...), add...) {
+		t = strings.TrimSpace(t)
+		if t == "" || seen[t] || drop[t] {
+			continue
+		}
+		seen[t] = true
+		out = append(out, t)
+	}
+	return out
 }
 
 func (s *Server) deleteArchive(c *gin.Context) {
 	id := c.Param("id")
+	scope := c.DefaultQuery("scope", DeleteScopeAll)
+	if !validDeleteScope(scope) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "scope must be all, snapshot or ai"})
+		return
+	}
 	var item models.Archive
 	if err := s.DB.First(&item, "id = ?", id).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -365,19 +1455,107 @@ func (s *Server) deleteArchive(c *gin.Context) {
 		return
 	}
 
-	if err := s.DB.Delete(&models.Archive{}, "id = ?", id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+	if err := s.deleteArchiveScoped(c.Request.Context(), item, currentPrincipal(c).Username, scope); err != nil {
+		if scope == DeleteScopeAll {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		}
 		return
 	}
-
-	_ = s.DB.Where("archive_id = ?", id).Delete(&models.ArchivePath{}).Error
-	_ = s.Store.RemovePrefix(c.Request.Context(), storage.ArchivePrefix(id))
 	c.JSON(http.StatusOK, gin.H{"ok": true})
 }
 
+func validDeleteScope(scope string) bool {
+	return scope == DeleteScopeAll || scope == DeleteScopeSnapshot || scope == DeleteScopeAI
+}
+
+func (s *Server) deleteArchiveScoped(ctx context.Context, item models.Archive, actor, scope string) error {
+	switch scope {
+	case DeleteScopeSnapshot:
+		return s.dropArchiveSnapshot(ctx, item, actor, EventDowngrade)
+	case DeleteScopeAI:
+		return s.clearArchiveAI(ctx, item, actor)
+	default:
+		return s.removeArchive(ctx, item, actor)
+	}
+}
+
+// removeArchive deletes an archive row and everything hanging off it: paths,
+// embeddings and stored objects. Only the row deletion is fatal.
+func (s *Server) removeArchive(ctx context.Context, item models.Archive, actor string) error {
+	before := s.snapshotArchive(item)
+	if err := s.DB.Delete(&models.Archive{}, "id = ?", item.ID).Error; err != nil {
+		return err
+	}
+	s.recordArchiveEvent(item.ID, EventDelete, actor, before, nil)
+
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.ArchivePath{}).Error
+	if s.LLM != nil && s.LLM.EmbeddingModel != "" {
+		_ = s.vectors().Delete(ctx, s.LLM.EmbeddingModel, []string{item.ID})
+	}
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.ArchiveEmbedding{}).Error
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.Flashcard{}).Error
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.AnalysisProposal{}).Error
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.AITrace{}).Error
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.PricePoint{}).Error
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.PageChange{}).Error
+	_ = s.pruneArchiveVersions(ctx, item.ID, 0)
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.ViewEvent{}).Error
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.ArchiveAlias{}).Error
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.Annotation{}).Error
+	_ = s.DB.Where("archive_id = ?", item.ID).Delete(&models.ArchiveAuthor{}).Error
+	_ = s.DB.Where("archive_a = ? OR archive_b = ?", item.ID, item.ID).Delete(&models.StanceCheck{}).Error
+	_ = s.Store.RemovePrefix(ctx, storage.ArchivePrefix(item.ID))
+	return nil
+}
+
+// maxHighlightQuery bounds the ?highlight= text, which becomes a regexp.
+const maxHighlightQuery = 200
+
+// getArchiveHTML serves the snapshot, or with ARCHIVE_VIEWER_ORIGIN set
+// redirects to it there; the view is recorded here either way, as the
+// viewer origin knows no users.
 func (s *Server) getArchiveHTML(c *gin.Context) {
-	id := c.Param("id")
-	objectPath := storage.ArchivePrefix(id) + "/index.html"
+	if !s.redirectToViewer(c, c.Param("id"), "/archives/", false) {
+		s.serveArchiveHTML(c, c.Param("id"), false)
+	}
+	switch c.Writer.Status() {
+	case http.StatusOK, http.StatusNotModified, http.StatusFound:
+		s.recordView(c.Param("id"), currentPrincipal(c).Username)
+	}
+}
+
+// serveArchiveHTML streams the stored page, or with ?theme=dark its dark
+// variant. Its relative asset URLs resolve to the public asset route when
+// served from /api/public; public copies are revalidated on every load, so
+// unpublishing takes effect at once.
+func (s *Server) serveArchiveHTML(c *gin.Context, id string, public bool) {
+	name := "index.html"
+	switch c.Query("theme") {
+	case "":
+	case ThemeDark:
+		dark, err := s.darkVariant(c.Request.Context(), id)
+		if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, errNoSnapshot) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "render dark variant failed"})
+			return
+		}
+		name = dark
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "theme must be dark"})
+		return
+	}
+	s.serveArchivePage(c, id, name, public)
+}
+
+// serveArchivePage streams one of the archive's stored HTML documents, the
+// snapshot or a variant of it stored next to it.
+func (s *Server) serveArchivePage(c *gin.Context, id, name string, public bool) {
+	objectPath := storage.ArchivePrefix(id) + "/" + name
 	obj, err := s.Store.Get(c.Request.Context(), objectPath)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
@@ -385,14 +1563,83 @@ func (s *Server) getArchiveHTML(c *gin.Context) {
 	}
 	defer obj.Close()
 
+	stat, err := obj.Stat()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	// ?highlight= marks the query's terms in the served copy; each query
+	// is its own representation, so it gets its own ETag.
+	highlight := truncate(strings.TrimSpace(c.Query("highlight")), maxHighlightQuery)
+	etag := `"` + strings.Trim(stat.ETag, `"`) + `"`
+	if highlight != "" {
+		h := fnv.New64a()
+		h.Write([]byte(highlight))
+		etag = fmt.Sprintf(`"%s-hl-%x"`, strings.Trim(stat.ETag, `"`), h.Sum64())
+	}
+	// ?annotations=1 marks the quotes saved with the archive. They can be
+	// deleted, so that copy is revalidated rather than cached for good.
+	var quotes []processor.Quote
+	if !public && c.Query("annotations") == "1" {
+		if quotes, err = s.annotationQuotes(id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		h := fnv.New64a()
+		for _, q := range quotes {
+			h.Write([]byte(q.ID))
+		}
+		etag = fmt.Sprintf(`%s-an-%x"`, strings.TrimSuffix(etag, `"`), h.Sum64())
+	}
+	// The object is rewritten in place (integrity repair, asset URL
+	// migrations, encryption), so every copy is revalidated against the
+	// ETag; only public pages may be kept by shared caches.
+	c.Header("ETag", etag)
+	if public {
+		c.Header("Cache-Control", "no-cache")
+	} else {
+		c.Header("Cache-Control", "private, no-cache")
+	}
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
 	c.Header("Content-Type", "text/html; charset=utf-8")
-	c.Header("Content-Security-Policy", "default-src 'self' data: blob:; img-src 'self' data: blob:; style-src 'self' 'unsafe-inline' data:; font-src 'self' data:; media-src 'self' data:; script-src 'self' 'unsafe-inline'")
+	wantsDownload(c, func() string { return s.archiveHTMLFilename(id) })
+	c.Header("Content-Security-Policy", s.pageCSP(c))
+	if highlight != "" || len(quotes) > 0 {
+		page, err := io.ReadAll(obj)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "read failed"})
+			return
+		}
+		if marked, count, err := processor.MarkQuotes(page, quotes); err == nil {
+			page = marked
+			c.Header("X-Annotation-Count", strconv.Itoa(count))
+		}
+		if marked, count, err := processor.Highlight(page, highlight); err == nil {
+			page = marked
+			c.Header("X-Highlight-Count", strconv.Itoa(count))
+		}
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+		return
+	}
+	c.Header("Content-Length", strconv.FormatInt(stat.Size, 10))
 	c.Status(http.StatusOK)
 	_, _ = io.Copy(c.Writer, obj)
 }
 
 func (s *Server) getAsset(c *gin.Context) {
 	id := c.Param("id")
+	if s.redirectToViewer(c, id, "/assets/", false) {
+		return
+	}
+	// An asset opened on its own must not run as a document where the
+	// session cookie would be sent with its requests.
+	if onViewerOrigin(c) || s.AuthEnabled {
+		c.Header("Content-Security-Policy", viewerAssetCSP)
+	}
 	p := c.Param("path")
 	if len(p) > 0 && p[0] == '/' {
 		p = p[1:]
@@ -405,10 +1652,37 @@ func (s *Server) getAsset(c *gin.Context) {
 	}
 	defer obj.Close()
 
+	// Stat before writing anything, so a missing object is a 404 and the
+	// size is known: ServeContent then answers HEAD and Range requests with
+	// Content-Length, which media players rely on to probe and seek.
 	stat, err := obj.Stat()
-	if err == nil && stat.ContentType != "" {
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if stat.ContentType != "" {
 		c.Header("Content-Type", stat.ContentType)
 	}
-	c.Status(http.StatusOK)
-	_, _ = io.Copy(c.Writer, obj)
+	if stat.ETag != "" {
+		c.Header("ETag", `"`+strings.Trim(stat.ETag, `"`)+`"`)
+	}
+	wantsDownload(c, func() string { return s.assetFilename(id, p) })
+	http.ServeContent(c.Writer, c.Request, path.Base(p), stat.LastModified, obj)
+}
+
+func etagMatches(header, etag string) bool {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
 }
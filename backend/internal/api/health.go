@@ -0,0 +1,112 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DependencyStatus struct {
+	OK        bool   `json:"ok"`
+	LatencyMs int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+	Optional  bool   `json:"optional,omitempty"`
+}
+
+type ReadinessResponse struct {
+	OK           bool                        `json:"ok"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// SetReady flips the server in or out of degraded mode. DB, Store and
+// Processor must be assigned before the server is marked ready.
+func (s *Server) SetReady(ready bool) {
+	s.ready.Store(ready)
+}
+
+func (s *Server) Ready() bool {
+	return s.ready.Load()
+}
+
+func (s *Server) requireReady() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.Ready() {
+			c.Header("Retry-After", "5")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "dependencies unavailable"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// readyz pings each dependency in parallel with its own timeout. The LLM is
+// only checked when asked for (?llm=1) since it may be billed per call and
+// is not required for capture to work.
+func (s *Server) readyz(c *gin.Context) {
+	if !s.Ready() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"ok": false, "degraded": true})
+		return
+	}
+	checks := map[string]func(context.Context) error{
+		"mysql": func(ctx context.Context) error {
+			sqlDB, err := s.DB.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		},
+		"minio": s.Store.Ping,
+	}
+	if s.ReadDB != nil && s.ReadDB != s.DB {
+		checks["mysql-replica"] = func(ctx context.Context) error {
+			sqlDB, err := s.ReadDB.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		}
+	}
+	optional := map[string]bool{}
+	if c.Query("llm") == "1" && s.LLM != nil && s.LLM.Enabled() {
+		checks["llm"] = s.LLM.Ping
+		optional["llm"] = true
+	}
+
+	resp := ReadinessResponse{OK: true, Dependencies: map[string]DependencyStatus{}}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check func(context.Context) error) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(c.Request.Context(), 3*time.Second)
+			defer cancel()
+			start := time.Now()
+			err := check(ctx)
+			status := DependencyStatus{
+				OK:        err == nil,
+				LatencyMs: time.Since(start).Milliseconds(),
+				Optional:  optional[name],
+			}
+			if err != nil {
+				status.Error = err.Error()
+			}
+			mu.Lock()
+			resp.Dependencies[name] = status
+			if err != nil && !optional[name] {
+				resp.OK = false
+			}
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	if !resp.OK {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, resp)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"webarchive/internal/models"
+)
+
+const (
+	EventCapture   = "capture"
+	EventEdit      = "edit"
+	EventAITag     = "ai_tag"
+	EventAutoTag   = "auto_tag"
+	EventAnalyzer  = "analyzer"
+	EventBulk      = "bulk"
+	EventDelete    = "delete"
+	EventAIImport  = "ai_import"
+	EventDowngrade = "downgrade"
+	EventMerge     = "merge"
+)
+
+type archiveSnapshot struct {
+	Category       string   `json:"category"`
+	Tags           []string `json:"tags"`
+	Hierarchy      []string `json:"hierarchy"`
+	HierarchyPaths []string `json:"hierarchyPaths"`
+	Published      bool     `json:"published"`
+	Watched        bool     `json:"watched,omitempty"`
+}
+
+type ArchiveEventResponse struct {
+	ID        string           `json:"id"`
+	ArchiveID string           `json:"archiveId"`
+	Action    string           `json:"action"`
+	Actor     string           `json:"actor"`
+	Before    *archiveSnapshot `json:"before"`
+	After     *archiveSnapshot `json:"after"`
+	CreatedAt time.Time        `json:"createdAt"`
+}
+
+func (s *Server) snapshotArchive(item models.Archive) *archiveSnapshot {
+	resp := toArchiveResponse(item, nil)
+	if paths, err := s.loadArchivePaths(item.ID); err == nil {
+		resp.HierarchyPaths = paths
+	}
+	return &archiveSnapshot{
+		Category:       resp.Category,
+		Tags:           resp.Tags,
+		Hierarchy:      resp.Hierarchy,
+		HierarchyPaths: resp.HierarchyPaths,
+		Published:      item.Published,
+		Watched:        item.Watched,
+	}
+}
+
+func (s *Server) recordArchiveEvent(archiveID, action, actor string, before, after *archiveSnapshot) {
+	beforeJSON, _ := json.Marshal(before)
+	afterJSON, _ := json.Marshal(after)
+	event := models.ArchiveEvent{
+		ID:         uuid.New().String(),
+		ArchiveID:  archiveID,
+		Action:     action,
+		Actor:      actor,
+		BeforeJSON: beforeJSON,
+		AfterJSON:  afterJSON,
+	}
+	if err := s.DB.Create(&event).Error; err != nil {
+		log.Printf("record %s event for archive %s failed: %v", action, archiveID, err)
+	}
+
+	live := LiveArchiveUpdated
+	switch action {
+	case EventCapture:
+		live = LiveArchiveCreated
+	case EventDelete:
+		live = LiveArchiveDeleted
+	}
+	s.publishEvent(live, gin.H{"id": archiveID, "action": action, "actor": actor, "after": after})
+}
+
+func (s *Server) getArchiveHistory(c *gin.Context) {
+	var events []models.ArchiveEvent
+	if err := s.DB.Where("archive_id = ?", c.Param("id")).
+		Order("created_at desc").
+		Limit(parseLimit(c.Query("limit"), 100)).
+		Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	out := make([]ArchiveEventResponse, 0, len(events))
+	for _, e := range events {
+		out = append(out, toArchiveEventResponse(e))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func toArchiveEventResponse(e models.ArchiveEvent) ArchiveEventResponse {
+	resp := ArchiveEventResponse{
+		ID:        e.ID,
+		ArchiveID: e.ArchiveID,
+		Action:    e.Action,
+		Actor:     e.Actor,
+		CreatedAt: e.CreatedAt,
+	}
+	if len(e.BeforeJSON) > 0 {
+		_ = json.Unmarshal(e.BeforeJSON, &resp.Before)
+	}
+	if len(e.AfterJSON) > 0 {
+		_ = json.Unmarshal(e.AfterJSON, &resp.After)
+	}
+	return resp
+}
@@ -0,0 +1,343 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+const (
+	bookmarkMaxBytes = 32 << 20
+	bookmarkWorkers  = 4
+	// bookmarkFailures caps the failures kept on the status.
+	bookmarkFailures = 50
+)
+
+type BookmarkFolder struct {
+	Path      string `json:"path"`
+	Bookmarks int    `json:"bookmarks"`
+	Exists    bool   `json:"exists"`
+}
+
+// BookmarkImportPreview describes what confirming the upload would do.
+type BookmarkImportPreview struct {
+	Bookmarks int              `json:"bookmarks"`
+	Existing  int              `json:"existing"`
+	Folders   []BookmarkFolder `json:"folders"`
+	NewNodes  []string         `json:"newNodes"`
+}
+
+type BookmarkImportStatus struct {
+	Running    bool                `json:"running"`
+	Total      int                 `json:"total"`
+	Imported   int                 `json:"imported"`
+	Existing   int                 `json:"existing"`
+	Failed     int                 `json:"failed"`
+	Failures   []WARCImportFailure `json:"failures"`
+	NewNodes   []string            `json:"newNodes"`
+	StartedAt  *time.Time          `json:"startedAt,omitempty"`
+	FinishedAt *time.Time          `json:"finishedAt,omitempty"`
+}
+
+type bookmarkItem struct {
+	URL     string
+	Title   string
+	Path    string
+	Tags    []string
+	Source  string
+	AddedAt *time.Time
+}
+
+// bookmarkPath files a bookmark under root followed by its folders. skipTop
+// drops the browser's own top folder ("书签栏", "Bookmarks Toolbar"...).
+func bookmarkPath(b processor.Bookmark, root string, folders, skipTop bool, limits taxonomyLimits) string {
+	parts := []string{}
+	if root != "" {
+		parts = append(parts, root)
+	}
+	if folders {
+		names := b.Folders
+		if skipTop && len(names) > 0 {
+			names = names[1:]
+		}
+		for _, name := range names {
+			// A slash in a folder name would otherwise start a new level.
+			if name = strings.TrimSpace(strings.ReplaceAll(name, "/", "-")); name != "" {
+				parts = append(parts, name)
+			}
+		}
+	}
+	return limits.clampPath(strings.Join(parts, "/"))
+}
+
+// importBookmarks imports a browser bookmark export (Netscape HTML, as
+// written by Chrome, Firefox, Safari and Edge) or a Pocket export (its HTML,
+// or a .csv file). Each archive records where and when the link was saved
+// as its provenance. Form fields: file, path
+// (root under which everything is filed), folders=ignore to file every
+// bookmark directly under path instead of materializing the folder tree,
+// skipTop=true to drop the top-level browser folder, tags (comma
+// separated). Without confirm=true nothing is written and the response is a
+// preview of the folders and the taxonomy nodes they would create. On
+// confirm the nodes are created first, then the links are fetched in the
+// background; follow progress on /import/bookmarks/status.
+func (s *Server) importBookmarks(c *gin.Context) {
+	var quotaErr *captureError
+	if errors.As(s.checkQuota(currentPrincipal(c).Username, 0), &quotaErr) {
+		c.JSON(quotaErr.status, gin.H{"error": quotaErr.msg})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, bookmarkMaxBytes)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "read upload failed"})
+		return
+	}
+	defer f.Close()
+	parse := processor.ParseBookmarks
+	if strings.HasSuffix(strings.ToLower(fh.Filename), ".csv") {
+		parse = processor.ParsePocketCSV
+	}
+	bookmarks, err := parse(f)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bookmark file: " + err.Error()})
+		return
+	}
+
+	limits := s.taxonomyLimits()
+	root := limits.clampPath(strings.Trim(strings.TrimSpace(c.PostForm("path")), "/"))
+	folders := c.PostForm("folders") != "ignore"
+	skipTop := c.PostForm("skipTop") == "true"
+	tags := splitList(c.PostForm("tags"))
+
+	items := []bookmarkItem{}
+	counts := map[string]int{}
+	seen := map[string]bool{}
+	for _, b := range bookmarks {
+		canonical := processor.NormalizeURL(b.URL)
+		if seen[canonical] {
+			continue
+		}
+		seen[canonical] = true
+		path := bookmarkPath(b, root, folders, skipTop, limits)
+		if path != "" {
+			counts[path]++
+		}
+		items = append(items, bookmarkItem{URL: b.URL, Title: b.Title, Path: path, Tags: patchTags(tags, b.Tags, nil), Source: b.Source, AddedAt: b.AddedAt})
+	}
+
+	paths := make([]string, 0, len(counts))
+	prefixes := []string{}
+	for path := range counts {
+		paths = append(paths, path)
+		parts := strings.Split(path, "/")
+		for i := range parts {
+			prefixes = append(prefixes, strings.Join(parts[:i+1], "/"))
+		}
+	}
+	sort.Strings(paths)
+	var known []string
+	if len(prefixes) > 0 {
+		if err := s.DB.Model(&models.TaxonomyNode{}).Where("path IN ?", prefixes).Pluck("path", &known).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+	}
+	exists := map[string]bool{}
+	for _, p := range known {
+		exists[p] = true
+	}
+
+	if c.PostForm("confirm") != "true" {
+		preview := BookmarkImportPreview{Bookmarks: len(items), Folders: []BookmarkFolder{}, NewNodes: []string{}}
+		for _, path := range paths {
+			preview.Folders = append(preview.Folders, BookmarkFolder{Path: path, Bookmarks: counts[path], Exists: exists[path]})
+		}
+		added := map[string]bool{}
+		for _, p := range prefixes {
+			if !exists[p] && !added[p] {
+				added[p] = true
+				preview.NewNodes = append(preview.NewNodes, p)
+			}
+		}
+		sort.Strings(preview.NewNodes)
+		canonicals := make([]string, 0, len(seen))
+		for u := range seen {
+			canonicals = append(canonicals, u)
+		}
+		for start := 0; start < len(canonicals); start += 500 {
+			end := min(start+500, len(canonicals))
+			var n int64
+			if err := s.DB.Model(&models.Archive{}).Where("canonical_url IN ?", canonicals[start:end]).
+				Distinct("canonical_url").Count(&n).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+				return
+			}
+			preview.Existing += int(n)
+		}
+		c.JSON(http.StatusOK, preview)
+		return
+	}
+
+	s.bookmarkMu.Lock()
+	if s.bookmarkStatus.Running {
+		s.bookmarkMu.Unlock()
+		c.JSON(http.StatusConflict, gin.H{"error": "a bookmark import is already running"})
+		return
+	}
+	created := []string{}
+	if len(paths) > 0 {
+		err = s.DB.Transaction(func(tx *gorm.DB) error {
+			ids, err := taxonomyNodeIDsDB(tx, paths)
+			if err != nil {
+				return err
+			}
+			for path := range ids {
+				if !exists[path] {
+					created = append(created, path)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			s.bookmarkMu.Unlock()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+			return
+		}
+		sort.Strings(created)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	s.bookmarkCancel = cancel
+	s.bookmarkStatus = BookmarkImportStatus{Running: true, Total: len(items), Failures: []WARCImportFailure{}, NewNodes: created, StartedAt: &now}
+	s.bookmarkMu.Unlock()
+	if len(created) > 0 {
+		s.publishTaxonomyChanged("import")
+	}
+
+	go s.runBookmarkImport(ctx, items, captureMetaFrom(c))
+	c.JSON(http.StatusAccepted, s.getBookmarkStatus())
+}
+
+func (s *Server) getBookmarkStatus() BookmarkImportStatus {
+	s.bookmarkMu.Lock()
+	defer s.bookmarkMu.Unlock()
+	status := s.bookmarkStatus
+	status.Failures = append([]WARCImportFailure{}, status.Failures...)
+	return status
+}
+
+func (s *Server) bookmarkImportStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.getBookmarkStatus())
+}
+
+func (s *Server) stopBookmarkImport(c *gin.Context) {
+	s.bookmarkMu.Lock()
+	if s.bookmarkCancel != nil {
+		s.bookmarkCancel()
+		s.bookmarkCancel = nil
+	}
+	s.bookmarkMu.Unlock()
+	c.JSON(http.StatusOK, s.getBookmarkStatus())
+}
+
+// runBookmarkImport fetches each link server-side. Links already archived
+// are counted and left alone rather than captured again.
+func (s *Server) runBookmarkImport(ctx context.Context, items []bookmarkItem, meta captureMeta) {
+	defer func() {
+		now := time.Now()
+		s.bookmarkMu.Lock()
+		s.bookmarkStatus.Running = false
+		s.bookmarkStatus.FinishedAt = &now
+		s.bookmarkCancel = nil
+		failed, failures := s.bookmarkStatus.Failed, s.bookmarkStatus.Failures
+		s.bookmarkMu.Unlock()
+		if failed > 0 {
+			lines := make([]string, 0, len(failures))
+			for _, f := range failures {
+				lines = append(lines, f.URL+": "+f.Error)
+			}
+			s.notifyFailure(models.Notification{
+				Username: meta.Actor,
+				Kind:     NotifyImportFailed,
+				Title:    fmt.Sprintf("Bookmark import: %d of %d links failed", failed, len(items)),
+				Message:  strings.Join(lines, "\n"),
+			})
+		}
+	}()
+
+	queue := make(chan bookmarkItem)
+	var wg sync.WaitGroup
+	for i := 0; i < bookmarkWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range queue {
+				existing, err := s.importBookmark(ctx, item, meta)
+				s.bookmarkMu.Lock()
+				switch {
+				case err != nil:
+					s.bookmarkStatus.Failed++
+					if len(s.bookmarkStatus.Failures) < bookmarkFailures {
+						s.bookmarkStatus.Failures = append(s.bookmarkStatus.Failures, WARCImportFailure{URL: item.URL, Error: err.Error()})
+					}
+				case existing:
+					s.bookmarkStatus.Existing++
+				default:
+					s.bookmarkStatus.Imported++
+				}
+				s.bookmarkMu.Unlock()
+			}
+		}()
+	}
+	for _, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- item
+	}
+	close(queue)
+	wg.Wait()
+}
+
+func (s *Server) importBookmark(ctx context.Context, item bookmarkItem, meta captureMeta) (bool, error) {
+	var count int64
+	if err := s.DB.Model(&models.Archive{}).Where("canonical_url = ?", processor.NormalizeURL(item.URL)).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	system := ProvenanceBookmarks
+	if item.Source == processor.BookmarkSourcePocket {
+		system = ProvenancePocket
+	}
+	req := CreateArchiveRequest{
+		URL:        item.URL,
+		Title:      item.Title,
+		Tags:       item.Tags,
+		Source:     "importer:" + system,
+		Provenance: importedFrom(system, "", item.AddedAt),
+	}
+	if item.Path != "" {
+		req.HierarchyPaths = []string{item.Path}
+	}
+	_, err := s.captureArchive(ctx, req, meta)
+	return false, err
+}
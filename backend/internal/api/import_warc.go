@@ -0,0 +1,205 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+)
+
+// Every response in the file is held in memory while the pages are
+// processed, so uploads are capped.
+const warcMaxBytes = 512 << 20
+
+type WARCImportFailure struct {
+	URL   string `json:"url"`
+	Error string `json:"error"`
+}
+
+type WARCImportResponse struct {
+	Imported []string            `json:"imported"`
+	Existing int                 `json:"existing"`
+	Failed   []WARCImportFailure `json:"failed"`
+	// Skipped counts WARC records that could not be parsed.
+	Skipped int `json:"skipped"`
+}
+
+// importWARC ingests a WARC or WACZ file (ArchiveBox, browsertrix, wget
+// --warc-file...). Each HTML page becomes an archive captured at its
+// original WARC-Date, rendered offline from the responses in the file.
+// Optional form fields: path (taxonomy path), tags (comma separated).
+func (s *Server) importWARC(c *gin.Context) {
+	var quotaErr *captureError
+	if errors.As(s.checkQuota(currentPrincipal(c).Username, 0), &quotaErr) {
+		c.JSON(quotaErr.status, gin.H{"error": quotaErr.msg})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, warcMaxBytes)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "read upload failed"})
+		return
+	}
+	defer f.Close()
+
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is empty"})
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "read upload failed"})
+		return
+	}
+	var col *processor.WARCCollection
+	if bytes.Equal(magic, []byte("PK\x03\x04")) {
+		col, err = processor.ParseWACZ(f, fh.Size)
+	} else {
+		col, err = processor.ParseWARC(f)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid warc: " + err.Error()})
+		return
+	}
+
+	limits := s.taxonomyLimits()
+	path := limits.clampPath(strings.Trim(strings.TrimSpace(c.PostForm("path")), "/"))
+	tags := splitList(c.PostForm("tags"))
+	resp := WARCImportResponse{Imported: []string{}, Failed: []WARCImportFailure{}, Skipped: col.Skipped}
+	for _, page := range col.Pages {
+		if err := c.Request.Context().Err(); err != nil {
+			break
+		}
+		id, existing, err := s.importWARCPage(c, col, page, path, tags, limits)
+		switch {
+		case err != nil:
+			resp.Failed = append(resp.Failed, WARCImportFailure{URL: page.URL, Error: err.Error()})
+		case existing:
+			resp.Existing++
+		default:
+			resp.Imported = append(resp.Imported, id)
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// importWARCPage stores one page. A page whose URL was already archived at
+// the same capture time is reported as existing so re-imports are no-ops.
+func (s *Server) importWARCPage(c *gin.Context, col *processor.WARCCollection, page processor.WARCPage, path string, tags []string, limits taxonomyLimits) (string, bool, error) {
+	var capturedAt *time.Time
+	if !page.CapturedAt.IsZero() {
+		capturedAt = &page.CapturedAt
+		var count int64
+		err := s.DB.Model(&models.Archive{}).
+			Where("url = ? AND captured_at = ?", page.URL, page.CapturedAt).Count(&count).Error
+		if err != nil {
+			return "", false, err
+		}
+		if count > 0 {
+			return "", true, nil
+		}
+	}
+
+	id := uuid.New().String()
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+	result, err := s.Processor.ProcessWithOptions(ctx, id, page.URL, page.HTML, processor.Options{
+		Resources:     col.Resources,
+		Offline:       true,
+		ArchivedLinks: s.archivedLinks(processor.LinkURLs(page.HTML, page.URL)),
+	})
+	if err != nil {
+		s.discardArchiveObjects(id)
+		return "", false, err
+	}
+	if err := s.Store.PutBytes(ctx, storage.ArchivePrefix(id)+"/index.html", result.HTML, "text/html; charset=utf-8"); err != nil {
+		s.discardArchiveObjects(id)
+		return "", false, err
+	}
+	actor := currentPrincipal(c).Username
+	stored := captureBytes(result.HTML, result.Assets, nil)
+	if err := s.checkQuota(actor, stored); err != nil {
+		s.discardArchiveObjects(id)
+		return "", false, err
+	}
+
+	title, text := processor.ExtractText(page.HTML)
+	s.scrubText(&title, &text)
+	if title == "" {
+		title = page.URL
+	}
+	hierarchy := []string{}
+	if path != "" {
+		hierarchy = strings.Split(path, "/")
+	}
+	tagsJSON, _ := json.Marshal(tags)
+	hierarchyJSON, _ := json.Marshal(hierarchy)
+	assetsJSON, _ := json.Marshal(result.Assets)
+	canonical := result.CanonicalURL
+	if canonical == "" {
+		canonical = page.URL
+	}
+	archive := models.Archive{
+		ID:             id,
+		Title:          truncate(title, 500),
+		URL:            page.URL,
+		TagsJSON:       tagsJSON,
+		HierarchyJSON:  hierarchyJSON,
+		HierarchyPath:  path,
+		ContentText:    text,
+		CapturedAt:     capturedAt,
+		HTMLPath:       "index.html",
+		HTMLSHA256:     contentHash(string(result.HTML)),
+		CaptureMode:    CaptureModeFull,
+		AssetsJSON:     assetsJSON,
+		CaptureSource:  "importer:warc",
+		ProvenanceJSON: marshalProvenance(importedFrom(ProvenanceWARC, page.RecordID, capturedAt)),
+		CapturedBy:     actor,
+		StorageBytes:   stored,
+		ClientIP:       c.ClientIP(),
+		UserAgent:      truncate(c.Request.UserAgent(), 512),
+	}
+	applyContentStats(&archive)
+	setCompleteness(&archive, result.Assets, result.Missing)
+	setStructured(&archive, processor.DetectStructured(page.HTML))
+	archive.ReportJSON, _ = json.Marshal(result.Report)
+	archive.CanonicalURL = truncate(processor.NormalizeURL(canonical), 2000)
+	archive.Domain = truncate(processor.URLDomain(archive.CanonicalURL), 255)
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&archive).Error; err != nil {
+			return err
+		}
+		if path != "" {
+			return replaceArchivePathsDB(tx, archive.ID, []string{path}, limits)
+		}
+		return nil
+	})
+	if err != nil {
+		s.discardArchiveObjects(id)
+		return "", false, err
+	}
+	s.recordArchiveEvent(archive.ID, EventCapture, currentPrincipal(c).Username, nil, s.snapshotArchive(archive))
+	s.slugAfterCapture(&archive)
+	if s.autoTagOnCapture() && s.LLM != nil && s.LLM.Enabled() && s.TagQueue != nil {
+		s.TagQueue.Enqueue(archive.ID, false)
+	}
+	return id, false, nil
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+)
+
+type RepairResponse struct {
+	Completeness  int      `json:"completeness"`
+	Repaired      int      `json:"repaired"`
+	Missing       []string `json:"missing"`
+	TextRecovered bool     `json:"textRecovered"`
+}
+
+// completeness scores a capture out of 100: the share of its assets that
+// were stored counts for 60, having text and having a title 20 each.
+// Archives without assets, such as metadata-only ones, get the full 60.
+func completeness(assets []processor.Asset, missing []string, title, text string) int {
+	stored := map[string]bool{}
+	for _, a := range assets {
+		stored[a.Original] = true
+	}
+	score := 60.0
+	if total := len(stored) + len(missing); total > 0 {
+		score = 60 * float64(len(stored)) / float64(total)
+	}
+	if strings.TrimSpace(text) != "" {
+		score += 20
+	}
+	if strings.TrimSpace(title) != "" {
+		score += 20
+	}
+	return int(math.Floor(score))
+}
+
+func processingReport(raw []byte) *processor.Report {
+	if len(raw) == 0 {
+		return nil
+	}
+	var report processor.Report
+	if err := json.Unmarshal(raw, &report); err != nil {
+		return nil
+	}
+	return &report
+}
+
+func setCompleteness(item *models.Archive, assets []processor.Asset, missing []string) {
+	if missing == nil {
+		missing = []string{}
+	}
+	score := completeness(assets, missing, item.Title, item.ContentText)
+	item.Completeness = &score
+	item.MissingJSON, _ = json.Marshal(missing)
+}
+
+// repairArchive retries what the capture missed: assets that failed are
+// fetched again by re-processing the stored page, and missing text or
+// title is extracted from it, or from the live page when nothing is stored.
+func (s *Server) repairArchive(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+	pageURL := item.FinalURL
+	if pageURL == "" {
+		pageURL = item.URL
+	}
+	assets := []processor.Asset{}
+	if len(item.AssetsJSON) > 0 {
+		_ = json.Unmarshal(item.AssetsJSON, &assets)
+	}
+	missing := jsonStrings(item.MissingJSON)
+	page := s.storedPageHTML(ctx, item)
+	updates := map[string]any{}
+	resp := RepairResponse{}
+
+	if len(page) > 0 && len(missing) > 0 {
+		jar, err := s.captureJar(pageURL, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "load cookies failed"})
+			return
+		}
+		result, err := s.Processor.ProcessWithOptions(ctx, item.ID, pageURL, page, processor.Options{Jar: jar, HostRules: s.userHostRules(item.CapturedBy)})
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "processing failed"})
+			return
+		}
+		if len(result.Assets) > 0 {
+			if err := s.Store.PutBytes(ctx, storage.ArchivePrefix(item.ID)+"/index.html", result.HTML, "text/html; charset=utf-8"); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "store html failed"})
+				return
+			}
+			assets = append(assets, result.Assets...)
+			assetsJSON, _ := json.Marshal(assets)
+			updates["assets_json"] = assetsJSON
+			updates["html_sha256"] = contentHash(string(result.HTML))
+			stored := item.StorageBytes + captureBytes(result.HTML, result.Assets, nil) - int64(len(page))
+			if freed, err := s.dropDarkVariant(ctx, item); err == nil && item.DarkPath != "" {
+				updates["dark_path"] = ""
+				stored -= freed
+			}
+			updates["storage_bytes"] = stored
+			page = result.HTML
+		}
+		// CSS references are not revisited, so only those retried here can
+		// leave the list.
+		stillMissing := []string{}
+		for _, u := range missing {
+			retried := false
+			for _, a := range result.Assets {
+				if a.Original == u {
+					retried = true
+					break
+				}
+			}
+			if !retried {
+				stillMissing = append(stillMissing, u)
+			}
+		}
+		resp.Repaired = len(missing) - len(stillMissing)
+		missing = stillMissing
+	}
+
+	if strings.TrimSpace(item.ContentText) == "" || strings.TrimSpace(item.Title) == "" {
+		if len(page) == 0 {
+			fetchCtx, cancel := context.WithTimeout(ctx, time.Minute)
+			if live, err := s.Processor.FetchPage(fetchCtx, pageURL, processor.Options{HostRules: s.userHostRules(item.CapturedBy)}); err == nil {
+				page = live.HTML
+			}
+			cancel()
+		}
+		title, text := processor.ExtractText(page)
+		s.scrubText(&title, &text)
+		if strings.TrimSpace(item.Title) == "" && title != "" {
+			item.Title = truncate(title, 500)
+			updates["title"] = item.Title
+			resp.TextRecovered = true
+		}
+		if strings.TrimSpace(item.ContentText) == "" && strings.TrimSpace(text) != "" {
+			item.ContentText = text
+			applyContentStats(&item)
+			updates["content_text"] = item.ContentText
+			updates["word_count"] = item.WordCount
+			updates["read_minutes"] = item.ReadMinutes
+			updates["sim_hash"] = item.SimHash
+			updates["sim_band0"], updates["sim_band1"] = item.SimBand0, item.SimBand1
+			updates["sim_band2"], updates["sim_band3"] = item.SimBand2, item.SimBand3
+			resp.TextRecovered = true
+		}
+	}
+
+	setCompleteness(&item, assets, missing)
+	updates["completeness"] = *item.Completeness
+	updates["missing_json"] = item.MissingJSON
+	if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	s.publishEvent(LiveArchiveUpdated, gin.H{"id": item.ID, "action": "repair", "actor": currentPrincipal(c).Username})
+	resp.Completeness = *item.Completeness
+	resp.Missing = missing
+	if resp.Missing == nil {
+		resp.Missing = []string{}
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+
+	"webarchive/internal/models"
+)
+
+const (
+	// leaseTTL is how long a lease outlives its holder's last heartbeat, and
+	// so how long a crashed instance keeps others from taking the job over.
+	leaseTTL       = 30 * time.Second
+	leaseHeartbeat = 10 * time.Second
+)
+
+// instanceID names this process among the replicas sharing the database.
+func (s *Server) instanceID() string {
+	s.instanceOnce.Do(func() {
+		host, _ := os.Hostname()
+		s.instance = fmt.Sprintf("%s-%s", host, uuid.New().String()[:8])
+	})
+	return s.instance
+}
+
+// acquireLease takes the named lease unless another live instance holds it.
+func (s *Server) acquireLease(name string) (bool, error) {
+	now := time.Now()
+	if err := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Lease{Name: name, ExpiresAt: time.Unix(0, 0)}).Error; err != nil {
+		return false, err
+	}
+	res := s.DB.Model(&models.Lease{}).
+		Where("name = ? AND (holder = ? OR expires_at < ?)", name, s.instanceID(), now).
+		Updates(map[string]any{"holder": s.instanceID(), "expires_at": now.Add(leaseTTL), "stop_requested": false})
+	return res.RowsAffected == 1, res.Error
+}
+
+// renewLease extends a lease this instance holds. held is false once it
+// was lost, e.g. after a pause longer than leaseTTL let another instance
+// take it; stop reports a stop requested by another instance.
+func (s *Server) renewLease(name string) (held, stop bool, err error) {
+	res := s.DB.Model(&models.Lease{}).
+		Where("name = ? AND holder = ?", name, s.instanceID()).
+		Update("expires_at", time.Now().Add(leaseTTL))
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, false, res.Error
+	}
+	var lease models.Lease
+	if err := s.DB.First(&lease, "name = ?", name).Error; err != nil {
+		return true, false, err
+	}
+	return true, lease.StopRequested, nil
+}
+
+// releaseLease frees a lease this instance holds; its state stays.
+func (s *Server) releaseLease(name string) error {
+	return s.DB.Model(&models.Lease{}).
+		Where("name = ? AND holder = ?", name, s.instanceID()).
+		Updates(map[string]any{"holder": "", "expires_at": time.Unix(0, 0)}).Error
+}
+
+// saveLeaseState publishes the holder's state to the other instances.
+func (s *Server) saveLeaseState(name string, state any) error {
+	raw, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return s.DB.Model(&models.Lease{}).
+		Where("name = ? AND holder = ?", name, s.instanceID()).
+		Update("state", raw).Error
+}
+
+// requestLeaseStop asks the live holder of a lease to stop. It reports
+// whether there was one.
+func (s *Server) requestLeaseStop(name string) (bool, error) {
+	res := s.DB.Model(&models.Lease{}).
+		Where("name = ? AND holder <> '' AND expires_at >= ?", name, time.Now()).
+		Update("stop_requested", true)
+	return res.RowsAffected > 0, res.Error
+}
+
+// loadLease returns the lease and whether a live instance holds it.
+func (s *Server) loadLease(name string) (models.Lease, bool, error) {
+	var lease models.Lease
+	if err := s.DB.First(&lease, "name = ?", name).Error; err != nil {
+		return lease, false, err
+	}
+	return lease, lease.Holder != "" && time.Now().Before(lease.ExpiresAt), nil
+}
@@ -0,0 +1,33 @@
+package api
+
+import "log"
+
+// archivedLinks maps each normalized URL that already has an archive to the
+// most recent archive ID for it.
+func (s *Server) archivedLinks(urls []string) map[string]string {
+	out := map[string]string{}
+	const chunk = 500
+	for start := 0; start < len(urls); start += chunk {
+		end := start + chunk
+		if end > len(urls) {
+			end = len(urls)
+		}
+		var rows []struct {
+			ID           string
+			CanonicalURL string
+		}
+		err := s.DB.Table("archives").
+			Select("id, canonical_url").
+			Where("canonical_url IN ?", urls[start:end]).
+			Order("created_at asc").
+			Scan(&rows).Error
+		if err != nil {
+			log.Printf("archived link lookup failed: %v", err)
+			return out
+		}
+		for _, row := range rows {
+			out[row.CanonicalURL] = row.ID
+		}
+	}
+	return out
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+const metaRefreshBatchSize = 50
+
+type MetaRefreshStatus struct {
+	Running    bool       `json:"running"`
+	Pending    int64      `json:"pending"`
+	Checked    int        `json:"checked"`
+	Updated    int        `json:"updated"`
+	Failed     int        `json:"failed"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+// missingMeta selects archives with an empty title, site name or favicon.
+// force includes the ones an earlier pass already tried.
+func missingMeta(db *gorm.DB, force bool) *gorm.DB {
+	db = db.Model(&models.Archive{}).Where("title = '' OR site_name = '' OR favicon = ''")
+	if !force {
+		db = db.Where("meta_checked_at IS NULL")
+	}
+	return db
+}
+
+func (s *Server) metaRefreshJobStatus(c *gin.Context) {
+	status := s.getMetaRefreshStatus()
+	if err := missingMeta(s.DB, false).Count(&status.Pending).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// runMetaRefreshNow starts a pass; ?force=1 retries archives whose pages
+// had nothing to offer last time.
+func (s *Server) runMetaRefreshNow(c *gin.Context) {
+	s.startMetaRefresh(c.Query("force") == "1")
+	c.JSON(http.StatusOK, s.getMetaRefreshStatus())
+}
+
+func (s *Server) stopMetaRefresh(c *gin.Context) {
+	s.metaRefreshMu.Lock()
+	if s.metaRefreshCancel != nil {
+		s.metaRefreshCancel()
+		s.metaRefreshCancel = nil
+	}
+	s.metaRefreshMu.Unlock()
+	c.JSON(http.StatusOK, s.getMetaRefreshStatus())
+}
+
+func (s *Server) getMetaRefreshStatus() MetaRefreshStatus {
+	s.metaRefreshMu.Lock()
+	defer s.metaRefreshMu.Unlock()
+	return s.metaRefreshStatus
+}
+
+// startMetaRefresh launches a pass unless one is already running.
+func (s *Server) startMetaRefresh(force bool) {
+	s.metaRefreshMu.Lock()
+	defer s.metaRefreshMu.Unlock()
+	if s.metaRefreshStatus.Running {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	s.metaRefreshCancel = cancel
+	s.metaRefreshStatus = MetaRefreshStatus{Running: true, StartedAt: &now}
+	go s.runMetaRefresh(ctx, force)
+}
+
+// runMetaRefresh fetches each archive's page and fills in only the fields
+// that are empty, so nothing a user or the extension set is overwritten.
+// Every archive is marked as checked, including failures, so unreachable
+// pages are not fetched again on each pass.
+func (s *Server) runMetaRefresh(ctx context.Context, force bool) {
+	lastErr := ""
+	defer func() {
+		now := time.Now()
+		s.metaRefreshMu.Lock()
+		s.metaRefreshStatus.Running = false
+		s.metaRefreshStatus.FinishedAt = &now
+		s.metaRefreshStatus.LastError = lastErr
+		s.metaRefreshCancel = nil
+		s.metaRefreshMu.Unlock()
+		if lastErr != "" {
+			log.Printf("metadata refresh: %s", lastErr)
+		}
+	}()
+
+	lastID := ""
+	for {
+		var items []models.Archive
+		if err := missingMeta(s.DB, force).Select("id", "url", "final_url", "title", "site_name", "favicon", "captured_by").
+			Where("id > ?", lastID).Order("id asc").Limit(metaRefreshBatchSize).Find(&items).Error; err != nil {
+			lastErr = err.Error()
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		for _, item := range items {
+			if ctx.Err() != nil {
+				lastErr = "canceled"
+				return
+			}
+			updated, err := s.refreshArchiveMeta(ctx, item)
+			s.metaRefreshMu.Lock()
+			s.metaRefreshStatus.Checked++
+			if err != nil {
+				s.metaRefreshStatus.Failed++
+			} else if updated {
+				s.metaRefreshStatus.Updated++
+			}
+			s.metaRefreshMu.Unlock()
+		}
+		lastID = items[len(items)-1].ID
+	}
+}
+
+func (s *Server) refreshArchiveMeta(ctx context.Context, item models.Archive) (bool, error) {
+	pageURL := item.FinalURL
+	if pageURL == "" {
+		pageURL = item.URL
+	}
+	now := time.Now()
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	page, err := s.Processor.FetchPage(fetchCtx, pageURL, processor.Options{HostRules: s.userHostRules(item.CapturedBy)})
+	cancel()
+	if err != nil {
+		if ctx.Err() == nil {
+			_ = s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).UpdateColumn("meta_checked_at", now).Error
+		}
+		return false, err
+	}
+	meta := processor.ExtractMeta(page.HTML, page.FinalURL)
+	updates := map[string]any{}
+	if item.Title == "" && meta.Title != "" {
+		updates["title"] = truncate(s.cleanCaptureTitle(meta.Title, meta.SiteName, page.FinalURL), 500)
+	}
+	if item.SiteName == "" && meta.SiteName != "" {
+		updates["site_name"] = truncate(meta.SiteName, 255)
+	}
+	if item.Favicon == "" && meta.Favicon != "" {
+		updates["favicon"] = truncate(meta.Favicon, 2000)
+	}
+	if len(updates) == 0 {
+		return false, s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).UpdateColumn("meta_checked_at", now).Error
+	}
+	updates["meta_checked_at"] = now
+	if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).Updates(updates).Error; err != nil {
+		return false, err
+	}
+	s.publishEvent(LiveArchiveUpdated, gin.H{"id": item.ID, "action": "metadata", "actor": "metadata-refresh"})
+	return true, nil
+}
+
+// StartMetaRefreshScheduler runs a metadata pass every interval; 0 disables
+// it, leaving only manual runs.
+func (s *Server) StartMetaRefreshScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.startMetaRefresh(false)
+		}
+	}()
+}
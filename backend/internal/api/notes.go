@@ -0,0 +1,204 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+type NoteRequest struct {
+	Title      string   `json:"title"`
+	Body       string   `json:"body"`
+	ArchiveIDs []string `json:"archiveIds"`
+	Entities   []string `json:"entities"`
+}
+
+type NoteResponse struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	Body       string    `json:"body"`
+	ArchiveIDs []string  `json:"archiveIds"`
+	Entities   []string  `json:"entities"`
+	CreatedBy  string    `json:"createdBy"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+func toNoteResponse(n models.Note) NoteResponse {
+	return NoteResponse{
+		ID:         n.ID,
+		Title:      n.Title,
+		Body:       n.Body,
+		ArchiveIDs: noteArchiveIDs(n),
+		Entities:   noteEntities(n),
+		CreatedBy:  n.CreatedBy,
+		CreatedAt:  n.CreatedAt,
+		UpdatedAt:  n.UpdatedAt,
+	}
+}
+
+func noteArchiveIDs(n models.Note) []string {
+	ids := []string{}
+	if len(n.ArchiveIDsJSON) > 0 {
+		_ = json.Unmarshal(n.ArchiveIDsJSON, &ids)
+	}
+	return ids
+}
+
+func noteEntities(n models.Note) []string {
+	entities := []string{}
+	if len(n.EntitiesJSON) > 0 {
+		_ = json.Unmarshal(n.EntitiesJSON, &entities)
+	}
+	return entities
+}
+
+func (s *Server) listNotes(c *gin.Context) {
+	db := s.DB
+	if archiveID := c.Query("archive"); archiveID != "" {
+		db = db.Where("JSON_CONTAINS(archive_ids_json, JSON_QUOTE(?))", archiveID)
+	}
+	if entity := c.Query("entity"); entity != "" {
+		db = db.Where("JSON_CONTAINS(entities_json, JSON_QUOTE(?))", entity)
+	}
+	if q := c.Query("q"); q != "" {
+		like := "%" + q + "%"
+		db = db.Where("title LIKE ? OR body LIKE ?", like, like)
+	}
+	var notes []models.Note
+	if err := db.Order("updated_at desc").Find(&notes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	out := make([]NoteResponse, 0, len(notes))
+	for _, n := range notes {
+		out = append(out, toNoteResponse(n))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) getNote(c *gin.Context) {
+	var note models.Note
+	if err := s.DB.First(&note, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, toNoteResponse(note))
+}
+
+func (s *Server) createNote(c *gin.Context) {
+	var req NoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	note := models.Note{ID: uuid.New().String(), CreatedBy: currentPrincipal(c).Username}
+	if err := s.applyNoteRequest(&note, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.DB.Create(&note).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	c.JSON(http.StatusOK, toNoteResponse(note))
+}
+
+func (s *Server) updateNote(c *gin.Context) {
+	var req NoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	var note models.Note
+	if err := s.DB.First(&note, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err := s.applyNoteRequest(&note, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.DB.Save(&note).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, toNoteResponse(note))
+}
+
+func (s *Server) deleteNote(c *gin.Context) {
+	if err := s.DB.Delete(&models.Note{}, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// applyNoteRequest validates the request and copies it onto the note. Linked
+// archives must exist so the graph never points at nothing.
+func (s *Server) applyNoteRequest(note *models.Note, req NoteRequest) error {
+	title := strings.TrimSpace(req.Title)
+	if title == "" {
+		return errors.New("title required")
+	}
+	ids := patchTags(req.ArchiveIDs, nil, nil)
+	if len(ids) > 0 {
+		var found int64
+		if err := s.DB.Model(&models.Archive{}).Where("id IN ?", ids).Count(&found).Error; err != nil {
+			return err
+		}
+		if int(found) != len(ids) {
+			return errors.New("unknown archive id")
+		}
+	}
+	note.Title = truncate(title, 500)
+	note.Body = req.Body
+	note.ArchiveIDsJSON, _ = json.Marshal(ids)
+	note.EntitiesJSON, _ = json.Marshal(patchTags(req.Entities, nil, nil))
+	return nil
+}
+
+// noteGraph returns the note node and its edges to archives and entities.
+func noteGraph(n models.Note) (GraphNode, []GraphLink) {
+	node := GraphNode{ID: "note:" + n.ID, Label: n.Title, Group: "note", RefID: n.ID}
+	links := make([]GraphLink, 0)
+	for _, id := range noteArchiveIDs(n) {
+		links = append(links, GraphLink{Source: node.ID, Target: "arc:" + id, Value: 1, Type: "cites"})
+	}
+	for _, ent := range noteEntities(n) {
+		links = append(links, GraphLink{Source: node.ID, Target: "ent:" + ent, Value: 1, Type: "mentions"})
+	}
+	return node, links
+}
+
+// attachNotes adds notes that link to any node already in the graph, along
+// with those links.
+func (s *Server) attachNotes(nodes map[string]GraphNode, links []GraphLink) ([]GraphLink, error) {
+	var notes []models.Note
+	if err := s.DB.Order("updated_at desc").Find(&notes).Error; err != nil {
+		return links, err
+	}
+	for _, n := range notes {
+		node, noteLinks := noteGraph(n)
+		for _, link := range noteLinks {
+			if _, ok := nodes[link.Target]; !ok {
+				continue
+			}
+			nodes[node.ID] = node
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"webarchive/internal/models"
+	"webarchive/internal/notify"
+)
+
+const (
+	NotifyCaptureFailed = "capture_failed"
+	NotifyAutoTagFailed = "autotag_failed"
+	NotifyImportFailed  = "import_failed"
+
+	// notificationKeep is how long notifications are kept, read or not.
+	notificationKeep  = 90 * 24 * time.Hour
+	notificationLimit = 200
+)
+
+type NotificationList struct {
+	Unread        int64                 `json:"unread"`
+	Notifications []models.Notification `json:"notifications"`
+}
+
+// notifyFailure records a background failure for username and, when
+// configured, sends it on to the webhook and mail recipients. Delivery runs
+// in the background so the failing job isn't held up by a slow hook.
+func (s *Server) notifyFailure(n models.Notification) {
+	n.ID = uuid.New().String()
+	if n.Username == "" {
+		n.Username = localPrincipal.Username
+	}
+	n.URL = truncate(n.URL, 2000)
+	n.Title = truncate(n.Title, 500)
+	if err := s.DB.Create(&n).Error; err != nil {
+		log.Printf("notification %s for %s: %v", n.Kind, n.Username, err)
+		return
+	}
+	_ = s.DB.Where("created_at < ?", time.Now().Add(-notificationKeep)).Delete(&models.Notification{}).Error
+	if s.NotifyWebhookURL == "" && len(s.NotifyEmails) == 0 {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if s.NotifyWebhookURL != "" {
+			payload := gin.H{"type": "notification", "user": n.Username, "notification": n}
+			if n.ArchiveID != "" {
+				payload["archiveUrl"] = strings.TrimRight(s.BaseURL, "/") + "/archive/" + n.ArchiveID
+			}
+			if err := notify.Webhook(ctx, s.NotifyWebhookURL, payload); err != nil {
+				log.Printf("notification webhook: %v", err)
+			}
+		}
+		if len(s.NotifyEmails) > 0 && s.SMTP.Enabled() {
+			body := n.Message + "\n"
+			if n.URL != "" {
+				body = n.URL + "\n\n" + body
+			}
+			if err := notify.Email(s.SMTP, s.NotifyEmails, "WebArchive: "+n.Title, body); err != nil {
+				log.Printf("notification email: %v", err)
+			}
+		}
+	}()
+}
+
+// notifyAutoTagFailed is the tag queue's dead-letter hook; the archive's
+// capturer hears about it.
+func (s *Server) notifyAutoTagFailed(archiveID, reason string) {
+	var item models.Archive
+	if err := s.DB.Select("id", "title", "url", "captured_by").First(&item, "id = ?", archiveID).Error; err != nil {
+		return
+	}
+	title := item.Title
+	if title == "" {
+		title = item.URL
+	}
+	s.notifyFailure(models.Notification{
+		Username:  item.CapturedBy,
+		Kind:      NotifyAutoTagFailed,
+		ArchiveID: item.ID,
+		URL:       item.URL,
+		Title:     "Auto-tagging failed: " + title,
+		Message:   reason,
+	})
+}
+
+func (s *Server) listNotifications(c *gin.Context) {
+	user := currentPrincipal(c).Username
+	limit := parseLimit(c.Query("limit"), 50)
+	if limit == 0 || limit > notificationLimit {
+		limit = notificationLimit
+	}
+	resp := NotificationList{Notifications: []models.Notification{}}
+	if err := s.DB.Model(&models.Notification{}).Where("username = ? AND read_at IS NULL", user).Count(&resp.Unread).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	db := s.DB.Where("username = ?", user)
+	if unread, err := strconv.ParseBool(c.Query("unread")); err == nil && unread {
+		db = db.Where("read_at IS NULL")
+	}
+	if err := db.Order("created_at desc").Limit(limit).Find(&resp.Notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) readNotification(c *gin.Context) {
+	res := s.DB.Model(&models.Notification{}).
+		Where("id = ? AND username = ?", c.Param("id"), currentPrincipal(c).Username).
+		Where("read_at IS NULL").
+		UpdateColumn("read_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		var count int64
+		s.DB.Model(&models.Notification{}).Where("id = ? AND username = ?", c.Param("id"), currentPrincipal(c).Username).Count(&count)
+		if count == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *Server) readAllNotifications(c *gin.Context) {
+	res := s.DB.Model(&models.Notification{}).
+		Where("username = ? AND read_at IS NULL", currentPrincipal(c).Username).
+		UpdateColumn("read_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"read": res.RowsAffected})
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const openSearchTemplate = `<?xml version="1.0" encoding="UTF-8"?>
+<OpenSearchDescription xmlns="http://a9.com/-/spec/opensearch/1.1/" xmlns:moz="http://www.mozilla.org/2006/browser/search/">
+  <ShortName>WebArchive</ShortName>
+  <Description>Search archived pages</Description>
+  <InputEncoding>UTF-8</InputEncoding>
+  <Url type="text/html" method="get" template="%[1]s/search?q={searchTerms}"/>
+  <Url type="application/opensearchdescription+xml" rel="self" template="%[1]s/opensearch.xml"/>
+  <moz:SearchForm>%[1]s/</moz:SearchForm>
+</OpenSearchDescription>
+`
+
+// openSearchDescription lets browsers offer the archive as an address-bar
+// search engine. It describes no data, so it needs no login; the links use
+// the origin the browser came in on, like the feeds.
+func (s *Server) openSearchDescription(c *gin.Context) {
+	base := html.EscapeString(requestBaseURL(c))
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, "application/opensearchdescription+xml; charset=utf-8", []byte(fmt.Sprintf(openSearchTemplate, base)))
+}
+
+// searchRedirect is the descriptor's search URL: it hands the terms to the
+// web UI, which signs the user in if needed and runs the search.
+func (s *Server) searchRedirect(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.Redirect(http.StatusFound, "/")
+		return
+	}
+	c.Redirect(http.StatusFound, "/?"+url.Values{"q": {q}}.Encode())
+}
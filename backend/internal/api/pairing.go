@@ -0,0 +1,126 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/auth"
+	"webarchive/internal/models"
+)
+
+const pairingTTL = 10 * time.Minute
+
+type PairingCodeRequest struct {
+	// Scopes default to capture, which is all the extension needs.
+	Scopes []string `json:"scopes"`
+}
+
+type PairRequest struct {
+	Code   string `json:"code"`
+	Device string `json:"device"`
+}
+
+// createPairingCode issues a short code the user types into a device, so
+// the device can obtain its own token without copying a long API key.
+func (s *Server) createPairingCode(c *gin.Context) {
+	if !s.AuthEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "auth is disabled; devices need no token"})
+		return
+	}
+	var req PairingCodeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+	}
+	if len(req.Scopes) == 0 {
+		req.Scopes = []string{auth.ScopeCapture}
+	}
+	for _, scope := range req.Scopes {
+		if !auth.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "scopes must be read, capture, write or admin"})
+			return
+		}
+	}
+	code, err := auth.NewPairingCode()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "code generation failed"})
+		return
+	}
+	// Drop this user's stale codes while we are here.
+	_ = s.DB.Where("user_id = ? AND expires_at < ?", currentPrincipal(c).UserID, time.Now()).Delete(&models.PairingCode{}).Error
+	row := models.PairingCode{
+		ID:        uuid.New().String(),
+		UserID:    currentPrincipal(c).UserID,
+		CodeHash:  auth.HashToken(auth.NormalizePairingCode(code)),
+		Scopes:    strings.Join(req.Scopes, ","),
+		ExpiresAt: time.Now().Add(pairingTTL),
+	}
+	if err := s.DB.Create(&row).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"code": code, "scopes": req.Scopes, "expiresAt": row.ExpiresAt})
+}
+
+// pair exchanges a pairing code for a token. Codes work once and expire
+// after pairingTTL.
+func (s *Server) pair(c *gin.Context) {
+	var req PairRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if !s.AuthEnabled {
+		c.JSON(http.StatusOK, gin.H{"token": "", "authEnabled": false})
+		return
+	}
+	code := auth.NormalizePairingCode(req.Code)
+	if code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code required"})
+		return
+	}
+	device := strings.TrimSpace(req.Device)
+	if device == "" {
+		device = "device"
+	}
+
+	var row models.PairingCode
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&row, "code_hash = ? AND expires_at > ?", auth.HashToken(code), time.Now()).Error; err != nil {
+			return err
+		}
+		// Deleting first makes the code single-use even under concurrent claims.
+		res := tx.Delete(&models.PairingCode{}, "id = ?", row.ID)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired code"})
+		return
+	}
+	scopes := auth.ParseScopes(row.Scopes)
+	token, tokenRow, err := s.issueToken(row.UserID, truncate("paired: "+device, 128), scopes, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "token generation failed"})
+		return
+	}
+	// The route is unauthenticated; attribute the audit entry to the code's owner.
+	var user models.User
+	if err := s.DB.First(&user, "id = ?", row.UserID).Error; err == nil {
+		c.Set(principalKey, Principal{UserID: user.ID, Username: user.Username, Role: user.Role})
+	}
+	s.recordAdminAudit(c, AuditTokenCreate, tokenRow.ID, nil, toTokenResponse(tokenRow))
+	c.JSON(http.StatusOK, gin.H{"token": token, "scopes": scopes, "authEnabled": true})
+}
@@ -0,0 +1,228 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+)
+
+const (
+	pdfMaxBytes = 200 << 20
+	pdfObject   = "original.pdf"
+	pdfThumb    = "screenshot.png"
+)
+
+// uploadArchive archives an uploaded PDF (multipart field file). The
+// original is kept and served from the asset route, its text becomes the
+// archive's content and a reader page, and the first page is rendered as
+// the screenshot. Optional form fields: url (where the file came from),
+// title, path (taxonomy path), tags (comma separated), autoTag.
+func (s *Server) uploadArchive(c *gin.Context) {
+	meta := captureMetaFrom(c)
+	var quotaErr *captureError
+	if errors.As(s.checkQuota(meta.Actor, 0), &quotaErr) {
+		c.JSON(quotaErr.status, gin.H{"error": quotaErr.msg})
+		return
+	}
+	if c.Request.ContentLength > pdfMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "payload too large"})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, pdfMaxBytes)
+	fh, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "payload too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file required"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "read upload failed"})
+		return
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "read upload failed"})
+		return
+	}
+	if !processor.IsPDF(data) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "file must be a PDF"})
+		return
+	}
+	source := strings.TrimSpace(c.PostForm("url"))
+	if source != "" {
+		if u, err := url.Parse(source); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an http(s) link"})
+			return
+		}
+	}
+
+	// The poppler tools read from a file, not stdin.
+	tmp, err := os.CreateTemp("", "webarchive-*.pdf")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "store pdf failed"})
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "store pdf failed"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Minute)
+	defer cancel()
+	text, err := s.PDFTools.Text(ctx, tmp.Name())
+	if err != nil {
+		log.Printf("pdf upload %s: text extraction: %v", fh.Filename, err)
+	}
+	thumb, err := s.PDFTools.Thumbnail(ctx, tmp.Name())
+	if err != nil {
+		log.Printf("pdf upload %s: thumbnail: %v", fh.Filename, err)
+	}
+
+	title := strings.TrimSpace(c.PostForm("title"))
+	if title == "" {
+		title = processor.PDFTitle(data)
+	}
+	if title == "" {
+		for _, line := range strings.Split(text, "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				title = line
+				break
+			}
+		}
+	}
+	if title == "" {
+		title = strings.TrimSuffix(path.Base(fh.Filename), path.Ext(fh.Filename))
+	}
+	s.scrubText(&title, &text)
+	title = truncate(title, 500)
+
+	id := uuid.New().String()
+	prefix := storage.ArchivePrefix(id)
+	sum := sha256.Sum256(data)
+	original := source
+	if original == "" {
+		original = fh.Filename
+	}
+	assets := []processor.Asset{{Original: original, Stored: pdfObject, Type: "application/pdf", SHA256: hex.EncodeToString(sum[:]), Size: int64(len(data))}}
+	page := processor.PDFPage(title, text, processor.AssetPrefix(id)+pdfObject)
+	if err := s.Store.PutBytes(ctx, prefix+"/"+pdfObject, data, "application/pdf"); err != nil {
+		s.discardArchiveObjects(id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "store pdf failed"})
+		return
+	}
+	if err := s.Store.PutBytes(ctx, prefix+"/index.html", page, "text/html; charset=utf-8"); err != nil {
+		s.discardArchiveObjects(id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "store html failed"})
+		return
+	}
+	screenshotPath := ""
+	if len(thumb) > 0 {
+		if err := s.Store.PutBytes(ctx, prefix+"/"+pdfThumb, thumb, "image/png"); err != nil {
+			s.discardArchiveObjects(id)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "store screenshot failed"})
+			return
+		}
+		screenshotPath = pdfThumb
+	}
+	stored := captureBytes(page, assets, thumb)
+	if err := s.checkQuota(meta.Actor, stored); err != nil {
+		s.discardArchiveObjects(id)
+		var capErr *captureError
+		if errors.As(err, &capErr) {
+			c.JSON(capErr.status, gin.H{"error": capErr.msg})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		}
+		return
+	}
+
+	limits := s.taxonomyLimits()
+	hierarchyPath := limits.clampPath(strings.Trim(strings.TrimSpace(c.PostForm("path")), "/"))
+	hierarchy := []string{}
+	if hierarchyPath != "" {
+		hierarchy = strings.Split(hierarchyPath, "/")
+	}
+	tagsJSON, _ := json.Marshal(splitList(c.PostForm("tags")))
+	hierarchyJSON, _ := json.Marshal(hierarchy)
+	assetsJSON, _ := json.Marshal(assets)
+	archive := models.Archive{
+		ID:             id,
+		Title:          title,
+		URL:            truncate(original, 2000),
+		TagsJSON:       tagsJSON,
+		HierarchyJSON:  hierarchyJSON,
+		HierarchyPath:  hierarchyPath,
+		ContentText:    strings.TrimSpace(strings.ReplaceAll(text, "\f", "\n\n")),
+		HTMLPath:       "index.html",
+		HTMLSHA256:     contentHash(string(page)),
+		ScreenshotPath: screenshotPath,
+		DocumentPath:   pdfObject,
+		CaptureMode:    CaptureModeFull,
+		AssetsJSON:     assetsJSON,
+		CaptureSource:  "upload",
+		CapturedBy:     meta.Actor,
+		StorageBytes:   stored,
+		ClientIP:       meta.ClientIP,
+		UserAgent:      truncate(meta.UserAgent, 512),
+	}
+	applyContentStats(&archive)
+	setCompleteness(&archive, assets, nil)
+	if source != "" {
+		archive.CanonicalURL = truncate(processor.NormalizeURL(source), 2000)
+		archive.Domain = truncate(processor.URLDomain(archive.CanonicalURL), 255)
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&archive).Error; err != nil {
+			return err
+		}
+		if hierarchyPath != "" {
+			return replaceArchivePathsDB(tx, archive.ID, []string{hierarchyPath}, limits)
+		}
+		return nil
+	})
+	if err != nil {
+		s.discardArchiveObjects(id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	s.recordArchiveEvent(archive.ID, EventCapture, meta.Actor, nil, s.snapshotArchive(archive))
+	s.slugAfterCapture(&archive)
+	autoTag := c.PostForm("autoTag") == "true"
+	if (autoTag || s.autoTagOnCapture()) && s.LLM != nil && s.LLM.Enabled() && s.TagQueue != nil {
+		s.TagQueue.Enqueue(archive.ID, autoTag)
+	}
+
+	resp := toArchiveResponse(archive, nil)
+	resp.DuplicateOf = s.duplicateArchiveIDs(archive.CanonicalURL, archive.ID)
+	c.JSON(http.StatusOK, resp)
+}
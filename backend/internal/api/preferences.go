@@ -0,0 +1,97 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/settings"
+)
+
+type PreferencesRequest struct {
+	Density  *string `json:"density"`
+	Sort     *string `json:"sort"`
+	PresetID *string `json:"presetId"`
+	Theme    *string `json:"theme"`
+}
+
+// preferencesOwner keys preferences by user ID, so renaming a user keeps
+// them; with auth disabled everyone is the one local user.
+func preferencesOwner(c *gin.Context) string {
+	if p := currentPrincipal(c); p.UserID != "" {
+		return p.UserID
+	}
+	return localPrincipal.Username
+}
+
+func (s *Server) getPreferences(c *gin.Context) {
+	prefs, err := settings.LoadPreferences(s.DB, preferencesOwner(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load preferences failed"})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+func (s *Server) updatePreferences(c *gin.Context) {
+	var req PreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	owner := preferencesOwner(c)
+	prefs, err := settings.LoadPreferences(s.DB, owner)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "load preferences failed"})
+		return
+	}
+	if req.Density != nil {
+		prefs.Density = strings.TrimSpace(*req.Density)
+	}
+	if req.Sort != nil {
+		prefs.Sort = strings.TrimSpace(*req.Sort)
+	}
+	if req.Theme != nil {
+		prefs.Theme = strings.TrimSpace(*req.Theme)
+	}
+	if req.PresetID != nil {
+		prefs.PresetID = strings.TrimSpace(*req.PresetID)
+		if prefs.PresetID != "" {
+			if err := s.DB.Select("id").First(&models.CapturePreset{}, "id = ?", prefs.PresetID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "preset not found"})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+				}
+				return
+			}
+		}
+	}
+	if err := prefs.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := settings.SavePreferences(s.DB, owner, prefs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save preferences failed"})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// archiveOrder is the ORDER BY for the list's sort=, one of the orders a
+// user can save as their default.
+func archiveOrder(sort string) string {
+	switch sort {
+	case settings.SortOldest:
+		return "created_at asc"
+	case settings.SortTitle:
+		return "title asc, created_at desc"
+	case settings.SortUpdated:
+		return "updated_at desc"
+	}
+	return "created_at desc"
+}
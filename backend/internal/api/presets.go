@@ -0,0 +1,244 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+type CapturePresetRequest struct {
+	Name        string   `json:"name"`
+	AutoTag     bool     `json:"autoTag"`
+	DefaultTags []string `json:"defaultTags"`
+	DefaultPath string   `json:"defaultPath"`
+	AssetPolicy string   `json:"assetPolicy"`
+	Profile     string   `json:"profile"`
+	// RenderMode is how the extension takes the page when the preset is
+	// chosen there: readability, full (browser-packaged) or selection.
+	RenderMode string `json:"renderMode"`
+	// BlockTrackers nil follows the server default (FETCH_BLOCK_TRACKERS).
+	BlockTrackers *bool `json:"blockTrackers"`
+	// RemoveSelectors are CSS selectors dropped from pages captured with
+	// the preset, on top of the domain's removal rules.
+	RemoveSelectors []string `json:"removeSelectors"`
+	// SummaryStyles are generated for every capture with the preset.
+	SummaryStyles []string `json:"summaryStyles"`
+}
+
+type CapturePresetResponse struct {
+	ID              string    `json:"id"`
+	Name            string    `json:"name"`
+	AutoTag         bool      `json:"autoTag"`
+	RenderMode      string    `json:"renderMode"`
+	DefaultTags     []string  `json:"defaultTags"`
+	DefaultPath     string    `json:"defaultPath"`
+	AssetPolicy     string    `json:"assetPolicy"`
+	Profile         string    `json:"profile"`
+	BlockTrackers   *bool     `json:"blockTrackers"`
+	RemoveSelectors []string  `json:"removeSelectors"`
+	SummaryStyles   []string  `json:"summaryStyles"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
+
+func toCapturePresetResponse(p models.CapturePreset) CapturePresetResponse {
+	tags := []string{}
+	if len(p.DefaultTagsJSON) > 0 {
+		_ = json.Unmarshal(p.DefaultTagsJSON, &tags)
+	}
+	selectors := []string{}
+	if len(p.RemoveSelectorsJSON) > 0 {
+		_ = json.Unmarshal(p.RemoveSelectorsJSON, &selectors)
+	}
+	return CapturePresetResponse{
+		ID:              p.ID,
+		Name:            p.Name,
+		AutoTag:         p.AutoTag,
+		RenderMode:      p.RenderMode,
+		DefaultTags:     tags,
+		DefaultPath:     p.DefaultPath,
+		AssetPolicy:     p.AssetPolicy,
+		Profile:         p.Profile,
+		BlockTrackers:   p.BlockTrackers,
+		RemoveSelectors: selectors,
+		SummaryStyles:   jsonStrings(p.SummaryStylesJSON),
+		CreatedAt:       p.CreatedAt,
+		UpdatedAt:       p.UpdatedAt,
+	}
+}
+
+func (r CapturePresetRequest) validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("name required")
+	}
+	switch r.RenderMode {
+	case "", "readability", "full", "selection":
+	default:
+		return errors.New("renderMode must be readability, full or selection")
+	}
+	if !processor.ValidAssetPolicy(r.AssetPolicy) {
+		return errors.New("assetPolicy must be all, none, no-media or no-scripts")
+	}
+	if !processor.ValidProfile(r.Profile) {
+		return errors.New("profile must be email")
+	}
+	if _, err := processor.ParseSelectors(r.RemoveSelectors); err != nil {
+		return fmt.Errorf("removeSelectors: %v", err)
+	}
+	if _, err := normalizeSummaryStyles(r.SummaryStyles); err != nil {
+		return fmt.Errorf("summaryStyles: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) listPresets(c *gin.Context) {
+	presets, err := s.loadPresets()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	out := make([]CapturePresetResponse, 0, len(presets))
+	for _, p := range presets {
+		out = append(out, toCapturePresetResponse(p))
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) loadPresets() ([]models.CapturePreset, error) {
+	var presets []models.CapturePreset
+	if err := s.DB.Order("name asc").Find(&presets).Error; err != nil {
+		return nil, err
+	}
+	return presets, nil
+}
+
+func (s *Server) createPreset(c *gin.Context) {
+	var req CapturePresetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	preset := models.CapturePreset{ID: uuid.New().String()}
+	applyPresetRequest(&preset, req)
+	if err := s.DB.Create(&preset).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	c.JSON(http.StatusOK, toCapturePresetResponse(preset))
+}
+
+func (s *Server) updatePreset(c *gin.Context) {
+	var req CapturePresetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var preset models.CapturePreset
+	if err := s.DB.First(&preset, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	applyPresetRequest(&preset, req)
+	if err := s.DB.Save(&preset).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	c.JSON(http.StatusOK, toCapturePresetResponse(preset))
+}
+
+func (s *Server) deletePreset(c *gin.Context) {
+	if err := s.DB.Delete(&models.CapturePreset{}, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func applyPresetRequest(preset *models.CapturePreset, req CapturePresetRequest) {
+	tags := patchTags(req.DefaultTags, nil, nil)
+	tagsJSON, _ := json.Marshal(tags)
+	preset.Name = strings.TrimSpace(req.Name)
+	preset.AutoTag = req.AutoTag
+	preset.RenderMode = req.RenderMode
+	preset.DefaultTagsJSON = tagsJSON
+	preset.DefaultPath = strings.Trim(strings.TrimSpace(req.DefaultPath), "/")
+	preset.AssetPolicy = req.AssetPolicy
+	preset.Profile = req.Profile
+	preset.BlockTrackers = req.BlockTrackers
+	selectors := make([]string, 0, len(req.RemoveSelectors))
+	for _, sel := range req.RemoveSelectors {
+		if sel = strings.TrimSpace(sel); sel != "" {
+			selectors = append(selectors, sel)
+		}
+	}
+	preset.RemoveSelectorsJSON, _ = json.Marshal(selectors)
+	styles, _ := normalizeSummaryStyles(req.SummaryStyles)
+	preset.SummaryStylesJSON, _ = json.Marshal(styles)
+}
+
+// findPreset resolves the preset named in a capture request by ID or name.
+func (s *Server) findPreset(ref string) (*models.CapturePreset, error) {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return nil, nil
+	}
+	var preset models.CapturePreset
+	tx := s.DB.Where("id = ? OR name = ?", ref, ref).Limit(1).Find(&preset)
+	if tx.Error != nil {
+		return nil, tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &preset, nil
+}
+
+// applyPreset fills in whatever the capture request left unspecified from
+// the preset; explicit request values always win.
+func applyPreset(req *CreateArchiveRequest, preset *models.CapturePreset) processor.Options {
+	if preset == nil {
+		return processor.Options{}
+	}
+	if preset.AutoTag {
+		req.AutoTag = true
+	}
+	defaults := []string{}
+	if len(preset.DefaultTagsJSON) > 0 {
+		_ = json.Unmarshal(preset.DefaultTagsJSON, &defaults)
+	}
+	if len(defaults) > 0 {
+		req.Tags = patchTags(req.Tags, defaults, nil)
+	}
+	if len(req.SummaryStyles) == 0 {
+		req.SummaryStyles = jsonStrings(preset.SummaryStylesJSON)
+	}
+	if len(req.Hierarchy) == 0 && len(req.HierarchyPaths) == 0 && req.Category == "" && preset.DefaultPath != "" {
+		req.HierarchyPaths = []string{preset.DefaultPath}
+	}
+	opts := processor.Options{AssetPolicy: preset.AssetPolicy, Profile: preset.Profile}
+	if len(preset.RemoveSelectorsJSON) > 0 {
+		var selectors []string
+		if json.Unmarshal(preset.RemoveSelectorsJSON, &selectors) == nil {
+			opts.RemoveSelectors, _ = processor.ParseSelectors(selectors)
+		}
+	}
+	return opts
+}
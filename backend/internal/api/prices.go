@@ -0,0 +1,270 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/notify"
+	"webarchive/internal/processor"
+)
+
+const priceTrackBatchSize = 50
+
+type PriceTrackStatus struct {
+	Running    bool       `json:"running"`
+	Tracked    int64      `json:"tracked"`
+	Checked    int        `json:"checked"`
+	Changed    int        `json:"changed"`
+	Failed     int        `json:"failed"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+type PriceHistoryResponse struct {
+	ArchiveID string              `json:"archiveId"`
+	Current   *models.PricePoint  `json:"current"`
+	Points    []models.PricePoint `json:"points"`
+}
+
+func trackedProducts(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Archive{}).Where("structured_type = ?", processor.StructuredProduct)
+}
+
+// getArchivePrices returns an archive's price series, oldest first.
+func (s *Server) getArchivePrices(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.Select("id").First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	resp := PriceHistoryResponse{ArchiveID: item.ID, Points: []models.PricePoint{}}
+	if err := s.reader().Where("archive_id = ?", item.ID).Order("observed_at asc").Find(&resp.Points).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if n := len(resp.Points); n > 0 {
+		resp.Current = &resp.Points[n-1]
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) priceTrackJobStatus(c *gin.Context) {
+	status := s.getPriceTrackStatus()
+	if err := trackedProducts(s.DB).Count(&status.Tracked).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) runPriceTrackNow(c *gin.Context) {
+	s.startPriceTrack()
+	c.JSON(http.StatusOK, s.getPriceTrackStatus())
+}
+
+func (s *Server) stopPriceTrack(c *gin.Context) {
+	s.priceMu.Lock()
+	if s.priceCancel != nil {
+		s.priceCancel()
+		s.priceCancel = nil
+	}
+	s.priceMu.Unlock()
+	c.JSON(http.StatusOK, s.getPriceTrackStatus())
+}
+
+func (s *Server) getPriceTrackStatus() PriceTrackStatus {
+	s.priceMu.Lock()
+	defer s.priceMu.Unlock()
+	return s.priceStatus
+}
+
+func (s *Server) startPriceTrack() {
+	s.priceMu.Lock()
+	defer s.priceMu.Unlock()
+	if s.priceStatus.Running {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	s.priceCancel = cancel
+	s.priceStatus = PriceTrackStatus{Running: true, StartedAt: &now}
+	go s.runPriceTrack(ctx)
+}
+
+// runPriceTrack re-fetches every product archive and records its current
+// price.
+func (s *Server) runPriceTrack(ctx context.Context) {
+	lastErr := ""
+	defer func() {
+		now := time.Now()
+		s.priceMu.Lock()
+		s.priceStatus.Running = false
+		s.priceStatus.FinishedAt = &now
+		s.priceStatus.LastError = lastErr
+		s.priceCancel = nil
+		s.priceMu.Unlock()
+		if lastErr != "" {
+			log.Printf("price tracking: %s", lastErr)
+		}
+	}()
+
+	lastID := ""
+	for {
+		var items []models.Archive
+		if err := trackedProducts(s.DB).Select("id", "url", "final_url", "title", "structured_json", "captured_at", "created_at", "captured_by").
+			Where("id > ?", lastID).Order("id asc").Limit(priceTrackBatchSize).Find(&items).Error; err != nil {
+			lastErr = err.Error()
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		for _, item := range items {
+			if ctx.Err() != nil {
+				lastErr = "canceled"
+				return
+			}
+			changed, err := s.trackArchivePrice(ctx, item)
+			s.priceMu.Lock()
+			s.priceStatus.Checked++
+			if err != nil {
+				s.priceStatus.Failed++
+			} else if changed {
+				s.priceStatus.Changed++
+			}
+			s.priceMu.Unlock()
+		}
+		lastID = items[len(items)-1].ID
+	}
+}
+
+// trackArchivePrice fetches the live page and compares its price with the
+// last point. An archive without points starts its series from the price
+// seen at capture, so the first check can already report a change.
+func (s *Server) trackArchivePrice(ctx context.Context, item models.Archive) (bool, error) {
+	last, err := s.lastPricePoint(item)
+	if err != nil {
+		return false, err
+	}
+	pageURL := item.FinalURL
+	if pageURL == "" {
+		pageURL = item.URL
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	page, err := s.Processor.FetchPage(fetchCtx, pageURL, processor.Options{HostRules: s.userHostRules(item.CapturedBy)})
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	found, ok := processor.ExtractStructured(page.HTML, processor.StructuredProduct)
+	if !ok {
+		return false, errors.New("page no longer declares a product")
+	}
+	current := pricePoint(item.ID, found.Product, now)
+	if last != nil && samePrice(*last, current) {
+		return false, s.DB.Model(&models.PricePoint{}).Where("id = ?", last.ID).UpdateColumn("checked_at", now).Error
+	}
+	if err := s.DB.Create(&current).Error; err != nil {
+		return false, err
+	}
+	if last == nil {
+		return false, nil
+	}
+	s.publishEvent(LiveArchiveUpdated, gin.H{"id": item.ID, "action": "price", "actor": "price-tracker"})
+	if s.PriceWebhookURL != "" {
+		payload := gin.H{"type": "price.changed", "archiveId": item.ID, "title": item.Title, "url": pageURL, "previous": last, "current": current}
+		if err := notify.Webhook(ctx, s.PriceWebhookURL, payload); err != nil {
+			log.Printf("price webhook failed for %s: %v", item.ID, err)
+		}
+	}
+	return true, nil
+}
+
+// lastPricePoint returns the newest point, seeding the series from the
+// captured product data when there is none yet.
+func (s *Server) lastPricePoint(item models.Archive) (*models.PricePoint, error) {
+	var last models.PricePoint
+	err := s.DB.Where("archive_id = ?", item.ID).Order("observed_at desc").First(&last).Error
+	if err == nil {
+		return &last, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	var product processor.Product
+	if json.Unmarshal(item.StructuredJSON, &product) != nil || (product.Price == "" && product.Availability == "") {
+		return nil, nil
+	}
+	observed := item.CreatedAt
+	if item.CapturedAt != nil {
+		observed = *item.CapturedAt
+	}
+	seed := pricePoint(item.ID, &product, observed)
+	if err := s.DB.Create(&seed).Error; err != nil {
+		return nil, err
+	}
+	return &seed, nil
+}
+
+func pricePoint(archiveID string, p *processor.Product, at time.Time) models.PricePoint {
+	return models.PricePoint{
+		ID:           uuid.New().String(),
+		ArchiveID:    archiveID,
+		Price:        truncate(p.Price, 32),
+		Amount:       priceAmount(p.Price),
+		Currency:     truncate(strings.ToUpper(p.Currency), 8),
+		Availability: truncate(p.Availability, 64),
+		ObservedAt:   at,
+		CheckedAt:    at,
+	}
+}
+
+func samePrice(a, b models.PricePoint) bool {
+	return a.Price == b.Price && a.Currency == b.Currency && a.Availability == b.Availability
+}
+
+// priceAmount reads "1,299.00" or "19.99" as a number for charting; prices it
+// cannot read count as 0.
+func priceAmount(price string) float64 {
+	clean := strings.Map(func(r rune) rune {
+		if (r >= '0' && r <= '9') || r == '.' {
+			return r
+		}
+		return -1
+	}, price)
+	amount, _ := strconv.ParseFloat(clean, 64)
+	return amount
+}
+
+// StartPriceTrackScheduler checks product prices every interval; 0 disables
+// it, leaving only manual runs.
+func (s *Server) StartPriceTrackScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.startPriceTrack()
+		}
+	}()
+}
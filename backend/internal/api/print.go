@@ -0,0 +1,79 @@
+package api
+
+import (
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+)
+
+// printObject is the print-media variant stored next to index.html. It is
+// served from a sibling route, so its relative asset URLs resolve the same.
+const printObject = "print.html"
+
+func (s *Server) getArchivePrint(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.Select("id", "print_path").First(&item, "id = ?", c.Param("id")).Error; err != nil || item.PrintPath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	s.serveArchivePage(c, item.ID, item.PrintPath, false)
+}
+
+// renderArchivePrint builds the print variant of an archive captured
+// without one, or rebuilds it, from the stored snapshot.
+func (s *Server) renderArchivePrint(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.Select("id", "html_path", "print_path", "storage_bytes").First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if item.HTMLPath == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "archive has no stored snapshot"})
+		return
+	}
+	ctx := c.Request.Context()
+	prefix := storage.ArchivePrefix(item.ID)
+	obj, err := s.Store.Get(ctx, prefix+"/"+item.HTMLPath)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	page, err := io.ReadAll(obj)
+	obj.Close()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	variant, err := processor.PrintVariant(page)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "parse failed"})
+		return
+	}
+	var previous int64
+	if item.PrintPath != "" {
+		if old, err := s.Store.Get(ctx, prefix+"/"+item.PrintPath); err == nil {
+			if info, err := old.Stat(); err == nil {
+				previous = info.Size
+			}
+			old.Close()
+		}
+	}
+	if err := s.Store.PutBytes(ctx, prefix+"/"+printObject, variant, "text/html; charset=utf-8"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "store html failed"})
+		return
+	}
+	if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).Updates(map[string]any{
+		"print_path":    printObject,
+		"storage_bytes": item.StorageBytes - previous + int64(len(variant)),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	s.publishEvent(LiveArchiveUpdated, gin.H{"id": item.ID, "action": "print", "actor": currentPrincipal(c).Username})
+	c.JSON(http.StatusOK, gin.H{"printPath": printObject, "bytes": len(variant)})
+}
@@ -0,0 +1,630 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/settings"
+)
+
+const (
+	// profileVersion is the format ConfigProfile is written in; imports of
+	// newer profiles are refused.
+	profileVersion = 1
+
+	maxProfileItems = 1000
+	maxProfileNodes = 10000
+)
+
+// Profile sections, as ?include= names them.
+const (
+	ProfileSettings = "settings"
+	ProfilePresets  = "presets"
+	ProfileRules    = "rules"
+	ProfileTaxonomy = "taxonomy"
+	ProfileSearches = "searches"
+)
+
+var profileSections = []string{ProfileSettings, ProfilePresets, ProfileRules, ProfileTaxonomy, ProfileSearches}
+
+// ConfigProfile is an instance's configuration without any content: runtime
+// settings, capture presets, the capture, recapture and retention rules, the
+// taxonomy tree and the exporting user's saved searches. Presets and nodes
+// are referred to by name and path, so a profile applies to any instance.
+type ConfigProfile struct {
+	Version        int                    `json:"version"`
+	ExportedAt     time.Time              `json:"exportedAt"`
+	Settings       json.RawMessage        `json:"settings,omitempty"`
+	Presets        []CapturePresetRequest `json:"presets,omitempty"`
+	CaptureRules   []CaptureRuleRequest   `json:"captureRules,omitempty"`
+	RecaptureRules []RecaptureRuleRequest `json:"recaptureRules,omitempty"`
+	RetentionRules []RetentionRuleRequest `json:"retentionRules,omitempty"`
+	Taxonomy       []ProfileTaxonomyNode  `json:"taxonomy,omitempty"`
+	Searches       []ProfileSearch        `json:"searches,omitempty"`
+}
+
+type ProfileTaxonomyNode struct {
+	Path        string            `json:"path"`
+	Description string            `json:"description,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Aliases     []string          `json:"aliases,omitempty"`
+}
+
+// ProfileSearch is a dashboard pin that doesn't name archives: a search
+// query, a tag, or a taxonomy node by path.
+type ProfileSearch struct {
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+	Ref   string `json:"ref"`
+}
+
+// ProfileImportResult counts what an import created and updated, by section.
+type ProfileImportResult struct {
+	Created  map[string]int `json:"created"`
+	Updated  map[string]int `json:"updated"`
+	Warnings []string       `json:"warnings"`
+}
+
+// profileInclude reads ?include=, a comma-separated list of sections; empty
+// means all of them.
+func profileInclude(raw string) (map[string]bool, error) {
+	include := map[string]bool{}
+	for _, name := range splitList(raw) {
+		known := false
+		for _, section := range profileSections {
+			known = known || name == section
+		}
+		if !known {
+			return nil, fmt.Errorf("include must list %s", strings.Join(profileSections, ", "))
+		}
+		include[name] = true
+	}
+	if len(include) == 0 {
+		for _, section := range profileSections {
+			include[section] = true
+		}
+	}
+	return include, nil
+}
+
+// sensitiveHeader reports header rules that likely carry credentials; they
+// are left out of exported profiles.
+func sensitiveHeader(name string) bool {
+	name = textproto.CanonicalMIMEHeaderKey(name)
+	if name == "Authorization" || name == "Proxy-Authorization" {
+		return true
+	}
+	lower := strings.ToLower(name)
+	for _, word := range []string{"token", "secret", "key", "auth", "session", "password"} {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// exportProfile downloads the configuration as a profile. Header rules that
+// look like credentials and the per-user capture hosts, whose user names
+// don't carry over, are left out.
+func (s *Server) exportProfile(c *gin.Context) {
+	include, err := profileInclude(c.Query("include"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	profile, err := s.buildProfile(include, dashboardOwner(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="webarchive-profile.json"`)
+	c.JSON(http.StatusOK, profile)
+}
+
+func (s *Server) buildProfile(include map[string]bool, owner string) (ConfigProfile, error) {
+	profile := ConfigProfile{Version: profileVersion, ExportedAt: time.Now().UTC()}
+	if include[ProfileSettings] {
+		rt := s.runtimeSettings()
+		rt.UserCaptureHosts = nil
+		rules := []settings.HeaderRule{}
+		for _, rule := range rt.HeaderRules {
+			headers := map[string]string{}
+			for name, value := range rule.Headers {
+				if !sensitiveHeader(name) {
+					headers[name] = value
+				}
+			}
+			if len(headers) > 0 {
+				rules = append(rules, settings.HeaderRule{Domain: rule.Domain, Headers: headers})
+			}
+		}
+		rt.HeaderRules = rules
+		raw, err := json.Marshal(rt)
+		if err != nil {
+			return profile, err
+		}
+		// Per-user host rules name this instance's users; the key is left
+		// out rather than written as null.
+		var fields map[string]json.RawMessage
+		_ = json.Unmarshal(raw, &fields)
+		delete(fields, "userCaptureHosts")
+		profile.Settings, _ = json.Marshal(fields)
+	}
+
+	presetNames := map[string]string{}
+	if include[ProfilePresets] || include[ProfileRules] {
+		presets, err := s.loadPresets()
+		if err != nil {
+			return profile, err
+		}
+		for _, preset := range presets {
+			presetNames[preset.ID] = preset.Name
+			if include[ProfilePresets] {
+				profile.Presets = append(profile.Presets, presetRequestOf(preset))
+			}
+		}
+	}
+	if include[ProfileRules] {
+		var capture []models.CaptureRule
+		if err := s.DB.Order("priority asc").Order("name asc").Find(&capture).Error; err != nil {
+			return profile, err
+		}
+		for _, rule := range capture {
+			preset := rule.Preset
+			if name, ok := presetNames[preset]; ok {
+				preset = name
+			}
+			profile.CaptureRules = append(profile.CaptureRules, CaptureRuleRequest{
+				Name: rule.Name, Priority: rule.Priority, Domain: rule.Domain, URLPattern: rule.URLPattern,
+				TitleKeywords: jsonStrings(rule.TitleKeywordsJSON), Tags: jsonStrings(rule.TagsJSON),
+				Path: rule.Path, Preset: preset, SkipAI: rule.SkipAI, Stop: rule.Stop, Enabled: rule.Enabled,
+			})
+		}
+		var recapture []models.RecaptureRule
+		if err := s.DB.Order("name asc").Find(&recapture).Error; err != nil {
+			return profile, err
+		}
+		for _, rule := range recapture {
+			profile.RecaptureRules = append(profile.RecaptureRules, RecaptureRuleRequest{
+				Name: rule.Name, Tag: rule.Tag, PathPrefix: rule.PathPrefix, EveryDays: rule.EveryDays, Enabled: rule.Enabled,
+			})
+		}
+		var retention []models.RetentionRule
+		if err := s.DB.Order("name asc").Find(&retention).Error; err != nil {
+			return profile, err
+		}
+		for _, rule := range retention {
+			profile.RetentionRules = append(profile.RetentionRules, RetentionRuleRequest{
+				Name: rule.Name, Tag: rule.Tag, PathPrefix: rule.PathPrefix, OlderThanDays: rule.OlderThanDays,
+				Action: rule.Action, Enabled: rule.Enabled,
+			})
+		}
+	}
+
+	nodePaths := map[string]string{}
+	if include[ProfileTaxonomy] || include[ProfileSearches] {
+		var nodes []models.TaxonomyNode
+		if err := s.DB.Select("id", "path", "description", "labels_json", "aliases_json").
+			Order("path asc").Find(&nodes).Error; err != nil {
+			return profile, err
+		}
+		for _, node := range nodes {
+			nodePaths[node.ID] = node.Path
+			if include[ProfileTaxonomy] {
+				profile.Taxonomy = append(profile.Taxonomy, ProfileTaxonomyNode{
+					Path: node.Path, Description: node.Description,
+					Labels: nodeLabels(node), Aliases: jsonStrings(node.AliasesJSON),
+				})
+			}
+		}
+	}
+	if include[ProfileSearches] {
+		var pins []models.DashboardPin
+		if err := s.DB.Where("owner = ? AND kind IN ?", owner, []string{PinSearch, PinTag, PinNode}).
+			Order("position asc, created_at asc").Find(&pins).Error; err != nil {
+			return profile, err
+		}
+		for _, pin := range pins {
+			ref := pin.Ref
+			if pin.Kind == PinNode {
+				if ref = nodePaths[pin.Ref]; ref == "" {
+					continue
+				}
+			}
+			profile.Searches = append(profile.Searches, ProfileSearch{Kind: pin.Kind, Label: pin.Label, Ref: ref})
+		}
+	}
+	return profile, nil
+}
+
+func presetRequestOf(preset models.CapturePreset) CapturePresetRequest {
+	return CapturePresetRequest{
+		Name:            preset.Name,
+		AutoTag:         preset.AutoTag,
+		RenderMode:      preset.RenderMode,
+		DefaultTags:     jsonStrings(preset.DefaultTagsJSON),
+		DefaultPath:     preset.DefaultPath,
+		AssetPolicy:     preset.AssetPolicy,
+		Profile:         preset.Profile,
+		BlockTrackers:   preset.BlockTrackers,
+		RemoveSelectors: jsonStrings(preset.RemoveSelectorsJSON),
+		SummaryStyles:   jsonStrings(preset.SummaryStylesJSON),
+	}
+}
+
+// importProfile applies a profile on top of the current configuration.
+// Presets and rules replace the ones with the same name and are added
+// otherwise, taxonomy nodes are created when missing and take the profile's
+// description, translations and aliases, and searches are pinned to the
+// importing user's dashboard unless already there. Settings present in the
+// profile replace the current ones. Retention rules always arrive disabled,
+// so nothing is deleted before they are reviewed. Everything is checked
+// before anything is written; ?include= limits the sections applied.
+func (s *Server) importProfile(c *gin.Context) {
+	include, err := profileInclude(c.Query("include"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var profile ConfigProfile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	rt, err := s.checkProfile(&profile, include)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := ProfileImportResult{Created: map[string]int{}, Updated: map[string]int{}, Warnings: []string{}}
+	limits := s.taxonomyLimits()
+	owner := dashboardOwner(c)
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if include[ProfilePresets] {
+			if err := importPresets(tx, profile.Presets, &result); err != nil {
+				return err
+			}
+		}
+		if include[ProfileRules] {
+			if err := importRules(tx, profile, &result); err != nil {
+				return err
+			}
+		}
+		if include[ProfileTaxonomy] {
+			if err := importTaxonomy(tx, profile.Taxonomy, limits, &result); err != nil {
+				return err
+			}
+		}
+		if include[ProfileSearches] {
+			return importSearches(tx, profile.Searches, owner, limits, &result)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "import failed: " + err.Error()})
+		return
+	}
+	if include[ProfileTaxonomy] {
+		s.importNodeNames(profile.Taxonomy, limits, &result)
+		if result.Created[ProfileTaxonomy] > 0 || result.Updated[ProfileTaxonomy] > 0 {
+			s.publishTaxonomyChanged("import")
+		}
+	}
+	if rt != nil {
+		before := s.runtimeSettings()
+		if err := settings.SaveRuntime(s.DB, *rt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "save settings failed"})
+			return
+		}
+		s.ApplyRuntime(*rt)
+		s.recordAdminAudit(c, AuditSettings, "runtime", before, *rt)
+		result.Updated[ProfileSettings] = 1
+	}
+	s.recordAdminAudit(c, AuditProfileImport, "profile", nil, result)
+	c.JSON(http.StatusOK, result)
+}
+
+// checkProfile validates the sections to import and returns the runtime
+// settings the profile leads to, nil when it has none.
+func (s *Server) checkProfile(profile *ConfigProfile, include map[string]bool) (*settings.RuntimeSettings, error) {
+	if profile.Version < 1 || profile.Version > profileVersion {
+		return nil, fmt.Errorf("unsupported profile version %d", profile.Version)
+	}
+	if len(profile.Presets) > maxProfileItems || len(profile.CaptureRules) > maxProfileItems ||
+		len(profile.RecaptureRules) > maxProfileItems || len(profile.RetentionRules) > maxProfileItems ||
+		len(profile.Searches) > maxProfileItems {
+		return nil, fmt.Errorf("at most %d items per section", maxProfileItems)
+	}
+	if len(profile.Taxonomy) > maxProfileNodes {
+		return nil, fmt.Errorf("at most %d taxonomy nodes", maxProfileNodes)
+	}
+	presets := map[string]bool{}
+	if include[ProfilePresets] {
+		for i, req := range profile.Presets {
+			if err := req.validate(); err != nil {
+				return nil, fmt.Errorf("presets[%d]: %v", i, err)
+			}
+			presets[strings.TrimSpace(req.Name)] = true
+		}
+	}
+	if include[ProfileRules] {
+		for i, req := range profile.CaptureRules {
+			if err := req.validate(); err != nil {
+				return nil, fmt.Errorf("captureRules[%d]: %v", i, err)
+			}
+			if preset := strings.TrimSpace(req.Preset); preset != "" && !presets[preset] {
+				if _, err := s.findPreset(preset); err != nil {
+					return nil, fmt.Errorf("captureRules[%d]: unknown preset %q", i, preset)
+				}
+			}
+		}
+		for i, req := range profile.RecaptureRules {
+			if err := req.validate(); err != nil {
+				return nil, fmt.Errorf("recaptureRules[%d]: %v", i, err)
+			}
+		}
+		for i := range profile.RetentionRules {
+			profile.RetentionRules[i].Enabled = false
+			if err := profile.RetentionRules[i].validate(); err != nil {
+				return nil, fmt.Errorf("retentionRules[%d]: %v", i, err)
+			}
+		}
+	}
+	if include[ProfileTaxonomy] {
+		for i, node := range profile.Taxonomy {
+			if strings.Trim(strings.TrimSpace(node.Path), "/") == "" {
+				return nil, fmt.Errorf("taxonomy[%d]: path required", i)
+			}
+		}
+	}
+	if include[ProfileSearches] {
+		for i, search := range profile.Searches {
+			switch search.Kind {
+			case PinSearch, PinTag, PinNode:
+			default:
+				return nil, fmt.Errorf("searches[%d]: kind must be search, tag or node", i)
+			}
+			if strings.TrimSpace(search.Ref) == "" {
+				return nil, fmt.Errorf("searches[%d]: ref required", i)
+			}
+		}
+	}
+	if !include[ProfileSettings] || len(profile.Settings) == 0 {
+		return nil, nil
+	}
+	var req RuntimeSettingsRequest
+	if err := json.Unmarshal(profile.Settings, &req); err != nil {
+		return nil, fmt.Errorf("settings: %v", err)
+	}
+	// Per-user rules name users of the exporting instance.
+	req.UserCaptureHosts = nil
+	rt := s.runtimeSettings()
+	req.applyTo(&rt)
+	if err := rt.Validate(); err != nil {
+		return nil, fmt.Errorf("settings: %v", err)
+	}
+	return &rt, nil
+}
+
+func importPresets(tx *gorm.DB, reqs []CapturePresetRequest, result *ProfileImportResult) error {
+	for _, req := range reqs {
+		var preset models.CapturePreset
+		found := tx.Where("name = ?", strings.TrimSpace(req.Name)).Limit(1).Find(&preset)
+		if found.Error != nil {
+			return found.Error
+		}
+		if found.RowsAffected == 0 {
+			preset = models.CapturePreset{ID: uuid.New().String()}
+			result.Created[ProfilePresets]++
+		} else {
+			result.Updated[ProfilePresets]++
+		}
+		applyPresetRequest(&preset, req)
+		if err := tx.Save(&preset).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importRules upserts the rules of each kind by name. Capture rules store
+// the preset by name, which resolves on this instance too.
+func importRules(tx *gorm.DB, profile ConfigProfile, result *ProfileImportResult) error {
+	upsert := func(model any, name string, fresh func() any, apply func(row any)) error {
+		found := tx.Where("name = ?", name).Limit(1).Find(model)
+		if found.Error != nil {
+			return found.Error
+		}
+		row := model
+		if found.RowsAffected == 0 {
+			row = fresh()
+			result.Created[ProfileRules]++
+		} else {
+			result.Updated[ProfileRules]++
+		}
+		apply(row)
+		return tx.Save(row).Error
+	}
+	for _, req := range profile.CaptureRules {
+		err := upsert(&models.CaptureRule{}, strings.TrimSpace(req.Name),
+			func() any { return &models.CaptureRule{ID: uuid.New().String()} },
+			func(row any) { applyCaptureRuleRequest(row.(*models.CaptureRule), req) })
+		if err != nil {
+			return err
+		}
+	}
+	for _, req := range profile.RecaptureRules {
+		err := upsert(&models.RecaptureRule{}, strings.TrimSpace(req.Name),
+			func() any { return &models.RecaptureRule{ID: uuid.New().String()} },
+			func(row any) { applyRecaptureRequest(row.(*models.RecaptureRule), req) })
+		if err != nil {
+			return err
+		}
+	}
+	for _, req := range profile.RetentionRules {
+		err := upsert(&models.RetentionRule{}, strings.TrimSpace(req.Name),
+			func() any { return &models.RetentionRule{ID: uuid.New().String()} },
+			func(row any) { applyRetentionRequest(row.(*models.RetentionRule), req) })
+		if err != nil {
+			return err
+		}
+	}
+	if len(profile.RetentionRules) > 0 {
+		result.Warnings = append(result.Warnings, "retention rules were imported disabled; enable them after checking /api/retention/preview")
+	}
+	return nil
+}
+
+// importTaxonomy creates the nodes of the profile that don't exist yet and
+// sets the descriptions it has.
+func importTaxonomy(tx *gorm.DB, nodes []ProfileTaxonomyNode, limits taxonomyLimits, result *ProfileImportResult) error {
+	paths := []string{}
+	descriptions := map[string]string{}
+	for _, node := range nodes {
+		path := limits.clampPath(strings.Trim(strings.TrimSpace(node.Path), "/"))
+		if path == "" {
+			continue
+		}
+		if path != strings.Trim(strings.TrimSpace(node.Path), "/") {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("taxonomy path %q was stored as %q", node.Path, path))
+		}
+		paths = append(paths, path)
+		if desc := strings.TrimSpace(node.Description); desc != "" {
+			descriptions[path] = desc
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	var before int64
+	if err := tx.Model(&models.TaxonomyNode{}).Count(&before).Error; err != nil {
+		return err
+	}
+	ids, err := taxonomyNodeIDsDB(tx, paths)
+	if err != nil {
+		return err
+	}
+	var after int64
+	if err := tx.Model(&models.TaxonomyNode{}).Count(&after).Error; err != nil {
+		return err
+	}
+	result.Created[ProfileTaxonomy] += int(after - before)
+	for path, desc := range descriptions {
+		res := tx.Model(&models.TaxonomyNode{}).Where("id = ? AND description <> ?", ids[path], desc).Update("description", desc)
+		if res.Error != nil {
+			return res.Error
+		}
+		result.Updated[ProfileTaxonomy] += int(res.RowsAffected)
+	}
+	return nil
+}
+
+// importNodeNames sets the translations and aliases of imported nodes. A
+// node whose names clash with a sibling keeps its own, with a warning.
+func (s *Server) importNodeNames(nodes []ProfileTaxonomyNode, limits taxonomyLimits, result *ProfileImportResult) {
+	for _, pn := range nodes {
+		if len(pn.Labels) == 0 && len(pn.Aliases) == 0 {
+			continue
+		}
+		path := limits.clampPath(strings.Trim(strings.TrimSpace(pn.Path), "/"))
+		labels, aliases, err := normalizeNodeNames(pn.Labels, pn.Aliases, limits.maxLabelLen)
+		if err == nil {
+			err = s.setNodeNames(path, labels, aliases)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("taxonomy %s: %v", path, err))
+			continue
+		}
+		result.Updated[ProfileTaxonomy]++
+	}
+}
+
+func (s *Server) setNodeNames(path string, labels map[string]string, aliases []string) error {
+	var node models.TaxonomyNode
+	if err := s.DB.First(&node, "path = ?", path).Error; err != nil {
+		return err
+	}
+	parent := ""
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		parent = path[:i]
+	}
+	siblings, err := s.taxonomySiblings(parent)
+	if err != nil {
+		return err
+	}
+	if err := siblingNameClash(siblings, node.Label, nodeNames(labels, aliases)); err != nil {
+		return err
+	}
+	labelsJSON, _ := json.Marshal(labels)
+	aliasesJSON, _ := json.Marshal(aliases)
+	return s.DB.Model(&node).Updates(map[string]any{"labels_json": labelsJSON, "aliases_json": aliasesJSON}).Error
+}
+
+// importSearches pins the profile's searches to owner's dashboard after the
+// pins already there, skipping the ones pinned before.
+func importSearches(tx *gorm.DB, searches []ProfileSearch, owner string, limits taxonomyLimits, result *ProfileImportResult) error {
+	var pins []models.DashboardPin
+	if err := tx.Where("owner = ?", owner).Find(&pins).Error; err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for _, pin := range pins {
+		have[pin.Kind+"\x00"+pin.Ref] = true
+	}
+	nodePaths := []string{}
+	for _, search := range searches {
+		if search.Kind == PinNode {
+			nodePaths = append(nodePaths, limits.clampPath(strings.Trim(strings.TrimSpace(search.Ref), "/")))
+		}
+	}
+	sort.Strings(nodePaths)
+	nodeIDs := map[string]string{}
+	if err := loadTaxonomyNodeIDs(tx, nodePaths, nodeIDs); err != nil {
+		return err
+	}
+	position := len(pins)
+	for _, search := range searches {
+		ref := strings.TrimSpace(search.Ref)
+		if search.Kind == PinNode {
+			path := limits.clampPath(strings.Trim(ref, "/"))
+			if ref = nodeIDs[path]; ref == "" {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("search %q: no taxonomy node %s", search.Label, path))
+				continue
+			}
+		}
+		if have[search.Kind+"\x00"+ref] {
+			continue
+		}
+		have[search.Kind+"\x00"+ref] = true
+		label := strings.TrimSpace(search.Label)
+		if label == "" {
+			label = strings.TrimSpace(search.Ref)
+		}
+		pin := models.DashboardPin{
+			ID: uuid.New().String(), Owner: owner, Kind: search.Kind,
+			Label: truncate(label, 255), Ref: truncate(ref, 512), Position: position,
+		}
+		pin.ArchiveIDsJSON, _ = json.Marshal([]string{})
+		if err := tx.Create(&pin).Error; err != nil {
+			return err
+		}
+		position++
+		result.Created[ProfileSearches]++
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// projection is a set of dotted field paths as a tree; a nil subtree
+// stands for the whole value under that key.
+type projection map[string]projection
+
+func parseProjection(raw string) projection {
+	p := projection{}
+	for _, path := range splitList(raw) {
+		node := p
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			child, seen := node[part]
+			if seen && child == nil {
+				break
+			}
+			if i == len(parts)-1 {
+				node[part] = nil
+				break
+			}
+			if !seen {
+				child = projection{}
+				node[part] = child
+			}
+			node = child
+		}
+	}
+	return p
+}
+
+// keep drops every key not in p. Arrays are projected element by element,
+// so on a list the paths name the fields of each record.
+func (p projection) keep(v any) any {
+	switch v := v.(type) {
+	case []any:
+		for i := range v {
+			v[i] = p.keep(v[i])
+		}
+		return v
+	case map[string]any:
+		out := make(map[string]any, len(p))
+		for key, sub := range p {
+			if val, ok := v[key]; ok {
+				if sub != nil {
+					val = sub.keep(val)
+				}
+				out[key] = val
+			}
+		}
+		return out
+	}
+	return v
+}
+
+// drop removes the keys in p and keeps the rest.
+func (p projection) drop(v any) any {
+	switch v := v.(type) {
+	case []any:
+		for i := range v {
+			v[i] = p.drop(v[i])
+		}
+	case map[string]any:
+		for key, sub := range p {
+			if sub == nil {
+				delete(v, key)
+			} else if val, ok := v[key]; ok {
+				v[key] = sub.drop(val)
+			}
+		}
+	}
+	return v
+}
+
+// wantsField reports whether a top-level record field survives the
+// request's fields= and omit=. Handlers use it to skip loading heavy
+// columns; omitByDefault marks fields lists leave out unless asked for,
+// and an explicit omit= (even an empty one) overrides that default.
+func wantsField(c *gin.Context, name string, omitByDefault bool) bool {
+	if fields := c.Query("fields"); fields != "" {
+		_, ok := parseProjection(fields)[name]
+		return ok
+	}
+	if omit, ok := c.GetQuery("omit"); ok {
+		sub, listed := parseProjection(omit)[name]
+		return !listed || sub != nil
+	}
+	return !omitByDefault
+}
+
+type projectWriter struct {
+	gin.ResponseWriter
+	buf bytes.Buffer
+	// passthrough is decided on the first write: anything but a successful
+	// JSON document goes out untouched.
+	decided     bool
+	passthrough bool
+}
+
+func (w *projectWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		ct := w.Header().Get("Content-Type")
+		w.passthrough = w.Status() < http.StatusOK || w.Status() >= http.StatusMultipleChoices || !strings.HasPrefix(ct, "application/json")
+	}
+	if w.passthrough {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.buf.Write(b)
+}
+
+func (w *projectWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush only reaches the client for passed-through responses; a projected
+// document has to be complete before it can be rewritten.
+func (w *projectWriter) Flush() {
+	if w.passthrough {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// projectFields applies ?fields= and ?omit= to any JSON response, so every
+// endpoint supports them without each serializer knowing about it. Paths
+// are dotted (results.id) and apply to each element of an array.
+func projectFields() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		fields, omit := c.Query("fields"), c.Query("omit")
+		if (fields == "" && omit == "") || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+		w := &projectWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+		if w.passthrough || w.buf.Len() == 0 {
+			return
+		}
+		body := w.buf.Bytes()
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var doc any
+		if err := dec.Decode(&doc); err == nil {
+			if fields != "" {
+				doc = parseProjection(fields).keep(doc)
+			}
+			if omit != "" {
+				doc = parseProjection(omit).drop(doc)
+			}
+			if out, err := json.Marshal(doc); err == nil {
+				body = out
+			}
+		}
+		_, _ = w.ResponseWriter.Write(body)
+	}
+}
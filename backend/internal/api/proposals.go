@@ -0,0 +1,194 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"webarchive/internal/models"
+)
+
+type Classification struct {
+	Category      string              `json:"category"`
+	Tags          []string            `json:"tags"`
+	HierarchyPath string              `json:"hierarchyPath"`
+	Entities      []string            `json:"entities"`
+	EntityTypes   map[string]string   `json:"entityTypes"`
+	Relations     []knowledgeRelation `json:"relations"`
+	Summary       string              `json:"summary"`
+}
+
+type ProposalResponse struct {
+	ArchiveID string         `json:"archiveId"`
+	Title     string         `json:"title"`
+	URL       string         `json:"url"`
+	Model     string         `json:"model"`
+	Columns   []string       `json:"columns"`
+	Current   Classification `json:"current"`
+	Proposed  Classification `json:"proposed"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+func classificationOf(item models.Archive) Classification {
+	out := Classification{
+		Category:      item.Category,
+		Tags:          jsonStrings(item.TagsJSON),
+		HierarchyPath: item.HierarchyPath,
+		Entities:      jsonStrings(item.EntitiesJSON),
+		EntityTypes:   archiveEntityTypes(item),
+		Relations:     []knowledgeRelation{},
+		Summary:       item.Summary,
+	}
+	if len(item.RelationsJSON) > 0 {
+		_ = json.Unmarshal(item.RelationsJSON, &out.Relations)
+	}
+	return out
+}
+
+// withProposal returns item as it would be after the proposal is applied.
+func withProposal(item models.Archive, p models.AnalysisProposal) models.Archive {
+	for _, col := range jsonStrings(p.ColumnsJSON) {
+		switch col {
+		case "category":
+			item.Category = p.Category
+		case "tags_json":
+			item.TagsJSON = p.TagsJSON
+		case "hierarchy_json":
+			item.HierarchyJSON = p.HierarchyJSON
+		case "hierarchy_path":
+			item.HierarchyPath = p.HierarchyPath
+		case "entities_json":
+			item.EntitiesJSON = p.EntitiesJSON
+		case "entity_types_json":
+			item.EntityTypesJSON = p.EntityTypesJSON
+		case "relations_json":
+			item.RelationsJSON = p.RelationsJSON
+		case "summary":
+			item.Summary = p.Summary
+		}
+	}
+	return item
+}
+
+// stageProposal analyzes item within scope and keeps the result for review,
+// replacing any earlier proposal for the same archive.
+func (s *Server) stageProposal(ctx context.Context, item models.Archive, scope string) error {
+	propose := s.proposeClassification
+	if scope == AnalysisScopeEntities {
+		propose = s.proposeEntities
+	}
+	proposed, columns, err := propose(ctx, s.LLM, item)
+	if err != nil {
+		return err
+	}
+	columnsJSON, _ := json.Marshal(columns)
+	return s.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(&models.AnalysisProposal{
+		ArchiveID:       item.ID,
+		Model:           s.LLM.Model,
+		ColumnsJSON:     columnsJSON,
+		Category:        proposed.Category,
+		TagsJSON:        proposed.TagsJSON,
+		HierarchyJSON:   proposed.HierarchyJSON,
+		HierarchyPath:   proposed.HierarchyPath,
+		EntitiesJSON:    proposed.EntitiesJSON,
+		EntityTypesJSON: proposed.EntityTypesJSON,
+		RelationsJSON:   proposed.RelationsJSON,
+		Summary:         proposed.Summary,
+	}).Error
+}
+
+// listProposals pages through staged proposals, oldest first, each next to
+// the archive's current classification.
+func (s *Server) listProposals(c *gin.Context) {
+	var total int64
+	if err := s.DB.Model(&models.AnalysisProposal{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	var proposals []models.AnalysisProposal
+	if err := s.DB.Order("created_at asc, archive_id asc").
+		Limit(parseLimit(c.Query("limit"), 50)).Offset(parseLimit(c.Query("offset"), 0)).
+		Find(&proposals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	ids := make([]string, 0, len(proposals))
+	for _, p := range proposals {
+		ids = append(ids, p.ArchiveID)
+	}
+	archives := map[string]models.Archive{}
+	if len(ids) > 0 {
+		var items []models.Archive
+		if err := s.DB.Omit("content_text").Where("id IN ?", ids).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		for _, item := range items {
+			archives[item.ID] = item
+		}
+	}
+	out := make([]ProposalResponse, 0, len(proposals))
+	for _, p := range proposals {
+		item, ok := archives[p.ArchiveID]
+		if !ok {
+			continue
+		}
+		out = append(out, ProposalResponse{
+			ArchiveID: p.ArchiveID,
+			Title:     item.Title,
+			URL:       item.URL,
+			Model:     p.Model,
+			Columns:   jsonStrings(p.ColumnsJSON),
+			Current:   classificationOf(item),
+			Proposed:  classificationOf(withProposal(item, p)),
+			CreatedAt: p.CreatedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"total": total, "items": out})
+}
+
+func (s *Server) applyProposal(c *gin.Context) {
+	var p models.AnalysisProposal
+	if err := s.DB.First(&p, "archive_id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	var item models.Archive
+	if err := s.DB.First(&item, "id = ?", p.ArchiveID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	before := s.snapshotArchive(item)
+	updated, err := s.saveClassification(withProposal(item, p), jsonStrings(p.ColumnsJSON))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	_ = s.DB.Delete(&models.AnalysisProposal{}, "archive_id = ?", p.ArchiveID).Error
+	s.recordArchiveEvent(updated.ID, EventAnalyzer, currentPrincipal(c).Username, before, s.snapshotArchive(updated))
+	paths, _ := s.loadArchivePaths(updated.ID)
+	c.JSON(http.StatusOK, toArchiveResponse(updated, paths))
+}
+
+func (s *Server) discardProposal(c *gin.Context) {
+	res := s.DB.Delete(&models.AnalysisProposal{}, "archive_id = ?", c.Param("id"))
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Provenance systems the importers record; migrations may name others.
+const (
+	ProvenancePocket    = "pocket"
+	ProvenanceBookmarks = "bookmarks"
+	ProvenanceWARC      = "warc"
+)
+
+// maxProvenance bounds the chain one archive carries.
+const maxProvenance = 20
+
+// Provenance is one system an item was saved in before it came here: the
+// system's name, the item's ID there when it had one, and when it was saved
+// there and imported from it. Chains are kept oldest first, so an archive
+// exported and imported again still knows where it started.
+type Provenance struct {
+	System     string     `json:"system"`
+	OriginalID string     `json:"originalId,omitempty"`
+	SavedAt    *time.Time `json:"savedAt,omitempty"`
+	ImportedAt *time.Time `json:"importedAt,omitempty"`
+}
+
+// normalizeProvenance trims a chain passed with a capture, rejecting entries
+// without a system.
+func normalizeProvenance(chain []Provenance) ([]Provenance, error) {
+	if len(chain) > maxProvenance {
+		return nil, fmt.Errorf("at most %d provenance entries", maxProvenance)
+	}
+	out := make([]Provenance, 0, len(chain))
+	for _, p := range chain {
+		p.System = strings.ToLower(strings.TrimSpace(p.System))
+		p.OriginalID = strings.TrimSpace(p.OriginalID)
+		if p.System == "" || len(p.System) > 64 {
+			return nil, errors.New("provenance system is required and at most 64 bytes")
+		}
+		if len(p.OriginalID) > 255 {
+			return nil, errors.New("provenance originalId is at most 255 bytes")
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// importedFrom is the provenance an importer records for an item taken from
+// system.
+func importedFrom(system, originalID string, savedAt *time.Time) []Provenance {
+	now := time.Now().UTC()
+	return []Provenance{{System: system, OriginalID: originalID, SavedAt: savedAt, ImportedAt: &now}}
+}
+
+// String renders the entry on one line for text exports, e.g.
+// "pocket (saved 2019-03-01T10:00:00Z, imported 2024-05-02T08:00:00Z)".
+func (p Provenance) String() string {
+	details := []string{}
+	if p.OriginalID != "" {
+		details = append(details, "id "+p.OriginalID)
+	}
+	if p.SavedAt != nil {
+		details = append(details, "saved "+p.SavedAt.UTC().Format(time.RFC3339))
+	}
+	if p.ImportedAt != nil {
+		details = append(details, "imported "+p.ImportedAt.UTC().Format(time.RFC3339))
+	}
+	if len(details) == 0 {
+		return p.System
+	}
+	return p.System + " (" + strings.Join(details, ", ") + ")"
+}
+
+func provenanceChain(raw datatypes.JSON) []Provenance {
+	var out []Provenance
+	if len(raw) > 0 {
+		_ = json.Unmarshal(raw, &out)
+	}
+	return out
+}
+
+// marshalProvenance stores a chain, leaving the column NULL when there is
+// none.
+func marshalProvenance(chain []Provenance) datatypes.JSON {
+	if len(chain) == 0 {
+		return nil
+	}
+	raw, _ := json.Marshal(chain)
+	return raw
+}
+
+// mergeProvenance appends the entries of extra missing from base, keeping a
+// merged archive's import history from both sides.
+func mergeProvenance(base, extra []Provenance) []Provenance {
+	key := func(p Provenance) string {
+		saved := ""
+		if p.SavedAt != nil {
+			saved = p.SavedAt.UTC().Format(time.RFC3339)
+		}
+		return p.System + "\x00" + p.OriginalID + "\x00" + saved
+	}
+	seen := map[string]bool{}
+	out := append([]Provenance{}, base...)
+	for _, p := range base {
+		seen[key(p)] = true
+	}
+	for _, p := range extra {
+		if !seen[key(p)] && len(out) < maxProvenance {
+			seen[key(p)] = true
+			out = append(out, p)
+		}
+	}
+	return out
+}
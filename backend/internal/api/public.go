@@ -0,0 +1,140 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+// PublicArchiveResponse is what anonymous visitors see of a published
+// archive: no capture client, IP, user agent or storage details.
+type PublicArchiveResponse struct {
+	ID            string     `json:"id"`
+	Title         string     `json:"title"`
+	URL           string     `json:"url"`
+	Domain        string     `json:"domain"`
+	SiteName      string     `json:"siteName"`
+	Byline        string     `json:"byline"`
+	Excerpt       string     `json:"excerpt"`
+	Summary       string     `json:"summary"`
+	Category      string     `json:"category"`
+	Tags          []string   `json:"tags"`
+	HierarchyPath string     `json:"hierarchyPath"`
+	WordCount     int        `json:"wordCount"`
+	ReadMinutes   int        `json:"readMinutes"`
+	CaptureMode   string     `json:"captureMode"`
+	CapturedAt    *time.Time `json:"capturedAt"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+func toPublicArchiveResponse(item models.Archive) PublicArchiveResponse {
+	return PublicArchiveResponse{
+		ID:            item.ID,
+		Title:         item.Title,
+		URL:           item.URL,
+		Domain:        item.Domain,
+		SiteName:      item.SiteName,
+		Byline:        item.Byline,
+		Excerpt:       item.Excerpt,
+		Summary:       item.Summary,
+		Category:      item.Category,
+		Tags:          jsonStrings(item.TagsJSON),
+		HierarchyPath: item.HierarchyPath,
+		WordCount:     item.WordCount,
+		ReadMinutes:   item.ReadMinutes,
+		CaptureMode:   captureModeOf(item),
+		CapturedAt:    item.CapturedAt,
+		CreatedAt:     item.CreatedAt,
+	}
+}
+
+// requirePublic hides the whole public surface unless PUBLIC_ENABLED is set.
+func (s *Server) requirePublic() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.PublicEnabled {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) publicArchives(c *gin.Context) {
+	db := s.reader().Where("published = ?", true)
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + q + "%"
+		db = db.Where("title LIKE ? OR url LIKE ? OR content_text LIKE ?", like, like, like)
+	}
+	if tag := strings.TrimSpace(c.Query("tag")); tag != "" {
+		db = db.Where("JSON_CONTAINS(tags_json, JSON_QUOTE(?))", tag)
+	}
+	if path := strings.Trim(c.Query("path"), "/"); path != "" {
+		db = db.Where("hierarchy_path = ? OR hierarchy_path LIKE ?", path, path+"/%")
+	}
+	limit := parseLimit(c.Query("limit"), 100)
+	if limit == 0 || limit > 500 {
+		limit = 500
+	}
+	var items []models.Archive
+	if err := db.Order("created_at desc").Limit(limit).Offset(parseLimit(c.Query("offset"), 0)).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	resp := make([]PublicArchiveResponse, 0, len(items))
+	for _, item := range items {
+		resp = append(resp, toPublicArchiveResponse(item))
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) publicArchive(c *gin.Context) {
+	item, ok := s.loadPublishedArchive(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, toPublicArchiveResponse(item))
+}
+
+func (s *Server) publicArchiveHTML(c *gin.Context) {
+	if item, ok := s.loadPublishedArchive(c); ok && !s.redirectToViewer(c, item.ID, "/archives/", true) {
+		s.serveArchiveHTML(c, item.ID, true)
+	}
+}
+
+func (s *Server) publicAsset(c *gin.Context) {
+	if item, ok := s.loadPublishedArchive(c); ok && !s.redirectToViewer(c, item.ID, "/assets/", true) {
+		s.getAsset(c)
+	}
+}
+
+func (s *Server) publicGraph(c *gin.Context) {
+	filter, err := parseGraphFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter.published = true
+	s.serveGraph(c, filter)
+}
+
+// loadPublishedArchive answers 404 for unknown and unpublished archives
+// alike, so the public surface doesn't reveal what exists.
+func (s *Server) loadPublishedArchive(c *gin.Context) (models.Archive, bool) {
+	var item models.Archive
+	err := s.DB.First(&item, "id = ? AND published = ?", c.Param("id"), true).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		}
+		return item, false
+	}
+	return item, true
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type QuickCaptureRequest struct {
+	URL string `json:"url" form:"url"`
+	// Tags may also be one comma-separated string, which is easier to
+	// build in a launcher script.
+	Tags []string `json:"tags" form:"tags"`
+}
+
+// quickCapture queues a server-side capture and answers straight away with
+// the job, for bookmarklets and launcher scripts that shouldn't wait for
+// the page to be fetched. Poll GET /api/quick/:id for the outcome.
+func (s *Server) quickCapture(c *gin.Context) {
+	var req QuickCaptureRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an http(s) link"})
+		return
+	}
+	job := s.queueCapture(CreateArchiveRequest{
+		URL:    u.String(),
+		Tags:   splitList(strings.Join(req.Tags, ",")),
+		Source: "quick",
+	}, captureMetaFrom(c))
+	c.JSON(http.StatusAccepted, gin.H{"id": job.ID, "status": job.Status})
+}
+
+func (s *Server) quickCaptureStatus(c *gin.Context) {
+	s.shareMu.Lock()
+	job, ok := s.shareJobs[c.Param("id")]
+	var snapshot shareJob
+	if ok {
+		snapshot = *job
+	}
+	s.shareMu.Unlock()
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found or expired"})
+		return
+	}
+	c.JSON(http.StatusOK, snapshot)
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+)
+
+const quickSearchLimit = 10
+
+type QuickSearchResult struct {
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	URL    string `json:"url"`
+	Path   string `json:"path"`
+	Domain string `json:"domain"`
+	// ArchiveURL opens the archived copy.
+	ArchiveURL string `json:"archiveUrl"`
+}
+
+// quickSearch serves launcher integrations typing ahead: the newest ten
+// matches of q, with just the columns a result row shows, cached until the
+// next archive or taxonomy change.
+func (s *Server) quickSearch(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	results := []QuickSearchResult{}
+	if q == "" {
+		c.JSON(http.StatusOK, gin.H{"results": results})
+		return
+	}
+	var items []models.Archive
+	query := s.filterArchives(s.reader().Model(&models.Archive{}), c)
+	if err := query.Select("id, slug, title, url, hierarchy_path, domain").Order("created_at desc").Limit(quickSearchLimit).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	base := strings.TrimRight(s.BaseURL, "/")
+	for _, item := range items {
+		results = append(results, QuickSearchResult{
+			ID:         item.ID,
+			Title:      item.Title,
+			URL:        item.URL,
+			Path:       item.HierarchyPath,
+			Domain:     item.Domain,
+			ArchiveURL: base + "/archive/" + archiveRef(item),
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/textutil"
+)
+
+type QuizQuestion struct {
+	Question     string `json:"question"`
+	Answer       string `json:"answer"`
+	ArchiveID    string `json:"archiveId"`
+	ArchiveTitle string `json:"archiveTitle"`
+}
+
+type QuizResponse struct {
+	Path      string         `json:"path"`
+	Archives  []string       `json:"archives"`
+	Questions []QuizQuestion `json:"questions"`
+}
+
+// createQuiz samples archives under a taxonomy branch and asks the LLM for a
+// short quiz; every answer points back at the archive it came from.
+func (s *Server) createQuiz(c *gin.Context) {
+	if s.LLM == nil || !s.LLM.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "llm not configured"})
+		return
+	}
+	path := strings.Trim(strings.TrimSpace(c.Query("path")), "/")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path required"})
+		return
+	}
+	sample := parseLimit(c.Query("archives"), 5)
+	if sample < 1 || sample > 10 {
+		sample = 5
+	}
+	count := parseLimit(c.Query("questions"), 5)
+	if count < 1 || count > 20 {
+		count = 5
+	}
+
+	var items []models.Archive
+	err := s.DB.Where("(hierarchy_path = ? OR hierarchy_path LIKE ?) AND content_text <> ''", path, path+"/%").
+		Order("RAND()").
+		Limit(sample).
+		Find(&items).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if len(items) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no archives under path"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 120*time.Second)
+	defer cancel()
+	questions, err := s.askQuiz(ctx, items, count)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	out := QuizResponse{Path: path, Archives: make([]string, 0, len(items)), Questions: questions}
+	for _, item := range items {
+		out.Archives = append(out.Archives, item.ID)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) askQuiz(ctx context.Context, items []models.Archive, count int) ([]QuizQuestion, error) {
+	// Split the prompt budget across sources so one long page can't crowd
+	// out the rest.
+	perSource := 8000 / len(items)
+	var sources strings.Builder
+	for i, item := range items {
+		content := textutil.Truncate(strings.TrimSpace(item.ContentText), perSource)
+		fmt.Fprintf(&sources, "[%d] %s\n%s\n\n", i+1, item.Title, content)
+	}
+	system := "You write short quizzes from study material. Return strict JSON only."
+	user := fmt.Sprintf("Write %d quiz questions drawn from the numbered sources below, in the same language as the sources.\n"+
+		"Spread the questions across sources; answers must be short and supported by the cited source.\n"+
+		"Return JSON: {\"questions\": [{\"question\": string, \"answer\": string, \"source\": number}]}.\n\n%s",
+		count, sources.String())
+	raw, err := s.LLM.ChatJSON(ctx, system, user, 0.4)
+	if err != nil {
+		return nil, err
+	}
+	var resp struct {
+		Questions []struct {
+			Question string `json:"question"`
+			Answer   string `json:"answer"`
+			Source   int    `json:"source"`
+		} `json:"questions"`
+	}
+	if err := json.Unmarshal([]byte(extractJSON(raw)), &resp); err != nil {
+		return nil, errors.New("llm invalid json")
+	}
+	out := make([]QuizQuestion, 0, len(resp.Questions))
+	for _, q := range resp.Questions {
+		q.Question = strings.TrimSpace(q.Question)
+		q.Answer = strings.TrimSpace(q.Answer)
+		// Drop questions citing a source we never sent.
+		if q.Question == "" || q.Answer == "" || q.Source < 1 || q.Source > len(items) {
+			continue
+		}
+		item := items[q.Source-1]
+		out = append(out, QuizQuestion{Question: q.Question, Answer: q.Answer, ArchiveID: item.ID, ArchiveTitle: item.Title})
+		if len(out) == count {
+			break
+		}
+	}
+	if len(out) == 0 {
+		return nil, errors.New("llm returned no questions")
+	}
+	return out, nil
+}
@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/auth"
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+)
+
+const keyStorageBytes = "migration.storage_bytes"
+
+type QuotaUsage struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+}
+
+type UserStorage struct {
+	User     string `json:"user"`
+	Bytes    int64  `json:"bytes"`
+	Archives int64  `json:"archives"`
+}
+
+type QuotaStatus struct {
+	Global QuotaUsage    `json:"global"`
+	User   *QuotaUsage   `json:"user,omitempty"`
+	Users  []UserStorage `json:"users,omitempty"`
+}
+
+// storageUsed sums the recorded object sizes, of one user's archives when
+// user is not empty.
+func (s *Server) storageUsed(user string) (int64, error) {
+	db := s.DB.Model(&models.Archive{})
+	if user != "" {
+		db = db.Where("captured_by = ?", user)
+	}
+	var used int64
+	err := db.Select("COALESCE(SUM(storage_bytes), 0)").Scan(&used).Error
+	return used, err
+}
+
+// checkQuota fails with a 413 when a quota is already used up, or when
+// storing adding more bytes for user would go over it. Zero limits are
+// unlimited.
+func (s *Server) checkQuota(user string, adding int64) error {
+	if s.QuotaBytes > 0 {
+		used, err := s.storageUsed("")
+		if err != nil {
+			return &captureError{status: http.StatusInternalServerError, msg: "db query failed"}
+		}
+		if used >= s.QuotaBytes || used+adding > s.QuotaBytes {
+			return &captureError{status: http.StatusRequestEntityTooLarge, msg: quotaMessage("storage quota exceeded", used, adding, s.QuotaBytes)}
+		}
+	}
+	if s.UserQuotaBytes > 0 && s.AuthEnabled && user != "" {
+		used, err := s.storageUsed(user)
+		if err != nil {
+			return &captureError{status: http.StatusInternalServerError, msg: "db query failed"}
+		}
+		if used >= s.UserQuotaBytes || used+adding > s.UserQuotaBytes {
+			return &captureError{status: http.StatusRequestEntityTooLarge, msg: quotaMessage("user storage quota exceeded", used, adding, s.UserQuotaBytes)}
+		}
+	}
+	return nil
+}
+
+func quotaMessage(prefix string, used, adding, limit int64) string {
+	if adding > 0 {
+		return fmt.Sprintf("%s: capture needs %d bytes, %d of %d bytes used", prefix, adding, used, limit)
+	}
+	return fmt.Sprintf("%s: %d of %d bytes used", prefix, used, limit)
+}
+
+// captureBytes is what a capture wrote to storage.
+func captureBytes(html []byte, assets []processor.Asset, screenshot []byte) int64 {
+	total := int64(len(html) + len(screenshot))
+	for _, a := range assets {
+		total += a.Size
+	}
+	return total
+}
+
+// quotaStatus reports usage against the quotas; admins also get usage per
+// user.
+func (s *Server) quotaStatus(c *gin.Context) {
+	p := currentPrincipal(c)
+	status := QuotaStatus{Global: QuotaUsage{Limit: s.QuotaBytes}}
+	var err error
+	if status.Global.Used, err = s.storageUsed(""); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if s.AuthEnabled {
+		status.User = &QuotaUsage{Limit: s.UserQuotaBytes}
+		if status.User.Used, err = s.storageUsed(p.Username); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+	}
+	if auth.RoleAllows(p.Role, auth.RoleAdmin) {
+		status.Users = []UserStorage{}
+		if err := s.DB.Model(&models.Archive{}).
+			Select("captured_by AS `user`, COALESCE(SUM(storage_bytes), 0) AS bytes, COUNT(*) AS archives").
+			Group("captured_by").Order("bytes desc").Scan(&status.Users).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// BackfillStorageBytes measures archives captured before storage accounting
+// existed by listing their objects, and attributes them to whoever captured
+// them according to the history. It runs once per database.
+func (s *Server) BackfillStorageBytes() {
+	var done int64
+	if err := s.DB.Model(&models.AppSetting{}).Where("setting_key = ?", keyStorageBytes).Count(&done).Error; err != nil {
+		log.Printf("storage accounting backfill failed: %v", err)
+		return
+	}
+	if done > 0 {
+		return
+	}
+
+	if err := s.DB.Exec("UPDATE archives a JOIN archive_events e ON e.archive_id = a.id AND e.action = ? "+
+		"SET a.captured_by = e.actor WHERE a.captured_by = ''", EventCapture).Error; err != nil {
+		log.Printf("storage accounting backfill failed: %v", err)
+		return
+	}
+
+	ctx := context.Background()
+	lastID := ""
+	updated, failed := 0, 0
+	for {
+		var items []models.Archive
+		if err := s.DB.Select("id").Where("id > ? AND storage_bytes = 0", lastID).
+			Order("id asc").Limit(200).Find(&items).Error; err != nil {
+			log.Printf("storage accounting backfill failed: %v", err)
+			return
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			lastID = item.ID
+			size, err := s.Store.PrefixSize(ctx, storage.ArchivePrefix(item.ID)+"/")
+			if err != nil {
+				log.Printf("storage accounting backfill failed for %s: %v", item.ID, err)
+				failed++
+				continue
+			}
+			if size == 0 {
+				continue
+			}
+			if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).UpdateColumn("storage_bytes", size).Error; err != nil {
+				log.Printf("storage accounting backfill failed: %v", err)
+				return
+			}
+			updated++
+		}
+	}
+	if updated > 0 {
+		log.Printf("storage bytes backfilled for %d archives", updated)
+	}
+	if failed > 0 {
+		return
+	}
+	if err := s.DB.Create(&models.AppSetting{Key: keyStorageBytes, Value: "1"}).Error; err != nil {
+		log.Printf("storage accounting backfill failed: %v", err)
+	}
+}
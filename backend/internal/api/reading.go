@@ -0,0 +1,59 @@
+package api
+
+import (
+	"log"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+// applyContentStats computes the capture-time text statistics: word count,
+// reading time and the simhash used for near-duplicate detection.
+func applyContentStats(item *models.Archive) {
+	item.WordCount, item.ReadMinutes = processor.ReadingStats(item.ContentText)
+	item.SimHash = processor.SimHash(item.ContentText)
+	bands := processor.SimHashBands(item.SimHash)
+	item.SimBand0, item.SimBand1, item.SimBand2, item.SimBand3 = bands[0], bands[1], bands[2], bands[3]
+}
+
+// BackfillContentStats fills word counts, reading times and simhashes for
+// archives captured before they were computed at capture time.
+func (s *Server) BackfillContentStats() {
+	lastID := ""
+	updated := 0
+	for {
+		var items []models.Archive
+		if err := s.DB.Select("id", "content_text").
+			Where("id > ? AND (word_count = 0 OR sim_hash = 0) AND content_text <> ''", lastID).
+			Order("id asc").Limit(200).Find(&items).Error; err != nil {
+			log.Printf("content stats backfill failed: %v", err)
+			return
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			lastID = item.ID
+			applyContentStats(&item)
+			if item.WordCount == 0 {
+				continue
+			}
+			if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).UpdateColumns(map[string]any{
+				"word_count":   item.WordCount,
+				"read_minutes": item.ReadMinutes,
+				"sim_hash":     item.SimHash,
+				"sim_band0":    item.SimBand0,
+				"sim_band1":    item.SimBand1,
+				"sim_band2":    item.SimBand2,
+				"sim_band3":    item.SimBand3,
+			}).Error; err != nil {
+				log.Printf("content stats backfill failed: %v", err)
+				return
+			}
+			updated++
+		}
+	}
+	if updated > 0 {
+		log.Printf("content stats backfilled for %d archives", updated)
+	}
+}
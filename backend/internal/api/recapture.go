@@ -0,0 +1,408 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+)
+
+const (
+	recaptureBatchSize = 50
+	recaptureActor     = "recapture"
+)
+
+type RecaptureRuleRequest struct {
+	Name       string `json:"name"`
+	Tag        string `json:"tag"`
+	PathPrefix string `json:"pathPrefix"`
+	EveryDays  int    `json:"everyDays"`
+	Enabled    bool   `json:"enabled"`
+}
+
+type RecaptureStatus struct {
+	Running    bool       `json:"running"`
+	Checked    int        `json:"checked"`
+	Recaptured int        `json:"recaptured"`
+	Failed     int        `json:"failed"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+func (r RecaptureRuleRequest) validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("name required")
+	}
+	if strings.TrimSpace(r.Tag) == "" && strings.Trim(strings.TrimSpace(r.PathPrefix), "/") == "" {
+		return errors.New("tag or pathPrefix required")
+	}
+	if r.EveryDays <= 0 {
+		return errors.New("everyDays must be greater than zero")
+	}
+	return nil
+}
+
+func applyRecaptureRequest(rule *models.RecaptureRule, req RecaptureRuleRequest) {
+	rule.Name = strings.TrimSpace(req.Name)
+	rule.Tag = strings.TrimSpace(req.Tag)
+	rule.PathPrefix = strings.Trim(strings.TrimSpace(req.PathPrefix), "/")
+	rule.EveryDays = req.EveryDays
+	rule.Enabled = req.Enabled
+}
+
+func (s *Server) listRecaptureRules(c *gin.Context) {
+	var rules []models.RecaptureRule
+	if err := s.DB.Order("name asc").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (s *Server) createRecaptureRule(c *gin.Context) {
+	var req RecaptureRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule := models.RecaptureRule{ID: uuid.New().String()}
+	applyRecaptureRequest(&rule, req)
+	if err := s.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditRecaptureSave, rule.ID, nil, rule)
+	c.JSON(http.StatusOK, rule)
+}
+
+func (s *Server) updateRecaptureRule(c *gin.Context) {
+	var req RecaptureRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var rule models.RecaptureRule
+	if err := s.DB.First(&rule, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	before := rule
+	applyRecaptureRequest(&rule, req)
+	if err := s.DB.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditRecaptureSave, rule.ID, before, rule)
+	c.JSON(http.StatusOK, rule)
+}
+
+func (s *Server) deleteRecaptureRule(c *gin.Context) {
+	var rule models.RecaptureRule
+	if err := s.DB.First(&rule, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err := s.DB.Delete(&models.RecaptureRule{}, "id = ?", rule.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditRecaptureDelete, rule.ID, rule, nil)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+func (s *Server) listArchiveVersions(c *gin.Context) {
+	var versions []models.ArchiveVersion
+	if err := s.reader().Where("archive_id = ?", c.Param("id")).Order("captured_at desc").Find(&versions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// getVersionHTML serves a version's snapshot. Like an archive's page it sits
+// two levels below /api, so its relative asset links resolve to
+// /api/assets/<version id>/.
+func (s *Server) getVersionHTML(c *gin.Context) {
+	var version models.ArchiveVersion
+	if err := s.DB.Select("id").First(&version, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	s.serveArchivePage(c, version.ID, "index.html", false)
+}
+
+func (s *Server) recaptureArchiveNow(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.Omit("content_text").First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	version, err := s.recaptureArchive(c.Request.Context(), item, currentPrincipal(c).Username)
+	var capErr *captureError
+	if errors.As(err, &capErr) {
+		c.JSON(capErr.status, gin.H{"error": capErr.msg})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "recapture failed"})
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
+
+// recaptureArchive fetches the live page again and stores it as a new
+// version, then drops the oldest versions beyond RecaptureKeepVersions.
+// Versions count towards the archive's storage, and so its owner's quota.
+func (s *Server) recaptureArchive(ctx context.Context, item models.Archive, actor string) (models.ArchiveVersion, error) {
+	ctx, cancel := context.WithTimeout(ctx, 90*time.Second)
+	defer cancel()
+	jar, err := s.captureJar(item.URL, nil)
+	if err != nil {
+		return models.ArchiveVersion{}, &captureError{status: http.StatusInternalServerError, msg: "load cookies failed"}
+	}
+	opts := processor.Options{Jar: jar, HostRules: s.userHostRules(item.CapturedBy)}
+	page, err := s.Processor.FetchPage(ctx, item.URL, opts)
+	if errors.Is(err, processor.ErrHostNotAllowed) {
+		return models.ArchiveVersion{}, &captureError{status: http.StatusForbidden, msg: "host not allowed for capture"}
+	}
+	if err != nil {
+		return models.ArchiveVersion{}, &captureError{status: http.StatusBadGateway, msg: "fetch failed: " + err.Error()}
+	}
+	id := uuid.New().String()
+	result, err := s.Processor.ProcessWithOptions(ctx, id, page.FinalURL, page.HTML, opts)
+	if err != nil {
+		s.discardArchiveObjects(id)
+		return models.ArchiveVersion{}, &captureError{status: http.StatusInternalServerError, msg: "processing failed"}
+	}
+	if err := s.Store.PutBytes(ctx, storage.ArchivePrefix(id)+"/index.html", result.HTML, "text/html; charset=utf-8"); err != nil {
+		s.discardArchiveObjects(id)
+		return models.ArchiveVersion{}, &captureError{status: http.StatusInternalServerError, msg: "store html failed"}
+	}
+	stored := captureBytes(result.HTML, result.Assets, nil)
+	if err := s.checkQuota(item.CapturedBy, stored); err != nil {
+		s.discardArchiveObjects(id)
+		return models.ArchiveVersion{}, err
+	}
+	title, text := processor.ExtractText(page.HTML)
+	s.scrubText(&title, &text)
+	now := time.Now()
+	version := models.ArchiveVersion{
+		ID:           id,
+		ArchiveID:    item.ID,
+		Title:        truncate(title, 500),
+		FinalURL:     truncate(page.FinalURL, 2000),
+		HTMLSHA256:   contentHash(string(result.HTML)),
+		StorageBytes: stored,
+		Completeness: completeness(result.Assets, result.Missing, title, text),
+		Actor:        actor,
+		CapturedAt:   now,
+	}
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Archive{}).Where("id = ?", item.ID).Updates(map[string]any{
+			"recaptured_at": now,
+			"storage_bytes": gorm.Expr("storage_bytes + ?", stored),
+		}).Error
+	})
+	if err != nil {
+		s.discardArchiveObjects(id)
+		return models.ArchiveVersion{}, &captureError{status: http.StatusInternalServerError, msg: "db insert failed"}
+	}
+	if err := s.pruneArchiveVersions(ctx, item.ID, s.RecaptureKeepVersions); err != nil {
+		log.Printf("prune versions of %s failed: %v", item.ID, err)
+	}
+	s.publishEvent(LiveArchiveUpdated, gin.H{"id": item.ID, "action": "recapture", "actor": actor})
+	return version, nil
+}
+
+// pruneArchiveVersions keeps the newest keep versions of an archive; keep 0
+// removes them all.
+func (s *Server) pruneArchiveVersions(ctx context.Context, archiveID string, keep int) error {
+	var old []models.ArchiveVersion
+	if err := s.DB.Where("archive_id = ?", archiveID).Order("captured_at desc").Offset(keep).Find(&old).Error; err != nil {
+		return err
+	}
+	for _, v := range old {
+		if err := s.Store.RemovePrefix(ctx, storage.ArchivePrefix(v.ID)); err != nil {
+			return err
+		}
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&models.ArchiveVersion{}, "id = ?", v.ID).Error; err != nil {
+				return err
+			}
+			return tx.Model(&models.Archive{}).Where("id = ?", archiveID).
+				UpdateColumn("storage_bytes", gorm.Expr("GREATEST(storage_bytes - ?, 0)", v.StorageBytes)).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) recaptureJobStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.getRecaptureStatus())
+}
+
+func (s *Server) runRecaptureNow(c *gin.Context) {
+	s.startRecapture()
+	c.JSON(http.StatusOK, s.getRecaptureStatus())
+}
+
+func (s *Server) stopRecapture(c *gin.Context) {
+	s.recaptureMu.Lock()
+	if s.recaptureCancel != nil {
+		s.recaptureCancel()
+		s.recaptureCancel = nil
+	}
+	s.recaptureMu.Unlock()
+	c.JSON(http.StatusOK, s.getRecaptureStatus())
+}
+
+func (s *Server) getRecaptureStatus() RecaptureStatus {
+	s.recaptureMu.Lock()
+	defer s.recaptureMu.Unlock()
+	return s.recaptureStatus
+}
+
+func (s *Server) startRecapture() {
+	s.recaptureMu.Lock()
+	defer s.recaptureMu.Unlock()
+	if s.recaptureStatus.Running {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	s.recaptureCancel = cancel
+	s.recaptureStatus = RecaptureStatus{Running: true, StartedAt: &now}
+	go s.runRecapture(ctx)
+}
+
+// runRecapture recaptures the archives that are due: those with their own
+// RecaptureDays first, then those matched by each enabled rule. An archive
+// is due when its latest snapshot, the original capture or the last
+// version, is older than its interval.
+func (s *Server) runRecapture(ctx context.Context) {
+	lastErr := ""
+	defer func() {
+		now := time.Now()
+		s.recaptureMu.Lock()
+		s.recaptureStatus.Running = false
+		s.recaptureStatus.FinishedAt = &now
+		s.recaptureStatus.LastError = lastErr
+		s.recaptureCancel = nil
+		s.recaptureMu.Unlock()
+		if lastErr != "" {
+			log.Printf("recapture: %s", lastErr)
+		}
+	}()
+
+	now := time.Now()
+	perArchive := func() *gorm.DB {
+		return s.DB.Model(&models.Archive{}).
+			Where("recapture_days > 0 AND COALESCE(recaptured_at, captured_at, created_at) < DATE_SUB(?, INTERVAL recapture_days DAY)", now)
+	}
+	if _, err := s.recaptureDue(ctx, perArchive); err != nil {
+		lastErr = err.Error()
+		return
+	}
+	var rules []models.RecaptureRule
+	if err := s.DB.Where("enabled = ?", true).Order("name asc").Find(&rules).Error; err != nil {
+		lastErr = err.Error()
+		return
+	}
+	for _, rule := range rules {
+		affected, err := s.recaptureDue(ctx, func() *gorm.DB { return s.recaptureRuleQuery(rule, now) })
+		_ = s.DB.Model(&models.RecaptureRule{}).Where("id = ?", rule.ID).
+			Updates(map[string]any{"last_run_at": now, "last_affected": affected}).Error
+		if err != nil {
+			lastErr = err.Error()
+			return
+		}
+	}
+}
+
+func (s *Server) recaptureRuleQuery(rule models.RecaptureRule, now time.Time) *gorm.DB {
+	query := s.DB.Model(&models.Archive{}).Where("recapture_days = 0").
+		Where("COALESCE(recaptured_at, captured_at, created_at) < ?", now.AddDate(0, 0, -rule.EveryDays))
+	if rule.Tag != "" {
+		query = query.Where("JSON_CONTAINS(tags_json, JSON_QUOTE(?))", rule.Tag)
+	}
+	if rule.PathPrefix != "" {
+		query = query.Where("id IN (?)", s.DB.Model(&models.ArchivePath{}).Select("archive_id").
+			Where("path = ? OR path LIKE ?", rule.PathPrefix, rule.PathPrefix+"/%"))
+	}
+	return query
+}
+
+func (s *Server) recaptureDue(ctx context.Context, query func() *gorm.DB) (int, error) {
+	recaptured := 0
+	lastID := ""
+	for {
+		var items []models.Archive
+		if err := query().Select("id", "url", "captured_by").Where("id > ?", lastID).
+			Order("id asc").Limit(recaptureBatchSize).Find(&items).Error; err != nil {
+			return recaptured, err
+		}
+		if len(items) == 0 {
+			return recaptured, nil
+		}
+		for _, item := range items {
+			if ctx.Err() != nil {
+				return recaptured, errors.New("canceled")
+			}
+			_, err := s.recaptureArchive(ctx, item, recaptureActor)
+			s.recaptureMu.Lock()
+			s.recaptureStatus.Checked++
+			if err != nil {
+				s.recaptureStatus.Failed++
+			} else {
+				s.recaptureStatus.Recaptured++
+				recaptured++
+			}
+			s.recaptureMu.Unlock()
+		}
+		lastID = items[len(items)-1].ID
+	}
+}
+
+// StartRecaptureScheduler runs due recaptures every interval; 0 disables
+// it, leaving only manual runs.
+func (s *Server) StartRecaptureScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.startRecapture()
+		}
+	}()
+}
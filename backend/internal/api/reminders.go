@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/notify"
+)
+
+// EventArchiveReminder is the webhook type sent when a reminder falls due.
+const EventArchiveReminder = "archive.reminder"
+
+const reminderBatch = 200
+
+// StartReminderScheduler fires due reminders every interval; 0 disables it.
+func (s *Server) StartReminderScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if !s.Ready() {
+				continue
+			}
+			if n, err := s.fireReminders(ctx); err != nil {
+				log.Printf("reminders: %v", err)
+			} else if n > 0 {
+				log.Printf("reminders: %d due", n)
+			}
+		}
+	}()
+}
+
+// fireReminders notifies once per reminder. Each archive is claimed by
+// setting reminded_at before anything is sent, so replicas sharing the
+// database don't send the same reminder twice.
+func (s *Server) fireReminders(ctx context.Context) (int, error) {
+	now := time.Now()
+	var due []models.Archive
+	if err := s.DB.Select("id", "slug", "title", "url", "tags_json", "remind_at", "remind_note").
+		Where("remind_at <= ? AND reminded_at IS NULL", now).
+		Order("remind_at asc").Limit(reminderBatch).Find(&due).Error; err != nil {
+		return 0, err
+	}
+	fired := 0
+	for _, item := range due {
+		claim := s.DB.Model(&models.Archive{}).
+			Where("id = ? AND reminded_at IS NULL", item.ID).
+			UpdateColumn("reminded_at", now)
+		if claim.Error != nil {
+			return fired, claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			continue
+		}
+		fired++
+		s.publishEvent(LiveArchiveUpdated, gin.H{"id": item.ID, "action": "reminder", "actor": "reminder"})
+		if s.ReminderWebhookURL == "" {
+			continue
+		}
+		payload := gin.H{
+			"type":       EventArchiveReminder,
+			"archiveId":  item.ID,
+			"title":      item.Title,
+			"url":        item.URL,
+			"tags":       archiveTags(item),
+			"note":       item.RemindNote,
+			"remindAt":   item.RemindAt,
+			"archiveUrl": strings.TrimRight(s.BaseURL, "/") + "/archive/" + archiveRef(item),
+		}
+		if err := notify.Webhook(ctx, s.ReminderWebhookURL, payload); err != nil {
+			log.Printf("reminder webhook for %s: %v", item.ID, err)
+		}
+	}
+	return fired, nil
+}
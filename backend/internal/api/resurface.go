@@ -0,0 +1,231 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+const (
+	// Archives younger than this are still fresh in memory.
+	resurfaceMinAge = 30 * 24 * time.Hour
+	// Only the best candidates are kept between runs.
+	resurfaceKeep = 200
+)
+
+type ResurfaceItem struct {
+	Archive ArchiveResponse       `json:"archive"`
+	Score   models.ResurfaceScore `json:"score"`
+}
+
+// markArchiveRead records that the archive was opened for reading; the
+// resurface job prefers archives nobody has read.
+func (s *Server) markArchiveRead(c *gin.Context) {
+	now := time.Now()
+	tx := s.DB.Model(&models.Archive{}).Where("id = ?", c.Param("id")).UpdateColumns(map[string]any{
+		"view_count":     gorm.Expr("view_count + 1"),
+		"last_viewed_at": now,
+	})
+	if tx.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	if tx.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// listResurface returns the highest scored archives that have not been read
+// or opened since they were scored.
+func (s *Server) listResurface(c *gin.Context) {
+	limit := parseLimit(c.Query("limit"), 10)
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+	var scores []models.ResurfaceScore
+	err := s.DB.Table("resurface_scores").
+		Select("resurface_scores.*").
+		Joins("JOIN archives ON archives.id = resurface_scores.archive_id").
+		Where("archives.last_viewed_at IS NULL OR archives.last_viewed_at < resurface_scores.computed_at").
+		Where("NOT EXISTS (SELECT 1 FROM view_events WHERE view_events.archive_id = archives.id AND view_events.viewed_at >= resurface_scores.computed_at)").
+		Order("resurface_scores.score desc").
+		Limit(limit).
+		Scan(&scores).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	ids := make([]string, 0, len(scores))
+	for _, sc := range scores {
+		ids = append(ids, sc.ArchiveID)
+	}
+	byID := map[string]models.Archive{}
+	if len(ids) > 0 {
+		var items []models.Archive
+		if err := s.DB.Omit("content_text").Where("id IN ?", ids).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		for _, item := range items {
+			byID[item.ID] = item
+		}
+	}
+	out := make([]ResurfaceItem, 0, len(scores))
+	for _, sc := range scores {
+		if item, ok := byID[sc.ArchiveID]; ok {
+			out = append(out, ResurfaceItem{Archive: toArchiveResponse(item, nil), Score: sc})
+		}
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) rebuildResurfaceNow(c *gin.Context) {
+	n, err := s.rebuildResurface(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "resurface scoring failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"scored": n})
+}
+
+// StartResurfaceScheduler scores archives once at startup and then every
+// interval.
+func (s *Server) StartResurfaceScheduler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if n, err := s.rebuildResurface(ctx); err != nil {
+				log.Printf("resurface: scoring failed: %v", err)
+			} else {
+				log.Printf("resurface: scored %d archives", n)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// rebuildResurface scores every archive older than resurfaceMinAge:
+//
+//	age        grows to 1 over a year since capture
+//	unread     1 if never opened, otherwise grows with time since last read
+//	centrality how many other archives share its tags/entities, normalized
+//	affinity   share of its tags the user read about in the last 90 days
+//
+// and keeps the top resurfaceKeep.
+func (s *Server) rebuildResurface(ctx context.Context) (int, error) {
+	var items []models.Archive
+	err := s.DB.WithContext(ctx).
+		Select("id", "tags_json", "entities_json", "captured_at", "created_at", "view_count", "last_viewed_at").
+		Find(&items).Error
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+
+	degree := map[string]int{}
+	maxDegree := 0
+	for _, link := range coCitationLinks(items, 1) {
+		degree[link.Source]++
+		degree[link.Target]++
+	}
+	for _, d := range degree {
+		if d > maxDegree {
+			maxDegree = d
+		}
+	}
+
+	// Pages opened count as reading as much as archives marked read.
+	var opened []string
+	if err := s.DB.WithContext(ctx).Model(&models.ViewEvent{}).Distinct("archive_id").
+		Where("viewed_at > ?", now.Add(-90*24*time.Hour)).Pluck("archive_id", &opened).Error; err != nil {
+		return 0, err
+	}
+	recentlyOpened := make(map[string]bool, len(opened))
+	for _, id := range opened {
+		recentlyOpened[id] = true
+	}
+	recentTags := map[string]bool{}
+	for _, item := range items {
+		if recentlyOpened[item.ID] || (item.LastViewedAt != nil && now.Sub(*item.LastViewedAt) < 90*24*time.Hour) {
+			for _, tag := range archiveTags(item) {
+				recentTags[tag] = true
+			}
+		}
+	}
+
+	scores := make([]models.ResurfaceScore, 0)
+	for _, item := range items {
+		captured := item.CreatedAt
+		if item.CapturedAt != nil {
+			captured = *item.CapturedAt
+		}
+		age := now.Sub(captured)
+		if age < resurfaceMinAge {
+			continue
+		}
+		sc := models.ResurfaceScore{ArchiveID: item.ID, ComputedAt: now}
+		sc.Age = math.Min(1, age.Hours()/(365*24))
+		switch {
+		case item.ViewCount == 0 || item.LastViewedAt == nil:
+			sc.Unread = 1
+		default:
+			sc.Unread = 0.5 * math.Min(1, now.Sub(*item.LastViewedAt).Hours()/(180*24))
+		}
+		if maxDegree > 0 {
+			sc.Centrality = float64(degree["arc:"+item.ID]) / float64(maxDegree)
+		}
+		if tags := archiveTags(item); len(tags) > 0 && len(recentTags) > 0 {
+			hits := 0
+			for _, tag := range tags {
+				if recentTags[tag] {
+					hits++
+				}
+			}
+			sc.Affinity = float64(hits) / float64(len(tags))
+		}
+		sc.Score = 0.3*sc.Age + 0.3*sc.Unread + 0.25*sc.Centrality + 0.15*sc.Affinity
+		scores = append(scores, sc)
+	}
+	sort.Slice(scores, func(i, j int) bool { return scores[i].Score > scores[j].Score })
+	if len(scores) > resurfaceKeep {
+		scores = scores[:resurfaceKeep]
+	}
+
+	err = s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&models.ResurfaceScore{}).Error; err != nil {
+			return err
+		}
+		if len(scores) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(scores, 100).Error
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(scores), nil
+}
+
+func archiveTags(item models.Archive) []string {
+	tags := []string{}
+	if len(item.TagsJSON) > 0 {
+		_ = json.Unmarshal(item.TagsJSON, &tags)
+	}
+	return tags
+}
@@ -0,0 +1,340 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/datatypes"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/storage"
+)
+
+const (
+	RetentionDelete     = "delete"
+	RetentionDropAssets = "drop-assets"
+
+	EventRetention = "retention"
+
+	retentionSampleSize = 20
+	retentionBatchSize  = 100
+	retentionActor      = "retention"
+)
+
+type RetentionRuleRequest struct {
+	Name          string `json:"name"`
+	Tag           string `json:"tag"`
+	PathPrefix    string `json:"pathPrefix"`
+	OlderThanDays int    `json:"olderThanDays"`
+	Action        string `json:"action"`
+	Enabled       bool   `json:"enabled"`
+}
+
+type RetentionMatch struct {
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	URL        string     `json:"url"`
+	CapturedAt *time.Time `json:"capturedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+type RetentionReport struct {
+	Rule    models.RetentionRule `json:"rule"`
+	Cutoff  time.Time            `json:"cutoff"`
+	Matched int64                `json:"matched"`
+	Sample  []RetentionMatch     `json:"sample"`
+	// Affected is only set when the rule was actually executed.
+	Affected int    `json:"affected,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+func (r RetentionRuleRequest) validate() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("name required")
+	}
+	if strings.TrimSpace(r.Tag) == "" && strings.Trim(strings.TrimSpace(r.PathPrefix), "/") == "" {
+		return errors.New("tag or pathPrefix required")
+	}
+	if r.OlderThanDays <= 0 {
+		return errors.New("olderThanDays must be greater than zero")
+	}
+	switch r.Action {
+	case RetentionDelete, RetentionDropAssets:
+	default:
+		return fmt.Errorf("action must be %s or %s", RetentionDelete, RetentionDropAssets)
+	}
+	return nil
+}
+
+func applyRetentionRequest(rule *models.RetentionRule, req RetentionRuleRequest) {
+	rule.Name = strings.TrimSpace(req.Name)
+	rule.Tag = strings.TrimSpace(req.Tag)
+	rule.PathPrefix = strings.Trim(strings.TrimSpace(req.PathPrefix), "/")
+	rule.OlderThanDays = req.OlderThanDays
+	rule.Action = req.Action
+	rule.Enabled = req.Enabled
+}
+
+func (s *Server) listRetentionRules(c *gin.Context) {
+	var rules []models.RetentionRule
+	if err := s.DB.Order("name asc").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (s *Server) createRetentionRule(c *gin.Context) {
+	var req RetentionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	rule := models.RetentionRule{ID: uuid.New().String()}
+	applyRetentionRequest(&rule, req)
+	if err := s.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditRetentionSave, rule.ID, nil, rule)
+	c.JSON(http.StatusOK, rule)
+}
+
+func (s *Server) updateRetentionRule(c *gin.Context) {
+	var req RetentionRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var rule models.RetentionRule
+	if err := s.DB.First(&rule, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	before := rule
+	applyRetentionRequest(&rule, req)
+	if err := s.DB.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditRetentionSave, rule.ID, before, rule)
+	c.JSON(http.StatusOK, rule)
+}
+
+func (s *Server) deleteRetentionRule(c *gin.Context) {
+	var rule models.RetentionRule
+	if err := s.DB.First(&rule, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err := s.DB.Delete(&models.RetentionRule{}, "id = ?", rule.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditRetentionDelete, rule.ID, rule, nil)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// previewRetention is the dry run: what every rule (or ?id= one rule) would
+// touch right now, including disabled rules.
+func (s *Server) previewRetention(c *gin.Context) {
+	rules, err := s.loadRetentionRules(c.Query("id"), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	now := time.Now()
+	out := make([]RetentionReport, 0, len(rules))
+	for _, rule := range rules {
+		report, err := s.retentionReport(rule, now)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		out = append(out, report)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// runRetentionNow executes the enabled rules (or ?id= one rule, even if
+// disabled) immediately instead of waiting for the scheduler.
+func (s *Server) runRetentionNow(c *gin.Context) {
+	id := c.Query("id")
+	rules, err := s.loadRetentionRules(id, id == "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if id != "" && len(rules) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	out := s.applyRetention(c.Request.Context(), rules, currentPrincipal(c).Username)
+	s.recordAdminAudit(c, AuditRetentionRun, id, nil, out)
+	c.JSON(http.StatusOK, out)
+}
+
+func (s *Server) loadRetentionRules(id string, enabledOnly bool) ([]models.RetentionRule, error) {
+	var rules []models.RetentionRule
+	query := s.DB.Order("name asc")
+	if id != "" {
+		query = query.Where("id = ?", id)
+	}
+	if enabledOnly {
+		query = query.Where("enabled = ?", true)
+	}
+	return rules, query.Find(&rules).Error
+}
+
+func (s *Server) retentionQuery(rule models.RetentionRule, cutoff time.Time) *gorm.DB {
+	query := s.DB.Model(&models.Archive{}).Where("COALESCE(captured_at, created_at) < ?", cutoff)
+	if rule.Tag != "" {
+		query = query.Where("JSON_CONTAINS(tags_json, JSON_QUOTE(?))", rule.Tag)
+	}
+	if rule.PathPrefix != "" {
+		query = query.Where("id IN (?)", s.DB.Model(&models.ArchivePath{}).Select("archive_id").
+			Where("path = ? OR path LIKE ?", rule.PathPrefix, rule.PathPrefix+"/%"))
+	}
+	if rule.Action == RetentionDropAssets {
+		query = query.Where("html_path <> ''")
+	}
+	return query
+}
+
+func (s *Server) retentionReport(rule models.RetentionRule, now time.Time) (RetentionReport, error) {
+	cutoff := now.AddDate(0, 0, -rule.OlderThanDays)
+	report := RetentionReport{Rule: rule, Cutoff: cutoff, Sample: []RetentionMatch{}}
+	if err := s.retentionQuery(rule, cutoff).Count(&report.Matched).Error; err != nil {
+		return report, err
+	}
+	var items []models.Archive
+	if err := s.retentionQuery(rule, cutoff).Select("id", "title", "url", "html_path", "print_path", "dark_path", "captured_at", "created_at").
+		Order("created_at asc").Limit(retentionSampleSize).Find(&items).Error; err != nil {
+		return report, err
+	}
+	for _, item := range items {
+		report.Sample = append(report.Sample, RetentionMatch{ID: item.ID, Title: item.Title, URL: item.URL, CapturedAt: item.CapturedAt, CreatedAt: item.CreatedAt})
+	}
+	return report, nil
+}
+
+func (s *Server) applyRetention(ctx context.Context, rules []models.RetentionRule, actor string) []RetentionReport {
+	now := time.Now()
+	out := make([]RetentionReport, 0, len(rules))
+	for _, rule := range rules {
+		report, err := s.retentionReport(rule, now)
+		if err == nil {
+			report.Affected, err = s.executeRetention(ctx, rule, report.Cutoff, actor)
+		}
+		if err != nil {
+			report.Error = err.Error()
+		}
+		_ = s.DB.Model(&models.RetentionRule{}).Where("id = ?", rule.ID).
+			Updates(map[string]any{"last_run_at": now, "last_affected": report.Affected}).Error
+		out = append(out, report)
+	}
+	return out
+}
+
+func (s *Server) executeRetention(ctx context.Context, rule models.RetentionRule, cutoff time.Time, actor string) (int, error) {
+	affected := 0
+	lastID := ""
+	for {
+		if ctx.Err() != nil {
+			return affected, ctx.Err()
+		}
+		var items []models.Archive
+		if err := s.retentionQuery(rule, cutoff).Where("id > ?", lastID).Order("id asc").
+			Limit(retentionBatchSize).Find(&items).Error; err != nil {
+			return affected, err
+		}
+		if len(items) == 0 {
+			return affected, nil
+		}
+		for _, item := range items {
+			var err error
+			if rule.Action == RetentionDelete {
+				err = s.removeArchive(ctx, item, actor)
+			} else {
+				err = s.dropArchiveSnapshot(ctx, item, actor, EventRetention)
+			}
+			if err != nil {
+				return affected, err
+			}
+			affected++
+		}
+		lastID = items[len(items)-1].ID
+	}
+}
+
+// dropArchiveSnapshot turns an archive into a metadata-only one: the stored
+// HTML and assets go, the row, extracted text and screenshot stay. action is
+// the history event recorded for it.
+func (s *Server) dropArchiveSnapshot(ctx context.Context, item models.Archive, actor, action string) error {
+	prefix := storage.ArchivePrefix(item.ID)
+	if err := s.Store.RemovePrefix(ctx, prefix+"/assets/"); err != nil {
+		return err
+	}
+	for _, name := range []string{item.HTMLPath, item.PrintPath, item.DarkPath} {
+		if name == "" {
+			continue
+		}
+		if err := s.Store.Remove(ctx, prefix+"/"+name); err != nil {
+			return err
+		}
+	}
+	if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).Updates(map[string]any{
+		"html_path":    "",
+		"html_sha256":  "",
+		"print_path":   "",
+		"dark_path":    "",
+		"assets_json":  datatypes.JSON("[]"),
+		"capture_mode": CaptureModeMetadata,
+	}).Error; err != nil {
+		return err
+	}
+	snapshot := s.snapshotArchive(item)
+	s.recordArchiveEvent(item.ID, action, actor, snapshot, snapshot)
+	return nil
+}
+
+// StartRetentionScheduler applies the enabled rules every interval.
+func (s *Server) StartRetentionScheduler(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			rules, err := s.loadRetentionRules("", true)
+			if err != nil {
+				log.Printf("retention: load rules failed: %v", err)
+				continue
+			}
+			for _, report := range s.applyRetention(ctx, rules, retentionActor) {
+				if report.Affected > 0 || report.Error != "" {
+					log.Printf("retention: rule %q affected %d archives %s", report.Rule.Name, report.Affected, report.Error)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/cache"
+	"webarchive/internal/processor"
+	"webarchive/internal/settings"
+)
+
+type RuntimeSettingsRequest struct {
+	HTTPTimeoutSeconds *int    `json:"httpTimeoutSeconds"`
+	MaxAssetBytes      *int64  `json:"maxAssetBytes"`
+	AutoTagOnCapture   *bool   `json:"autoTagOnCapture"`
+	AutoTagWorkers     *int    `json:"autoTagWorkers"`
+	LLMTimeoutSeconds  *int    `json:"llmTimeoutSeconds"`
+	LLMConcurrency     *int    `json:"llmConcurrency"`
+	UserAgent          *string `json:"userAgent"`
+	// HeaderRules replaces the whole list when present.
+	HeaderRules *[]settings.HeaderRule `json:"headerRules"`
+	// RemovalRules replaces the whole list when present.
+	RemovalRules   *[]settings.RemovalRule `json:"removalRules"`
+	TaxonomyRouter *string                 `json:"taxonomyRouter"`
+	// Taxonomy limits only constrain new writes; existing nodes are kept.
+	TaxonomyMaxDepth       *int    `json:"taxonomyMaxDepth"`
+	TaxonomyMaxOptions     *int    `json:"taxonomyMaxOptions"`
+	TaxonomyMaxPathLength  *int    `json:"taxonomyMaxPathLength"`
+	TaxonomyMaxLabelLength *int    `json:"taxonomyMaxLabelLength"`
+	TaxonomyMaxLabelWords  *int    `json:"taxonomyMaxLabelWords"`
+	TaxonomyBannedChars    *string `json:"taxonomyBannedChars"`
+	TaxonomyLabelCase      *string `json:"taxonomyLabelCase"`
+	TaxonomyMergeSimilar   *bool   `json:"taxonomyMergeSimilar"`
+	// CaptureHosts and UserCaptureHosts replace the whole value when
+	// present.
+	CaptureHosts     *processor.HostRules            `json:"captureHosts"`
+	UserCaptureHosts *map[string]processor.HostRules `json:"userCaptureHosts"`
+
+	LLMTrace              *bool `json:"llmTrace"`
+	LLMTraceRetentionDays *int  `json:"llmTraceRetentionDays"`
+}
+
+// ApplyRuntime pushes runtime settings into the live components. It is safe
+// to call while captures and analysis are running.
+func (s *Server) ApplyRuntime(rt settings.RuntimeSettings) {
+	s.runtimeMu.Lock()
+	s.runtime = rt
+	s.AutoTag = rt.AutoTagOnCapture
+	s.runtimeMu.Unlock()
+	s.Cache.Invalidate(context.Background(), cache.ClientConfig)
+
+	if s.Processor != nil {
+		s.Processor.SetLimits(time.Duration(rt.HTTPTimeoutSeconds)*time.Second, rt.MaxAssetBytes)
+		rules := make(map[string]http.Header, len(rt.HeaderRules))
+		for _, rule := range rt.HeaderRules {
+			h := http.Header{}
+			for name, value := range rule.Headers {
+				h.Set(name, value)
+			}
+			rules[strings.TrimPrefix(strings.ToLower(strings.TrimSpace(rule.Domain)), ".")] = h
+		}
+		s.Processor.SetHeaders(rt.UserAgent, rules)
+		removal := make(map[string][]*processor.Selector, len(rt.RemovalRules))
+		for _, rule := range rt.RemovalRules {
+			// Validate has already rejected rules that do not parse.
+			sels, _ := processor.ParseSelectors(rule.Selectors)
+			domain := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(rule.Domain)), ".")
+			removal[domain] = append(removal[domain], sels...)
+		}
+		s.Processor.SetRemovalRules(removal)
+		s.Processor.SetHostRules(rt.CaptureHosts)
+	}
+	if s.TagQueue != nil {
+		s.TagQueue.SetWorkers(rt.AutoTagWorkers)
+	}
+	if s.LLM != nil {
+		s.LLM.SetTimeout(time.Duration(rt.LLMTimeoutSeconds) * time.Second)
+	}
+	s.LLMLimiter.SetLimit(rt.LLMConcurrency)
+	log.Printf("runtime settings applied: %+v", rt)
+}
+
+func (s *Server) runtimeSettings() settings.RuntimeSettings {
+	s.runtimeMu.Lock()
+	defer s.runtimeMu.Unlock()
+	return s.runtime
+}
+
+func (s *Server) autoTagOnCapture() bool {
+	s.runtimeMu.Lock()
+	defer s.runtimeMu.Unlock()
+	return s.AutoTag
+}
+
+// userHostRules returns the extra capture host rules for username, nil when
+// there are none.
+func (s *Server) userHostRules(username string) *processor.HostRules {
+	rules, ok := s.runtimeSettings().UserCaptureHosts[username]
+	if !ok {
+		return nil
+	}
+	return &rules
+}
+
+func (s *Server) getRuntimeSettings(c *gin.Context) {
+	c.JSON(http.StatusOK, s.runtimeSettings())
+}
+
+func (s *Server) updateRuntimeSettings(c *gin.Context) {
+	var req RuntimeSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	before := s.runtimeSettings()
+	rt := before
+	req.applyTo(&rt)
+	if err := rt.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := settings.SaveRuntime(s.DB, rt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "save settings failed"})
+		return
+	}
+	s.ApplyRuntime(rt)
+	s.recordAdminAudit(c, AuditSettings, "runtime", before, rt)
+	c.JSON(http.StatusOK, rt)
+}
+
+// applyTo sets the fields present in the request on rt.
+func (req RuntimeSettingsRequest) applyTo(rt *settings.RuntimeSettings) {
+	if req.HTTPTimeoutSeconds != nil {
+		rt.HTTPTimeoutSeconds = *req.HTTPTimeoutSeconds
+	}
+	if req.MaxAssetBytes != nil {
+		rt.MaxAssetBytes = *req.MaxAssetBytes
+	}
+	if req.AutoTagOnCapture != nil {
+		rt.AutoTagOnCapture = *req.AutoTagOnCapture
+	}
+	if req.AutoTagWorkers != nil {
+		rt.AutoTagWorkers = *req.AutoTagWorkers
+	}
+	if req.LLMTimeoutSeconds != nil {
+		rt.LLMTimeoutSeconds = *req.LLMTimeoutSeconds
+	}
+	if req.LLMConcurrency != nil {
+		rt.LLMConcurrency = *req.LLMConcurrency
+	}
+	if req.UserAgent != nil {
+		rt.UserAgent = strings.TrimSpace(*req.UserAgent)
+	}
+	if req.HeaderRules != nil {
+		rt.HeaderRules = *req.HeaderRules
+	}
+	if req.RemovalRules != nil {
+		rt.RemovalRules = *req.RemovalRules
+	}
+	if req.TaxonomyRouter != nil {
+		rt.TaxonomyRouter = strings.TrimSpace(*req.TaxonomyRouter)
+	}
+	if req.TaxonomyMaxDepth != nil {
+		rt.TaxonomyMaxDepth = *req.TaxonomyMaxDepth
+	}
+	if req.TaxonomyMaxOptions != nil {
+		rt.TaxonomyMaxOptions = *req.TaxonomyMaxOptions
+	}
+	if req.TaxonomyMaxPathLength != nil {
+		rt.TaxonomyMaxPathLength = *req.TaxonomyMaxPathLength
+	}
+	if req.TaxonomyMaxLabelLength != nil {
+		rt.TaxonomyMaxLabelLength = *req.TaxonomyMaxLabelLength
+	}
+	if req.TaxonomyMaxLabelWords != nil {
+		rt.TaxonomyMaxLabelWords = *req.TaxonomyMaxLabelWords
+	}
+	if req.TaxonomyBannedChars != nil {
+		rt.TaxonomyBannedChars = *req.TaxonomyBannedChars
+	}
+	if req.TaxonomyLabelCase != nil {
+		rt.TaxonomyLabelCase = strings.TrimSpace(*req.TaxonomyLabelCase)
+	}
+	if req.TaxonomyMergeSimilar != nil {
+		rt.TaxonomyMergeSimilar = *req.TaxonomyMergeSimilar
+	}
+	if req.CaptureHosts != nil {
+		rt.CaptureHosts = *req.CaptureHosts
+	}
+	if req.UserCaptureHosts != nil {
+		users := map[string]processor.HostRules{}
+		for user, rules := range *req.UserCaptureHosts {
+			if user = strings.TrimSpace(user); user != "" {
+				users[user] = rules
+			}
+		}
+		rt.UserCaptureHosts = users
+	}
+	if req.LLMTrace != nil {
+		rt.LLMTrace = *req.LLMTrace
+	}
+	if req.LLMTraceRetentionDays != nil {
+		rt.LLMTraceRetentionDays = *req.LLMTraceRetentionDays
+	}
+}
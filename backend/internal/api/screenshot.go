@@ -0,0 +1,95 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/storage"
+)
+
+const (
+	CaptureModeFull     = "full"
+	CaptureModeMetadata = "metadata"
+	// CaptureModeTextOnly is set by the server, never requested: the page's
+	// assets were refused and only the reader text and screenshot were kept.
+	CaptureModeTextOnly = "text-only"
+
+	maxScreenshotBytes = 8 << 20
+)
+
+func validCaptureMode(mode string) bool {
+	return mode == "" || mode == CaptureModeFull || mode == CaptureModeMetadata
+}
+
+// captureModeOf treats archives from before capture modes existed as full.
+func captureModeOf(item models.Archive) string {
+	if item.CaptureMode == "" {
+		return CaptureModeFull
+	}
+	return item.CaptureMode
+}
+
+// decodeScreenshot accepts the data URL produced by
+// chrome.tabs.captureVisibleTab and returns the image bytes, content type
+// and object name to store it under.
+func decodeScreenshot(dataURL string) ([]byte, string, string, error) {
+	header, payload, ok := strings.Cut(dataURL, ",")
+	if !ok || !strings.HasPrefix(header, "data:") || !strings.HasSuffix(header, ";base64") {
+		return nil, "", "", errors.New("screenshot must be a base64 data URL")
+	}
+	contentType := strings.TrimSuffix(strings.TrimPrefix(header, "data:"), ";base64")
+	name := ""
+	switch contentType {
+	case "image/jpeg":
+		name = "screenshot.jpg"
+	case "image/png":
+		name = "screenshot.png"
+	case "image/webp":
+		name = "screenshot.webp"
+	default:
+		return nil, "", "", errors.New("screenshot must be jpeg, png or webp")
+	}
+	if base64.StdEncoding.DecodedLen(len(payload)) > maxScreenshotBytes {
+		return nil, "", "", errors.New("screenshot too large")
+	}
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, "", "", errors.New("screenshot is not valid base64")
+	}
+	return data, contentType, name, nil
+}
+
+func (s *Server) getArchiveScreenshot(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.Select("id", "screenshot_path").First(&item, "id = ?", c.Param("id")).Error; err != nil || item.ScreenshotPath == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	obj, err := s.Store.Get(c.Request.Context(), storage.ArchivePrefix(item.ID)+"/"+item.ScreenshotPath)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	defer obj.Close()
+	stat, err := obj.Stat()
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	etag := `"` + strings.Trim(stat.ETag, `"`) + `"`
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Header("Content-Type", stat.ContentType)
+	c.Status(http.StatusOK)
+	_, _ = io.Copy(c.Writer, obj)
+}
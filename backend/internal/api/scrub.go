@@ -0,0 +1,13 @@
+package api
+
+// scrubText removes personal data from text stored alongside a page, with
+// the rules the processor applies to the page itself.
+func (s *Server) scrubText(fields ...*string) {
+	if s.Processor == nil {
+		return
+	}
+	scrubber := s.Processor.Scrubber()
+	for _, f := range fields {
+		*f, _ = scrubber.Text(*f)
+	}
+}
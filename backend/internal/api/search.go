@@ -0,0 +1,122 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+const (
+	searchDefaultLimit = 20
+	searchMaxLimit     = 100
+	facetDefaultLimit  = 20
+)
+
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+type SearchFacets struct {
+	Tags    []FacetCount `json:"tags"`
+	Domains []FacetCount `json:"domains"`
+	Paths   []FacetCount `json:"paths"`
+	Years   []FacetCount `json:"years"`
+}
+
+type SearchResponse struct {
+	Total   int64             `json:"total"`
+	Limit   int               `json:"limit"`
+	Offset  int               `json:"offset"`
+	Results []ArchiveResponse `json:"results"`
+	Facets  SearchFacets      `json:"facets"`
+}
+
+// searchArchives takes the list filters plus limit/offset and returns one
+// page of results together with facet counts over all matches, so a
+// sidebar can be drawn from a single request. Results leave out the
+// content text.
+func (s *Server) searchArchives(c *gin.Context) {
+	limit := parseLimit(c.Query("limit"), searchDefaultLimit)
+	if limit == 0 || limit > searchMaxLimit {
+		limit = searchMaxLimit
+	}
+	offset := parseLimit(c.Query("offset"), 0)
+	facetLimit := parseLimit(c.Query("facetLimit"), facetDefaultLimit)
+	if facetLimit == 0 {
+		facetLimit = facetDefaultLimit
+	}
+	matches := func() *gorm.DB { return s.filterArchives(s.reader().Model(&models.Archive{}), c) }
+
+	resp := SearchResponse{Limit: limit, Offset: offset, Results: []ArchiveResponse{}}
+	if err := matches().Count(&resp.Total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	var items []models.Archive
+	if err := matches().Omit("content_text").Order("created_at desc").Limit(limit).Offset(offset).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	for _, item := range items {
+		resp.Results = append(resp.Results, toArchiveResponse(item, nil))
+	}
+
+	var err error
+	if resp.Facets.Tags, err = s.tagFacet(matches(), facetLimit); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	facets := []struct {
+		out   *[]FacetCount
+		query *gorm.DB
+	}{
+		{&resp.Facets.Domains, matches().Select("domain AS value, COUNT(*) AS count").Where("domain <> ''").
+			Group("domain").Order("count desc").Limit(facetLimit)},
+		{&resp.Facets.Paths, s.reader().Model(&models.ArchivePath{}).Select("path AS value, COUNT(*) AS count").
+			Where("archive_id IN (?)", matches().Select("id")).Group("path").Order("count desc").Limit(facetLimit)},
+		{&resp.Facets.Years, matches().Select("CAST(YEAR(COALESCE(captured_at, created_at)) AS CHAR) AS value, COUNT(*) AS count").
+			Group("value").Order("value desc")},
+	}
+	for _, f := range facets {
+		*f.out = []FacetCount{}
+		if err := f.query.Scan(f.out).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// tagFacet counts tags in Go: they live in a JSON column, and only the
+// column itself is loaded.
+func (s *Server) tagFacet(db *gorm.DB, limit int) ([]FacetCount, error) {
+	var raws [][]byte
+	if err := db.Where("tags_json IS NOT NULL").Pluck("tags_json", &raws).Error; err != nil {
+		return nil, err
+	}
+	counts := map[string]int64{}
+	for _, raw := range raws {
+		for _, tag := range jsonStrings(raw) {
+			counts[tag]++
+		}
+	}
+	out := make([]FacetCount, 0, len(counts))
+	for tag, n := range counts {
+		out = append(out, FacetCount{Value: tag, Count: n})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Count != out[j].Count {
+			return out[i].Count > out[j].Count
+		}
+		return out[i].Value < out[j].Value
+	})
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
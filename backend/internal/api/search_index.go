@@ -0,0 +1,152 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/searchindex"
+)
+
+const (
+	// searchIndexMaxHits caps the IDs taken from an external index for one
+	// query; the remaining filters, sorting and paging run in SQL on them.
+	searchIndexMaxHits = 1000
+	searchIndexBatch   = 200
+	// searchIndexMaxText keeps documents well below backend payload limits.
+	searchIndexMaxText = 100000
+	searchMatchesKey   = "searchIndexMatches"
+)
+
+type SearchReindexResponse struct {
+	Index   string `json:"index"`
+	Indexed int    `json:"indexed"`
+}
+
+func (s *Server) searchIndex() searchindex.Index {
+	if s.SearchIndex != nil {
+		return s.SearchIndex
+	}
+	return searchindex.NewDBIndex(s.DB, false)
+}
+
+// textCondition is the WHERE clause for a q filter evaluated in SQL.
+func (s *Server) textCondition(query string) (string, []any) {
+	if index, ok := s.searchIndex().(*searchindex.DBIndex); ok {
+		return index.Condition(query)
+	}
+	return searchindex.LikeCondition(query)
+}
+
+// indexMatches returns the archive IDs an external index finds for query,
+// memoized on the request since search asks once per facet. ok is false when
+// the database index is in use or the external one failed, and the caller
+// falls back to LIKE.
+func (s *Server) indexMatches(c *gin.Context, query string) ([]string, bool) {
+	index := s.searchIndex()
+	if index.Name() == "db" {
+		return nil, false
+	}
+	if cached, ok := c.Get(searchMatchesKey); ok {
+		ids, ok := cached.([]string)
+		return ids, ok
+	}
+	ids, err := index.Search(c.Request.Context(), query, searchIndexMaxHits)
+	if err != nil {
+		log.Printf("search index %s: %v", index.Name(), err)
+		c.Set(searchMatchesKey, false)
+		return nil, false
+	}
+	c.Set(searchMatchesKey, ids)
+	return ids, true
+}
+
+func searchDocument(item models.Archive) searchindex.Document {
+	return searchindex.Document{
+		ID:         item.ID,
+		Title:      item.Title,
+		URL:        item.URL,
+		Domain:     item.Domain,
+		Tags:       jsonStrings(item.TagsJSON),
+		Summary:    item.Summary,
+		Content:    truncate(item.ContentText, searchIndexMaxText),
+		CapturedAt: item.CapturedAt,
+	}
+}
+
+// syncSearchIndex mirrors archive events into an external index. It runs off
+// the request path; a missed update is repaired by the next write or a
+// reindex.
+func (s *Server) syncSearchIndex(typ string, data any) {
+	index := s.searchIndex()
+	if index.Name() == "db" {
+		return
+	}
+	payload, ok := data.(gin.H)
+	if !ok {
+		return
+	}
+	id, _ := payload["id"].(string)
+	if id == "" {
+		return
+	}
+	switch typ {
+	case LiveArchiveCreated, LiveArchiveUpdated, LiveArchiveDeleted:
+	default:
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		var err error
+		if typ == LiveArchiveDeleted {
+			err = index.Delete(ctx, []string{id})
+		} else {
+			var item models.Archive
+			if err = s.DB.First(&item, "id = ?", id).Error; err == nil {
+				err = index.Upsert(ctx, []searchindex.Document{searchDocument(item)})
+			}
+		}
+		if err != nil {
+			log.Printf("search index %s: sync %s failed: %v", index.Name(), id, err)
+		}
+	}()
+}
+
+// reindexSearch feeds every archive to the search index, for a new backend
+// or after it lost data.
+func (s *Server) reindexSearch(c *gin.Context) {
+	index := s.searchIndex()
+	resp := SearchReindexResponse{Index: index.Name()}
+	if index.Name() == "db" {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	ctx := c.Request.Context()
+	lastID := ""
+	for {
+		var items []models.Archive
+		if err := s.DB.Where("id > ?", lastID).Order("id asc").Limit(searchIndexBatch).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		if len(items) == 0 {
+			break
+		}
+		docs := make([]searchindex.Document, 0, len(items))
+		for _, item := range items {
+			docs = append(docs, searchDocument(item))
+		}
+		if err := index.Upsert(ctx, docs); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error(), "indexed": resp.Indexed})
+			return
+		}
+		resp.Indexed += len(docs)
+		lastID = items[len(items)-1].ID
+	}
+	c.JSON(http.StatusOK, resp)
+}
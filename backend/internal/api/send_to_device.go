@@ -0,0 +1,103 @@
+package api
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/notify"
+	"webarchive/internal/processor"
+)
+
+// deviceMaxBytes is the attachment limit of Send to Kindle, the strictest
+// of the common e-reader mail services.
+const deviceMaxBytes = 50 << 20
+
+type SendToDeviceRequest struct {
+	// To picks one of the configured device addresses; empty sends to all
+	// of them.
+	To string `json:"to"`
+}
+
+// sendArchiveToDevice mails an archive's reader text as an EPUB to the
+// configured e-reader addresses.
+func (s *Server) sendArchiveToDevice(c *gin.Context) {
+	var item models.Archive
+	if err := s.reader().Select(epubArchiveColumns).First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	title := strings.TrimSpace(item.Title)
+	if title == "" {
+		title = item.URL
+	}
+	s.sendToDevice(c, processor.EPUBBook{
+		ID:       item.ID,
+		Title:    title,
+		Modified: item.CreatedAt,
+		Chapters: []processor.EPUBChapter{epubChapterOf(item)},
+	})
+}
+
+func (s *Server) sendCollectionToDevice(c *gin.Context) {
+	book, ok := s.collectionBook(c)
+	if !ok {
+		return
+	}
+	s.sendToDevice(c, book)
+}
+
+// sendToDevice only mails configured addresses, so the endpoint can't be
+// used to send mail anywhere else.
+func (s *Server) sendToDevice(c *gin.Context, book processor.EPUBBook) {
+	var req SendToDeviceRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+			return
+		}
+	}
+	if len(s.DeviceEmails) == 0 || !s.SMTP.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "send-to-device not configured"})
+		return
+	}
+	to := s.DeviceEmails
+	if want := strings.TrimSpace(req.To); want != "" {
+		to = nil
+		for _, addr := range s.DeviceEmails {
+			if strings.EqualFold(addr, want) {
+				to = []string{addr}
+			}
+		}
+		if to == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a configured device address"})
+			return
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := processor.WriteEPUB(&buf, book); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "epub export failed"})
+		return
+	}
+	if buf.Len() > deviceMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "epub exceeds the 50 MiB device mail limit"})
+		return
+	}
+	filename := epubFilename(book)
+	err := notify.Email(s.SMTP, to, book.Title, "Sent from WebArchive: "+book.Title+"\n", notify.Attachment{
+		Filename:    filename,
+		ContentType: "application/epub+zip",
+		Data:        buf.Bytes(),
+	})
+	if err != nil {
+		log.Printf("send to device %s: %v", book.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "send mail failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"ok": true, "to": to, "filename": filename, "bytes": buf.Len()})
+}
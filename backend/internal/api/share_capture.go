@@ -0,0 +1,197 @@
+package api
+
+import (
+	"context"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"webarchive/internal/models"
+)
+
+const (
+	ShareQueued  = "queued"
+	ShareRunning = "running"
+	ShareDone    = "done"
+	ShareFailed  = "failed"
+
+	// shareWorkers bounds concurrent share-sheet captures; shareJobTTL is how
+	// long finished jobs stay visible on the status page.
+	shareWorkers = 4
+	shareJobTTL  = time.Hour
+)
+
+type shareJob struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Status    string    `json:"status"`
+	ArchiveID string    `json:"archiveId,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// shareCapture accepts a bare URL from a mobile share sheet (GET or POST,
+// ?url=... or form field url; text= is searched for a URL since some share
+// sheets only send text), queues a server-side fetch and answers at once
+// with a status page. Authenticate with ?access_token=... or a bearer token.
+func (s *Server) shareCapture(c *gin.Context) {
+	raw := c.Query("url")
+	if raw == "" {
+		raw = c.PostForm("url")
+	}
+	if raw == "" {
+		raw = firstURL(c.Query("text") + " " + c.PostForm("text"))
+	}
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		s.renderShareJob(c, http.StatusBadRequest, &shareJob{URL: raw, Status: ShareFailed, Error: "url must be an http(s) link"})
+		return
+	}
+
+	req := CreateArchiveRequest{URL: u.String(), Title: c.Query("title"), Source: "share-sheet"}
+	job := s.queueCapture(req, captureMetaFrom(c))
+	s.renderShareJob(c, http.StatusAccepted, &job)
+}
+
+// queueCapture runs a capture on the share-sheet workers and returns the
+// job as queued; /capture/status/:id reports on it from then on.
+func (s *Server) queueCapture(req CreateArchiveRequest, meta captureMeta) shareJob {
+	job := &shareJob{ID: uuid.New().String(), URL: req.URL, Status: ShareQueued, CreatedAt: time.Now()}
+	s.shareMu.Lock()
+	if s.shareJobs == nil {
+		s.shareJobs = map[string]*shareJob{}
+		s.shareSlots = make(chan struct{}, shareWorkers)
+	}
+	for id, old := range s.shareJobs {
+		if time.Since(old.CreatedAt) > shareJobTTL {
+			delete(s.shareJobs, id)
+		}
+	}
+	s.shareJobs[job.ID] = job
+	queued := *job
+	slots := s.shareSlots
+	s.shareMu.Unlock()
+
+	go func() {
+		slots <- struct{}{}
+		defer func() { <-slots }()
+		s.setShareJob(job.ID, func(j *shareJob) { j.Status = ShareRunning })
+		archive, err := s.captureArchive(context.Background(), req, meta)
+		s.setShareJob(job.ID, func(j *shareJob) {
+			if err != nil {
+				j.Status, j.Error = ShareFailed, err.Error()
+				return
+			}
+			j.Status, j.ArchiveID = ShareDone, archive.ID
+		})
+		if err != nil {
+			s.notifyFailure(models.Notification{
+				Username: meta.Actor,
+				Kind:     NotifyCaptureFailed,
+				URL:      req.URL,
+				Title:    "Capture failed: " + req.URL,
+				Message:  err.Error(),
+			})
+		}
+	}()
+	return queued
+}
+
+func (s *Server) shareCaptureStatus(c *gin.Context) {
+	s.shareMu.Lock()
+	job, ok := s.shareJobs[c.Param("id")]
+	var snapshot shareJob
+	if ok {
+		snapshot = *job
+	}
+	s.shareMu.Unlock()
+	if !ok {
+		s.renderShareJob(c, http.StatusNotFound, &shareJob{Status: ShareFailed, Error: "job not found or expired"})
+		return
+	}
+	s.renderShareJob(c, http.StatusOK, &snapshot)
+}
+
+func (s *Server) setShareJob(id string, update func(*shareJob)) {
+	s.shareMu.Lock()
+	defer s.shareMu.Unlock()
+	if job, ok := s.shareJobs[id]; ok {
+		update(job)
+	}
+}
+
+var shareTemplate = template.Must(template.New("share").Parse(`<!doctype html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+{{if .Pending}}<meta http-equiv="refresh" content="2;url={{.StatusURL}}">{{end}}
+<title>WebArchive</title>
+<style>
+body { font-family: -apple-system, system-ui, sans-serif; margin: 0; padding: 32px 20px; background: #fafbfc; color: #1f2a44; }
+.card { max-width: 420px; margin: 0 auto; background: #fff; border-radius: 12px; padding: 20px; box-shadow: 0 2px 12px rgba(0,0,0,.06); }
+.url { word-break: break-all; color: #4b5563; font-size: 14px; }
+.status { font-size: 20px; font-weight: 600; margin: 12px 0; }
+</style>
+</head>
+<body>
+<div class="card">
+<div class="status">{{.Label}}</div>
+{{if .Job.URL}}<div class="url">{{.Job.URL}}</div>{{end}}
+{{if .Job.Error}}<p>{{.Job.Error}}</p>{{end}}
+</div>
+</body>
+</html>
+`))
+
+// renderShareJob answers with JSON for API clients (e.g. iOS Shortcuts
+// sending Accept: application/json) and a small HTML page otherwise.
+func (s *Server) renderShareJob(c *gin.Context, status int, job *shareJob) {
+	if strings.Contains(c.GetHeader("Accept"), "application/json") {
+		if job.Status == ShareFailed && job.ID == "" {
+			c.JSON(status, gin.H{"error": job.Error})
+			return
+		}
+		c.JSON(status, job)
+		return
+	}
+	labels := map[string]string{
+		ShareQueued:  "已加入抓取队列",
+		ShareRunning: "正在抓取…",
+		ShareDone:    "已保存",
+		ShareFailed:  "保存失败",
+	}
+	statusURL := ""
+	if job.ID != "" {
+		statusURL = "/capture/status/" + url.PathEscape(job.ID)
+		if token := c.Query("access_token"); token != "" {
+			statusURL += "?access_token=" + url.QueryEscape(token)
+		}
+	}
+	data := struct {
+		Job       *shareJob
+		Label     string
+		Pending   bool
+		StatusURL string
+	}{job, labels[job.Status], job.Status == ShareQueued || job.Status == ShareRunning, statusURL}
+	c.Status(status)
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := shareTemplate.Execute(c.Writer, data); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// firstURL picks the first http(s) link out of free text.
+func firstURL(text string) string {
+	for _, field := range strings.Fields(text) {
+		if strings.HasPrefix(field, "http://") || strings.HasPrefix(field, "https://") {
+			return field
+		}
+	}
+	return ""
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"errors"
+	"math/bits"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+type SimilarContentHit struct {
+	Archive  ArchiveResponse `json:"archive"`
+	Distance int             `json:"distance"`
+}
+
+// similarContent finds re-posts and mirrors of an archive by simhash
+// Hamming distance. Candidates come from the indexed 16-bit bands, which
+// is exhaustive for distances up to 3.
+func (s *Server) similarContent(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.Select("id", "sim_hash", "sim_band0", "sim_band1", "sim_band2", "sim_band3").First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if item.SimHash == 0 {
+		c.JSON(http.StatusOK, []SimilarContentHit{})
+		return
+	}
+	maxDistance := 3
+	if n, err := strconv.Atoi(c.Query("maxDistance")); err == nil && n >= 0 && n <= 3 {
+		maxDistance = n
+	}
+
+	var candidates []models.Archive
+	if err := s.DB.Omit("content_text").
+		Where("id <> ? AND sim_hash <> 0", item.ID).
+		Where("sim_band0 = ? OR sim_band1 = ? OR sim_band2 = ? OR sim_band3 = ?", item.SimBand0, item.SimBand1, item.SimBand2, item.SimBand3).
+		Find(&candidates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	hits := []SimilarContentHit{}
+	for _, cand := range candidates {
+		d := bits.OnesCount64(item.SimHash ^ cand.SimHash)
+		if d <= maxDistance {
+			hits = append(hits, SimilarContentHit{Archive: toArchiveResponse(cand, nil), Distance: d})
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Distance < hits[j].Distance })
+	c.JSON(http.StatusOK, hits)
+}
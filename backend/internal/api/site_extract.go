@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+)
+
+// applySiteExtractor runs the extractor registered for pageURL, if any, over
+// the raw page. Its fields replace what the client sent or the generic
+// extraction found, since a site-specific extractor knows better than a
+// document title with the site name appended; its tags are added to the
+// request's. It returns the extractor's name, or "" when none ran. A failing
+// extractor is logged and the capture goes on with the generic fields.
+func (s *Server) applySiteExtractor(ctx context.Context, req *CreateArchiveRequest, pageURL string, page []byte) string {
+	ex := s.Extractors.Lookup(pageURL)
+	if ex == nil {
+		return ""
+	}
+	found, err := ex.Extract(ctx, pageURL, page)
+	if err != nil {
+		log.Printf("extractor %s for %s: %v", ex.Name(), pageURL, err)
+		return ""
+	}
+	for _, f := range []struct {
+		dst *string
+		val string
+	}{
+		{&req.Title, found.Title},
+		{&req.Byline, found.Byline},
+		{&req.SiteName, found.SiteName},
+		{&req.Excerpt, found.Excerpt},
+		{&req.Content, found.Content},
+	} {
+		if v := strings.TrimSpace(f.val); v != "" {
+			*f.dst = v
+		}
+	}
+	for _, tag := range found.Tags {
+		if tag = strings.TrimSpace(tag); tag != "" && !slices.Contains(req.Tags, tag) {
+			req.Tags = append(req.Tags, tag)
+		}
+	}
+	return ex.Name()
+}
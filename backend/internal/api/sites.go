@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+// siteCapturedAt is when an archive's page was captured, falling back to
+// when the archive was created for imports without a capture time.
+const siteCapturedAt = "COALESCE(captured_at, created_at)"
+
+type SiteSummary struct {
+	Domain          string    `json:"domain"`
+	SiteName        string    `json:"siteName"`
+	Count           int64     `json:"count"`
+	FirstCapturedAt time.Time `json:"firstCapturedAt"`
+	LastCapturedAt  time.Time `json:"lastCapturedAt"`
+}
+
+type SiteResponse struct {
+	SiteSummary
+	TopTags  []map[string]any  `json:"topTags"`
+	Archives []ArchiveResponse `json:"archives"`
+}
+
+func siteSummaries(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Archive{}).
+		Select("domain, MAX(site_name) AS site_name, COUNT(*) AS count, " +
+			"MIN(" + siteCapturedAt + ") AS first_captured_at, MAX(" + siteCapturedAt + ") AS last_captured_at").
+		Where("domain <> ''").
+		Group("domain")
+}
+
+// listSites groups the collection by publication. sort is count (default),
+// recent (latest capture first) or domain.
+func (s *Server) listSites(c *gin.Context) {
+	query := siteSummaries(s.reader())
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + strings.ToLower(q) + "%"
+		query = query.Where("domain LIKE ? OR site_name LIKE ?", like, like)
+	}
+	switch c.Query("sort") {
+	case "recent":
+		query = query.Order("last_captured_at desc")
+	case "domain":
+		query = query.Order("domain asc")
+	default:
+		query = query.Order("count desc").Order("domain asc")
+	}
+	var out []SiteSummary
+	if err := query.Limit(parseLimit(c.Query("limit"), 100)).Offset(parseLimit(c.Query("offset"), 0)).
+		Scan(&out).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if out == nil {
+		out = []SiteSummary{}
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// getSite returns one site's summary, its most used tags and its archives,
+// newest first.
+func (s *Server) getSite(c *gin.Context) {
+	domain := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(c.Param("domain"))), "www.")
+	db := s.reader()
+	var summaries []SiteSummary
+	if err := siteSummaries(db).Where("domain = ?", domain).Scan(&summaries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if len(summaries) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+
+	var tagRows []models.Archive
+	if err := db.Select("id, tags_json").Where("domain = ?", domain).Find(&tagRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	counts := map[string]int{}
+	for _, item := range tagRows {
+		for _, t := range jsonStrings(item.TagsJSON) {
+			if t = strings.TrimSpace(t); t != "" {
+				counts[t]++
+			}
+		}
+	}
+
+	var items []models.Archive
+	if err := db.Omit("content_text").Where("domain = ?", domain).Order("created_at desc").
+		Limit(parseLimit(c.Query("limit"), 50)).Offset(parseLimit(c.Query("offset"), 0)).
+		Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	archives := make([]ArchiveResponse, 0, len(items))
+	for _, item := range items {
+		archives = append(archives, toArchiveResponse(item, nil))
+	}
+	c.JSON(http.StatusOK, SiteResponse{
+		SiteSummary: summaries[0],
+		TopTags:     rankCounts(counts, nil, parseLimit(c.Query("tags"), 10)),
+		Archives:    archives,
+	})
+}
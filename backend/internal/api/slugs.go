@@ -0,0 +1,199 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+)
+
+// maxSlugLength bounds slugs in runes.
+const maxSlugLength = 60
+
+// uuidLike matches archive IDs, which slugs may never look like so a
+// route's :id is never ambiguous.
+var uuidLike = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// slugify turns a title into a slug: lower-cased letters and digits in any
+// script, with every run of anything else made a single hyphen, e.g.
+// "Go 1.22 Release Notes" → "go-1-22-release-notes".
+func slugify(title string) string {
+	var b strings.Builder
+	n := 0
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if n == maxSlugLength {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+				n++
+			}
+			dash = false
+			b.WriteRune(r)
+			n++
+			continue
+		}
+		dash = true
+	}
+	return strings.TrimRight(b.String(), "-")
+}
+
+// normalizeSlug checks a slug set by hand, slugifying it the way generated
+// ones are.
+func normalizeSlug(raw string) (string, error) {
+	slug := slugify(raw)
+	if slug == "" {
+		return "", errors.New("slug must contain letters or digits")
+	}
+	if uuidLike.MatchString(slug) {
+		return "", errors.New("slug must not look like an archive ID")
+	}
+	return slug, nil
+}
+
+// archiveRef is how links name an archive: its slug when it has one, its ID
+// otherwise.
+func archiveRef(item models.Archive) string {
+	if item.Slug != nil && *item.Slug != "" {
+		return *item.Slug
+	}
+	return item.ID
+}
+
+// resolveSlugs swaps a slug in the :id of archive and asset routes for the
+// archive's ID, so every such route takes either. Runs before followAliases.
+func (s *Server) resolveSlugs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		if id == "" || uuidLike.MatchString(id) {
+			return
+		}
+		route := c.FullPath()
+		if !strings.Contains(route, "/archives/:id") && !strings.Contains(route, "/assets/:id/") {
+			return
+		}
+		var item models.Archive
+		if err := s.DB.Select("id").First(&item, "slug = ?", id).Error; err != nil {
+			return
+		}
+		for i := range c.Params {
+			if c.Params[i].Key == "id" {
+				c.Params[i].Value = item.ID
+			}
+		}
+	}
+}
+
+// slugTaken reports whether another archive than id already uses slug.
+func (s *Server) slugTaken(slug, id string) (bool, error) {
+	var n int64
+	err := s.DB.Model(&models.Archive{}).Where("slug = ? AND id <> ?", slug, id).Count(&n).Error
+	return n > 0, err
+}
+
+// assignSlug gives an archive without a slug one made from its title,
+// numbering it ("title-2") when the plain one is taken and falling back to
+// the start of the ID after that.
+func (s *Server) assignSlug(item *models.Archive) error {
+	base := slugify(item.Title)
+	if base == "" || uuidLike.MatchString(base) {
+		base = "archive"
+	}
+	candidates := []string{base}
+	for i := 2; i <= 10; i++ {
+		candidates = append(candidates, base+"-"+strconv.Itoa(i))
+	}
+	candidates = append(candidates, base+"-"+item.ID[:8])
+	var lastErr error
+	for _, slug := range candidates {
+		if taken, err := s.slugTaken(slug, item.ID); err != nil || taken {
+			lastErr = err
+			continue
+		}
+		// A concurrent capture may take the slug first; the unique index
+		// refuses the second and the next candidate is tried.
+		res := s.DB.Model(&models.Archive{}).Where("id = ? AND slug IS NULL", item.ID).UpdateColumn("slug", slug)
+		if res.Error != nil {
+			lastErr = res.Error
+			continue
+		}
+		if res.RowsAffected == 1 {
+			item.Slug = &slug
+		}
+		return nil
+	}
+	return lastErr
+}
+
+// slugAfterCapture gives a new archive its slug when slugs are generated.
+func (s *Server) slugAfterCapture(item *models.Archive) {
+	if !s.ArchiveSlugs {
+		return
+	}
+	if err := s.assignSlug(item); err != nil {
+		log.Printf("slug %s: %v", item.ID, err)
+	}
+}
+
+// BackfillSlugs gives archives saved before slugs were generated theirs.
+func (s *Server) BackfillSlugs() {
+	if !s.ArchiveSlugs {
+		return
+	}
+	lastID := ""
+	assigned := 0
+	for {
+		var items []models.Archive
+		if err := s.DB.Select("id", "title").Where("id > ? AND slug IS NULL", lastID).
+			Order("id asc").Limit(500).Find(&items).Error; err != nil {
+			log.Printf("slug backfill failed: %v", err)
+			return
+		}
+		if len(items) == 0 {
+			break
+		}
+		for i := range items {
+			lastID = items[i].ID
+			if err := s.assignSlug(&items[i]); err != nil {
+				log.Printf("slug backfill failed: %v", err)
+				return
+			}
+			assigned++
+		}
+	}
+	if assigned > 0 {
+		log.Printf("slugs assigned to %d archives", assigned)
+	}
+}
+
+// slugUpdate validates the slug a PATCH sets: "" clears it, anything else
+// is slugified and must be free. It writes the response on failure.
+func (s *Server) slugUpdate(c *gin.Context, raw, id string) (any, bool) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, true
+	}
+	slug, err := normalizeSlug(raw)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	taken, err := s.slugTaken(slug, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return nil, false
+	}
+	if taken {
+		c.JSON(http.StatusConflict, gin.H{"error": "slug is already used by another archive"})
+		return nil, false
+	}
+	return slug, true
+}
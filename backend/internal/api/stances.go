@@ -0,0 +1,449 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm/clause"
+
+	"webarchive/internal/cache"
+	"webarchive/internal/models"
+	"webarchive/internal/textutil"
+)
+
+// Stance relation types, also the knowledge graph link types between the
+// two archives.
+const (
+	StanceSupports    = "supports"
+	StanceContradicts = "contradicts"
+)
+
+const (
+	stanceDefaultPairs = 50
+	stanceMaxPairs     = 1000
+	stanceMaxClaims    = 5
+	stanceTextRunes    = 2000
+)
+
+type StanceStatus struct {
+	Running     bool       `json:"running"`
+	Pairs       int        `json:"pairs"`
+	Compared    int        `json:"compared"`
+	Supports    int        `json:"supports"`
+	Contradicts int        `json:"contradicts"`
+	Failed      int        `json:"failed"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	FinishedAt  *time.Time `json:"finishedAt,omitempty"`
+	LastError   string     `json:"lastError,omitempty"`
+}
+
+// StanceRequest starts a comparison run. Pairs are archives sharing at
+// least MinShared entities (default 2), most shared first; IDs restricts
+// them to pairs involving those archives. Pairs compared before are
+// skipped unless Recheck is set.
+type StanceRequest struct {
+	Limit     int      `json:"limit"`
+	MinShared int      `json:"minShared"`
+	IDs       []string `json:"ids"`
+	Recheck   bool     `json:"recheck"`
+}
+
+// StanceClaim is a point on which two sources agree or disagree. ClaimA
+// and ClaimB are what each source says, in the order of the pair.
+type StanceClaim struct {
+	Type   string `json:"type"`
+	Entity string `json:"entity"`
+	ClaimA string `json:"claimA"`
+	ClaimB string `json:"claimB"`
+	Note   string `json:"note,omitempty"`
+}
+
+// ArchiveStance is a comparison as seen from one archive: Claim is its own
+// side of each claim and OtherClaim the other archive's.
+type ArchiveStance struct {
+	ArchiveID string              `json:"archiveId"`
+	Title     string              `json:"title"`
+	Shared    []string            `json:"shared"`
+	Claims    []ArchiveStanceSide `json:"claims"`
+	CheckedAt time.Time           `json:"checkedAt"`
+}
+
+type ArchiveStanceSide struct {
+	Type       string `json:"type"`
+	Entity     string `json:"entity"`
+	Claim      string `json:"claim"`
+	OtherClaim string `json:"otherClaim"`
+	Note       string `json:"note,omitempty"`
+}
+
+type stancePair struct {
+	a, b   string
+	shared []string
+}
+
+func (s *Server) stanceJobStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.getStanceStatus())
+}
+
+func (s *Server) getStanceStatus() StanceStatus {
+	s.stanceMu.Lock()
+	defer s.stanceMu.Unlock()
+	return s.stanceStatus
+}
+
+func (s *Server) startStances(c *gin.Context) {
+	if s.LLM == nil || !s.LLM.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "llm not configured"})
+		return
+	}
+	var req StanceRequest
+	_ = c.ShouldBindJSON(&req)
+	if req.Limit <= 0 {
+		req.Limit = stanceDefaultPairs
+	}
+	if req.Limit > stanceMaxPairs {
+		req.Limit = stanceMaxPairs
+	}
+	if req.MinShared <= 0 {
+		req.MinShared = 2
+	}
+
+	s.stanceMu.Lock()
+	if s.stanceStatus.Running {
+		status := s.stanceStatus
+		s.stanceMu.Unlock()
+		c.JSON(http.StatusOK, status)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	s.stanceCancel = cancel
+	s.stanceStatus = StanceStatus{Running: true, StartedAt: &now}
+	s.stanceMu.Unlock()
+
+	go s.runStances(ctx, req)
+	c.JSON(http.StatusOK, s.getStanceStatus())
+}
+
+func (s *Server) stopStances(c *gin.Context) {
+	s.stanceMu.Lock()
+	if s.stanceCancel != nil {
+		s.stanceCancel()
+		s.stanceCancel = nil
+	}
+	s.stanceMu.Unlock()
+	c.JSON(http.StatusOK, s.getStanceStatus())
+}
+
+func (s *Server) runStances(ctx context.Context, req StanceRequest) {
+	lastErr := ""
+	found := false
+	defer func() {
+		now := time.Now()
+		s.stanceMu.Lock()
+		s.stanceStatus.Running = false
+		s.stanceStatus.FinishedAt = &now
+		s.stanceStatus.LastError = lastErr
+		s.stanceCancel = nil
+		s.stanceMu.Unlock()
+		if found {
+			s.Cache.Invalidate(context.Background(), cache.Graph)
+		}
+		if lastErr != "" {
+			log.Printf("stance analysis: %s", lastErr)
+		}
+	}()
+
+	pairs, err := s.pendingStancePairs(req)
+	if err != nil {
+		lastErr = err.Error()
+		return
+	}
+	s.stanceMu.Lock()
+	s.stanceStatus.Pairs = len(pairs)
+	s.stanceMu.Unlock()
+
+	for _, pair := range pairs {
+		if ctx.Err() != nil {
+			lastErr = "canceled"
+			return
+		}
+		check, err := s.compareStances(ctx, pair)
+		if err != nil {
+			log.Printf("stance analysis failed for %s/%s: %v", pair.a, pair.b, err)
+		}
+		s.stanceMu.Lock()
+		if err != nil {
+			s.stanceStatus.Failed++
+		} else {
+			s.stanceStatus.Compared++
+			s.stanceStatus.Supports += check.Supports
+			s.stanceStatus.Contradicts += check.Contradicts
+		}
+		s.stanceMu.Unlock()
+		if err == nil && check.Supports+check.Contradicts > 0 {
+			found = true
+		}
+	}
+}
+
+// pendingStancePairs lists the pairs to compare in this run.
+func (s *Server) pendingStancePairs(req StanceRequest) ([]stancePair, error) {
+	var items []models.Archive
+	if err := s.DB.Select("id", "entities_json").Where("entities_json IS NOT NULL").Find(&items).Error; err != nil {
+		return nil, err
+	}
+	pairs := stancePairs(items, req.MinShared)
+	if len(req.IDs) > 0 {
+		want := map[string]bool{}
+		for _, id := range req.IDs {
+			want[id] = true
+		}
+		kept := pairs[:0]
+		for _, p := range pairs {
+			if want[p.a] || want[p.b] {
+				kept = append(kept, p)
+			}
+		}
+		pairs = kept
+	}
+	if !req.Recheck {
+		var checked []models.StanceCheck
+		if err := s.DB.Select("archive_a", "archive_b").Find(&checked).Error; err != nil {
+			return nil, err
+		}
+		done := map[[2]string]bool{}
+		for _, c := range checked {
+			done[[2]string{c.ArchiveA, c.ArchiveB}] = true
+		}
+		kept := pairs[:0]
+		for _, p := range pairs {
+			if !done[[2]string{p.a, p.b}] {
+				kept = append(kept, p)
+			}
+		}
+		pairs = kept
+	}
+	if len(pairs) > req.Limit {
+		pairs = pairs[:req.Limit]
+	}
+	return pairs, nil
+}
+
+// stancePairs pairs archives sharing at least min entities, most shared
+// first. Entities held by more than maxCoCiteFanout archives are left out,
+// as for co-citation links.
+func stancePairs(items []models.Archive, min int) []stancePair {
+	holders := map[string][]string{}
+	for _, item := range items {
+		seen := map[string]bool{}
+		for _, ent := range jsonStrings(item.EntitiesJSON) {
+			ent = strings.TrimSpace(ent)
+			if ent != "" && !seen[ent] {
+				seen[ent] = true
+				holders[ent] = append(holders[ent], item.ID)
+			}
+		}
+	}
+	shared := map[[2]string][]string{}
+	for ent, ids := range holders {
+		if len(ids) < 2 || len(ids) > maxCoCiteFanout {
+			continue
+		}
+		sort.Strings(ids)
+		for i := 0; i < len(ids); i++ {
+			for j := i + 1; j < len(ids); j++ {
+				key := [2]string{ids[i], ids[j]}
+				shared[key] = append(shared[key], ent)
+			}
+		}
+	}
+	pairs := make([]stancePair, 0)
+	for key, ents := range shared {
+		if len(ents) < min {
+			continue
+		}
+		sort.Strings(ents)
+		pairs = append(pairs, stancePair{a: key[0], b: key[1], shared: ents})
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if len(pairs[i].shared) != len(pairs[j].shared) {
+			return len(pairs[i].shared) > len(pairs[j].shared)
+		}
+		if pairs[i].a != pairs[j].a {
+			return pairs[i].a < pairs[j].a
+		}
+		return pairs[i].b < pairs[j].b
+	})
+	return pairs
+}
+
+// compareStances asks the LLM for the claims on which the pair agrees or
+// disagrees and stores the result.
+func (s *Server) compareStances(ctx context.Context, pair stancePair) (models.StanceCheck, error) {
+	var items []models.Archive
+	if err := s.DB.Select("id", "title", "url", "summary", "excerpt", "content_text").
+		Where("id IN ?", []string{pair.a, pair.b}).Find(&items).Error; err != nil {
+		return models.StanceCheck{}, err
+	}
+	byID := map[string]models.Archive{}
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	a, okA := byID[pair.a]
+	b, okB := byID[pair.b]
+	if !okA || !okB {
+		return models.StanceCheck{}, errors.New("archive not found")
+	}
+
+	system := "You compare the claims of two saved web pages. Return strict JSON only."
+	user := "Both sources discuss: " + strings.Join(pair.shared, ", ") + ".\n" +
+		"List specific claims or positions about these topics on which the two sources agree (supports) or contradict each other (contradicts). " +
+		"Ignore points only one source makes and differences in emphasis. Answer in the same language as the sources.\n" +
+		"Return JSON: {\"relations\": [{\"type\": \"supports\" or \"contradicts\", \"entity\": string, \"claimA\": string, \"claimB\": string, \"note\": string}]}, " +
+		fmt.Sprintf("at most %d relations; an empty list when there are none.\n\n", stanceMaxClaims) +
+		"Source A: " + stanceSource(a) + "\n\nSource B: " + stanceSource(b)
+	raw, err := s.LLM.ChatJSON(ctx, system, user, 0.2)
+	if err != nil {
+		return models.StanceCheck{}, err
+	}
+	raw = extractJSON(raw)
+	if raw == "" {
+		return models.StanceCheck{}, errors.New("invalid json")
+	}
+	var out struct {
+		Relations []StanceClaim `json:"relations"`
+	}
+	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+		return models.StanceCheck{}, err
+	}
+
+	check := models.StanceCheck{ID: uuid.New().String(), ArchiveA: pair.a, ArchiveB: pair.b, Model: s.LLM.Model, CheckedAt: time.Now()}
+	claims := []StanceClaim{}
+	for _, rel := range out.Relations {
+		rel.Type = strings.ToLower(strings.TrimSpace(rel.Type))
+		rel.ClaimA, rel.ClaimB = strings.TrimSpace(rel.ClaimA), strings.TrimSpace(rel.ClaimB)
+		if rel.ClaimA == "" || rel.ClaimB == "" || len(claims) == stanceMaxClaims {
+			continue
+		}
+		switch rel.Type {
+		case StanceSupports:
+			check.Supports++
+		case StanceContradicts:
+			check.Contradicts++
+		default:
+			continue
+		}
+		rel.Entity = strings.TrimSpace(rel.Entity)
+		claims = append(claims, rel)
+	}
+	check.SharedJSON, _ = json.Marshal(pair.shared)
+	check.ClaimsJSON, _ = json.Marshal(claims)
+	err = s.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "archive_a"}, {Name: "archive_b"}},
+		DoUpdates: clause.AssignmentColumns([]string{"shared_json", "claims_json", "supports", "contradicts", "model", "checked_at"}),
+	}).Create(&check).Error
+	return check, err
+}
+
+func stanceSource(item models.Archive) string {
+	text := item.Summary
+	if text == "" {
+		text = item.Excerpt
+	}
+	if body := strings.TrimSpace(item.ContentText); body != "" {
+		text += "\n" + body
+	}
+	return item.Title + " (" + item.URL + ")\n" + textutil.TruncateRunes(strings.TrimSpace(text), stanceTextRunes)
+}
+
+// getArchiveStances lists the archives compared with this one that agree or
+// disagree with it on something.
+func (s *Server) getArchiveStances(c *gin.Context) {
+	id := c.Param("id")
+	var checks []models.StanceCheck
+	if err := s.reader().Where("(archive_a = ? OR archive_b = ?) AND (supports > 0 OR contradicts > 0)", id, id).
+		Order("contradicts desc, checked_at desc").Find(&checks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	others := make([]string, 0, len(checks))
+	for _, check := range checks {
+		others = append(others, stanceOther(check, id))
+	}
+	titles := map[string]string{}
+	if len(others) > 0 {
+		var items []models.Archive
+		if err := s.reader().Select("id", "title").Where("id IN ?", others).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		for _, item := range items {
+			titles[item.ID] = item.Title
+		}
+	}
+	out := make([]ArchiveStance, 0, len(checks))
+	for _, check := range checks {
+		other := stanceOther(check, id)
+		title, ok := titles[other]
+		if !ok {
+			continue
+		}
+		var claims []StanceClaim
+		_ = json.Unmarshal(check.ClaimsJSON, &claims)
+		resp := ArchiveStance{ArchiveID: other, Title: title, Shared: jsonStrings(check.SharedJSON), Claims: []ArchiveStanceSide{}, CheckedAt: check.CheckedAt}
+		for _, cl := range claims {
+			side := ArchiveStanceSide{Type: cl.Type, Entity: cl.Entity, Claim: cl.ClaimA, OtherClaim: cl.ClaimB, Note: cl.Note}
+			if check.ArchiveB == id {
+				side.Claim, side.OtherClaim = cl.ClaimB, cl.ClaimA
+			}
+			resp.Claims = append(resp.Claims, side)
+		}
+		out = append(out, resp)
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+func stanceOther(check models.StanceCheck, id string) string {
+	if check.ArchiveA == id {
+		return check.ArchiveB
+	}
+	return check.ArchiveA
+}
+
+// stanceLinks returns supports/contradicts links between the given
+// archives, weighted by the number of claims.
+func (s *Server) stanceLinks(items []models.Archive) ([]GraphLink, error) {
+	links := make([]GraphLink, 0)
+	if len(items) < 2 {
+		return links, nil
+	}
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	var checks []models.StanceCheck
+	if err := s.reader().Select("archive_a", "archive_b", "supports", "contradicts").
+		Where("archive_a IN ? AND archive_b IN ? AND (supports > 0 OR contradicts > 0)", ids, ids).
+		Find(&checks).Error; err != nil {
+		return nil, err
+	}
+	for _, check := range checks {
+		if check.Supports > 0 {
+			links = append(links, GraphLink{Source: "arc:" + check.ArchiveA, Target: "arc:" + check.ArchiveB, Value: check.Supports, Type: StanceSupports})
+		}
+		if check.Contradicts > 0 {
+			links = append(links, GraphLink{Source: "arc:" + check.ArchiveA, Target: "arc:" + check.ArchiveB, Value: check.Contradicts, Type: StanceContradicts})
+		}
+	}
+	return links, nil
+}
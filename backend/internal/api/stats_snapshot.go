@@ -0,0 +1,177 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"webarchive/internal/models"
+)
+
+const statsSnapshotBatchSize = 1000
+
+// StatsTrend is the snapshots taken in a window, oldest first. Change is how
+// much each count moved from the first snapshot to the last; Rising names the
+// counts the latest snapshot found higher than the one before it.
+type StatsTrend struct {
+	Snapshots []models.StatsSnapshot `json:"snapshots"`
+	Change    map[string]int64       `json:"change"`
+	Rising    []string               `json:"rising"`
+}
+
+// listStatsSnapshots returns the snapshots of the last ?days= days (default
+// 30).
+func (s *Server) listStatsSnapshots(c *gin.Context) {
+	days := parseLimit(c.Query("days"), 30)
+	if days <= 0 || days > 3650 {
+		days = 30
+	}
+	var snaps []models.StatsSnapshot
+	if err := s.reader().Where("taken_at >= ?", time.Now().AddDate(0, 0, -days)).
+		Order("taken_at asc").Find(&snaps).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	trend := StatsTrend{Snapshots: snaps, Change: map[string]int64{}, Rising: []string{}}
+	if len(snaps) > 0 {
+		first, last := statsCounts(snaps[0]), statsCounts(snaps[len(snaps)-1])
+		for _, name := range statsCountNames {
+			trend.Change[name] = last[name] - first[name]
+		}
+	}
+	if len(snaps) > 1 {
+		trend.Rising = risingStats(snaps[len(snaps)-2], snaps[len(snaps)-1])
+	}
+	c.JSON(http.StatusOK, trend)
+}
+
+// takeStatsSnapshotNow records a snapshot outside the schedule.
+func (s *Server) takeStatsSnapshotNow(c *gin.Context) {
+	snap, err := s.takeStatsSnapshot(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "snapshot failed: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, snap)
+}
+
+// StartStatsSnapshotScheduler records a snapshot every interval and drops
+// the ones older than keep; keep of zero keeps them all. With several
+// instances sharing the database, an instance skips its turn when another
+// took a snapshot within the last half interval.
+func (s *Server) StartStatsSnapshotScheduler(ctx context.Context, interval, keep time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			var recent int64
+			if err := s.DB.Model(&models.StatsSnapshot{}).
+				Where("taken_at > ?", time.Now().Add(-interval/2)).Count(&recent).Error; err != nil {
+				log.Printf("stats snapshot: %v", err)
+				continue
+			}
+			if recent == 0 {
+				if _, err := s.takeStatsSnapshot(ctx); err != nil {
+					log.Printf("stats snapshot: %v", err)
+				}
+			}
+			if keep > 0 {
+				if err := s.DB.Where("taken_at < ?", time.Now().Add(-keep)).Delete(&models.StatsSnapshot{}).Error; err != nil {
+					log.Printf("stats snapshot: prune failed: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// takeStatsSnapshot counts the drift between the archive rows, their stored
+// objects and the taxonomy, saves the counts and logs the ones that rose
+// since the previous snapshot. Captures in flight at the time may show up
+// for one snapshot as archives without objects or objects without archives.
+func (s *Server) takeStatsSnapshot(ctx context.Context) (models.StatsSnapshot, error) {
+	db := s.DB.WithContext(ctx)
+	snap := models.StatsSnapshot{ID: uuid.New().String()}
+
+	stored, err := s.Store.ArchiveIDs(ctx)
+	if err != nil {
+		return snap, err
+	}
+	lastID := ""
+	for {
+		var items []models.Archive
+		if err := db.Select("id", "html_path").Where("id > ?", lastID).
+			Order("id asc").Limit(statsSnapshotBatchSize).Find(&items).Error; err != nil {
+			return snap, err
+		}
+		if len(items) == 0 {
+			break
+		}
+		for _, item := range items {
+			snap.Archives++
+			if stored[item.ID] {
+				delete(stored, item.ID)
+			} else if item.HTMLPath != "" {
+				snap.ArchivesWithoutObjects++
+			}
+		}
+		lastID = items[len(items)-1].ID
+	}
+	snap.ObjectsWithoutArchives = int64(len(stored))
+
+	if err := db.Model(&models.Archive{}).Where("content_text IS NULL OR content_text = ''").
+		Count(&snap.ArchivesMissingContent).Error; err != nil {
+		return snap, err
+	}
+	if err := db.Model(&models.ArchivePath{}).
+		Where("NOT EXISTS (SELECT 1 FROM taxonomy_nodes WHERE taxonomy_nodes.id = archive_paths.node_id)").
+		Count(&snap.PathsWithoutNodes).Error; err != nil {
+		return snap, err
+	}
+
+	var prev models.StatsSnapshot
+	hasPrev := db.Order("taken_at desc").Limit(1).Find(&prev).RowsAffected > 0
+	snap.TakenAt = time.Now()
+	if err := db.Create(&snap).Error; err != nil {
+		return snap, err
+	}
+	if hasPrev {
+		before, after := statsCounts(prev), statsCounts(snap)
+		for _, name := range risingStats(prev, snap) {
+			log.Printf("stats snapshot: %s rose to %d (was %d)", name, after[name], before[name])
+		}
+	}
+	return snap, nil
+}
+
+// statsCountNames are the drift counts of a snapshot, by their JSON names.
+var statsCountNames = []string{"archivesWithoutObjects", "objectsWithoutArchives", "archivesMissingContent", "pathsWithoutNodes"}
+
+func statsCounts(snap models.StatsSnapshot) map[string]int64 {
+	return map[string]int64{
+		"archivesWithoutObjects": snap.ArchivesWithoutObjects,
+		"objectsWithoutArchives": snap.ObjectsWithoutArchives,
+		"archivesMissingContent": snap.ArchivesMissingContent,
+		"pathsWithoutNodes":      snap.PathsWithoutNodes,
+	}
+}
+
+// risingStats names the drift counts that are higher in next than in prev.
+func risingStats(prev, next models.StatsSnapshot) []string {
+	before, after := statsCounts(prev), statsCounts(next)
+	out := []string{}
+	for _, name := range statsCountNames {
+		if after[name] > before[name] {
+			out = append(out, name)
+		}
+	}
+	return out
+}
@@ -0,0 +1,256 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+	"webarchive/internal/textutil"
+)
+
+// structuredPrompts describe each type's JSON shape for the model, matching
+// the processor's structs.
+var structuredPrompts = map[string]string{
+	processor.StructuredRecipe:  `{"name": string, "description": string, "yield": string, "prepTime": string, "cookTime": string, "totalTime": string, "ingredients": [string], "steps": [string]}`,
+	processor.StructuredProduct: `{"name": string, "description": string, "brand": string, "sku": string, "price": string, "currency": string, "availability": string, "specs": {string: string}}`,
+	processor.StructuredEvent:   `{"name": string, "description": string, "startDate": string, "endDate": string, "location": string, "address": string, "organizer": string}`,
+}
+
+type CardField struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type CardList struct {
+	Key   string   `json:"key"`
+	Items []string `json:"items"`
+}
+
+// StructuredCard is the display form of an archive's structured data: a
+// title, key/value fields and lists, plus the typed record itself in Data.
+// Keys are stable identifiers for clients to label.
+type StructuredCard struct {
+	Type   string          `json:"type"`
+	Title  string          `json:"title"`
+	Image  string          `json:"image,omitempty"`
+	Fields []CardField     `json:"fields"`
+	Lists  []CardList      `json:"lists"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type StructuredRequest struct {
+	Type string `json:"type"`
+}
+
+func setStructured(item *models.Archive, st processor.Structured) {
+	item.StructuredType = st.Type
+	item.StructuredJSON = nil
+	if st.Type != "" {
+		item.StructuredJSON, _ = json.Marshal(st.Value())
+	}
+}
+
+func structuredCard(item models.Archive) *StructuredCard {
+	if item.StructuredType == "" || len(item.StructuredJSON) == 0 {
+		return nil
+	}
+	card := &StructuredCard{Type: item.StructuredType, Fields: []CardField{}, Lists: []CardList{}, Data: json.RawMessage(item.StructuredJSON)}
+	field := func(key, value string) {
+		if value != "" {
+			card.Fields = append(card.Fields, CardField{Key: key, Value: value})
+		}
+	}
+	list := func(key string, items []string) {
+		if len(items) > 0 {
+			card.Lists = append(card.Lists, CardList{Key: key, Items: items})
+		}
+	}
+	switch item.StructuredType {
+	case processor.StructuredRecipe:
+		var r processor.Recipe
+		if json.Unmarshal(item.StructuredJSON, &r) != nil {
+			return nil
+		}
+		card.Title, card.Image = r.Name, r.Image
+		field("yield", r.Yield)
+		field("prepTime", r.PrepTime)
+		field("cookTime", r.CookTime)
+		field("totalTime", r.TotalTime)
+		list("ingredients", r.Ingredients)
+		list("steps", r.Steps)
+	case processor.StructuredProduct:
+		var p processor.Product
+		if json.Unmarshal(item.StructuredJSON, &p) != nil {
+			return nil
+		}
+		card.Title, card.Image = p.Name, p.Image
+		price := p.Price
+		if price != "" && p.Currency != "" {
+			price += " " + p.Currency
+		}
+		field("price", price)
+		field("brand", p.Brand)
+		field("availability", p.Availability)
+		field("sku", p.SKU)
+		keys := make([]string, 0, len(p.Specs))
+		for k := range p.Specs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			field(k, p.Specs[k])
+		}
+	case processor.StructuredEvent:
+		var e processor.Event
+		if json.Unmarshal(item.StructuredJSON, &e) != nil {
+			return nil
+		}
+		card.Title, card.Image = e.Name, e.Image
+		field("startDate", e.StartDate)
+		field("endDate", e.EndDate)
+		field("location", e.Location)
+		field("address", e.Address)
+		field("organizer", e.Organizer)
+	default:
+		return nil
+	}
+	if card.Title == "" {
+		card.Title = item.Title
+	}
+	return card
+}
+
+// extractArchiveStructured re-runs structured extraction on an archive.
+// type is auto (detect from the stored page), recipe, product or event to
+// force one, or none to clear. A forced type the page does not declare is
+// extracted from the text by the model.
+func (s *Server) extractArchiveStructured(c *gin.Context) {
+	var req StructuredRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	kind := strings.ToLower(strings.TrimSpace(req.Type))
+	if kind == "" {
+		kind = "auto"
+	}
+	if _, ok := structuredPrompts[kind]; !ok && kind != "auto" && kind != "none" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type must be auto, none, " + strings.Join(processor.StructuredTypes, ", ")})
+		return
+	}
+	var item models.Archive
+	if err := s.DB.First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	before := s.snapshotArchive(item)
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 90*time.Second)
+	defer cancel()
+	var st processor.Structured
+	if kind != "none" {
+		page := s.storedPageHTML(ctx, item)
+		if kind == "auto" {
+			st = processor.DetectStructured(page)
+		} else if found, ok := processor.ExtractStructured(page, kind); ok {
+			st = found
+		} else {
+			var err error
+			if st, err = s.extractStructuredLLM(ctx, item, kind); err != nil {
+				c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+				return
+			}
+		}
+	}
+	setStructured(&item, st)
+	if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).Updates(map[string]any{
+		"structured_type": item.StructuredType,
+		"structured_json": item.StructuredJSON,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	s.recordArchiveEvent(item.ID, EventEdit, currentPrincipal(c).Username, before, s.snapshotArchive(item))
+	c.JSON(http.StatusOK, gin.H{"type": item.StructuredType, "card": structuredCard(item)})
+}
+
+// storedPageHTML reads the archived page, or nothing for metadata-only
+// archives and unreadable objects.
+func (s *Server) storedPageHTML(ctx context.Context, item models.Archive) []byte {
+	if item.HTMLPath == "" {
+		return nil
+	}
+	obj, err := s.Store.Get(ctx, storage.ArchivePrefix(item.ID)+"/index.html")
+	if err != nil {
+		return nil
+	}
+	defer obj.Close()
+	data, err := io.ReadAll(io.LimitReader(obj, 32<<20))
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+func (s *Server) extractStructuredLLM(ctx context.Context, item models.Archive, kind string) (processor.Structured, error) {
+	if s.LLM == nil || !s.LLM.Enabled() {
+		return processor.Structured{}, errors.New("page declares no " + kind + " and llm not configured")
+	}
+	st := processor.Structured{Type: kind}
+	var out any
+	switch kind {
+	case processor.StructuredRecipe:
+		st.Recipe = &processor.Recipe{}
+		out = st.Recipe
+	case processor.StructuredProduct:
+		st.Product = &processor.Product{}
+		out = st.Product
+	case processor.StructuredEvent:
+		st.Event = &processor.Event{}
+		out = st.Event
+	}
+	system := "You extract structured data from web pages. Return strict JSON only."
+	user := "Extract the " + kind + " described on this page, in the page's language. Leave fields empty when the page does not say.\n" +
+		"Return JSON: " + structuredPrompts[kind] + "\n" +
+		"Title: " + item.Title + "\nURL: " + item.URL + "\nContent: " + textutil.Truncate(strings.TrimSpace(item.ContentText), 6000)
+	validate := func() error {
+		if structuredName(st) == "" {
+			return errors.New("name is required")
+		}
+		return nil
+	}
+	if err := s.LLM.DecodeJSON(ctx, system, user, 0.1, out, validate); err != nil {
+		return processor.Structured{}, err
+	}
+	if st.Recipe != nil {
+		if st.Recipe.Ingredients == nil {
+			st.Recipe.Ingredients = []string{}
+		}
+		if st.Recipe.Steps == nil {
+			st.Recipe.Steps = []string{}
+		}
+	}
+	return st, nil
+}
+
+func structuredName(st processor.Structured) string {
+	switch {
+	case st.Recipe != nil:
+		return strings.TrimSpace(st.Recipe.Name)
+	case st.Product != nil:
+		return strings.TrimSpace(st.Product.Name)
+	case st.Event != nil:
+		return strings.TrimSpace(st.Event.Name)
+	}
+	return ""
+}
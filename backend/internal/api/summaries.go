@@ -0,0 +1,174 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/textutil"
+)
+
+// Summary styles, each stored in a column of its own: a one-sentence line
+// for lists, a few bullet key points, and a paragraph abstract for the
+// detail view.
+const (
+	SummaryOneLiner = "oneliner"
+	SummaryPoints   = "points"
+	SummaryAbstract = "abstract"
+)
+
+var summaryStyles = []string{SummaryOneLiner, SummaryPoints, SummaryAbstract}
+
+type SummarizeRequest struct {
+	// Styles to generate; empty means all of them.
+	Styles []string `json:"styles"`
+}
+
+type summaryReply struct {
+	OneLiner string   `json:"oneliner"`
+	Points   []string `json:"points"`
+	Abstract string   `json:"abstract"`
+}
+
+// normalizeSummaryStyles lower-cases and de-duplicates styles into their
+// canonical order, rejecting unknown ones.
+func normalizeSummaryStyles(styles []string) ([]string, error) {
+	seen := map[string]bool{}
+	for _, style := range styles {
+		style = strings.ToLower(strings.TrimSpace(style))
+		if style == "" {
+			continue
+		}
+		if !slices.Contains(summaryStyles, style) {
+			return nil, fmt.Errorf("unknown summary style %q, want oneliner, points or abstract", style)
+		}
+		seen[style] = true
+	}
+	out := []string{}
+	for _, style := range summaryStyles {
+		if seen[style] {
+			out = append(out, style)
+		}
+	}
+	return out, nil
+}
+
+// summarizeArchiveHandler generates the requested summary styles for an
+// archive and returns it updated.
+func (s *Server) summarizeArchiveHandler(c *gin.Context) {
+	if s.LLM == nil || !s.LLM.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "llm not configured"})
+		return
+	}
+	var req SummarizeRequest
+	_ = c.ShouldBindJSON(&req)
+	styles, err := normalizeSummaryStyles(req.Styles)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(styles) == 0 {
+		styles = summaryStyles
+	}
+	var item models.Archive
+	if err := s.DB.First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 90*time.Second)
+	defer cancel()
+	updated, err := s.summarizeArchive(ctx, item, styles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	paths, _ := s.loadArchivePaths(updated.ID)
+	c.JSON(http.StatusOK, toArchiveResponse(updated, paths))
+}
+
+// summarizeAfterCapture runs the summaries a capture or its preset asked
+// for once the archive is stored, off the request path.
+func (s *Server) summarizeAfterCapture(item models.Archive, styles []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	if _, err := s.summarizeArchive(ctx, item, styles); err != nil {
+		log.Printf("summarize %s: %v", item.ID, err)
+	}
+}
+
+// summarizeArchive asks the LLM for the given styles in one call and writes
+// only their columns; styles not asked for keep their current value.
+func (s *Server) summarizeArchive(ctx context.Context, item models.Archive, styles []string) (_ models.Archive, err error) {
+	ctx, done := s.startAITrace(ctx, item.ID, AITraceSummary, s.LLM.Model)
+	defer func() { done(err) }()
+
+	fields := []string{}
+	for _, style := range styles {
+		switch style {
+		case SummaryOneLiner:
+			fields = append(fields, "oneliner (string): one plain sentence of at most 25 words")
+		case SummaryPoints:
+			fields = append(fields, "points (array of strings): 3 to 6 short key points")
+		case SummaryAbstract:
+			fields = append(fields, "abstract (string): a detailed abstract of 120 to 200 words")
+		}
+	}
+	system := "You summarize archived web pages. Return strict JSON only. Write in the language of the content."
+	user := fmt.Sprintf("Summarize the following content.\nReturn JSON with fields:\n- %s\nTitle: %s\nURL: %s\nContent: %s",
+		strings.Join(fields, "\n- "), item.Title, item.URL, textutil.Truncate(strings.TrimSpace(item.ContentText), 8000))
+
+	var reply summaryReply
+	validate := func() error {
+		for _, style := range styles {
+			switch {
+			case style == SummaryOneLiner && strings.TrimSpace(reply.OneLiner) == "",
+				style == SummaryPoints && len(cleanPoints(reply.Points)) == 0,
+				style == SummaryAbstract && strings.TrimSpace(reply.Abstract) == "":
+				return errors.New(style + " is required")
+			}
+		}
+		return nil
+	}
+	if err := s.LLM.DecodeJSON(ctx, system, user, 0.3, &reply, validate); err != nil {
+		return item, err
+	}
+
+	columns := []string{}
+	for _, style := range styles {
+		switch style {
+		case SummaryOneLiner:
+			item.SummaryOneLiner = truncate(strings.TrimSpace(reply.OneLiner), 512)
+			columns = append(columns, "summary_one_liner")
+		case SummaryPoints:
+			item.SummaryPointsJSON, _ = json.Marshal(cleanPoints(reply.Points))
+			columns = append(columns, "summary_points_json")
+		case SummaryAbstract:
+			item.SummaryAbstract = strings.TrimSpace(reply.Abstract)
+			columns = append(columns, "summary_abstract")
+		}
+	}
+	if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).Select(columns).Updates(&item).Error; err != nil {
+		return item, err
+	}
+	return item, nil
+}
+
+// cleanPoints drops empty key points and bullet markers the model added.
+func cleanPoints(points []string) []string {
+	out := []string{}
+	for _, p := range points {
+		if p = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(p), "-*•")); p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
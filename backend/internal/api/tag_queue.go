@@ -0,0 +1,340 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/queue"
+)
+
+const maxDeadLetters = 100
+
+// Auto-tagging states recorded on an archive while its job travels
+// through the tag queue.
+const (
+	AITaskQueued  = "queued"
+	AITaskRunning = "running"
+	AITaskDone    = "done"
+	AITaskError   = "error"
+)
+
+// AITaskStatus is the archive's latest auto-tagging job; Error is set in
+// the error state and, while a retry is pending, holds the last failure.
+type AITaskStatus struct {
+	State     string     `json:"state"`
+	Error     string     `json:"error,omitempty"`
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+type tagJob struct {
+	ArchiveID string `json:"archiveId"`
+	Attempts  int    `json:"attempts"`
+	Priority  bool   `json:"priority"`
+}
+
+type DeadLetter struct {
+	ArchiveID string    `json:"archiveId"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error"`
+	FailedAt  time.Time `json:"failedAt"`
+}
+
+type TagQueueStatus struct {
+	Workers    int          `json:"workers"`
+	Capacity   int          `json:"capacity"`
+	Pending    int          `json:"pending"`
+	Priority   int          `json:"priority"`
+	InFlight   int          `json:"inFlight"`
+	Processed  int          `json:"processed"`
+	Retried    int          `json:"retried"`
+	DeadLetter []DeadLetter `json:"deadLetter"`
+}
+
+// TagQueue runs auto-tagging off the request path. Jobs travel through a
+// queue.Queue, so with a shared backend every replica's workers take jobs
+// any replica queued; the counters and dead letters in Status are this
+// replica's own.
+type TagQueue struct {
+	backend    queue.Queue
+	workers    int
+	maxRetries int
+	handle     func(ctx context.Context, archiveID string) error
+	ctx        context.Context
+	// stops ends one worker each, once it finishes its current job.
+	stops []context.CancelFunc
+	// OnDead, if set, hears about every job that lands in the dead letters.
+	OnDead func(archiveID, reason string)
+	// OnState, if set, hears every state change of a job: queued, running,
+	// done or error, with the failure message where there is one.
+	OnState func(archiveID, state, message string)
+
+	mu        sync.Mutex
+	inFlight  int
+	processed int
+	retried   int
+	dead      []DeadLetter
+}
+
+func NewTagQueue(backend queue.Queue, workers, maxRetries int, handle func(ctx context.Context, archiveID string) error) *TagQueue {
+	if workers <= 0 {
+		workers = 1
+	}
+	if maxRetries < 0 {
+		maxRetries = 0
+	}
+	return &TagQueue{
+		backend:    backend,
+		workers:    workers,
+		maxRetries: maxRetries,
+		handle:     handle,
+	}
+}
+
+func (q *TagQueue) Start(ctx context.Context) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.ctx = ctx
+	for i := 0; i < q.workers; i++ {
+		q.spawn()
+	}
+}
+
+// spawn starts a worker; q.mu must be held.
+func (q *TagQueue) spawn() {
+	stop, cancel := context.WithCancel(q.ctx)
+	q.stops = append(q.stops, cancel)
+	go q.worker(q.ctx, stop)
+}
+
+// SetWorkers grows or shrinks the worker pool. Surplus workers exit once
+// they finish their current job, so nothing in flight is dropped.
+func (q *TagQueue) SetWorkers(n int) {
+	if n <= 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	diff := n - q.workers
+	q.workers = n
+	if q.ctx == nil {
+		return
+	}
+	for ; diff > 0; diff-- {
+		q.spawn()
+	}
+	for ; diff < 0 && len(q.stops) > 0; diff++ {
+		q.stops[len(q.stops)-1]()
+		q.stops = q.stops[:len(q.stops)-1]
+	}
+}
+
+// Enqueue never waits for room: when the queue is full or its backend
+// unreachable the job goes straight to the dead letter list so a capture
+// burst can't stall request handlers.
+func (q *TagQueue) Enqueue(archiveID string, priority bool) bool {
+	job := tagJob{ArchiveID: archiveID, Priority: priority}
+	payload, _ := json.Marshal(job)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := q.backend.Push(ctx, payload, priority); err != nil {
+		q.deadLetter(job, err.Error())
+		return false
+	}
+	q.state(archiveID, AITaskQueued, "")
+	return true
+}
+
+func (q *TagQueue) state(archiveID, state, message string) {
+	if q.OnState != nil {
+		q.OnState(archiveID, state, message)
+	}
+}
+
+// worker takes jobs until stop is done; ctx bounds the jobs themselves, so
+// stopping a worker lets its current job finish.
+func (q *TagQueue) worker(ctx, stop context.Context) {
+	for {
+		payload, err := q.backend.Pop(stop)
+		if stop.Err() != nil {
+			return
+		}
+		if err != nil {
+			log.Printf("tag queue: %v", err)
+			continue
+		}
+		var job tagJob
+		if err := json.Unmarshal(payload, &job); err != nil || job.ArchiveID == "" {
+			log.Printf("tag queue: dropping malformed job %q", payload)
+			continue
+		}
+		q.run(ctx, job)
+	}
+}
+
+func (q *TagQueue) run(ctx context.Context, job tagJob) {
+	q.mu.Lock()
+	q.inFlight++
+	q.mu.Unlock()
+	defer func() {
+		q.mu.Lock()
+		q.inFlight--
+		q.mu.Unlock()
+	}()
+
+	for {
+		job.Attempts++
+		q.state(job.ArchiveID, AITaskRunning, "")
+		taskCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+		err := q.handle(taskCtx, job.ArchiveID)
+		cancel()
+		if err == nil {
+			q.mu.Lock()
+			q.processed++
+			q.mu.Unlock()
+			q.state(job.ArchiveID, AITaskDone, "")
+			return
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if job.Attempts > q.maxRetries {
+			q.deadLetter(job, err.Error())
+			return
+		}
+		q.mu.Lock()
+		q.retried++
+		q.mu.Unlock()
+		q.state(job.ArchiveID, AITaskQueued, err.Error())
+
+		backoff := time.Duration(job.Attempts*job.Attempts) * 2 * time.Second
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+	}
+}
+
+func (q *TagQueue) deadLetter(job tagJob, reason string) {
+	q.mu.Lock()
+	q.dead = append(q.dead, DeadLetter{
+		ArchiveID: job.ArchiveID,
+		Attempts:  job.Attempts,
+		Error:     reason,
+		FailedAt:  time.Now(),
+	})
+	if len(q.dead) > maxDeadLetters {
+		q.dead = q.dead[len(q.dead)-maxDeadLetters:]
+	}
+	onDead := q.OnDead
+	q.mu.Unlock()
+	q.state(job.ArchiveID, AITaskError, reason)
+	if onDead != nil {
+		onDead(job.ArchiveID, reason)
+	}
+}
+
+// Requeue moves every dead letter back onto the normal lane and returns how
+// many were accepted.
+func (q *TagQueue) Requeue() int {
+	q.mu.Lock()
+	dead := q.dead
+	q.dead = nil
+	q.mu.Unlock()
+
+	count := 0
+	for _, d := range dead {
+		if q.Enqueue(d.ArchiveID, false) {
+			count++
+		}
+	}
+	return count
+}
+
+func (q *TagQueue) Status() TagQueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	dead := make([]DeadLetter, len(q.dead))
+	copy(dead, q.dead)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	pending, priority, err := q.backend.Len(ctx)
+	if err != nil {
+		log.Printf("tag queue length: %v", err)
+	}
+	return TagQueueStatus{
+		Workers:    q.workers,
+		Capacity:   q.backend.Capacity(),
+		Pending:    pending,
+		Priority:   priority,
+		InFlight:   q.inFlight,
+		Processed:  q.processed,
+		Retried:    q.retried,
+		DeadLetter: dead,
+	}
+}
+
+func (s *Server) StartTagQueue(ctx context.Context, backend queue.Queue, workers, maxRetries int) {
+	s.TagQueue = NewTagQueue(backend, workers, maxRetries, s.autoTagArchive)
+	s.TagQueue.OnDead = s.notifyAutoTagFailed
+	s.TagQueue.OnState = s.setAITaskState
+	s.TagQueue.Start(ctx)
+}
+
+func (s *Server) autoTagArchive(ctx context.Context, archiveID string) error {
+	var item models.Archive
+	if err := s.DB.First(&item, "id = ?", archiveID).Error; err != nil {
+		return err
+	}
+	before := s.snapshotArchive(item)
+	updated, err := s.classifyArchive(ctx, item)
+	if err != nil {
+		return err
+	}
+	s.recordArchiveEvent(updated.ID, EventAutoTag, "queue", before, s.snapshotArchive(updated))
+	return nil
+}
+
+// setAITaskState records a tag queue job's state on its archive. It skips
+// the updated_at bump so a pending edit's optimistic check isn't spoiled by
+// the queue.
+func (s *Server) setAITaskState(archiveID, state, message string) {
+	err := s.DB.Model(&models.Archive{}).Where("id = ?", archiveID).UpdateColumns(map[string]any{
+		"ai_task_state": state,
+		"ai_task_error": truncate(message, 2000),
+		"ai_task_at":    time.Now(),
+	}).Error
+	if err != nil {
+		log.Printf("tag queue state %s for %s: %v", state, archiveID, err)
+	}
+}
+
+func aiTaskStatus(item models.Archive) *AITaskStatus {
+	if item.AITaskState == "" {
+		return nil
+	}
+	return &AITaskStatus{State: item.AITaskState, Error: item.AITaskError, UpdatedAt: item.AITaskAt}
+}
+
+func (s *Server) tagQueueStatus(c *gin.Context) {
+	if s.TagQueue == nil {
+		c.JSON(http.StatusOK, TagQueueStatus{DeadLetter: []DeadLetter{}})
+		return
+	}
+	c.JSON(http.StatusOK, s.TagQueue.Status())
+}
+
+func (s *Server) retryTagQueue(c *gin.Context) {
+	if s.TagQueue == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag queue not running"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"requeued": s.TagQueue.Requeue()})
+}
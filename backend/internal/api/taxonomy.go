@@ -1,37 +1,66 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"webarchive/internal/models"
+	"webarchive/internal/textutil"
 )
 
 type TaxonomyNodeResponse struct {
-	ID       string                 `json:"id"`
-	Label    string                 `json:"label"`
-	ParentID *string                `json:"parentId"`
-	Path     string                 `json:"path"`
-	Level    int                    `json:"level"`
-	Children []TaxonomyNodeResponse `json:"children,omitempty"`
+	ID       string  `json:"id"`
+	Label    string  `json:"label"`
+	ParentID *string `json:"parentId"`
+	Path     string  `json:"path"`
+	Level    int     `json:"level"`
+	// Labels are the node's translations by language and Aliases its other
+	// names. With ?lang= Label is the translation, where there is one, and
+	// DisplayPath the path made of translated labels.
+	Labels      map[string]string `json:"labels,omitempty"`
+	Aliases     []string          `json:"aliases,omitempty"`
+	DisplayPath string            `json:"displayPath,omitempty"`
+	// Description and Overview are only filled in by the node detail.
+	Description string                 `json:"description,omitempty"`
+	Overview    string                 `json:"overview,omitempty"`
+	Children    []TaxonomyNodeResponse `json:"children,omitempty"`
 }
 
+// getTaxonomy returns the whole tree; ?lang= shows labels in that language
+// where the nodes have a translation.
 func (s *Server) getTaxonomy(c *gin.Context) {
+	lang, err := normalizeLang(c.Query("lang"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	nodes, err := s.loadTaxonomyNodes()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
 		return
 	}
 	tree := buildTaxonomyTree(nodes)
+	if lang != "" {
+		localizeTaxonomyTree(tree, lang, "")
+	}
 	c.JSON(http.StatusOK, tree)
 }
 
 func (s *Server) getTaxonomyNode(c *gin.Context) {
 	id := c.Param("id")
 	includeDesc := c.Query("desc") == "1"
+	lang, err := normalizeLang(c.Query("lang"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	var node models.TaxonomyNode
 	if err := s.DB.First(&node, "id = ?", id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
@@ -80,24 +109,24 @@ func (s *Server) getTaxonomyNode(c *gin.Context) {
 		Children []TaxonomyNodeResponse `json:"children"`
 		Archives []ArchiveResponse      `json:"archives"`
 	}{
-		Node: TaxonomyNodeResponse{
-			ID:       node.ID,
-			Label:    node.Label,
-			ParentID: node.ParentID,
-			Path:     node.Path,
-			Level:    node.Level,
-		},
+		Node:     taxonomyNodeResponse(node),
 		Children: make([]TaxonomyNodeResponse, 0, len(children)),
 		Archives: make([]ArchiveResponse, 0, len(archives)),
 	}
+	resp.Node.Description = node.Description
+	resp.Node.Overview = s.freshOverview(node)
 	for _, child := range children {
-		resp.Children = append(resp.Children, TaxonomyNodeResponse{
-			ID:       child.ID,
-			Label:    child.Label,
-			ParentID: child.ParentID,
-			Path:     child.Path,
-			Level:    child.Level,
-		})
+		resp.Children = append(resp.Children, taxonomyNodeResponse(child))
+	}
+	if lang != "" {
+		parent, err := s.localizedPath(node, lang)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		resp.Node.Label = localizedLabel(resp.Node.Labels, lang, node.Label)
+		resp.Node.DisplayPath = joinLabel(parent, resp.Node.Label)
+		localizeTaxonomyTree(resp.Children, lang, resp.Node.DisplayPath)
 	}
 	for _, item := range archives {
 		paths, _ := s.loadArchivePaths(item.ID)
@@ -106,6 +135,53 @@ func (s *Server) getTaxonomyNode(c *gin.Context) {
 	c.JSON(http.StatusOK, resp)
 }
 
+func taxonomyNodeResponse(node models.TaxonomyNode) TaxonomyNodeResponse {
+	resp := TaxonomyNodeResponse{
+		ID:       node.ID,
+		Label:    node.Label,
+		ParentID: node.ParentID,
+		Path:     node.Path,
+		Level:    node.Level,
+		Labels:   nodeLabels(node),
+		Aliases:  jsonStrings(node.AliasesJSON),
+	}
+	if len(resp.Aliases) == 0 {
+		resp.Aliases = nil
+	}
+	return resp
+}
+
+// localizedPath is the display path of a node's parent in lang, made of its
+// ancestors' translated labels.
+func (s *Server) localizedPath(node models.TaxonomyNode, lang string) (string, error) {
+	parts := strings.Split(node.Path, "/")
+	if len(parts) < 2 {
+		return "", nil
+	}
+	prefixes := make([]string, 0, len(parts)-1)
+	for i := 1; i < len(parts); i++ {
+		prefixes = append(prefixes, strings.Join(parts[:i], "/"))
+	}
+	var ancestors []models.TaxonomyNode
+	if err := s.DB.Select("path", "label", "labels_json").Where("path IN ?", prefixes).Find(&ancestors).Error; err != nil {
+		return "", err
+	}
+	byPath := map[string]models.TaxonomyNode{}
+	for _, a := range ancestors {
+		byPath[a.Path] = a
+	}
+	labels := make([]string, 0, len(prefixes))
+	for i, prefix := range prefixes {
+		a, ok := byPath[prefix]
+		if !ok {
+			labels = append(labels, parts[i])
+			continue
+		}
+		labels = append(labels, localizedLabel(nodeLabels(a), lang, a.Label))
+	}
+	return strings.Join(labels, "/"), nil
+}
+
 func (s *Server) loadTaxonomyNodes() ([]models.TaxonomyNode, error) {
 	var nodes []models.TaxonomyNode
 	if err := s.DB.Order("level asc, label asc").Find(&nodes).Error; err != nil {
@@ -115,8 +191,12 @@ func (s *Server) loadTaxonomyNodes() ([]models.TaxonomyNode, error) {
 }
 
 func (s *Server) getNodeByPath(path string) (models.TaxonomyNode, error) {
+	return getNodeByPathDB(s.DB, path)
+}
+
+func getNodeByPathDB(db *gorm.DB, path string) (models.TaxonomyNode, error) {
 	var node models.TaxonomyNode
-	err := s.DB.Where("path = ?", path).Limit(1).Find(&node).Error
+	err := db.Where("path = ?", path).Limit(1).Find(&node).Error
 	return node, err
 }
 
@@ -126,13 +206,8 @@ func buildTaxonomyTree(nodes []models.TaxonomyNode) []TaxonomyNodeResponse {
 	roots := []*TaxonomyNodeResponse{}
 
 	for _, node := range nodes {
-		n := &TaxonomyNodeResponse{
-			ID:       node.ID,
-			Label:    node.Label,
-			ParentID: node.ParentID,
-			Path:     node.Path,
-			Level:    node.Level,
-		}
+		resp := taxonomyNodeResponse(node)
+		n := &resp
 		index[node.ID] = n
 		if node.ParentID != nil {
 			childrenMap[*node.ParentID] = append(childrenMap[*node.ParentID], n)
@@ -160,47 +235,207 @@ func buildTaxonomyTree(nodes []models.TaxonomyNode) []TaxonomyNodeResponse {
 	return out
 }
 
-func (s *Server) ensureTaxonomyPath(path []string) error {
-	clean := make([]string, 0, len(path))
+// taxonomyLimits bounds the shape of the taxonomy and tidies its labels;
+// see the Taxonomy* runtime settings.
+type taxonomyLimits struct {
+	maxDepth    int
+	maxOptions  int
+	maxPathLen  int
+	maxLabelLen int
+	labelRules  labelRules
+	// siblings returns the nodes already under a parent path, "" for the
+	// roots; nil leaves labels unmatched against existing nodes.
+	siblings func(parent string) []taxonomySibling
+	// warnings, when set, collects a note for every label the label rules
+	// changed, for endpoints that report them.
+	warnings *[]string
+}
+
+func (s *Server) taxonomyLimits() taxonomyLimits {
+	rt := s.runtimeSettings()
+	l := taxonomyLimits{
+		maxDepth:    rt.TaxonomyMaxDepth,
+		maxOptions:  rt.TaxonomyMaxOptions,
+		maxPathLen:  rt.TaxonomyMaxPathLength,
+		maxLabelLen: rt.TaxonomyMaxLabelLength,
+		labelRules: labelRules{
+			maxWords: rt.TaxonomyMaxLabelWords,
+			banned:   rt.TaxonomyBannedChars,
+			letters:  rt.TaxonomyLabelCase,
+			merge:    rt.TaxonomyMergeSimilar,
+		},
+	}
+	// Before runtime settings are applied (degraded start) use the defaults.
+	if l.maxDepth <= 0 {
+		l.maxDepth = 4
+	}
+	if l.maxOptions <= 0 {
+		l.maxOptions = 30
+	}
+	if l.maxPathLen <= 0 {
+		l.maxPathLen = 500
+	}
+	if l.maxLabelLen <= 0 {
+		l.maxLabelLen = 80
+	}
+	if l.labelRules.maxWords <= 0 {
+		l.labelRules.maxWords = 6
+	}
+	if s.DB != nil {
+		l.siblings = s.siblingLabels()
+	}
+	return l
+}
+
+// reporting returns a copy of l that collects label warnings, and the list
+// they land in.
+func (l taxonomyLimits) reporting() (taxonomyLimits, *[]string) {
+	warnings := []string{}
+	l.warnings = &warnings
+	return l, &warnings
+}
+
+func (l taxonomyLimits) warn(format string, args ...any) {
+	if l.warnings != nil {
+		*l.warnings = append(*l.warnings, fmt.Sprintf(format, args...))
+	}
+}
+
+// clamp trims and clips labels, tidies the ones that aren't already in the
+// taxonomy, then keeps as many levels as fit within the depth and path
+// length limits.
+func (l taxonomyLimits) clamp(path []string) []string {
+	out := make([]string, 0, len(path))
+	length := 0
 	for _, p := range path {
-		p = strings.TrimSpace(p)
-		if len(p) > 80 {
-			p = p[:80]
+		p = strings.TrimSpace(strings.ReplaceAll(p, "/", " "))
+		p = strings.TrimSpace(textutil.TruncateRunes(p, l.maxLabelLen))
+		if p == "" {
+			continue
+		}
+		if len(out) == l.maxDepth {
+			break
 		}
+		p = l.tidy(strings.Join(out, "/"), p)
 		if p == "" {
 			continue
 		}
-		clean = append(clean, p)
+		n := utf8.RuneCountInString(p)
+		if len(out) > 0 {
+			n++ // separator
+		}
+		if length+n > l.maxPathLen {
+			break
+		}
+		length += n
+		out = append(out, p)
 	}
+	return out
+}
+
+// clampPath is clamp for a slash-separated path.
+func (l taxonomyLimits) clampPath(path string) string {
+	return strings.Join(l.clamp(strings.Split(path, "/")), "/")
+}
+
+func (s *Server) ensureTaxonomyPath(path []string) error {
+	return ensureTaxonomyPathDB(s.DB, path, s.taxonomyLimits())
+}
+
+// taxonomyBatchSize bounds IN lists and multi-row inserts when filing
+// archives into the taxonomy.
+const taxonomyBatchSize = 500
+
+func ensureTaxonomyPathDB(db *gorm.DB, path []string, limits taxonomyLimits) error {
+	clean := limits.clamp(path)
 	if len(clean) == 0 {
 		return nil
 	}
+	_, err := taxonomyNodeIDsDB(db, []string{strings.Join(clean, "/")})
+	return err
+}
 
-	var parentID *string
-	for i, label := range clean {
-		nodePath := strings.Join(clean[:i+1], "/")
-		if len(nodePath) > 500 {
-			break
+// taxonomyNodeIDsDB maps each clamped path and all of its ancestors to a node
+// ID. Existing nodes are read in one pass; missing ones are inserted with one
+// batch per level so parents exist before their children.
+func taxonomyNodeIDsDB(db *gorm.DB, paths []string) (map[string]string, error) {
+	var all []string
+	var levels [][]string
+	seen := map[string]bool{}
+	for _, path := range paths {
+		parts := strings.Split(path, "/")
+		for i := range parts {
+			prefix := strings.Join(parts[:i+1], "/")
+			if seen[prefix] {
+				continue
+			}
+			seen[prefix] = true
+			all = append(all, prefix)
+			for len(levels) <= i {
+				levels = append(levels, nil)
+			}
+			levels[i] = append(levels[i], prefix)
+		}
+	}
+
+	ids := map[string]string{}
+	if err := loadTaxonomyNodeIDs(db, all, ids); err != nil {
+		return nil, err
+	}
+	for level, levelPaths := range levels {
+		var missing []models.TaxonomyNode
+		for _, path := range levelPaths {
+			if _, ok := ids[path]; ok {
+				continue
+			}
+			node := models.TaxonomyNode{ID: uuid.New().String(), Label: path, Path: path, Level: level}
+			if i := strings.LastIndex(path, "/"); i >= 0 {
+				parentID := ids[path[:i]]
+				node.Label = path[i+1:]
+				node.ParentID = &parentID
+			}
+			missing = append(missing, node)
+		}
+		if len(missing) == 0 {
+			continue
 		}
-		var node models.TaxonomyNode
-		tx := s.DB.Where("path = ?", nodePath).Limit(1).Find(&node)
-		if tx.Error != nil {
-			return tx.Error
-		}
-		if tx.RowsAffected == 0 {
-			id := uuid.New().String()
-			node = models.TaxonomyNode{
-				ID:       id,
-				Label:    label,
-				ParentID: parentID,
-				Path:     nodePath,
-				Level:    i,
+		res := db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&missing, taxonomyBatchSize)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		if res.RowsAffected == int64(len(missing)) {
+			for _, node := range missing {
+				ids[node.Path] = node.ID
 			}
-			if err := s.DB.Create(&node).Error; err != nil {
-				return err
+			continue
+		}
+		// Someone else created some of these nodes first; use their IDs.
+		created := make([]string, 0, len(missing))
+		for _, node := range missing {
+			created = append(created, node.Path)
+		}
+		if err := loadTaxonomyNodeIDs(db, created, ids); err != nil {
+			return nil, err
+		}
+		for _, path := range created {
+			if ids[path] == "" {
+				return nil, fmt.Errorf("taxonomy node %q missing after insert", path)
 			}
 		}
-		parentID = &node.ID
+	}
+	return ids, nil
+}
+
+func loadTaxonomyNodeIDs(db *gorm.DB, paths []string, ids map[string]string) error {
+	for start := 0; start < len(paths); start += taxonomyBatchSize {
+		end := min(start+taxonomyBatchSize, len(paths))
+		var rows []models.TaxonomyNode
+		if err := db.Select("id", "path").Where("path IN ?", paths[start:end]).Find(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			ids[row.Path] = row.ID
+		}
 	}
 	return nil
 }
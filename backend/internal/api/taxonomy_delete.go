@@ -0,0 +1,130 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+const AuditTaxonomyDelete = "taxonomy_delete"
+
+type TaxonomyDeletePreview struct {
+	ConfirmPreview
+	Path  string `json:"path"`
+	Nodes int    `json:"nodes"`
+	// Archives are filed somewhere in the subtree; Unfiled of them are
+	// filed nowhere else and end up without a path.
+	Archives int `json:"archives"`
+	Unfiled  int `json:"unfiled"`
+}
+
+// deleteTaxonomySubtree deletes a node and all its descendants. Archives
+// are kept and only lose the paths into the subtree. Without ?confirm= it
+// is a dry run returning the counts and the token to confirm with.
+func (s *Server) deleteTaxonomySubtree(c *gin.Context) {
+	var root models.TaxonomyNode
+	if err := s.DB.First(&root, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	var nodes []models.TaxonomyNode
+	if err := s.DB.Where("path = ? OR path LIKE ?", root.Path, root.Path+"/%").Find(&nodes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	nodeIDs := make([]string, 0, len(nodes))
+	paths := make([]string, 0, len(nodes))
+	inSubtree := map[string]bool{}
+	for _, n := range nodes {
+		nodeIDs = append(nodeIDs, n.ID)
+		paths = append(paths, n.Path)
+		inSubtree[n.Path] = true
+	}
+	var archiveIDs []string
+	if err := s.DB.Model(&models.ArchivePath{}).Where("node_id IN ?", nodeIDs).Distinct().Pluck("archive_id", &archiveIDs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	var keep []string
+	if len(archiveIDs) > 0 {
+		if err := s.DB.Model(&models.ArchivePath{}).Where("archive_id IN ? AND node_id NOT IN ?", archiveIDs, nodeIDs).Distinct().Pluck("archive_id", &keep).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+	}
+
+	// Archives filed into the subtree after the dry run change the set, so
+	// the token no longer confirms it.
+	affected := append(append([]string{}, nodeIDs...), archiveIDs...)
+	action := "taxonomy.delete." + root.ID
+	confirm := c.Query("confirm")
+	if confirm == "" {
+		c.JSON(http.StatusOK, TaxonomyDeletePreview{
+			ConfirmPreview: s.confirmPreview(c, action, affected),
+			Path:           root.Path,
+			Nodes:          len(nodes),
+			Archives:       len(archiveIDs),
+			Unfiled:        len(archiveIDs) - len(keep),
+		})
+		return
+	}
+	if !s.checkConfirm(c, confirm, action, affected) {
+		return
+	}
+
+	var items []models.Archive
+	if len(archiveIDs) > 0 {
+		if err := s.DB.Where("id IN ?", archiveIDs).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+	}
+	befores := make(map[string]*archiveSnapshot, len(items))
+	for _, item := range items {
+		befores[item.ID] = s.snapshotArchive(item)
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("node_id IN ?", nodeIDs).Delete(&models.ArchivePath{}).Error; err != nil {
+			return err
+		}
+		for _, item := range items {
+			if !inSubtree[item.HierarchyPath] {
+				if err := touchArchive(tx, item.ID); err != nil {
+					return err
+				}
+				continue
+			}
+			var next models.ArchivePath
+			if err := tx.Where("archive_id = ?", item.ID).Order("path asc").Limit(1).Find(&next).Error; err != nil {
+				return err
+			}
+			if err := setPrimaryHierarchy(tx, item.ID, next.Path); err != nil {
+				return err
+			}
+		}
+		return tx.Where("id IN ?", nodeIDs).Delete(&models.TaxonomyNode{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+
+	actor := currentPrincipal(c).Username
+	for _, item := range items {
+		var updated models.Archive
+		if err := s.DB.First(&updated, "id = ?", item.ID).Error; err == nil {
+			s.recordArchiveEvent(item.ID, EventBulk, actor, befores[item.ID], s.snapshotArchive(updated))
+		}
+	}
+	s.recordAdminAudit(c, AuditTaxonomyDelete, root.Path, paths, nil)
+	s.publishTaxonomyChanged("delete")
+	c.JSON(http.StatusOK, gin.H{"deleted": len(nodes), "archives": len(items)})
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/storage"
+)
+
+const exportBatchSize = 100
+
+// exportTaxonomyBranch streams a zip of every archive filed under a node or
+// its descendants. Folders mirror the hierarchy below the node; each archive
+// becomes a Markdown file (metadata, summary, text) and, with format=all or
+// html, its stored HTML snapshot. An archive filed under several paths
+// appears in each folder.
+func (s *Server) exportTaxonomyBranch(c *gin.Context) {
+	format := c.DefaultQuery("format", "all")
+	if format != "all" && format != "md" && format != "html" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be all, md or html"})
+		return
+	}
+	var node models.TaxonomyNode
+	if err := s.DB.First(&node, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	var paths []models.ArchivePath
+	if err := s.DB.Where("path = ? OR path LIKE ?", node.Path, node.Path+"/%").
+		Order("path asc").Find(&paths).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+
+	root := safeFilename(node.Label, "branch")
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": root + ".zip"}))
+	c.Status(http.StatusOK)
+
+	zw := zip.NewWriter(c.Writer)
+	defer zw.Close()
+	used := map[string]bool{}
+	ctx := c.Request.Context()
+	for start := 0; start < len(paths); start += exportBatchSize {
+		batch := paths[start:min(start+exportBatchSize, len(paths))]
+		ids := make([]string, 0, len(batch))
+		for _, p := range batch {
+			ids = append(ids, p.ArchiveID)
+		}
+		var items []models.Archive
+		if err := s.DB.Select("id", "title", "url", "summary", "tags_json", "captured_at", "html_path", "content_text").
+			Where("id IN ?", ids).Find(&items).Error; err != nil {
+			return
+		}
+		byID := make(map[string]models.Archive, len(items))
+		for _, item := range items {
+			byID[item.ID] = item
+		}
+		for _, p := range batch {
+			item, ok := byID[p.ArchiveID]
+			if !ok {
+				continue
+			}
+			name := exportName(used, branchFolder(root, node.Path, p.Path), item)
+			if format != "html" {
+				if err := writeZipEntry(zw, name+".md", strings.NewReader(archiveMarkdown(item, p.Path))); err != nil {
+					return
+				}
+			}
+			if format != "md" && item.HTMLPath != "" {
+				obj, err := s.Store.Get(ctx, path.Join(storage.ArchivePrefix(item.ID), item.HTMLPath))
+				if err != nil {
+					continue
+				}
+				// Skip snapshots that are gone rather than writing an empty entry.
+				_, err = obj.Stat()
+				if err == nil {
+					err = writeZipEntry(zw, name+".html", obj)
+				}
+				obj.Close()
+				if err != nil && ctx.Err() != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// branchFolder maps an archive path below the exported node onto a folder in
+// the zip, sanitizing each segment.
+func branchFolder(root, base, archivePath string) string {
+	folder := root
+	for _, segment := range strings.Split(strings.Trim(strings.TrimPrefix(archivePath, base), "/"), "/") {
+		if segment != "" {
+			folder += "/" + safeFilename(segment, "_")
+		}
+	}
+	return folder
+}
+
+// exportName picks a file name (without extension) for item inside folder,
+// adding the short archive id when two titles collide.
+func exportName(used map[string]bool, folder string, item models.Archive) string {
+	name := folder + "/" + safeFilename(item.Title, item.ID)
+	if used[strings.ToLower(name)] {
+		short := item.ID
+		if len(short) > 8 {
+			short = short[:8]
+		}
+		name += " (" + short + ")"
+	}
+	used[strings.ToLower(name)] = true
+	return name
+}
+
+func archiveMarkdown(item models.Archive, hierarchy string) string {
+	var b strings.Builder
+	title := strings.TrimSpace(item.Title)
+	if title == "" {
+		title = item.URL
+	}
+	fmt.Fprintf(&b, "# %s\n\n", strings.ReplaceAll(title, "\n", " "))
+	fmt.Fprintf(&b, "- URL: <%s>\n", item.URL)
+	fmt.Fprintf(&b, "- Path: %s\n", hierarchy)
+	if tags := jsonStrings(item.TagsJSON); len(tags) > 0 {
+		fmt.Fprintf(&b, "- Tags: %s\n", strings.Join(tags, ", "))
+	}
+	if item.CapturedAt != nil {
+		fmt.Fprintf(&b, "- Captured: %s\n", item.CapturedAt.UTC().Format(time.RFC3339))
+	}
+	fmt.Fprintf(&b, "- Archive ID: %s\n", item.ID)
+	for _, p := range provenanceChain(item.ProvenanceJSON) {
+		fmt.Fprintf(&b, "- Imported from: %s\n", p)
+	}
+	if summary := strings.TrimSpace(item.Summary); summary != "" {
+		fmt.Fprintf(&b, "\n## Summary\n\n%s\n", summary)
+	}
+	if text := strings.TrimSpace(item.ContentText); text != "" {
+		fmt.Fprintf(&b, "\n## Content\n\n%s\n", text)
+	}
+	return b.String()
+}
+
+func writeZipEntry(zw *zip.Writer, name string, r io.Reader) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, r)
+	return err
+}
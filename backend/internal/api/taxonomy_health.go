@@ -0,0 +1,269 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+const AuditTaxonomyPrune = "taxonomy_prune"
+
+var errTaxonomyChanged = errors.New("taxonomy changed")
+
+type TaxonomyHealthNode struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+}
+
+type TaxonomySimilarPair struct {
+	ParentPath string               `json:"parentPath"`
+	Nodes      []TaxonomyHealthNode `json:"nodes"`
+}
+
+type TaxonomyHealthResponse struct {
+	NodeCount int `json:"nodeCount"`
+	// EmptyNodes have no archives anywhere in their subtree; only the top of
+	// each empty subtree is listed.
+	EmptyNodes []TaxonomyHealthNode `json:"emptyNodes"`
+	// Chains are runs of nodes that hold no archives and have exactly one
+	// child, listed top-down.
+	Chains          [][]TaxonomyHealthNode `json:"chains"`
+	SimilarSiblings []TaxonomySimilarPair  `json:"similarSiblings"`
+}
+
+type taxonomyShape struct {
+	nodes    []models.TaxonomyNode
+	children map[string][]models.TaxonomyNode
+	direct   map[string]int
+	subtree  map[string]int
+}
+
+func (s *Server) loadTaxonomyShape() (*taxonomyShape, error) {
+	nodes, err := s.loadTaxonomyNodes()
+	if err != nil {
+		return nil, err
+	}
+	var counts []struct {
+		NodeID string
+		Count  int
+	}
+	err = s.DB.Model(&models.ArchivePath{}).
+		Select("node_id, COUNT(*) AS count").Group("node_id").Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+	shape := &taxonomyShape{
+		nodes:    nodes,
+		children: map[string][]models.TaxonomyNode{},
+		direct:   map[string]int{},
+		subtree:  map[string]int{},
+	}
+	for _, row := range counts {
+		shape.direct[row.NodeID] = row.Count
+	}
+	for _, n := range nodes {
+		parent := ""
+		if n.ParentID != nil {
+			parent = *n.ParentID
+		}
+		shape.children[parent] = append(shape.children[parent], n)
+	}
+	var walk func(id string) int
+	walk = func(id string) int {
+		total := shape.direct[id]
+		for _, child := range shape.children[id] {
+			total += walk(child.ID)
+		}
+		shape.subtree[id] = total
+		return total
+	}
+	for _, root := range shape.children[""] {
+		walk(root.ID)
+	}
+	return shape, nil
+}
+
+// emptyRoots returns the topmost nodes of every subtree without archives.
+func (t *taxonomyShape) emptyRoots() []models.TaxonomyNode {
+	out := []models.TaxonomyNode{}
+	var walk func(parent string)
+	walk = func(parent string) {
+		for _, n := range t.children[parent] {
+			if t.subtree[n.ID] == 0 {
+				out = append(out, n)
+				continue
+			}
+			walk(n.ID)
+		}
+	}
+	walk("")
+	return out
+}
+
+func (s *Server) getTaxonomyHealth(c *gin.Context) {
+	shape, err := s.loadTaxonomyShape()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	resp := TaxonomyHealthResponse{
+		NodeCount:       len(shape.nodes),
+		EmptyNodes:      []TaxonomyHealthNode{},
+		Chains:          [][]TaxonomyHealthNode{},
+		SimilarSiblings: []TaxonomySimilarPair{},
+	}
+	for _, n := range shape.emptyRoots() {
+		resp.EmptyNodes = append(resp.EmptyNodes, TaxonomyHealthNode{ID: n.ID, Path: n.Path})
+	}
+
+	passThrough := func(n models.TaxonomyNode) bool {
+		return shape.direct[n.ID] == 0 && len(shape.children[n.ID]) == 1
+	}
+	byID := map[string]models.TaxonomyNode{}
+	for _, n := range shape.nodes {
+		byID[n.ID] = n
+	}
+	for _, n := range shape.nodes {
+		if !passThrough(n) || shape.subtree[n.ID] == 0 {
+			continue
+		}
+		if n.ParentID != nil {
+			if parent, ok := byID[*n.ParentID]; ok && passThrough(parent) {
+				continue // not the start of the chain
+			}
+		}
+		chain := []TaxonomyHealthNode{}
+		cur := n
+		for passThrough(cur) {
+			chain = append(chain, TaxonomyHealthNode{ID: cur.ID, Path: cur.Path})
+			cur = shape.children[cur.ID][0]
+		}
+		chain = append(chain, TaxonomyHealthNode{ID: cur.ID, Path: cur.Path})
+		resp.Chains = append(resp.Chains, chain)
+	}
+
+	for parentID, siblings := range shape.children {
+		parentPath := ""
+		if parent, ok := byID[parentID]; ok {
+			parentPath = parent.Path
+		}
+		for i := 0; i < len(siblings); i++ {
+			for j := i + 1; j < len(siblings); j++ {
+				if similarLabels(siblings[i].Label, siblings[j].Label) {
+					resp.SimilarSiblings = append(resp.SimilarSiblings, TaxonomySimilarPair{
+						ParentPath: parentPath,
+						Nodes: []TaxonomyHealthNode{
+							{ID: siblings[i].ID, Path: siblings[i].Path},
+							{ID: siblings[j].ID, Path: siblings[j].Path},
+						},
+					})
+				}
+			}
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// pruneTaxonomy deletes every node whose subtree holds no archives.
+func (s *Server) pruneTaxonomy(c *gin.Context) {
+	shape, err := s.loadTaxonomyShape()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	ids := []string{}
+	paths := []string{}
+	for _, n := range shape.nodes {
+		if shape.subtree[n.ID] == 0 {
+			ids = append(ids, n.ID)
+			paths = append(paths, n.Path)
+		}
+	}
+	if len(ids) > 0 {
+		err = s.DB.Transaction(func(tx *gorm.DB) error {
+			// Re-check inside the transaction so a concurrent capture cannot
+			// lose its node.
+			var used int64
+			if err := tx.Model(&models.ArchivePath{}).Where("node_id IN ?", ids).Count(&used).Error; err != nil {
+				return err
+			}
+			if used > 0 {
+				return errTaxonomyChanged
+			}
+			return tx.Where("id IN ?", ids).Delete(&models.TaxonomyNode{}).Error
+		})
+		if errors.Is(err, errTaxonomyChanged) {
+			c.JSON(http.StatusConflict, gin.H{"error": "taxonomy changed, retry"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+			return
+		}
+		s.recordAdminAudit(c, AuditTaxonomyPrune, "taxonomy", paths, nil)
+		s.publishTaxonomyChanged("prune")
+	}
+	c.JSON(http.StatusOK, gin.H{"pruned": len(ids), "paths": paths})
+}
+
+// similarLabels flags sibling labels that likely name the same thing:
+// equal after folding case and punctuation, one containing the other, or
+// within a small edit distance.
+func similarLabels(a, b string) bool {
+	na, nb := foldLabel(a), foldLabel(b)
+	if na == "" || nb == "" {
+		return false
+	}
+	if na == nb {
+		return true
+	}
+	ra, rb := []rune(na), []rune(nb)
+	shorter := len(ra)
+	if len(rb) < shorter {
+		shorter = len(rb)
+	}
+	if shorter >= 4 && (strings.Contains(na, nb) || strings.Contains(nb, na)) {
+		return true
+	}
+	limit := shorter / 5
+	if limit < 1 {
+		return false
+	}
+	return editDistance(ra, rb) <= limit
+}
+
+func foldLabel(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func editDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,152 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"webarchive/internal/models"
+)
+
+// maxNodeNames bounds the translations and, separately, the aliases one
+// taxonomy node carries.
+const maxNodeNames = 20
+
+var langCode = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// taxonomySibling is a node under some parent as label matching sees it:
+// its label and every other name it answers to.
+type taxonomySibling struct {
+	label string
+	names []string
+}
+
+// normalizeLang lower-cases a language tag such as "zh_CN" to "zh-cn"; an
+// empty tag stays empty.
+func normalizeLang(raw string) (string, error) {
+	lang := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(raw)), "_", "-")
+	if lang != "" && !langCode.MatchString(lang) {
+		return "", fmt.Errorf("invalid language %q", raw)
+	}
+	return lang, nil
+}
+
+func nodeLabels(node models.TaxonomyNode) map[string]string {
+	var out map[string]string
+	if len(node.LabelsJSON) > 0 {
+		_ = json.Unmarshal(node.LabelsJSON, &out)
+	}
+	return out
+}
+
+// localizedLabel picks the label for lang, falling back from a regional tag
+// ("zh-tw") to its language ("zh") and then to the node's own label.
+func localizedLabel(labels map[string]string, lang, label string) string {
+	for lang != "" {
+		if l := labels[lang]; l != "" {
+			return l
+		}
+		i := strings.LastIndex(lang, "-")
+		if i < 0 {
+			break
+		}
+		lang = lang[:i]
+	}
+	return label
+}
+
+// localizeTaxonomyTree swaps every label for its lang translation and sets
+// the display path made of them; paths stay canonical.
+func localizeTaxonomyTree(tree []TaxonomyNodeResponse, lang, parent string) {
+	for i := range tree {
+		n := &tree[i]
+		n.Label = localizedLabel(n.Labels, lang, n.Label)
+		n.DisplayPath = joinLabel(parent, n.Label)
+		localizeTaxonomyTree(n.Children, lang, n.DisplayPath)
+	}
+}
+
+// normalizeNodeNames checks the translations and aliases a PATCH sets on a
+// node: labels may not contain "/", are trimmed and at most maxLen long, and
+// empty ones are dropped.
+func normalizeNodeNames(labels map[string]string, aliases []string, maxLen int) (map[string]string, []string, error) {
+	clean := func(name string) (string, error) {
+		name = strings.Join(strings.Fields(name), " ")
+		if strings.Contains(name, "/") {
+			return "", fmt.Errorf("label %q contains /", name)
+		}
+		if utf8.RuneCountInString(name) > maxLen {
+			return "", fmt.Errorf("label %q is longer than %d characters", name, maxLen)
+		}
+		return name, nil
+	}
+	if len(labels) > maxNodeNames || len(aliases) > maxNodeNames {
+		return nil, nil, fmt.Errorf("at most %d labels and %d aliases", maxNodeNames, maxNodeNames)
+	}
+	outLabels := map[string]string{}
+	for lang, label := range labels {
+		code, err := normalizeLang(lang)
+		if err != nil || code == "" {
+			return nil, nil, fmt.Errorf("invalid language %q", lang)
+		}
+		if label, err = clean(label); err != nil {
+			return nil, nil, err
+		}
+		if label != "" {
+			outLabels[code] = label
+		}
+	}
+	outAliases := []string{}
+	seen := map[string]bool{}
+	for _, alias := range aliases {
+		alias, err := clean(alias)
+		if err != nil {
+			return nil, nil, err
+		}
+		if alias != "" && !seen[foldLabel(alias)] {
+			seen[foldLabel(alias)] = true
+			outAliases = append(outAliases, alias)
+		}
+	}
+	return outLabels, outAliases, nil
+}
+
+// nodeNames lists every name a node answers to besides its label.
+func nodeNames(labels map[string]string, aliases []string) []string {
+	out := make([]string, 0, len(labels)+len(aliases))
+	langs := make([]string, 0, len(labels))
+	for lang := range labels {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	for _, lang := range langs {
+		out = append(out, labels[lang])
+	}
+	return append(out, aliases...)
+}
+
+func taxonomySiblingOf(node models.TaxonomyNode) taxonomySibling {
+	return taxonomySibling{label: node.Label, names: nodeNames(nodeLabels(node), jsonStrings(node.AliasesJSON))}
+}
+
+// siblingNameClash finds the sibling that already answers to one of names,
+// so two nodes under one parent never claim the same name.
+func siblingNameClash(siblings []taxonomySibling, self string, names []string) error {
+	for _, sibling := range siblings {
+		if sibling.label == self {
+			continue
+		}
+		for _, name := range names {
+			for _, other := range append([]string{sibling.label}, sibling.names...) {
+				if foldLabel(name) == foldLabel(other) {
+					return errors.New("name " + name + " is already used by sibling " + sibling.label)
+				}
+			}
+		}
+	}
+	return nil
+}
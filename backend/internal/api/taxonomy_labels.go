@@ -0,0 +1,176 @@
+package api
+
+import (
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"webarchive/internal/models"
+	"webarchive/internal/settings"
+)
+
+// labelRules keep new taxonomy labels tidy. They are soft: a label that
+// breaks one is fixed rather than refused, and the fix reported as a
+// warning.
+type labelRules struct {
+	maxWords int
+	banned   string
+	letters  string
+	merge    bool
+}
+
+// tidy applies the label rules to a label headed for parent. Labels that
+// already exist there are left alone, so the rules only shape new nodes and
+// never split an existing branch. A label that is a translation or alias of
+// an existing node is filed under that node's label, so "机器学习" and
+// "Machine Learning" share one node.
+func (l taxonomyLimits) tidy(parent, label string) string {
+	var siblings []taxonomySibling
+	var existing []string
+	if l.siblings != nil {
+		siblings = l.siblings(parent)
+		for _, sibling := range siblings {
+			existing = append(existing, sibling.label)
+		}
+		if slices.Contains(existing, label) {
+			return label
+		}
+		for _, sibling := range siblings {
+			for _, name := range sibling.names {
+				if foldLabel(name) == foldLabel(label) {
+					l.warn("label %q filed under %q, which it names", label, joinLabel(parent, sibling.label))
+					return sibling.label
+				}
+			}
+		}
+	}
+	r := l.labelRules
+	out := label
+	if r.banned != "" || strings.ContainsFunc(out, unicode.IsControl) {
+		out = strings.Map(func(c rune) rune {
+			if unicode.IsControl(c) || strings.ContainsRune(r.banned, c) {
+				return ' '
+			}
+			return c
+		}, out)
+	}
+	words := strings.Fields(out)
+	if r.maxWords > 0 && len(words) > r.maxWords {
+		words = words[:r.maxWords]
+	}
+	out = caseLabel(strings.Join(words, " "), r.letters)
+	if out == "" {
+		l.warn("label %q dropped: nothing left after removing banned characters", label)
+		return ""
+	}
+	if r.merge {
+		for _, sibling := range existing {
+			if nearIdenticalLabels(out, sibling) {
+				l.warn("label %q merged into existing %q", label, joinLabel(parent, sibling))
+				return sibling
+			}
+		}
+	}
+	if out != label {
+		l.warn("label %q tidied to %q", label, out)
+	}
+	return out
+}
+
+func joinLabel(parent, label string) string {
+	if parent == "" {
+		return label
+	}
+	return parent + "/" + label
+}
+
+func caseLabel(label, letters string) string {
+	switch letters {
+	case settings.CaseLower:
+		return strings.ToLower(label)
+	case settings.CaseSentence:
+		return upperFirst(label)
+	case settings.CaseTitle:
+		words := strings.Fields(label)
+		for i, w := range words {
+			words[i] = upperFirst(w)
+		}
+		return strings.Join(words, " ")
+	}
+	return label
+}
+
+func upperFirst(s string) string {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 {
+		return s
+	}
+	return string(unicode.ToUpper(r)) + s[size:]
+}
+
+// nearIdenticalLabels is the strict form of similarLabels used when filing:
+// the same after folding case and punctuation, or a single edit apart
+// (a typo or plural) once labels are long enough for that to be telling.
+// Containment doesn't count, since "Learning" under "Machine Learning" is a
+// real distinction.
+func nearIdenticalLabels(a, b string) bool {
+	na, nb := foldLabel(a), foldLabel(b)
+	if na == "" || nb == "" {
+		return false
+	}
+	if na == nb {
+		return true
+	}
+	ra, rb := []rune(na), []rune(nb)
+	if min(len(ra), len(rb)) < 5 {
+		return false
+	}
+	limit := 1
+	if min(len(ra), len(rb)) >= 12 {
+		limit = 2
+	}
+	return editDistance(ra, rb) <= limit
+}
+
+// siblingLabels looks up the nodes under each parent path once, for the
+// life of one taxonomyLimits.
+func (s *Server) siblingLabels() func(parent string) []taxonomySibling {
+	var mu sync.Mutex
+	seen := map[string][]taxonomySibling{}
+	return func(parent string) []taxonomySibling {
+		mu.Lock()
+		defer mu.Unlock()
+		if siblings, ok := seen[parent]; ok {
+			return siblings
+		}
+		siblings, err := s.taxonomySiblings(parent)
+		if err != nil {
+			log.Printf("taxonomy siblings of %q: %v", parent, err)
+		}
+		seen[parent] = siblings
+		return siblings
+	}
+}
+
+// taxonomySiblings reads the nodes directly under a parent path, "" for the
+// roots.
+func (s *Server) taxonomySiblings(parent string) ([]taxonomySibling, error) {
+	var nodes []models.TaxonomyNode
+	db := s.DB.Select("label", "labels_json", "aliases_json")
+	if parent == "" {
+		db = db.Where("parent_id IS NULL")
+	} else {
+		db = db.Where("parent_id = (?)", s.DB.Model(&models.TaxonomyNode{}).Select("id").Where("path = ?", parent))
+	}
+	if err := db.Find(&nodes).Error; err != nil {
+		return nil, err
+	}
+	out := make([]taxonomySibling, 0, len(nodes))
+	for _, node := range nodes {
+		out = append(out, taxonomySiblingOf(node))
+	}
+	return out, nil
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+type MoveArchivesRequest struct {
+	TargetID           string `json:"targetId"`
+	IncludeDescendants bool   `json:"includeDescendants"`
+}
+
+// moveTaxonomyArchives reassigns every archive filed under one node to
+// another. Only the affected paths change; other paths, tags and the
+// category are left alone. Empty source nodes are kept.
+func (s *Server) moveTaxonomyArchives(c *gin.Context) {
+	var req MoveArchivesRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.TargetID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "targetId required"})
+		return
+	}
+	var source, target models.TaxonomyNode
+	if err := s.DB.First(&source, "id = ?", c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if err := s.DB.First(&target, "id = ?", req.TargetID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "target not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if source.ID == target.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source and target are the same node"})
+		return
+	}
+
+	query := s.DB.Where("node_id = ?", source.ID)
+	if req.IncludeDescendants {
+		query = s.DB.Where("path = ? OR path LIKE ?", source.Path, source.Path+"/%")
+	}
+	var rows []models.ArchivePath
+	if err := query.Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	moved := map[string]map[string]bool{}
+	order := []string{}
+	for _, row := range rows {
+		if row.Path == target.Path {
+			continue
+		}
+		if moved[row.ArchiveID] == nil {
+			moved[row.ArchiveID] = map[string]bool{}
+			order = append(order, row.ArchiveID)
+		}
+		moved[row.ArchiveID][row.Path] = true
+	}
+	if len(order) == 0 {
+		c.JSON(http.StatusOK, gin.H{"moved": 0})
+		return
+	}
+
+	var items []models.Archive
+	if err := s.DB.Where("id IN ?", order).Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	befores := make(map[string]*archiveSnapshot, len(items))
+	for _, item := range items {
+		befores[item.ID] = s.snapshotArchive(item)
+	}
+
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		for _, item := range items {
+			paths := make([]string, 0, len(moved[item.ID]))
+			for path := range moved[item.ID] {
+				paths = append(paths, path)
+			}
+			if err := tx.Where("archive_id = ? AND path IN ?", item.ID, paths).Delete(&models.ArchivePath{}).Error; err != nil {
+				return err
+			}
+			var count int64
+			if err := tx.Model(&models.ArchivePath{}).Where("archive_id = ? AND path = ?", item.ID, target.Path).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				row := models.ArchivePath{
+					ID:        uuid.New().String(),
+					ArchiveID: item.ID,
+					NodeID:    target.ID,
+					Path:      target.Path,
+				}
+				if err := tx.Create(&row).Error; err != nil {
+					return err
+				}
+			}
+			if moved[item.ID][item.HierarchyPath] {
+				if err := setPrimaryHierarchy(tx, item.ID, target.Path); err != nil {
+					return err
+				}
+			} else if err := touchArchive(tx, item.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "move failed"})
+		return
+	}
+
+	actor := currentPrincipal(c).Username
+	for _, item := range items {
+		var updated models.Archive
+		if err := s.DB.First(&updated, "id = ?", item.ID).Error; err == nil {
+			s.recordArchiveEvent(item.ID, EventBulk, actor, befores[item.ID], s.snapshotArchive(updated))
+		}
+	}
+	s.publishTaxonomyChanged("move")
+	c.JSON(http.StatusOK, gin.H{"moved": len(items)})
+}
@@ -0,0 +1,209 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/textutil"
+)
+
+const overviewMaxArchives = 40
+
+type UpdateTaxonomyNodeRequest struct {
+	Description *string `json:"description"`
+	// Labels replaces the node's translations, keyed by language code, and
+	// Aliases its other names; the label itself and the path don't change.
+	Labels  *map[string]string `json:"labels"`
+	Aliases *[]string          `json:"aliases"`
+}
+
+func (s *Server) updateTaxonomyNode(c *gin.Context) {
+	var req UpdateTaxonomyNodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	var node models.TaxonomyNode
+	if err := s.DB.First(&node, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if req.Description != nil {
+		node.Description = strings.TrimSpace(*req.Description)
+		if err := s.DB.Model(&node).Update("description", node.Description).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+			return
+		}
+		s.publishTaxonomyChanged("describe")
+	}
+	if req.Labels != nil || req.Aliases != nil {
+		labels, aliases := nodeLabels(node), jsonStrings(node.AliasesJSON)
+		if req.Labels != nil {
+			labels = *req.Labels
+		}
+		if req.Aliases != nil {
+			aliases = *req.Aliases
+		}
+		labels, aliases, err := normalizeNodeNames(labels, aliases, s.taxonomyLimits().maxLabelLen)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		parent := ""
+		if i := strings.LastIndex(node.Path, "/"); i >= 0 {
+			parent = node.Path[:i]
+		}
+		siblings, err := s.taxonomySiblings(parent)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		if err := siblingNameClash(siblings, node.Label, nodeNames(labels, aliases)); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		node.LabelsJSON, _ = json.Marshal(labels)
+		node.AliasesJSON, _ = json.Marshal(aliases)
+		if err := s.DB.Model(&node).Updates(map[string]any{
+			"labels_json":  node.LabelsJSON,
+			"aliases_json": node.AliasesJSON,
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+			return
+		}
+		s.publishTaxonomyChanged("labels")
+	}
+	c.JSON(http.StatusOK, node)
+}
+
+// taxonomyOverview returns the cached overview for a node, regenerating it
+// when archives were added to or removed from the subtree since it was
+// written. Pass refresh=1 to regenerate regardless.
+func (s *Server) taxonomyOverview(c *gin.Context) {
+	var node models.TaxonomyNode
+	if err := s.DB.First(&node, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	ids, key, err := s.nodeMembership(node)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "node has no archives"})
+		return
+	}
+	if node.Overview != "" && node.OverviewKey == key && c.Query("refresh") != "1" {
+		c.JSON(http.StatusOK, gin.H{"overview": node.Overview, "overviewAt": node.OverviewAt, "archiveCount": len(ids), "cached": true})
+		return
+	}
+	if s.LLM == nil || !s.LLM.Enabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "llm not configured"})
+		return
+	}
+
+	var items []models.Archive
+	err = s.DB.Select("id", "title", "url", "summary", "excerpt").
+		Where("id IN ?", ids).Order("created_at desc").Limit(overviewMaxArchives).Find(&items).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 90*time.Second)
+	defer cancel()
+	overview, err := s.askOverview(ctx, node, items)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	err = s.DB.Model(&node).Updates(map[string]any{
+		"overview":     overview,
+		"overview_key": key,
+		"overview_at":  now,
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+		return
+	}
+	s.publishTaxonomyChanged("overview")
+	c.JSON(http.StatusOK, gin.H{"overview": overview, "overviewAt": now, "archiveCount": len(ids), "cached": false})
+}
+
+func (s *Server) askOverview(ctx context.Context, node models.TaxonomyNode, items []models.Archive) (string, error) {
+	var b strings.Builder
+	for _, item := range items {
+		title := item.Title
+		if title == "" {
+			title = item.URL
+		}
+		summary := item.Summary
+		if summary == "" {
+			summary = item.Excerpt
+		}
+		fmt.Fprintf(&b, "- %s: %s\n", textutil.TruncateRunes(title, 120), textutil.TruncateRunes(summary, 300))
+	}
+	system := "You summarize collections of saved articles. Return strict JSON only."
+	user := fmt.Sprintf("Write a short overview (3-5 sentences) of what the archives filed under the category %q cover: "+
+		"the main themes, how they relate and what stands out. Use the language most of the titles use.\n"+
+		"Return JSON: {\"overview\": string}.\n", node.Path)
+	if node.Description != "" {
+		user += "Category description: " + node.Description + "\n"
+	}
+	user += "Archives:\n" + textutil.Truncate(b.String(), 12000)
+
+	var resp struct {
+		Overview string `json:"overview"`
+	}
+	validate := func() error {
+		if strings.TrimSpace(resp.Overview) == "" {
+			return errors.New("overview is empty")
+		}
+		return nil
+	}
+	if err := s.LLM.DecodeJSON(ctx, system, user, 0.3, &resp, validate); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(resp.Overview), nil
+}
+
+// nodeMembership lists the archives filed under the node or its descendants
+// and a hash of that set, used to tell whether a cached overview is stale.
+func (s *Server) nodeMembership(node models.TaxonomyNode) ([]string, string, error) {
+	var ids []string
+	err := s.DB.Model(&models.ArchivePath{}).
+		Where("path = ? OR path LIKE ?", node.Path, node.Path+"/%").
+		Distinct().Pluck("archive_id", &ids).Error
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Strings(ids)
+	sum := sha256.Sum256([]byte(strings.Join(ids, ",")))
+	return ids, hex.EncodeToString(sum[:]), nil
+}
+
+// freshOverview returns the node's overview only while it still describes
+// the current membership.
+func (s *Server) freshOverview(node models.TaxonomyNode) string {
+	if node.Overview == "" {
+		return ""
+	}
+	_, key, err := s.nodeMembership(node)
+	if err != nil || key != node.OverviewKey {
+		return ""
+	}
+	return node.Overview
+}
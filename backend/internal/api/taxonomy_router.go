@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"webarchive/internal/ai"
+	"webarchive/internal/models"
+)
+
+// maxRouterTreeLines caps the tree sent by the single-call router. Nodes are
+// loaded shallowest first, so pruning drops the deepest branches.
+const maxRouterTreeLines = 300
+
+type singlePathResponse struct {
+	Path []string `json:"path"`
+}
+
+// pickPathSingle sends the (pruned) taxonomy tree in one prompt and asks for
+// the complete path. Existing branches must be followed exactly; only the
+// final label may be new, mirroring the stepwise router.
+func (s *Server) pickPathSingle(ctx context.Context, llm *ai.Client, item models.Archive, nodes []models.TaxonomyNode) ([]string, error) {
+	limits := s.taxonomyLimits()
+	known := map[string]bool{}
+	lines := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		known[n.Path] = true
+		if len(lines) < maxRouterTreeLines && n.Level < limits.maxDepth {
+			lines = append(lines, n.Path)
+		}
+	}
+
+	system := "You are a taxonomy router. Return strict JSON only."
+	user := "Place the content below in the taxonomy. Existing branches are listed one per line as slash-separated paths.\n" +
+		fmt.Sprintf("Return the full path from the top level down (1-%d labels). Follow existing branches exactly; ", limits.maxDepth) +
+		"only the last label may be new (<=6 words) when nothing fits.\n" +
+		"Return JSON: {\"path\": [\"top\", \"...\"]}\n" +
+		"Taxonomy:\n" + strings.Join(lines, "\n") + "\n" +
+		"Title: " + item.Title + "\nURL: " + item.URL + "\nExcerpt: " + item.Excerpt + "\nContent: " + trimContent(item.ContentText)
+
+	var resp singlePathResponse
+	validate := func() error { return validateRoutedPath(limits.clamp(resp.Path), known) }
+	if err := llm.DecodeJSON(ctx, system, user, 0.1, &resp, validate); err != nil {
+		return nil, err
+	}
+	return limits.clamp(resp.Path), nil
+}
+
+func validateRoutedPath(clean []string, known map[string]bool) error {
+	if len(clean) == 0 {
+		return errors.New("path must not be empty")
+	}
+	for i := 0; i < len(clean)-1; i++ {
+		prefix := strings.Join(clean[:i+1], "/")
+		if !known[prefix] {
+			return fmt.Errorf("%q is not an existing branch; only the last label may be new", prefix)
+		}
+	}
+	return nil
+}
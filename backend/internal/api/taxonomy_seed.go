@@ -0,0 +1,234 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+const AuditTaxonomySeed = "taxonomy_seed"
+
+var errTaxonomyNotEmpty = errors.New("taxonomy not empty")
+
+// taxonomySeed is a starter tree. Nodes lists paths with an optional
+// description after " | "; ancestors are created as needed.
+type taxonomySeed struct {
+	Name        string
+	Label       string
+	Description string
+	Nodes       []string
+}
+
+var taxonomySeeds = []taxonomySeed{
+	{
+		Name:        "software",
+		Label:       "软件工程",
+		Description: "编程语言、架构、运维与工程实践",
+		Nodes: []string{
+			"技术/编程语言/Go",
+			"技术/编程语言/Python",
+			"技术/编程语言/JavaScript",
+			"技术/编程语言/Rust",
+			"技术/编程语言/Java",
+			"技术/前端 | 浏览器、框架、CSS 与交互",
+			"技术/后端 | 服务端开发、API 设计",
+			"技术/数据库 | 关系型、NoSQL、查询优化",
+			"技术/架构设计 | 系统设计、分布式系统、设计模式",
+			"技术/运维与云 | 部署、容器、监控、云服务",
+			"技术/安全 | 漏洞、加密、认证授权",
+			"技术/人工智能 | 机器学习、大模型与应用",
+			"技术/工具 | 编辑器、命令行、开发效率",
+			"工程实践/测试",
+			"工程实践/代码审查",
+			"工程实践/团队与流程 | 协作、项目管理、工程文化",
+			"职业发展 | 面试、成长、行业观察",
+		},
+	},
+	{
+		Name:        "research",
+		Label:       "学术研究",
+		Description: "论文、方法、领域综述与写作",
+		Nodes: []string{
+			"论文/计算机科学",
+			"论文/自然科学",
+			"论文/社会科学",
+			"论文/人文",
+			"研究方法/实验设计",
+			"研究方法/统计分析",
+			"研究方法/定性研究",
+			"综述与教程 | 领域综述、讲义、教程",
+			"数据集 | 公开数据集与基准",
+			"学术写作 | 写作、投稿、审稿",
+			"学术动态 | 会议、期刊、资助与学术新闻",
+			"工具 | 文献管理、计算环境、绘图",
+		},
+	},
+	{
+		Name:        "cooking",
+		Label:       "烹饪",
+		Description: "菜谱、食材与厨房技巧",
+		Nodes: []string{
+			"菜谱/中餐",
+			"菜谱/西餐",
+			"菜谱/日韩料理",
+			"菜谱/东南亚料理",
+			"菜谱/烘焙甜点",
+			"菜谱/饮品",
+			"菜谱/素食",
+			"食材 | 食材知识、挑选与保存",
+			"厨房技巧 | 刀工、火候、调味",
+			"厨具 | 锅具、电器与测评",
+			"营养与健康",
+			"餐厅与美食探店",
+		},
+	},
+	{
+		Name:        "pkm",
+		Label:       "通用知识管理",
+		Description: "适合各类内容的宽泛分类",
+		Nodes: []string{
+			"技术",
+			"科学",
+			"商业与经济",
+			"社会与时事",
+			"历史与人文",
+			"艺术与设计",
+			"健康与生活",
+			"学习与效率 | 学习方法、时间管理、知识管理",
+			"职业与个人发展",
+			"旅行",
+			"娱乐 | 影视、音乐、游戏、书籍",
+			"参考资料 | 文档、手册、速查表",
+		},
+	},
+}
+
+type TaxonomySeedResponse struct {
+	Name        string   `json:"name"`
+	Label       string   `json:"label"`
+	Description string   `json:"description"`
+	Paths       []string `json:"paths"`
+}
+
+type TaxonomySeedRequest struct {
+	Seed string `json:"seed"`
+	// Force seeds a taxonomy that already has nodes. Existing nodes and
+	// their descriptions are kept; the seed only adds what is missing.
+	Force bool `json:"force"`
+}
+
+func seedPath(node string) (string, string) {
+	path, desc, _ := strings.Cut(node, "|")
+	return strings.Trim(strings.TrimSpace(path), "/"), strings.TrimSpace(desc)
+}
+
+func (seed taxonomySeed) response() TaxonomySeedResponse {
+	out := TaxonomySeedResponse{Name: seed.Name, Label: seed.Label, Description: seed.Description, Paths: []string{}}
+	for _, node := range seed.Nodes {
+		path, _ := seedPath(node)
+		out.Paths = append(out.Paths, path)
+	}
+	return out
+}
+
+func (s *Server) listTaxonomySeeds(c *gin.Context) {
+	out := make([]TaxonomySeedResponse, 0, len(taxonomySeeds))
+	for _, seed := range taxonomySeeds {
+		out = append(out, seed.response())
+	}
+	c.JSON(http.StatusOK, out)
+}
+
+// seedTaxonomy creates a starter tree so the router has branches to choose
+// from before any archive is filed. It is meant for a fresh install and
+// refuses a non-empty taxonomy unless forced.
+func (s *Server) seedTaxonomy(c *gin.Context) {
+	var req TaxonomySeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	var seed *taxonomySeed
+	for i := range taxonomySeeds {
+		if taxonomySeeds[i].Name == strings.TrimSpace(req.Seed) {
+			seed = &taxonomySeeds[i]
+		}
+	}
+	if seed == nil {
+		names := make([]string, 0, len(taxonomySeeds))
+		for _, t := range taxonomySeeds {
+			names = append(names, t.Name)
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "seed must be one of " + strings.Join(names, ", ")})
+		return
+	}
+
+	limits, warnings := s.taxonomyLimits().reporting()
+	paths := []string{}
+	descriptions := map[string]string{}
+	for _, node := range seed.Nodes {
+		path, desc := seedPath(node)
+		if path = limits.clampPath(path); path == "" {
+			continue
+		}
+		paths = append(paths, path)
+		if desc != "" {
+			descriptions[path] = desc
+		}
+	}
+
+	created := []string{}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.TaxonomyNode{}).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 && !req.Force {
+			return errTaxonomyNotEmpty
+		}
+		var before []string
+		if err := tx.Model(&models.TaxonomyNode{}).Pluck("path", &before).Error; err != nil {
+			return err
+		}
+		had := map[string]bool{}
+		for _, p := range before {
+			had[p] = true
+		}
+		ids, err := taxonomyNodeIDsDB(tx, paths)
+		if err != nil {
+			return err
+		}
+		for path := range ids {
+			if !had[path] {
+				created = append(created, path)
+			}
+		}
+		for path, desc := range descriptions {
+			if err := tx.Model(&models.TaxonomyNode{}).Where("id = ? AND description = ?", ids[path], "").
+				Update("description", desc).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errTaxonomyNotEmpty) {
+		c.JSON(http.StatusConflict, gin.H{"error": "taxonomy is not empty; pass force to add the seed anyway"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	sort.Strings(created)
+	if len(created) > 0 {
+		s.recordAdminAudit(c, AuditTaxonomySeed, seed.Name, nil, created)
+		s.publishTaxonomyChanged("seed")
+	}
+	c.JSON(http.StatusOK, gin.H{"seed": seed.Name, "created": created, "warnings": *warnings})
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"webarchive/internal/models"
+	"webarchive/internal/notify"
+)
+
+const (
+	// taxonomyWebhookLease keeps deliveries to one replica; its state is
+	// the cursor into archive_paths.
+	taxonomyWebhookLease = "taxonomy-webhooks"
+	taxonomyWebhookEvery = time.Minute
+	taxonomyWebhookBatch = 500
+	// taxonomyWebhookSettle leaves rows from transactions that may not have
+	// committed yet for the next pass.
+	taxonomyWebhookSettle = 5 * time.Second
+)
+
+// EventArchiveFiled is the type of taxonomy webhook payloads.
+const EventArchiveFiled = "archive.filed"
+
+type TaxonomyWebhookRequest struct {
+	Path string `json:"path"`
+	URL  string `json:"url"`
+}
+
+// filedCursor is the last archive_paths row delivered.
+type filedCursor struct {
+	At time.Time `json:"at"`
+	ID string    `json:"id"`
+}
+
+func (s *Server) listTaxonomyWebhooks(c *gin.Context) {
+	var hooks []models.TaxonomyWebhook
+	if err := s.DB.Order("path asc").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+func (s *Server) createTaxonomyWebhook(c *gin.Context) {
+	var req TaxonomyWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	path := strings.Trim(strings.TrimSpace(req.Path), "/")
+	if path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path required"})
+		return
+	}
+	u, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an http(s) link"})
+		return
+	}
+	hook := models.TaxonomyWebhook{ID: uuid.New().String(), Path: path, URL: u.String()}
+	if err := s.DB.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db insert failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditWebhookSave, hook.ID, nil, hook)
+	c.JSON(http.StatusOK, hook)
+}
+
+func (s *Server) deleteTaxonomyWebhook(c *gin.Context) {
+	var hook models.TaxonomyWebhook
+	if err := s.DB.First(&hook, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if err := s.DB.Delete(&models.TaxonomyWebhook{}, "id = ?", hook.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	s.recordAdminAudit(c, AuditWebhookDelete, hook.ID, hook, nil)
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// StartTaxonomyWebhookScheduler delivers archive.filed events for archive_paths rows
+// added since the last pass. Working from the table rather than from each
+// code path that files archives covers capture, edits, moves and imports
+// alike.
+func (s *Server) StartTaxonomyWebhookScheduler(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(taxonomyWebhookEvery)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if err := s.deliverTaxonomyWebhooks(ctx); err != nil {
+				log.Printf("taxonomy webhooks: %v", err)
+			}
+		}
+	}()
+}
+
+func (s *Server) deliverTaxonomyWebhooks(ctx context.Context) error {
+	held, err := s.acquireLease(taxonomyWebhookLease)
+	if err != nil || !held {
+		return err
+	}
+	defer func() {
+		if err := s.releaseLease(taxonomyWebhookLease); err != nil {
+			log.Printf("release taxonomy webhook lease: %v", err)
+		}
+	}()
+
+	horizon := time.Now().Add(-taxonomyWebhookSettle)
+	lease, _, err := s.loadLease(taxonomyWebhookLease)
+	if err != nil {
+		return err
+	}
+	var cursor filedCursor
+	if len(lease.State) == 0 || json.Unmarshal(lease.State, &cursor) != nil || cursor.At.IsZero() {
+		// The first pass starts from now rather than replaying history.
+		return s.saveLeaseState(taxonomyWebhookLease, filedCursor{At: horizon})
+	}
+	var hooks []models.TaxonomyWebhook
+	if err := s.DB.Find(&hooks).Error; err != nil {
+		return err
+	}
+
+	for {
+		// Slow endpoints can make a pass outlast the lease.
+		if held, _, err := s.renewLease(taxonomyWebhookLease); err != nil || !held {
+			return err
+		}
+		var rows []models.ArchivePath
+		if err := s.DB.Where("(created_at > ? OR (created_at = ? AND id > ?)) AND created_at <= ?", cursor.At, cursor.At, cursor.ID, horizon).
+			Order("created_at asc, id asc").Limit(taxonomyWebhookBatch).Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		if len(hooks) > 0 {
+			s.sendFiledEvents(ctx, hooks, rows)
+		}
+		last := rows[len(rows)-1]
+		cursor = filedCursor{At: last.CreatedAt, ID: last.ID}
+		if err := s.saveLeaseState(taxonomyWebhookLease, cursor); err != nil {
+			return err
+		}
+		if len(rows) < taxonomyWebhookBatch {
+			return nil
+		}
+	}
+}
+
+// sendFiledEvents posts one event per webhook and archive: an archive filed
+// under both A and A/B reaches a webhook on A once, naming the deepest path.
+func (s *Server) sendFiledEvents(ctx context.Context, hooks []models.TaxonomyWebhook, rows []models.ArchivePath) {
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ArchiveID)
+	}
+	var items []models.Archive
+	if err := s.DB.Select("id", "slug", "title", "url", "tags_json").Where("id IN ?", ids).Find(&items).Error; err != nil {
+		log.Printf("taxonomy webhooks: load archives: %v", err)
+		return
+	}
+	archives := map[string]models.Archive{}
+	for _, item := range items {
+		archives[item.ID] = item
+	}
+	for i := range hooks {
+		hook := &hooks[i]
+		filed := map[string]models.ArchivePath{}
+		order := []string{}
+		for _, row := range rows {
+			if row.Path != hook.Path && !strings.HasPrefix(row.Path, hook.Path+"/") {
+				continue
+			}
+			if _, ok := archives[row.ArchiveID]; !ok {
+				continue
+			}
+			prev, seen := filed[row.ArchiveID]
+			if !seen {
+				order = append(order, row.ArchiveID)
+			}
+			if !seen || len(row.Path) > len(prev.Path) {
+				filed[row.ArchiveID] = row
+			}
+		}
+		if len(order) == 0 {
+			continue
+		}
+		lastErr := ""
+		for _, id := range order {
+			row, item := filed[id], archives[id]
+			payload := gin.H{
+				"type":       EventArchiveFiled,
+				"webhookId":  hook.ID,
+				"node":       hook.Path,
+				"path":       row.Path,
+				"archiveId":  item.ID,
+				"title":      item.Title,
+				"url":        item.URL,
+				"tags":       archiveTags(item),
+				"archiveUrl": strings.TrimRight(s.BaseURL, "/") + "/archive/" + archiveRef(item),
+				"filedAt":    row.CreatedAt,
+			}
+			if err := notify.Webhook(ctx, hook.URL, payload); err != nil {
+				log.Printf("taxonomy webhook %s for %s: %v", hook.ID, item.ID, err)
+				lastErr = err.Error()
+			}
+		}
+		now := time.Now()
+		if err := s.DB.Model(&models.TaxonomyWebhook{}).Where("id = ?", hook.ID).
+			Updates(map[string]any{"last_sent_at": now, "last_error": truncate(lastErr, 512)}).Error; err != nil {
+			log.Printf("taxonomy webhook %s: %v", hook.ID, err)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/storage"
+)
+
+const StorageTierCold = "cold"
+
+const tieringBatchSize = 100
+
+type TieringStatus struct {
+	Enabled    bool       `json:"enabled"`
+	Months     int        `json:"months"`
+	Running    bool       `json:"running"`
+	Hot        int64      `json:"hot"`
+	Cold       int64      `json:"cold"`
+	Moved      int        `json:"moved"`
+	Objects    int        `json:"objects"`
+	Cutoff     *time.Time `json:"cutoff,omitempty"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+func (s *Server) tieringEnabled() bool {
+	return s.TieringMonths > 0 && s.Store != nil && s.Store.Cold != nil
+}
+
+func (s *Server) tieringJobStatus(c *gin.Context) {
+	status := s.getTieringStatus()
+	if err := s.DB.Model(&models.Archive{}).Where("storage_tier = ?", "").Count(&status.Hot).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if err := s.DB.Model(&models.Archive{}).Where("storage_tier = ?", StorageTierCold).Count(&status.Cold).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// runTieringNow starts a tiering pass instead of waiting for the scheduler.
+func (s *Server) runTieringNow(c *gin.Context) {
+	if !s.tieringEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tiering is not configured"})
+		return
+	}
+	s.startTiering()
+	c.JSON(http.StatusOK, s.getTieringStatus())
+}
+
+func (s *Server) stopTiering(c *gin.Context) {
+	s.tieringMu.Lock()
+	if s.tieringCancel != nil {
+		s.tieringCancel()
+		s.tieringCancel = nil
+	}
+	s.tieringMu.Unlock()
+	c.JSON(http.StatusOK, s.getTieringStatus())
+}
+
+func (s *Server) getTieringStatus() TieringStatus {
+	s.tieringMu.Lock()
+	defer s.tieringMu.Unlock()
+	status := s.tieringStatus
+	status.Enabled = s.tieringEnabled()
+	status.Months = s.TieringMonths
+	return status
+}
+
+// startTiering launches a pass unless one is already running.
+func (s *Server) startTiering() {
+	s.tieringMu.Lock()
+	defer s.tieringMu.Unlock()
+	if s.tieringStatus.Running {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	cutoff := now.AddDate(0, -s.TieringMonths, 0)
+	s.tieringCancel = cancel
+	s.tieringStatus = TieringStatus{Running: true, Cutoff: &cutoff, StartedAt: &now}
+	go s.runTiering(ctx, cutoff)
+}
+
+// runTiering moves the objects of archives captured before cutoff to the
+// cold tier. Archives stay readable throughout: reads fall back to the cold
+// bucket, and the hot copies are only deleted once the cold ones exist.
+func (s *Server) runTiering(ctx context.Context, cutoff time.Time) {
+	lastErr := ""
+	defer func() {
+		now := time.Now()
+		s.tieringMu.Lock()
+		s.tieringStatus.Running = false
+		s.tieringStatus.FinishedAt = &now
+		s.tieringStatus.LastError = lastErr
+		s.tieringCancel = nil
+		s.tieringMu.Unlock()
+		if lastErr != "" {
+			log.Printf("tiering: %s", lastErr)
+		}
+	}()
+
+	lastID := ""
+	for {
+		var items []models.Archive
+		if err := s.DB.Select("id").Where("id > ? AND storage_tier = ? AND COALESCE(captured_at, created_at) < ?", lastID, "", cutoff).
+			Order("id asc").Limit(tieringBatchSize).Find(&items).Error; err != nil {
+			lastErr = err.Error()
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		for _, item := range items {
+			if ctx.Err() != nil {
+				lastErr = "canceled"
+				return
+			}
+			objects, err := s.Store.MoveToCold(ctx, storage.ArchivePrefix(item.ID)+"/")
+			if err != nil {
+				lastErr = item.ID + ": " + err.Error()
+				return
+			}
+			if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).
+				UpdateColumn("storage_tier", StorageTierCold).Error; err != nil {
+				lastErr = err.Error()
+				return
+			}
+			s.tieringMu.Lock()
+			s.tieringStatus.Moved++
+			s.tieringStatus.Objects += objects
+			s.tieringMu.Unlock()
+		}
+		lastID = items[len(items)-1].ID
+	}
+}
+
+// StartTieringScheduler runs a tiering pass every interval when a cold tier
+// and TIERING_AFTER_MONTHS are configured.
+func (s *Server) StartTieringScheduler(ctx context.Context, interval time.Duration) {
+	if !s.tieringEnabled() {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.startTiering()
+		}
+	}()
+}
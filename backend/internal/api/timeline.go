@@ -0,0 +1,133 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const timelineTS = "COALESCE(captured_at, created_at)"
+
+var timelineFormats = map[string]string{
+	"day":   "%Y-%m-%d",
+	"week":  "%x-W%v",
+	"month": "%Y-%m",
+	"year":  "%Y",
+}
+
+type TimelineItem struct {
+	ID         string    `json:"id"`
+	Title      string    `json:"title"`
+	URL        string    `json:"url"`
+	SiteName   string    `json:"siteName"`
+	Favicon    string    `json:"favicon"`
+	CapturedAt time.Time `json:"capturedAt"`
+}
+
+type TimelineBucket struct {
+	Key   string         `json:"key"`
+	Count int64          `json:"count"`
+	Items []TimelineItem `json:"items"`
+}
+
+// getTimeline buckets archives by capture time (falling back to creation
+// time) with counts and the newest few items per bucket. Use samples=0 for
+// a count-only heatmap.
+func (s *Server) getTimeline(c *gin.Context) {
+	bucket := c.DefaultQuery("bucket", "day")
+	format, ok := timelineFormats[bucket]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "bucket must be day, week, month or year"})
+		return
+	}
+	from, err := parseTimelineDate(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from"})
+		return
+	}
+	to, err := parseTimelineDate(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to"})
+		return
+	}
+	if !to.IsZero() && len(strings.TrimSpace(c.Query("to"))) == len("2006-01-02") {
+		// A bare date means "through the end of that day".
+		to = to.Add(24*time.Hour - time.Nanosecond)
+	}
+	samples := parseLimit(c.Query("samples"), 3)
+	if samples > 20 {
+		samples = 20
+	}
+
+	// Filtering on the raw columns (not the COALESCE) lets MySQL use the
+	// captured_at / created_at indexes for range scans.
+	where := []string{}
+	args := []any{}
+	if !from.IsZero() {
+		where = append(where, "(captured_at >= ? OR (captured_at IS NULL AND created_at >= ?))")
+		args = append(args, from, from)
+	}
+	if !to.IsZero() {
+		where = append(where, "(captured_at <= ? OR (captured_at IS NULL AND created_at <= ?))")
+		args = append(args, to, to)
+	}
+	cond := ""
+	if len(where) > 0 {
+		cond = " WHERE " + strings.Join(where, " AND ")
+	}
+	keyExpr := "DATE_FORMAT(" + timelineTS + ", '" + format + "')"
+
+	var counts []struct {
+		Key   string
+		Count int64
+	}
+	if err := s.reader().Raw("SELECT "+keyExpr+" AS `key`, COUNT(*) AS count FROM archives"+cond+" GROUP BY `key` ORDER BY `key` DESC", args...).
+		Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+
+	byKey := map[string][]TimelineItem{}
+	if samples > 0 && len(counts) > 0 {
+		var rows []struct {
+			Key string
+			TimelineItem
+		}
+		query := "SELECT `key`, id, title, url, site_name, favicon, captured_at FROM (" +
+			"SELECT " + keyExpr + " AS `key`, id, title, url, site_name, favicon, " + timelineTS + " AS captured_at, " +
+			"ROW_NUMBER() OVER (PARTITION BY " + keyExpr + " ORDER BY " + timelineTS + " DESC) AS rn FROM archives" + cond +
+			") t WHERE rn <= ? ORDER BY captured_at DESC"
+		if err := s.reader().Raw(query, append(args, samples)...).Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		for _, row := range rows {
+			byKey[row.Key] = append(byKey[row.Key], row.TimelineItem)
+		}
+	}
+
+	var total int64
+	out := make([]TimelineBucket, 0, len(counts))
+	for _, row := range counts {
+		items := byKey[row.Key]
+		if items == nil {
+			items = []TimelineItem{}
+		}
+		total += row.Count
+		out = append(out, TimelineBucket{Key: row.Key, Count: row.Count, Items: items})
+	}
+	c.JSON(http.StatusOK, gin.H{"bucket": bucket, "total": total, "buckets": out})
+}
+
+func parseTimelineDate(raw string) (time.Time, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", raw, time.Local)
+}
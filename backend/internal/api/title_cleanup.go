@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+const titleCleanupBatchSize = 200
+
+type TitleCleanupStatus struct {
+	Running    bool       `json:"running"`
+	LLM        bool       `json:"llm"`
+	Checked    int        `json:"checked"`
+	Updated    int        `json:"updated"`
+	Failed     int        `json:"failed"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+type titleReply struct {
+	Title string `json:"title"`
+}
+
+// cleanCaptureTitle applies the title rules to a capture before it is
+// stored, when they are on.
+func (s *Server) cleanCaptureTitle(title, siteName, pageURL string) string {
+	if !s.TitleCleanup || title == "" {
+		return title
+	}
+	return processor.CleanTitle(title, siteName, urlHostname(pageURL))
+}
+
+// cleanTitleAfterCapture asks the LLM about a freshly stored title the rules
+// could not settle, off the request path.
+func (s *Server) cleanTitleAfterCapture(item models.Archive) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if _, err := s.cleanArchiveTitle(ctx, item, true); err != nil {
+		log.Printf("title cleanup %s: %v", item.ID, err)
+	}
+}
+
+func (s *Server) titleCleanupJobStatus(c *gin.Context) {
+	c.JSON(http.StatusOK, s.getTitleCleanupStatus())
+}
+
+// runTitleCleanupNow starts a pass over every archive; ?llm=1 also asks the
+// LLM about titles the rules leave with a separator in them.
+func (s *Server) runTitleCleanupNow(c *gin.Context) {
+	useLLM := c.Query("llm") == "1"
+	if useLLM && (s.LLM == nil || !s.LLM.Enabled()) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "llm not configured"})
+		return
+	}
+	s.startTitleCleanup(useLLM)
+	c.JSON(http.StatusOK, s.getTitleCleanupStatus())
+}
+
+func (s *Server) stopTitleCleanup(c *gin.Context) {
+	s.titleCleanupMu.Lock()
+	if s.titleCleanupCancel != nil {
+		s.titleCleanupCancel()
+		s.titleCleanupCancel = nil
+	}
+	s.titleCleanupMu.Unlock()
+	c.JSON(http.StatusOK, s.getTitleCleanupStatus())
+}
+
+func (s *Server) getTitleCleanupStatus() TitleCleanupStatus {
+	s.titleCleanupMu.Lock()
+	defer s.titleCleanupMu.Unlock()
+	return s.titleCleanupStatus
+}
+
+// startTitleCleanup launches a pass unless one is already running.
+func (s *Server) startTitleCleanup(useLLM bool) {
+	s.titleCleanupMu.Lock()
+	defer s.titleCleanupMu.Unlock()
+	if s.titleCleanupStatus.Running {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	s.titleCleanupCancel = cancel
+	s.titleCleanupStatus = TitleCleanupStatus{Running: true, LLM: useLLM, StartedAt: &now}
+	go s.runTitleCleanup(ctx, useLLM)
+}
+
+// runTitleCleanup walks all archives in id order and rewrites the titles
+// the rules, and optionally the LLM, change. The pass always applies the
+// rules, whatever the capture-time setting says.
+func (s *Server) runTitleCleanup(ctx context.Context, useLLM bool) {
+	lastErr := ""
+	defer func() {
+		now := time.Now()
+		s.titleCleanupMu.Lock()
+		s.titleCleanupStatus.Running = false
+		s.titleCleanupStatus.FinishedAt = &now
+		s.titleCleanupStatus.LastError = lastErr
+		s.titleCleanupCancel = nil
+		s.titleCleanupMu.Unlock()
+		if lastErr != "" {
+			log.Printf("title cleanup: %s", lastErr)
+		}
+	}()
+
+	lastID := ""
+	for {
+		var items []models.Archive
+		if err := s.DB.Model(&models.Archive{}).Select("id", "url", "final_url", "title", "site_name", "content_text").
+			Where("id > ? AND title <> ''", lastID).Order("id asc").Limit(titleCleanupBatchSize).Find(&items).Error; err != nil {
+			lastErr = err.Error()
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		for _, item := range items {
+			if ctx.Err() != nil {
+				lastErr = "canceled"
+				return
+			}
+			updated, err := s.cleanArchiveTitle(ctx, item, useLLM)
+			s.titleCleanupMu.Lock()
+			s.titleCleanupStatus.Checked++
+			if err != nil {
+				s.titleCleanupStatus.Failed++
+				s.titleCleanupStatus.LastError = err.Error()
+			} else if updated {
+				s.titleCleanupStatus.Updated++
+			}
+			s.titleCleanupMu.Unlock()
+		}
+		lastID = items[len(items)-1].ID
+	}
+}
+
+// cleanArchiveTitle stores the cleaned title of one archive when it differs
+// from the current one.
+func (s *Server) cleanArchiveTitle(ctx context.Context, item models.Archive, useLLM bool) (bool, error) {
+	pageURL := item.FinalURL
+	if pageURL == "" {
+		pageURL = item.URL
+	}
+	title := processor.CleanTitle(item.Title, item.SiteName, urlHostname(pageURL))
+	if useLLM && processor.TitleHasSeparator(title) {
+		cleaned, err := s.llmCleanTitle(ctx, item, title)
+		if err != nil {
+			return false, err
+		}
+		title = cleaned
+	}
+	if title == "" || title == item.Title {
+		return false, nil
+	}
+	if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).UpdateColumn("title", truncate(title, 500)).Error; err != nil {
+		return false, err
+	}
+	s.publishEvent(LiveArchiveUpdated, gin.H{"id": item.ID, "action": "title", "actor": "title-cleanup"})
+	return true, nil
+}
+
+// llmCleanTitle asks the LLM to drop the site name and boilerplate from a
+// title. It may only cut: a reply that is not part of the title is refused
+// and the title kept.
+func (s *Server) llmCleanTitle(ctx context.Context, item models.Archive, title string) (string, error) {
+	system := "You clean up web page titles. Return strict JSON only."
+	user := fmt.Sprintf("Remove the site or publication name, section names and boilerplate such as \"Home\" or \"Official Site\" from this page title. Do not rephrase, translate or add words; if nothing should be removed, return it unchanged.\nReturn JSON with field title (string).\nTitle: %s\nSite: %s\nURL: %s\nContent: %s",
+		title, item.SiteName, item.URL, truncate(strings.TrimSpace(item.ContentText), 500))
+	var reply titleReply
+	validate := func() error {
+		if strings.TrimSpace(reply.Title) == "" {
+			return errors.New("title is required")
+		}
+		return nil
+	}
+	if err := s.LLM.DecodeJSON(ctx, system, user, 0, &reply, validate); err != nil {
+		return title, err
+	}
+	cleaned := strings.Join(strings.Fields(reply.Title), " ")
+	if !strings.Contains(strings.ToLower(title), strings.ToLower(cleaned)) {
+		return title, nil
+	}
+	return cleaned, nil
+}
+
+// urlHostname is the host of rawURL without its port, or "" when it doesn't
+// parse.
+func urlHostname(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
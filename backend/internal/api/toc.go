@@ -0,0 +1,65 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+	"webarchive/internal/storage"
+)
+
+type TOCEntry struct {
+	processor.Heading
+	// Link opens the snapshot at the section; empty for headings of pages
+	// captured before anchors were assigned.
+	Link string `json:"link,omitempty"`
+}
+
+type TOCResponse struct {
+	ArchiveID string     `json:"archiveId"`
+	Sections  []TOCEntry `json:"sections"`
+}
+
+// getArchiveTOC lists the headings of the stored snapshot with the anchors
+// assigned at capture, for deep links and section-level citations.
+func (s *Server) getArchiveTOC(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.Select("id", "html_path").First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	resp := TOCResponse{ArchiveID: item.ID, Sections: []TOCEntry{}}
+	if item.HTMLPath == "" {
+		c.JSON(http.StatusOK, resp)
+		return
+	}
+	obj, err := s.Store.Get(c.Request.Context(), path.Join(storage.ArchivePrefix(item.ID), item.HTMLPath))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	defer obj.Close()
+	page, err := io.ReadAll(obj)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	headings, err := processor.TableOfContents(page)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "parse failed"})
+		return
+	}
+	for _, h := range headings {
+		entry := TOCEntry{Heading: h}
+		if h.ID != "" {
+			entry.Link = "/api/archives/" + url.PathEscape(item.ID) + "/html#" + url.PathEscape(h.ID)
+		}
+		resp.Sections = append(resp.Sections, entry)
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,186 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+)
+
+const (
+	trendDefaultPeriods = 6
+	trendMaxPeriods     = 24
+	trendDefaultTop     = 20
+)
+
+type TrendTopic struct {
+	Name   string `json:"name"`
+	Counts []int  `json:"counts"`
+	Total  int    `json:"total"`
+	// Growth is the change in the topic's share of the archives saved in
+	// the latest period against the one before, e.g. 0.5 for half again
+	// as much; nil when the topic is new in the latest period.
+	Growth *float64 `json:"growth"`
+}
+
+type TrendsResponse struct {
+	Window   string       `json:"window"`
+	Periods  []string     `json:"periods"`
+	Archives []int        `json:"archives"`
+	Tags     []TrendTopic `json:"tags"`
+	Entities []TrendTopic `json:"entities"`
+}
+
+// getTrends counts tags and entities per month or quarter of capture time,
+// oldest period first and ending with the current, still open one. Growth
+// compares shares rather than counts, so the open period isn't read as a
+// decline just for being short. The archive list filters apply.
+func (s *Server) getTrends(c *gin.Context) {
+	window := c.DefaultQuery("window", "month")
+	if window != "month" && window != "quarter" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "window must be month or quarter"})
+		return
+	}
+	periods := parseLimit(c.Query("periods"), trendDefaultPeriods)
+	if periods < 2 {
+		periods = 2
+	}
+	if periods > trendMaxPeriods {
+		periods = trendMaxPeriods
+	}
+	top := parseLimit(c.Query("top"), trendDefaultTop)
+	sortBy := c.DefaultQuery("sort", "total")
+	if sortBy != "total" && sortBy != "growth" && sortBy != "decline" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be total, growth or decline"})
+		return
+	}
+
+	starts := trendPeriodStarts(window, periods, time.Now())
+	from := starts[0]
+	var items []models.Archive
+	if err := s.filterArchives(s.reader(), c).Model(&models.Archive{}).
+		Select("id", "tags_json", "entities_json", "captured_at", "created_at").
+		Where("(captured_at >= ? OR (captured_at IS NULL AND created_at >= ?))", from, from).
+		Find(&items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+
+	resp := TrendsResponse{Window: window, Periods: make([]string, periods), Archives: make([]int, periods)}
+	for i, start := range starts {
+		resp.Periods[i] = trendPeriodKey(window, start)
+	}
+	tags := map[string][]int{}
+	entities := map[string][]int{}
+	count := func(m map[string][]int, names []string, period int) {
+		seen := map[string]bool{}
+		for _, name := range names {
+			name = strings.TrimSpace(name)
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			if m[name] == nil {
+				m[name] = make([]int, periods)
+			}
+			m[name][period]++
+		}
+	}
+	for _, item := range items {
+		at := item.CreatedAt
+		if item.CapturedAt != nil {
+			at = *item.CapturedAt
+		}
+		period := sort.Search(len(starts), func(i int) bool { return starts[i].After(at) }) - 1
+		if period < 0 {
+			continue
+		}
+		resp.Archives[period]++
+		count(tags, archiveTags(item), period)
+		count(entities, jsonStrings(item.EntitiesJSON), period)
+	}
+	resp.Tags = trendTopics(tags, resp.Archives, sortBy, top)
+	resp.Entities = trendTopics(entities, resp.Archives, sortBy, top)
+	c.JSON(http.StatusOK, resp)
+}
+
+// trendPeriodStarts returns the start of each of the last n periods in local
+// time, oldest first; the last one contains now.
+func trendPeriodStarts(window string, n int, now time.Time) []time.Time {
+	months := 1
+	month := now.Month()
+	if window == "quarter" {
+		months = 3
+		month -= (month - 1) % 3
+	}
+	current := time.Date(now.Year(), month, 1, 0, 0, 0, 0, now.Location())
+	starts := make([]time.Time, n)
+	for i := range starts {
+		starts[i] = current.AddDate(0, -months*(n-1-i), 0)
+	}
+	return starts
+}
+
+func trendPeriodKey(window string, start time.Time) string {
+	if window == "quarter" {
+		return fmt.Sprintf("%d-Q%d", start.Year(), (int(start.Month())+2)/3)
+	}
+	return start.Format("2006-01")
+}
+
+func trendTopics(counts map[string][]int, archives []int, sortBy string, top int) []TrendTopic {
+	last := len(archives) - 1
+	share := func(n, period int) float64 {
+		if archives[period] == 0 {
+			return 0
+		}
+		return float64(n) / float64(archives[period])
+	}
+	out := make([]TrendTopic, 0, len(counts))
+	for name, series := range counts {
+		t := TrendTopic{Name: name, Counts: series}
+		for _, n := range series {
+			t.Total += n
+		}
+		if prev := share(series[last-1], last-1); prev > 0 {
+			g := share(series[last], last)/prev - 1
+			t.Growth = &g
+		}
+		out = append(out, t)
+	}
+	growth := func(t TrendTopic) float64 {
+		if t.Growth == nil {
+			if t.Counts[last] > 0 {
+				return 1e9 // new this period
+			}
+			return 0
+		}
+		return *t.Growth
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		switch sortBy {
+		case "growth":
+			if ga, gb := growth(a), growth(b); ga != gb {
+				return ga > gb
+			}
+		case "decline":
+			if ga, gb := growth(a), growth(b); ga != gb {
+				return ga < gb
+			}
+		}
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.Name < b.Name
+	})
+	if top > 0 && len(out) > top {
+		out = out[:top]
+	}
+	return out
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"webarchive/internal/models"
+	"webarchive/internal/textutil"
+)
+
+// unfurlDescriptionRunes matches what chat apps show of og:description
+// before cutting it off themselves.
+const unfurlDescriptionRunes = 300
+
+// UnfurlResponse carries the OpenGraph fields of an archive under their
+// og: names, so a client can copy them straight into meta tags.
+type UnfurlResponse struct {
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	SiteName    string `json:"siteName"`
+	// URL is the archived copy; OriginalURL is the page it was taken from.
+	URL         string `json:"url"`
+	OriginalURL string `json:"originalUrl"`
+	// Image is empty when the archive has no screenshot.
+	Image         string     `json:"image"`
+	Favicon       string     `json:"favicon,omitempty"`
+	Author        string     `json:"author,omitempty"`
+	PublishedTime *time.Time `json:"publishedTime,omitempty"`
+	Tags          []string   `json:"tags"`
+}
+
+// unfurl builds the preview for item. Links point at the public garden
+// when the archive is published there, since that is the only copy a chat
+// app fetching the preview can open; otherwise they need a login.
+func (s *Server) unfurl(item models.Archive) UnfurlResponse {
+	base := strings.TrimRight(s.BaseURL, "/")
+	public := s.PublicEnabled && item.Published
+	resp := UnfurlResponse{
+		Type:          "article",
+		Title:         item.Title,
+		Description:   unfurlDescription(item),
+		SiteName:      item.SiteName,
+		URL:           base + "/archive/" + archiveRef(item),
+		OriginalURL:   item.URL,
+		Favicon:       item.Favicon,
+		Author:        item.Byline,
+		PublishedTime: item.CapturedAt,
+		Tags:          jsonStrings(item.TagsJSON),
+	}
+	if resp.Title == "" {
+		resp.Title = item.URL
+	}
+	if resp.SiteName == "" {
+		resp.SiteName = item.Domain
+	}
+	if public {
+		resp.URL = base + "/api/public/archives/" + archiveRef(item) + "/html"
+	}
+	if item.ScreenshotPath != "" {
+		if public {
+			resp.Image = base + "/api/public/archives/" + item.ID + "/screenshot"
+		} else {
+			resp.Image = base + "/api/archives/" + item.ID + "/screenshot"
+		}
+	}
+	return resp
+}
+
+// unfurlDescription prefers the page's own excerpt, then the AI summary,
+// then the start of the text.
+func unfurlDescription(item models.Archive) string {
+	for _, text := range []string{item.Excerpt, item.Summary, item.ContentText} {
+		if text = strings.Join(strings.Fields(text), " "); text != "" {
+			return textutil.TruncateRunes(text, unfurlDescriptionRunes)
+		}
+	}
+	return ""
+}
+
+func (s *Server) getArchiveUnfurl(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	c.JSON(http.StatusOK, s.unfurl(item))
+}
+
+func (s *Server) publicArchiveUnfurl(c *gin.Context) {
+	if item, ok := s.loadPublishedArchive(c); ok {
+		c.JSON(http.StatusOK, s.unfurl(item))
+	}
+}
+
+func (s *Server) publicScreenshot(c *gin.Context) {
+	if _, ok := s.loadPublishedArchive(c); ok {
+		s.getArchiveScreenshot(c)
+	}
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// viewerTicketTTL bounds how long a viewer link works. It is generous
+// because lazily loaded assets are fetched with the page's ticket long
+// after the page itself.
+const viewerTicketTTL = 12 * time.Hour
+
+// publicTicket takes the place of a signed ticket for published archives,
+// which need no credentials.
+const publicTicket = "public"
+
+const viewerOriginKey = "viewerOrigin"
+
+// viewerCSP replaces the snapshot CSP on the viewer origin: archived scripts
+// may still run, but cannot call out, submit forms or load plugins.
+const viewerCSP = "default-src 'self' data: blob:; img-src 'self' data: blob:; style-src 'self' 'unsafe-inline' data:; font-src 'self' data:; " +
+	"media-src 'self' data:; script-src 'self' 'unsafe-inline'; connect-src 'none'; form-action 'none'; base-uri 'none'; object-src 'none'"
+
+// snapshotCSP is the snapshot CSP on the API origin without logins.
+const snapshotCSP = "default-src 'self' data: blob:; img-src 'self' data: blob:; style-src 'self' 'unsafe-inline' data:; font-src 'self' data:; media-src 'self' data:; script-src 'self' 'unsafe-inline'"
+
+// sessionOriginCSP replaces it once logins are on: the session cookie is
+// sent with every request from the API origin, so an archived script there
+// could call the API as whoever is viewing the page. No script runs;
+// ARCHIVE_VIEWER_ORIGIN serves pages with their scripts instead.
+const sessionOriginCSP = "default-src 'self' data: blob:; img-src 'self' data: blob:; style-src 'self' 'unsafe-inline' data:; font-src 'self' data:; " +
+	"media-src 'self' data:; script-src 'none'; connect-src 'none'; form-action 'none'; base-uri 'none'; object-src 'none'"
+
+// viewerAssetCSP keeps an asset opened directly (an SVG, an HTML frame) from
+// running as a document.
+const viewerAssetCSP = "sandbox; default-src 'none'; img-src 'self' data:; style-src 'unsafe-inline'"
+
+// viewerHost restricts the viewer origin to the /view routes and keeps them
+// off the API origin, so archived pages never share an origin, and thereby
+// cookies or storage, with the management UI and API. Links between
+// archives inside a page go back to BASE_URL.
+func (s *Server) viewerHost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		view := strings.HasPrefix(c.Request.URL.Path, "/view/")
+		if s.ViewerOrigin == "" {
+			if view {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "not found"})
+				return
+			}
+			c.Next()
+			return
+		}
+		onViewer := strings.EqualFold(c.Request.Host, viewerHostname(s.ViewerOrigin))
+		switch {
+		case onViewer && strings.HasPrefix(c.Request.URL.Path, "/archive/"):
+			c.Redirect(http.StatusFound, strings.TrimRight(s.BaseURL, "/")+c.Request.URL.RequestURI())
+			c.Abort()
+		case onViewer != view:
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "not found"})
+		default:
+			if onViewer {
+				c.Set(viewerOriginKey, true)
+				c.Header("Referrer-Policy", "no-referrer")
+				c.Header("X-Content-Type-Options", "nosniff")
+			}
+			c.Next()
+		}
+	}
+}
+
+func viewerHostname(origin string) string {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+func onViewerOrigin(c *gin.Context) bool {
+	return c.GetBool(viewerOriginKey)
+}
+
+// pageCSP is the CSP an archived page is served with.
+func (s *Server) pageCSP(c *gin.Context) string {
+	switch {
+	case onViewerOrigin(c):
+		return viewerCSP
+	case s.AuthEnabled:
+		return sessionOriginCSP
+	default:
+		return snapshotCSP
+	}
+}
+
+// viewerTicket signs access to one archive's page and assets until expires.
+func (s *Server) viewerTicket(archiveID string, expires time.Time) string {
+	exp := strconv.FormatInt(expires.Unix(), 36)
+	return exp + "-" + s.viewerSignature(archiveID, exp)
+}
+
+func (s *Server) viewerSignature(archiveID, exp string) string {
+	mac := hmac.New(sha256.New, s.ViewerSecret)
+	mac.Write([]byte(archiveID + "\n" + exp))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+func (s *Server) validViewerTicket(ticket, archiveID string) bool {
+	exp, sig, ok := strings.Cut(ticket, "-")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 36, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.viewerSignature(archiveID, exp)))
+}
+
+// redirectToViewer sends a request for an archive's page or asset on the API
+// origin to the same resource on the viewer origin, keeping the query
+// (theme, highlight, annotations, download) but not the access token. marker is the
+// route segment ("/archives/" or "/assets/") the viewer path continues
+// from. It reports whether it redirected.
+func (s *Server) redirectToViewer(c *gin.Context, id, marker string, public bool) bool {
+	if s.ViewerOrigin == "" || onViewerOrigin(c) {
+		return false
+	}
+	ticket := publicTicket
+	if !public {
+		// Tickets change once an hour, so browsers can cache what they load.
+		ticket = s.viewerTicket(id, time.Now().Truncate(time.Hour).Add(viewerTicketTTL))
+	}
+	escaped := c.Request.URL.EscapedPath()
+	rest := escaped[strings.Index(escaped, marker)+1:]
+	query := c.Request.URL.Query()
+	query.Del("access_token")
+	target := strings.TrimRight(s.ViewerOrigin, "/") + "/view/" + ticket + "/" + rest
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, target)
+	return true
+}
+
+// viewArchiveHTML and viewAsset serve the viewer origin. The ticket stands
+// in for the API credentials, which are never sent here.
+func (s *Server) viewArchiveHTML(c *gin.Context) {
+	if c.Param("ticket") == publicTicket && s.PublicEnabled {
+		s.publicArchiveHTML(c)
+		return
+	}
+	if !s.validViewerTicket(c.Param("ticket"), c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	s.serveArchiveHTML(c, c.Param("id"), false)
+}
+
+func (s *Server) viewAsset(c *gin.Context) {
+	if c.Param("ticket") == publicTicket && s.PublicEnabled {
+		s.publicAsset(c)
+		return
+	}
+	if !s.validViewerTicket(c.Param("ticket"), c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	s.getAsset(c)
+}
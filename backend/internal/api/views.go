@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+// viewSession is how long reopening an archive counts as the same view.
+const viewSession = 30 * time.Minute
+
+type RecentView struct {
+	Archive  ArchiveResponse `json:"archive"`
+	ViewedAt time.Time       `json:"viewedAt"`
+	Views    int64           `json:"views"`
+}
+
+// recordView notes that user opened an archive, for the recent views list
+// and resurfacing. Failures are only logged; the page is served anyway.
+func (s *Server) recordView(archiveID, user string) {
+	now := time.Now()
+	var last models.ViewEvent
+	err := s.DB.Where("archive_id = ? AND username = ?", archiveID, user).Order("viewed_at desc").First(&last).Error
+	switch {
+	case err == nil && now.Sub(last.ViewedAt) < viewSession:
+		err = s.DB.Model(&models.ViewEvent{}).Where("id = ?", last.ID).UpdateColumn("viewed_at", now).Error
+	case err == nil || errors.Is(err, gorm.ErrRecordNotFound):
+		err = s.DB.Create(&models.ViewEvent{ID: uuid.New().String(), ArchiveID: archiveID, Username: user, ViewedAt: now}).Error
+	}
+	if err != nil {
+		log.Printf("record view of %s failed: %v", archiveID, err)
+	}
+}
+
+// listRecentViews returns the archives the current user opened most
+// recently, one entry per archive, for a "jump back in" list.
+func (s *Server) listRecentViews(c *gin.Context) {
+	limit := parseLimit(c.Query("limit"), 20)
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	var rows []struct {
+		ArchiveID string
+		ViewedAt  time.Time
+		Views     int64
+	}
+	if err := s.reader().Model(&models.ViewEvent{}).
+		Select("archive_id, MAX(viewed_at) AS viewed_at, COUNT(*) AS views").
+		Where("username = ?", currentPrincipal(c).Username).
+		Group("archive_id").Order("viewed_at desc").Limit(limit).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ArchiveID)
+	}
+	var items []models.Archive
+	if len(ids) > 0 {
+		if err := s.reader().Omit("content_text").Where("id IN ?", ids).Find(&items).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+	}
+	byID := make(map[string]models.Archive, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+	out := make([]RecentView, 0, len(rows))
+	for _, row := range rows {
+		item, ok := byID[row.ArchiveID]
+		if !ok {
+			continue
+		}
+		out = append(out, RecentView{Archive: toArchiveResponse(item, nil), ViewedAt: row.ViewedAt, Views: row.Views})
+	}
+	c.JSON(http.StatusOK, out)
+}
@@ -0,0 +1,504 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"webarchive/internal/auth"
+	"webarchive/internal/models"
+)
+
+// The Wallabag compatibility layer lives under /wallabag so mobile apps and
+// integrations can be pointed at https://host/wallabag. Wallabag's
+// "archived" flag maps to read (view count > 0); starring is not supported.
+
+const (
+	wallabagVersion    = "2.5.0"
+	wallabagTimeLayout = "2006-01-02T15:04:05-0700"
+	wallabagTokenName  = "wallabag"
+)
+
+type wallabagTag struct {
+	ID    uint32 `json:"id"`
+	Label string `json:"label"`
+	Slug  string `json:"slug"`
+}
+
+type wallabagEntry struct {
+	ID             uint          `json:"id"`
+	URL            string        `json:"url"`
+	GivenURL       string        `json:"given_url"`
+	Title          string        `json:"title"`
+	Content        string        `json:"content"`
+	IsArchived     int           `json:"is_archived"`
+	IsStarred      int           `json:"is_starred"`
+	Tags           []wallabagTag `json:"tags"`
+	ReadingTime    int           `json:"reading_time"`
+	DomainName     string        `json:"domain_name"`
+	PreviewPicture *string       `json:"preview_picture"`
+	Mimetype       string        `json:"mimetype"`
+	Language       *string       `json:"language"`
+	CreatedAt      string        `json:"created_at"`
+	UpdatedAt      string        `json:"updated_at"`
+	ArchivedAt     *string       `json:"archived_at"`
+	UserName       string        `json:"user_name"`
+}
+
+type wallabagEntryRequest struct {
+	URL     string `form:"url" json:"url"`
+	Title   string `form:"title" json:"title"`
+	Tags    string `form:"tags" json:"tags"`
+	Content string `form:"content" json:"content"`
+	// Archive is 0 or 1; a pointer keeps "not sent" apart from 0.
+	Archive *int `form:"archive" json:"archive"`
+}
+
+func (s *Server) registerWallabagRoutes(r *gin.Engine) {
+	wb := r.Group("/wallabag", s.requireReady())
+	wb.POST("/oauth/v2/token", s.wallabagToken)
+
+	api := wb.Group("/api", s.authenticate())
+	api.GET("/version", s.wallabagVersion)
+	api.GET("/version.json", s.wallabagVersion)
+	api.GET("/info", s.wallabagInfo)
+	api.GET("/info.json", s.wallabagInfo)
+
+	read := api.Group("", s.requireRole(auth.RoleViewer), s.requireScope(auth.ScopeRead))
+	read.GET("/entries.json", s.wallabagListEntries)
+	read.GET("/entries/exists.json", s.wallabagEntryExists)
+	read.GET("/entries/:entry", s.wallabagGetEntry)
+
+	// Adding an entry is a capture; changing or deleting one needs write,
+	// as PATCH and DELETE /api/archives/:id do.
+	capture := api.Group("", s.requireRole(auth.RoleEditor), s.requireScope(auth.ScopeCapture, auth.ScopeWrite), s.limitPayload())
+	capture.POST("/entries.json", s.wallabagAddEntry)
+
+	write := api.Group("", s.requireRole(auth.RoleEditor), s.requireScope(auth.ScopeWrite))
+	write.PATCH("/entries/:entry", s.wallabagUpdateEntry)
+	write.DELETE("/entries/:entry", s.wallabagDeleteEntry)
+}
+
+// wallabagToken implements the OAuth2 password and refresh_token grants.
+// client_id and client_secret are accepted but not checked; the refresh
+// token is the access token itself, valid until it expires.
+func (s *Server) wallabagToken(c *gin.Context) {
+	grant := c.PostForm("grant_type")
+	var user models.User
+	var oldTokenID string
+	switch {
+	case !s.AuthEnabled:
+		c.JSON(http.StatusOK, gin.H{
+			"access_token": "local", "refresh_token": "local", "token_type": "bearer",
+			"expires_in": int(sessionTTL.Seconds()), "scope": nil,
+		})
+		return
+	case grant == "password":
+		err := s.DB.First(&user, "username = ?", strings.TrimSpace(c.PostForm("username"))).Error
+		if err != nil || !auth.CheckPassword(user.PasswordHash, c.PostForm("password")) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": "Invalid username and password combination"})
+			return
+		}
+	case grant == "refresh_token":
+		var row models.APIToken
+		err := s.DB.First(&row, "token_hash = ? AND name = ?", auth.HashToken(c.PostForm("refresh_token")), wallabagTokenName).Error
+		if err != nil || (row.ExpiresAt != nil && row.ExpiresAt.Before(time.Now())) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": "Invalid refresh token"})
+			return
+		}
+		if err := s.DB.First(&user, "id = ?", row.UserID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid_grant", "error_description": "Invalid refresh token"})
+			return
+		}
+		oldTokenID = row.ID
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported_grant_type"})
+		return
+	}
+
+	expires := time.Now().Add(sessionTTL)
+	scopes := []string{auth.ScopeRead, auth.ScopeCapture, auth.ScopeWrite}
+	token, _, err := s.issueToken(user.ID, wallabagTokenName, scopes, &expires)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server_error"})
+		return
+	}
+	if oldTokenID != "" {
+		_ = s.DB.Delete(&models.APIToken{}, "id = ?", oldTokenID).Error
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token, "refresh_token": token, "token_type": "bearer",
+		"expires_in": int(sessionTTL.Seconds()), "scope": nil,
+	})
+}
+
+func (s *Server) wallabagVersion(c *gin.Context) {
+	c.JSON(http.StatusOK, wallabagVersion)
+}
+
+func (s *Server) wallabagInfo(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"appname": "wallabag", "version": wallabagVersion, "allowed_registration": false})
+}
+
+func (s *Server) wallabagListEntries(c *gin.Context) {
+	page := parseLimit(c.Query("page"), 1)
+	if page < 1 {
+		page = 1
+	}
+	perPage := parseLimit(c.Query("perPage"), 30)
+	if perPage < 1 || perPage > 500 {
+		perPage = 30
+	}
+
+	db := s.DB.Model(&models.Archive{})
+	switch c.Query("archive") {
+	case "1":
+		db = db.Where("view_count > 0")
+	case "0":
+		db = db.Where("view_count = 0")
+	}
+	if c.Query("starred") == "1" {
+		db = db.Where("1 = 0")
+	}
+	for _, tag := range splitList(c.Query("tags")) {
+		db = db.Where("JSON_CONTAINS(tags_json, JSON_QUOTE(?))", tag)
+	}
+	if since, err := strconv.ParseInt(c.Query("since"), 10, 64); err == nil && since > 0 {
+		db = db.Where("updated_at >= ?", time.Unix(since, 0))
+	}
+	if domain := strings.TrimSpace(c.Query("domain_name")); domain != "" {
+		db = db.Where("domain = ?", domain)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	column := "created_at"
+	if c.Query("sort") == "updated" {
+		column = "updated_at"
+	}
+	desc := c.Query("order") != "asc"
+	var items []models.Archive
+	err := db.Order(clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc}).
+		Offset((page - 1) * perPage).Limit(perPage).Find(&items).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	entries, err := s.wallabagEntries(items, currentPrincipal(c).Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	pages := int((total + int64(perPage) - 1) / int64(perPage))
+	if pages == 0 {
+		pages = 1
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"page":      page,
+		"limit":     perPage,
+		"pages":     pages,
+		"total":     total,
+		"_embedded": gin.H{"items": entries},
+	})
+}
+
+func (s *Server) wallabagEntryExists(c *gin.Context) {
+	var item models.Archive
+	err := s.DB.Select("id").Where("url = ?", c.Query("url")).Limit(1).Find(&item).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if item.ID == "" {
+		c.JSON(http.StatusOK, gin.H{"exists": false})
+		return
+	}
+	if c.Query("return_id") == "1" {
+		ids, err := s.compatIDs([]string{item.ID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"exists": ids[item.ID]})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"exists": true})
+}
+
+func (s *Server) wallabagGetEntry(c *gin.Context) {
+	item, ok := s.wallabagArchive(c)
+	if !ok {
+		return
+	}
+	s.respondWallabagEntry(c, item)
+}
+
+// wallabagAddEntry saves a URL like POST /api/archives with server-side
+// fetching. A URL that is already archived returns the existing entry, as
+// Wallabag does.
+func (s *Server) wallabagAddEntry(c *gin.Context) {
+	var req wallabagEntryRequest
+	if err := c.ShouldBind(&req); err != nil || strings.TrimSpace(req.URL) == "" {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "payload too large"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url required"})
+		return
+	}
+	var existing models.Archive
+	if err := s.DB.Where("url = ?", req.URL).Order("created_at desc").Limit(1).Find(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if existing.ID != "" {
+		s.respondWallabagEntry(c, existing)
+		return
+	}
+
+	capture := CreateArchiveRequest{
+		URL:    strings.TrimSpace(req.URL),
+		Title:  strings.TrimSpace(req.Title),
+		Tags:   splitList(req.Tags),
+		HTML:   req.Content,
+		Source: "wallabag",
+	}
+	item, err := s.captureArchive(c.Request.Context(), capture, captureMetaFrom(c))
+	var capErr *captureError
+	if errors.As(err, &capErr) {
+		c.JSON(capErr.status, gin.H{"error": capErr.msg})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "capture failed"})
+		return
+	}
+	if req.Archive != nil && *req.Archive == 1 {
+		_ = s.setWallabagArchived(item.ID, true)
+		_ = s.DB.First(&item, "id = ?", item.ID).Error
+	}
+	s.respondWallabagEntry(c, item)
+}
+
+func (s *Server) wallabagUpdateEntry(c *gin.Context) {
+	item, ok := s.wallabagArchive(c)
+	if !ok {
+		return
+	}
+	var req wallabagEntryRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid payload"})
+		return
+	}
+	before := s.snapshotArchive(item)
+	updates := map[string]any{}
+	if title := strings.TrimSpace(req.Title); title != "" {
+		updates["title"] = truncate(title, 500)
+	}
+	if req.Tags != "" {
+		tags := []string{}
+		if len(item.TagsJSON) > 0 {
+			_ = json.Unmarshal(item.TagsJSON, &tags)
+		}
+		// Wallabag's PATCH adds tags; removal has its own endpoint.
+		tagsJSON, _ := json.Marshal(patchTags(tags, splitList(req.Tags), nil))
+		updates["tags_json"] = tagsJSON
+	}
+	if len(updates) > 0 {
+		if err := s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+			return
+		}
+	}
+	if req.Archive != nil {
+		if err := s.setWallabagArchived(item.ID, *req.Archive == 1); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "db update failed"})
+			return
+		}
+	}
+	if err := s.DB.First(&item, "id = ?", item.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if len(updates) > 0 {
+		s.recordArchiveEvent(item.ID, EventEdit, currentPrincipal(c).Username, before, s.snapshotArchive(item))
+	}
+	s.respondWallabagEntry(c, item)
+}
+
+func (s *Server) wallabagDeleteEntry(c *gin.Context) {
+	item, ok := s.wallabagArchive(c)
+	if !ok {
+		return
+	}
+	entry, err := s.wallabagEntries([]models.Archive{item}, currentPrincipal(c).Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	if err := s.removeArchive(c.Request.Context(), item, currentPrincipal(c).Username); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db delete failed"})
+		return
+	}
+	c.JSON(http.StatusOK, entry[0])
+}
+
+// setWallabagArchived maps Wallabag's archive flag onto the read counter:
+// archiving marks an unread archive read once, unarchiving resets it.
+func (s *Server) setWallabagArchived(archiveID string, archived bool) error {
+	if !archived {
+		return s.DB.Model(&models.Archive{}).Where("id = ?", archiveID).
+			UpdateColumns(map[string]any{"view_count": 0, "last_viewed_at": nil}).Error
+	}
+	return s.DB.Model(&models.Archive{}).Where("id = ? AND view_count = 0", archiveID).
+		UpdateColumns(map[string]any{"view_count": 1, "last_viewed_at": time.Now()}).Error
+}
+
+// wallabagArchive resolves the :entry parameter ("123" or "123.json").
+func (s *Server) wallabagArchive(c *gin.Context) (models.Archive, bool) {
+	var item models.Archive
+	seq, err := strconv.ParseUint(strings.TrimSuffix(c.Param("entry"), ".json"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return item, false
+	}
+	var row models.CompatID
+	if err := s.DB.First(&row, "seq = ?", seq).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return item, false
+	}
+	if err := s.DB.First(&item, "id = ?", row.ArchiveID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+			return item, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return item, false
+	}
+	return item, true
+}
+
+func (s *Server) respondWallabagEntry(c *gin.Context, item models.Archive) {
+	entries, err := s.wallabagEntries([]models.Archive{item}, currentPrincipal(c).Username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, entries[0])
+}
+
+func (s *Server) wallabagEntries(items []models.Archive, username string) ([]wallabagEntry, error) {
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	seqs, err := s.compatIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]wallabagEntry, 0, len(items))
+	for _, item := range items {
+		entry := wallabagEntry{
+			ID:          seqs[item.ID],
+			URL:         item.URL,
+			GivenURL:    item.URL,
+			Title:       item.Title,
+			Content:     textToHTML(item.ContentText),
+			Tags:        []wallabagTag{},
+			ReadingTime: item.ReadMinutes,
+			DomainName:  item.Domain,
+			Mimetype:    "text/html",
+			CreatedAt:   item.CreatedAt.Format(wallabagTimeLayout),
+			UpdatedAt:   item.UpdatedAt.Format(wallabagTimeLayout),
+			UserName:    username,
+		}
+		if item.ViewCount > 0 {
+			entry.IsArchived = 1
+			if item.LastViewedAt != nil {
+				at := item.LastViewedAt.Format(wallabagTimeLayout)
+				entry.ArchivedAt = &at
+			}
+		}
+		tags := []string{}
+		if len(item.TagsJSON) > 0 {
+			_ = json.Unmarshal(item.TagsJSON, &tags)
+		}
+		for _, tag := range tags {
+			entry.Tags = append(entry.Tags, wallabagTag{
+				ID:    crc32.ChecksumIEEE([]byte(tag)),
+				Label: tag,
+				Slug:  strings.Join(strings.Fields(strings.ToLower(tag)), "-"),
+			})
+		}
+		out = append(out, entry)
+	}
+	return out, nil
+}
+
+// compatIDs returns the integer ID of each archive, assigning new ones as
+// needed.
+func (s *Server) compatIDs(archiveIDs []string) (map[string]uint, error) {
+	out := map[string]uint{}
+	if len(archiveIDs) == 0 {
+		return out, nil
+	}
+	var rows []models.CompatID
+	if err := s.DB.Where("archive_id IN ?", archiveIDs).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		out[row.ArchiveID] = row.Seq
+	}
+	missing := []models.CompatID{}
+	for _, id := range archiveIDs {
+		if _, ok := out[id]; !ok {
+			missing = append(missing, models.CompatID{ArchiveID: id})
+		}
+	}
+	if len(missing) == 0 {
+		return out, nil
+	}
+	// A concurrent request may assign the same archive; keep its row.
+	if err := s.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&missing).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(missing))
+	for _, row := range missing {
+		ids = append(ids, row.ArchiveID)
+	}
+	rows = nil
+	if err := s.DB.Where("archive_id IN ?", ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		out[row.ArchiveID] = row.Seq
+	}
+	return out, nil
+}
+
+// textToHTML wraps extracted text in paragraphs, which is what Wallabag
+// clients expect in "content".
+func textToHTML(text string) string {
+	var b strings.Builder
+	for _, para := range strings.Split(text, "\n") {
+		if para = strings.TrimSpace(para); para != "" {
+			b.WriteString("<p>")
+			b.WriteString(html.EscapeString(para))
+			b.WriteString("</p>")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,313 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/notify"
+	"webarchive/internal/processor"
+	"webarchive/internal/textutil"
+)
+
+const (
+	watchBatchSize   = 50
+	watchSampleLines = 50
+)
+
+type WatchStatus struct {
+	Running    bool       `json:"running"`
+	Watched    int64      `json:"watched"`
+	Checked    int        `json:"checked"`
+	Changed    int        `json:"changed"`
+	Failed     int        `json:"failed"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	LastError  string     `json:"lastError,omitempty"`
+}
+
+type PageChangeResponse struct {
+	ID            string    `json:"id"`
+	ChangePercent float64   `json:"changePercent"`
+	AddedLines    int       `json:"addedLines"`
+	RemovedLines  int       `json:"removedLines"`
+	Added         []string  `json:"added"`
+	Removed       []string  `json:"removed"`
+	DetectedAt    time.Time `json:"detectedAt"`
+}
+
+type PageChangesResponse struct {
+	ArchiveID     string               `json:"archiveId"`
+	Watched       bool                 `json:"watched"`
+	LastCheckedAt *time.Time           `json:"lastCheckedAt"`
+	Changes       []PageChangeResponse `json:"changes"`
+}
+
+func watchedArchives(db *gorm.DB) *gorm.DB {
+	return db.Model(&models.Archive{}).Where("watched = ?", true)
+}
+
+// getArchiveChanges lists the changes detected on a watched archive's live
+// page, newest first.
+func (s *Server) getArchiveChanges(c *gin.Context) {
+	var item models.Archive
+	if err := s.DB.Select("id", "watched", "watch_checked_at").First(&item, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	var changes []models.PageChange
+	if err := s.reader().Omit("content_text").Where("archive_id = ?", item.ID).
+		Order("detected_at desc").Limit(parseLimit(c.Query("limit"), 50)).Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	resp := PageChangesResponse{ArchiveID: item.ID, Watched: item.Watched, LastCheckedAt: item.WatchCheckedAt, Changes: make([]PageChangeResponse, 0, len(changes))}
+	for _, ch := range changes {
+		resp.Changes = append(resp.Changes, PageChangeResponse{
+			ID:            ch.ID,
+			ChangePercent: ch.ChangePercent,
+			AddedLines:    ch.AddedLines,
+			RemovedLines:  ch.RemovedLines,
+			Added:         jsonStrings(ch.AddedJSON),
+			Removed:       jsonStrings(ch.RemovedJSON),
+			DetectedAt:    ch.DetectedAt,
+		})
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) watchJobStatus(c *gin.Context) {
+	status := s.getWatchStatus()
+	if err := watchedArchives(s.DB).Count(&status.Watched).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "db query failed"})
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+func (s *Server) runWatchNow(c *gin.Context) {
+	s.startWatch()
+	c.JSON(http.StatusOK, s.getWatchStatus())
+}
+
+func (s *Server) stopWatch(c *gin.Context) {
+	s.watchMu.Lock()
+	if s.watchCancel != nil {
+		s.watchCancel()
+		s.watchCancel = nil
+	}
+	s.watchMu.Unlock()
+	c.JSON(http.StatusOK, s.getWatchStatus())
+}
+
+func (s *Server) getWatchStatus() WatchStatus {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	return s.watchStatus
+}
+
+func (s *Server) startWatch() {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	if s.watchStatus.Running {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	s.watchCancel = cancel
+	s.watchStatus = WatchStatus{Running: true, StartedAt: &now}
+	go s.runWatch(ctx)
+}
+
+func (s *Server) runWatch(ctx context.Context) {
+	lastErr := ""
+	defer func() {
+		now := time.Now()
+		s.watchMu.Lock()
+		s.watchStatus.Running = false
+		s.watchStatus.FinishedAt = &now
+		s.watchStatus.LastError = lastErr
+		s.watchCancel = nil
+		s.watchMu.Unlock()
+		if lastErr != "" {
+			log.Printf("page watch: %s", lastErr)
+		}
+	}()
+
+	lastID := ""
+	for {
+		var items []models.Archive
+		if err := watchedArchives(s.DB).Omit("content_text").
+			Where("id > ?", lastID).Order("id asc").Limit(watchBatchSize).Find(&items).Error; err != nil {
+			lastErr = err.Error()
+			return
+		}
+		if len(items) == 0 {
+			return
+		}
+		for _, item := range items {
+			if ctx.Err() != nil {
+				lastErr = "canceled"
+				return
+			}
+			changed, err := s.checkWatchedPage(ctx, item)
+			if err != nil {
+				log.Printf("page watch failed for %s: %v", item.ID, err)
+			}
+			s.watchMu.Lock()
+			s.watchStatus.Checked++
+			if err != nil {
+				s.watchStatus.Failed++
+			} else if changed {
+				s.watchStatus.Changed++
+			}
+			s.watchMu.Unlock()
+		}
+		lastID = items[len(items)-1].ID
+	}
+}
+
+// checkWatchedPage fetches the live page and diffs its text against the
+// last recorded change, or the archived copy before the first one. Changes
+// under WatchMinChangePercent only move the check time, so small edits add
+// up against the same baseline until they are worth reporting.
+func (s *Server) checkWatchedPage(ctx context.Context, item models.Archive) (bool, error) {
+	pageURL := item.FinalURL
+	if pageURL == "" {
+		pageURL = item.URL
+	}
+	fetchCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	page, err := s.Processor.FetchPage(fetchCtx, pageURL, processor.Options{HostRules: s.userHostRules(item.CapturedBy)})
+	cancel()
+	if err != nil {
+		return false, err
+	}
+	_, live := processor.ExtractText(page.HTML)
+	if strings.TrimSpace(live) == "" {
+		return false, errors.New("live page has no text")
+	}
+	baseline, err := s.watchBaseline(ctx, item)
+	if err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	markChecked := func() error {
+		return s.DB.Model(&models.Archive{}).Where("id = ?", item.ID).UpdateColumn("watch_checked_at", now).Error
+	}
+	added, removed, percent := textutil.LineDiff(baseline, live)
+	if len(added)+len(removed) == 0 || percent < s.WatchMinChangePercent {
+		return false, markChecked()
+	}
+	addedJSON, _ := json.Marshal(sampleLines(added))
+	removedJSON, _ := json.Marshal(sampleLines(removed))
+	change := models.PageChange{
+		ID:            uuid.New().String(),
+		ArchiveID:     item.ID,
+		ChangePercent: percent,
+		AddedLines:    len(added),
+		RemovedLines:  len(removed),
+		AddedJSON:     addedJSON,
+		RemovedJSON:   removedJSON,
+		ContentText:   live,
+		DetectedAt:    now,
+	}
+	if err := s.DB.Create(&change).Error; err != nil {
+		return false, err
+	}
+	if err := markChecked(); err != nil {
+		return true, err
+	}
+	s.publishEvent(LiveArchiveUpdated, gin.H{"id": item.ID, "action": "watch", "actor": "page-watch"})
+	if s.WatchWebhookURL != "" {
+		payload := gin.H{
+			"type":          "page.changed",
+			"archiveId":     item.ID,
+			"title":         item.Title,
+			"url":           pageURL,
+			"changePercent": percent,
+			"addedLines":    len(added),
+			"removedLines":  len(removed),
+			"added":         sampleLines(added),
+			"removed":       sampleLines(removed),
+			"detectedAt":    now,
+		}
+		if err := notify.Webhook(ctx, s.WatchWebhookURL, payload); err != nil {
+			log.Printf("watch webhook failed for %s: %v", item.ID, err)
+		}
+	}
+	return true, nil
+}
+
+// watchBaseline returns the text the live page is compared against. The
+// archived page is re-extracted the same way as the live one, since the
+// stored content text may come from the extension's reader view.
+func (s *Server) watchBaseline(ctx context.Context, item models.Archive) (string, error) {
+	var last models.PageChange
+	err := s.DB.Where("archive_id = ?", item.ID).Order("detected_at desc").First(&last).Error
+	if err == nil {
+		return last.ContentText, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+	return s.snapshotText(ctx, item)
+}
+
+// snapshotText re-extracts the archived page's text, falling back to the
+// stored content text when there is no snapshot to extract from.
+func (s *Server) snapshotText(ctx context.Context, item models.Archive) (string, error) {
+	if stored := s.storedPageHTML(ctx, item); len(stored) > 0 {
+		if _, text := processor.ExtractText(stored); strings.TrimSpace(text) != "" {
+			return text, nil
+		}
+	}
+	var full models.Archive
+	if err := s.DB.Select("content_text").First(&full, "id = ?", item.ID).Error; err != nil {
+		return "", err
+	}
+	return full.ContentText, nil
+}
+
+func sampleLines(lines []string) []string {
+	if lines == nil {
+		return []string{}
+	}
+	if len(lines) > watchSampleLines {
+		lines = lines[:watchSampleLines]
+	}
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = truncate(line, 500)
+	}
+	return out
+}
+
+// StartWatchScheduler checks watched pages every interval; 0 disables it,
+// leaving only manual runs.
+func (s *Server) StartWatchScheduler(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			s.startWatch()
+		}
+	}()
+}
@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	RoleViewer = "viewer"
+	RoleEditor = "editor"
+	RoleAdmin  = "admin"
+)
+
+// Token scopes narrow what a credential may do on top of the owner's role,
+// so e.g. the extension can hold a capture-only token. ScopeAdmin grants
+// everything the role allows.
+const (
+	ScopeRead    = "read"
+	ScopeCapture = "capture"
+	ScopeWrite   = "write"
+	ScopeAdmin   = "admin"
+)
+
+var roleRank = map[string]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+func ValidRole(role string) bool {
+	_, ok := roleRank[role]
+	return ok
+}
+
+// RoleAllows reports whether a user with role may call a route that needs
+// required. Roles are strictly ordered: admin > editor > viewer.
+func RoleAllows(role, required string) bool {
+	return roleRank[role] > 0 && roleRank[role] >= roleRank[required]
+}
+
+func ValidScope(scope string) bool {
+	switch scope {
+	case ScopeRead, ScopeCapture, ScopeWrite, ScopeAdmin:
+		return true
+	}
+	return false
+}
+
+// ScopeAllows reports whether granted contains any of the accepted scopes.
+func ScopeAllows(granted []string, accepted ...string) bool {
+	for _, g := range granted {
+		if g == ScopeAdmin {
+			return true
+		}
+		for _, a := range accepted {
+			if g == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func ParseScopes(s string) []string {
+	out := []string{}
+	for _, part := range strings.Split(s, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func CheckPassword(hash, password string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+// NewToken returns a random bearer token and the hash to persist. Only the
+// hash is stored, so a leaked database does not leak usable tokens.
+func NewToken() (token string, hash string, err error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = "wa_" + hex.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// pairingAlphabet leaves out characters that are easy to misread (0/O, 1/I).
+const pairingAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// NewPairingCode returns an 8 character code formatted as XXXX-XXXX.
+func NewPairingCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	out := make([]byte, 0, 9)
+	for i, b := range buf {
+		if i == 4 {
+			out = append(out, '-')
+		}
+		out = append(out, pairingAlphabet[int(b)%len(pairingAlphabet)])
+	}
+	return string(out), nil
+}
+
+// NormalizePairingCode uppercases a typed code and drops separators, so
+// "abcd 1234" and "ABCD-1234" hash the same.
+func NormalizePairingCode(code string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(code) {
+		if strings.ContainsRune(pairingAlphabet, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+)
+
+// ErrMiss is returned by backends for keys that are absent or expired.
+var ErrMiss = errors.New("cache miss")
+
+// Namespaces group entries that are invalidated together.
+const (
+	Taxonomy     = "taxonomy"
+	Graph        = "graph"
+	ClientConfig = "client-config"
+	Search       = "search"
+)
+
+type Backend interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Incr atomically increments a counter that never expires.
+	Incr(ctx context.Context, key string) (int64, error)
+}
+
+// Cache stores values per namespace. Invalidating a namespace bumps its
+// generation counter, which is part of every key, so stale entries are never
+// read again and simply age out. A nil *Cache caches nothing.
+type Cache struct {
+	backend Backend
+	ttl     time.Duration
+	prefix  string
+}
+
+func New(backend Backend, ttl time.Duration) *Cache {
+	return &Cache{backend: backend, ttl: ttl, prefix: "webarchive:"}
+}
+
+func (c *Cache) Get(ctx context.Context, ns, key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	value, err := c.backend.Get(ctx, c.key(ctx, ns, key))
+	if err != nil {
+		return nil, false
+	}
+	return value, true
+}
+
+func (c *Cache) Set(ctx context.Context, ns, key string, value []byte) {
+	if c == nil {
+		return
+	}
+	_ = c.backend.Set(ctx, c.key(ctx, ns, key), value, c.ttl)
+}
+
+func (c *Cache) Invalidate(ctx context.Context, namespaces ...string) {
+	if c == nil {
+		return
+	}
+	for _, ns := range namespaces {
+		_, _ = c.backend.Incr(ctx, c.prefix+"gen:"+ns)
+	}
+}
+
+func (c *Cache) key(ctx context.Context, ns, key string) string {
+	gen := "0"
+	if raw, err := c.backend.Get(ctx, c.prefix+"gen:"+ns); err == nil {
+		gen = string(raw)
+	}
+	return c.prefix + ns + ":" + gen + ":" + key
+}
+
+func formatInt(n int64) []byte {
+	return []byte(strconv.FormatInt(n, 10))
+}
@@ -0,0 +1,126 @@
+package cluster
+
+import (
+	"math"
+	"math/rand"
+)
+
+// KMeans groups vectors into k clusters by cosine similarity (spherical
+// k-means) and returns the cluster index of every vector. Seeding is
+// k-means++ with a fixed seed so repeated runs over the same data agree.
+func KMeans(vectors [][]float32, k, iterations int) []int {
+	n := len(vectors)
+	assign := make([]int, n)
+	if n == 0 || k <= 1 {
+		return assign
+	}
+	if k > n {
+		k = n
+	}
+	points := make([][]float64, n)
+	for i, v := range vectors {
+		points[i] = normalize(v)
+	}
+
+	rng := rand.New(rand.NewSource(42))
+	centroids := [][]float64{points[rng.Intn(n)]}
+	dist := make([]float64, n)
+	for len(centroids) < k {
+		total := 0.0
+		for i, p := range points {
+			d := 1 - dot(p, centroids[len(centroids)-1])
+			if len(centroids) == 1 || d < dist[i] {
+				dist[i] = d
+			}
+			total += dist[i]
+		}
+		if total == 0 {
+			break
+		}
+		target := rng.Float64() * total
+		next := n - 1
+		for i, d := range dist {
+			target -= d
+			if target <= 0 {
+				next = i
+				break
+			}
+		}
+		centroids = append(centroids, points[next])
+	}
+
+	for iter := 0; iter < iterations; iter++ {
+		changed := false
+		for i, p := range points {
+			best, bestSim := 0, math.Inf(-1)
+			for c, centroid := range centroids {
+				if sim := dot(p, centroid); sim > bestSim {
+					best, bestSim = c, sim
+				}
+			}
+			if assign[i] != best {
+				assign[i] = best
+				changed = true
+			}
+		}
+		if !changed && iter > 0 {
+			break
+		}
+		dims := len(points[0])
+		sums := make([][]float64, len(centroids))
+		for c := range sums {
+			sums[c] = make([]float64, dims)
+		}
+		for i, p := range points {
+			for d, x := range p {
+				sums[assign[i]][d] += x
+			}
+		}
+		for c, sum := range sums {
+			if norm := math.Sqrt(dot(sum, sum)); norm > 0 {
+				for d := range sum {
+					sum[d] /= norm
+				}
+				centroids[c] = sum
+			}
+		}
+	}
+	return assign
+}
+
+// DefaultK is the usual sqrt(n/2) rule of thumb, clamped to [2, 50].
+func DefaultK(n int) int {
+	k := int(math.Sqrt(float64(n) / 2))
+	if k < 2 {
+		k = 2
+	}
+	if k > 50 {
+		k = 50
+	}
+	return k
+}
+
+func normalize(v []float32) []float64 {
+	out := make([]float64, len(v))
+	var norm float64
+	for i, x := range v {
+		out[i] = float64(x)
+		norm += out[i] * out[i]
+	}
+	if norm = math.Sqrt(norm); norm > 0 {
+		for i := range out {
+			out[i] /= norm
+		}
+	}
+	return out
+}
+
+func dot(a, b []float64) float64 {
+	var s float64
+	for i := range a {
+		if i < len(b) {
+			s += a[i] * b[i]
+		}
+	}
+	return s
+}
@@ -1,74 +1,521 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"webarchive/internal/extractor"
+	"webarchive/internal/processor"
+	"webarchive/internal/proxy"
+	"webarchive/internal/storage"
 )
 
 type Config struct {
-	Addr             string
-	BaseURL          string
-	MySQLDSN         string
-	MinIOEndpoint    string
-	MinIOAccessKey   string
-	MinIOSecretKey   string
-	MinIOSecure      bool
-	MinIOBucket      string
-	HTTPTimeout      time.Duration
-	LLMBaseURL       string
-	LLMAPIKey        string
-	LLMModel         string
-	LLMTimeout       time.Duration
-	LLMEnabled       bool
-	AutoTagOnCapture bool
-	EinoEnabled      bool
+	Addr              string
+	BaseURL           string
+	MySQLDSN          string
+	MySQLReadDSN      string
+	MySQLMaxOpen      int
+	MySQLMaxIdle      int
+	MySQLMaxLifetime  time.Duration
+	MinIOEndpoint     string
+	MinIOAccessKey    string
+	MinIOSecretKey    string
+	MinIOSecure       bool
+	MinIOBucket       string
+	ColdEndpoint      string
+	ColdAccessKey     string
+	ColdSecretKey     string
+	ColdSecure        bool
+	ColdBucket        string
+	TieringMonths     int
+	TieringEvery      time.Duration
+	MetaRefreshEvery  time.Duration
+	TitleCleanup      bool
+	TitleCleanupLLM   bool
+	PriceTrackEvery   time.Duration
+	PriceWebhookURL   string
+	WatchEvery        time.Duration
+	WatchMinChange    int
+	WatchWebhookURL   string
+	RemindEvery       time.Duration
+	RemindWebhookURL  string
+	NotifyWebhookURL  string
+	NotifyEmailTo     string
+	RecaptureEvery    time.Duration
+	RecaptureKeep     int
+	QuotaBytes        int
+	UserQuotaBytes    int
+	HTTPTimeout       time.Duration
+	MaxAssetBytes     int
+	MaxPayloadBytes   int
+	AssetMemoryBytes  int
+	ParseMaxBytes     int
+	ParseMaxNodes     int
+	ParseMaxDepth     int
+	ParseTimeout      time.Duration
+	LLMBaseURL        string
+	LLMAPIKey         string
+	LLMModel          string
+	LLMTimeout        time.Duration
+	LLMEnabled        bool
+	LLMEmbedModel     string
+	LLMFallbackURL    string
+	LLMFallbackKey    string
+	LLMFallbackModel  string
+	LLMBreakerFails   int
+	LLMBreakerReset   time.Duration
+	LLMConcurrency    int
+	LLMTrace          bool
+	LLMTraceDays      int
+	AutoTagOnCapture  bool
+	AutoTagWorkers    int
+	AutoTagQueueSize  int
+	AutoTagRetries    int
+	AutoTagBackend    string
+	EinoEnabled       bool
+	StartupRetries    int
+	SettingsKey       string
+	ArchiveKey        string
+	VectorStore       string
+	CacheBackend      string
+	CacheTTL          time.Duration
+	CacheMaxEntries   int
+	RedisAddr         string
+	RedisPassword     string
+	RedisDB           int
+	QdrantURL         string
+	QdrantAPIKey      string
+	QdrantPrefix      string
+	SearchIndex       string
+	SearchNgram       bool
+	MeiliURL          string
+	MeiliAPIKey       string
+	MeiliIndex        string
+	DigestSchedule    string
+	DigestWebhookURL  string
+	DigestEmailTo     string
+	SMTPAddr          string
+	SMTPUsername      string
+	SMTPPassword      string
+	SMTPFrom          string
+	DeviceEmailTo     string
+	AuthEnabled       bool
+	PublicEnabled     bool
+	ViewerOrigin      string
+	ViewerSecret      string
+	ArchiveIDFormat   string
+	AdminUsername     string
+	AdminPassword     string
+	RetentionEvery    time.Duration
+	TaxonomyRouter    string
+	TaxonomyDepth     int
+	TaxonomyOptions   int
+	TaxonomyPathLen   int
+	TaxonomyLabelLen  int
+	TaxonomyWords     int
+	TaxonomyBanned    string
+	TaxonomyCase      string
+	TaxonomyMerge     bool
+	ResurfaceEvery    time.Duration
+	ConsistencyEvery  time.Duration
+	ConsistencyRepair bool
+	SnapshotEvery     time.Duration
+	SnapshotKeep      time.Duration
+	FetchUserAgent    string
+	FetchRobots       bool
+	FetchHostDelay    time.Duration
+	FetchConcurrency  int
+	FetchProxy        string
+	FetchProxyRules   string
+	BlockTrackers     bool
+	BlocklistFile     string
+	FetchAllowHosts   string
+	FetchDenyHosts    string
+	ExtractorBuiltin  bool
+	ExtractorURLs     string
+	PDFTextCommand    string
+	PDFThumbCommand   string
+	ScrubRules        string
+	ScrubPatternFile  string
+	ScrubTerms        string
+	LLMProxy          string
+	LLMProxyRules     string
+
+	// Entries records every resolved key with its origin, for --print-config.
+	Entries []Entry
+}
+
+type Entry struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// LoadFile merges defaults, an optional YAML/TOML file and environment
+// variables (highest precedence) and validates the result. Validation errors
+// are reported together so a bad deployment can be fixed in one pass.
+func LoadFile(path string) (Config, error) {
+	l := &loader{file: map[string]string{}, used: map[string]bool{}}
+	if path != "" {
+		values, err := readFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		l.file = values
+		l.path = path
+	}
+
+	cfg := Config{
+		Addr:              l.str("ADDR", ":8080"),
+		BaseURL:           l.str("BASE_URL", "http://localhost:8080"),
+		MySQLDSN:          l.str("MYSQL_DSN", "webarchive:webarchive@tcp(127.0.0.1:3306)/webarchive?charset=utf8mb4&parseTime=True&loc=Local"),
+		MySQLReadDSN:      l.str("MYSQL_READ_DSN", ""),
+		MySQLMaxOpen:      l.nonNegative("MYSQL_MAX_OPEN_CONNS", 0),
+		MySQLMaxIdle:      l.nonNegative("MYSQL_MAX_IDLE_CONNS", 10),
+		MySQLMaxLifetime:  time.Duration(l.nonNegative("MYSQL_CONN_MAX_LIFETIME_SECONDS", 0)) * time.Second,
+		MinIOEndpoint:     l.str("MINIO_ENDPOINT", "127.0.0.1:9000"),
+		MinIOAccessKey:    l.str("MINIO_ACCESS_KEY", "minioadmin"),
+		MinIOSecretKey:    l.str("MINIO_SECRET_KEY", "minioadmin"),
+		MinIOSecure:       l.boolean("MINIO_SECURE", false),
+		MinIOBucket:       l.str("MINIO_BUCKET", "webarchive"),
+		ColdEndpoint:      l.str("MINIO_COLD_ENDPOINT", ""),
+		ColdAccessKey:     l.str("MINIO_COLD_ACCESS_KEY", ""),
+		ColdSecretKey:     l.str("MINIO_COLD_SECRET_KEY", ""),
+		ColdSecure:        l.boolean("MINIO_COLD_SECURE", false),
+		ColdBucket:        l.str("MINIO_COLD_BUCKET", ""),
+		TieringMonths:     l.nonNegative("TIERING_AFTER_MONTHS", 0),
+		TieringEvery:      time.Duration(l.positive("TIERING_INTERVAL_HOURS", 24)) * time.Hour,
+		MetaRefreshEvery:  time.Duration(l.nonNegative("METADATA_REFRESH_INTERVAL_HOURS", 24)) * time.Hour,
+		TitleCleanup:      l.boolean("METADATA_TITLE_CLEANUP", true),
+		TitleCleanupLLM:   l.boolean("METADATA_TITLE_CLEANUP_LLM", false),
+		PriceTrackEvery:   time.Duration(l.nonNegative("PRICE_TRACK_INTERVAL_HOURS", 24)) * time.Hour,
+		PriceWebhookURL:   l.str("PRICE_WEBHOOK_URL", ""),
+		WatchEvery:        time.Duration(l.nonNegative("WATCH_INTERVAL_HOURS", 24)) * time.Hour,
+		WatchMinChange:    l.nonNegative("WATCH_MIN_CHANGE_PERCENT", 5),
+		WatchWebhookURL:   l.str("WATCH_WEBHOOK_URL", ""),
+		RemindEvery:       time.Duration(l.nonNegative("REMINDER_INTERVAL_MINUTES", 15)) * time.Minute,
+		RemindWebhookURL:  l.str("REMINDER_WEBHOOK_URL", ""),
+		NotifyWebhookURL:  l.str("NOTIFICATIONS_WEBHOOK_URL", ""),
+		NotifyEmailTo:     l.str("NOTIFICATIONS_EMAIL_TO", ""),
+		RecaptureEvery:    time.Duration(l.nonNegative("RECAPTURE_INTERVAL_HOURS", 24)) * time.Hour,
+		RecaptureKeep:     l.positive("RECAPTURE_KEEP_VERSIONS", 5),
+		QuotaBytes:        l.nonNegative("QUOTA_BYTES", 0),
+		UserQuotaBytes:    l.nonNegative("QUOTA_USER_BYTES", 0),
+		HTTPTimeout:       l.seconds("HTTP_TIMEOUT_SECONDS", 20),
+		MaxAssetBytes:     l.positive("MAX_ASSET_BYTES", 20<<20),
+		MaxPayloadBytes:   l.nonNegative("MAX_PAYLOAD_BYTES", 128<<20),
+		AssetMemoryBytes:  l.nonNegative("ASSET_MEMORY_BYTES", 256<<20),
+		ParseMaxBytes:     l.nonNegative("PARSE_MAX_BYTES", 64<<20),
+		ParseMaxNodes:     l.nonNegative("PARSE_MAX_NODES", 1000000),
+		ParseMaxDepth:     l.nonNegative("PARSE_MAX_DEPTH", 1024),
+		ParseTimeout:      time.Duration(l.nonNegative("PARSE_TIMEOUT_SECONDS", 10)) * time.Second,
+		LLMBaseURL:        l.str("LLM_BASE_URL", "https://api.openai.com/v1"),
+		LLMAPIKey:         l.str("LLM_API_KEY", ""),
+		LLMModel:          l.str("LLM_MODEL", ""),
+		LLMTimeout:        l.seconds("LLM_TIMEOUT_SECONDS", 90),
+		LLMEnabled:        l.boolean("LLM_ENABLED", false),
+		LLMEmbedModel:     l.str("LLM_EMBEDDING_MODEL", ""),
+		LLMFallbackURL:    l.str("LLM_FALLBACK_BASE_URL", ""),
+		LLMFallbackKey:    l.str("LLM_FALLBACK_API_KEY", ""),
+		LLMFallbackModel:  l.str("LLM_FALLBACK_MODEL", ""),
+		LLMBreakerFails:   l.positive("LLM_BREAKER_THRESHOLD", 5),
+		LLMBreakerReset:   l.seconds("LLM_BREAKER_COOLDOWN_SECONDS", 60),
+		LLMConcurrency:    l.nonNegative("LLM_MAX_CONCURRENT", 4),
+		LLMTrace:          l.boolean("LLM_TRACE", false),
+		LLMTraceDays:      l.positive("LLM_TRACE_RETENTION_DAYS", 7),
+		AutoTagOnCapture:  l.boolean("AUTO_TAG_ON_CAPTURE", false),
+		AutoTagWorkers:    l.positive("AUTO_TAG_WORKERS", 2),
+		AutoTagQueueSize:  l.positive("AUTO_TAG_QUEUE_SIZE", 200),
+		AutoTagRetries:    l.nonNegative("AUTO_TAG_RETRIES", 2),
+		AutoTagBackend:    l.str("AUTO_TAG_QUEUE_BACKEND", "memory"),
+		EinoEnabled:       l.boolean("EINO_ENABLED", true),
+		StartupRetries:    l.positive("STARTUP_RETRIES", 8),
+		SettingsKey:       l.str("SETTINGS_ENCRYPTION_KEY", ""),
+		ArchiveKey:        l.str("ARCHIVE_ENCRYPTION_KEY", ""),
+		VectorStore:       l.str("VECTOR_STORE", "db"),
+		CacheBackend:      l.str("CACHE_BACKEND", "memory"),
+		CacheTTL:          l.seconds("CACHE_TTL_SECONDS", 60),
+		CacheMaxEntries:   l.positive("CACHE_MAX_ENTRIES", 1000),
+		RedisAddr:         l.str("REDIS_ADDR", "127.0.0.1:6379"),
+		RedisPassword:     l.str("REDIS_PASSWORD", ""),
+		RedisDB:           l.nonNegative("REDIS_DB", 0),
+		QdrantURL:         l.str("QDRANT_URL", "http://127.0.0.1:6333"),
+		QdrantAPIKey:      l.str("QDRANT_API_KEY", ""),
+		QdrantPrefix:      l.str("QDRANT_COLLECTION_PREFIX", "webarchive"),
+		SearchIndex:       l.str("SEARCH_INDEX", "db"),
+		SearchNgram:       l.boolean("SEARCH_NGRAM", false),
+		MeiliURL:          l.str("MEILISEARCH_URL", "http://127.0.0.1:7700"),
+		MeiliAPIKey:       l.str("MEILISEARCH_API_KEY", ""),
+		MeiliIndex:        l.str("MEILISEARCH_INDEX", "webarchive"),
+		DigestSchedule:    l.str("DIGEST_SCHEDULE", "off"),
+		DigestWebhookURL:  l.str("DIGEST_WEBHOOK_URL", ""),
+		DigestEmailTo:     l.str("DIGEST_EMAIL_TO", ""),
+		SMTPAddr:          l.str("SMTP_ADDR", ""),
+		SMTPUsername:      l.str("SMTP_USERNAME", ""),
+		SMTPPassword:      l.str("SMTP_PASSWORD", ""),
+		SMTPFrom:          l.str("SMTP_FROM", ""),
+		DeviceEmailTo:     l.str("DEVICE_EMAIL_TO", ""),
+		AuthEnabled:       l.boolean("AUTH_ENABLED", false),
+		PublicEnabled:     l.boolean("PUBLIC_ENABLED", false),
+		ViewerOrigin:      strings.TrimRight(l.str("ARCHIVE_VIEWER_ORIGIN", ""), "/"),
+		ViewerSecret:      l.str("ARCHIVE_VIEWER_SECRET", ""),
+		ArchiveIDFormat:   strings.ToLower(l.str("ARCHIVE_ID_FORMAT", "uuid")),
+		AdminUsername:     l.str("ADMIN_USERNAME", "admin"),
+		AdminPassword:     l.str("ADMIN_PASSWORD", ""),
+		RetentionEvery:    time.Duration(l.positive("RETENTION_INTERVAL_HOURS", 24)) * time.Hour,
+		ResurfaceEvery:    time.Duration(l.positive("RESURFACE_INTERVAL_HOURS", 168)) * time.Hour,
+		ConsistencyEvery:  time.Duration(l.positive("CONSISTENCY_INTERVAL_HOURS", 24)) * time.Hour,
+		ConsistencyRepair: l.boolean("CONSISTENCY_AUTO_REPAIR", false),
+		SnapshotEvery:     time.Duration(l.positive("CONSISTENCY_SNAPSHOT_HOURS", 24)) * time.Hour,
+		SnapshotKeep:      time.Duration(l.nonNegative("CONSISTENCY_SNAPSHOT_KEEP_DAYS", 365)) * 24 * time.Hour,
+		FetchUserAgent:    l.str("FETCH_USER_AGENT", "WebArchiveBot/0.1"),
+		FetchRobots:       l.boolean("FETCH_RESPECT_ROBOTS", false),
+		FetchHostDelay:    time.Duration(l.nonNegative("FETCH_HOST_DELAY_MS", 0)) * time.Millisecond,
+		FetchConcurrency:  l.nonNegative("FETCH_MAX_CONCURRENT", 0),
+		FetchProxy:        l.str("FETCH_PROXY", ""),
+		FetchProxyRules:   l.str("FETCH_PROXY_RULES", ""),
+		BlockTrackers:     l.boolean("FETCH_BLOCK_TRACKERS", false),
+		BlocklistFile:     l.str("FETCH_BLOCKLIST_FILE", ""),
+		FetchAllowHosts:   l.str("FETCH_ALLOW_HOSTS", ""),
+		FetchDenyHosts:    l.str("FETCH_DENY_HOSTS", ""),
+		ExtractorBuiltin:  l.boolean("EXTRACTOR_BUILTIN", true),
+		ExtractorURLs:     l.str("EXTRACTOR_ENDPOINTS", ""),
+		PDFTextCommand:    l.str("PDF_TEXT_COMMAND", "pdftotext"),
+		PDFThumbCommand:   l.str("PDF_THUMBNAIL_COMMAND", "pdftoppm"),
+		ScrubRules:        l.str("SCRUB_RULES", ""),
+		ScrubPatternFile:  l.str("SCRUB_PATTERNS_FILE", ""),
+		ScrubTerms:        l.str("SCRUB_TERMS", ""),
+		LLMProxy:          l.str("LLM_PROXY", ""),
+		LLMProxyRules:     l.str("LLM_PROXY_RULES", ""),
+		TaxonomyRouter:    l.str("TAXONOMY_ROUTER", "stepwise"),
+		TaxonomyDepth:     l.positive("TAXONOMY_MAX_DEPTH", 4),
+		TaxonomyOptions:   l.positive("TAXONOMY_MAX_OPTIONS", 30),
+		TaxonomyPathLen:   l.positive("TAXONOMY_MAX_PATH_LENGTH", 500),
+		TaxonomyLabelLen:  l.positive("TAXONOMY_MAX_LABEL_LENGTH", 80),
+		TaxonomyWords:     l.positive("TAXONOMY_MAX_LABEL_WORDS", 6),
+		TaxonomyBanned:    l.str("TAXONOMY_BANNED_CHARS", "#*<>|\"`{}[]\\"),
+		TaxonomyCase:      l.str("TAXONOMY_LABEL_CASE", "keep"),
+		TaxonomyMerge:     l.boolean("TAXONOMY_MERGE_SIMILAR", true),
+	}
+	if strings.TrimSpace(cfg.Addr) == "" {
+		l.fail("ADDR", "must not be empty")
+	}
+	if strings.TrimSpace(cfg.MinIOBucket) == "" {
+		l.fail("MINIO_BUCKET", "must not be empty")
+	}
+	if cfg.MySQLMaxOpen > 0 && cfg.MySQLMaxIdle > cfg.MySQLMaxOpen {
+		l.fail("MYSQL_MAX_IDLE_CONNS", fmt.Sprintf("must not exceed MYSQL_MAX_OPEN_CONNS (%d)", cfg.MySQLMaxOpen))
+	}
+	if cfg.TieringMonths > 0 && strings.TrimSpace(cfg.ColdBucket) == "" {
+		l.fail("TIERING_AFTER_MONTHS", "requires MINIO_COLD_BUCKET")
+	}
+	if cfg.ColdBucket != "" && cfg.ColdBucket == cfg.MinIOBucket && (cfg.ColdEndpoint == "" || cfg.ColdEndpoint == cfg.MinIOEndpoint) {
+		l.fail("MINIO_COLD_BUCKET", "must differ from MINIO_BUCKET on the same endpoint")
+	}
+	switch cfg.VectorStore {
+	case "db", "qdrant":
+	default:
+		l.fail("VECTOR_STORE", fmt.Sprintf("must be db or qdrant, got %q", cfg.VectorStore))
+	}
+	switch cfg.SearchIndex {
+	case "db", "meilisearch":
+	default:
+		l.fail("SEARCH_INDEX", fmt.Sprintf("must be db or meilisearch, got %q", cfg.SearchIndex))
+	}
+	switch cfg.CacheBackend {
+	case "off", "memory", "redis":
+	default:
+		l.fail("CACHE_BACKEND", fmt.Sprintf("must be off, memory or redis, got %q", cfg.CacheBackend))
+	}
+	switch cfg.AutoTagBackend {
+	case "memory", "redis":
+	default:
+		l.fail("AUTO_TAG_QUEUE_BACKEND", fmt.Sprintf("must be memory or redis, got %q", cfg.AutoTagBackend))
+	}
+	switch cfg.DigestSchedule {
+	case "off", "daily", "weekly":
+	default:
+		l.fail("DIGEST_SCHEDULE", fmt.Sprintf("must be off, daily or weekly, got %q", cfg.DigestSchedule))
+	}
+	switch cfg.TaxonomyRouter {
+	case "stepwise", "single":
+	default:
+		l.fail("TAXONOMY_ROUTER", fmt.Sprintf("must be stepwise or single, got %q", cfg.TaxonomyRouter))
+	}
+	if cfg.TaxonomyDepth > 10 {
+		l.fail("TAXONOMY_MAX_DEPTH", "must be at most 10")
+	}
+	if cfg.TaxonomyOptions > 200 {
+		l.fail("TAXONOMY_MAX_OPTIONS", "must be at most 200")
+	}
+	if cfg.TaxonomyPathLen > 512 {
+		l.fail("TAXONOMY_MAX_PATH_LENGTH", "must be at most 512")
+	}
+	if cfg.TaxonomyLabelLen > 255 {
+		l.fail("TAXONOMY_MAX_LABEL_LENGTH", "must be at most 255")
+	}
+	if cfg.LLMTraceDays > 365 {
+		l.fail("LLM_TRACE_RETENTION_DAYS", "must be at most 365")
+	}
+	if cfg.TaxonomyWords > 50 {
+		l.fail("TAXONOMY_MAX_LABEL_WORDS", "must be at most 50")
+	}
+	switch cfg.TaxonomyCase {
+	case "keep", "lower", "sentence", "title":
+	default:
+		l.fail("TAXONOMY_LABEL_CASE", fmt.Sprintf("must be keep, lower, sentence or title, got %q", cfg.TaxonomyCase))
+	}
+	switch cfg.ArchiveIDFormat {
+	case "uuid", "slug":
+	default:
+		l.fail("ARCHIVE_ID_FORMAT", fmt.Sprintf("must be uuid or slug, got %q", cfg.ArchiveIDFormat))
+	}
+	if cfg.AuthEnabled && strings.TrimSpace(cfg.AdminUsername) == "" {
+		l.fail("ADMIN_USERNAME", "must not be empty when AUTH_ENABLED is true")
+	}
+	if cfg.ViewerOrigin != "" {
+		viewer, err := url.Parse(cfg.ViewerOrigin)
+		base, _ := url.Parse(cfg.BaseURL)
+		switch {
+		case err != nil || (viewer.Scheme != "http" && viewer.Scheme != "https") || viewer.Host == "" || viewer.Path != "":
+			l.fail("ARCHIVE_VIEWER_ORIGIN", "must be an origin like https://view.example.com")
+		case base != nil && strings.EqualFold(viewer.Host, base.Host):
+			l.fail("ARCHIVE_VIEWER_ORIGIN", "must differ from BASE_URL")
+		}
+	}
+	if strings.TrimSpace(cfg.FetchUserAgent) == "" {
+		l.fail("FETCH_USER_AGENT", "must not be empty")
+	}
+	if _, err := proxy.Parse(cfg.FetchProxy, cfg.FetchProxyRules); err != nil {
+		l.fail("FETCH_PROXY", err.Error())
+	}
+	if _, err := proxy.Parse(cfg.LLMProxy, cfg.LLMProxyRules); err != nil {
+		l.fail("LLM_PROXY", err.Error())
+	}
+	if _, err := processor.NewScrubber(strings.Split(cfg.ScrubRules, ",")); err != nil {
+		l.fail("SCRUB_RULES", err.Error())
+	}
+	if cfg.ArchiveKey != "" {
+		if _, err := storage.NewCipher(cfg.ArchiveKey); err != nil {
+			l.fail("ARCHIVE_ENCRYPTION_KEY", err.Error())
+		}
+	}
+	if _, err := extractor.New(cfg.ExtractorBuiltin, cfg.ExtractorURLs); err != nil {
+		l.fail("EXTRACTOR_ENDPOINTS", err.Error())
+	}
+	for key := range l.file {
+		if !l.used[key] {
+			l.errs = append(l.errs, fmt.Sprintf("%s: unknown key %q", path, strings.ToLower(key)))
+		}
+	}
+	cfg.Entries = l.entries
+	if len(l.errs) > 0 {
+		return cfg, errors.New("invalid configuration:\n  " + strings.Join(l.errs, "\n  "))
+	}
+	return cfg, nil
 }
 
-func Load() Config {
-	return Config{
-		Addr:             getenv("ADDR", ":8080"),
-		BaseURL:          getenv("BASE_URL", "http://localhost:8080"),
-		MySQLDSN:         getenv("MYSQL_DSN", "webarchive:webarchive@tcp(127.0.0.1:3306)/webarchive?charset=utf8mb4&parseTime=True&loc=Local"),
-		MinIOEndpoint:    getenv("MINIO_ENDPOINT", "127.0.0.1:9000"),
-		MinIOAccessKey:   getenv("MINIO_ACCESS_KEY", "minioadmin"),
-		MinIOSecretKey:   getenv("MINIO_SECRET_KEY", "minioadmin"),
-		MinIOSecure:      getenvBool("MINIO_SECURE", false),
-		MinIOBucket:      getenv("MINIO_BUCKET", "webarchive"),
-		HTTPTimeout:      time.Duration(getenvInt("HTTP_TIMEOUT_SECONDS", 20)) * time.Second,
-		LLMBaseURL:       getenv("LLM_BASE_URL", "https://api.openai.com/v1"),
-		LLMAPIKey:        getenv("LLM_API_KEY", ""),
-		LLMModel:         getenv("LLM_MODEL", ""),
-		LLMTimeout:       time.Duration(getenvInt("LLM_TIMEOUT_SECONDS", 90)) * time.Second,
-		LLMEnabled:       getenvBool("LLM_ENABLED", false),
-		AutoTagOnCapture: getenvBool("AUTO_TAG_ON_CAPTURE", false),
-		EinoEnabled:      getenvBool("EINO_ENABLED", true),
+// Print writes the effective configuration with secrets masked.
+func (c Config) Print(w io.Writer) {
+	for _, e := range c.Entries {
+		fmt.Fprintf(w, "%s: %q  # %s\n", strings.ToLower(e.Key), maskValue(e.Key, e.Value), e.Source)
 	}
 }
 
-func getenv(key, def string) string {
+type loader struct {
+	path    string
+	file    map[string]string
+	used    map[string]bool
+	entries []Entry
+	errs    []string
+}
+
+func (l *loader) lookup(key, def string) (string, string) {
+	l.used[key] = true
+	value, source := def, "default"
+	if v, ok := l.file[key]; ok {
+		value, source = v, l.path
+	}
 	if v := os.Getenv(key); v != "" {
-		return v
+		value, source = v, "env"
 	}
-	return def
+	l.entries = append(l.entries, Entry{Key: key, Value: value, Source: source})
+	return value, source
 }
 
-func getenvBool(key string, def bool) bool {
-	if v := os.Getenv(key); v != "" {
-		b, err := strconv.ParseBool(v)
-		if err == nil {
-			return b
-		}
+func (l *loader) fail(key, msg string) {
+	l.errs = append(l.errs, fmt.Sprintf("%s: %s", key, msg))
+}
+
+func (l *loader) str(key, def string) string {
+	v, _ := l.lookup(key, def)
+	return v
+}
+
+func (l *loader) boolean(key string, def bool) bool {
+	v, source := l.lookup(key, strconv.FormatBool(def))
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		l.fail(key, fmt.Sprintf("expected true/false, got %q (from %s)", v, source))
+		return def
 	}
-	return def
+	return b
 }
 
-func getenvInt(key string, def int) int {
-	if v := os.Getenv(key); v != "" {
-		i, err := strconv.Atoi(v)
-		if err == nil {
-			return i
+func (l *loader) integer(key string, def int) (int, string, bool) {
+	v, source := l.lookup(key, strconv.Itoa(def))
+	i, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		l.fail(key, fmt.Sprintf("expected an integer, got %q (from %s)", v, source))
+		return def, source, false
+	}
+	return i, source, true
+}
+
+func (l *loader) positive(key string, def int) int {
+	i, source, ok := l.integer(key, def)
+	if ok && i <= 0 {
+		l.fail(key, fmt.Sprintf("must be greater than zero, got %d (from %s)", i, source))
+		return def
+	}
+	return i
+}
+
+func (l *loader) nonNegative(key string, def int) int {
+	i, source, ok := l.integer(key, def)
+	if ok && i < 0 {
+		l.fail(key, fmt.Sprintf("must not be negative, got %d (from %s)", i, source))
+		return def
+	}
+	return i
+}
+
+func (l *loader) seconds(key string, def int) time.Duration {
+	return time.Duration(l.positive(key, def)) * time.Second
+}
+
+func maskValue(key, value string) string {
+	if value == "" {
+		return ""
+	}
+	upper := strings.ToUpper(key)
+	switch {
+	case strings.Contains(upper, "SECRET"), strings.Contains(upper, "API_KEY"), strings.Contains(upper, "PASSWORD"), strings.HasSuffix(upper, "_KEY") && !strings.HasSuffix(upper, "ACCESS_KEY"):
+		return "********"
+	case strings.HasSuffix(upper, "_DSN"):
+		// user:password@tcp(...) -> user:********@tcp(...)
+		if at := strings.Index(value, "@"); at > 0 {
+			if colon := strings.Index(value[:at], ":"); colon >= 0 {
+				return value[:colon+1] + "********" + value[at:]
+			}
 		}
 	}
-	return def
+	return value
 }
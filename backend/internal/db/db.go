@@ -1,6 +1,8 @@
 package db
 
 import (
+	"time"
+
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -8,15 +10,45 @@ import (
 	"webarchive/internal/models"
 )
 
-func Connect(dsn string) (*gorm.DB, error) {
+// Pool bounds the connections kept by each database handle. Zero values
+// leave database/sql's defaults in place, except MaxIdle where zero keeps
+// no idle connections.
+type Pool struct {
+	MaxOpen     int
+	MaxIdle     int
+	MaxLifetime time.Duration
+}
+
+func Connect(dsn string, pool Pool) (*gorm.DB, error) {
+	gdb, err := open(dsn, pool)
+	if err != nil {
+		return nil, err
+	}
+	if err := gdb.AutoMigrate(&models.Archive{}, &models.ArchivePath{}, &models.TaxonomyNode{}, &models.AppSetting{}, &models.ArchiveEvent{}, &models.CapturePreset{}, &models.User{}, &models.APIToken{}, &models.AdminAudit{}, &models.ArchiveEmbedding{}, &models.ArchiveCluster{}, &models.Digest{}, &models.DomainCookie{}, &models.RetentionRule{}, &models.Note{}, &models.Flashcard{}, &models.ResurfaceScore{}, &models.CompatID{}, &models.PairingCode{}, &models.DashboardPin{}, &models.AnalysisProposal{}, &models.PricePoint{}, &models.PageChange{}, &models.RecaptureRule{}, &models.ArchiveVersion{}, &models.ViewEvent{}, &models.ArchiveAlias{}, &models.Lease{}, &models.TaxonomyWebhook{}, &models.Annotation{}, &models.StanceCheck{}, &models.Notification{}, &models.GraphSnapshot{}, &models.AITrace{}, &models.Author{}, &models.ArchiveAuthor{}, &models.StatsSnapshot{}, &models.CaptureRule{}); err != nil {
+		return nil, err
+	}
+	return gdb, nil
+}
+
+// ConnectReplica opens a read replica. The schema is migrated on the primary
+// and reaches the replica through replication, so nothing is migrated here.
+func ConnectReplica(dsn string, pool Pool) (*gorm.DB, error) {
+	return open(dsn, pool)
+}
+
+func open(dsn string, pool Pool) (*gorm.DB, error) {
 	gdb, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Warn),
 	})
 	if err != nil {
 		return nil, err
 	}
-	if err := gdb.AutoMigrate(&models.Archive{}, &models.ArchivePath{}, &models.TaxonomyNode{}, &models.AppSetting{}); err != nil {
+	sqlDB, err := gdb.DB()
+	if err != nil {
 		return nil, err
 	}
+	sqlDB.SetMaxOpenConns(pool.MaxOpen)
+	sqlDB.SetMaxIdleConns(pool.MaxIdle)
+	sqlDB.SetConnMaxLifetime(pool.MaxLifetime)
 	return gdb, nil
 }
@@ -0,0 +1,21 @@
+package db
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+	"webarchive/internal/searchindex"
+)
+
+// EnsureNgramIndex adds the ngram FULLTEXT index used by SEARCH_NGRAM. Building
+// it rewrites the archives table, so the first start after enabling it can
+// take a while on large archives.
+func EnsureNgramIndex(gdb *gorm.DB) error {
+	if gdb.Migrator().HasIndex(&models.Archive{}, searchindex.NgramIndexName) {
+		return nil
+	}
+	sql := fmt.Sprintf("CREATE FULLTEXT INDEX %s ON archives (title, content_text) WITH PARSER ngram", searchindex.NgramIndexName)
+	return gdb.Exec(sql).Error
+}
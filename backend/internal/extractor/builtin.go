@@ -0,0 +1,44 @@
+package extractor
+
+// builtins are the extractors shipped with the server. Their selectors
+// follow each site's markup at the time of writing; when a site changes
+// its layout an HTTP extractor for the domain can stand in until they are
+// updated.
+var builtins = []struct {
+	domains   []string
+	extractor Extractor
+}{
+	{[]string{"mp.weixin.qq.com"}, &Selectors{
+		Label:    "wechat",
+		Title:    []string{"#activity-name", "meta[property=\"og:title\"]"},
+		Byline:   []string{"#js_author_name", "meta[name=author]"},
+		SiteName: []string{"#js_name"},
+		Excerpt:  []string{"meta[name=description]"},
+		Content:  []string{"#js_content"},
+	}},
+	{[]string{"zhihu.com"}, &Selectors{
+		Label:    "zhihu",
+		Title:    []string{"h1.Post-Title", "h1.QuestionHeader-title"},
+		Byline:   []string{".AuthorInfo-name", "meta[itemprop=name]"},
+		SiteName: []string{"meta[property=\"og:site_name\"]"},
+		Excerpt:  []string{"meta[name=description]"},
+		Content:  []string{".Post-RichText", ".RichContent-inner"},
+		Tags:     []string{".QuestionHeader-topics .Tag", ".Post-topicsAndReviewer .Tag"},
+	}},
+	{[]string{"medium.com"}, &Selectors{
+		Label:    "medium",
+		Title:    []string{"h1[data-testid=storyTitle]", "article h1"},
+		Byline:   []string{"[data-testid=authorName]", "meta[name=author]"},
+		SiteName: []string{"meta[property=\"og:site_name\"]"},
+		Excerpt:  []string{"meta[name=description]"},
+		Content:  []string{"article"},
+	}},
+	{[]string{"reddit.com"}, &Selectors{
+		Label:    "reddit",
+		Title:    []string{"h1[slot=title]", "#siteTable a.title", "meta[property=\"og:title\"]"},
+		Byline:   []string{"#siteTable .tagline .author"},
+		SiteName: []string{"meta[property=\"og:site_name\"]"},
+		Excerpt:  []string{"meta[property=\"og:description\"]"},
+		Content:  []string{"[slot=text-body]", "#siteTable .usertext-body"},
+	}},
+}
@@ -0,0 +1,104 @@
+// Package extractor pulls article fields out of pages from sites whose
+// markup the generic text extraction gets wrong: forums, Q&A sites and
+// platforms that wrap the article in their own chrome.
+package extractor
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Extraction is what a site extractor found on a page. Fields it leaves
+// empty keep the values from the generic extraction.
+type Extraction struct {
+	Title    string   `json:"title"`
+	Byline   string   `json:"byline"`
+	SiteName string   `json:"siteName"`
+	Excerpt  string   `json:"excerpt"`
+	Content  string   `json:"content"` // plain text
+	Tags     []string `json:"tags"`
+}
+
+// Extractor handles the pages of one site. Extract gets the page as fetched
+// or submitted, before the processor rewrites it.
+type Extractor interface {
+	Name() string
+	Extract(ctx context.Context, pageURL string, page []byte) (*Extraction, error)
+}
+
+// Registry maps domain patterns to extractors. A pattern is a host and
+// covers its subdomains; the most specific registered pattern wins.
+type Registry struct {
+	domains map[string]Extractor
+}
+
+func NewRegistry() *Registry {
+	return &Registry{domains: map[string]Extractor{}}
+}
+
+// Register adds e for pattern, replacing any extractor registered for
+// exactly that pattern.
+func (r *Registry) Register(pattern string, e Extractor) {
+	r.domains[normalizePattern(pattern)] = e
+}
+
+// Lookup returns the extractor for pageURL, or nil. A nil *Registry has none.
+func (r *Registry) Lookup(pageURL string) Extractor {
+	if r == nil {
+		return nil
+	}
+	u, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for host != "" {
+		if e, ok := r.domains[host]; ok {
+			return e
+		}
+		dot := strings.Index(host, ".")
+		if dot < 0 {
+			break
+		}
+		host = host[dot+1:]
+	}
+	return nil
+}
+
+func normalizePattern(p string) string {
+	return strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(strings.TrimSpace(p)), "*"), ".")
+}
+
+// New builds the registry from the built-in extractors, when builtin is
+// set, and a comma separated list of domain=url pairs naming external HTTP
+// extractors, which take precedence over built-ins for the same domain.
+func New(builtin bool, endpoints string) (*Registry, error) {
+	r := NewRegistry()
+	if builtin {
+		for _, b := range builtins {
+			for _, pattern := range b.domains {
+				r.Register(pattern, b.extractor)
+			}
+		}
+	}
+	for _, rule := range strings.Split(endpoints, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		domain, target, ok := strings.Cut(rule, "=")
+		domain = normalizePattern(domain)
+		target = strings.TrimSpace(target)
+		if !ok || domain == "" || target == "" {
+			return nil, fmt.Errorf("extractor rule %q: expected domain=url", rule)
+		}
+		u, err := url.Parse(target)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("extractor rule %q: invalid url %q", rule, target)
+		}
+		r.Register(domain, &HTTP{Endpoint: target})
+	}
+	return r, nil
+}
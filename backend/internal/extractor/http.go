@@ -0,0 +1,54 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// HTTP delegates extraction to an external service, so extractors can be
+// written in any language and maintained outside this repository. The
+// service receives {"url": ..., "html": ...} as a JSON POST and answers with
+// an Extraction object; any non-2xx answer is an error.
+type HTTP struct {
+	Endpoint string
+	Client   *http.Client
+}
+
+// maxExtractionBytes caps the answer read from an external extractor.
+const maxExtractionBytes = 8 << 20
+
+func (h *HTTP) Name() string { return h.Endpoint }
+
+func (h *HTTP) Extract(ctx context.Context, pageURL string, page []byte) (*Extraction, error) {
+	body, err := json.Marshal(map[string]string{"url": pageURL, "html": string(page)})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("extractor status: %d", resp.StatusCode)
+	}
+	var out Extraction
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxExtractionBytes)).Decode(&out); err != nil {
+		return nil, fmt.Errorf("extractor response: %w", err)
+	}
+	return &out, nil
+}
@@ -0,0 +1,112 @@
+package extractor
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"webarchive/internal/processor"
+)
+
+// Selectors extracts fields with CSS selectors (the subset
+// processor.ParseSelector supports). Each field lists selectors in order of
+// preference: Title, Byline, SiteName, Excerpt and Content take the first
+// element the earliest selector matches, Tags every element any of them
+// matches. A matched <meta> contributes its content attribute.
+type Selectors struct {
+	Label    string
+	Title    []string
+	Byline   []string
+	SiteName []string
+	Excerpt  []string
+	Content  []string
+	Tags     []string
+}
+
+func (s *Selectors) Name() string { return s.Label }
+
+func (s *Selectors) Extract(_ context.Context, _ string, page []byte) (*Extraction, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	out := &Extraction{}
+	fields := []struct {
+		selectors []string
+		dst       *string
+	}{
+		{s.Title, &out.Title},
+		{s.Byline, &out.Byline},
+		{s.SiteName, &out.SiteName},
+		{s.Excerpt, &out.Excerpt},
+		{s.Content, &out.Content},
+	}
+	for _, f := range fields {
+		sels, err := processor.ParseSelectors(f.selectors)
+		if err != nil {
+			return nil, err
+		}
+	field:
+		for _, sel := range sels {
+			for _, n := range selectAll(doc, sel) {
+				if text := nodeText(n); text != "" {
+					*f.dst = text
+					break field
+				}
+			}
+		}
+	}
+	sels, err := processor.ParseSelectors(s.Tags)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	for _, sel := range sels {
+		for _, n := range selectAll(doc, sel) {
+			if tag := nodeText(n); tag != "" && !seen[tag] {
+				seen[tag] = true
+				out.Tags = append(out.Tags, tag)
+			}
+		}
+	}
+	return out, nil
+}
+
+// selectAll returns the elements matching sel in document order, skipping
+// the subtrees of matches.
+func selectAll(doc *html.Node, sel *processor.Selector) []*html.Node {
+	var out []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if sel.Match(c) {
+				out = append(out, c)
+				continue
+			}
+			walk(c)
+		}
+	}
+	walk(doc)
+	return out
+}
+
+func nodeText(n *html.Node) string {
+	if strings.EqualFold(n.Data, "meta") {
+		for _, a := range n.Attr {
+			if strings.EqualFold(a.Key, "content") {
+				return strings.TrimSpace(a.Val)
+			}
+		}
+		return ""
+	}
+	// The node is rendered on its own so the generic extraction, with its
+	// line breaks between blocks, applies to just this subtree.
+	var b bytes.Buffer
+	if err := html.Render(&b, n); err != nil {
+		return ""
+	}
+	_, text := processor.ExtractText(b.Bytes())
+	return strings.TrimSpace(text)
+}
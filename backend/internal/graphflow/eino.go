@@ -2,14 +2,16 @@ package graphflow
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/cloudwego/eino/compose"
 
 	"webarchive/internal/ai"
 	"webarchive/internal/models"
+	"webarchive/internal/textutil"
 )
 
 type GraphInput struct {
@@ -25,14 +27,18 @@ type Relation struct {
 }
 
 type GraphOutput struct {
-	Category  string     `json:"category"`
-	Tags      []string   `json:"tags"`
-	Path      []string   `json:"path"`
-	Entities  []string   `json:"entities"`
-	Relations []Relation `json:"relations"`
-	Summary   string     `json:"summary"`
+	Category    string            `json:"category"`
+	Tags        []string          `json:"tags"`
+	Path        []string          `json:"path"`
+	Entities    []string          `json:"entities"`
+	EntityTypes map[string]string `json:"entity_types"`
+	Relations   []Relation        `json:"relations"`
+	Summary     string            `json:"summary"`
 }
 
+// EntityTypes are the classes an extracted entity may be tagged with.
+var EntityTypes = []string{"person", "organization", "technology", "concept", "place"}
+
 type cleanedInput struct {
 	Title    string
 	URL      string
@@ -85,17 +91,18 @@ func (a *Analyzer) Analyze(ctx context.Context, input GraphInput) (GraphOutput,
 	return a.runnable.Invoke(ctx, input)
 }
 
+const (
+	// chunkBytes is how much text goes into one extraction prompt.
+	chunkBytes = 6000
+	// maxChunks bounds the LLM calls spent on a single archive.
+	maxChunks = 4
+)
+
 func cleanerNode(ctx context.Context, input GraphInput) (cleanedInput, error) {
-	content := strings.TrimSpace(input.Archive.ContentText)
-	if len(content) > 6000 {
-		content = content[:6000]
-	}
+	content := textutil.Truncate(strings.TrimSpace(input.Archive.ContentText), chunkBytes*maxChunks)
 	excerpt := input.Archive.Excerpt
 	if excerpt == "" {
-		excerpt = strings.TrimSpace(content)
-		if len(excerpt) > 200 {
-			excerpt = excerpt[:200]
-		}
+		excerpt = textutil.Truncate(strings.TrimSpace(content), 200)
 	}
 	return cleanedInput{
 		Title:    input.Archive.Title,
@@ -107,41 +114,69 @@ func cleanerNode(ctx context.Context, input GraphInput) (cleanedInput, error) {
 	}, nil
 }
 
+// extractorNode analyzes the content one chunk at a time and merges the
+// results, so long articles are covered past the first prompt's worth.
 func extractorNode(ctx context.Context, input cleanedInput) (GraphOutput, error) {
 	if input.LLM == nil || !input.LLM.Enabled() {
 		return GraphOutput{}, errors.New("llm not configured")
 	}
+	chunks := textutil.Chunks(input.Content, chunkBytes)
+	if len(chunks) == 0 {
+		chunks = []string{""}
+	}
+	if len(chunks) > maxChunks {
+		chunks = chunks[:maxChunks]
+	}
+	parts := make([]GraphOutput, 0, len(chunks))
+	var lastErr error
+	for i, chunk := range chunks {
+		out, err := extractChunk(ctx, input, chunk, i+1, len(chunks))
+		if err != nil {
+			if ctx.Err() != nil {
+				return GraphOutput{}, err
+			}
+			// One bad chunk shouldn't lose what the others found.
+			lastErr = err
+			continue
+		}
+		parts = append(parts, out)
+	}
+	if len(parts) == 0 {
+		return GraphOutput{}, lastErr
+	}
+	return mergeOutputs(parts), nil
+}
 
+func extractChunk(ctx context.Context, input cleanedInput, content string, part, total int) (GraphOutput, error) {
 	taxonomyHint := "none"
 	if len(input.Taxonomy) > 0 {
 		taxonomyHint = strings.Join(input.Taxonomy, ", ")
 	}
+	partHint := ""
+	if total > 1 {
+		partHint = fmt.Sprintf("The content is part %d of %d of a longer document; classify the whole document as best you can.\n", part, total)
+	}
 
 	system := "You are a knowledge graph analyst. Return strict JSON only."
 	user := "Analyze the content and return JSON with fields: " +
 		"category (string), tags (array of short strings), path (array of strings from high-level to low-level), " +
-		"entities (array of key concepts), relations (array of {source,target,type}), summary (one sentence).\n" +
+		"entities (array of key concepts), entity_types (object mapping each entity to its type), " +
+		"relations (array of {source,target,type}), summary (one sentence).\n" +
+		"Entity types must be one of: " + strings.Join(EntityTypes, ", ") + ".\n" +
 		"Relations type must be one of: is_a, part_of, related_to, prerequisite, based_on.\n" +
 		"Prefer taxonomy branches if provided, otherwise create a concise path (2-4 levels).\n" +
+		partHint +
 		"Taxonomy options: " + taxonomyHint + "\n" +
-		"Title: " + input.Title + "\nURL: " + input.URL + "\nExcerpt: " + input.Excerpt + "\nContent: " + input.Content
-
-	raw, err := input.LLM.ChatJSON(ctx, system, user, 0.2)
-	if err != nil {
-		return GraphOutput{}, err
-	}
-	raw = extractJSON(raw)
-	if raw == "" {
-		return GraphOutput{}, errors.New("llm invalid json")
-	}
+		"Title: " + input.Title + "\nURL: " + input.URL + "\nExcerpt: " + input.Excerpt + "\nContent: " + content
 
 	var out GraphOutput
-	if err := json.Unmarshal([]byte(raw), &out); err != nil {
+	if err := input.LLM.DecodeJSON(ctx, system, user, 0.2, &out, out.validate); err != nil {
 		return GraphOutput{}, err
 	}
 	out.Tags = normalizeList(out.Tags)
 	out.Path = normalizeList(out.Path)
 	out.Entities = normalizeList(out.Entities)
+	out.EntityTypes = normalizeEntityTypes(out.EntityTypes, out.Entities)
 	out.Relations = normalizeRelations(out.Relations)
 	out.Summary = strings.TrimSpace(out.Summary)
 
@@ -151,6 +186,96 @@ func extractorNode(ctx context.Context, input cleanedInput) (GraphOutput, error)
 	return out, nil
 }
 
+// validate rejects replies that can't be placed in the taxonomy or that
+// relate entities they never listed.
+func (o *GraphOutput) validate() error {
+	if strings.TrimSpace(o.Category) == "" && len(normalizeList(o.Path)) == 0 {
+		return errors.New("category or path is required")
+	}
+	allowed := map[string]bool{"is_a": true, "part_of": true, "related_to": true, "prerequisite": true, "based_on": true}
+	for _, r := range o.Relations {
+		if t := strings.TrimSpace(r.Type); t != "" && !allowed[t] {
+			return fmt.Errorf("relation type %q is not allowed", t)
+		}
+	}
+	return nil
+}
+
+// mergeOutputs combines per-chunk results: the path and category most chunks
+// agreed on (ties go to the earlier chunk), tags and entities ranked by how
+// many chunks mention them, and the union of relations. The first chunk's
+// summary wins since it usually covers the lead.
+func mergeOutputs(parts []GraphOutput) GraphOutput {
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	out := GraphOutput{EntityTypes: map[string]string{}}
+	out.Path = mostCommon(parts, func(p GraphOutput) string { return strings.Join(p.Path, "\x00") })
+	category := mostCommon(parts, func(p GraphOutput) string { return p.Category })
+	if len(category) > 0 {
+		out.Category = category[0]
+	}
+	out.Tags = rankByFrequency(parts, func(p GraphOutput) []string { return p.Tags })
+	out.Entities = rankByFrequency(parts, func(p GraphOutput) []string { return p.Entities })
+	seen := map[Relation]bool{}
+	for _, p := range parts {
+		for ent, t := range p.EntityTypes {
+			if _, ok := out.EntityTypes[ent]; !ok {
+				out.EntityTypes[ent] = t
+			}
+		}
+		for _, r := range p.Relations {
+			if !seen[r] {
+				seen[r] = true
+				out.Relations = append(out.Relations, r)
+			}
+		}
+		if out.Summary == "" {
+			out.Summary = p.Summary
+		}
+	}
+	out.EntityTypes = normalizeEntityTypes(out.EntityTypes, out.Entities)
+	if out.Category == "" && len(out.Path) > 0 {
+		out.Category = out.Path[0]
+	}
+	return out
+}
+
+// mostCommon returns the split form of the most frequent non-empty key.
+func mostCommon(parts []GraphOutput, key func(GraphOutput) string) []string {
+	counts := map[string]int{}
+	best, bestCount := "", 0
+	for _, p := range parts {
+		k := key(p)
+		if k == "" {
+			continue
+		}
+		counts[k]++
+		if counts[k] > bestCount {
+			best, bestCount = k, counts[k]
+		}
+	}
+	if best == "" {
+		return nil
+	}
+	return strings.Split(best, "\x00")
+}
+
+func rankByFrequency(parts []GraphOutput, values func(GraphOutput) []string) []string {
+	counts := map[string]int{}
+	order := []string{}
+	for _, p := range parts {
+		for _, v := range values(p) {
+			if counts[v] == 0 {
+				order = append(order, v)
+			}
+			counts[v]++
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] > counts[order[j]] })
+	return order
+}
+
 func formatterNode(ctx context.Context, input GraphOutput) (GraphOutput, error) {
 	if len(input.Path) > 6 {
 		input.Path = input.Path[:6]
@@ -160,6 +285,7 @@ func formatterNode(ctx context.Context, input GraphOutput) (GraphOutput, error)
 	}
 	if len(input.Entities) > 20 {
 		input.Entities = input.Entities[:20]
+		input.EntityTypes = normalizeEntityTypes(input.EntityTypes, input.Entities)
 	}
 	input.Category = strings.TrimSpace(input.Category)
 	if input.Category == "" && len(input.Path) > 0 {
@@ -168,15 +294,6 @@ func formatterNode(ctx context.Context, input GraphOutput) (GraphOutput, error)
 	return input, nil
 }
 
-func extractJSON(text string) string {
-	start := strings.Index(text, "{")
-	end := strings.LastIndex(text, "}")
-	if start == -1 || end == -1 || end <= start {
-		return ""
-	}
-	return text[start : end+1]
-}
-
 func normalizeList(items []string) []string {
 	out := make([]string, 0, len(items))
 	seen := map[string]bool{}
@@ -204,3 +321,19 @@ func normalizeRelations(items []Relation) []Relation {
 	}
 	return out
 }
+
+// normalizeEntityTypes keeps types for known entities only, lowercased and
+// restricted to EntityTypes.
+func normalizeEntityTypes(types map[string]string, entities []string) map[string]string {
+	out := map[string]string{}
+	for _, ent := range entities {
+		t := strings.ToLower(strings.TrimSpace(types[ent]))
+		for _, valid := range EntityTypes {
+			if t == valid {
+				out[ent] = t
+				break
+			}
+		}
+	}
+	return out
+}
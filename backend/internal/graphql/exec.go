@@ -0,0 +1,337 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// MaxDepth bounds how deeply selections may nest, so a query cannot walk
+// archive → node → archive → … without end.
+const MaxDepth = 12
+
+// MaxListDepth bounds lists nested in lists, each of which multiplies the
+// work done for the ones below it.
+const MaxListDepth = 3
+
+// MaxCost is the budget of one request: every object in the result, and
+// every call of a resolver returning objects (which usually queries the
+// database), costs one. Past the budget fields resolve to null and lists are
+// cut short, with an error saying so.
+const MaxCost = 5000
+
+// Object is an output type. Fields whose Type is nil are leaves and are
+// serialized with encoding/json; the rest need a selection set, and their
+// resolver may return a single source value or a slice of them.
+type Object struct {
+	Name   string
+	Fields map[string]*Field
+}
+
+type Field struct {
+	Type    *Object
+	Resolve func(p Params) (any, error)
+}
+
+type Params struct {
+	Context context.Context
+	Source  any
+	Args    map[string]any
+}
+
+func (p Params) String(name string) string {
+	s, _ := p.Args[name].(string)
+	return s
+}
+
+func (p Params) Int(name string, def int) int {
+	switch v := p.Args[name].(type) {
+	case int:
+		return v
+	case float64:
+		return int(v)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return int(n)
+		}
+	}
+	return def
+}
+
+func (p Params) Bool(name string) bool {
+	b, _ := p.Args[name].(bool)
+	return b
+}
+
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+type Response struct {
+	Data   any     `json:"data,omitempty"`
+	Errors []Error `json:"errors,omitempty"`
+}
+
+// Execute runs a query operation against the root type. Field errors are
+// collected into the response next to whatever data could be resolved.
+func Execute(ctx context.Context, root *Object, req Request) Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	op, err := pickOperation(doc, req.OperationName)
+	if err != nil {
+		return Response{Errors: []Error{{Message: err.Error()}}}
+	}
+	if op.Type != "query" {
+		return Response{Errors: []Error{{Message: "only query operations are supported"}}}
+	}
+	vars := map[string]any{}
+	for _, def := range op.Variables {
+		if v, ok := req.Variables[def.Name]; ok {
+			vars[def.Name] = v
+		} else if def.Default != nil {
+			vars[def.Name] = def.Default.resolve(nil)
+		}
+	}
+	e := &executor{ctx: ctx, doc: doc, vars: vars}
+	data := e.selectionSet(root, nil, op.Selections, nil)
+	return Response{Data: data, Errors: e.errors}
+}
+
+func pickOperation(doc *Document, name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, fmt.Errorf("operationName is required when the document has several operations")
+		}
+		return doc.Operations[0], nil
+	}
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+type executor struct {
+	ctx    context.Context
+	doc    *Document
+	vars   map[string]any
+	errors []Error
+	cost   int
+	// tooNested is set once a list nested too deeply has been reported.
+	tooNested bool
+}
+
+func (e *executor) fail(path []any, format string, args ...any) {
+	e.errors = append(e.errors, Error{Message: fmt.Sprintf(format, args...), Path: path})
+}
+
+// spend charges one unit of MaxCost, reporting the first time it runs out.
+func (e *executor) spend(path []any) bool {
+	e.cost++
+	if e.cost == MaxCost+1 {
+		e.fail(path, "query exceeds the cost budget of %d", MaxCost)
+	}
+	return e.cost <= MaxCost
+}
+
+type fieldGroup struct {
+	key    string
+	fields []Selection
+}
+
+// collect flattens fragments and drops skipped selections, grouping fields
+// by response key in query order.
+func (e *executor) collect(typ *Object, sels []Selection, groups []fieldGroup, index map[string]int, visited map[string]bool) []fieldGroup {
+	for _, sel := range sels {
+		if !e.included(sel.Directives) {
+			continue
+		}
+		switch sel.Kind {
+		case FieldSelection:
+			key := sel.responseKey()
+			if i, ok := index[key]; ok {
+				groups[i].fields = append(groups[i].fields, sel)
+				continue
+			}
+			index[key] = len(groups)
+			groups = append(groups, fieldGroup{key: key, fields: []Selection{sel}})
+		case FragmentSpread:
+			frag := e.doc.Fragments[sel.Name]
+			if frag == nil || visited[sel.Name] || frag.TypeCondition != typ.Name {
+				continue
+			}
+			visited[sel.Name] = true
+			groups = e.collect(typ, frag.Selections, groups, index, visited)
+		case InlineFragment:
+			if sel.TypeCondition != "" && sel.TypeCondition != typ.Name {
+				continue
+			}
+			groups = e.collect(typ, sel.Selections, groups, index, visited)
+		}
+	}
+	return groups
+}
+
+func (e *executor) included(directives []Directive) bool {
+	for _, d := range directives {
+		cond, _ := d.Args["if"].resolve(e.vars).(bool)
+		if (d.Name == "skip" && cond) || (d.Name == "include" && !cond) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *executor) selectionSet(typ *Object, source any, sels []Selection, path []any) *orderedMap {
+	out := &orderedMap{}
+	if fieldDepth(path) > MaxDepth {
+		e.fail(path, "query is nested deeper than %d levels", MaxDepth)
+		return out
+	}
+	for _, group := range e.collect(typ, sels, nil, map[string]int{}, map[string]bool{}) {
+		first := group.fields[0]
+		fieldPath := extend(path, group.key)
+		if first.Name == "__typename" {
+			out.set(group.key, typ.Name)
+			continue
+		}
+		field := typ.Fields[first.Name]
+		if field == nil {
+			e.fail(fieldPath, "cannot query field %q on type %q", first.Name, typ.Name)
+			out.set(group.key, nil)
+			continue
+		}
+		args := map[string]any{}
+		for name, v := range first.Args {
+			args[name] = v.resolve(e.vars)
+		}
+		var sub []Selection
+		for _, f := range group.fields {
+			sub = append(sub, f.Selections...)
+		}
+		if field.Type != nil && !e.spend(fieldPath) {
+			out.set(group.key, nil)
+			continue
+		}
+		value, err := e.resolve(field, Params{Context: e.ctx, Source: source, Args: args}, first.Name)
+		if err != nil {
+			e.fail(fieldPath, "%s", err.Error())
+			out.set(group.key, nil)
+			continue
+		}
+		out.set(group.key, e.complete(field.Type, first.Name, value, sub, fieldPath))
+	}
+	return out
+}
+
+func (e *executor) resolve(field *Field, p Params, name string) (any, error) {
+	if field.Resolve != nil {
+		return field.Resolve(p)
+	}
+	if m, ok := p.Source.(map[string]any); ok {
+		return m[name], nil
+	}
+	return nil, nil
+}
+
+func (e *executor) complete(typ *Object, name string, value any, sub []Selection, path []any) any {
+	if typ == nil {
+		if len(sub) > 0 {
+			e.fail(path, "field %q is a scalar and takes no selection", name)
+			return nil
+		}
+		return value
+	}
+	if len(sub) == 0 {
+		e.fail(path, "field %q of type %q needs a selection", name, typ.Name)
+		return nil
+	}
+	if value == nil {
+		return nil
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil
+	}
+	if rv.Kind() != reflect.Slice {
+		if !e.spend(path) {
+			return nil
+		}
+		return e.selectionSet(typ, value, sub, path)
+	}
+	if len(path)-fieldDepth(path) >= MaxListDepth {
+		if !e.tooNested {
+			e.tooNested = true
+			e.fail(path, "lists are nested deeper than %d levels", MaxListDepth)
+		}
+		return nil
+	}
+	list := make([]any, 0, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		if !e.spend(extend(path, i)) {
+			break
+		}
+		list = append(list, e.selectionSet(typ, rv.Index(i).Interface(), sub, extend(path, i)))
+	}
+	return list
+}
+
+// fieldDepth counts field names in a path, ignoring list indexes.
+func fieldDepth(path []any) int {
+	n := 0
+	for _, elem := range path {
+		if _, ok := elem.(string); ok {
+			n++
+		}
+	}
+	return n
+}
+
+func extend(path []any, elem any) []any {
+	out := make([]any, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, elem)
+}
+
+// orderedMap keeps response keys in query order, as the spec requires.
+type orderedMap struct {
+	keys   []string
+	values []any
+}
+
+func (m *orderedMap) set(key string, value any) {
+	m.keys = append(m.keys, key)
+	m.values = append(m.values, value)
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := json.Marshal(m.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
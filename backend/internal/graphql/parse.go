@@ -0,0 +1,681 @@
+// Package graphql is a small executor for GraphQL query documents. It covers
+// what a read API needs — fields, aliases, arguments, variables, fragments
+// and @skip/@include — and leaves out mutations, subscriptions and
+// introspection.
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+type Operation struct {
+	Type       string
+	Name       string
+	Variables  []VariableDef
+	Selections []Selection
+}
+
+type VariableDef struct {
+	Name    string
+	Default *Value
+}
+
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Selections    []Selection
+}
+
+type SelectionKind int
+
+const (
+	FieldSelection SelectionKind = iota
+	FragmentSpread
+	InlineFragment
+)
+
+// Selection is a field, a named fragment spread (Name is the fragment) or an
+// inline fragment.
+type Selection struct {
+	Kind          SelectionKind
+	Alias         string
+	Name          string
+	Args          map[string]Value
+	Directives    []Directive
+	TypeCondition string
+	Selections    []Selection
+}
+
+func (s Selection) responseKey() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Name
+}
+
+type Directive struct {
+	Name string
+	Args map[string]Value
+}
+
+type ValueKind int
+
+const (
+	VariableValue ValueKind = iota
+	IntValue
+	FloatValue
+	StringValue
+	BooleanValue
+	NullValue
+	EnumValue
+	ListValue
+	ObjectValue
+)
+
+type Value struct {
+	Kind   ValueKind
+	Raw    string
+	List   []Value
+	Fields map[string]Value
+}
+
+// resolve turns a literal into plain Go values (int, float64, string, bool,
+// nil, []any, map[string]any), substituting variables.
+func (v Value) resolve(vars map[string]any) any {
+	switch v.Kind {
+	case VariableValue:
+		return vars[v.Raw]
+	case IntValue:
+		n, _ := strconv.Atoi(v.Raw)
+		return n
+	case FloatValue:
+		f, _ := strconv.ParseFloat(v.Raw, 64)
+		return f
+	case StringValue, EnumValue:
+		return v.Raw
+	case BooleanValue:
+		return v.Raw == "true"
+	case ListValue:
+		out := make([]any, 0, len(v.List))
+		for _, item := range v.List {
+			out = append(out, item.resolve(vars))
+		}
+		return out
+	case ObjectValue:
+		out := make(map[string]any, len(v.Fields))
+		for k, item := range v.Fields {
+			out[k] = item.resolve(vars)
+		}
+		return out
+	}
+	return nil
+}
+
+// Parse reads a query document.
+func Parse(src string) (*Document, error) {
+	p := &parser{lex: lexer{src: src}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	doc := &Document{Fragments: map[string]*Fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.peekPunct("{"):
+			sels, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", Selections: sels})
+		case p.tok.kind == tokName && (p.tok.val == "query" || p.tok.val == "mutation" || p.tok.val == "subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.tok.kind == tokName && p.tok.val == "fragment":
+			frag, err := p.fragment()
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := doc.Fragments[frag.Name]; dup {
+				return nil, fmt.Errorf("duplicate fragment %q", frag.Name)
+			}
+			doc.Fragments[frag.Name] = frag
+		default:
+			return nil, p.unexpected()
+		}
+	}
+	if len(doc.Operations) == 0 {
+		return nil, fmt.Errorf("document has no operations")
+	}
+	return doc, nil
+}
+
+type parser struct {
+	lex lexer
+	tok token
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) peekPunct(s string) bool {
+	return p.tok.kind == tokPunct && p.tok.val == s
+}
+
+func (p *parser) unexpected() error {
+	if p.tok.kind == tokEOF {
+		return fmt.Errorf("syntax error: unexpected end of query")
+	}
+	return fmt.Errorf("syntax error at offset %d: unexpected %q", p.tok.pos, p.tok.val)
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.peekPunct(s) {
+		return p.unexpected()
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokName {
+		return "", p.unexpected()
+	}
+	name := p.tok.val
+	return name, p.advance()
+}
+
+func (p *parser) operation() (*Operation, error) {
+	op := &Operation{Type: p.tok.val}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokName {
+		op.Name = p.tok.val
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peekPunct("(") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		for !p.peekPunct(")") {
+			def, err := p.variableDef()
+			if err != nil {
+				return nil, err
+			}
+			op.Variables = append(op.Variables, def)
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sels, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	op.Selections = sels
+	return op, nil
+}
+
+func (p *parser) variableDef() (VariableDef, error) {
+	if err := p.expectPunct("$"); err != nil {
+		return VariableDef{}, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return VariableDef{}, err
+	}
+	if err := p.expectPunct(":"); err != nil {
+		return VariableDef{}, err
+	}
+	if err := p.skipType(); err != nil {
+		return VariableDef{}, err
+	}
+	def := VariableDef{Name: name}
+	if p.peekPunct("=") {
+		if err := p.advance(); err != nil {
+			return VariableDef{}, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return VariableDef{}, err
+		}
+		def.Default = &v
+	}
+	_, err = p.directives()
+	return def, err
+}
+
+// skipType consumes a type reference; variable types are not checked.
+func (p *parser) skipType() error {
+	if p.peekPunct("[") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+		if err := p.skipType(); err != nil {
+			return err
+		}
+		if err := p.expectPunct("]"); err != nil {
+			return err
+		}
+	} else if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.peekPunct("!") {
+		return p.advance()
+	}
+	return nil
+}
+
+func (p *parser) fragment() (*Fragment, error) {
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokName || p.tok.val != "on" {
+		return nil, p.unexpected()
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	typ, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.directives(); err != nil {
+		return nil, err
+	}
+	sels, err := p.selectionSet()
+	if err != nil {
+		return nil, err
+	}
+	return &Fragment{Name: name, TypeCondition: typ, Selections: sels}, nil
+}
+
+func (p *parser) selectionSet() ([]Selection, error) {
+	if err := p.expectPunct("{"); err != nil {
+		return nil, err
+	}
+	var out []Selection
+	for !p.peekPunct("}") {
+		sel, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sel)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("syntax error: empty selection set")
+	}
+	return out, p.advance()
+}
+
+func (p *parser) selection() (Selection, error) {
+	if p.peekPunct("...") {
+		return p.fragmentSelection()
+	}
+	name, err := p.name()
+	if err != nil {
+		return Selection{}, err
+	}
+	sel := Selection{Kind: FieldSelection, Name: name}
+	if p.peekPunct(":") {
+		if err := p.advance(); err != nil {
+			return Selection{}, err
+		}
+		sel.Alias = name
+		if sel.Name, err = p.name(); err != nil {
+			return Selection{}, err
+		}
+	}
+	if sel.Args, err = p.arguments(); err != nil {
+		return Selection{}, err
+	}
+	if sel.Directives, err = p.directives(); err != nil {
+		return Selection{}, err
+	}
+	if p.peekPunct("{") {
+		if sel.Selections, err = p.selectionSet(); err != nil {
+			return Selection{}, err
+		}
+	}
+	return sel, nil
+}
+
+func (p *parser) fragmentSelection() (Selection, error) {
+	if err := p.advance(); err != nil {
+		return Selection{}, err
+	}
+	sel := Selection{Kind: InlineFragment}
+	if p.tok.kind == tokName && p.tok.val != "on" {
+		sel.Kind = FragmentSpread
+		sel.Name = p.tok.val
+		if err := p.advance(); err != nil {
+			return Selection{}, err
+		}
+		var err error
+		sel.Directives, err = p.directives()
+		return sel, err
+	}
+	if p.tok.kind == tokName {
+		if err := p.advance(); err != nil {
+			return Selection{}, err
+		}
+		typ, err := p.name()
+		if err != nil {
+			return Selection{}, err
+		}
+		sel.TypeCondition = typ
+	}
+	var err error
+	if sel.Directives, err = p.directives(); err != nil {
+		return Selection{}, err
+	}
+	sel.Selections, err = p.selectionSet()
+	return sel, err
+}
+
+func (p *parser) arguments() (map[string]Value, error) {
+	if !p.peekPunct("(") {
+		return nil, nil
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	args := map[string]Value{}
+	for !p.peekPunct(")") {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectPunct(":"); err != nil {
+			return nil, err
+		}
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		args[name] = v
+	}
+	return args, p.advance()
+}
+
+func (p *parser) directives() ([]Directive, error) {
+	var out []Directive
+	for p.peekPunct("@") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, Directive{Name: name, Args: args})
+	}
+	return out, nil
+}
+
+func (p *parser) value() (Value, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokPunct && tok.val == "$":
+		if err := p.advance(); err != nil {
+			return Value{}, err
+		}
+		name, err := p.name()
+		return Value{Kind: VariableValue, Raw: name}, err
+	case tok.kind == tokInt:
+		return Value{Kind: IntValue, Raw: tok.val}, p.advance()
+	case tok.kind == tokFloat:
+		return Value{Kind: FloatValue, Raw: tok.val}, p.advance()
+	case tok.kind == tokString:
+		return Value{Kind: StringValue, Raw: tok.val}, p.advance()
+	case tok.kind == tokName:
+		v := Value{Kind: EnumValue, Raw: tok.val}
+		switch tok.val {
+		case "true", "false":
+			v.Kind = BooleanValue
+		case "null":
+			v.Kind = NullValue
+		}
+		return v, p.advance()
+	case tok.kind == tokPunct && tok.val == "[":
+		if err := p.advance(); err != nil {
+			return Value{}, err
+		}
+		v := Value{Kind: ListValue, List: []Value{}}
+		for !p.peekPunct("]") {
+			item, err := p.value()
+			if err != nil {
+				return Value{}, err
+			}
+			v.List = append(v.List, item)
+		}
+		return v, p.advance()
+	case tok.kind == tokPunct && tok.val == "{":
+		if err := p.advance(); err != nil {
+			return Value{}, err
+		}
+		v := Value{Kind: ObjectValue, Fields: map[string]Value{}}
+		for !p.peekPunct("}") {
+			name, err := p.name()
+			if err != nil {
+				return Value{}, err
+			}
+			if err := p.expectPunct(":"); err != nil {
+				return Value{}, err
+			}
+			item, err := p.value()
+			if err != nil {
+				return Value{}, err
+			}
+			v.Fields[name] = item
+		}
+		return v, p.advance()
+	}
+	return Value{}, p.unexpected()
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokName
+	tokInt
+	tokFloat
+	tokString
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	val  string
+	pos  int
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, pos: l.pos}, nil
+	}
+	start := l.pos
+	ch := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{kind: tokPunct, val: "...", pos: start}, nil
+	case strings.IndexByte("!$&()/:=@[]{|}", ch) >= 0:
+		l.pos++
+		return token{kind: tokPunct, val: string(ch), pos: start}, nil
+	case ch == '_' || isLetter(ch):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{kind: tokName, val: l.src[start:l.pos], pos: start}, nil
+	case ch == '-' || isDigit(ch):
+		return l.number()
+	case ch == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.blockString()
+		}
+		return l.string()
+	}
+	return token{}, fmt.Errorf("syntax error at offset %d: unexpected character %q", start, ch)
+}
+
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch ch := l.src[l.pos]; {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r' || ch == ',':
+			l.pos++
+		case ch == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case strings.HasPrefix(l.src[l.pos:], "\uFEFF"):
+			l.pos += len("\uFEFF")
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) number() (token, error) {
+	start := l.pos
+	kind := tokInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return token{}, fmt.Errorf("syntax error at offset %d: invalid number", start)
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokFloat
+		l.pos++
+		if digits() == 0 {
+			return token{}, fmt.Errorf("syntax error at offset %d: invalid number", start)
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return token{}, fmt.Errorf("syntax error at offset %d: invalid number", start)
+		}
+	}
+	return token{kind: kind, val: l.src[start:l.pos], pos: start}, nil
+}
+
+func (l *lexer) string() (token, error) {
+	start := l.pos
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		ch := l.src[l.pos]
+		switch {
+		case ch == '"':
+			l.pos++
+			return token{kind: tokString, val: b.String(), pos: start}, nil
+		case ch == '\n' || ch == '\r':
+			return token{}, fmt.Errorf("syntax error at offset %d: unterminated string", start)
+		case ch == '\\' && l.pos+1 < len(l.src):
+			esc := l.src[l.pos+1]
+			l.pos += 2
+			switch esc {
+			case '"', '\\', '/':
+				b.WriteByte(esc)
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 't':
+				b.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return token{}, fmt.Errorf("syntax error at offset %d: bad escape", l.pos)
+				}
+				n, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return token{}, fmt.Errorf("syntax error at offset %d: bad escape", l.pos)
+				}
+				b.WriteRune(rune(n))
+				l.pos += 4
+			default:
+				return token{}, fmt.Errorf("syntax error at offset %d: bad escape", l.pos-2)
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(l.src[l.pos:])
+			b.WriteRune(r)
+			l.pos += size
+		}
+	}
+	return token{}, fmt.Errorf("syntax error at offset %d: unterminated string", start)
+}
+
+// blockString keeps the raw contents; escapes and common indentation are
+// not processed.
+func (l *lexer) blockString() (token, error) {
+	start := l.pos
+	l.pos += 3
+	end := strings.Index(l.src[l.pos:], `"""`)
+	if end < 0 {
+		return token{}, fmt.Errorf("syntax error at offset %d: unterminated string", start)
+	}
+	val := l.src[l.pos : l.pos+end]
+	l.pos += end + 3
+	return token{kind: tokString, val: strings.TrimSpace(val), pos: start}, nil
+}
+
+func isLetter(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z')
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// AITrace keeps the exact prompts and raw replies of one classification or
+// extraction run over an archive, recorded while LLM tracing is on.
+type AITrace struct {
+	ID        string         `gorm:"primaryKey;size:36" json:"id"`
+	ArchiveID string         `gorm:"size:36;index" json:"archiveId"`
+	Kind      string         `gorm:"size:16" json:"kind"`
+	Model     string         `gorm:"size:128" json:"model"`
+	Error     string         `gorm:"type:text" json:"error,omitempty"`
+	CallsJSON datatypes.JSON `gorm:"type:json" json:"calls"`
+	CreatedAt time.Time      `gorm:"index" json:"createdAt"`
+}
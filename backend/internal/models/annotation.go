@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Annotation is a passage highlighted in an archive. Start and End are rune
+// offsets into the archive's ContentText, -1 when the quote isn't found
+// there; Prefix and Suffix hold a little of the surrounding page text so the
+// passage can be found again in the snapshot.
+type Annotation struct {
+	ID        string    `gorm:"primaryKey;size:36" json:"id"`
+	ArchiveID string    `gorm:"size:36;index" json:"archiveId"`
+	Quote     string    `gorm:"type:text" json:"quote"`
+	Prefix    string    `gorm:"size:255" json:"prefix"`
+	Suffix    string    `gorm:"size:255" json:"suffix"`
+	Note      string    `gorm:"type:text" json:"note"`
+	Start     int       `json:"start"`
+	End       int       `json:"end"`
+	CreatedBy string    `gorm:"size:128" json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
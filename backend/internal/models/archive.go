@@ -7,26 +7,77 @@ import (
 )
 
 type Archive struct {
-	ID            string         `gorm:"primaryKey;size:36" json:"id"`
-	Title         string         `gorm:"size:500" json:"title"`
-	URL           string         `gorm:"size:2000" json:"url"`
-	SiteName      string         `gorm:"size:255" json:"siteName"`
-	Byline        string         `gorm:"size:255" json:"byline"`
-	Excerpt       string         `gorm:"type:text" json:"excerpt"`
-	Favicon       string         `gorm:"size:2000" json:"favicon"`
-	Category      string         `gorm:"size:255" json:"category"`
-	TagsJSON      datatypes.JSON `gorm:"type:json" json:"tags"`
-	HierarchyJSON datatypes.JSON `gorm:"type:json" json:"hierarchy"`
-	HierarchyPath string         `gorm:"size:512;index" json:"hierarchyPath"`
-	EntitiesJSON  datatypes.JSON `gorm:"type:json" json:"entities"`
-	RelationsJSON datatypes.JSON `gorm:"type:json" json:"relations"`
-	Summary       string         `gorm:"type:text" json:"summary"`
-	ContentText   string         `gorm:"type:longtext" json:"contentText,omitempty"`
-	CapturedAt    *time.Time     `json:"capturedAt"`
-	HTMLPath      string         `gorm:"size:1024" json:"htmlPath"`
-	AssetsJSON    datatypes.JSON `gorm:"type:json" json:"assets"`
-	CreatedAt     time.Time      `json:"createdAt"`
-	UpdatedAt     time.Time      `json:"updatedAt"`
+	ID              string         `gorm:"primaryKey;size:36" json:"id"`
+	Title           string         `gorm:"size:500" json:"title"`
+	URL             string         `gorm:"size:2000" json:"url"`
+	CanonicalURL    string         `gorm:"size:2000;index:idx_archives_canonical_url,length:255" json:"canonicalUrl"`
+	Domain          string         `gorm:"size:255;index" json:"domain"`
+	FinalURL        string         `gorm:"size:2000" json:"finalUrl"`
+	RedirectsJSON   datatypes.JSON `gorm:"type:json" json:"redirects"`
+	SiteName        string         `gorm:"size:255" json:"siteName"`
+	Byline          string         `gorm:"size:255" json:"byline"`
+	Excerpt         string         `gorm:"type:text" json:"excerpt"`
+	Favicon         string         `gorm:"size:2000" json:"favicon"`
+	MetaCheckedAt   *time.Time     `json:"-"`
+	Category        string         `gorm:"size:255" json:"category"`
+	TagsJSON        datatypes.JSON `gorm:"type:json" json:"tags"`
+	HierarchyJSON   datatypes.JSON `gorm:"type:json" json:"hierarchy"`
+	HierarchyPath   string         `gorm:"size:512;index" json:"hierarchyPath"`
+	Slug            *string        `gorm:"size:255;uniqueIndex" json:"slug"`
+	EntitiesJSON    datatypes.JSON `gorm:"type:json" json:"entities"`
+	EntityTypesJSON datatypes.JSON `gorm:"type:json" json:"entityTypes"`
+	RelationsJSON   datatypes.JSON `gorm:"type:json" json:"relations"`
+	Summary         string         `gorm:"type:text" json:"summary"`
+	// Summary styles generated on request or by a capture preset.
+	SummaryOneLiner   string         `gorm:"size:512" json:"summaryOneLiner"`
+	SummaryPointsJSON datatypes.JSON `gorm:"type:json" json:"summaryPoints"`
+	SummaryAbstract   string         `gorm:"type:text" json:"summaryAbstract"`
+	AITaskState       string         `gorm:"size:16;index" json:"-"`
+	AITaskError       string         `gorm:"type:text" json:"-"`
+	AITaskAt          *time.Time     `json:"-"`
+	StructuredType    string         `gorm:"size:16;index" json:"structuredType"`
+	StructuredJSON    datatypes.JSON `gorm:"type:json" json:"structured"`
+	ContentText       string         `gorm:"type:longtext" json:"contentText,omitempty"`
+	WordCount         int            `json:"wordCount"`
+	ReadMinutes       int            `gorm:"index" json:"readMinutes"`
+	SimHash           uint64         `json:"-"`
+	SimBand0          uint16         `gorm:"index" json:"-"`
+	SimBand1          uint16         `gorm:"index" json:"-"`
+	SimBand2          uint16         `gorm:"index" json:"-"`
+	SimBand3          uint16         `gorm:"index" json:"-"`
+	CapturedAt        *time.Time     `gorm:"index" json:"capturedAt"`
+	ViewCount         int            `json:"viewCount"`
+	Published         bool           `gorm:"index" json:"published"`
+	Watched           bool           `gorm:"index" json:"watched"`
+	WatchCheckedAt    *time.Time     `json:"-"`
+	RecaptureDays     int            `gorm:"index" json:"recaptureDays"`
+	RecapturedAt      *time.Time     `gorm:"index" json:"recapturedAt"`
+	LastViewedAt      *time.Time     `gorm:"index" json:"lastViewedAt"`
+	RemindAt          *time.Time     `gorm:"index" json:"remindAt"`
+	RemindNote        string         `gorm:"size:500" json:"remindNote"`
+	RemindedAt        *time.Time     `json:"remindedAt"`
+	HTMLPath          string         `gorm:"size:1024" json:"htmlPath"`
+	HTMLSHA256        string         `gorm:"column:html_sha256;size:64" json:"htmlSha256"`
+	CaptureMode       string         `gorm:"size:16;index" json:"captureMode"`
+	StorageTier       string         `gorm:"size:16;index" json:"storageTier"`
+	StorageBytes      int64          `json:"storageBytes"`
+	Encrypted         bool           `gorm:"index" json:"encrypted"`
+	ScreenshotPath    string         `gorm:"size:255" json:"screenshotPath"`
+	PrintPath         string         `gorm:"size:255" json:"printPath"`
+	DarkPath          string         `gorm:"size:255" json:"darkPath"`
+	DocumentPath      string         `gorm:"size:255" json:"documentPath"`
+	AssetsJSON        datatypes.JSON `gorm:"type:json" json:"assets"`
+	Completeness      *int           `gorm:"index" json:"completeness"`
+	MissingJSON       datatypes.JSON `gorm:"type:json" json:"-"`
+	ReportJSON        datatypes.JSON `gorm:"type:json" json:"-"`
+	CaptureSource     string         `gorm:"size:64;index" json:"captureSource"`
+	ProvenanceJSON    datatypes.JSON `gorm:"type:json" json:"provenance"`
+	CaptureClient     string         `gorm:"size:255" json:"captureClient"`
+	CapturedBy        string         `gorm:"size:128;index" json:"capturedBy"`
+	ClientIP          string         `gorm:"size:64" json:"clientIp"`
+	UserAgent         string         `gorm:"size:512" json:"userAgent"`
+	CreatedAt         time.Time      `gorm:"index" json:"createdAt"`
+	UpdatedAt         time.Time      `json:"updatedAt"`
 }
 
 type ArchivePath struct {
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ArchiveAlias maps the ID of an archive that no longer exists, because it
+// was merged into another one, to the archive that replaced it.
+type ArchiveAlias struct {
+	AliasID   string    `gorm:"primaryKey;size:36" json:"aliasId"`
+	ArchiveID string    `gorm:"size:36;index" json:"archiveId"`
+	CreatedBy string    `gorm:"size:128" json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+type AdminAudit struct {
+	ID         string         `gorm:"primaryKey;size:36" json:"id"`
+	Action     string         `gorm:"size:32;index" json:"action"`
+	Actor      string         `gorm:"size:128;index" json:"actor"`
+	Target     string         `gorm:"size:64" json:"target"`
+	ClientIP   string         `gorm:"size:64" json:"clientIp"`
+	BeforeJSON datatypes.JSON `gorm:"type:json" json:"before"`
+	AfterJSON  datatypes.JSON `gorm:"type:json" json:"after"`
+	CreatedAt  time.Time      `gorm:"index" json:"createdAt"`
+}
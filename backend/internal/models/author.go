@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Author is a writer named in archive bylines. NameKey is the folded name
+// bylines are matched on, so "Jane Doe" and "jane  doe" are one author; Name
+// keeps the spelling first seen.
+type Author struct {
+	ID        string    `gorm:"primaryKey;size:36" json:"id"`
+	NameKey   string    `gorm:"size:255;uniqueIndex" json:"-"`
+	Name      string    `gorm:"size:255" json:"name"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// ArchiveAuthor links an archive to one of the authors of its byline, in
+// the order the byline names them.
+type ArchiveAuthor struct {
+	ArchiveID string `gorm:"primaryKey;size:36" json:"archiveId"`
+	AuthorID  string `gorm:"primaryKey;size:36;index" json:"authorId"`
+	Position  int    `json:"position"`
+}
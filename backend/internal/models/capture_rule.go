@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// CaptureRule classifies captures without the LLM. A rule matches when
+// every condition it sets holds: the page's host is Domain or one of its
+// subdomains, its URL matches the URLPattern regexp and its title contains
+// one of TitleKeywords. Matching rules add their tags, file the capture under
+// Path and pick Preset when the request didn't, and SkipAI keeps the
+// capture out of the auto-tag queue. Rules run in Priority order, lowest
+// first; a matching rule with Stop set ends the evaluation.
+type CaptureRule struct {
+	ID                string         `gorm:"primaryKey;size:36" json:"id"`
+	Name              string         `gorm:"size:128" json:"name"`
+	Priority          int            `gorm:"index" json:"priority"`
+	Domain            string         `gorm:"size:255" json:"domain"`
+	URLPattern        string         `gorm:"size:512" json:"urlPattern"`
+	TitleKeywordsJSON datatypes.JSON `gorm:"type:json" json:"titleKeywords"`
+	TagsJSON          datatypes.JSON `gorm:"type:json" json:"tags"`
+	Path              string         `gorm:"size:512" json:"path"`
+	Preset            string         `gorm:"size:128" json:"preset"`
+	SkipAI            bool           `json:"skipAi"`
+	Stop              bool           `json:"stop"`
+	Enabled           bool           `json:"enabled"`
+	Matches           int64          `json:"matches"`
+	LastMatchedAt     *time.Time     `json:"lastMatchedAt"`
+	CreatedAt         time.Time      `json:"createdAt"`
+	UpdatedAt         time.Time      `json:"updatedAt"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// ArchiveCluster is one group from the latest clustering run; every run
+// replaces the whole set.
+type ArchiveCluster struct {
+	ID             string         `gorm:"primaryKey;size:36" json:"id"`
+	Model          string         `gorm:"size:128" json:"model"`
+	Label          string         `gorm:"size:255" json:"label"`
+	Size           int            `json:"size"`
+	ArchiveIDsJSON datatypes.JSON `gorm:"type:json" json:"archiveIds"`
+	CreatedAt      time.Time      `json:"createdAt"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// CompatID gives an archive the integer ID that third-party APIs (Wallabag)
+// require. Rows are created on first use.
+type CompatID struct {
+	Seq       uint      `gorm:"primaryKey;autoIncrement" json:"seq"`
+	ArchiveID string    `gorm:"size:36;uniqueIndex" json:"archiveId"`
+	CreatedAt time.Time `json:"createdAt"`
+}
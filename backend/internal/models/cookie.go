@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// DomainCookie holds cookies configured for a site so server-side fetches
+// can reach login-gated pages. Cookies is encrypted with the settings key.
+type DomainCookie struct {
+	ID        string    `gorm:"primaryKey;size:36" json:"id"`
+	Domain    string    `gorm:"size:255;uniqueIndex" json:"domain"`
+	Cookies   string    `gorm:"type:text" json:"-"`
+	CreatedBy string    `gorm:"size:128" json:"createdBy"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// DashboardPin is one item on a user's dashboard. Ref holds the taxonomy node
+// ID, tag or search query depending on Kind; collections keep their archives
+// in ArchiveIDsJSON instead.
+type DashboardPin struct {
+	ID             string         `gorm:"primaryKey;size:36" json:"id"`
+	Owner          string         `gorm:"size:64;index" json:"-"`
+	Kind           string         `gorm:"size:16" json:"kind"`
+	Label          string         `gorm:"size:255" json:"label"`
+	Ref            string         `gorm:"size:512" json:"ref"`
+	ArchiveIDsJSON datatypes.JSON `gorm:"type:json" json:"archiveIds"`
+	Position       int            `json:"position"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+type Digest struct {
+	ID            string         `gorm:"primaryKey;size:36" json:"id"`
+	Period        string         `gorm:"size:16;uniqueIndex:idx_digest_period" json:"period"`
+	PeriodStart   time.Time      `gorm:"uniqueIndex:idx_digest_period" json:"periodStart"`
+	PeriodEnd     time.Time      `json:"periodEnd"`
+	ArchiveCount  int            `json:"archiveCount"`
+	Summary       string         `gorm:"type:text" json:"summary"`
+	ThemesJSON    datatypes.JSON `gorm:"type:json" json:"themes"`
+	NotableJSON   datatypes.JSON `gorm:"type:json" json:"notable"`
+	FollowUpsJSON datatypes.JSON `gorm:"type:json" json:"followUps"`
+	RemindersJSON datatypes.JSON `gorm:"type:json" json:"reminders"`
+	Generator     string         `gorm:"size:16" json:"generator"`
+	CreatedAt     time.Time      `json:"createdAt"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ArchiveEmbedding caches one vector per archive and embedding model.
+// ContentHash is the hash of the embedded text, so unchanged archives are
+// never re-embedded.
+type ArchiveEmbedding struct {
+	ArchiveID   string    `gorm:"primaryKey;size:36" json:"archiveId"`
+	Model       string    `gorm:"primaryKey;size:128" json:"model"`
+	ContentHash string    `gorm:"size:64" json:"contentHash"`
+	Dims        int       `json:"dims"`
+	Vector      []byte    `gorm:"type:mediumblob" json:"-"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
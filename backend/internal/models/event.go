@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+type ArchiveEvent struct {
+	ID         string         `gorm:"primaryKey;size:36" json:"id"`
+	ArchiveID  string         `gorm:"size:36;index" json:"archiveId"`
+	Action     string         `gorm:"size:32;index" json:"action"`
+	Actor      string         `gorm:"size:64" json:"actor"`
+	BeforeJSON datatypes.JSON `gorm:"type:json" json:"before"`
+	AfterJSON  datatypes.JSON `gorm:"type:json" json:"after"`
+	CreatedAt  time.Time      `gorm:"index" json:"createdAt"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type Flashcard struct {
+	ID        string    `gorm:"primaryKey;size:36" json:"id"`
+	ArchiveID string    `gorm:"size:36;index" json:"archiveId"`
+	Question  string    `gorm:"type:text" json:"question"`
+	Answer    string    `gorm:"type:text" json:"answer"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// GraphSnapshot is a saved copy of the computed graph, so later graphs can be
+// compared with it. Query is the /api/graph query string it was computed
+// with.
+type GraphSnapshot struct {
+	ID        string         `gorm:"primaryKey;size:36" json:"id"`
+	Label     string         `gorm:"size:255" json:"label"`
+	Query     string         `gorm:"size:1024" json:"query"`
+	NodeCount int            `json:"nodeCount"`
+	LinkCount int            `json:"linkCount"`
+	GraphJSON datatypes.JSON `gorm:"type:json" json:"-"`
+	CreatedBy string         `gorm:"size:64" json:"createdBy"`
+	CreatedAt time.Time      `gorm:"index" json:"createdAt"`
+}
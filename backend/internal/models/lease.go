@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Lease is a named lock one backend instance holds until ExpiresAt, so a
+// job runs on a single replica. The holder keeps its progress in State for
+// the other replicas to report; StopRequested asks it to stop.
+type Lease struct {
+	Name          string         `gorm:"primaryKey;size:64" json:"name"`
+	Holder        string         `gorm:"size:128" json:"holder"`
+	ExpiresAt     time.Time      `json:"expiresAt"`
+	StopRequested bool           `json:"stopRequested"`
+	State         datatypes.JSON `gorm:"type:json" json:"state"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+}
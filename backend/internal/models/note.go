@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// Note is a user-written synthesis that ties several archives and entities
+// together. Body is markdown.
+type Note struct {
+	ID             string         `gorm:"primaryKey;size:36" json:"id"`
+	Title          string         `gorm:"size:500" json:"title"`
+	Body           string         `gorm:"type:longtext" json:"body"`
+	ArchiveIDsJSON datatypes.JSON `gorm:"type:json" json:"archiveIds"`
+	EntitiesJSON   datatypes.JSON `gorm:"type:json" json:"entities"`
+	CreatedBy      string         `gorm:"size:64" json:"createdBy"`
+	CreatedAt      time.Time      `gorm:"index" json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+}
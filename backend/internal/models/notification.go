@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Notification tells a user about something that went wrong in the
+// background on their behalf, such as a queued capture or auto-tagging
+// that gave up. ArchiveID and URL are set when known.
+type Notification struct {
+	ID        string     `gorm:"primaryKey;size:36" json:"id"`
+	Username  string     `gorm:"size:128;index" json:"-"`
+	Kind      string     `gorm:"size:32;index" json:"kind"`
+	ArchiveID string     `gorm:"size:36;index" json:"archiveId,omitempty"`
+	URL       string     `gorm:"size:2000" json:"url,omitempty"`
+	Title     string     `gorm:"size:500" json:"title"`
+	Message   string     `gorm:"type:text" json:"message"`
+	ReadAt    *time.Time `gorm:"index" json:"readAt"`
+	CreatedAt time.Time  `gorm:"index" json:"createdAt"`
+}
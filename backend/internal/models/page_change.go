@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// PageChange records a significant difference between a watched archive
+// and its live page. ContentText holds the live text at that point, which
+// becomes the baseline for the next check.
+type PageChange struct {
+	ID            string         `gorm:"primaryKey;size:36" json:"id"`
+	ArchiveID     string         `gorm:"size:36;index" json:"archiveId"`
+	ChangePercent float64        `json:"changePercent"`
+	AddedLines    int            `json:"addedLines"`
+	RemovedLines  int            `json:"removedLines"`
+	AddedJSON     datatypes.JSON `gorm:"type:json" json:"added"`
+	RemovedJSON   datatypes.JSON `gorm:"type:json" json:"removed"`
+	ContentText   string         `gorm:"type:longtext" json:"-"`
+	DetectedAt    time.Time      `gorm:"index" json:"detectedAt"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+type CapturePreset struct {
+	ID                  string         `gorm:"primaryKey;size:36" json:"id"`
+	Name                string         `gorm:"size:128;uniqueIndex" json:"name"`
+	AutoTag             bool           `json:"autoTag"`
+	RenderMode          string         `gorm:"size:32" json:"renderMode"`
+	DefaultTagsJSON     datatypes.JSON `gorm:"type:json" json:"defaultTags"`
+	DefaultPath         string         `gorm:"size:512" json:"defaultPath"`
+	AssetPolicy         string         `gorm:"size:32" json:"assetPolicy"`
+	Profile             string         `gorm:"size:16" json:"profile"`
+	BlockTrackers       *bool          `json:"blockTrackers"`
+	RemoveSelectorsJSON datatypes.JSON `gorm:"type:json" json:"removeSelectors"`
+	SummaryStylesJSON   datatypes.JSON `gorm:"type:json" json:"summaryStyles"`
+	CreatedAt           time.Time      `json:"createdAt"`
+	UpdatedAt           time.Time      `json:"updatedAt"`
+}
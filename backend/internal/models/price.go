@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// PricePoint is one observed price of a product archive. A point is only
+// added when the price, currency or availability changes, so the series is
+// a step function; CheckedAt moves forward while it stays the same.
+type PricePoint struct {
+	ID           string    `gorm:"primaryKey;size:36" json:"id"`
+	ArchiveID    string    `gorm:"size:36;index" json:"archiveId"`
+	Price        string    `gorm:"size:32" json:"price"`
+	Amount       float64   `json:"amount"`
+	Currency     string    `gorm:"size:8" json:"currency"`
+	Availability string    `gorm:"size:64" json:"availability"`
+	ObservedAt   time.Time `gorm:"index" json:"observedAt"`
+	CheckedAt    time.Time `json:"checkedAt"`
+}
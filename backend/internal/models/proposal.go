@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// AnalysisProposal is a classification staged by a dry-run analysis. It
+// holds the values the analyzer would have written; ColumnsJSON lists which
+// of them it sets, since the tag-only path leaves entities and summary alone.
+type AnalysisProposal struct {
+	ArchiveID       string         `gorm:"primaryKey;size:36" json:"archiveId"`
+	Model           string         `gorm:"size:255" json:"model"`
+	ColumnsJSON     datatypes.JSON `gorm:"type:json" json:"columns"`
+	Category        string         `gorm:"size:255" json:"category"`
+	TagsJSON        datatypes.JSON `gorm:"type:json" json:"tags"`
+	HierarchyJSON   datatypes.JSON `gorm:"type:json" json:"hierarchy"`
+	HierarchyPath   string         `gorm:"size:512" json:"hierarchyPath"`
+	EntitiesJSON    datatypes.JSON `gorm:"type:json" json:"entities"`
+	EntityTypesJSON datatypes.JSON `gorm:"type:json" json:"entityTypes"`
+	RelationsJSON   datatypes.JSON `gorm:"type:json" json:"relations"`
+	Summary         string         `gorm:"type:text" json:"summary"`
+	CreatedAt       time.Time      `gorm:"index" json:"createdAt"`
+}
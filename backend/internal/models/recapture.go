@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// RecaptureRule re-captures archives matching Tag and/or PathPrefix whose
+// latest snapshot is older than EveryDays. Archives with their own
+// RecaptureDays are left to that setting.
+type RecaptureRule struct {
+	ID           string     `gorm:"primaryKey;size:36" json:"id"`
+	Name         string     `gorm:"size:128" json:"name"`
+	Tag          string     `gorm:"size:128" json:"tag"`
+	PathPrefix   string     `gorm:"size:512" json:"pathPrefix"`
+	EveryDays    int        `json:"everyDays"`
+	Enabled      bool       `json:"enabled"`
+	LastRunAt    *time.Time `json:"lastRunAt"`
+	LastAffected int        `json:"lastAffected"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+}
+
+// ArchiveVersion is a later snapshot of an archive. Its HTML and assets are
+// stored under its own ID, the same layout as an archive's.
+type ArchiveVersion struct {
+	ID           string    `gorm:"primaryKey;size:36" json:"id"`
+	ArchiveID    string    `gorm:"size:36;index" json:"archiveId"`
+	Title        string    `gorm:"size:500" json:"title"`
+	FinalURL     string    `gorm:"size:2000" json:"finalUrl"`
+	HTMLSHA256   string    `gorm:"column:html_sha256;size:64" json:"htmlSha256"`
+	StorageBytes int64     `json:"storageBytes"`
+	Completeness int       `json:"completeness"`
+	Actor        string    `gorm:"size:128" json:"actor"`
+	CapturedAt   time.Time `gorm:"index" json:"capturedAt"`
+}
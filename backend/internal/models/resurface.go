@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// ResurfaceScore is the latest resurfacing score for an archive, rebuilt
+// periodically by the resurface job. The component scores are kept so the
+// UI can say why something was picked.
+type ResurfaceScore struct {
+	ArchiveID  string    `gorm:"primaryKey;size:36" json:"archiveId"`
+	Score      float64   `gorm:"index" json:"score"`
+	Age        float64   `json:"age"`
+	Unread     float64   `json:"unread"`
+	Centrality float64   `json:"centrality"`
+	Affinity   float64   `json:"affinity"`
+	ComputedAt time.Time `json:"computedAt"`
+}
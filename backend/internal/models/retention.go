@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// RetentionRule prunes archives matching Tag and/or PathPrefix once they are
+// older than OlderThanDays. New rules start disabled so they can be checked
+// with the dry-run report first.
+type RetentionRule struct {
+	ID            string     `gorm:"primaryKey;size:36" json:"id"`
+	Name          string     `gorm:"size:128" json:"name"`
+	Tag           string     `gorm:"size:128" json:"tag"`
+	PathPrefix    string     `gorm:"size:512" json:"pathPrefix"`
+	OlderThanDays int        `json:"olderThanDays"`
+	Action        string     `gorm:"size:32" json:"action"`
+	Enabled       bool       `json:"enabled"`
+	LastRunAt     *time.Time `json:"lastRunAt"`
+	LastAffected  int        `json:"lastAffected"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
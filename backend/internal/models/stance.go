@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
+
+// StanceCheck records one LLM comparison of two archives that share
+// entities. ArchiveA sorts before ArchiveB. ClaimsJSON lists the agreeing
+// and contradicting claims found; a pair with none is kept too, so it isn't
+// compared again.
+type StanceCheck struct {
+	ID          string         `gorm:"primaryKey;size:36" json:"id"`
+	ArchiveA    string         `gorm:"size:36;uniqueIndex:idx_stance_pair" json:"archiveA"`
+	ArchiveB    string         `gorm:"size:36;uniqueIndex:idx_stance_pair;index" json:"archiveB"`
+	SharedJSON  datatypes.JSON `gorm:"type:json" json:"shared"`
+	ClaimsJSON  datatypes.JSON `gorm:"type:json" json:"claims"`
+	Supports    int            `json:"supports"`
+	Contradicts int            `gorm:"index" json:"contradicts"`
+	Model       string         `gorm:"size:128" json:"model"`
+	CheckedAt   time.Time      `gorm:"index" json:"checkedAt"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// StatsSnapshot records how far the database and the object store have
+// drifted apart at one point in time, so the counts can be followed as a
+// trend.
+type StatsSnapshot struct {
+	ID                     string    `gorm:"primaryKey;size:36" json:"id"`
+	Archives               int64     `json:"archives"`
+	ArchivesWithoutObjects int64     `json:"archivesWithoutObjects"`
+	ObjectsWithoutArchives int64     `json:"objectsWithoutArchives"`
+	ArchivesMissingContent int64     `json:"archivesMissingContent"`
+	PathsWithoutNodes      int64     `json:"pathsWithoutNodes"`
+	TakenAt                time.Time `gorm:"index" json:"takenAt"`
+}
@@ -1,13 +1,28 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/datatypes"
+)
 
 type TaxonomyNode struct {
-	ID        string    `gorm:"primaryKey;size:36" json:"id"`
-	Label     string    `gorm:"size:255;index" json:"label"`
-	ParentID  *string   `gorm:"size:36;index" json:"parentId"`
-	Path      string    `gorm:"size:512;uniqueIndex" json:"path"`
-	Level     int       `json:"level"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	ID       string  `gorm:"primaryKey;size:36" json:"id"`
+	Label    string  `gorm:"size:255;index" json:"label"`
+	ParentID *string `gorm:"size:36;index" json:"parentId"`
+	Path     string  `gorm:"size:512;uniqueIndex" json:"path"`
+	Level    int     `json:"level"`
+	// LabelsJSON maps language codes to the label in that language and
+	// AliasesJSON lists other names for the node; labels matching either are
+	// filed here instead of forking a sibling.
+	LabelsJSON  datatypes.JSON `gorm:"type:json" json:"labels"`
+	AliasesJSON datatypes.JSON `gorm:"type:json" json:"aliases"`
+	// Description is written by users; Overview is generated by the LLM and
+	// only valid while the node's membership still hashes to OverviewKey.
+	Description string     `gorm:"type:text" json:"description"`
+	Overview    string     `gorm:"type:text" json:"overview"`
+	OverviewKey string     `gorm:"size:64" json:"-"`
+	OverviewAt  *time.Time `json:"overviewAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
 }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// TaxonomyWebhook is POSTed whenever an archive is filed under Path or one
+// of its descendants.
+type TaxonomyWebhook struct {
+	ID         string     `gorm:"primaryKey;size:36" json:"id"`
+	Path       string     `gorm:"size:512;index" json:"path"`
+	URL        string     `gorm:"size:1024" json:"url"`
+	LastSentAt *time.Time `json:"lastSentAt"`
+	LastError  string     `gorm:"size:512" json:"lastError"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
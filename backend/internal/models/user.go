@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type User struct {
+	ID           string    `gorm:"primaryKey;size:36" json:"id"`
+	Username     string    `gorm:"size:128;uniqueIndex" json:"username"`
+	PasswordHash string    `gorm:"size:255" json:"-"`
+	Role         string    `gorm:"size:16" json:"role"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+}
+
+type APIToken struct {
+	ID         string     `gorm:"primaryKey;size:36" json:"id"`
+	UserID     string     `gorm:"size:36;index" json:"userId"`
+	Name       string     `gorm:"size:128" json:"name"`
+	TokenHash  string     `gorm:"size:64;uniqueIndex" json:"-"`
+	Prefix     string     `gorm:"size:16" json:"prefix"`
+	Scopes     string     `gorm:"size:128" json:"-"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+// PairingCode is a short-lived code shown in the web UI that a device (the
+// browser extension) exchanges once for an API token.
+type PairingCode struct {
+	ID        string    `gorm:"primaryKey;size:36" json:"id"`
+	UserID    string    `gorm:"size:36;index" json:"userId"`
+	CodeHash  string    `gorm:"size:64;uniqueIndex" json:"-"`
+	Scopes    string    `gorm:"size:128" json:"-"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ViewEvent is one user opening an archive's page. Opens shortly after the
+// previous one by the same user move its ViewedAt instead of adding a row.
+type ViewEvent struct {
+	ID        string    `gorm:"primaryKey;size:36" json:"id"`
+	ArchiveID string    `gorm:"size:36;index" json:"archiveId"`
+	Username  string    `gorm:"size:128;index" json:"username"`
+	ViewedAt  time.Time `gorm:"index" json:"viewedAt"`
+}
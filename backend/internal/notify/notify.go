@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+type SMTPConfig struct {
+	Addr     string
+	Username string
+	Password string
+	From     string
+}
+
+func (c SMTPConfig) Enabled() bool {
+	return c.Addr != "" && c.From != ""
+}
+
+// Webhook POSTs payload as JSON and treats any non-2xx answer as an error.
+func Webhook(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook status: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Attachment is a file sent along with an email.
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// Email sends a plain-text UTF-8 message, with attachments as a
+// multipart/mixed message. Auth is only attempted when a username is
+// configured.
+func Email(cfg SMTPConfig, to []string, subject, text string, attachments ...Attachment) error {
+	if !cfg.Enabled() {
+		return fmt.Errorf("smtp not configured")
+	}
+	var auth smtp.Auth
+	if cfg.Username != "" {
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			return err
+		}
+		auth = smtp.PlainAuth("", cfg.Username, cfg.Password, host)
+	}
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&msg, "Subject: =?UTF-8?B?%s?=\r\n", base64String(subject))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	body := strings.ReplaceAll(text, "\n", "\r\n")
+	if len(attachments) == 0 {
+		msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		msg.WriteString(body)
+		return smtp.SendMail(cfg.Addr, auth, cfg.From, to, []byte(msg.String()))
+	}
+	var parts bytes.Buffer
+	mw := multipart.NewWriter(&parts)
+	fmt.Fprintf(&msg, "Content-Type: multipart/mixed; boundary=%q\r\n\r\n", mw.Boundary())
+	pw, err := mw.CreatePart(textproto.MIMEHeader{"Content-Type": {"text/plain; charset=UTF-8"}})
+	if err != nil {
+		return err
+	}
+	io.WriteString(pw, body)
+	for _, a := range attachments {
+		pw, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {a.ContentType},
+			"Content-Transfer-Encoding": {"base64"},
+			"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename})},
+		})
+		if err != nil {
+			return err
+		}
+		// Lines of encoded data must stay under the SMTP line limit.
+		encoded := base64.StdEncoding.EncodeToString(a.Data)
+		for len(encoded) > 76 {
+			io.WriteString(pw, encoded[:76]+"\r\n")
+			encoded = encoded[76:]
+		}
+		io.WriteString(pw, encoded+"\r\n")
+	}
+	if err := mw.Close(); err != nil {
+		return err
+	}
+	msg.Write(parts.Bytes())
+	return smtp.SendMail(cfg.Addr, auth, cfg.From, to, []byte(msg.String()))
+}
+
+func base64String(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
@@ -0,0 +1,265 @@
+package processor
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// AnnotationAnchor prefixes the ids of annotation marks, numbered in the
+// order the quotes are given.
+const AnnotationAnchor = "webarchive-note-"
+
+const annotationStyle = "mark.webarchive-note{background:#c3f0ca;color:inherit;padding:0;border-bottom:2px solid #2da44e}"
+
+// maxQuoteCandidates bounds the occurrences of a quote weighed against its
+// prefix and suffix.
+const maxQuoteCandidates = 200
+
+// Quote is a passage the user selected, with a little of the text around
+// it to tell repeated passages apart.
+type Quote struct {
+	Exact  string
+	Prefix string
+	Suffix string
+	// ID and Note are copied onto the rendered mark.
+	ID   string
+	Note string
+}
+
+// quoteText is a run of text split into segments, text nodes or a single
+// string, with whitespace dropped: a browser's selection text puts line
+// breaks between blocks where the markup has none, and collapses the
+// whitespace it does have, so quotes are matched on the other characters
+// alone. pos maps each byte of flat back to its segment and offset.
+type quoteText struct {
+	flat string
+	pos  []quotePos
+}
+
+type quotePos struct{ seg, off int }
+
+func newQuoteText(segments []string) quoteText {
+	var b strings.Builder
+	var pos []quotePos
+	for i, s := range segments {
+		for off, r := range s {
+			if unicode.IsSpace(r) {
+				continue
+			}
+			n := utf8.RuneLen(r)
+			if r == utf8.RuneError {
+				n = 1
+			}
+			b.WriteString(s[off : off+n])
+			for k := 0; k < n; k++ {
+				pos = append(pos, quotePos{i, off + k})
+			}
+		}
+	}
+	return quoteText{flat: b.String(), pos: pos}
+}
+
+func stripSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// find returns the segment positions of q's first byte and one past its
+// last, preferring the occurrence whose surroundings match q's prefix and
+// suffix best.
+func (t quoteText) find(q Quote) (start, end quotePos, ok bool) {
+	exact := stripSpace(q.Exact)
+	if exact == "" {
+		return quotePos{}, quotePos{}, false
+	}
+	prefix, suffix := stripSpace(q.Prefix), stripSpace(q.Suffix)
+	best, bestScore := -1, -1
+	for from, seen := 0, 0; seen < maxQuoteCandidates; seen++ {
+		i := strings.Index(t.flat[from:], exact)
+		if i < 0 {
+			break
+		}
+		i += from
+		score := 0
+		if prefix != "" && strings.HasSuffix(t.flat[:i], prefix) {
+			score++
+		}
+		if suffix != "" && strings.HasPrefix(t.flat[i+len(exact):], suffix) {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+		_, n := utf8.DecodeRuneInString(t.flat[i:])
+		from = i + n
+	}
+	if best < 0 {
+		return quotePos{}, quotePos{}, false
+	}
+	last := t.pos[best+len(exact)-1]
+	return t.pos[best], quotePos{last.seg, last.off + 1}, true
+}
+
+// LocateQuote returns the rune offsets of q within text, or -1, -1 when the
+// text doesn't contain it.
+func LocateQuote(text string, q Quote) (int, int) {
+	start, end, ok := newQuoteText([]string{text}).find(q)
+	if !ok {
+		return -1, -1
+	}
+	return utf8.RuneCountInString(text[:start.off]), utf8.RuneCountInString(text[:end.off])
+}
+
+type quoteMark struct {
+	start, end int
+	quote      int
+	// first marks the quote's opening span, which carries the anchor id.
+	first bool
+}
+
+// MarkQuotes wraps each quote's first best match in the page's body text
+// with <mark> elements, one per text node it spans, and adds their style.
+// Quotes that can't be found, or that overlap one marked earlier, are
+// skipped. It returns the rewritten page and the number of quotes marked.
+func MarkQuotes(page []byte, quotes []Quote) ([]byte, int, error) {
+	if len(quotes) == 0 {
+		return page, 0, nil
+	}
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, 0, err
+	}
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	var nodes []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			switch c.Type {
+			case html.ElementNode:
+				switch c.DataAtom {
+				case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Textarea, atom.Title, atom.Svg:
+				default:
+					walk(c)
+				}
+			case html.TextNode:
+				nodes = append(nodes, c)
+			}
+		}
+	}
+	walk(body)
+	segments := make([]string, len(nodes))
+	for i, n := range nodes {
+		segments[i] = n.Data
+	}
+	text := newQuoteText(segments)
+
+	marks := map[int][]quoteMark{}
+	overlaps := func(seg, start, end int) bool {
+		for _, m := range marks[seg] {
+			if start < m.end && m.start < end {
+				return true
+			}
+		}
+		return false
+	}
+	count := 0
+	for qi, q := range quotes {
+		start, end, ok := text.find(q)
+		if !ok {
+			continue
+		}
+		type span struct {
+			seg int
+			quoteMark
+		}
+		var spans []span
+		clash := false
+		for seg := start.seg; seg <= end.seg; seg++ {
+			from, to := 0, len(segments[seg])
+			if seg == start.seg {
+				from = start.off
+			}
+			if seg == end.seg {
+				to = end.off
+			}
+			if strings.TrimSpace(segments[seg][from:to]) == "" {
+				continue
+			}
+			if overlaps(seg, from, to) {
+				clash = true
+				break
+			}
+			spans = append(spans, span{seg, quoteMark{from, to, qi, len(spans) == 0}})
+		}
+		if clash || len(spans) == 0 {
+			continue
+		}
+		for _, sp := range spans {
+			marks[sp.seg] = append(marks[sp.seg], sp.quoteMark)
+		}
+		count++
+	}
+	if count == 0 {
+		return page, 0, nil
+	}
+	for seg, ms := range marks {
+		sort.Slice(ms, func(i, j int) bool { return ms[i].start < ms[j].start })
+		splitMarks(nodes[seg], ms, quotes)
+	}
+	if head := findElement(doc, atom.Head); head != nil {
+		style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: annotationStyle})
+		head.AppendChild(style)
+	}
+	var out bytes.Buffer
+	if err := html.Render(&out, doc); err != nil {
+		return nil, 0, err
+	}
+	return out.Bytes(), count, nil
+}
+
+// splitMarks replaces text with plain text and <mark> nodes for the sorted,
+// non-overlapping ranges in marks.
+func splitMarks(text *html.Node, marks []quoteMark, quotes []Quote) {
+	parent, data, last := text.Parent, text.Data, 0
+	for _, m := range marks {
+		if m.start > last {
+			parent.InsertBefore(&html.Node{Type: html.TextNode, Data: data[last:m.start]}, text)
+		}
+		q := quotes[m.quote]
+		attrs := []html.Attribute{
+			{Key: "class", Val: "webarchive-note"},
+		}
+		if m.first {
+			attrs = append(attrs, html.Attribute{Key: "id", Val: AnnotationAnchor + strconv.Itoa(m.quote)})
+		}
+		if q.ID != "" {
+			attrs = append(attrs, html.Attribute{Key: "data-annotation", Val: q.ID})
+		}
+		if q.Note != "" {
+			attrs = append(attrs, html.Attribute{Key: "title", Val: q.Note})
+		}
+		mark := &html.Node{Type: html.ElementNode, Data: "mark", DataAtom: atom.Mark, Attr: attrs}
+		mark.AppendChild(&html.Node{Type: html.TextNode, Data: data[m.start:m.end]})
+		parent.InsertBefore(mark, text)
+		last = m.end
+	}
+	if last < len(data) {
+		parent.InsertBefore(&html.Node{Type: html.TextNode, Data: data[last:]}, text)
+	}
+	parent.RemoveChild(text)
+}
@@ -0,0 +1,175 @@
+package processor
+
+import (
+	"bufio"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// defaultTrackers are analytics and ad hosts common enough to block without
+// a list file.
+var defaultTrackers = []string{
+	"google-analytics.com", "googletagmanager.com", "googletagservices.com", "doubleclick.net",
+	"googlesyndication.com", "adservice.google.com", "connect.facebook.net", "facebook.com/tr",
+	"scorecardresearch.com", "quantserve.com", "hotjar.com", "mixpanel.com", "segment.com",
+	"segment.io", "amplitude.com", "criteo.com", "criteo.net", "taboola.com", "outbrain.com",
+	"adnxs.com", "amazon-adsystem.com", "moatads.com", "chartbeat.com", "newrelic.com",
+	"nr-data.net", "hm.baidu.com", "cnzz.com", "umeng.com", "mc.yandex.ru", "ads-twitter.com",
+	"analytics.twitter.com", "bat.bing.com", "clarity.ms", "matomo.cloud", "pubmatic.com",
+	"rubiconproject.com", "openx.net", "adsrvr.org", "bluekai.com", "krxd.net",
+}
+
+var scriptHost = regexp.MustCompile(`(?i)//([a-z0-9][a-z0-9.-]*\.[a-z]{2,})(/[^\s'"]*)?`)
+
+// Blocklist matches URLs against host rules: a rule blocks its host and all
+// subdomains, and a rule with a path ("facebook.com/tr") only URLs under it.
+type Blocklist struct {
+	hosts map[string][]string
+}
+
+// NewBlocklist returns the built-in tracker list.
+func NewBlocklist() *Blocklist {
+	b := &Blocklist{hosts: map[string][]string{}}
+	for _, rule := range defaultTrackers {
+		b.add(rule)
+	}
+	return b
+}
+
+func (b *Blocklist) add(rule string) {
+	host, path, _ := strings.Cut(strings.ToLower(rule), "/")
+	if host == "" {
+		return
+	}
+	prefix := ""
+	if path != "" {
+		prefix = "/" + path
+	}
+	b.hosts[host] = append(b.hosts[host], prefix)
+}
+
+// Load adds the domain rules of an EasyList-style filter list
+// ("||ads.example.com^", optionally with $options) or a hosts file
+// ("0.0.0.0 ads.example.com"). Element hiding, exception and pattern rules
+// are skipped: only whole hosts can be decided from a URL alone.
+func (b *Blocklist) Load(r io.Reader) (int, error) {
+	added := 0
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "[") {
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "||"); ok {
+			rest, _, _ = strings.Cut(rest, "$")
+			host, tail, found := strings.Cut(rest, "^")
+			if !found || strings.Trim(tail, "|") != "" || strings.ContainsAny(host, "*/") {
+				continue
+			}
+			b.add(host)
+			added++
+			continue
+		}
+		if fields := strings.Fields(line); len(fields) == 2 && (fields[0] == "0.0.0.0" || fields[0] == "127.0.0.1") && fields[1] != "localhost" {
+			b.add(fields[1])
+			added++
+		}
+	}
+	return added, scanner.Err()
+}
+
+// Blocks reports whether rawURL points at a listed host.
+func (b *Blocklist) Blocks(rawURL string) bool {
+	if b == nil {
+		return false
+	}
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || u.Host == "" {
+		return false
+	}
+	return b.matches(strings.ToLower(u.Hostname()), strings.ToLower(u.Path))
+}
+
+func (b *Blocklist) matches(host, path string) bool {
+	for host != "" {
+		for _, prefix := range b.hosts[host] {
+			if prefix == "" || strings.HasPrefix(path, prefix) {
+				return true
+			}
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok || !strings.Contains(parent, ".") {
+			return false
+		}
+		host = parent
+	}
+	return false
+}
+
+// mentionsBlocked reports whether inline script or noscript text loads a
+// listed host, as analytics snippets do.
+func (b *Blocklist) mentionsBlocked(text string) bool {
+	for _, m := range scriptHost.FindAllStringSubmatch(text, -1) {
+		if b.matches(strings.ToLower(m[1]), strings.ToLower(m[2])) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripTrackers removes scripts, pixels, frames and other embeds that load
+// from blocked hosts, plus inline snippets that inject them, and returns how
+// many elements went.
+func stripTrackers(doc *html.Node, base *url.URL, blocklist *Blocklist) int {
+	removed := 0
+	resolve := func(ref string) string {
+		if base == nil {
+			return ref
+		}
+		u, err := base.Parse(strings.TrimSpace(ref))
+		if err != nil {
+			return ref
+		}
+		return u.String()
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			if c.Type == html.ElementNode && trackerElement(c, blocklist, resolve) {
+				n.RemoveChild(c)
+				removed++
+			} else {
+				walk(c)
+			}
+			c = next
+		}
+	}
+	walk(doc)
+	return removed
+}
+
+func trackerElement(n *html.Node, blocklist *Blocklist, resolve func(string) string) bool {
+	key := ""
+	switch n.DataAtom {
+	case atom.Script, atom.Img, atom.Iframe, atom.Embed, atom.Source, atom.Video, atom.Audio:
+		key = "src"
+	case atom.Link:
+		key = "href"
+	case atom.Object:
+		key = "data"
+	case atom.Noscript:
+		return n.FirstChild != nil && n.FirstChild.Type == html.TextNode && blocklist.mentionsBlocked(n.FirstChild.Data)
+	default:
+		return false
+	}
+	if src := attrRaw(n, key); src != "" {
+		return blocklist.Blocks(resolve(src))
+	}
+	return n.DataAtom == atom.Script && n.FirstChild != nil && blocklist.mentionsBlocked(n.FirstChild.Data)
+}
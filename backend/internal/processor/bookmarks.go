@@ -0,0 +1,182 @@
+package processor
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Bookmark is one link from a browser bookmark export. Folders runs from the
+// outermost folder to the one holding the link. Source is "pocket" for links
+// from a Pocket export and empty for a browser's.
+type Bookmark struct {
+	URL     string
+	Title   string
+	Folders []string
+	Tags    []string
+	AddedAt *time.Time
+	Source  string
+}
+
+// BookmarkSourcePocket marks links exported from Pocket.
+const BookmarkSourcePocket = "pocket"
+
+// ParseBookmarks reads the Netscape bookmark file format every browser
+// exports: folders are <H3> headings each followed by a <DL> list of links
+// and subfolders. The lists are tracked on the token stream rather than a
+// parsed tree, because the format's unclosed <DT> and <p> tags nest
+// differently once an HTML parser repairs them. Links that aren't http(s)
+// (javascript: bookmarklets, place: queries) are skipped.
+func ParseBookmarks(r io.Reader) ([]Bookmark, error) {
+	z := html.NewTokenizer(r)
+	var (
+		out     []Bookmark
+		folders []string // "" for a list without a heading, like the root
+		heading *string
+		text    strings.Builder
+		inH3    bool
+		link    *Bookmark
+	)
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			if err := z.Err(); err != io.EOF {
+				return nil, err
+			}
+			if len(out) == 0 {
+				return nil, errors.New("no bookmarks found")
+			}
+			return out, nil
+		case html.TextToken:
+			if inH3 || link != nil {
+				text.Write(z.Text())
+			}
+		case html.StartTagToken:
+			tok := z.Token()
+			switch tok.DataAtom {
+			case atom.H3:
+				inH3 = true
+				text.Reset()
+			case atom.Dl:
+				name := ""
+				if heading != nil {
+					name = *heading
+				}
+				folders = append(folders, name)
+				heading = nil
+			case atom.A:
+				link = &Bookmark{}
+				text.Reset()
+				for _, a := range tok.Attr {
+					switch strings.ToLower(a.Key) {
+					case "href":
+						link.URL = strings.TrimSpace(a.Val)
+					case "tags":
+						for _, t := range strings.Split(a.Val, ",") {
+							if t = strings.TrimSpace(t); t != "" {
+								link.Tags = append(link.Tags, t)
+							}
+						}
+					case "add_date", "time_added":
+						if sec, err := strconv.ParseInt(strings.TrimSpace(a.Val), 10, 64); err == nil && sec > 0 {
+							t := time.Unix(sec, 0)
+							link.AddedAt = &t
+						}
+						// Pocket's HTML export is the only one with time_added.
+						if strings.EqualFold(a.Key, "time_added") {
+							link.Source = BookmarkSourcePocket
+						}
+					}
+				}
+			}
+		case html.EndTagToken:
+			switch tok := z.Token(); tok.DataAtom {
+			case atom.H3:
+				if inH3 {
+					name := strings.TrimSpace(text.String())
+					heading = &name
+					inH3 = false
+				}
+			case atom.Dl:
+				if len(folders) > 0 {
+					folders = folders[:len(folders)-1]
+				}
+			case atom.A:
+				if link == nil {
+					continue
+				}
+				lower := strings.ToLower(link.URL)
+				if strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://") {
+					link.Title = strings.TrimSpace(text.String())
+					for _, f := range folders {
+						if f != "" {
+							link.Folders = append(link.Folders, f)
+						}
+					}
+					out = append(out, *link)
+				}
+				link = nil
+			}
+		}
+	}
+}
+
+// ParsePocketCSV reads the CSV export Pocket replaced its HTML export with:
+// a header row naming title, url, time_added, tags (separated by "|") and
+// status columns, in any order. Rows without an http(s) URL are skipped.
+func ParsePocketCSV(r io.Reader) ([]Bookmark, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, err
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := col["url"]; !ok {
+		return nil, errors.New("no url column")
+	}
+	field := func(row []string, name string) string {
+		if i, ok := col[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+	var out []Bookmark
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		link := Bookmark{URL: field(row, "url"), Title: field(row, "title"), Source: BookmarkSourcePocket}
+		lower := strings.ToLower(link.URL)
+		if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") {
+			continue
+		}
+		for _, t := range strings.Split(field(row, "tags"), "|") {
+			if t = strings.TrimSpace(t); t != "" {
+				link.Tags = append(link.Tags, t)
+			}
+		}
+		if sec, err := strconv.ParseInt(field(row, "time_added"), 10, 64); err == nil && sec > 0 {
+			t := time.Unix(sec, 0)
+			link.AddedAt = &t
+		}
+		out = append(out, link)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("no bookmarks found")
+	}
+	return out, nil
+}
@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	prefersDark  = regexp.MustCompile(`(?i)\(\s*prefers-color-scheme\s*:\s*dark\s*\)`)
+	prefersLight = regexp.MustCompile(`(?i)\(\s*prefers-color-scheme\s*:\s*light\s*\)`)
+)
+
+// Always-true and never-true media features standing in for the colour
+// scheme preferences once the dark one is forced.
+const (
+	mediaAlways = "(min-width: 0px)"
+	mediaNever  = "(max-width: -1px)"
+)
+
+// darkInvertStyle darkens a page with no dark scheme of its own by inverting
+// it, then inverts media back so photos and video keep their colours.
+const darkInvertStyle = `html{filter:invert(1) hue-rotate(180deg);background:#fff}` +
+	`img,picture,video,canvas,iframe,embed,object,svg image,[style*="background-image"]{filter:invert(1) hue-rotate(180deg)}`
+
+// DarkVariant returns a copy of the page that reads well in a dark UI. A
+// page with its own dark scheme (prefers-color-scheme rules or a
+// color-scheme meta naming dark) gets those rules switched on and its light
+// ones off; any other page is inverted with a filter that spares images and
+// video. Linked stylesheets are matched by their media attribute only.
+func DarkVariant(page []byte) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	native := false
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Style:
+				if c.FirstChild != nil && c.FirstChild.Type == html.TextNode && forceDarkScheme(&c.FirstChild.Data) {
+					native = true
+				}
+				if forceDarkMedia(c) {
+					native = true
+				}
+			case atom.Link:
+				if forceDarkMedia(c) {
+					native = true
+				}
+			case atom.Meta:
+				if strings.EqualFold(attrValue(c, "name"), "color-scheme") && strings.Contains(attrValue(c, "content"), "dark") {
+					native = true
+				}
+			}
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	head := findElement(doc, atom.Head)
+	if head == nil {
+		head = doc
+	}
+	for c := head.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode && c.DataAtom == atom.Meta && strings.EqualFold(attrValue(c, "name"), "color-scheme") {
+			head.RemoveChild(c)
+		}
+		c = next
+	}
+	head.AppendChild(&html.Node{Type: html.ElementNode, Data: "meta", DataAtom: atom.Meta, Attr: []html.Attribute{
+		{Key: "name", Val: "color-scheme"},
+		{Key: "content", Val: "dark"},
+	}})
+	if !native {
+		style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: darkInvertStyle})
+		head.AppendChild(style)
+	}
+
+	var out bytes.Buffer
+	if err := html.Render(&out, doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// forceDarkScheme rewrites prefers-color-scheme conditions in css and
+// reports whether there were any.
+func forceDarkScheme(css *string) bool {
+	if !prefersDark.MatchString(*css) && !prefersLight.MatchString(*css) {
+		return false
+	}
+	*css = prefersDark.ReplaceAllString(*css, mediaAlways)
+	*css = prefersLight.ReplaceAllString(*css, mediaNever)
+	return true
+}
+
+func forceDarkMedia(n *html.Node) bool {
+	for i := range n.Attr {
+		if n.Attr[i].Namespace == "" && strings.EqualFold(n.Attr[i].Key, "media") {
+			return forceDarkScheme(&n.Attr[i].Val)
+		}
+	}
+	return false
+}
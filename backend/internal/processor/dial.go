@@ -0,0 +1,114 @@
+package processor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"webarchive/internal/proxy"
+)
+
+// guardedTransport is the default transport routed through rules, whose
+// direct connections are refused when the address a host resolved to is
+// denied by an IP or CIDR pattern of the processor's host rules or of extra.
+// Checking the connected address rather than the name covers hostnames
+// pointing into a denied range, redirects and DNS answers that change
+// between the check and the fetch. Connections to a proxy aren't checked:
+// the proxy resolves the target, so only the name rules apply to proxied
+// fetches. A transport for extra rules keeps no idle connections, so none
+// are shared with fetches those rules don't cover.
+func (p *Processor) guardedTransport(rules *proxy.Rules, extra *HostRules) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	next := t.Proxy
+	if rules != nil {
+		next = rules.Proxy
+	}
+	var proxies sync.Map
+	t.Proxy = func(req *http.Request) (*url.URL, error) {
+		u, err := next(req)
+		if u != nil {
+			proxies.Store(strings.ToLower(u.Hostname()), true)
+		}
+		return u, err
+	}
+	direct := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	guarded := *direct
+	guarded.Control = func(_, address string, _ syscall.RawConn) error {
+		return p.checkAddr(address, extra)
+	}
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, _ := net.SplitHostPort(addr)
+		if _, ok := proxies.Load(strings.ToLower(host)); ok {
+			return direct.DialContext(ctx, network, addr)
+		}
+		return guarded.DialContext(ctx, network, addr)
+	}
+	t.DisableKeepAlives = extra != nil
+	return t
+}
+
+// swapTransport replaces the fetch transport after the proxy or host rules
+// change, so connections pooled under the old ones aren't reused. Callers
+// hold p.mu.
+func (p *Processor) swapTransport(timeout time.Duration) {
+	if old, ok := p.transport.(*http.Transport); ok {
+		old.CloseIdleConnections()
+	}
+	p.transport = p.guardedTransport(p.proxyRules, nil)
+	p.Client = &http.Client{Timeout: timeout, Transport: p.transport}
+}
+
+// checkAddr refuses a connection to address, an IP and port, that the
+// processor's rules or extra deny.
+func (p *Processor) checkAddr(address string, extra *HostRules) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	p.mu.RLock()
+	rules := p.hostRules
+	p.mu.RUnlock()
+	if rules.DeniesAddr(addr) || (extra != nil && extra.DeniesAddr(addr)) {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, addr)
+	}
+	return nil
+}
+
+// fetchClient is base with the capture's cookie jar and a redirect policy
+// that checks every hop against the host rules, as the first URL is. A
+// capture whose own rules deny addresses gets a transport checking them too.
+// onHop, when set, sees each redirect that is followed.
+func (p *Processor) fetchClient(base *http.Client, opts Options, onHop func(*http.Request)) *http.Client {
+	client := *base
+	client.Jar = opts.Jar
+	if opts.HostRules != nil && opts.HostRules.hasAddrRules() {
+		p.mu.RLock()
+		client.Transport = p.guardedTransport(p.proxyRules, opts.HostRules)
+		p.mu.RUnlock()
+	}
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return errors.New("too many redirects")
+		}
+		if err := p.checkHost(req.URL.Hostname(), opts); err != nil {
+			return err
+		}
+		if onHop != nil {
+			onHop(req)
+		}
+		return nil
+	}
+	return &client
+}
@@ -0,0 +1,163 @@
+package processor
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ProfileEmail tunes a capture for email and newsletter HTML: see
+// sanitizeEmail.
+const ProfileEmail = "email"
+
+func ValidProfile(profile string) bool {
+	return profile == "" || profile == ProfileEmail
+}
+
+// trackerParams are query parameters mail tools add to links to attribute
+// clicks; they carry nothing the reader needs.
+var trackerParams = map[string]bool{
+	"mc_cid": true, "mc_eid": true, "_hsenc": true, "_hsmi": true, "mkt_tok": true,
+	"vero_id": true, "vero_conv": true, "oly_anon_id": true, "oly_enc_id": true,
+	"ck_subscriber_id": true, "__s": true, "ss_source": true, "ss_campaign_id": true,
+}
+
+// trackerPaths are URL fragments of open-tracking endpoints.
+var trackerPaths = []string{"/open", "/track", "/pixel", "/beacon", "/wf/open", "/e/o/", "/trk", "open.aspx", "tracking"}
+
+// sanitizeEmail strips what makes mail HTML unfit to archive: scripts and
+// forms, event handlers, open-tracking pixels and click-tracking query
+// parameters. cid: images are kept when resources has the part and
+// replaced by their alt text otherwise, since nothing can load them later.
+func sanitizeEmail(doc *html.Node, resources map[string]Resource) {
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			if c.Type == html.ElementNode {
+				switch strings.ToLower(c.Data) {
+				case "script", "noscript", "iframe", "object", "embed", "form", "input", "button", "select", "textarea":
+					n.RemoveChild(c)
+					c = next
+					continue
+				case "meta":
+					if attrValue(c, "http-equiv") == "refresh" {
+						n.RemoveChild(c)
+						c = next
+						continue
+					}
+				case "img":
+					if replacement, drop := emailImage(c, resources); drop {
+						if replacement != nil {
+							n.InsertBefore(replacement, c)
+						}
+						n.RemoveChild(c)
+						c = next
+						continue
+					}
+				case "a", "area":
+					for i := range c.Attr {
+						if c.Attr[i].Key == "href" {
+							c.Attr[i].Val = stripTrackerParams(c.Attr[i].Val)
+						}
+					}
+				}
+				kept := c.Attr[:0]
+				for _, a := range c.Attr {
+					if !strings.HasPrefix(strings.ToLower(a.Key), "on") {
+						kept = append(kept, a)
+					}
+				}
+				c.Attr = kept
+				walk(c)
+			}
+			c = next
+		}
+	}
+	walk(doc)
+}
+
+// emailImage reports whether img should go, with the node to put in its
+// place. The cid: reference is normalized to the key resources use.
+func emailImage(img *html.Node, resources map[string]Resource) (*html.Node, bool) {
+	for i := range img.Attr {
+		if img.Attr[i].Key != "src" {
+			continue
+		}
+		src := strings.TrimSpace(img.Attr[i].Val)
+		if len(src) > 4 && strings.EqualFold(src[:4], "cid:") {
+			ref := "cid:" + strings.Trim(src[4:], "<> ")
+			if _, ok := resources[ref]; !ok {
+				if alt := strings.TrimSpace(attrRaw(img, "alt")); alt != "" {
+					return &html.Node{Type: html.TextNode, Data: alt}, true
+				}
+				return nil, true
+			}
+			img.Attr[i].Val = ref
+			return nil, false
+		}
+		if isTrackingPixel(img, src) {
+			return nil, true
+		}
+	}
+	return nil, false
+}
+
+func isTrackingPixel(img *html.Node, src string) bool {
+	tiny := func(v string) bool {
+		n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(v), "px"))
+		return err == nil && n <= 1
+	}
+	if tiny(attrRaw(img, "width")) && tiny(attrRaw(img, "height")) {
+		return true
+	}
+	style := strings.ReplaceAll(attrValue(img, "style"), " ", "")
+	if strings.Contains(style, "display:none") || (strings.Contains(style, "width:1px") && strings.Contains(style, "height:1px")) {
+		return true
+	}
+	u, err := url.Parse(src)
+	if err != nil {
+		return false
+	}
+	path := strings.ToLower(u.Path)
+	for _, p := range trackerPaths {
+		if strings.Contains(path, p) && !hasImageExt(path) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasImageExt(path string) bool {
+	for _, ext := range []string{".png", ".jpg", ".jpeg", ".gif", ".webp", ".svg"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripTrackerParams drops utm_* and the mail tools' click parameters from
+// a link, leaving it untouched when it has none.
+func stripTrackerParams(href string) string {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil || u.RawQuery == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return href
+	}
+	q := u.Query()
+	changed := false
+	for key := range q {
+		lower := strings.ToLower(key)
+		if strings.HasPrefix(lower, "utm_") || trackerParams[lower] {
+			q.Del(key)
+			changed = true
+		}
+	}
+	if !changed {
+		return href
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
@@ -0,0 +1,248 @@
+package processor
+
+import (
+	"archive/zip"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+)
+
+// EPUBChapter is one article of a book. Text is the archive's reader text,
+// with paragraphs separated by blank lines, or by line breaks when it has
+// no blank lines.
+type EPUBChapter struct {
+	Title    string
+	Byline   string
+	SiteName string
+	URL      string
+	Date     *time.Time
+	Text     string
+}
+
+type EPUBBook struct {
+	ID       string
+	Title    string
+	Language string
+	Modified time.Time
+	Chapters []EPUBChapter
+}
+
+const epubStyle = `body { font-family: serif; line-height: 1.6; margin: 0 4%; }
+h1 { font-size: 1.4em; line-height: 1.3; margin: 1em 0 0.3em; }
+p.meta { color: #666; font-size: 0.85em; margin: 0 0 1.5em; }
+p.meta a { color: #666; }
+p { margin: 0 0 0.8em; text-align: justify; }
+nav ol { list-style: none; padding: 0; }
+nav li { margin: 0.4em 0; }
+`
+
+// WriteEPUB writes book as an EPUB 3 file with a navigation document and,
+// for older readers, an NCX table of contents.
+func WriteEPUB(w io.Writer, book EPUBBook) error {
+	if book.Language == "" {
+		book.Language = guessLanguage(book.Chapters)
+	}
+	if book.Modified.IsZero() {
+		book.Modified = time.Now()
+	}
+	zw := zip.NewWriter(w)
+	// The mimetype entry comes first and uncompressed so readers can sniff it.
+	mt, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store, Modified: book.Modified})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mt, "application/epub+zip"); err != nil {
+		return err
+	}
+	files := []struct{ name, body string }{
+		{"META-INF/container.xml", `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`},
+		{"OEBPS/content.opf", epubPackage(book)},
+		{"OEBPS/nav.xhtml", epubNav(book)},
+		{"OEBPS/toc.ncx", epubNCX(book)},
+		{"OEBPS/style.css", epubStyle},
+	}
+	for i, ch := range book.Chapters {
+		ch.Title = chapterTitle(ch, i)
+		files = append(files, struct{ name, body string }{"OEBPS/" + epubChapterFile(i), epubChapter(book.Language, ch)})
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: book.Modified})
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, f.body); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+func epubChapterFile(i int) string {
+	return fmt.Sprintf("chapter-%03d.xhtml", i+1)
+}
+
+func guessLanguage(chapters []EPUBChapter) string {
+	cjk, total := 0, 0
+	for _, ch := range chapters {
+		for _, r := range ch.Title + ch.Text {
+			if total >= 4000 {
+				break
+			}
+			if r > ' ' {
+				total++
+				if isCJK(r) {
+					cjk++
+				}
+			}
+		}
+	}
+	if total > 0 && cjk*5 >= total {
+		return "zh"
+	}
+	return "en"
+}
+
+// xmlText escapes s for XML, dropping the control characters XML 1.0
+// doesn't allow at all.
+func xmlText(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\t' && r != '\n' && r != '\r' || r == 0xfffe || r == 0xffff {
+			return -1
+		}
+		return r
+	}, s)
+	return html.EscapeString(s)
+}
+
+func chapterTitle(ch EPUBChapter, i int) string {
+	if t := strings.TrimSpace(ch.Title); t != "" {
+		return t
+	}
+	return fmt.Sprintf("#%d", i+1)
+}
+
+func epubPackage(book EPUBBook) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+`)
+	fmt.Fprintf(&b, "    <dc:identifier id=\"book-id\">urn:webarchive:%s</dc:identifier>\n", xmlText(book.ID))
+	fmt.Fprintf(&b, "    <dc:title>%s</dc:title>\n", xmlText(book.Title))
+	fmt.Fprintf(&b, "    <dc:language>%s</dc:language>\n", xmlText(book.Language))
+	b.WriteString("    <dc:creator>WebArchive</dc:creator>\n")
+	fmt.Fprintf(&b, "    <meta property=\"dcterms:modified\">%s</meta>\n", book.Modified.UTC().Format("2006-01-02T15:04:05Z"))
+	b.WriteString(`  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    <item id="style" href="style.css" media-type="text/css"/>
+`)
+	for i := range book.Chapters {
+		fmt.Fprintf(&b, "    <item id=\"ch%d\" href=\"%s\" media-type=\"application/xhtml+xml\"/>\n", i+1, epubChapterFile(i))
+	}
+	b.WriteString("  </manifest>\n  <spine toc=\"ncx\">\n    <itemref idref=\"nav\"/>\n")
+	for i := range book.Chapters {
+		fmt.Fprintf(&b, "    <itemref idref=\"ch%d\"/>\n", i+1)
+	}
+	b.WriteString("  </spine>\n</package>\n")
+	return b.String()
+}
+
+func epubNav(book EPUBBook) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="%[1]s" lang="%[1]s">
+<head>
+  <title>%[2]s</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>%[2]s</h1>
+    <ol>
+`, xmlText(book.Language), xmlText(book.Title))
+	for i, ch := range book.Chapters {
+		fmt.Fprintf(&b, "      <li><a href=\"%s\">%s</a></li>\n", epubChapterFile(i), xmlText(chapterTitle(ch, i)))
+	}
+	b.WriteString("    </ol>\n  </nav>\n</body>\n</html>\n")
+	return b.String()
+}
+
+func epubNCX(book EPUBBook) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="urn:webarchive:%s"/>
+    <meta name="dtb:depth" content="1"/>
+  </head>
+  <docTitle><text>%s</text></docTitle>
+  <navMap>
+`, xmlText(book.ID), xmlText(book.Title))
+	for i, ch := range book.Chapters {
+		fmt.Fprintf(&b, "    <navPoint id=\"np%d\" playOrder=\"%d\"><navLabel><text>%s</text></navLabel><content src=\"%s\"/></navPoint>\n",
+			i+1, i+1, xmlText(chapterTitle(ch, i)), epubChapterFile(i))
+	}
+	b.WriteString("  </navMap>\n</ncx>\n")
+	return b.String()
+}
+
+func epubChapter(lang string, ch EPUBChapter) string {
+	var b strings.Builder
+	title := xmlText(strings.TrimSpace(ch.Title))
+	fmt.Fprintf(&b, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="%[1]s" lang="%[1]s">
+<head>
+  <title>%[2]s</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body>
+  <h1>%[2]s</h1>
+`, xmlText(lang), title)
+	meta := []string{}
+	for _, part := range []string{ch.Byline, ch.SiteName} {
+		if part = strings.TrimSpace(part); part != "" {
+			meta = append(meta, xmlText(part))
+		}
+	}
+	if ch.Date != nil {
+		meta = append(meta, ch.Date.Format("2006-01-02"))
+	}
+	if u := strings.TrimSpace(ch.URL); strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") {
+		meta = append(meta, fmt.Sprintf(`<a href="%s">%s</a>`, xmlText(u), xmlText(URLDomain(u))))
+	}
+	if len(meta) > 0 {
+		fmt.Fprintf(&b, "  <p class=\"meta\">%s</p>\n", strings.Join(meta, " · "))
+	}
+	// Extracted text often has single line breaks between paragraphs; only
+	// when it has blank lines do the single ones stay within a paragraph.
+	text := strings.ReplaceAll(ch.Text, "\r\n", "\n")
+	sep := "\n"
+	if strings.Contains(text, "\n\n") {
+		sep = "\n\n"
+	}
+	for _, para := range strings.Split(text, sep) {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		lines := strings.Split(para, "\n")
+		for i := range lines {
+			lines[i] = xmlText(strings.TrimSpace(lines[i]))
+		}
+		fmt.Fprintf(&b, "  <p>%s</p>\n", strings.Join(lines, "<br/>"))
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
@@ -0,0 +1,126 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+const maxRedirects = 10
+
+type Hop struct {
+	URL    string `json:"url"`
+	Status int    `json:"status"`
+}
+
+// Page is a document fetched by the server. Redirects lists every hop in
+// order, ending with the final URL and its status.
+type Page struct {
+	HTML      []byte
+	FinalURL  string
+	Status    int
+	Redirects []Hop
+}
+
+// FetchPage GETs rawURL, following up to 10 redirects and recording each
+// hop, so archives of shortened links are keyed to the real page.
+func (p *Processor) FetchPage(ctx context.Context, rawURL string, opts Options) (*Page, error) {
+	base, maxBytes := p.limits()
+	hops := []Hop{}
+	client := p.fetchClient(base, opts, func(req *http.Request) {
+		if resp := req.Response; resp != nil {
+			hops = append(hops, Hop{URL: resp.Request.URL.String(), Status: resp.StatusCode})
+		}
+	})
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,*/*;q=0.8")
+	p.applyHeaders(req)
+
+	if err := p.checkHost(req.URL.Hostname(), opts); err != nil {
+		return nil, err
+	}
+	if !p.allowedByRobots(ctx, req.URL, opts) {
+		return nil, ErrRobotsDisallowed
+	}
+	release, err := p.acquire(ctx, req.URL, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	final := resp.Request.URL.String()
+	hops = append(hops, Hop{URL: final, Status: resp.StatusCode})
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("fetch %s: status %d", final, resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "" && !strings.Contains(ct, "html") {
+		return nil, fmt.Errorf("fetch %s: not an html page (%s)", final, ct)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes))
+	if err != nil {
+		return nil, err
+	}
+	return &Page{HTML: body, FinalURL: final, Status: resp.StatusCode, Redirects: hops}, nil
+}
+
+// ExtractText returns the document title and its visible body text, for
+// pages the server fetched itself rather than receiving from the extension.
+func ExtractText(rawHTML []byte) (string, string) {
+	doc, err := html.Parse(bytes.NewReader(rawHTML))
+	if err != nil {
+		return "", ""
+	}
+	title := ""
+	var b strings.Builder
+	var walk func(*html.Node, bool)
+	walk = func(n *html.Node, inBody bool) {
+		if n.Type == html.ElementNode {
+			switch strings.ToLower(n.Data) {
+			case "script", "style", "noscript", "template", "svg", "iframe":
+				return
+			case "title":
+				if title == "" && n.FirstChild != nil {
+					title = strings.TrimSpace(n.FirstChild.Data)
+				}
+				return
+			case "body":
+				inBody = true
+			case "p", "div", "br", "li", "h1", "h2", "h3", "h4", "h5", "h6", "tr", "section", "article":
+				b.WriteString("\n")
+			}
+		}
+		if n.Type == html.TextNode && inBody {
+			if text := strings.TrimSpace(n.Data); text != "" {
+				b.WriteString(text)
+				b.WriteString(" ")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c, inBody)
+		}
+	}
+	walk(doc, false)
+
+	lines := strings.Split(b.String(), "\n")
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			out = append(out, line)
+		}
+	}
+	return title, strings.Join(out, "\n")
+}
@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// ParseLimits bound the HTML the processor builds a DOM for. Each zero
+// field is unlimited.
+type ParseLimits struct {
+	MaxBytes int64
+	MaxNodes int
+	MaxDepth int
+	Timeout  time.Duration
+}
+
+// LimitError reports a page rejected by ParseLimits before it was parsed.
+type LimitError struct {
+	// Limit is "bytes", "nodes", "depth" or "time".
+	Limit string
+	msg   string
+}
+
+func (e *LimitError) Error() string { return e.msg }
+
+// guardCheckEvery is how many tokens pass between clock reads.
+const guardCheckEvery = 4096
+
+// Check scans page with the tokenizer, which keeps no tree, and rejects it
+// when it is larger, has more nodes, nests deeper or takes longer to scan
+// than the limits allow. Elements whose end tag may be omitted (p, li, td...)
+// are not counted as nesting, since the parser closes them implicitly.
+func (l ParseLimits) Check(page []byte) error {
+	if l.MaxBytes > 0 && int64(len(page)) > l.MaxBytes {
+		return &LimitError{Limit: "bytes", msg: fmt.Sprintf("html is %d bytes, over the %d byte limit", len(page), l.MaxBytes)}
+	}
+	if l.MaxNodes <= 0 && l.MaxDepth <= 0 && l.Timeout <= 0 {
+		return nil
+	}
+	var deadline time.Time
+	if l.Timeout > 0 {
+		deadline = time.Now().Add(l.Timeout)
+	}
+	z := html.NewTokenizer(bytes.NewReader(page))
+	nodes, depth := 0, 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			if z.Err() == io.EOF {
+				return nil
+			}
+			return z.Err()
+		case html.StartTagToken:
+			name, _ := z.TagName()
+			if !impliedEnd(atom.Lookup(name)) {
+				depth++
+			}
+		case html.EndTagToken:
+			name, _ := z.TagName()
+			if depth > 0 && !impliedEnd(atom.Lookup(name)) {
+				depth--
+			}
+		}
+		nodes++
+		if l.MaxNodes > 0 && nodes > l.MaxNodes {
+			return &LimitError{Limit: "nodes", msg: fmt.Sprintf("html has more than %d nodes", l.MaxNodes)}
+		}
+		if l.MaxDepth > 0 && depth > l.MaxDepth {
+			return &LimitError{Limit: "depth", msg: fmt.Sprintf("html nests deeper than %d elements", l.MaxDepth)}
+		}
+		if !deadline.IsZero() && nodes%guardCheckEvery == 0 && time.Now().After(deadline) {
+			return &LimitError{Limit: "time", msg: fmt.Sprintf("html took longer than %s to scan", l.Timeout)}
+		}
+	}
+}
+
+// impliedEnd reports elements that are void or whose end tag is optional.
+func impliedEnd(a atom.Atom) bool {
+	switch a {
+	case atom.Area, atom.Base, atom.Br, atom.Col, atom.Embed, atom.Hr, atom.Img, atom.Input, atom.Link, atom.Meta,
+		atom.Source, atom.Track, atom.Wbr, atom.P, atom.Li, atom.Dt, atom.Dd, atom.Option, atom.Optgroup,
+		atom.Tr, atom.Td, atom.Th, atom.Thead, atom.Tbody, atom.Tfoot, atom.Colgroup, atom.Rb, atom.Rt, atom.Rp, atom.Rtc:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package processor
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// HighlightAnchor prefixes the ids of highlight marks; the first one is
+// HighlightAnchor+"0", so a viewer can link to #webarchive-hl-0.
+const HighlightAnchor = "webarchive-hl-"
+
+const maxHighlights = 500
+
+const highlightStyle = "mark.webarchive-hl{background:#ffe066;color:inherit;padding:0}"
+
+// Highlight wraps case-insensitive occurrences of the query's terms in the
+// page's body text with <mark> elements and adds the style for them. Terms
+// are the whitespace-separated words of query, so a CJK phrase without
+// spaces is one term. It returns the rewritten page and the number of marks.
+func Highlight(page []byte, query string) ([]byte, int, error) {
+	re := highlightPattern(query)
+	if re == nil {
+		return page, 0, nil
+	}
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, 0, err
+	}
+	count := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			switch c.Type {
+			case html.ElementNode:
+				switch c.DataAtom {
+				case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Textarea, atom.Title, atom.Svg:
+				default:
+					walk(c)
+				}
+			case html.TextNode:
+				count += markMatches(n, c, re, count)
+			}
+			c = next
+		}
+	}
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+	walk(body)
+	if count == 0 {
+		return page, 0, nil
+	}
+	if head := findElement(doc, atom.Head); head != nil {
+		style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+		style.AppendChild(&html.Node{Type: html.TextNode, Data: highlightStyle})
+		head.AppendChild(style)
+	}
+	var out bytes.Buffer
+	if err := html.Render(&out, doc); err != nil {
+		return nil, 0, err
+	}
+	return out.Bytes(), count, nil
+}
+
+func highlightPattern(query string) *regexp.Regexp {
+	terms := strings.Fields(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	// Longest first, so "archives" wins over "archive" at the same offset.
+	sort.Slice(terms, func(i, j int) bool { return len(terms[i]) > len(terms[j]) })
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = regexp.QuoteMeta(t)
+	}
+	return regexp.MustCompile("(?i)" + strings.Join(quoted, "|"))
+}
+
+// markMatches splits text into plain text and <mark> nodes in place and
+// returns how many marks it added; numbering continues from seen.
+func markMatches(parent, text *html.Node, re *regexp.Regexp, seen int) int {
+	if seen >= maxHighlights {
+		return 0
+	}
+	matches := re.FindAllStringIndex(text.Data, maxHighlights-seen)
+	if len(matches) == 0 {
+		return 0
+	}
+	data := text.Data
+	last := 0
+	for i, m := range matches {
+		if m[0] > last {
+			parent.InsertBefore(&html.Node{Type: html.TextNode, Data: data[last:m[0]]}, text)
+		}
+		mark := &html.Node{Type: html.ElementNode, Data: "mark", DataAtom: atom.Mark, Attr: []html.Attribute{
+			{Key: "class", Val: "webarchive-hl"},
+			{Key: "id", Val: HighlightAnchor + strconv.Itoa(seen+i)},
+		}}
+		mark.AppendChild(&html.Node{Type: html.TextNode, Data: data[m[0]:m[1]]})
+		parent.InsertBefore(mark, text)
+		last = m[1]
+	}
+	if last < len(data) {
+		parent.InsertBefore(&html.Node{Type: html.TextNode, Data: data[last:]}, text)
+	}
+	parent.RemoveChild(text)
+	return len(matches)
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
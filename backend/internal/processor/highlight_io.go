@@ -0,0 +1,262 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HighlightRecord is a quoted passage as exchanged with other annotation tools.
+// URL or, failing that, Title identifies the page it belongs to. Location
+// is a rune offset into the page text, -1 when unknown.
+type HighlightRecord struct {
+	URL           string
+	Title         string
+	Author        string
+	Quote         string
+	Prefix        string
+	Suffix        string
+	Note          string
+	Location      int
+	HighlightedAt *time.Time
+}
+
+// ParseHypothesis reads annotations as returned by the Hypothes.is search
+// API, either the {"rows": [...]} response or a bare array, which is what
+// the common export scripts save. Page notes without a quoted passage are
+// skipped.
+func ParseHypothesis(r io.Reader) ([]HighlightRecord, error) {
+	type selector struct {
+		Type   string `json:"type"`
+		Exact  string `json:"exact"`
+		Prefix string `json:"prefix"`
+		Suffix string `json:"suffix"`
+	}
+	type row struct {
+		URI      string `json:"uri"`
+		Text     string `json:"text"`
+		Created  string `json:"created"`
+		Document struct {
+			Title []string `json:"title"`
+		} `json:"document"`
+		Target []struct {
+			Selector []selector `json:"selector"`
+		} `json:"target"`
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var rows []row
+	if err := json.Unmarshal(raw, &rows); err != nil {
+		var wrapped struct {
+			Rows []row `json:"rows"`
+		}
+		if err := json.Unmarshal(raw, &wrapped); err != nil {
+			return nil, errors.New("not a Hypothes.is JSON export")
+		}
+		rows = wrapped.Rows
+	}
+	out := []HighlightRecord{}
+	for _, row := range rows {
+		h := HighlightRecord{URL: row.URI, Note: strings.TrimSpace(row.Text), Location: -1, HighlightedAt: parseHighlightTime(row.Created)}
+		if len(row.Document.Title) > 0 {
+			h.Title = row.Document.Title[0]
+		}
+		for _, t := range row.Target {
+			for _, sel := range t.Selector {
+				if sel.Type == "TextQuoteSelector" {
+					h.Quote, h.Prefix, h.Suffix = sel.Exact, sel.Prefix, sel.Suffix
+				}
+			}
+		}
+		if strings.TrimSpace(h.Quote) != "" {
+			out = append(out, h)
+		}
+	}
+	return out, nil
+}
+
+// ParseReadwise reads either Readwise export: the JSON of the export API
+// (books, each with its highlights) or a CSV, from the web app's export or
+// in the CSV import layout WriteReadwiseCSV produces. The app's CSV has no
+// page URL, only the title.
+func ParseReadwise(r io.Reader) ([]HighlightRecord, error) {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// Spreadsheet CSVs often start with a byte order mark.
+	raw = bytes.TrimLeft(bytes.TrimPrefix(raw, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if len(raw) == 0 {
+		return nil, errors.New("empty export")
+	}
+	if raw[0] == '{' || raw[0] == '[' {
+		return parseReadwiseJSON(raw)
+	}
+	return parseReadwiseCSV(bytes.NewReader(raw))
+}
+
+func parseReadwiseJSON(raw []byte) ([]HighlightRecord, error) {
+	type book struct {
+		Title      string `json:"title"`
+		Author     string `json:"author"`
+		SourceURL  string `json:"source_url"`
+		Highlights []struct {
+			Text          string `json:"text"`
+			Note          string `json:"note"`
+			HighlightedAt string `json:"highlighted_at"`
+		} `json:"highlights"`
+	}
+	var books []book
+	if err := json.Unmarshal(raw, &books); err != nil {
+		var wrapped struct {
+			Results []book `json:"results"`
+		}
+		if err := json.Unmarshal(raw, &wrapped); err != nil {
+			return nil, errors.New("not a Readwise JSON export")
+		}
+		books = wrapped.Results
+	}
+	out := []HighlightRecord{}
+	for _, b := range books {
+		for _, hl := range b.Highlights {
+			if strings.TrimSpace(hl.Text) == "" {
+				continue
+			}
+			out = append(out, HighlightRecord{
+				URL:           b.SourceURL,
+				Title:         b.Title,
+				Author:        b.Author,
+				Quote:         hl.Text,
+				Note:          strings.TrimSpace(hl.Note),
+				Location:      -1,
+				HighlightedAt: parseHighlightTime(hl.HighlightedAt),
+			})
+		}
+	}
+	return out, nil
+}
+
+func parseReadwiseCSV(r io.Reader) ([]HighlightRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("not a Readwise CSV export")
+	}
+	col := map[string]int{}
+	for i, name := range header {
+		col[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	field := func(rec []string, names ...string) string {
+		for _, name := range names {
+			if i, ok := col[name]; ok && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+		}
+		return ""
+	}
+	if _, ok := col["highlight"]; !ok {
+		return nil, errors.New("CSV has no Highlight column")
+	}
+	out := []HighlightRecord{}
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		h := HighlightRecord{
+			URL:           field(rec, "url", "source url"),
+			Title:         field(rec, "title", "book title"),
+			Author:        field(rec, "author", "book author"),
+			Quote:         field(rec, "highlight"),
+			Note:          field(rec, "note"),
+			Location:      -1,
+			HighlightedAt: parseHighlightTime(field(rec, "date", "highlighted at")),
+		}
+		if h.Quote != "" {
+			out = append(out, h)
+		}
+	}
+}
+
+var highlightTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05-07:00", "2006-01-02 15:04:05", "2006-01-02"}
+
+func parseHighlightTime(raw string) *time.Time {
+	raw = strings.TrimSpace(raw)
+	for _, layout := range highlightTimeLayouts {
+		if t, err := time.Parse(layout, raw); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+// WriteReadwiseCSV writes highlights in the layout of Readwise's CSV
+// import template.
+func WriteReadwiseCSV(w io.Writer, hs []HighlightRecord) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"Highlight", "Title", "Author", "URL", "Note", "Location", "Date"})
+	for _, h := range hs {
+		location, date := "", ""
+		if h.Location >= 0 {
+			location = strconv.Itoa(h.Location)
+		}
+		if h.HighlightedAt != nil {
+			date = h.HighlightedAt.UTC().Format("2006-01-02 15:04:05")
+		}
+		if err := cw.Write([]string{h.Quote, h.Title, h.Author, h.URL, h.Note, location, date}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteHighlightsMarkdown writes one section per page, laid out like
+// Readwise's Markdown export. Highlights of a page must be adjacent.
+func WriteHighlightsMarkdown(w io.Writer, hs []HighlightRecord) error {
+	bw := bufio.NewWriter(w)
+	page := ""
+	for _, h := range hs {
+		if key := h.URL + "\x00" + h.Title; key != page {
+			if page != "" {
+				bw.WriteString("\n")
+			}
+			page = key
+			title := h.Title
+			if title == "" {
+				title = h.URL
+			}
+			fmt.Fprintf(bw, "# %s\n\n## Metadata\n", oneLine(title))
+			if h.Author != "" {
+				fmt.Fprintf(bw, "- Author: %s\n", oneLine(h.Author))
+			}
+			if h.URL != "" {
+				fmt.Fprintf(bw, "- URL: %s\n", h.URL)
+			}
+			bw.WriteString("\n## Highlights\n")
+		}
+		fmt.Fprintf(bw, "- %s\n", oneLine(h.Quote))
+		if h.Note != "" {
+			fmt.Fprintf(bw, "    - Note: %s\n", oneLine(h.Note))
+		}
+	}
+	return bw.Flush()
+}
+
+// oneLine keeps a value from breaking out of its Markdown list item.
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
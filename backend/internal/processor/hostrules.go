@@ -0,0 +1,147 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+)
+
+// ErrHostNotAllowed is returned for fetches of a host the capture host
+// rules refuse.
+var ErrHostNotAllowed = errors.New("host not allowed by capture rules")
+
+// HostRules decide which hosts may be captured or fetched. A pattern is a
+// domain, matching it and its subdomains; "*.domain", matching subdomains
+// only; or an IP address or CIDR range, matching hosts written as IP
+// literals and, in Deny, the address any host is connected to. Deny wins
+// over Allow, and a non-empty Allow refuses every host it doesn't match.
+type HostRules struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+func (r HostRules) Validate() error {
+	for _, list := range [][]string{r.Allow, r.Deny} {
+		for _, pattern := range list {
+			if err := validHostPattern(pattern); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validHostPattern(pattern string) error {
+	pattern = strings.ToLower(strings.TrimSpace(pattern))
+	switch {
+	case strings.Contains(pattern, "/"):
+		if _, err := netip.ParsePrefix(pattern); err != nil {
+			return fmt.Errorf("invalid host pattern %q", pattern)
+		}
+	case net.ParseIP(pattern) != nil:
+	default:
+		name := strings.TrimPrefix(pattern, "*.")
+		if name == "" || strings.ContainsAny(name, "*: ") {
+			return fmt.Errorf("invalid host pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether host, as returned by url.URL.Hostname, passes the
+// rules.
+func (r HostRules) Allows(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	for _, pattern := range r.Deny {
+		if hostMatches(pattern, host) {
+			return false
+		}
+	}
+	if len(r.Allow) == 0 {
+		return true
+	}
+	for _, pattern := range r.Allow {
+		if hostMatches(pattern, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeniesAddr reports whether an IP or CIDR pattern in Deny matches addr, the
+// address a fetch is about to connect to.
+func (r HostRules) DeniesAddr(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	for _, pattern := range r.Deny {
+		pattern = strings.TrimSpace(pattern)
+		if prefix, err := netip.ParsePrefix(pattern); err == nil && prefix.Contains(addr) {
+			return true
+		}
+		if ip, err := netip.ParseAddr(pattern); err == nil && ip.Unmap() == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (r HostRules) hasAddrRules() bool {
+	for _, pattern := range r.Deny {
+		pattern = strings.TrimSpace(pattern)
+		if strings.Contains(pattern, "/") || net.ParseIP(pattern) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func hostMatches(pattern, host string) bool {
+	pattern = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(pattern)), ".")
+	if pattern == "" || host == "" {
+		return false
+	}
+	if strings.Contains(pattern, "/") {
+		prefix, err := netip.ParsePrefix(pattern)
+		if err != nil {
+			return false
+		}
+		addr, err := netip.ParseAddr(host)
+		return err == nil && prefix.Contains(addr.Unmap())
+	}
+	if ip := net.ParseIP(pattern); ip != nil {
+		other := net.ParseIP(host)
+		return other != nil && ip.Equal(other)
+	}
+	if sub, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+sub)
+	}
+	return host == pattern || strings.HasSuffix(host, "."+pattern)
+}
+
+// SetHostRules replaces the rules every fetch is checked against; requests
+// for a capture may add their own through Options.HostRules.
+func (p *Processor) SetHostRules(rules HostRules) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.hostRules = rules
+	p.swapTransport(p.Client.Timeout)
+}
+
+// checkHost returns ErrHostNotAllowed when either the processor's rules or
+// the capture's refuse host.
+func (p *Processor) checkHost(host string, opts Options) error {
+	p.mu.RLock()
+	rules := p.hostRules
+	p.mu.RUnlock()
+	if !rules.Allows(host) || (opts.HostRules != nil && !opts.HostRules.Allows(host)) {
+		return fmt.Errorf("%w: %s", ErrHostNotAllowed, host)
+	}
+	return nil
+}
+
+// HostAllowed reports whether a capture of host would pass the processor's
+// rules and extra.
+func (p *Processor) HostAllowed(host string, extra *HostRules) bool {
+	return p.checkHost(host, Options{HostRules: extra}) == nil
+}
@@ -0,0 +1,119 @@
+package processor
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const internalLinkClass = "webarchive-internal"
+
+const internalLinkStyle = `a.webarchive-internal::after{content:"\25C6";margin-left:2px;font-size:.7em;color:#1f6feb;vertical-align:super}`
+
+// LinkURLs returns the normalized absolute http(s) targets of every <a href>
+// in the page, so callers can look up which of them are already archived.
+func LinkURLs(rawHTML []byte, pageURL string) []string {
+	doc, err := html.Parse(bytes.NewReader(rawHTML))
+	if err != nil {
+		return nil
+	}
+	base, _ := url.Parse(pageURL)
+	self := NormalizeURL(pageURL)
+	seen := map[string]bool{}
+	out := []string{}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			if target := linkTarget(base, attrRaw(n, "href")); target != "" && target != self && !seen[target] {
+				seen[target] = true
+				out = append(out, target)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return out
+}
+
+func linkTarget(base *url.URL, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return ""
+	}
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return NormalizeURL(u.String())
+}
+
+func attrRaw(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// rewriteArchivedLink points an <a> at the internal viewer when its target
+// is in links, keeping the original URL in data-webarchive-href.
+func rewriteArchivedLink(n *html.Node, base *url.URL, links map[string]string) bool {
+	target := linkTarget(base, attrRaw(n, "href"))
+	id, ok := links[target]
+	if target == "" || !ok {
+		return false
+	}
+	hasClass := false
+	for i := range n.Attr {
+		switch n.Attr[i].Key {
+		case "href":
+			n.Attr = append(n.Attr, html.Attribute{Key: "data-webarchive-href", Val: n.Attr[i].Val})
+			n.Attr[i].Val = "/archive/" + id
+		case "class":
+			n.Attr[i].Val = strings.TrimSpace(n.Attr[i].Val + " " + internalLinkClass)
+			hasClass = true
+		}
+	}
+	if !hasClass {
+		n.Attr = append(n.Attr, html.Attribute{Key: "class", Val: internalLinkClass})
+	}
+	n.Attr = append(n.Attr, html.Attribute{Key: "data-webarchive-id", Val: id})
+	return true
+}
+
+// injectLinkStyle adds the marker style for rewritten links to <head>.
+func injectLinkStyle(doc *html.Node) {
+	var head *html.Node
+	var find func(*html.Node)
+	find = func(n *html.Node) {
+		if head != nil {
+			return
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Head {
+			head = n
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	if head == nil {
+		return
+	}
+	style := &html.Node{Type: html.ElementNode, Data: "style", DataAtom: atom.Style}
+	style.AppendChild(&html.Node{Type: html.TextNode, Data: internalLinkStyle})
+	head.AppendChild(style)
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"context"
+	"sync"
+)
+
+// smallAssetBytes is the largest asset kept whole in memory; bigger ones are
+// streamed to storage as multipart uploads.
+const smallAssetBytes = 1 << 20
+
+// memBudget caps the bytes asset uploads hold in memory across all captures.
+// A zero limit means unlimited.
+type memBudget struct {
+	mu      sync.Mutex
+	limit   int64
+	used    int64
+	changed chan struct{}
+}
+
+// acquire reserves n bytes, waiting until enough are free. A request larger
+// than the whole budget waits for it to drain and then runs alone.
+func (b *memBudget) acquire(ctx context.Context, n int64) (func(), error) {
+	for {
+		b.mu.Lock()
+		if b.limit > 0 && n > b.limit {
+			n = b.limit
+		}
+		if b.limit <= 0 || b.used+n <= b.limit {
+			b.used += n
+			b.mu.Unlock()
+			return func() { b.release(n) }, nil
+		}
+		if b.changed == nil {
+			b.changed = make(chan struct{})
+		}
+		wait := b.changed
+		b.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-wait:
+		}
+	}
+}
+
+func (b *memBudget) release(n int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used -= n
+	if b.changed != nil {
+		close(b.changed)
+		b.changed = nil
+	}
+}
+
+func (b *memBudget) setLimit(limit int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.limit = limit
+	if b.changed != nil {
+		close(b.changed)
+		b.changed = nil
+	}
+}
+
+// SetMemoryLimit caps the memory asset downloads and uploads may hold at
+// once across all captures; 0 removes the cap.
+func (p *Processor) SetMemoryLimit(limit int64) {
+	p.memory.setLimit(limit)
+}
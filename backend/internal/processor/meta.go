@@ -0,0 +1,92 @@
+package processor
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// PageMeta is what the list view shows for an archive besides its URL.
+type PageMeta struct {
+	Title    string
+	SiteName string
+	Favicon  string
+}
+
+// ExtractMeta reads the title, site name and favicon from a page's head.
+// Icons are looked up in the same order as the browser extension, and a page
+// that declares none gets the conventional /favicon.ico of its origin.
+func ExtractMeta(rawHTML []byte, pageURL string) PageMeta {
+	var meta PageMeta
+	base, _ := url.Parse(pageURL)
+	doc, err := html.Parse(bytes.NewReader(rawHTML))
+	if err != nil {
+		return meta
+	}
+	var ogTitle, appName string
+	icons := map[string]string{}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Title:
+				if meta.Title == "" && n.FirstChild != nil {
+					meta.Title = strings.Join(strings.Fields(n.FirstChild.Data), " ")
+				}
+			case atom.Meta:
+				content := strings.TrimSpace(attrRaw(n, "content"))
+				switch strings.ToLower(attrRaw(n, "property") + attrRaw(n, "name")) {
+				case "og:site_name":
+					meta.SiteName = content
+				case "og:title":
+					ogTitle = content
+				case "application-name":
+					appName = content
+				}
+			case atom.Link:
+				rel := strings.ToLower(strings.Join(strings.Fields(attrRaw(n, "rel")), " "))
+				if href := strings.TrimSpace(attrRaw(n, "href")); href != "" && icons[rel] == "" {
+					icons[rel] = href
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+
+	if meta.Title == "" {
+		meta.Title = ogTitle
+	}
+	if meta.SiteName == "" {
+		meta.SiteName = appName
+	}
+	for _, rel := range []string{"icon", "shortcut icon", "apple-touch-icon"} {
+		if href := icons[rel]; href != "" {
+			meta.Favicon = absoluteURL(base, href)
+			break
+		}
+	}
+	if meta.Favicon == "" && base != nil && base.Host != "" {
+		meta.Favicon = (&url.URL{Scheme: base.Scheme, Host: base.Host, Path: "/favicon.ico"}).String()
+	}
+	return meta
+}
+
+func absoluteURL(base *url.URL, href string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	return u.String()
+}
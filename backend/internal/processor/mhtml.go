@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+)
+
+// Snapshot is a page packaged by the browser, with its resources.
+type Snapshot struct {
+	HTML      []byte
+	Location  string
+	Resources map[string]Resource
+}
+
+// ParseMHTML unpacks an MHTML (multipart/related) document as produced by
+// chrome.pageCapture. The main document is the part at
+// Snapshot-Content-Location, or the first text/html part.
+func ParseMHTML(data []byte) (*Snapshot, error) {
+	reader := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+	header, err := reader.ReadMIMEHeader()
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil, errors.New("not an mhtml document")
+	}
+
+	snap := &Snapshot{Location: header.Get("Snapshot-Content-Location"), Resources: map[string]Resource{}}
+	parts := multipart.NewReader(reader.R, params["boundary"])
+	for {
+		part, err := parts.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		body, err := decodePart(part)
+		if err != nil {
+			return nil, err
+		}
+		contentType := part.Header.Get("Content-Type")
+		location := strings.TrimSpace(part.Header.Get("Content-Location"))
+		isHTML := strings.HasPrefix(strings.ToLower(contentType), "text/html")
+		if snap.HTML == nil && isHTML && (snap.Location == "" || location == snap.Location) {
+			snap.HTML = body
+			if snap.Location == "" {
+				snap.Location = location
+			}
+			continue
+		}
+		res := Resource{ContentType: contentType, Body: body}
+		if location != "" {
+			snap.Resources[location] = res
+		}
+		if cid := strings.Trim(part.Header.Get("Content-ID"), "<> "); cid != "" {
+			snap.Resources["cid:"+cid] = res
+		}
+	}
+	if snap.HTML == nil {
+		return nil, errors.New("mhtml has no html part")
+	}
+	return snap, nil
+}
+
+func decodePart(part *multipart.Part) ([]byte, error) {
+	var r io.Reader = part
+	switch strings.ToLower(strings.TrimSpace(part.Header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, part)
+	case "quoted-printable":
+		r = quotedprintable.NewReader(part)
+	}
+	return io.ReadAll(r)
+}
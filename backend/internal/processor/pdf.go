@@ -0,0 +1,239 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// PDFThumbnailWidth is the width, in pixels, first-page thumbnails are
+// rendered at.
+const PDFThumbnailWidth = 480
+
+// ErrPDFToolMissing reports a PDF tool that is not configured or not
+// installed.
+var ErrPDFToolMissing = errors.New("pdf tool not available")
+
+// PDFTools runs poppler-utils: TextCommand is pdftotext, ThumbnailCommand
+// pdftoppm. An empty command disables that step.
+type PDFTools struct {
+	TextCommand      string
+	ThumbnailCommand string
+}
+
+// IsPDF reports whether data starts with the PDF header. Some writers put
+// a few bytes of junk first, which readers tolerate.
+func IsPDF(data []byte) bool {
+	head := data
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	return bytes.Contains(head, []byte("%PDF-"))
+}
+
+func lookTool(command string) (string, error) {
+	if strings.TrimSpace(command) == "" {
+		return "", ErrPDFToolMissing
+	}
+	bin, err := exec.LookPath(command)
+	if err != nil {
+		return "", ErrPDFToolMissing
+	}
+	return bin, nil
+}
+
+// Text extracts the document's text in reading order. Pages are separated
+// by form feeds, as pdftotext writes them.
+func (t PDFTools) Text(ctx context.Context, file string) (string, error) {
+	bin, err := lookTool(t.TextCommand)
+	if err != nil {
+		return "", err
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "-enc", "UTF-8", "-q", file, "-")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.New(msg)
+		}
+		return "", err
+	}
+	return strings.ToValidUTF8(string(out), ""), nil
+}
+
+// Thumbnail renders the first page as a PNG.
+func (t PDFTools) Thumbnail(ctx context.Context, file string) ([]byte, error) {
+	bin, err := lookTool(t.ThumbnailCommand)
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "webarchive-pdf-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "page")
+	cmd := exec.CommandContext(ctx, bin, "-png", "-f", "1", "-l", "1", "-singlefile",
+		"-scale-to-x", strconv.Itoa(PDFThumbnailWidth), "-scale-to-y", "-1", "-q", file, out)
+	if msg, err := cmd.CombinedOutput(); err != nil {
+		if s := strings.TrimSpace(string(msg)); s != "" {
+			return nil, errors.New(s)
+		}
+		return nil, err
+	}
+	return os.ReadFile(out + ".png")
+}
+
+var pdfTitle = regexp.MustCompile(`/Title\s*(\((?:\\.|[^\\)])*\)|<[0-9A-Fa-f\s]*>)`)
+
+// PDFTitle returns the Title entry of the document information dictionary
+// when it is stored uncompressed, which covers most writers; titles inside
+// compressed object streams are not found.
+func PDFTitle(data []byte) string {
+	m := pdfTitle.FindSubmatch(data)
+	if m == nil {
+		return ""
+	}
+	raw := m[1]
+	var b []byte
+	if raw[0] == '<' {
+		b = decodePDFHex(raw[1 : len(raw)-1])
+	} else {
+		b = decodePDFLiteral(raw[1 : len(raw)-1])
+	}
+	return strings.TrimSpace(pdfTextString(b))
+}
+
+func decodePDFHex(raw []byte) []byte {
+	digits := make([]byte, 0, len(raw))
+	for _, c := range raw {
+		if !isSpaceByte(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	out := make([]byte, len(digits)/2)
+	for i := range out {
+		out[i] = hexNibble(digits[2*i])<<4 | hexNibble(digits[2*i+1])
+	}
+	return out
+}
+
+func isSpaceByte(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func hexNibble(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10
+	}
+	return 0
+}
+
+func decodePDFLiteral(raw []byte) []byte {
+	out := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		c := raw[i]
+		if c != '\\' || i+1 == len(raw) {
+			out = append(out, c)
+			continue
+		}
+		i++
+		switch c = raw[i]; c {
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case '\r', '\n':
+			// A backslash before a line break continues the string.
+		default:
+			if c >= '0' && c <= '7' {
+				v := 0
+				for n := 0; n < 3 && i < len(raw) && raw[i] >= '0' && raw[i] <= '7'; n++ {
+					v = v*8 + int(raw[i]-'0')
+					i++
+				}
+				i--
+				out = append(out, byte(v))
+			} else {
+				out = append(out, c)
+			}
+		}
+	}
+	return out
+}
+
+// pdfTextString decodes a PDF text string: UTF-16BE after a byte order
+// mark, otherwise PDFDocEncoding, read here as Latin-1.
+func pdfTextString(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xfe && b[1] == 0xff {
+		units := make([]uint16, 0, len(b)/2)
+		for i := 2; i+1 < len(b); i += 2 {
+			units = append(units, uint16(b[i])<<8|uint16(b[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	if len(b) >= 3 && b[0] == 0xef && b[1] == 0xbb && b[2] == 0xbf {
+		return strings.ToValidUTF8(string(b[3:]), "")
+	}
+	r := make([]rune, len(b))
+	for i, c := range b {
+		r[i] = rune(c)
+	}
+	return string(r)
+}
+
+// PDFPage is the reader page stored for an uploaded PDF: the extracted
+// text, page by page, under an embed of the original at pdfURL.
+func PDFPage(title, text, pdfURL string) []byte {
+	var b strings.Builder
+	b.WriteString("<!doctype html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>")
+	b.WriteString(html.EscapeString(title))
+	b.WriteString("</title>\n<style>body{font-family:-apple-system,system-ui,sans-serif;max-width:860px;margin:0 auto;padding:24px;line-height:1.6;color:#1f2a44}object{width:100%;height:80vh;border:1px solid #e5e7eb}section{border-top:1px solid #e5e7eb;margin-top:24px}</style>\n</head>\n<body>\n<h1>")
+	b.WriteString(html.EscapeString(title))
+	b.WriteString("</h1>\n<p><a href=\"")
+	b.WriteString(html.EscapeString(pdfURL))
+	b.WriteString("\">PDF</a></p>\n<object type=\"application/pdf\" data=\"")
+	b.WriteString(html.EscapeString(pdfURL))
+	b.WriteString("\"></object>\n")
+	for i, page := range strings.Split(text, "\f") {
+		if strings.TrimSpace(page) == "" {
+			continue
+		}
+		b.WriteString("<section data-page=\"")
+		b.WriteString(strconv.Itoa(i + 1))
+		b.WriteString("\">\n")
+		for _, para := range strings.Split(strings.ReplaceAll(page, "\r\n", "\n"), "\n\n") {
+			if para = strings.TrimSpace(para); para != "" {
+				b.WriteString("<p>")
+				b.WriteString(strings.ReplaceAll(html.EscapeString(para), "\n", "<br>"))
+				b.WriteString("</p>\n")
+			}
+		}
+		b.WriteString("</section>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return []byte(b.String())
+}
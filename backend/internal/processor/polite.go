@@ -0,0 +1,244 @@
+package processor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrRobotsDisallowed = errors.New("disallowed by robots.txt")
+
+const robotsTTL = time.Hour
+
+// Politeness limits how hard the server hits other sites. Zero values turn
+// each control off.
+type Politeness struct {
+	RespectRobots bool
+	// HostDelay is the minimum gap between two requests to the same host.
+	HostDelay time.Duration
+	// MaxConcurrent caps in-flight fetches across all captures.
+	MaxConcurrent int
+}
+
+type robotsRules struct {
+	fetched  time.Time
+	allow    []string
+	disallow []string
+}
+
+type politeState struct {
+	mu     sync.Mutex
+	cfg    Politeness
+	slots  chan struct{}
+	nextAt map[string]time.Time
+	robots map[string]*robotsRules
+}
+
+// SetPoliteness replaces the politeness controls. Fetches already waiting
+// for a slot keep the limiter they started with.
+func (p *Processor) SetPoliteness(cfg Politeness) {
+	p.polite.mu.Lock()
+	defer p.polite.mu.Unlock()
+	p.polite.cfg = cfg
+	p.polite.slots = nil
+	if cfg.MaxConcurrent > 0 {
+		p.polite.slots = make(chan struct{}, cfg.MaxConcurrent)
+	}
+	if p.polite.nextAt == nil {
+		p.polite.nextAt = map[string]time.Time{}
+	}
+	if !cfg.RespectRobots {
+		p.polite.robots = nil
+	}
+}
+
+// acquire waits for a concurrency slot and the per-host delay. The returned
+// func must be called once the request is done.
+func (p *Processor) acquire(ctx context.Context, u *url.URL, opts Options) (func(), error) {
+	if opts.Unthrottled {
+		return func() {}, nil
+	}
+	p.polite.mu.Lock()
+	slots := p.polite.slots
+	wait := time.Duration(0)
+	if delay := p.polite.cfg.HostDelay; delay > 0 {
+		host := strings.ToLower(u.Host)
+		now := time.Now()
+		next := p.polite.nextAt[host]
+		if next.After(now) {
+			wait = next.Sub(now)
+		} else {
+			next = now
+		}
+		p.polite.nextAt[host] = next.Add(delay)
+	}
+	p.polite.mu.Unlock()
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+	if slots == nil {
+		return func() {}, nil
+	}
+	select {
+	case slots <- struct{}{}:
+		return func() { <-slots }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// allowedByRobots reports whether robots.txt lets our User-Agent fetch u.
+// Unreachable or broken robots.txt files allow everything.
+func (p *Processor) allowedByRobots(ctx context.Context, u *url.URL, opts Options) bool {
+	p.polite.mu.Lock()
+	respect := p.polite.cfg.RespectRobots
+	rules := p.polite.robots[u.Scheme+"://"+strings.ToLower(u.Host)]
+	p.polite.mu.Unlock()
+	if !respect || opts.IgnoreRobots {
+		return true
+	}
+	if rules == nil || time.Since(rules.fetched) > robotsTTL {
+		rules = p.fetchRobots(ctx, u, opts)
+		p.polite.mu.Lock()
+		if p.polite.robots == nil {
+			p.polite.robots = map[string]*robotsRules{}
+		}
+		p.polite.robots[u.Scheme+"://"+strings.ToLower(u.Host)] = rules
+		p.polite.mu.Unlock()
+	}
+	return rules.allows(u.RequestURI())
+}
+
+func (p *Processor) fetchRobots(ctx context.Context, u *url.URL, opts Options) *robotsRules {
+	rules := &robotsRules{fetched: time.Now()}
+	robotsURL := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL.String(), nil)
+	if err != nil {
+		return rules
+	}
+	p.applyHeaders(req)
+	client, _ := p.limits()
+	resp, err := p.fetchClient(client, Options{HostRules: opts.HostRules}, nil).Do(req)
+	if err != nil {
+		return rules
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return rules
+	}
+	agent := strings.ToLower(req.Header.Get("User-Agent"))
+	if i := strings.IndexAny(agent, "/ "); i > 0 {
+		agent = agent[:i]
+	}
+	return parseRobots(io.LimitReader(resp.Body, 512<<10), agent, rules)
+}
+
+// parseRobots keeps the rules of the group naming agent, or of the "*"
+// group when no group names it.
+func parseRobots(r io.Reader, agent string, out *robotsRules) *robotsRules {
+	var named, star robotsRules
+	foundNamed := false
+	var current []*robotsRules
+	inAgents := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = nil
+				inAgents = true
+			}
+			ua := strings.ToLower(value)
+			switch {
+			case ua == "*":
+				current = append(current, &star)
+			case agent != "" && strings.Contains(agent, ua):
+				foundNamed = true
+				current = append(current, &named)
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if value == "" {
+				continue
+			}
+			for _, group := range current {
+				if key == "allow" {
+					group.allow = append(group.allow, value)
+				} else {
+					group.disallow = append(group.disallow, value)
+				}
+			}
+		default:
+			inAgents = false
+		}
+	}
+	if foundNamed {
+		out.allow, out.disallow = named.allow, named.disallow
+	} else {
+		out.allow, out.disallow = star.allow, star.disallow
+	}
+	return out
+}
+
+// allows applies the longest matching rule; Allow wins ties.
+func (r *robotsRules) allows(path string) bool {
+	best, allowed := -1, true
+	for _, pattern := range r.disallow {
+		if len(pattern) > best && robotsMatch(pattern, path) {
+			best, allowed = len(pattern), false
+		}
+	}
+	for _, pattern := range r.allow {
+		if len(pattern) >= best && robotsMatch(pattern, path) {
+			best, allowed = len(pattern), true
+		}
+	}
+	return allowed
+}
+
+// robotsMatch matches path against a robots.txt pattern supporting "*"
+// and a trailing "$".
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+	for _, part := range parts[1:] {
+		i := strings.Index(rest, part)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len(part):]
+	}
+	if anchored {
+		last := parts[len(parts)-1]
+		return rest == "" || (len(parts) > 1 && strings.HasSuffix(path, last))
+	}
+	return true
+}
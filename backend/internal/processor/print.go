@@ -0,0 +1,84 @@
+package processor
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+var (
+	mediaPrintRule  = regexp.MustCompile(`(?i)@media\s+print\b`)
+	mediaScreenRule = regexp.MustCompile(`(?i)@media\s+(only\s+)?screen\b`)
+)
+
+// chromeRoles are ARIA landmarks that hold navigation and page furniture
+// rather than the content.
+var chromeRoles = map[string]bool{"navigation": true, "banner": true, "complementary": true, "contentinfo": true, "search": true}
+
+// PrintVariant approximates how the page prints without a browser: print
+// stylesheets apply and screen-only ones are dropped, @media print rules in
+// inline styles are switched on and @media screen ones off, and navigation,
+// sidebars, footers, forms and embeds are removed. Print CSS inside linked
+// stylesheets is left as stored.
+func PrintVariant(page []byte) ([]byte, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			if c.Type == html.ElementNode && dropForPrint(c) {
+				n.RemoveChild(c)
+				c = next
+				continue
+			}
+			if c.Type == html.ElementNode && c.DataAtom == atom.Style && c.FirstChild != nil && c.FirstChild.Type == html.TextNode {
+				css := mediaPrintRule.ReplaceAllString(c.FirstChild.Data, "@media all")
+				c.FirstChild.Data = mediaScreenRule.ReplaceAllString(css, "@media not all")
+			}
+			walk(c)
+			c = next
+		}
+	}
+	walk(doc)
+	var out bytes.Buffer
+	if err := html.Render(&out, doc); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// dropForPrint reports whether n goes from the print variant; stylesheets it
+// keeps get media="all".
+func dropForPrint(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Nav, atom.Aside, atom.Footer, atom.Form, atom.Button, atom.Iframe, atom.Object, atom.Embed, atom.Dialog, atom.Noscript, atom.Script:
+		return true
+	case atom.Link:
+		if !strings.Contains(attrValue(n, "rel"), "stylesheet") {
+			return false
+		}
+		return !printMedia(n)
+	case atom.Style:
+		return !printMedia(n)
+	}
+	return chromeRoles[attrValue(n, "role")]
+}
+
+func printMedia(n *html.Node) bool {
+	media := attrValue(n, "media")
+	if media != "" && !strings.Contains(media, "print") && !strings.Contains(media, "all") {
+		return false
+	}
+	for i := range n.Attr {
+		if n.Attr[i].Key == "media" {
+			n.Attr[i].Val = "all"
+		}
+	}
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha1"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -12,11 +13,14 @@ import (
 	"net/url"
 	"path"
 	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/net/html"
 
+	"webarchive/internal/proxy"
 	"webarchive/internal/storage"
 )
 
@@ -24,55 +28,390 @@ type Asset struct {
 	Original string `json:"original"`
 	Stored   string `json:"stored"`
 	Type     string `json:"type"`
+	// SHA256 and Size describe the object as written to storage, so it can
+	// be checked for fixity later.
+	SHA256 string `json:"sha256,omitempty"`
+	Size   int64  `json:"size,omitempty"`
+}
+
+// AssetPrefix is how a stored page refers to its assets. It is relative to
+// /api/archives/<id>/html, so pages keep working when the API sits behind a
+// sub-path or another origin, and under /api/public for published archives.
+func AssetPrefix(archiveID string) string {
+	return "../../assets/" + archiveID + "/"
+}
+
+// LegacyAssetPrefix is the absolute form captures used before asset URLs
+// became relative.
+func LegacyAssetPrefix(archiveID string) string {
+	return "/api/assets/" + archiveID + "/"
 }
 
 type Result struct {
 	HTML   []byte  `json:"html"`
 	Assets []Asset `json:"assets"`
+	// CanonicalURL is the absolute <link rel="canonical"> target, if any.
+	CanonicalURL string `json:"canonicalUrl"`
+	// AssetsFailed counts asset references that could not be stored, and
+	// AssetsDenied those refused with 401 or 403, out of AssetsRequested.
+	AssetsRequested int `json:"assetsRequested"`
+	AssetsFailed    int `json:"assetsFailed"`
+	AssetsDenied    int `json:"assetsDenied"`
+	// Missing lists the distinct asset URLs that failed.
+	Missing []string `json:"missing"`
+	// TrackersBlocked counts elements dropped by Options.BlockTrackers and
+	// ElementsRemoved those dropped by removal selectors.
+	TrackersBlocked int    `json:"trackersBlocked"`
+	ElementsRemoved int    `json:"elementsRemoved"`
+	Report          Report `json:"report"`
 }
 
 type Processor struct {
 	Client  *http.Client
 	Store   *storage.MinioStore
 	BaseURL string
+
+	mu            sync.RWMutex
+	maxAssetBytes int64
+	transport     http.RoundTripper
+	proxyRules    *proxy.Rules
+	userAgent     string
+	headerRules   map[string]http.Header
+	polite        politeState
+	memory        memBudget
+	blocklist     *Blocklist
+	scrubber      *Scrubber
+	removalRules  map[string][]*Selector
+	parseLimits   ParseLimits
+	hostRules     HostRules
+}
+
+const DefaultUserAgent = "WebArchiveBot/0.1"
+
+const (
+	AssetPolicyAll       = "all"
+	AssetPolicyNone      = "none"
+	AssetPolicyNoMedia   = "no-media"
+	AssetPolicyNoScripts = "no-scripts"
+)
+
+type Options struct {
+	AssetPolicy string
+	// Jar supplies cookies for the page and asset requests, so login-gated
+	// content the user can access is fetched as that user.
+	Jar http.CookieJar
+	// IgnoreRobots and Unthrottled bypass the politeness controls, for
+	// captures of the user's own sites.
+	IgnoreRobots bool
+	Unthrottled  bool
+	// Resources holds assets that arrived with the page (an MHTML
+	// package), keyed by absolute URL or "cid:" reference. Offline stops
+	// anything else from being fetched over the network.
+	Resources map[string]Resource
+	Offline   bool
+	// ArchivedLinks maps normalized URLs to existing archive IDs; matching
+	// <a href> links are pointed at the internal viewer.
+	ArchivedLinks map[string]string
+	// Profile adapts processing to a kind of document; ProfileEmail cleans
+	// up mail HTML before its assets are stored.
+	Profile string
+	// BlockTrackers drops ad and analytics elements matching the
+	// processor's blocklist before any asset is fetched.
+	BlockTrackers bool
+	// RemoveSelectors drop matching elements before storage, in addition
+	// to the removal rules for the page's domain.
+	RemoveSelectors []*Selector
+	// HostRules, when set, refuse hosts on top of the processor's own
+	// rules, such as a user's capture deny list.
+	HostRules *HostRules
+}
+
+type Resource struct {
+	ContentType string
+	Body        []byte
+}
+
+func ValidAssetPolicy(policy string) bool {
+	switch policy {
+	case "", AssetPolicyAll, AssetPolicyNone, AssetPolicyNoMedia, AssetPolicyNoScripts:
+		return true
+	}
+	return false
+}
+
+func (o Options) allows(tag string) bool {
+	switch o.AssetPolicy {
+	case AssetPolicyNone:
+		return false
+	case AssetPolicyNoMedia:
+		return tag != "video" && tag != "audio" && tag != "source"
+	case AssetPolicyNoScripts:
+		return tag != "script"
+	}
+	return true
 }
 
 type assetInfo struct {
 	Stored      string
 	ContentType string
+	SHA256      string
+	Size        int64
+}
+
+func (a assetInfo) asset(original string) Asset {
+	return Asset{Original: original, Stored: a.Stored, Type: a.ContentType, SHA256: a.SHA256, Size: a.Size}
+}
+
+// capture is the per-archive state shared by every asset fetch.
+type capture struct {
+	assets    map[string]assetInfo
+	opts      Options
+	requested int
+	failed    int
+	denied    int
+	missing   []string
+	bytes     int64
+	skipped   map[string]bool
+}
+
+// skip records an asset reference the asset policy keeps from being
+// downloaded.
+func (c *capture) skip(base *url.URL, raw string) {
+	raw = strings.TrimSpace(raw)
+	if !liveRef(raw) {
+		return
+	}
+	if base != nil {
+		if u, err := base.Parse(raw); err == nil {
+			raw = u.String()
+		}
+	}
+	c.skipped[raw] = true
+}
+
+// statusError is an asset response outside 2xx.
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("bad status: %d", int(e))
 }
 
 func New(store *storage.MinioStore, timeout time.Duration) *Processor {
-	return &Processor{
-		Store: store,
-		Client: &http.Client{
-			Timeout: timeout,
-		},
+	p := &Processor{
+		Store:         store,
+		maxAssetBytes: 20 << 20,
+	}
+	p.swapTransport(timeout)
+	return p
+}
+
+// SetLimits swaps in a new fetch client and asset size cap. Captures already
+// in progress keep the client they started with.
+func (p *Processor) SetLimits(timeout time.Duration, maxAssetBytes int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.Client = &http.Client{Timeout: timeout, Transport: p.transport}
+	if maxAssetBytes > 0 {
+		p.maxAssetBytes = maxAssetBytes
+	}
+}
+
+// SetBlocklist replaces the hosts Options.BlockTrackers removes; the
+// built-in list is used until it is called.
+func (p *Processor) SetBlocklist(b *Blocklist) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.blocklist = b
+}
+
+// SetScrubber sets the personal data removed from every processed page;
+// nil turns scrubbing off.
+func (p *Processor) SetScrubber(s *Scrubber) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.scrubber = s
+}
+
+// Scrubber returns the scrubber pages are processed with, so callers can
+// apply it to the text they store alongside. It may be nil.
+func (p *Processor) Scrubber() *Scrubber {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.scrubber
+}
+
+func (p *Processor) currentBlocklist() *Blocklist {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.blocklist == nil {
+		return NewBlocklist()
+	}
+	return p.blocklist
+}
+
+// SetParseLimits bounds the pages ProcessWithOptions and CheckHTML accept.
+func (p *Processor) SetParseLimits(limits ParseLimits) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.parseLimits = limits
+}
+
+// CheckHTML rejects a page over the parse limits with a *LimitError, so
+// callers can refuse it before doing any work on it.
+func (p *Processor) CheckHTML(page []byte) error {
+	p.mu.RLock()
+	limits := p.parseLimits
+	p.mu.RUnlock()
+	return limits.Check(page)
+}
+
+// SetRemovalRules sets the selectors removed from pages of particular
+// domains (keyed by lowercase domain, subdomains included).
+func (p *Processor) SetRemovalRules(rules map[string][]*Selector) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removalRules = rules
+}
+
+// removalSelectors returns the selectors of every rule matching host, from
+// the host itself and each of its parent domains.
+func (p *Processor) removalSelectors(host string) []*Selector {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var out []*Selector
+	for host = strings.ToLower(host); host != ""; {
+		out = append(out, p.removalRules[host]...)
+		dot := strings.Index(host, ".")
+		if dot < 0 {
+			break
+		}
+		host = host[dot+1:]
+	}
+	return out
+}
+
+// SetHeaders sets the User-Agent for fetches and the extra headers sent to
+// particular domains (keyed by lowercase domain, subdomains included).
+func (p *Processor) SetHeaders(userAgent string, rules map[string]http.Header) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.userAgent = userAgent
+	p.headerRules = rules
+}
+
+// applyHeaders sets the User-Agent and the most specific matching header
+// rule on req.
+func (p *Processor) applyHeaders(req *http.Request) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ua := p.userAgent
+	if ua == "" {
+		ua = DefaultUserAgent
+	}
+	req.Header.Set("User-Agent", ua)
+	for host := strings.ToLower(req.URL.Hostname()); host != ""; {
+		if h, ok := p.headerRules[host]; ok {
+			for name, values := range h {
+				req.Header[name] = values
+			}
+			return
+		}
+		dot := strings.Index(host, ".")
+		if dot < 0 {
+			return
+		}
+		host = host[dot+1:]
 	}
 }
 
+// SetProxy routes page and asset fetches through rules; nil restores the
+// environment proxy settings.
+func (p *Processor) SetProxy(rules *proxy.Rules) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.proxyRules = rules
+	p.swapTransport(p.Client.Timeout)
+}
+
+func (p *Processor) limits() (*http.Client, int64) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Client, p.maxAssetBytes
+}
+
 func (p *Processor) Process(ctx context.Context, archiveID string, pageURL string, rawHTML []byte) (*Result, error) {
+	return p.ProcessWithOptions(ctx, archiveID, pageURL, rawHTML, Options{})
+}
+
+func (p *Processor) ProcessWithOptions(ctx context.Context, archiveID string, pageURL string, rawHTML []byte, opts Options) (*Result, error) {
 	if len(rawHTML) == 0 {
 		return nil, errors.New("empty html")
 	}
+	if err := p.CheckHTML(rawHTML); err != nil {
+		return nil, err
+	}
 
-	cache := make(map[string]assetInfo)
+	start := time.Now()
+	run := &capture{assets: make(map[string]assetInfo), opts: opts, skipped: map[string]bool{}}
 	doc, err := html.Parse(bytes.NewReader(rawHTML))
 	if err != nil {
 		return nil, err
 	}
 
+	if opts.Profile == ProfileEmail {
+		sanitizeEmail(doc, opts.Resources)
+	}
+	anchorHeadings(doc)
+
 	base, _ := url.Parse(pageURL)
+	trackers := 0
+	if opts.BlockTrackers {
+		trackers = stripTrackers(doc, base, p.currentBlocklist())
+	}
+	removal := opts.RemoveSelectors
+	if base != nil {
+		removal = append(p.removalSelectors(base.Hostname()), removal...)
+	}
+	removed := removeSelected(doc, removal)
+	scrubbed := p.Scrubber().scrubDoc(doc)
 	assets := make([]Asset, 0)
+	canonical := ""
+	internalLinks := 0
+	var left unarchived
 
 	var walk func(*html.Node)
 	walk = func(n *html.Node) {
+		if len(opts.ArchivedLinks) > 0 && n.Type == html.ElementNode && strings.EqualFold(n.Data, "a") && rewriteArchivedLink(n, base, opts.ArchivedLinks) {
+			internalLinks++
+		}
+		if canonical == "" && n.Type == html.ElementNode && strings.EqualFold(n.Data, "link") && base != nil &&
+			strings.EqualFold(strings.TrimSpace(attrValue(n, "rel")), "canonical") {
+			if ref, err := base.Parse(strings.TrimSpace(attrValue(n, "href"))); err == nil && (ref.Scheme == "http" || ref.Scheme == "https") {
+				canonical = ref.String()
+			}
+		}
+		// A <base> element would redirect the relative asset URLs back to the
+		// original site; links were already resolved against the page URL.
+		if n.Type == html.ElementNode && strings.EqualFold(n.Data, "base") {
+			for i := range n.Attr {
+				if n.Attr[i].Key == "href" {
+					n.Attr = append(n.Attr[:i], n.Attr[i+1:]...)
+					break
+				}
+			}
+		}
 		if n.Type == html.ElementNode {
+			left.note(n)
+			if !opts.allows(strings.ToLower(n.Data)) {
+				run.skip(base, policyRef(n))
+			}
+		}
+		if n.Type == html.ElementNode && opts.allows(strings.ToLower(n.Data)) {
 			switch strings.ToLower(n.Data) {
 			case "img", "source", "video", "audio", "script":
 				for i := range n.Attr {
 					if n.Attr[i].Key == "src" {
-						updated, foundAssets := p.handleURL(ctx, archiveID, base, n.Attr[i].Val, cache)
+						updated, foundAssets := p.handleURL(ctx, archiveID, base, n.Attr[i].Val, run)
 						if updated != "" {
 							n.Attr[i].Val = updated
 						}
@@ -88,7 +427,7 @@ func (p *Processor) Process(ctx context.Context, archiveID string, pageURL strin
 					for _, key := range lazyAttrs {
 						for i := range n.Attr {
 							if n.Attr[i].Key == key {
-								updated, foundAssets := p.handleURL(ctx, archiveID, base, n.Attr[i].Val, cache)
+								updated, foundAssets := p.handleURL(ctx, archiveID, base, n.Attr[i].Val, run)
 								if updated != "" {
 									n.Attr[i].Key = "src"
 									n.Attr[i].Val = updated
@@ -102,7 +441,7 @@ func (p *Processor) Process(ctx context.Context, archiveID string, pageURL strin
 					}
 					for i := range n.Attr {
 						if n.Attr[i].Key == "srcset" {
-							updated, foundAssets := p.handleSrcset(ctx, archiveID, base, n.Attr[i].Val, cache)
+							updated, foundAssets := p.handleSrcset(ctx, archiveID, base, n.Attr[i].Val, run)
 							if updated != "" {
 								n.Attr[i].Val = updated
 							}
@@ -118,7 +457,7 @@ func (p *Processor) Process(ctx context.Context, archiveID string, pageURL strin
 				if strings.Contains(rel, "stylesheet") || strings.Contains(rel, "icon") {
 					for i := range n.Attr {
 						if n.Attr[i].Key == "href" {
-							updated, foundAssets := p.handleURL(ctx, archiveID, base, n.Attr[i].Val, cache)
+							updated, foundAssets := p.handleURL(ctx, archiveID, base, n.Attr[i].Val, run)
 							if updated != "" {
 								n.Attr[i].Val = updated
 							}
@@ -137,16 +476,41 @@ func (p *Processor) Process(ctx context.Context, archiveID string, pageURL strin
 	}
 
 	walk(doc)
+	if internalLinks > 0 {
+		injectLinkStyle(doc)
+	}
 
 	var out bytes.Buffer
 	if err := html.Render(&out, doc); err != nil {
 		return nil, err
 	}
 
-	return &Result{HTML: out.Bytes(), Assets: assets}, nil
+	return &Result{
+		HTML:            out.Bytes(),
+		Assets:          assets,
+		CanonicalURL:    canonical,
+		AssetsRequested: run.requested,
+		AssetsFailed:    run.failed,
+		AssetsDenied:    run.denied,
+		Missing:         run.missing,
+		TrackersBlocked: trackers,
+		ElementsRemoved: removed,
+		Report: Report{
+			AssetsFound:      run.requested + len(run.skipped),
+			AssetsDownloaded: run.requested - run.failed,
+			AssetsFailed:     run.failed,
+			AssetsSkipped:    len(run.skipped),
+			TrackersBlocked:  trackers,
+			ElementsRemoved:  removed,
+			PIIRemoved:       scrubbed,
+			Bytes:            int64(out.Len()) + run.bytes,
+			ElapsedMs:        time.Since(start).Milliseconds(),
+			Warnings:         left.warnings(len(run.skipped), opts.AssetPolicy),
+		},
+	}, nil
 }
 
-func (p *Processor) handleSrcset(ctx context.Context, archiveID string, base *url.URL, raw string, cache map[string]assetInfo) (string, []Asset) {
+func (p *Processor) handleSrcset(ctx context.Context, archiveID string, base *url.URL, raw string, run *capture) (string, []Asset) {
 	parts := strings.Split(raw, ",")
 	assets := make([]Asset, 0)
 	updatedParts := make([]string, 0, len(parts))
@@ -164,7 +528,7 @@ func (p *Processor) handleSrcset(ctx context.Context, archiveID string, base *ur
 		if len(fields) > 1 {
 			descriptor = " " + strings.Join(fields[1:], " ")
 		}
-		updated, foundAssets := p.handleURL(ctx, archiveID, base, urlPart, cache)
+		updated, foundAssets := p.handleURL(ctx, archiveID, base, urlPart, run)
 		if updated == "" {
 			updated = urlPart
 		}
@@ -179,11 +543,16 @@ func (p *Processor) handleSrcset(ctx context.Context, archiveID string, base *ur
 	return strings.Join(updatedParts, ", "), assets
 }
 
-func (p *Processor) handleURL(ctx context.Context, archiveID string, base *url.URL, raw string, cache map[string]assetInfo) (string, []Asset) {
+func (p *Processor) handleURL(ctx context.Context, archiveID string, base *url.URL, raw string, run *capture) (string, []Asset) {
 	raw = strings.TrimSpace(raw)
 	if raw == "" || strings.HasPrefix(raw, "data:") || strings.HasPrefix(raw, "javascript:") {
 		return raw, nil
 	}
+	// Already stored: a saved page can be processed again to retry only
+	// what failed the first time.
+	if strings.HasPrefix(raw, AssetPrefix(archiveID)) || strings.HasPrefix(raw, LegacyAssetPrefix(archiveID)) {
+		return raw, nil
+	}
 
 	u, err := url.Parse(raw)
 	if err != nil {
@@ -194,49 +563,48 @@ func (p *Processor) handleURL(ctx context.Context, archiveID string, base *url.U
 		u = base.ResolveReference(u)
 	}
 
-	if u.Scheme != "http" && u.Scheme != "https" {
+	if _, packaged := run.opts.Resources[u.String()]; !packaged && u.Scheme != "http" && u.Scheme != "https" {
 		return raw, nil
 	}
 
-	storedPath, contentType, extraAssets, err := p.downloadAndStore(ctx, archiveID, u.String(), cache)
+	info, extraAssets, err := p.downloadAndStore(ctx, archiveID, u.String(), run)
 	if err != nil {
 		return raw, nil
 	}
 
-	apiPath := fmt.Sprintf("/api/assets/%s/%s", archiveID, storedPath)
+	apiPath := AssetPrefix(archiveID) + info.Stored
 	assets := make([]Asset, 0, 1+len(extraAssets))
-	assets = append(assets, Asset{Original: u.String(), Stored: storedPath, Type: contentType})
+	assets = append(assets, info.asset(u.String()))
 	if len(extraAssets) > 0 {
 		assets = append(assets, extraAssets...)
 	}
 	return apiPath, assets
 }
 
-func (p *Processor) downloadAndStore(ctx context.Context, archiveID string, rawURL string, cache map[string]assetInfo) (string, string, []Asset, error) {
-	if info, ok := cache[rawURL]; ok {
-		return info.Stored, info.ContentType, nil, nil
-	}
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
-	if err != nil {
-		return "", "", nil, err
-	}
-	req.Header.Set("User-Agent", "WebArchiveBot/0.1")
-
-	resp, err := p.Client.Do(req)
-	if err != nil {
-		return "", "", nil, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return "", "", nil, fmt.Errorf("bad status: %d", resp.StatusCode)
+func (p *Processor) downloadAndStore(ctx context.Context, archiveID string, rawURL string, run *capture) (_ assetInfo, _ []Asset, err error) {
+	if info, ok := run.assets[rawURL]; ok {
+		return info, nil, nil
 	}
+	run.requested++
+	defer func() {
+		if err == nil {
+			return
+		}
+		run.failed++
+		if !slices.Contains(run.missing, rawURL) {
+			run.missing = append(run.missing, rawURL)
+		}
+		var status statusError
+		if errors.As(err, &status) && (status == http.StatusUnauthorized || status == http.StatusForbidden) {
+			run.denied++
+		}
+	}()
 
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 20<<20))
+	body, header, err := p.assetBody(ctx, rawURL, run)
 	if err != nil {
-		return "", "", nil, err
+		return assetInfo{}, nil, err
 	}
+	defer body.Close()
 
 	parsed, _ := url.Parse(rawURL)
 	ext := ""
@@ -244,7 +612,7 @@ func (p *Processor) downloadAndStore(ctx context.Context, archiveID string, rawU
 		ext = path.Ext(parsed.Path)
 	}
 	if ext == "" {
-		if ct := resp.Header.Get("Content-Type"); ct != "" {
+		if ct := header.Get("Content-Type"); ct != "" {
 			if exts, _ := mimeExtensions(ct); len(exts) > 0 {
 				ext = exts[0]
 			}
@@ -258,27 +626,152 @@ func (p *Processor) downloadAndStore(ctx context.Context, archiveID string, rawU
 	name := hex.EncodeToString(hash[:]) + ext
 
 	objectPath := path.Join(storage.ArchivePrefix(archiveID), "assets", name)
-	contentType := storage.GuessContentType(name, resp.Header.Get("Content-Type"))
+	contentType := storage.GuessContentType(name, header.Get("Content-Type"))
 
 	extraAssets := []Asset{}
+	var info assetInfo
 	if strings.Contains(contentType, "text/css") || strings.EqualFold(ext, ".css") {
-		rewritten, assets, err := p.rewriteCSS(ctx, archiveID, rawURL, body, cache)
+		css, err := io.ReadAll(body)
+		// Closing frees the fetch slot before recursing into imports.
+		body.Close()
+		if err != nil {
+			return assetInfo{}, nil, err
+		}
+		rewritten, assets, err := p.rewriteCSS(ctx, archiveID, rawURL, css, run)
 		if err == nil {
-			body = rewritten
+			css = rewritten
 			extraAssets = append(extraAssets, assets...)
 		}
+		if err := p.Store.PutBytes(ctx, objectPath, css, contentType); err != nil {
+			return assetInfo{}, nil, err
+		}
+		sum := sha256.Sum256(css)
+		info = assetInfo{SHA256: hex.EncodeToString(sum[:]), Size: int64(len(css))}
+	} else {
+		info, err = p.storeAsset(ctx, objectPath, body, contentType)
+		if err != nil {
+			return assetInfo{}, nil, err
+		}
+	}
+
+	info.Stored = path.Join("assets", name)
+	info.ContentType = contentType
+	run.assets[rawURL] = info
+	run.bytes += info.Size
+	return info, extraAssets, nil
+}
+
+// storeAsset writes body to storage. Small assets are uploaded in one piece;
+// larger ones stream through a multipart upload, so memory use stays at one
+// part per upload, bounded overall by the processor's memory budget. Assets
+// of sealed archives can't stream: they reserve room for the largest object
+// the store seals, held plain and sealed.
+func (p *Processor) storeAsset(ctx context.Context, objectPath string, body io.Reader, contentType string) (assetInfo, error) {
+	release, err := p.memory.acquire(ctx, smallAssetBytes)
+	if err != nil {
+		return assetInfo{}, err
+	}
+	var head bytes.Buffer
+	if _, err := head.ReadFrom(io.LimitReader(body, smallAssetBytes+1)); err != nil {
+		release()
+		return assetInfo{}, err
+	}
+	if head.Len() <= smallAssetBytes {
+		defer release()
+		if err := p.Store.PutBytes(ctx, objectPath, head.Bytes(), contentType); err != nil {
+			return assetInfo{}, err
+		}
+		sum := sha256.Sum256(head.Bytes())
+		return assetInfo{SHA256: hex.EncodeToString(sum[:]), Size: int64(head.Len())}, nil
+	}
+	release()
+
+	reserve := int64(storage.StreamPartSize + smallAssetBytes)
+	if p.Store.Seals(objectPath) {
+		reserve = 2 * storage.MaxSealedBytes
+	}
+	release, err = p.memory.acquire(ctx, reserve)
+	if err != nil {
+		return assetInfo{}, err
+	}
+	defer release()
+	hash := sha256.New()
+	counter := &countingWriter{}
+	stream := io.TeeReader(io.MultiReader(&head, body), io.MultiWriter(hash, counter))
+	if err := p.Store.PutStream(ctx, objectPath, stream, -1, contentType); err != nil {
+		return assetInfo{}, err
+	}
+	return assetInfo{SHA256: hex.EncodeToString(hash.Sum(nil)), Size: counter.n}, nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (w *countingWriter) Write(b []byte) (int, error) {
+	w.n += int64(len(b))
+	return len(b), nil
+}
+
+// assetBody returns a packaged resource when the capture has one for
+// rawURL and downloads it otherwise. The caller must close the body.
+func (p *Processor) assetBody(ctx context.Context, rawURL string, run *capture) (io.ReadCloser, http.Header, error) {
+	if res, ok := run.opts.Resources[rawURL]; ok {
+		return io.NopCloser(bytes.NewReader(res.Body)), http.Header{"Content-Type": {res.ContentType}}, nil
 	}
+	if run.opts.Offline {
+		return nil, nil, errors.New("not in snapshot")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.applyHeaders(req)
+	return p.fetchAsset(ctx, req, run)
+}
 
-	if err := p.Store.PutBytes(ctx, objectPath, body, contentType); err != nil {
-		return "", "", nil, err
+// fetchAsset starts downloading one asset under the politeness limits. The
+// slot is held until the returned body is closed, which callers do before
+// recursing into stylesheet imports.
+func (p *Processor) fetchAsset(ctx context.Context, req *http.Request, run *capture) (io.ReadCloser, http.Header, error) {
+	if err := p.checkHost(req.URL.Hostname(), run.opts); err != nil {
+		return nil, nil, err
+	}
+	release, err := p.acquire(ctx, req.URL, run.opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	client, maxBytes := p.limits()
+	resp, err := p.fetchClient(client, run.opts, nil).Do(req)
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		resp.Body.Close()
+		release()
+		return nil, nil, statusError(resp.StatusCode)
 	}
+	return &assetStream{Reader: io.LimitReader(resp.Body, maxBytes), body: resp.Body, release: release}, resp.Header, nil
+}
+
+type assetStream struct {
+	io.Reader
+	body    io.Closer
+	release func()
+	once    sync.Once
+}
 
-	storedPath := path.Join("assets", name)
-	cache[rawURL] = assetInfo{Stored: storedPath, ContentType: contentType}
-	return storedPath, contentType, extraAssets, nil
+func (a *assetStream) Close() error {
+	var err error
+	a.once.Do(func() {
+		err = a.body.Close()
+		a.release()
+	})
+	return err
 }
 
-func (p *Processor) rewriteCSS(ctx context.Context, archiveID string, cssURL string, css []byte, cache map[string]assetInfo) ([]byte, []Asset, error) {
+func (p *Processor) rewriteCSS(ctx context.Context, archiveID string, cssURL string, css []byte, run *capture) ([]byte, []Asset, error) {
 	base, err := url.Parse(cssURL)
 	if err != nil {
 		return css, nil, err
@@ -301,15 +794,17 @@ func (p *Processor) rewriteCSS(ctx context.Context, archiveID string, cssURL str
 		if base != nil {
 			u = base.ResolveReference(u)
 		}
-		if u.Scheme != "http" && u.Scheme != "https" {
+		if _, packaged := run.opts.Resources[u.String()]; !packaged && u.Scheme != "http" && u.Scheme != "https" {
 			return "", nil, nil
 		}
-		storedPath, contentType, extraAssets, err := p.downloadAndStore(ctx, archiveID, u.String(), cache)
+		info, extraAssets, err := p.downloadAndStore(ctx, archiveID, u.String(), run)
 		if err != nil {
 			return "", nil, nil
 		}
-		apiPath := fmt.Sprintf("/api/assets/%s/%s", archiveID, storedPath)
-		return apiPath, &Asset{Original: u.String(), Stored: storedPath, Type: contentType}, extraAssets
+		// Stylesheets live next to their assets, so a bare file name is enough.
+		apiPath := path.Base(info.Stored)
+		asset := info.asset(u.String())
+		return apiPath, &asset, extraAssets
 	}
 
 	cssText := string(css)
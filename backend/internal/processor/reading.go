@@ -0,0 +1,44 @@
+package processor
+
+import (
+	"math"
+	"unicode"
+)
+
+const (
+	wordsPerMinute    = 230
+	cjkCharsPerMinute = 400
+)
+
+// ReadingStats counts words in text and estimates reading time in whole
+// minutes. CJK characters are counted individually and read at a different
+// rate, since those scripts don't separate words with spaces.
+func ReadingStats(text string) (words int, minutes int) {
+	cjk := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			cjk++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				words++
+				inWord = true
+			}
+		case r == '\'' || r == '-':
+			// keep contractions and hyphenated words together
+		default:
+			inWord = false
+		}
+	}
+	if words == 0 && cjk == 0 {
+		return 0, 0
+	}
+	m := float64(words)/wordsPerMinute + float64(cjk)/cjkCharsPerMinute
+	return words + cjk, int(math.Max(1, math.Ceil(m)))
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Report summarises what processing did to a page, so a capture can be
+// judged without opening it.
+type Report struct {
+	// AssetsFound counts the distinct asset URLs the page referenced;
+	// each was downloaded, failed, or skipped by the asset policy.
+	AssetsFound      int `json:"assetsFound"`
+	AssetsDownloaded int `json:"assetsDownloaded"`
+	AssetsFailed     int `json:"assetsFailed"`
+	AssetsSkipped    int `json:"assetsSkipped"`
+	TrackersBlocked  int `json:"trackersBlocked"`
+	ElementsRemoved  int `json:"elementsRemoved"`
+	// PIIRemoved counts the personal data matches the scrubber replaced.
+	PIIRemoved int `json:"piiRemoved"`
+	// Bytes is the stored page plus every asset downloaded for it.
+	Bytes     int64 `json:"bytes"`
+	ElapsedMs int64 `json:"elapsedMs"`
+	// Warnings name what was left pointing at the live site.
+	Warnings []string `json:"warnings"`
+	// Extractor names the site extractor that supplied the title and text,
+	// if any. Set by the caller; the processor doesn't run extractors.
+	Extractor string `json:"extractor,omitempty"`
+}
+
+// unarchived counts references the processor leaves untouched, reported as
+// warnings.
+type unarchived struct {
+	iframes int
+	sources int
+	posters int
+	embeds  int
+}
+
+func (u *unarchived) note(n *html.Node) {
+	switch strings.ToLower(n.Data) {
+	case "iframe", "frame":
+		if liveRef(attrValue(n, "src")) {
+			u.iframes++
+		}
+	case "source":
+		if attrValue(n, "srcset") != "" {
+			u.sources++
+		}
+	case "video":
+		if liveRef(attrValue(n, "poster")) {
+			u.posters++
+		}
+	case "embed":
+		if liveRef(attrValue(n, "src")) {
+			u.embeds++
+		}
+	case "object":
+		if liveRef(attrValue(n, "data")) {
+			u.embeds++
+		}
+	}
+}
+
+func liveRef(ref string) bool {
+	ref = strings.TrimSpace(ref)
+	return ref != "" && !strings.HasPrefix(ref, "data:") && !strings.HasPrefix(ref, "about:") && !strings.HasPrefix(ref, "javascript:")
+}
+
+func (u unarchived) warnings(skipped int, policy string) []string {
+	out := []string{}
+	if u.iframes > 0 {
+		out = append(out, fmt.Sprintf("%d iframe(s) not archived, still loaded from the live site", u.iframes))
+	}
+	if u.sources > 0 {
+		out = append(out, fmt.Sprintf("%d <source srcset> skipped", u.sources))
+	}
+	if u.posters > 0 {
+		out = append(out, fmt.Sprintf("%d video poster(s) not archived", u.posters))
+	}
+	if u.embeds > 0 {
+		out = append(out, fmt.Sprintf("%d embed/object(s) not archived", u.embeds))
+	}
+	if skipped > 0 {
+		out = append(out, fmt.Sprintf("%d asset(s) skipped by asset policy %q", skipped, policy))
+	}
+	return out
+}
+
+// policyRef is the asset URL an element the asset policy covers would have
+// been downloaded from.
+func policyRef(n *html.Node) string {
+	switch strings.ToLower(n.Data) {
+	case "img", "source", "video", "audio", "script":
+		return attrRaw(n, "src")
+	case "link":
+		rel := attrValue(n, "rel")
+		if strings.Contains(rel, "stylesheet") || strings.Contains(rel, "icon") {
+			return attrRaw(n, "href")
+		}
+	}
+	return ""
+}
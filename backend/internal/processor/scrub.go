@@ -0,0 +1,247 @@
+package processor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// Built-in scrub rules.
+const (
+	ScrubEmail  = "email"
+	ScrubPhone  = "phone"
+	ScrubIDCard = "idcard"
+	ScrubCard   = "card"
+	ScrubIP     = "ip"
+)
+
+// scrubReplacement stands in for custom patterns and terms.
+const scrubReplacement = "[redacted]"
+
+type scrubRule struct {
+	re          *regexp.Regexp
+	replacement string
+	// digits rules only match where the neighbouring characters are not
+	// digits, so they don't cut numbers out of longer ones.
+	digits bool
+	valid  func(string) bool
+}
+
+var builtinScrubRules = map[string]scrubRule{
+	ScrubEmail: {re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)},
+	// Mainland mobile numbers, numbers written with a country code, and the
+	// North American (555) 123-4567 form.
+	ScrubPhone:  {re: regexp.MustCompile(`(?:\+?86[ -]?)?1[3-9]\d(?:[ -]?\d{4}){2}|\+\d{1,3}[ -]?\(?\d{1,4}\)?(?:[ -]?\d{2,4}){2,4}|\(\d{3}\) ?\d{3}-\d{4}`), digits: true},
+	ScrubIDCard: {re: regexp.MustCompile(`[1-9]\d{5}(?:18|19|20)\d{2}(?:0[1-9]|1[0-2])(?:0[1-9]|[12]\d|3[01])\d{3}[\dXx]`), digits: true, valid: validIDCard},
+	ScrubCard:   {re: regexp.MustCompile(`\d(?:[ -]?\d){12,18}`), digits: true, valid: validLuhn},
+	ScrubIP:     {re: regexp.MustCompile(`\d{1,3}(?:\.\d{1,3}){3}`), digits: true, valid: validIPv4},
+}
+
+// ScrubRules lists the built-in rule names.
+func ScrubRules() []string {
+	return []string{ScrubEmail, ScrubPhone, ScrubIDCard, ScrubCard, ScrubIP}
+}
+
+// Scrubber removes personal data from pages and text before they are
+// stored: the built-in rules it was made with, custom regular expressions
+// and literal terms (matched case-insensitively), such as the user's own
+// name or account ID.
+type Scrubber struct {
+	rules []scrubRule
+}
+
+func NewScrubber(rules []string) (*Scrubber, error) {
+	s := &Scrubber{}
+	for _, name := range rules {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		rule, ok := builtinScrubRules[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown scrub rule %q (want %s)", name, strings.Join(ScrubRules(), ", "))
+		}
+		if rule.replacement == "" {
+			rule.replacement = "[" + name + "]"
+		}
+		s.rules = append(s.rules, rule)
+	}
+	return s, nil
+}
+
+// AddPattern adds a custom regular expression.
+func (s *Scrubber) AddPattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	s.rules = append(s.rules, scrubRule{re: re, replacement: scrubReplacement})
+	return nil
+}
+
+// AddTerms adds literal strings to remove.
+func (s *Scrubber) AddTerms(terms []string) {
+	for _, t := range terms {
+		if t = strings.TrimSpace(t); t != "" {
+			s.rules = append(s.rules, scrubRule{re: regexp.MustCompile(`(?i)` + regexp.QuoteMeta(t)), replacement: scrubReplacement})
+		}
+	}
+}
+
+// Load reads one regular expression per line; blank lines and lines
+// starting with # are skipped. It returns how many patterns were added.
+func (s *Scrubber) Load(r io.Reader) (int, error) {
+	sc := bufio.NewScanner(r)
+	added, line := 0, 0
+	for sc.Scan() {
+		line++
+		pattern := strings.TrimSpace(sc.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") {
+			continue
+		}
+		if err := s.AddPattern(pattern); err != nil {
+			return added, fmt.Errorf("line %d: %w", line, err)
+		}
+		added++
+	}
+	return added, sc.Err()
+}
+
+// Empty reports whether the scrubber has nothing to remove.
+func (s *Scrubber) Empty() bool {
+	return s == nil || len(s.rules) == 0
+}
+
+// Text returns in with every match replaced and the number of matches.
+func (s *Scrubber) Text(in string) (string, int) {
+	if s.Empty() {
+		return in, 0
+	}
+	total := 0
+	for _, rule := range s.rules {
+		var n int
+		in, n = rule.apply(in)
+		total += n
+	}
+	return in, total
+}
+
+func (r scrubRule) apply(in string) (string, int) {
+	matches := r.re.FindAllStringIndex(in, -1)
+	if len(matches) == 0 {
+		return in, 0
+	}
+	var b strings.Builder
+	last, n := 0, 0
+	for _, m := range matches {
+		match := in[m[0]:m[1]]
+		if r.digits && (digitBefore(in, m[0]) || digitAfter(in, m[1])) {
+			continue
+		}
+		if r.valid != nil && !r.valid(match) {
+			continue
+		}
+		b.WriteString(in[last:m[0]])
+		b.WriteString(r.replacement)
+		last = m[1]
+		n++
+	}
+	if n == 0 {
+		return in, 0
+	}
+	b.WriteString(in[last:])
+	return b.String(), n
+}
+
+func digitBefore(s string, i int) bool {
+	return i > 0 && (unicode.IsDigit(rune(s[i-1])) || i > 1 && s[i-1] == '.' && unicode.IsDigit(rune(s[i-2])))
+}
+
+func digitAfter(s string, i int) bool {
+	return i < len(s) && (unicode.IsDigit(rune(s[i])) || i+1 < len(s) && s[i] == '.' && unicode.IsDigit(rune(s[i+1])))
+}
+
+// urlAttrs hold references the processor resolves and fetches; scrubbing
+// them would break the page, except for mailto: and tel: links.
+var urlAttrs = map[string]bool{"src": true, "srcset": true, "href": true, "data": true, "poster": true, "action": true, "background": true}
+
+// scrubDoc scrubs every text node, including scripts whose embedded state
+// often carries the signed-in user's details, and attribute values.
+func (s *Scrubber) scrubDoc(doc *html.Node) int {
+	if s.Empty() {
+		return 0
+	}
+	total := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode, html.CommentNode:
+			var k int
+			n.Data, k = s.Text(n.Data)
+			total += k
+		case html.ElementNode:
+			for i, a := range n.Attr {
+				if urlAttrs[strings.ToLower(a.Key)] {
+					lower := strings.ToLower(strings.TrimSpace(a.Val))
+					if !strings.HasPrefix(lower, "mailto:") && !strings.HasPrefix(lower, "tel:") {
+						continue
+					}
+				}
+				var k int
+				n.Attr[i].Val, k = s.Text(a.Val)
+				total += k
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return total
+}
+
+func validLuhn(s string) bool {
+	sum, double, count := 0, false, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if double {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+		count++
+	}
+	return count >= 13 && sum%10 == 0
+}
+
+// validIDCard checks the ISO 7064 MOD 11-2 check digit of a mainland
+// resident ID number.
+func validIDCard(s string) bool {
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	sum := 0
+	for i, w := range weights {
+		sum += int(s[i]-'0') * w
+	}
+	return "10X98765432"[sum%11] == strings.ToUpper(s[17:])[0]
+}
+
+func validIPv4(s string) bool {
+	for _, part := range strings.Split(s, ".") {
+		if v, err := strconv.Atoi(part); err != nil || v > 255 || len(part) > 1 && part[0] == '0' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,357 @@
+package processor
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Selector is a parsed CSS selector list used to remove page chrome (cookie
+// banners, newsletter modals, sticky headers) before a page is stored. The
+// supported subset is type, universal, #id, .class and attribute selectors
+// ([a], [a=v], [a~=v], [a|=v], [a^=v], [a$=v], [a*=v]) combined with the
+// descendant and child combinators; pseudo-classes are rejected.
+type Selector struct {
+	source string
+	chains [][]selectorStep
+}
+
+type selectorStep struct {
+	// child is true when the step is joined to the previous one by ">".
+	child   bool
+	tag     string
+	id      string
+	classes []string
+	attrs   []attrMatcher
+}
+
+type attrMatcher struct {
+	key, op, val string
+}
+
+func (s *Selector) String() string { return s.source }
+
+// ParseSelector parses a comma-separated selector list.
+func ParseSelector(source string) (*Selector, error) {
+	sel := &Selector{source: strings.TrimSpace(source)}
+	if sel.source == "" {
+		return nil, errors.New("empty selector")
+	}
+	for _, part := range splitSelectorList(sel.source) {
+		chain, err := parseChain(part)
+		if err != nil {
+			return nil, fmt.Errorf("selector %q: %w", part, err)
+		}
+		sel.chains = append(sel.chains, chain)
+	}
+	return sel, nil
+}
+
+// ParseSelectors parses each entry of list, skipping blank ones.
+func ParseSelectors(list []string) ([]*Selector, error) {
+	out := make([]*Selector, 0, len(list))
+	for _, source := range list {
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		sel, err := ParseSelector(source)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sel)
+	}
+	return out, nil
+}
+
+// splitSelectorList splits on commas outside attribute brackets and quotes.
+func splitSelectorList(s string) []string {
+	var parts []string
+	depth, quote, start := 0, byte(0), 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+func parseChain(s string) ([]selectorStep, error) {
+	if s == "" {
+		return nil, errors.New("empty selector")
+	}
+	var chain []selectorStep
+	child := false
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '>':
+			if child || len(chain) == 0 {
+				return nil, errors.New("misplaced >")
+			}
+			child = true
+			i++
+			continue
+		case c == '+' || c == '~':
+			return nil, fmt.Errorf("combinator %q not supported", c)
+		}
+		step, n, err := parseStep(s[i:])
+		if err != nil {
+			return nil, err
+		}
+		step.child = child
+		child = false
+		chain = append(chain, step)
+		i += n
+	}
+	if child || len(chain) == 0 {
+		return nil, errors.New("dangling >")
+	}
+	return chain, nil
+}
+
+// parseStep reads one compound selector and returns how many bytes it used.
+func parseStep(s string) (selectorStep, int, error) {
+	var step selectorStep
+	i := 0
+	if i < len(s) && s[i] == '*' {
+		i++
+	} else if n := identLen(s[i:]); n > 0 {
+		step.tag = strings.ToLower(s[i : i+n])
+		i += n
+	}
+	for i < len(s) {
+		switch s[i] {
+		case '#', '.':
+			n := identLen(s[i+1:])
+			if n == 0 {
+				return step, 0, fmt.Errorf("expected name after %q", s[i])
+			}
+			if s[i] == '#' {
+				step.id = s[i+1 : i+1+n]
+			} else {
+				step.classes = append(step.classes, s[i+1:i+1+n])
+			}
+			i += 1 + n
+		case '[':
+			m, n, err := parseAttr(s[i:])
+			if err != nil {
+				return step, 0, err
+			}
+			step.attrs = append(step.attrs, m)
+			i += n
+		case ':':
+			return step, 0, errors.New("pseudo-classes not supported")
+		case ' ', '\t', '\n', '>', '+', '~':
+			return step, i, nil
+		default:
+			return step, 0, fmt.Errorf("unexpected %q", s[i])
+		}
+	}
+	if i == 0 {
+		return step, 0, errors.New("empty selector")
+	}
+	return step, i, nil
+}
+
+func parseAttr(s string) (attrMatcher, int, error) {
+	end := -1
+	quote := byte(0)
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		if c == '"' || c == '\'' {
+			quote = c
+		} else if c == ']' {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return attrMatcher{}, 0, errors.New("unterminated [")
+	}
+	body := strings.TrimSpace(s[1:end])
+	m := attrMatcher{}
+	if eq := strings.IndexByte(body, '='); eq >= 0 {
+		m.key, m.op = body[:eq], "="
+		if eq > 0 && strings.ContainsRune("~|^$*", rune(body[eq-1])) {
+			m.key, m.op = body[:eq-1], body[eq-1:eq+1]
+		}
+		m.val = strings.TrimSpace(body[eq+1:])
+		if len(m.val) >= 2 && (m.val[0] == '"' || m.val[0] == '\'') && m.val[len(m.val)-1] == m.val[0] {
+			m.val = m.val[1 : len(m.val)-1]
+		}
+	} else {
+		m.key = body
+	}
+	m.key = strings.ToLower(strings.TrimSpace(m.key))
+	if m.key == "" || identLen(m.key) != len(m.key) {
+		return attrMatcher{}, 0, fmt.Errorf("invalid attribute selector [%s]", body)
+	}
+	return m, end + 1, nil
+}
+
+func identLen(s string) int {
+	n := 0
+	for n < len(s) {
+		c := s[n]
+		if c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80 {
+			n++
+			continue
+		}
+		break
+	}
+	return n
+}
+
+// Match reports whether the element matches any selector in the list.
+func (s *Selector) Match(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	for _, chain := range s.chains {
+		if matchChain(n, chain) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchChain(n *html.Node, chain []selectorStep) bool {
+	last := len(chain) - 1
+	if !chain[last].match(n) {
+		return false
+	}
+	if last == 0 {
+		return true
+	}
+	rest := chain[:last]
+	for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		if matchChain(p, rest) {
+			return true
+		}
+		if chain[last].child {
+			return false
+		}
+	}
+	return false
+}
+
+func (st selectorStep) match(n *html.Node) bool {
+	if st.tag != "" && !strings.EqualFold(n.Data, st.tag) {
+		return false
+	}
+	if st.id != "" && attrRaw(n, "id") != st.id {
+		return false
+	}
+	if len(st.classes) > 0 {
+		have := strings.Fields(attrRaw(n, "class"))
+		for _, want := range st.classes {
+			if !containsString(have, want) {
+				return false
+			}
+		}
+	}
+	for _, m := range st.attrs {
+		if !m.match(n) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m attrMatcher) match(n *html.Node) bool {
+	for _, a := range n.Attr {
+		if a.Namespace != "" || !strings.EqualFold(a.Key, m.key) {
+			continue
+		}
+		switch m.op {
+		case "":
+			return true
+		case "=":
+			return a.Val == m.val
+		case "~=":
+			return containsString(strings.Fields(a.Val), m.val)
+		case "|=":
+			return a.Val == m.val || strings.HasPrefix(a.Val, m.val+"-")
+		case "^=":
+			return m.val != "" && strings.HasPrefix(a.Val, m.val)
+		case "$=":
+			return m.val != "" && strings.HasSuffix(a.Val, m.val)
+		case "*=":
+			return m.val != "" && strings.Contains(a.Val, m.val)
+		}
+	}
+	return false
+}
+
+func containsString(list []string, v string) bool {
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// removeSelected drops every element matching one of sels, together with its
+// subtree, and returns how many elements were removed. <html>, <head> and
+// <body> are never removed.
+func removeSelected(doc *html.Node, sels []*Selector) int {
+	if len(sels) == 0 {
+		return 0
+	}
+	removed := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; {
+			next := c.NextSibling
+			if c.Type == html.ElementNode && !structuralElement(c) && matchesAny(c, sels) {
+				n.RemoveChild(c)
+				removed++
+			} else {
+				walk(c)
+			}
+			c = next
+		}
+	}
+	walk(doc)
+	return removed
+}
+
+func structuralElement(n *html.Node) bool {
+	switch strings.ToLower(n.Data) {
+	case "html", "head", "body":
+		return true
+	}
+	return false
+}
+
+func matchesAny(n *html.Node, sels []*Selector) bool {
+	for _, s := range sels {
+		if s.Match(n) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package processor
+
+import (
+	"hash/fnv"
+	"strings"
+	"unicode"
+)
+
+// SimHash returns a 64-bit simhash of text over word 3-shingles (character
+// 3-grams for CJK runs). Near-duplicate texts differ in only a few bits.
+// Returns 0 for text too short to fingerprint.
+func SimHash(text string) uint64 {
+	tokens := simhashTokens(text)
+	if len(tokens) < 3 {
+		return 0
+	}
+	var weights [64]int
+	h := fnv.New64a()
+	for i := 0; i+3 <= len(tokens); i++ {
+		h.Reset()
+		_, _ = h.Write([]byte(strings.Join(tokens[i:i+3], " ")))
+		sum := h.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if sum&(1<<uint(bit)) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	var out uint64
+	for bit, w := range weights {
+		if w > 0 {
+			out |= 1 << uint(bit)
+		}
+	}
+	return out
+}
+
+// SimHashBands splits a simhash into four 16-bit bands. Two hashes within
+// Hamming distance 3 always share at least one band, so indexed band
+// lookups find every candidate.
+func SimHashBands(h uint64) [4]uint16 {
+	return [4]uint16{uint16(h), uint16(h >> 16), uint16(h >> 32), uint16(h >> 48)}
+}
+
+func simhashTokens(text string) []string {
+	var tokens []string
+	var word strings.Builder
+	flush := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flush()
+			tokens = append(tokens, string(r))
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			word.WriteRune(r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return tokens
+}
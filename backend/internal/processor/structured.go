@@ -0,0 +1,361 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	StructuredRecipe  = "recipe"
+	StructuredProduct = "product"
+	StructuredEvent   = "event"
+)
+
+// StructuredTypes are the page types with a dedicated extractor.
+var StructuredTypes = []string{StructuredRecipe, StructuredProduct, StructuredEvent}
+
+type Recipe struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Image       string   `json:"image,omitempty"`
+	Yield       string   `json:"yield,omitempty"`
+	PrepTime    string   `json:"prepTime,omitempty"`
+	CookTime    string   `json:"cookTime,omitempty"`
+	TotalTime   string   `json:"totalTime,omitempty"`
+	Ingredients []string `json:"ingredients"`
+	Steps       []string `json:"steps"`
+}
+
+type Product struct {
+	Name         string            `json:"name"`
+	Description  string            `json:"description,omitempty"`
+	Image        string            `json:"image,omitempty"`
+	Brand        string            `json:"brand,omitempty"`
+	SKU          string            `json:"sku,omitempty"`
+	Price        string            `json:"price,omitempty"`
+	Currency     string            `json:"currency,omitempty"`
+	Availability string            `json:"availability,omitempty"`
+	Specs        map[string]string `json:"specs,omitempty"`
+}
+
+type Event struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	StartDate   string `json:"startDate,omitempty"`
+	EndDate     string `json:"endDate,omitempty"`
+	Location    string `json:"location,omitempty"`
+	Address     string `json:"address,omitempty"`
+	Organizer   string `json:"organizer,omitempty"`
+}
+
+// Structured is what an extractor found: Type names which of the pointers
+// is set.
+type Structured struct {
+	Type    string
+	Recipe  *Recipe
+	Product *Product
+	Event   *Event
+}
+
+// Value returns the typed record for storing as JSON.
+func (s Structured) Value() any {
+	switch s.Type {
+	case StructuredRecipe:
+		return s.Recipe
+	case StructuredProduct:
+		return s.Product
+	case StructuredEvent:
+		return s.Event
+	}
+	return nil
+}
+
+// DetectStructured looks for a schema.org Recipe, Product or Event in the
+// page's JSON-LD and extracts the first one found. Pages without one yield
+// an empty Type.
+func DetectStructured(rawHTML []byte) Structured {
+	for _, node := range jsonLDNodes(rawHTML) {
+		if kind := schemaKind(node); kind != "" {
+			return structuredFrom(kind, node)
+		}
+	}
+	return Structured{}
+}
+
+// ExtractStructured extracts kind from the page's JSON-LD, for when the user
+// says what the page is. ok is false when the page declares no such item.
+func ExtractStructured(rawHTML []byte, kind string) (Structured, bool) {
+	for _, node := range jsonLDNodes(rawHTML) {
+		if schemaKind(node) == kind {
+			return structuredFrom(kind, node), true
+		}
+	}
+	return Structured{}, false
+}
+
+func structuredFrom(kind string, node map[string]any) Structured {
+	out := Structured{Type: kind}
+	switch kind {
+	case StructuredRecipe:
+		out.Recipe = recipeFrom(node)
+	case StructuredProduct:
+		out.Product = productFrom(node)
+	case StructuredEvent:
+		out.Event = eventFrom(node)
+	}
+	return out
+}
+
+// jsonLDNodes returns every object in the page's ld+json scripts, flattening
+// top-level arrays and @graph lists.
+func jsonLDNodes(rawHTML []byte) []map[string]any {
+	doc, err := html.Parse(bytes.NewReader(rawHTML))
+	if err != nil {
+		return nil
+	}
+	var out []map[string]any
+	var collect func(v any)
+	collect = func(v any) {
+		switch t := v.(type) {
+		case []any:
+			for _, item := range t {
+				collect(item)
+			}
+		case map[string]any:
+			out = append(out, t)
+			if graph, ok := t["@graph"]; ok {
+				collect(graph)
+			}
+		}
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.Script &&
+			strings.EqualFold(strings.TrimSpace(attrRaw(n, "type")), "application/ld+json") && n.FirstChild != nil {
+			var v any
+			if json.Unmarshal([]byte(n.FirstChild.Data), &v) == nil {
+				collect(v)
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return out
+}
+
+// schemaKind maps a node's @type to an extractor. Event subtypes such as
+// MusicEvent count as events.
+func schemaKind(node map[string]any) string {
+	for _, t := range ldStrings(node["@type"]) {
+		t = strings.TrimPrefix(strings.TrimPrefix(t, "https://schema.org/"), "http://schema.org/")
+		switch {
+		case t == "Recipe":
+			return StructuredRecipe
+		case t == "Product" || t == "ProductGroup":
+			return StructuredProduct
+		case t == "Event" || strings.HasSuffix(t, "Event"):
+			return StructuredEvent
+		}
+	}
+	return ""
+}
+
+func recipeFrom(node map[string]any) *Recipe {
+	r := &Recipe{
+		Name:        ldText(node["name"]),
+		Description: ldText(node["description"]),
+		Image:       ldImage(node["image"]),
+		Yield:       ldText(node["recipeYield"]),
+		PrepTime:    ldText(node["prepTime"]),
+		CookTime:    ldText(node["cookTime"]),
+		TotalTime:   ldText(node["totalTime"]),
+		Ingredients: ldStrings(node["recipeIngredient"]),
+		Steps:       []string{},
+	}
+	if len(r.Ingredients) == 0 {
+		r.Ingredients = ldStrings(node["ingredients"])
+	}
+	if r.Ingredients == nil {
+		r.Ingredients = []string{}
+	}
+	var steps func(v any)
+	steps = func(v any) {
+		switch t := v.(type) {
+		case string:
+			for _, line := range strings.Split(t, "\n") {
+				if line = cleanText(line); line != "" {
+					r.Steps = append(r.Steps, line)
+				}
+			}
+		case []any:
+			for _, item := range t {
+				steps(item)
+			}
+		case map[string]any:
+			// HowToSection nests its steps; HowToStep carries text.
+			if list, ok := t["itemListElement"]; ok {
+				steps(list)
+			} else if text := ldText(t["text"]); text != "" {
+				r.Steps = append(r.Steps, text)
+			} else if name := ldText(t["name"]); name != "" {
+				r.Steps = append(r.Steps, name)
+			}
+		}
+	}
+	steps(node["recipeInstructions"])
+	return r
+}
+
+func productFrom(node map[string]any) *Product {
+	p := &Product{
+		Name:        ldText(node["name"]),
+		Description: ldText(node["description"]),
+		Image:       ldImage(node["image"]),
+		Brand:       ldText(node["brand"]),
+		SKU:         ldText(node["sku"]),
+	}
+	offer := ldFirst(node["offers"])
+	if offer != nil {
+		p.Price = ldText(offer["price"])
+		if p.Price == "" {
+			p.Price = ldText(offer["lowPrice"])
+		}
+		p.Currency = ldText(offer["priceCurrency"])
+		p.Availability = schemaEnum(ldText(offer["availability"]))
+	}
+	for _, prop := range ldObjects(node["additionalProperty"]) {
+		name, value := ldText(prop["name"]), ldText(prop["value"])
+		if name == "" || value == "" {
+			continue
+		}
+		if p.Specs == nil {
+			p.Specs = map[string]string{}
+		}
+		p.Specs[name] = value
+	}
+	return p
+}
+
+func eventFrom(node map[string]any) *Event {
+	e := &Event{
+		Name:        ldText(node["name"]),
+		Description: ldText(node["description"]),
+		Image:       ldImage(node["image"]),
+		StartDate:   ldText(node["startDate"]),
+		EndDate:     ldText(node["endDate"]),
+		Organizer:   ldText(node["organizer"]),
+	}
+	if place := ldFirst(node["location"]); place != nil {
+		e.Location = ldText(place["name"])
+		if e.Location == "" {
+			e.Location = ldText(place["url"])
+		}
+		e.Address = ldAddress(place["address"])
+	} else {
+		e.Location = ldText(node["location"])
+	}
+	return e
+}
+
+// ldText flattens a JSON-LD value to display text: strings as they are,
+// numbers formatted, and objects by their name.
+func ldText(v any) string {
+	switch t := v.(type) {
+	case string:
+		return cleanText(t)
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case []any:
+		if len(t) > 0 {
+			return ldText(t[0])
+		}
+	case map[string]any:
+		if name := ldText(t["name"]); name != "" {
+			return name
+		}
+		return ldText(t["@value"])
+	}
+	return ""
+}
+
+func ldStrings(v any) []string {
+	var out []string
+	switch t := v.(type) {
+	case string:
+		if s := cleanText(t); s != "" {
+			out = append(out, s)
+		}
+	case []any:
+		for _, item := range t {
+			if s := ldText(item); s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	return out
+}
+
+func ldObjects(v any) []map[string]any {
+	switch t := v.(type) {
+	case map[string]any:
+		return []map[string]any{t}
+	case []any:
+		var out []map[string]any
+		for _, item := range t {
+			if m, ok := item.(map[string]any); ok {
+				out = append(out, m)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func ldFirst(v any) map[string]any {
+	if objs := ldObjects(v); len(objs) > 0 {
+		return objs[0]
+	}
+	return nil
+}
+
+func ldImage(v any) string {
+	if obj := ldFirst(v); obj != nil {
+		return ldText(obj["url"])
+	}
+	return ldText(v)
+}
+
+func ldAddress(v any) string {
+	obj := ldFirst(v)
+	if obj == nil {
+		return ldText(v)
+	}
+	parts := []string{}
+	for _, key := range []string{"streetAddress", "addressLocality", "addressRegion", "postalCode", "addressCountry"} {
+		if s := ldText(obj[key]); s != "" {
+			parts = append(parts, s)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// schemaEnum turns https://schema.org/InStock into InStock.
+func schemaEnum(v string) string {
+	if i := strings.LastIndex(v, "/"); i >= 0 {
+		return v[i+1:]
+	}
+	return v
+}
+
+func cleanText(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(s)), " ")
+}
@@ -0,0 +1,118 @@
+package processor
+
+import (
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// titleSeparators split a page title from the site name pages append or
+// prepend to it, widest first so " - " doesn't cut into " -- ".
+var titleSeparators = []string{" :: ", " -- ", " | ", " - ", " – ", " — ", " · ", " • ", " » ", " / "}
+
+// titleCount matches an unread counter such as "(3) " that sites put in
+// front of the title.
+var titleCount = regexp.MustCompile(`^[(\[]\d+\+?[)\]]\s+`)
+
+// CleanTitle decodes HTML entities in a page title, collapses whitespace,
+// drops an unread counter and strips leading or trailing segments that only
+// name the site, matched against siteName and the host. Anything else is
+// left alone; a title made up of the site name alone is kept as it is.
+func CleanTitle(title, siteName, host string) string {
+	title = html.UnescapeString(html.UnescapeString(title))
+	title = strings.Map(func(r rune) rune {
+		switch r {
+		case '\u200b', '\u200c', '\u200d', '\ufeff':
+			return -1
+		}
+		return r
+	}, title)
+	title = strings.Join(strings.Fields(title), " ")
+	title = titleCount.ReplaceAllString(title, "")
+
+	names := siteNames(siteName, host)
+	if len(names) == 0 {
+		return title
+	}
+	for {
+		trimmed := stripSiteSegment(title, names)
+		if trimmed == title {
+			return title
+		}
+		title = trimmed
+	}
+}
+
+// TitleHasSeparator reports whether a cleaned title still joins segments
+// with a separator, which is where the rules may have missed a site name.
+func TitleHasSeparator(title string) bool {
+	for _, sep := range titleSeparators {
+		if strings.Contains(title, sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripSiteSegment removes one site-name segment from the end, or failing
+// that the start, of title.
+func stripSiteSegment(title string, names map[string]bool) string {
+	for _, sep := range titleSeparators {
+		if i := strings.LastIndex(title, sep); i > 0 {
+			head, tail := strings.TrimSpace(title[:i]), title[i+len(sep):]
+			if head != "" && names[foldTitle(tail)] {
+				return head
+			}
+		}
+	}
+	for _, sep := range titleSeparators {
+		if i := strings.Index(title, sep); i > 0 {
+			head, tail := title[:i], strings.TrimSpace(title[i+len(sep):])
+			if tail != "" && names[foldTitle(head)] {
+				return tail
+			}
+		}
+	}
+	return title
+}
+
+// siteNames lists the folded forms a site's name takes in titles: the site
+// name, the host with and without www, and the host's main label, so
+// "www.example.co.uk" matches "Example", "example.co.uk" and "EXAMPLE".
+func siteNames(siteName, host string) map[string]bool {
+	names := map[string]bool{}
+	add := func(s string) {
+		if f := foldTitle(s); f != "" {
+			names[f] = true
+		}
+	}
+	add(siteName)
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	if host != "" {
+		add(host)
+		labels := strings.Split(host, ".")
+		if len(labels) >= 2 {
+			main := labels[len(labels)-2]
+			// Second-level registries: example.co.uk, example.com.cn.
+			if len(labels) >= 3 && len(main) <= 3 {
+				main = labels[len(labels)-3]
+			}
+			if len(main) > 2 {
+				add(main)
+			}
+		}
+	}
+	return names
+}
+
+// foldTitle lower-cases s and keeps only its letters and digits.
+func foldTitle(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
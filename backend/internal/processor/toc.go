@@ -0,0 +1,123 @@
+package processor
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Heading is one entry of a page's table of contents.
+type Heading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+var headingLevels = map[atom.Atom]int{atom.H1: 1, atom.H2: 2, atom.H3: 3, atom.H4: 4, atom.H5: 5, atom.H6: 6}
+
+// anchorHeadings gives every heading without an id one derived from its
+// text, so sections can be linked to. The ids depend only on the headings
+// and ids already in the page, so a recapture of unchanged content yields
+// the same anchors. Existing ids are kept: the site's own links use them.
+func anchorHeadings(doc *html.Node) {
+	used := map[string]bool{}
+	var headings []*html.Node
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			if id := attrRaw(n, "id"); id != "" {
+				used[id] = true
+			} else if headingLevels[n.DataAtom] > 0 {
+				headings = append(headings, n)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	for _, h := range headings {
+		base := slugify(nodeText(h))
+		id := base
+		for i := 2; used[id]; i++ {
+			id = base + "-" + strconv.Itoa(i)
+		}
+		used[id] = true
+		h.Attr = append(h.Attr, html.Attribute{Key: "id", Val: id})
+	}
+}
+
+// TableOfContents lists the headings of a stored page in document order.
+// Headings of pages captured before anchors were assigned have an empty ID.
+func TableOfContents(page []byte) ([]Heading, error) {
+	doc, err := html.Parse(bytes.NewReader(page))
+	if err != nil {
+		return nil, err
+	}
+	out := []Heading{}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Script, atom.Style, atom.Noscript, atom.Template:
+				return
+			}
+			if level := headingLevels[n.DataAtom]; level > 0 {
+				if text := nodeText(n); text != "" {
+					out = append(out, Heading{Level: level, Text: text, ID: attrRaw(n, "id")})
+				}
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(doc)
+	return out, nil
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// slugify keeps letters and digits of any script, lowercased, joined by
+// hyphens.
+func slugify(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(r)
+		} else {
+			dash = true
+		}
+		if b.Len() >= 80 {
+			break
+		}
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+	return b.String()
+}
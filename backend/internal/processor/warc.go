@@ -0,0 +1,234 @@
+package processor
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WARCPage is an HTML response found in a WARC file.
+type WARCPage struct {
+	URL        string
+	CapturedAt time.Time
+	HTML       []byte
+	// RecordID is the WARC-Record-ID of the record the page came from.
+	RecordID string
+}
+
+// WARCCollection is the content of one or more WARC files: the pages to
+// archive and every successful response, which serve as the pages'
+// packaged resources.
+type WARCCollection struct {
+	Pages     []WARCPage
+	Resources map[string]Resource
+	// Skipped counts records that could not be parsed.
+	Skipped int
+}
+
+func newWARCCollection() *WARCCollection {
+	return &WARCCollection{Resources: map[string]Resource{}}
+}
+
+// ParseWARC reads a WARC file, optionally gzip-compressed per record.
+func ParseWARC(r io.Reader) (*WARCCollection, error) {
+	col := newWARCCollection()
+	if err := col.read(r); err != nil {
+		return nil, err
+	}
+	col.dedupePages(nil)
+	return col, nil
+}
+
+// ParseWACZ reads the WARC files inside a WACZ package. When the package
+// lists its pages in pages/pages.jsonl only those become pages; other HTML
+// responses (frames, error pages) are kept as resources.
+func ParseWACZ(ra io.ReaderAt, size int64) (*WARCCollection, error) {
+	zr, err := zip.NewReader(ra, size)
+	if err != nil {
+		return nil, err
+	}
+	col := newWARCCollection()
+	var listed map[string]bool
+	found := false
+	for _, f := range zr.File {
+		name := strings.ToLower(f.Name)
+		switch {
+		case strings.HasPrefix(name, "archive/") && (strings.HasSuffix(name, ".warc") || strings.HasSuffix(name, ".warc.gz")):
+			found = true
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			err = col.read(rc)
+			rc.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", f.Name, err)
+			}
+		case path.Base(name) == "pages.jsonl" && strings.HasPrefix(name, "pages/"):
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			listed = readWACZPages(rc)
+			rc.Close()
+		}
+	}
+	if !found {
+		return nil, errors.New("wacz has no warc files")
+	}
+	col.dedupePages(listed)
+	return col, nil
+}
+
+func readWACZPages(r io.Reader) map[string]bool {
+	out := map[string]bool{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		var entry struct {
+			URL string `json:"url"`
+		}
+		// The first line is a format header without a url.
+		if json.Unmarshal(scanner.Bytes(), &entry) == nil && entry.URL != "" {
+			out[entry.URL] = true
+		}
+	}
+	return out
+}
+
+// dedupePages keeps the earliest capture of each URL, restricted to listed
+// when it is non-empty.
+func (col *WARCCollection) dedupePages(listed map[string]bool) {
+	seen := map[string]int{}
+	out := make([]WARCPage, 0, len(col.Pages))
+	for _, page := range col.Pages {
+		if len(listed) > 0 && !listed[page.URL] {
+			continue
+		}
+		if i, ok := seen[page.URL]; ok {
+			if page.CapturedAt.Before(out[i].CapturedAt) {
+				out[i] = page
+			}
+			continue
+		}
+		seen[page.URL] = len(out)
+		out = append(out, page)
+	}
+	col.Pages = out
+}
+
+func (col *WARCCollection) read(r io.Reader) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		// Per-record gzip members read as one stream.
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		br = bufio.NewReader(gz)
+	}
+	tp := textproto.NewReader(br)
+	for {
+		version, err := tp.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if strings.TrimSpace(version) == "" {
+			continue // blank lines between records
+		}
+		if !strings.HasPrefix(version, "WARC/") {
+			return fmt.Errorf("not a warc record: %q", truncateLine(version))
+		}
+		header, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return err
+		}
+		length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+		if err != nil || length < 0 {
+			return errors.New("warc record without content length")
+		}
+		block := make([]byte, length)
+		if _, err := io.ReadFull(br, block); err != nil {
+			return err
+		}
+		if err := col.addRecord(header, block); err != nil {
+			col.Skipped++
+		}
+	}
+}
+
+func (col *WARCCollection) addRecord(header textproto.MIMEHeader, block []byte) error {
+	target := strings.Trim(header.Get("WARC-Target-URI"), "<> ")
+	if target == "" {
+		return nil
+	}
+	date, _ := time.Parse(time.RFC3339, header.Get("WARC-Date"))
+	var contentType string
+	var body []byte
+	switch header.Get("WARC-Type") {
+	case "response":
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(block)), nil)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil
+		}
+		body, err = decodeBody(resp)
+		if err != nil {
+			return err
+		}
+		contentType = resp.Header.Get("Content-Type")
+	case "resource":
+		body = block
+		contentType = header.Get("Content-Type")
+	default:
+		return nil
+	}
+	col.Resources[target] = Resource{ContentType: contentType, Body: body}
+	if strings.HasPrefix(strings.ToLower(contentType), "text/html") {
+		col.Pages = append(col.Pages, WARCPage{URL: target, CapturedAt: date, HTML: body, RecordID: strings.Trim(header.Get("WARC-Record-ID"), "<> ")})
+	}
+	return nil
+}
+
+// decodeBody undoes the Content-Encoding WARC files keep verbatim.
+func decodeBody(resp *http.Response) ([]byte, error) {
+	var r io.Reader = resp.Body
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	case "deflate":
+		r = flate.NewReader(resp.Body)
+	}
+	return io.ReadAll(r)
+}
+
+func truncateLine(s string) string {
+	if len(s) > 40 {
+		return s[:40]
+	}
+	return s
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Rules picks the proxy for an outbound request: the most specific domain
+// rule wins, otherwise Default. A nil *Rules leaves the standard
+// HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment handling in place.
+type Rules struct {
+	Default *url.URL
+	// Domains maps a host and its subdomains to a proxy; a nil value means
+	// connect directly.
+	Domains map[string]*url.URL
+}
+
+// Parse builds Rules from a default proxy URL and a comma separated list of
+// domain=proxy pairs, where proxy is a URL or "direct". It returns nil when
+// both are empty.
+func Parse(defaultProxy, rules string) (*Rules, error) {
+	defaultProxy = strings.TrimSpace(defaultProxy)
+	rules = strings.TrimSpace(rules)
+	if defaultProxy == "" && rules == "" {
+		return nil, nil
+	}
+	out := &Rules{Domains: map[string]*url.URL{}}
+	if defaultProxy != "" {
+		u, err := parseURL(defaultProxy)
+		if err != nil {
+			return nil, err
+		}
+		out.Default = u
+	}
+	for _, rule := range strings.Split(rules, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		domain, target, ok := strings.Cut(rule, "=")
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		target = strings.TrimSpace(target)
+		if !ok || domain == "" || target == "" {
+			return nil, fmt.Errorf("proxy rule %q: expected domain=proxy", rule)
+		}
+		if strings.EqualFold(target, "direct") {
+			out.Domains[domain] = nil
+			continue
+		}
+		u, err := parseURL(target)
+		if err != nil {
+			return nil, fmt.Errorf("proxy rule %q: %w", rule, err)
+		}
+		out.Domains[domain] = u
+	}
+	return out, nil
+}
+
+func parseURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %q", raw)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("proxy %q: scheme must be http, https or socks5", raw)
+	}
+	return u, nil
+}
+
+// Proxy implements http.Transport.Proxy.
+func (r *Rules) Proxy(req *http.Request) (*url.URL, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	for {
+		if u, ok := r.Domains[host]; ok {
+			return u, nil
+		}
+		dot := strings.Index(host, ".")
+		if dot < 0 {
+			break
+		}
+		host = host[dot+1:]
+	}
+	return r.Default, nil
+}
+
+// Transport returns a copy of the default transport routed through r, or
+// nil (use http.DefaultTransport) when r is nil.
+func (r *Rules) Transport() http.RoundTripper {
+	if r == nil {
+		return nil
+	}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = r.Proxy
+	return t
+}
@@ -0,0 +1,50 @@
+package queue
+
+import "context"
+
+// Memory keeps jobs in buffered channels; they are lost on restart and seen
+// only by this process.
+type Memory struct {
+	high   chan []byte
+	normal chan []byte
+}
+
+func NewMemory(size int) *Memory {
+	return &Memory{high: make(chan []byte, size), normal: make(chan []byte, size)}
+}
+
+// Push never blocks.
+func (m *Memory) Push(_ context.Context, payload []byte, priority bool) error {
+	ch := m.normal
+	if priority {
+		ch = m.high
+	}
+	select {
+	case ch <- payload:
+		return nil
+	default:
+		return ErrFull
+	}
+}
+
+func (m *Memory) Pop(ctx context.Context) ([]byte, error) {
+	select {
+	case p := <-m.high:
+		return p, nil
+	default:
+	}
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case p := <-m.high:
+		return p, nil
+	case p := <-m.normal:
+		return p, nil
+	}
+}
+
+func (m *Memory) Len(context.Context) (int, int, error) {
+	return len(m.normal), len(m.high), nil
+}
+
+func (m *Memory) Capacity() int { return cap(m.normal) }
@@ -0,0 +1,24 @@
+// Package queue carries background jobs from the request handlers that
+// create them to the workers that run them, either within one process or,
+// through Redis, across every replica of a horizontally scaled deployment.
+package queue
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrFull is returned by Push when the lane is at capacity.
+var ErrFull = errors.New("queue full")
+
+// Queue is a two-lane FIFO of opaque job payloads: Pop drains the priority
+// lane before the normal one.
+type Queue interface {
+	Push(ctx context.Context, payload []byte, priority bool) error
+	// Pop blocks until a job is available or ctx is done.
+	Pop(ctx context.Context) ([]byte, error)
+	// Len reports the jobs waiting in each lane.
+	Len(ctx context.Context) (normal, priority int, err error)
+	// Capacity is the most jobs a lane holds.
+	Capacity() int
+}
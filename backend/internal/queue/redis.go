@@ -0,0 +1,101 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"webarchive/internal/cache"
+)
+
+// redisPollSeconds bounds each blocking pop so workers notice shutdown.
+const redisPollSeconds = 1
+
+// Redis keeps each lane in a Redis list shared by every replica pointed at
+// the same server, so any of them may run a job another one queued. A job
+// is removed from the list when a worker takes it: one whose replica dies
+// while running it is not retried elsewhere.
+type Redis struct {
+	client   *cache.Redis
+	high     string
+	normal   string
+	capacity int
+}
+
+// NewRedis stores the lanes under key+":high" and key+":normal".
+func NewRedis(client *cache.Redis, key string, size int) *Redis {
+	return &Redis{client: client, high: key + ":high", normal: key + ":normal", capacity: size}
+}
+
+// Push checks the lane's length before adding to it, so concurrent pushes
+// from several replicas may overshoot the capacity slightly.
+func (r *Redis) Push(ctx context.Context, payload []byte, priority bool) error {
+	key := r.normal
+	if priority {
+		key = r.high
+	}
+	n, err := r.llen(ctx, key)
+	if err != nil {
+		return err
+	}
+	if n >= r.capacity {
+		return ErrFull
+	}
+	_, err = r.client.Do(ctx, "LPUSH", key, string(payload))
+	return err
+}
+
+func (r *Redis) Pop(ctx context.Context) ([]byte, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		// BRPOP checks its keys in order, so the priority lane comes first.
+		reply, err := r.client.Do(ctx, "BRPOP", r.high, r.normal, strconv.Itoa(redisPollSeconds))
+		if err != nil {
+			// Wait out an outage instead of spinning on it.
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		if reply == nil {
+			continue
+		}
+		pair, ok := reply.([]any)
+		if !ok || len(pair) != 2 {
+			return nil, fmt.Errorf("redis: unexpected BRPOP reply %T", reply)
+		}
+		payload, ok := pair[1].([]byte)
+		if !ok {
+			return nil, fmt.Errorf("redis: unexpected BRPOP payload %T", pair[1])
+		}
+		return payload, nil
+	}
+}
+
+func (r *Redis) Len(ctx context.Context) (int, int, error) {
+	normal, err := r.llen(ctx, r.normal)
+	if err != nil {
+		return 0, 0, err
+	}
+	high, err := r.llen(ctx, r.high)
+	return normal, high, err
+}
+
+func (r *Redis) Capacity() int { return r.capacity }
+
+func (r *Redis) llen(ctx context.Context, key string) (int, error) {
+	reply, err := r.client.Do(ctx, "LLEN", key)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected LLEN reply %T", reply)
+	}
+	return int(n), nil
+}
@@ -0,0 +1,61 @@
+package searchindex
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+// NgramIndexName is the FULLTEXT index over title and content_text built
+// with MySQL's ngram parser; see db.EnsureNgramIndex.
+const NgramIndexName = "idx_archives_ngram"
+
+// DBIndex searches the archives table. The table is the index, so there is
+// nothing to sync. Without Ngram it matches with LIKE; with it, MATCH
+// AGAINST uses the ngram FULLTEXT index, which splits Chinese and Japanese
+// text into bigrams instead of needing spaces between words.
+type DBIndex struct {
+	DB    *gorm.DB
+	Ngram bool
+}
+
+func NewDBIndex(db *gorm.DB, ngram bool) *DBIndex {
+	return &DBIndex{DB: db, Ngram: ngram}
+}
+
+func (s *DBIndex) Name() string { return "db" }
+
+func (s *DBIndex) Upsert(ctx context.Context, docs []Document) error { return nil }
+
+func (s *DBIndex) Delete(ctx context.Context, ids []string) error { return nil }
+
+func (s *DBIndex) Search(ctx context.Context, query string, limit int) ([]string, error) {
+	cond, args := s.Condition(query)
+	var ids []string
+	err := s.DB.WithContext(ctx).Model(&models.Archive{}).Where(cond, args...).
+		Order("created_at desc").Limit(limit).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// Condition is the WHERE clause matching query. Queries shorter than one
+// ngram token can't use the index and fall back to LIKE.
+func (s *DBIndex) Condition(query string) (string, []any) {
+	phrase := strings.Join(strings.Fields(strings.ReplaceAll(query, `"`, " ")), " ")
+	if !s.Ngram || utf8.RuneCountInString(phrase) < 2 {
+		return LikeCondition(query)
+	}
+	// A quoted phrase in boolean mode requires the query's ngrams to appear
+	// consecutively, which is what a substring search expects.
+	return "(MATCH(title, content_text) AGAINST (? IN BOOLEAN MODE) OR url LIKE ?)",
+		[]any{`"` + phrase + `"`, "%" + query + "%"}
+}
+
+// LikeCondition matches query as a substring of the title, URL or text.
+func LikeCondition(query string) (string, []any) {
+	like := "%" + query + "%"
+	return "(title LIKE ? OR url LIKE ? OR content_text LIKE ?)", []any{like, like, like}
+}
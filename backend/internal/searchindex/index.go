@@ -0,0 +1,28 @@
+package searchindex
+
+import (
+	"context"
+	"time"
+)
+
+// Document is the searchable part of an archive.
+type Document struct {
+	ID         string     `json:"id"`
+	Title      string     `json:"title"`
+	URL        string     `json:"url"`
+	Domain     string     `json:"domain"`
+	Tags       []string   `json:"tags"`
+	Summary    string     `json:"summary"`
+	Content    string     `json:"content"`
+	CapturedAt *time.Time `json:"capturedAt,omitempty"`
+}
+
+// Index answers full-text queries with archive IDs, best match first.
+// Backends other than the database keep their own copy of the documents and
+// are fed through Upsert and Delete as archives change.
+type Index interface {
+	Name() string
+	Upsert(ctx context.Context, docs []Document) error
+	Delete(ctx context.Context, ids []string) error
+	Search(ctx context.Context, query string, limit int) ([]string, error)
+}
@@ -0,0 +1,151 @@
+package searchindex
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MeiliIndex talks to Meilisearch's REST API. Its tokenizer segments Chinese
+// and Japanese text, which LIKE and MySQL's default full-text parser don't.
+// Writes are asynchronous tasks on the Meilisearch side; they are not awaited.
+type MeiliIndex struct {
+	BaseURL string
+	APIKey  string
+	Index   string
+	HTTP    *http.Client
+
+	mu      sync.Mutex
+	created bool
+}
+
+func NewMeiliIndex(baseURL, apiKey, index string) *MeiliIndex {
+	return &MeiliIndex{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		Index:   index,
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *MeiliIndex) Name() string { return "meilisearch" }
+
+// ensureIndex creates the index with id as primary key and restricts the
+// searchable fields, once per process.
+func (s *MeiliIndex) ensureIndex(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.created {
+		return nil
+	}
+	status, raw, err := s.do(ctx, http.MethodPost, "/indexes", map[string]any{"uid": s.Index, "primaryKey": "id"})
+	if err != nil {
+		return err
+	}
+	// 409 or a failed task both mean the index is already there.
+	if status >= 300 && status != http.StatusConflict {
+		return fmt.Errorf("meilisearch create index: %d %s", status, raw)
+	}
+	searchable := []string{"title", "tags", "summary", "content", "url", "domain"}
+	status, raw, err = s.do(ctx, http.MethodPut, "/indexes/"+s.Index+"/settings/searchable-attributes", searchable)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return fmt.Errorf("meilisearch settings: %d %s", status, raw)
+	}
+	s.created = true
+	return nil
+}
+
+func (s *MeiliIndex) Upsert(ctx context.Context, docs []Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	if err := s.ensureIndex(ctx); err != nil {
+		return err
+	}
+	status, raw, err := s.do(ctx, http.MethodPost, "/indexes/"+s.Index+"/documents?primaryKey=id", docs)
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return fmt.Errorf("meilisearch upsert: %d %s", status, raw)
+	}
+	return nil
+}
+
+func (s *MeiliIndex) Delete(ctx context.Context, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	status, raw, err := s.do(ctx, http.MethodPost, "/indexes/"+s.Index+"/documents/delete-batch", ids)
+	if err != nil {
+		return err
+	}
+	if status >= 300 && status != http.StatusNotFound {
+		return fmt.Errorf("meilisearch delete: %d %s", status, raw)
+	}
+	return nil
+}
+
+func (s *MeiliIndex) Search(ctx context.Context, query string, limit int) ([]string, error) {
+	body := map[string]any{"q": query, "limit": limit, "attributesToRetrieve": []string{"id"}}
+	status, raw, err := s.do(ctx, http.MethodPost, "/indexes/"+s.Index+"/search", body)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return []string{}, nil
+	}
+	if status >= 300 {
+		return nil, fmt.Errorf("meilisearch search: %d %s", status, raw)
+	}
+	var res struct {
+		Hits []struct {
+			ID string `json:"id"`
+		} `json:"hits"`
+	}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids, nil
+}
+
+func (s *MeiliIndex) do(ctx context.Context, method, path string, body any) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.BaseURL+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	}
+	resp, err := s.HTTP.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	return resp.StatusCode, raw, nil
+}
@@ -0,0 +1,10 @@
+package settings
+
+// SealCookies encrypts a Cookie header for storage the same way LLM keys are.
+func SealCookies(header string) (string, error) {
+	return encryptSecret(header)
+}
+
+func OpenCookies(stored string) (string, error) {
+	return decryptSecret(stored)
+}
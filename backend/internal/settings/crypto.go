@@ -0,0 +1,142 @@
+package settings
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+)
+
+// Secrets are stored with envelope encryption: each value gets a random data
+// key, the data key is wrapped with the server key derived from
+// SETTINGS_ENCRYPTION_KEY, and both ciphertexts are stored together as
+// "enc:v1:<wrapped key>:<ciphertext>".
+const encPrefix = "enc:v1:"
+
+var (
+	keyMu     sync.RWMutex
+	masterKey []byte
+)
+
+var ErrNoEncryptionKey = errors.New("setting is encrypted but SETTINGS_ENCRYPTION_KEY is not set")
+
+func SetEncryptionKey(secret string) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if strings.TrimSpace(secret) == "" {
+		masterKey = nil
+		return
+	}
+	sum := sha256.Sum256([]byte(secret))
+	masterKey = sum[:]
+}
+
+func EncryptionEnabled() bool {
+	keyMu.RLock()
+	defer keyMu.RUnlock()
+	return masterKey != nil
+}
+
+func encryptSecret(plain string) (string, error) {
+	keyMu.RLock()
+	key := masterKey
+	keyMu.RUnlock()
+	if key == nil || plain == "" {
+		return plain, nil
+	}
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return "", err
+	}
+	wrapped, err := seal(key, dek)
+	if err != nil {
+		return "", err
+	}
+	body, err := seal(dek, []byte(plain))
+	if err != nil {
+		return "", err
+	}
+	return encPrefix + base64.StdEncoding.EncodeToString(wrapped) + ":" + base64.StdEncoding.EncodeToString(body), nil
+}
+
+// decryptSecret returns legacy plaintext values unchanged so existing
+// deployments keep working until the setting is saved again.
+func decryptSecret(stored string) (string, error) {
+	if !strings.HasPrefix(stored, encPrefix) {
+		return stored, nil
+	}
+	keyMu.RLock()
+	key := masterKey
+	keyMu.RUnlock()
+	if key == nil {
+		return "", ErrNoEncryptionKey
+	}
+	parts := strings.SplitN(strings.TrimPrefix(stored, encPrefix), ":", 2)
+	if len(parts) != 2 {
+		return "", errors.New("malformed encrypted setting")
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", err
+	}
+	body, err := base64.StdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", err
+	}
+	dek, err := open(key, wrapped)
+	if err != nil {
+		return "", errors.New("decrypt setting: wrong SETTINGS_ENCRYPTION_KEY?")
+	}
+	plain, err := open(dek, body)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+func seal(key, plain []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plain, nil), nil
+}
+
+func open(key, data []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	return gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// MaskSecret keeps only the last four characters, enough for a user to
+// recognise which key is configured.
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if len(secret) <= 8 {
+		return "********"
+	}
+	return "********" + secret[len(secret)-4:]
+}
@@ -0,0 +1,90 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"webarchive/internal/models"
+)
+
+// KeyPrefsPrefix namespaces per-user UI preferences: each user has one row,
+// keyed by this prefix and the user ID, holding Preferences as JSON.
+const KeyPrefsPrefix = "prefs."
+
+// Archive list orders a user can pick as their default.
+const (
+	SortNewest  = "newest"
+	SortOldest  = "oldest"
+	SortTitle   = "title"
+	SortUpdated = "updated"
+)
+
+// Preferences follow a user across devices. PresetID is the capture preset
+// selected by default, empty for none.
+type Preferences struct {
+	Density  string `json:"density"`
+	Sort     string `json:"sort"`
+	PresetID string `json:"presetId"`
+	Theme    string `json:"theme"`
+}
+
+func DefaultPreferences() Preferences {
+	return Preferences{Density: "comfortable", Sort: SortNewest, Theme: "system"}
+}
+
+func (p Preferences) Validate() error {
+	switch p.Density {
+	case "comfortable", "compact":
+	default:
+		return errors.New("density must be comfortable or compact")
+	}
+	if !ValidSort(p.Sort) {
+		return errors.New("sort must be newest, oldest, title or updated")
+	}
+	switch p.Theme {
+	case "system", "light", "dark":
+	default:
+		return errors.New("theme must be system, light or dark")
+	}
+	return nil
+}
+
+func ValidSort(sort string) bool {
+	switch sort {
+	case SortNewest, SortOldest, SortTitle, SortUpdated:
+		return true
+	}
+	return false
+}
+
+func PreferencesKey(userID string) string {
+	return KeyPrefsPrefix + userID
+}
+
+// LoadPreferences returns the defaults for anything the user hasn't set,
+// including fields added after their preferences were saved.
+func LoadPreferences(db *gorm.DB, userID string) (Preferences, error) {
+	out := DefaultPreferences()
+	var rows []models.AppSetting
+	if err := db.Where("setting_key = ?", PreferencesKey(userID)).Limit(1).Find(&rows).Error; err != nil {
+		return out, err
+	}
+	if len(rows) > 0 {
+		_ = json.Unmarshal([]byte(rows[0].Value), &out)
+	}
+	return out, nil
+}
+
+func SavePreferences(db *gorm.DB, userID string, p Preferences) error {
+	raw, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "setting_key"}},
+		UpdateAll: true,
+	}).Create(&models.AppSetting{Key: PreferencesKey(userID), Value: string(raw)}).Error
+}
@@ -0,0 +1,359 @@
+package settings
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"webarchive/internal/models"
+	"webarchive/internal/processor"
+)
+
+const (
+	KeyHTTPTimeout      = "runtime.http_timeout_seconds"
+	KeyMaxAssetBytes    = "runtime.max_asset_bytes"
+	KeyAutoTagOnCapture = "runtime.auto_tag_on_capture"
+	KeyAutoTagWorkers   = "runtime.auto_tag_workers"
+	KeyLLMTimeout       = "runtime.llm_timeout_seconds"
+	KeyLLMConcurrency   = "runtime.llm_max_concurrent"
+	KeyUserAgent        = "runtime.user_agent"
+	KeyHeaderRules      = "runtime.header_rules"
+	KeyRemovalRules     = "runtime.removal_rules"
+	KeyTaxonomyRouter   = "runtime.taxonomy_router"
+	KeyTaxonomyDepth    = "runtime.taxonomy_max_depth"
+	KeyTaxonomyOptions  = "runtime.taxonomy_max_options"
+	KeyTaxonomyPathLen  = "runtime.taxonomy_max_path_length"
+	KeyTaxonomyLabelLen = "runtime.taxonomy_max_label_length"
+	KeyTaxonomyWords    = "runtime.taxonomy_max_label_words"
+	KeyTaxonomyBanned   = "runtime.taxonomy_banned_chars"
+	KeyTaxonomyCase     = "runtime.taxonomy_label_case"
+	KeyTaxonomyMerge    = "runtime.taxonomy_merge_similar"
+	KeyCaptureHosts     = "runtime.capture_hosts"
+	KeyUserCaptureHosts = "runtime.user_capture_hosts"
+	KeyLLMTrace         = "runtime.llm_trace"
+	KeyLLMTraceDays     = "runtime.llm_trace_retention_days"
+)
+
+// Taxonomy routers: stepwise asks the LLM one level at a time, single sends
+// the whole tree and asks for the full path in one call.
+const (
+	RouterStepwise = "stepwise"
+	RouterSingle   = "single"
+)
+
+// Taxonomy label cases: keep leaves labels as written, sentence upper-cases
+// the first letter and title the first letter of every word.
+const (
+	CaseKeep     = "keep"
+	CaseLower    = "lower"
+	CaseSentence = "sentence"
+	CaseTitle    = "title"
+)
+
+// RuntimeSettings are the knobs that can change while the server is running.
+// Values stored in the database override the ones from the config file/env.
+type RuntimeSettings struct {
+	HTTPTimeoutSeconds int   `json:"httpTimeoutSeconds"`
+	MaxAssetBytes      int64 `json:"maxAssetBytes"`
+	AutoTagOnCapture   bool  `json:"autoTagOnCapture"`
+	AutoTagWorkers     int   `json:"autoTagWorkers"`
+	LLMTimeoutSeconds  int   `json:"llmTimeoutSeconds"`
+	LLMConcurrency     int   `json:"llmConcurrency"`
+	// UserAgent is sent with every page and asset fetch unless a header
+	// rule for the host overrides it.
+	UserAgent   string       `json:"userAgent"`
+	HeaderRules []HeaderRule `json:"headerRules"`
+	// RemovalRules strip page chrome such as cookie banners from captures
+	// of a domain before they are stored.
+	RemovalRules []RemovalRule `json:"removalRules"`
+	// TaxonomyRouter trades routing accuracy (stepwise) for fewer LLM calls
+	// (single).
+	TaxonomyRouter string `json:"taxonomyRouter"`
+	// Taxonomy shape limits, applied to every path written and to what the
+	// AI routers may propose. Lengths are in characters.
+	TaxonomyMaxDepth       int `json:"taxonomyMaxDepth"`
+	TaxonomyMaxOptions     int `json:"taxonomyMaxOptions"`
+	TaxonomyMaxPathLength  int `json:"taxonomyMaxPathLength"`
+	TaxonomyMaxLabelLength int `json:"taxonomyMaxLabelLength"`
+	// Label tidying, applied like the limits above: labels are cut to
+	// TaxonomyMaxLabelWords words, lose TaxonomyBannedChars, get
+	// TaxonomyLabelCase and, with TaxonomyMergeSimilar, reuse a
+	// near-identical existing sibling instead of starting a new branch.
+	TaxonomyMaxLabelWords int    `json:"taxonomyMaxLabelWords"`
+	TaxonomyBannedChars   string `json:"taxonomyBannedChars"`
+	TaxonomyLabelCase     string `json:"taxonomyLabelCase"`
+	TaxonomyMergeSimilar  bool   `json:"taxonomyMergeSimilar"`
+	// CaptureHosts limits which hosts can be archived and fetched at all;
+	// UserCaptureHosts adds restrictions for individual users, keyed by
+	// username.
+	CaptureHosts     processor.HostRules            `json:"captureHosts"`
+	UserCaptureHosts map[string]processor.HostRules `json:"userCaptureHosts"`
+	// LLMTrace keeps the prompts and raw replies of every classification
+	// and extraction per archive, for LLMTraceRetentionDays days.
+	LLMTrace              bool `json:"llmTrace"`
+	LLMTraceRetentionDays int  `json:"llmTraceRetentionDays"`
+}
+
+// HeaderRule adds or overrides request headers (Referer, Accept-Language,
+// User-Agent...) for a domain and its subdomains.
+type HeaderRule struct {
+	Domain  string            `json:"domain"`
+	Headers map[string]string `json:"headers"`
+}
+
+// RemovalRule lists CSS selectors removed from pages of a domain and its
+// subdomains; see processor.Selector for the supported syntax.
+type RemovalRule struct {
+	Domain    string   `json:"domain"`
+	Selectors []string `json:"selectors"`
+}
+
+func (r RuntimeSettings) Validate() error {
+	if r.HTTPTimeoutSeconds <= 0 {
+		return errors.New("httpTimeoutSeconds must be greater than zero")
+	}
+	if r.MaxAssetBytes <= 0 {
+		return errors.New("maxAssetBytes must be greater than zero")
+	}
+	if r.AutoTagWorkers <= 0 || r.AutoTagWorkers > 64 {
+		return errors.New("autoTagWorkers must be between 1 and 64")
+	}
+	if r.LLMTimeoutSeconds <= 0 {
+		return errors.New("llmTimeoutSeconds must be greater than zero")
+	}
+	if r.LLMConcurrency < 0 {
+		return errors.New("llmConcurrency must not be negative")
+	}
+	if r.TaxonomyRouter != RouterStepwise && r.TaxonomyRouter != RouterSingle {
+		return errors.New("taxonomyRouter must be stepwise or single")
+	}
+	if r.TaxonomyMaxDepth < 1 || r.TaxonomyMaxDepth > 10 {
+		return errors.New("taxonomyMaxDepth must be between 1 and 10")
+	}
+	if r.TaxonomyMaxOptions < 1 || r.TaxonomyMaxOptions > 200 {
+		return errors.New("taxonomyMaxOptions must be between 1 and 200")
+	}
+	// The taxonomy path and label columns are varchar(512) and varchar(255).
+	if r.TaxonomyMaxPathLength < 1 || r.TaxonomyMaxPathLength > 512 {
+		return errors.New("taxonomyMaxPathLength must be between 1 and 512")
+	}
+	if r.TaxonomyMaxLabelLength < 1 || r.TaxonomyMaxLabelLength > 255 {
+		return errors.New("taxonomyMaxLabelLength must be between 1 and 255")
+	}
+	if r.TaxonomyMaxLabelWords < 1 || r.TaxonomyMaxLabelWords > 50 {
+		return errors.New("taxonomyMaxLabelWords must be between 1 and 50")
+	}
+	if strings.Contains(r.TaxonomyBannedChars, "/") {
+		return errors.New("taxonomyBannedChars: / already separates levels")
+	}
+	switch r.TaxonomyLabelCase {
+	case CaseKeep, CaseLower, CaseSentence, CaseTitle:
+	default:
+		return errors.New("taxonomyLabelCase must be keep, lower, sentence or title")
+	}
+	if r.LLMTraceRetentionDays < 1 || r.LLMTraceRetentionDays > 365 {
+		return errors.New("llmTraceRetentionDays must be between 1 and 365")
+	}
+	if strings.TrimSpace(r.UserAgent) == "" {
+		return errors.New("userAgent must not be empty")
+	}
+	for _, rule := range r.HeaderRules {
+		if strings.TrimSpace(rule.Domain) == "" {
+			return errors.New("headerRules: domain required")
+		}
+		for name, value := range rule.Headers {
+			if !httpguts.ValidHeaderFieldName(name) || !httpguts.ValidHeaderFieldValue(value) {
+				return fmt.Errorf("headerRules: invalid header %q for %s", name, rule.Domain)
+			}
+			switch textproto.CanonicalMIMEHeaderKey(name) {
+			case "Host", "Cookie", "Content-Length":
+				return fmt.Errorf("headerRules: %s cannot be overridden", name)
+			}
+		}
+	}
+	for _, rule := range r.RemovalRules {
+		if strings.TrimSpace(rule.Domain) == "" {
+			return errors.New("removalRules: domain required")
+		}
+		if _, err := processor.ParseSelectors(rule.Selectors); err != nil {
+			return fmt.Errorf("removalRules: %s: %v", rule.Domain, err)
+		}
+	}
+	if err := r.CaptureHosts.Validate(); err != nil {
+		return fmt.Errorf("captureHosts: %v", err)
+	}
+	for user, rules := range r.UserCaptureHosts {
+		if err := rules.Validate(); err != nil {
+			return fmt.Errorf("userCaptureHosts: %s: %v", user, err)
+		}
+	}
+	return nil
+}
+
+func LoadRuntime(db *gorm.DB, base RuntimeSettings) (RuntimeSettings, error) {
+	out := base
+	keys := []string{KeyHTTPTimeout, KeyMaxAssetBytes, KeyAutoTagOnCapture, KeyAutoTagWorkers, KeyLLMTimeout, KeyLLMConcurrency, KeyUserAgent, KeyHeaderRules, KeyRemovalRules, KeyTaxonomyRouter,
+		KeyTaxonomyDepth, KeyTaxonomyOptions, KeyTaxonomyPathLen, KeyTaxonomyLabelLen,
+		KeyTaxonomyWords, KeyTaxonomyBanned, KeyTaxonomyCase, KeyTaxonomyMerge, KeyCaptureHosts, KeyUserCaptureHosts,
+		KeyLLMTrace, KeyLLMTraceDays}
+	var rows []models.AppSetting
+	if err := db.Where("setting_key IN ?", keys).Find(&rows).Error; err != nil {
+		return out, err
+	}
+	for _, row := range rows {
+		switch row.Key {
+		case KeyHTTPTimeout:
+			if v, err := strconv.Atoi(row.Value); err == nil {
+				out.HTTPTimeoutSeconds = v
+			}
+		case KeyMaxAssetBytes:
+			if v, err := strconv.ParseInt(row.Value, 10, 64); err == nil {
+				out.MaxAssetBytes = v
+			}
+		case KeyAutoTagOnCapture:
+			if v, err := strconv.ParseBool(row.Value); err == nil {
+				out.AutoTagOnCapture = v
+			}
+		case KeyAutoTagWorkers:
+			if v, err := strconv.Atoi(row.Value); err == nil {
+				out.AutoTagWorkers = v
+			}
+		case KeyLLMTimeout:
+			if v, err := strconv.Atoi(row.Value); err == nil {
+				out.LLMTimeoutSeconds = v
+			}
+		case KeyLLMConcurrency:
+			if v, err := strconv.Atoi(row.Value); err == nil {
+				out.LLMConcurrency = v
+			}
+		case KeyUserAgent:
+			if row.Value != "" {
+				out.UserAgent = row.Value
+			}
+		case KeyTaxonomyRouter:
+			if row.Value == RouterStepwise || row.Value == RouterSingle {
+				out.TaxonomyRouter = row.Value
+			}
+		case KeyTaxonomyDepth:
+			if v, err := strconv.Atoi(row.Value); err == nil {
+				out.TaxonomyMaxDepth = v
+			}
+		case KeyTaxonomyOptions:
+			if v, err := strconv.Atoi(row.Value); err == nil {
+				out.TaxonomyMaxOptions = v
+			}
+		case KeyTaxonomyPathLen:
+			if v, err := strconv.Atoi(row.Value); err == nil {
+				out.TaxonomyMaxPathLength = v
+			}
+		case KeyTaxonomyLabelLen:
+			if v, err := strconv.Atoi(row.Value); err == nil {
+				out.TaxonomyMaxLabelLength = v
+			}
+		case KeyTaxonomyWords:
+			if v, err := strconv.Atoi(row.Value); err == nil {
+				out.TaxonomyMaxLabelWords = v
+			}
+		case KeyTaxonomyBanned:
+			out.TaxonomyBannedChars = row.Value
+		case KeyTaxonomyCase:
+			switch row.Value {
+			case CaseKeep, CaseLower, CaseSentence, CaseTitle:
+				out.TaxonomyLabelCase = row.Value
+			}
+		case KeyTaxonomyMerge:
+			if v, err := strconv.ParseBool(row.Value); err == nil {
+				out.TaxonomyMergeSimilar = v
+			}
+		case KeyLLMTrace:
+			if v, err := strconv.ParseBool(row.Value); err == nil {
+				out.LLMTrace = v
+			}
+		case KeyLLMTraceDays:
+			if v, err := strconv.Atoi(row.Value); err == nil {
+				out.LLMTraceRetentionDays = v
+			}
+		case KeyHeaderRules:
+			var rules []HeaderRule
+			if err := json.Unmarshal([]byte(row.Value), &rules); err == nil {
+				out.HeaderRules = rules
+			}
+		case KeyRemovalRules:
+			var rules []RemovalRule
+			if err := json.Unmarshal([]byte(row.Value), &rules); err == nil {
+				out.RemovalRules = rules
+			}
+		case KeyCaptureHosts:
+			var rules processor.HostRules
+			if err := json.Unmarshal([]byte(row.Value), &rules); err == nil {
+				out.CaptureHosts = rules
+			}
+		case KeyUserCaptureHosts:
+			var rules map[string]processor.HostRules
+			if err := json.Unmarshal([]byte(row.Value), &rules); err == nil {
+				out.UserCaptureHosts = rules
+			}
+		}
+	}
+	return out, nil
+}
+
+func SaveRuntime(db *gorm.DB, cfg RuntimeSettings) error {
+	rules, err := json.Marshal(cfg.HeaderRules)
+	if err != nil {
+		return err
+	}
+	removal, err := json.Marshal(cfg.RemovalRules)
+	if err != nil {
+		return err
+	}
+	hosts, err := json.Marshal(cfg.CaptureHosts)
+	if err != nil {
+		return err
+	}
+	userHosts, err := json.Marshal(cfg.UserCaptureHosts)
+	if err != nil {
+		return err
+	}
+	rows := []models.AppSetting{
+		{Key: KeyHTTPTimeout, Value: strconv.Itoa(cfg.HTTPTimeoutSeconds)},
+		{Key: KeyMaxAssetBytes, Value: strconv.FormatInt(cfg.MaxAssetBytes, 10)},
+		{Key: KeyAutoTagOnCapture, Value: strconv.FormatBool(cfg.AutoTagOnCapture)},
+		{Key: KeyAutoTagWorkers, Value: strconv.Itoa(cfg.AutoTagWorkers)},
+		{Key: KeyLLMTimeout, Value: strconv.Itoa(cfg.LLMTimeoutSeconds)},
+		{Key: KeyLLMConcurrency, Value: strconv.Itoa(cfg.LLMConcurrency)},
+		{Key: KeyUserAgent, Value: cfg.UserAgent},
+		{Key: KeyHeaderRules, Value: string(rules)},
+		{Key: KeyRemovalRules, Value: string(removal)},
+		{Key: KeyTaxonomyRouter, Value: cfg.TaxonomyRouter},
+		{Key: KeyTaxonomyDepth, Value: strconv.Itoa(cfg.TaxonomyMaxDepth)},
+		{Key: KeyTaxonomyOptions, Value: strconv.Itoa(cfg.TaxonomyMaxOptions)},
+		{Key: KeyTaxonomyPathLen, Value: strconv.Itoa(cfg.TaxonomyMaxPathLength)},
+		{Key: KeyTaxonomyLabelLen, Value: strconv.Itoa(cfg.TaxonomyMaxLabelLength)},
+		{Key: KeyTaxonomyWords, Value: strconv.Itoa(cfg.TaxonomyMaxLabelWords)},
+		{Key: KeyTaxonomyBanned, Value: cfg.TaxonomyBannedChars},
+		{Key: KeyTaxonomyCase, Value: cfg.TaxonomyLabelCase},
+		{Key: KeyTaxonomyMerge, Value: strconv.FormatBool(cfg.TaxonomyMergeSimilar)},
+		{Key: KeyCaptureHosts, Value: string(hosts)},
+		{Key: KeyUserCaptureHosts, Value: string(userHosts)},
+		{Key: KeyLLMTrace, Value: strconv.FormatBool(cfg.LLMTrace)},
+		{Key: KeyLLMTraceDays, Value: strconv.Itoa(cfg.LLMTraceRetentionDays)},
+	}
+	for _, row := range rows {
+		if err := db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "setting_key"}},
+			UpdateAll: true,
+		}).Create(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
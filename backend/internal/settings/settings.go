@@ -31,7 +31,11 @@ func LoadLLM(db *gorm.DB) (LLMSettings, error) {
 		case KeyLLMBaseURL:
 			out.BaseURL = row.Value
 		case KeyLLMAPIKey:
-			out.APIKey = row.Value
+			key, err := decryptSecret(row.Value)
+			if err != nil {
+				return out, err
+			}
+			out.APIKey = key
 		case KeyLLMModel:
 			out.Model = row.Value
 		}
@@ -40,9 +44,13 @@ func LoadLLM(db *gorm.DB) (LLMSettings, error) {
 }
 
 func SaveLLM(db *gorm.DB, cfg LLMSettings) error {
+	apiKey, err := encryptSecret(cfg.APIKey)
+	if err != nil {
+		return err
+	}
 	rows := []models.AppSetting{
 		{Key: KeyLLMBaseURL, Value: cfg.BaseURL},
-		{Key: KeyLLMAPIKey, Value: cfg.APIKey},
+		{Key: KeyLLMAPIKey, Value: apiKey},
 		{Key: KeyLLMModel, Value: cfg.Model},
 	}
 	for _, row := range rows {
@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// sealedMeta marks sealed objects in their user metadata, so reads know to
+// open them without looking at the content.
+const sealedMeta = "Webarchive-Sealed"
+
+// sealMagic prefixes every sealed object, ahead of the nonce.
+var sealMagic = []byte("WAENC1\x00")
+
+var (
+	ErrNoCipher  = errors.New("object is encrypted and no encryption key is configured")
+	ErrBadSealed = errors.New("encrypted object is corrupt or was sealed with another key")
+)
+
+// Cipher seals archive objects with AES-256-GCM. Each archive has its own
+// key, derived with HKDF-SHA256 from the master secret and the archive ID,
+// so one leaked archive key doesn't open the others. Changing the master
+// secret makes every sealed object unreadable.
+type Cipher struct {
+	master []byte
+}
+
+func NewCipher(secret string) (*Cipher, error) {
+	if len(secret) < 16 {
+		return nil, errors.New("encryption key must be at least 16 bytes")
+	}
+	return &Cipher{master: []byte(secret)}, nil
+}
+
+func (c *Cipher) aead(archiveID string) (cipher.AEAD, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, c.master, nil, []byte("webarchive archive "+archiveID)), key); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Seal encrypts plain with the archive's key. The archive ID is also the
+// additional data, so an object copied into another archive won't open.
+func (c *Cipher) Seal(archiveID string, plain []byte) ([]byte, error) {
+	gcm, err := c.aead(archiveID)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(sealMagic)+gcm.NonceSize(), len(sealMagic)+gcm.NonceSize()+len(plain)+gcm.Overhead())
+	copy(out, sealMagic)
+	if _, err := rand.Read(out[len(sealMagic):]); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(out, out[len(sealMagic):], plain, []byte(archiveID)), nil
+}
+
+func (c *Cipher) Open(archiveID string, sealed []byte) ([]byte, error) {
+	gcm, err := c.aead(archiveID)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(sealed, sealMagic) || len(sealed) < len(sealMagic)+gcm.NonceSize() {
+		return nil, ErrBadSealed
+	}
+	sealed = sealed[len(sealMagic):]
+	plain, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(archiveID))
+	if err != nil {
+		return nil, ErrBadSealed
+	}
+	return plain, nil
+}
+
+// archiveIDOf returns the archive an object belongs to, or "" for objects
+// outside ArchivePrefix.
+func archiveIDOf(objectPath string) string {
+	rest, ok := strings.CutPrefix(objectPath, ArchivePrefix(""))
+	if !ok {
+		return ""
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	return id
+}
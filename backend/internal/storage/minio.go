@@ -3,6 +3,9 @@ package storage
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"mime"
@@ -16,6 +19,21 @@ import (
 type MinioStore struct {
 	Client *minio.Client
 	Bucket string
+	// Cold is an optional cheaper tier (another bucket, possibly on another
+	// endpoint) that MoveToCold migrates objects to. Writes always go to
+	// this store; reads fall back to Cold for objects not found here.
+	Cold *MinioStore
+	// Cipher, when set, opens sealed objects on Get and seals new objects
+	// of the archives Sealed reports.
+	Cipher *Cipher
+	Sealed func(archiveID string) bool
+}
+
+// Object is a stored object as Get returns it: the object itself, or its
+// decrypted content when it was sealed.
+type Object interface {
+	io.ReadSeekCloser
+	Stat() (minio.ObjectInfo, error)
 }
 
 func NewMinioStore(endpoint, accessKey, secretKey string, secure bool, bucket string) (*MinioStore, error) {
@@ -41,23 +59,206 @@ func NewMinioStore(endpoint, accessKey, secretKey string, secure bool, bucket st
 	return &MinioStore{Client: client, Bucket: bucket}, nil
 }
 
+func (s *MinioStore) Ping(ctx context.Context) error {
+	exists, err := s.Client.BucketExists(ctx, s.Bucket)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("bucket %s missing", s.Bucket)
+	}
+	if s.Cold != nil {
+		return s.Cold.Ping(ctx)
+	}
+	return nil
+}
+
+// MaxSealedBytes caps an object of a sealed archive. Sealing works on the
+// whole object, so writing one holds it in memory twice, plain and sealed.
+const MaxSealedBytes = 64 << 20
+
+var ErrSealedTooLarge = errors.New("object too large to seal")
+
+// Seals reports whether an object written to objectPath is sealed.
+func (s *MinioStore) Seals(objectPath string) bool {
+	id := archiveIDOf(objectPath)
+	return id != "" && s.Cipher != nil && s.Sealed != nil && s.Sealed(id)
+}
+
 func (s *MinioStore) PutBytes(ctx context.Context, objectPath string, data []byte, contentType string) error {
-	reader := bytes.NewReader(data)
-	_, err := s.Client.PutObject(ctx, s.Bucket, objectPath, reader, int64(len(data)), minio.PutObjectOptions{
-		ContentType: contentType,
-	})
+	opts := minio.PutObjectOptions{ContentType: contentType}
+	if s.Seals(objectPath) {
+		sealed, err := s.Cipher.Seal(archiveIDOf(objectPath), data)
+		if err != nil {
+			return err
+		}
+		data = sealed
+		opts.UserMetadata = map[string]string{sealedMeta: "1"}
+	}
+	_, err := s.Client.PutObject(ctx, s.Bucket, objectPath, bytes.NewReader(data), int64(len(data)), opts)
 	return err
 }
 
+// StreamPartSize is the multipart chunk used for uploads of unknown length,
+// and so the memory such an upload holds.
+const StreamPartSize = 16 << 20
+
+// PutStream uploads r; a negative size streams it as a multipart upload in
+// StreamPartSize parts instead of needing the length up front.
+//
+// Objects of sealed archives are sealed whole, so they are read into memory
+// first and fail with ErrSealedTooLarge beyond MaxSealedBytes.
 func (s *MinioStore) PutStream(ctx context.Context, objectPath string, r io.Reader, size int64, contentType string) error {
-	_, err := s.Client.PutObject(ctx, s.Bucket, objectPath, r, size, minio.PutObjectOptions{
-		ContentType: contentType,
-	})
+	if s.Seals(objectPath) {
+		if size > MaxSealedBytes {
+			return ErrSealedTooLarge
+		}
+		data, err := io.ReadAll(io.LimitReader(r, MaxSealedBytes+1))
+		if err != nil {
+			return err
+		}
+		if len(data) > MaxSealedBytes {
+			return ErrSealedTooLarge
+		}
+		return s.PutBytes(ctx, objectPath, data, contentType)
+	}
+	opts := minio.PutObjectOptions{ContentType: contentType}
+	if size < 0 {
+		opts.PartSize = StreamPartSize
+	}
+	_, err := s.Client.PutObject(ctx, s.Bucket, objectPath, r, size, opts)
 	return err
 }
 
-func (s *MinioStore) Get(ctx context.Context, objectPath string) (*minio.Object, error) {
-	return s.Client.GetObject(ctx, s.Bucket, objectPath, minio.GetObjectOptions{})
+// Get opens an object, decrypting it when it was sealed. A missing object
+// is not an error until Stat or Read.
+func (s *MinioStore) Get(ctx context.Context, objectPath string) (Object, error) {
+	obj, err := s.Client.GetObject(ctx, s.Bucket, objectPath, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	info, err := obj.Stat()
+	if err != nil {
+		if s.Cold != nil && IsNotFound(err) {
+			obj.Close()
+			return s.Cold.Get(ctx, objectPath)
+		}
+		return obj, nil
+	}
+	if info.UserMetadata[sealedMeta] == "" {
+		return obj, nil
+	}
+	defer obj.Close()
+	if s.Cipher == nil {
+		return nil, ErrNoCipher
+	}
+	sealed, err := io.ReadAll(obj)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := s.Cipher.Open(archiveIDOf(objectPath), sealed)
+	if err != nil {
+		return nil, err
+	}
+	info.Size = int64(len(plain))
+	return &openedObject{Reader: bytes.NewReader(plain), info: info}, nil
+}
+
+type openedObject struct {
+	*bytes.Reader
+	info minio.ObjectInfo
+}
+
+func (o *openedObject) Stat() (minio.ObjectInfo, error) { return o.info, nil }
+func (o *openedObject) Close() error                    { return nil }
+
+// SealArchive seals (or with seal false, opens) every object of an archive
+// in place, in both tiers, and returns how many it rewrote. Objects already
+// in the wanted state are left alone, so an interrupted run can be retried.
+func (s *MinioStore) SealArchive(ctx context.Context, archiveID string, seal bool) (int, error) {
+	if s.Cipher == nil {
+		return 0, errors.New("no encryption key configured")
+	}
+	changed := 0
+	opts := minio.ListObjectsOptions{Prefix: ArchivePrefix(archiveID) + "/", Recursive: true}
+	for obj := range s.Client.ListObjects(ctx, s.Bucket, opts) {
+		if obj.Err != nil {
+			return changed, obj.Err
+		}
+		done, err := s.sealObject(ctx, archiveID, obj.Key, seal)
+		if err != nil {
+			return changed, fmt.Errorf("%s: %w", obj.Key, err)
+		}
+		if done {
+			changed++
+		}
+	}
+	if s.Cold != nil {
+		n, err := s.Cold.SealArchive(ctx, archiveID, seal)
+		return changed + n, err
+	}
+	return changed, nil
+}
+
+func (s *MinioStore) sealObject(ctx context.Context, archiveID, key string, seal bool) (bool, error) {
+	obj, err := s.Client.GetObject(ctx, s.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return false, err
+	}
+	defer obj.Close()
+	info, err := obj.Stat()
+	if err != nil {
+		return false, err
+	}
+	if (info.UserMetadata[sealedMeta] != "") == seal {
+		return false, nil
+	}
+	data, err := io.ReadAll(obj)
+	if err != nil {
+		return false, err
+	}
+	opts := minio.PutObjectOptions{ContentType: info.ContentType}
+	if seal {
+		data, err = s.Cipher.Seal(archiveID, data)
+		opts.UserMetadata = map[string]string{sealedMeta: "1"}
+	} else {
+		data, err = s.Cipher.Open(archiveID, data)
+	}
+	if err != nil {
+		return false, err
+	}
+	_, err = s.Client.PutObject(ctx, s.Bucket, key, bytes.NewReader(data), int64(len(data)), opts)
+	return err == nil, err
+}
+
+// Checksum streams an object and returns its hex SHA-256 and size.
+func (s *MinioStore) Checksum(ctx context.Context, objectPath string) (string, int64, error) {
+	obj, err := s.Get(ctx, objectPath)
+	if err != nil {
+		return "", 0, err
+	}
+	defer obj.Close()
+	h := sha256.New()
+	n, err := io.Copy(h, obj)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+func IsNotFound(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
+}
+
+func (s *MinioStore) Remove(ctx context.Context, objectPath string) error {
+	err := s.Client.RemoveObject(ctx, s.Bucket, objectPath, minio.RemoveObjectOptions{})
+	if err != nil && !IsNotFound(err) {
+		return err
+	}
+	if s.Cold != nil {
+		return s.Cold.Remove(ctx, objectPath)
+	}
+	return nil
 }
 
 func (s *MinioStore) RemovePrefix(ctx context.Context, prefix string) error {
@@ -70,6 +271,99 @@ func (s *MinioStore) RemovePrefix(ctx context.Context, prefix string) error {
 			return err
 		}
 	}
+	if s.Cold != nil {
+		return s.Cold.RemovePrefix(ctx, prefix)
+	}
+	return nil
+}
+
+// PrefixSize sums the sizes of all objects under prefix in both tiers.
+func (s *MinioStore) PrefixSize(ctx context.Context, prefix string) (int64, error) {
+	var total int64
+	opts := minio.ListObjectsOptions{Prefix: prefix, Recursive: true}
+	for obj := range s.Client.ListObjects(ctx, s.Bucket, opts) {
+		if obj.Err != nil {
+			return 0, obj.Err
+		}
+		total += obj.Size
+	}
+	if s.Cold != nil {
+		cold, err := s.Cold.PrefixSize(ctx, prefix)
+		if err != nil {
+			return 0, err
+		}
+		total += cold
+	}
+	return total, nil
+}
+
+// ArchiveIDs lists the archive IDs that have objects under archives/ in
+// either tier.
+func (s *MinioStore) ArchiveIDs(ctx context.Context) (map[string]bool, error) {
+	ids := map[string]bool{}
+	for store := s; store != nil; store = store.Cold {
+		opts := minio.ListObjectsOptions{Prefix: "archives/"}
+		for obj := range store.Client.ListObjects(ctx, store.Bucket, opts) {
+			if obj.Err != nil {
+				return nil, obj.Err
+			}
+			if id := strings.Trim(strings.TrimPrefix(obj.Key, "archives/"), "/"); id != "" {
+				ids[id] = true
+			}
+		}
+	}
+	return ids, nil
+}
+
+// MoveToCold copies every object under prefix to the cold tier, checks the
+// copies' sizes and only then deletes the originals. It returns the number
+// of objects moved.
+func (s *MinioStore) MoveToCold(ctx context.Context, prefix string) (int, error) {
+	if s.Cold == nil {
+		return 0, errors.New("no cold storage tier configured")
+	}
+	var keys []string
+	opts := minio.ListObjectsOptions{Prefix: prefix, Recursive: true}
+	for obj := range s.Client.ListObjects(ctx, s.Bucket, opts) {
+		if obj.Err != nil {
+			return 0, obj.Err
+		}
+		if err := s.copyToCold(ctx, obj.Key); err != nil {
+			return 0, err
+		}
+		keys = append(keys, obj.Key)
+	}
+	for _, key := range keys {
+		if err := s.Client.RemoveObject(ctx, s.Bucket, key, minio.RemoveObjectOptions{}); err != nil && !IsNotFound(err) {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
+
+func (s *MinioStore) copyToCold(ctx context.Context, key string) error {
+	obj, err := s.Client.GetObject(ctx, s.Bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return err
+	}
+	defer obj.Close()
+	info, err := obj.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := s.Cold.Client.PutObject(ctx, s.Cold.Bucket, key, obj, info.Size, minio.PutObjectOptions{
+		ContentType:  info.ContentType,
+		UserMetadata: info.UserMetadata,
+	}); err != nil {
+		return err
+	}
+	copied, err := s.Cold.Client.StatObject(ctx, s.Cold.Bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return err
+	}
+	if copied.Size != info.Size {
+		return fmt.Errorf("cold copy of %s has %d bytes, want %d", key, copied.Size, info.Size)
+	}
 	return nil
 }
 
@@ -0,0 +1,42 @@
+package textutil
+
+import "strings"
+
+// LineDiff compares two texts as multisets of non-empty lines, ignoring
+// whitespace differences and line order. It returns the lines only in b
+// (added) and only in a (removed), plus the share of all lines that
+// changed, from 0 to 100.
+func LineDiff(a, b string) (added, removed []string, percent float64) {
+	oldLines, newLines := diffLines(a), diffLines(b)
+	counts := make(map[string]int, len(oldLines))
+	for _, line := range oldLines {
+		counts[line]++
+	}
+	for _, line := range newLines {
+		if counts[line] > 0 {
+			counts[line]--
+			continue
+		}
+		added = append(added, line)
+	}
+	for _, line := range oldLines {
+		if counts[line] > 0 {
+			counts[line]--
+			removed = append(removed, line)
+		}
+	}
+	if total := len(oldLines) + len(newLines); total > 0 {
+		percent = float64(len(added)+len(removed)) * 100 / float64(total)
+	}
+	return added, removed, percent
+}
+
+func diffLines(text string) []string {
+	var out []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			out = append(out, line)
+		}
+	}
+	return out
+}
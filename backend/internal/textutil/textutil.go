@@ -0,0 +1,151 @@
+// Package textutil holds text helpers shared by the capture, AI and API
+// layers.
+package textutil
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Truncate returns the longest prefix of s that fits in max bytes without
+// splitting a UTF-8 sequence or a user-perceived character (a base rune with
+// its combining marks, an emoji ZWJ sequence, a flag).
+func Truncate(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	if len(s) <= max {
+		return s
+	}
+	cut := max
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:graphemeBoundary(s, cut)]
+}
+
+// TruncateRunes is Truncate measured in characters instead of bytes, for
+// limits that are about what a person reads (labels, titles).
+func TruncateRunes(s string, max int) string {
+	if max <= 0 {
+		return ""
+	}
+	n := 0
+	for i := range s {
+		if n == max {
+			return s[:graphemeBoundary(s, i)]
+		}
+		n++
+	}
+	return s
+}
+
+// graphemeBoundary moves cut (a rune boundary) back until it does not fall
+// inside a grapheme cluster. This is a practical subset of UAX #29 that
+// covers combining marks, variation selectors, emoji modifiers, ZWJ
+// sequences and regional-indicator pairs.
+func graphemeBoundary(s string, cut int) int {
+	for cut > 0 && cut < len(s) {
+		next, _ := utf8.DecodeRuneInString(s[cut:])
+		prev, size := utf8.DecodeLastRuneInString(s[:cut])
+		if extendsCluster(next) || prev == zwj {
+			cut -= size
+			continue
+		}
+		if isRegionalIndicator(next) && isRegionalIndicator(prev) {
+			// Flags are pairs; count the run to see if cut splits one.
+			run, i := 0, cut
+			for i > 0 {
+				r, w := utf8.DecodeLastRuneInString(s[:i])
+				if !isRegionalIndicator(r) {
+					break
+				}
+				run++
+				i -= w
+			}
+			if run%2 == 1 {
+				cut -= size
+				continue
+			}
+		}
+		break
+	}
+	return cut
+}
+
+const zwj = '\u200d'
+
+func extendsCluster(r rune) bool {
+	switch {
+	case r == zwj:
+		return true
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return true
+	case r >= 0xFE00 && r <= 0xFE0F, r >= 0xE0100 && r <= 0xE01EF: // variation selectors
+		return true
+	case r >= 0x1F3FB && r <= 0x1F3FF: // emoji skin tone modifiers
+		return true
+	case r >= 0xE0020 && r <= 0xE007F: // emoji tag sequences
+		return true
+	}
+	return false
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// Chunks splits text into pieces of at most size bytes, breaking between
+// paragraphs where possible, then between lines, and only as a last resort
+// inside a line (still on a rune boundary).
+func Chunks(text string, size int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if size <= 0 || len(text) <= size {
+		return []string{text}
+	}
+	out := []string{}
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			out = append(out, s)
+		}
+		cur.Reset()
+	}
+	for _, piece := range pieces(text, size) {
+		if cur.Len() > 0 && cur.Len()+1+len(piece) > size {
+			flush()
+		}
+		if cur.Len() > 0 {
+			cur.WriteByte('\n')
+		}
+		cur.WriteString(piece)
+	}
+	flush()
+	return out
+}
+
+// pieces breaks text into paragraph- or line-sized units no longer than size.
+func pieces(text string, size int) []string {
+	out := []string{}
+	for _, para := range strings.Split(text, "\n") {
+		para = strings.TrimSpace(para)
+		for len(para) > size {
+			head := Truncate(para, size)
+			// Prefer breaking after a space or sentence end inside the window.
+			if i := strings.LastIndexAny(head, " 。．.!?！？"); i > size/2 {
+				_, w := utf8.DecodeRuneInString(head[i:])
+				head = head[:i+w]
+			}
+			out = append(out, head)
+			para = strings.TrimSpace(para[len(head):])
+		}
+		if para != "" {
+			out = append(out, para)
+		}
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package vectorstore
+
+import (
+	"context"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"webarchive/internal/models"
+)
+
+// DBStore searches the archive_embeddings cache table by brute force. It
+// needs no extra infrastructure and is fine for a few tens of thousands of
+// archives.
+type DBStore struct {
+	DB *gorm.DB
+}
+
+func NewDBStore(db *gorm.DB) *DBStore {
+	return &DBStore{DB: db}
+}
+
+func (s *DBStore) Name() string { return "db" }
+
+// Upsert is a no-op: the embedding cache rows written by the caller already
+// are this store's index.
+func (s *DBStore) Upsert(ctx context.Context, model string, points []Point) error {
+	return nil
+}
+
+func (s *DBStore) Delete(ctx context.Context, model string, archiveIDs []string) error {
+	if len(archiveIDs) == 0 {
+		return nil
+	}
+	return s.DB.WithContext(ctx).Where("model = ? AND archive_id IN ?", model, archiveIDs).Delete(&models.ArchiveEmbedding{}).Error
+}
+
+func (s *DBStore) Search(ctx context.Context, model string, vector []float32, limit int) ([]Match, error) {
+	var rows []models.ArchiveEmbedding
+	if err := s.DB.WithContext(ctx).Where("model = ?", model).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make([]Match, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, Match{ArchiveID: row.ArchiveID, Score: Cosine(vector, DecodeVector(row.Vector))})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Score > out[j].Score })
+	if limit > 0 && len(out) > limit {
+		out = out[:limit]
+	}
+	return out, nil
+}
@@ -0,0 +1,181 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var collectionUnsafe = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// pointNamespace derives stable Qdrant point IDs from archive IDs, which
+// are not guaranteed to be UUIDs.
+var pointNamespace = uuid.MustParse("6f1c2b1e-7d0a-4c55-9a53-3f0e9b7c2a10")
+
+// QdrantStore talks to Qdrant's REST API, one collection per embedding
+// model.
+type QdrantStore struct {
+	BaseURL string
+	APIKey  string
+	Prefix  string
+	HTTP    *http.Client
+
+	mu      sync.Mutex
+	created map[string]bool
+}
+
+func NewQdrantStore(baseURL, apiKey, prefix string) *QdrantStore {
+	return &QdrantStore{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		APIKey:  apiKey,
+		Prefix:  prefix,
+		HTTP:    &http.Client{Timeout: 30 * time.Second},
+		created: map[string]bool{},
+	}
+}
+
+func (s *QdrantStore) Name() string { return "qdrant" }
+
+func (s *QdrantStore) collection(model string) string {
+	return s.Prefix + "_" + collectionUnsafe.ReplaceAllString(model, "_")
+}
+
+func pointID(archiveID string) string {
+	return uuid.NewSHA1(pointNamespace, []byte(archiveID)).String()
+}
+
+func (s *QdrantStore) ensureCollection(ctx context.Context, name string, dims int) error {
+	s.mu.Lock()
+	done := s.created[name]
+	s.mu.Unlock()
+	if done {
+		return nil
+	}
+	status, _, err := s.do(ctx, http.MethodGet, "/collections/"+name, nil)
+	if err != nil {
+		return err
+	}
+	if status == http.StatusNotFound {
+		body := map[string]any{"vectors": map[string]any{"size": dims, "distance": "Cosine"}}
+		if status, raw, err := s.do(ctx, http.MethodPut, "/collections/"+name, body); err != nil {
+			return err
+		} else if status >= 300 {
+			return fmt.Errorf("qdrant create collection: %d %s", status, raw)
+		}
+	}
+	s.mu.Lock()
+	s.created[name] = true
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *QdrantStore) Upsert(ctx context.Context, model string, points []Point) error {
+	if len(points) == 0 {
+		return nil
+	}
+	name := s.collection(model)
+	if err := s.ensureCollection(ctx, name, len(points[0].Vector)); err != nil {
+		return err
+	}
+	out := make([]map[string]any, 0, len(points))
+	for _, p := range points {
+		out = append(out, map[string]any{
+			"id":      pointID(p.ArchiveID),
+			"vector":  p.Vector,
+			"payload": map[string]any{"archive_id": p.ArchiveID},
+		})
+	}
+	status, raw, err := s.do(ctx, http.MethodPut, "/collections/"+name+"/points?wait=true", map[string]any{"points": out})
+	if err != nil {
+		return err
+	}
+	if status >= 300 {
+		return fmt.Errorf("qdrant upsert: %d %s", status, raw)
+	}
+	return nil
+}
+
+func (s *QdrantStore) Delete(ctx context.Context, model string, archiveIDs []string) error {
+	if len(archiveIDs) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(archiveIDs))
+	for _, id := range archiveIDs {
+		ids = append(ids, pointID(id))
+	}
+	status, raw, err := s.do(ctx, http.MethodPost, "/collections/"+s.collection(model)+"/points/delete?wait=true", map[string]any{"points": ids})
+	if err != nil {
+		return err
+	}
+	if status >= 300 && status != http.StatusNotFound {
+		return fmt.Errorf("qdrant delete: %d %s", status, raw)
+	}
+	return nil
+}
+
+func (s *QdrantStore) Search(ctx context.Context, model string, vector []float32, limit int) ([]Match, error) {
+	body := map[string]any{"vector": vector, "limit": limit, "with_payload": true}
+	status, raw, err := s.do(ctx, http.MethodPost, "/collections/"+s.collection(model)+"/points/search", body)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return []Match{}, nil
+	}
+	if status >= 300 {
+		return nil, fmt.Errorf("qdrant search: %d %s", status, raw)
+	}
+	var res struct {
+		Result []struct {
+			Score   float64 `json:"score"`
+			Payload struct {
+				ArchiveID string `json:"archive_id"`
+			} `json:"payload"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &res); err != nil {
+		return nil, err
+	}
+	out := make([]Match, 0, len(res.Result))
+	for _, r := range res.Result {
+		out = append(out, Match{ArchiveID: r.Payload.ArchiveID, Score: r.Score})
+	}
+	return out, nil
+}
+
+func (s *QdrantStore) do(ctx context.Context, method, path string, body any) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.BaseURL+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if s.APIKey != "" {
+		req.Header.Set("api-key", s.APIKey)
+	}
+	resp, err := s.HTTP.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	raw, _ := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	return resp.StatusCode, raw, nil
+}
@@ -0,0 +1,58 @@
+package vectorstore
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+)
+
+type Point struct {
+	ArchiveID string
+	Vector    []float32
+}
+
+type Match struct {
+	ArchiveID string  `json:"archiveId"`
+	Score     float64 `json:"score"`
+}
+
+// Store is a vector index keyed by archive ID. Vectors from different
+// embedding models live in separate namespaces and are never compared.
+type Store interface {
+	Name() string
+	Upsert(ctx context.Context, model string, points []Point) error
+	Delete(ctx context.Context, model string, archiveIDs []string) error
+	Search(ctx context.Context, model string, vector []float32, limit int) ([]Match, error)
+}
+
+func EncodeVector(v []float32) []byte {
+	out := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(out[4*i:], math.Float32bits(f))
+	}
+	return out
+}
+
+func DecodeVector(b []byte) []float32 {
+	out := make([]float32, len(b)/4)
+	for i := range out {
+		out[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return out
+}
+
+func Cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
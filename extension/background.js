@@ -6,10 +6,12 @@ const setBadge = (text, color) => {
   }
 }
 
-const postArchive = async (serverUrl, payload) => {
+const authHeaders = (apiToken) => (apiToken ? { Authorization: `Bearer ${apiToken}` } : {})
+
+const postArchive = async (serverUrl, apiToken, payload) => {
   const response = await fetch(`${serverUrl}/api/archives`, {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: { 'Content-Type': 'application/json', ...authHeaders(apiToken) },
     body: JSON.stringify(payload),
   })
   let data = null
@@ -25,9 +27,10 @@ const postArchive = async (serverUrl, payload) => {
   return data
 }
 
-const requestAiTag = async (serverUrl, archiveId) => {
+const requestAiTag = async (serverUrl, apiToken, archiveId) => {
   const response = await fetch(`${serverUrl}/api/archives/${archiveId}/ai-tag`, {
     method: 'POST',
+    headers: authHeaders(apiToken),
   })
   let data = null
   try {
@@ -45,6 +48,37 @@ const requestAiTag = async (serverUrl, archiveId) => {
   return data
 }
 
+// pageCookies returns the tab's cookies in the shape the backend expects.
+// They are only used for that capture and are not stored server-side.
+const pageCookies = async (url) => {
+  try {
+    const cookies = await chrome.cookies.getAll({ url })
+    return cookies.map((c) => ({
+      name: c.name,
+      value: c.value,
+      domain: c.domain,
+      path: c.path,
+      secure: c.secure,
+      hostOnly: c.hostOnly,
+    }))
+  } catch (_err) {
+    return []
+  }
+}
+
+const captureMHTML = async (tabId) => {
+  const blob = await chrome.pageCapture.saveAsMHTML({ tabId })
+  return blob ? blob.text() : ''
+}
+
+const captureScreenshot = async (windowId) => {
+  try {
+    return await chrome.tabs.captureVisibleTab(windowId, { format: 'jpeg', quality: 70 })
+  } catch (_err) {
+    return ''
+  }
+}
+
 const notifyPopup = (payload) => {
   try {
     chrome.runtime.sendMessage(payload, () => {
@@ -69,8 +103,23 @@ const reportStatus = async (status, message) => {
   notifyPopup({ type: 'capture-status', status, message })
 }
 
+// renderOptions applies a preset's render mode over the popup toggles:
+// readability sends the extracted article, full the whole page packaged by
+// the browser. Selection is handled by the popup starting the picker.
+const renderOptions = ({ renderMode, cleanContent, fullSnapshot }) => {
+  switch (renderMode) {
+    case 'readability':
+      return { cleanContent: true, fullSnapshot: false }
+    case 'full':
+      return { cleanContent: false, fullSnapshot: true }
+    default:
+      return { cleanContent, fullSnapshot }
+  }
+}
+
 const handleCapture = async (mode, options) => {
-  const { serverUrl, category, tags, autoTag, cleanContent } = options
+  const { serverUrl, apiToken, category, tags, autoTag, sendCookies, metadataOnly, preset } = options
+  const { cleanContent, fullSnapshot } = renderOptions(options)
   const [tab] = await chrome.tabs.query({ active: true, currentWindow: true })
   if (!tab || !tab.id) {
     setBadge('ERR', '#b00020')
@@ -95,11 +144,28 @@ const handleCapture = async (mode, options) => {
         category: category || '',
         tags: tags || [],
         autoTag: Boolean(autoTag),
+        preset: preset || '',
+        source: 'extension',
+        client: `webarchive-extension/${chrome.runtime.getManifest().version}`,
+      }
+      if (sendCookies && tab.url) {
+        payload.cookies = await pageCookies(tab.url)
+      }
+      if (metadataOnly) {
+        payload.mode = 'metadata'
+        payload.screenshot = await captureScreenshot(tab.windowId)
+        delete payload.html
+      } else if (fullSnapshot && mode === 'capture') {
+        // The browser packages the page with its resources, which also
+        // covers anything behind a login.
+        payload.snapshot = await captureMHTML(tab.id)
+        payload.snapshotFormat = 'mhtml'
+        delete payload.html
       }
-      const archive = await postArchive(serverUrl, payload)
+      const archive = await postArchive(serverUrl, apiToken, payload)
       if (autoTag && archive?.id) {
         reportStatus('progress', '已保存，AI 分类中…')
-        await requestAiTag(serverUrl, archive.id)
+        await requestAiTag(serverUrl, apiToken, archive.id)
       }
       setBadge('OK', '#2ea043')
       reportStatus('ok', '归档成功')
@@ -110,6 +110,31 @@ const captureReadable = () => {
   }
 }
 
+// selectedQuotes turns the current text selection into capture annotations,
+// with a little surrounding text so the server can find the right passage.
+const selectedQuotes = () => {
+  const selection = window.getSelection()
+  if (!selection || selection.isCollapsed || !document.body) return []
+  const quotes = []
+  for (let i = 0; i < selection.rangeCount; i += 1) {
+    const range = selection.getRangeAt(i)
+    const quote = range.toString().trim()
+    if (!quote) continue
+    const before = document.createRange()
+    before.setStart(document.body, 0)
+    before.setEnd(range.startContainer, range.startOffset)
+    const after = document.createRange()
+    after.setStart(range.endContainer, range.endOffset)
+    after.setEndAfter(document.body.lastChild || document.body)
+    quotes.push({
+      quote,
+      prefix: before.toString().slice(-64),
+      suffix: after.toString().slice(0, 64),
+    })
+  }
+  return quotes
+}
+
 const buildPayload = (html, text, meta = {}) => ({
   url: location.href,
   title: meta.title || document.title || '',
@@ -120,6 +145,7 @@ const buildPayload = (html, text, meta = {}) => ({
   siteName: meta.siteName || '',
   favicon: findFavicon(),
   capturedAt: new Date().toISOString(),
+  annotations: selectedQuotes(),
 })
 
 const createOverlay = () => {
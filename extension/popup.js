@@ -1,5 +1,6 @@
 const statusEl = document.getElementById('status')
 const serverInput = document.getElementById('serverUrl')
+const tokenInput = document.getElementById('apiToken')
 const captureBtn = document.getElementById('captureBtn')
 const selectBtn = document.getElementById('selectBtn')
 const categoryInput = document.getElementById('category')
@@ -7,7 +8,13 @@ const tagsInput = document.getElementById('tags')
 const autoTagInput = document.getElementById('autoTag')
 const enableOptionalInput = document.getElementById('enableOptional')
 const cleanContentInput = document.getElementById('cleanContent')
+const sendCookiesInput = document.getElementById('sendCookies')
+const metadataOnlyInput = document.getElementById('metadataOnly')
+const fullSnapshotInput = document.getElementById('fullSnapshot')
 const optionalDetails = document.getElementById('optionalDetails')
+const pairCodeInput = document.getElementById('pairCode')
+const pairBtn = document.getElementById('pairBtn')
+const presetInput = document.getElementById('preset')
 
 const setStatus = (msg) => {
   statusEl.textContent = msg
@@ -27,14 +34,20 @@ const updateOptionalState = () => {
 const loadServer = async () => {
   const data = await chrome.storage.sync.get([
     'serverUrl',
+    'apiToken',
     'category',
     'tags',
     'autoTag',
     'enableOptional',
     'cleanContent',
+    'sendCookies',
+    'metadataOnly',
+    'fullSnapshot',
+    'preset',
     'lastStatus',
   ])
   serverInput.value = data.serverUrl || 'http://localhost:8080'
+  if (tokenInput) tokenInput.value = data.apiToken || ''
   if (categoryInput) categoryInput.value = data.category || ''
   if (tagsInput) tagsInput.value = data.tags || ''
   if (autoTagInput) autoTagInput.checked = Boolean(data.autoTag)
@@ -45,17 +58,53 @@ const loadServer = async () => {
   if (cleanContentInput) {
     cleanContentInput.checked = data.cleanContent !== false
   }
+  if (sendCookiesInput) sendCookiesInput.checked = Boolean(data.sendCookies)
+  if (metadataOnlyInput) metadataOnlyInput.checked = Boolean(data.metadataOnly)
+  if (fullSnapshotInput) fullSnapshotInput.checked = Boolean(data.fullSnapshot)
   if (data.lastStatus) setStatus(data.lastStatus)
+  await loadPresets(data.preset || '')
 }
 
+// loadPresets fills the preset list from the backend; each option carries
+// the preset's render mode so the capture can follow it.
+const loadPresets = async (selected) => {
+  if (!presetInput) return
+  const serverUrl = serverInput.value.trim() || 'http://localhost:8080'
+  const apiToken = tokenInput?.value.trim() || ''
+  try {
+    const res = await fetch(`${serverUrl}/api/client/config`, {
+      headers: apiToken ? { Authorization: `Bearer ${apiToken}` } : {},
+    })
+    if (!res.ok) return
+    const data = await res.json()
+    presetInput.length = 1
+    for (const preset of data.presets || []) {
+      const option = new Option(preset.name, preset.id)
+      option.dataset.renderMode = preset.renderMode || ''
+      presetInput.add(option)
+    }
+    presetInput.value = selected
+    if (presetInput.value !== selected) presetInput.value = ''
+  } catch (_err) {
+    // backend unreachable; capture without presets
+  }
+}
+
+const presetRenderMode = () => presetInput?.selectedOptions[0]?.dataset.renderMode || ''
+
 const saveSettings = async () => {
   await chrome.storage.sync.set({
     serverUrl: serverInput.value.trim(),
+    apiToken: tokenInput?.value.trim() || '',
     category: categoryInput?.value.trim() || '',
     tags: tagsInput?.value.trim() || '',
     autoTag: Boolean(autoTagInput?.checked),
     enableOptional: Boolean(enableOptionalInput?.checked),
     cleanContent: Boolean(cleanContentInput?.checked),
+    sendCookies: Boolean(sendCookiesInput?.checked),
+    metadataOnly: Boolean(metadataOnlyInput?.checked),
+    fullSnapshot: Boolean(fullSnapshotInput?.checked),
+    preset: presetInput?.value || '',
   })
 }
 
@@ -79,15 +128,60 @@ const sendToBackground = async (type) => {
   chrome.runtime.sendMessage({
     type,
     serverUrl: serverInput.value.trim() || 'http://localhost:8080',
+    apiToken: tokenInput?.value.trim() || '',
     category: getCategory(),
     tags: buildTags(),
     autoTag: Boolean(autoTagInput?.checked),
     enableOptional: Boolean(enableOptionalInput?.checked),
     cleanContent: Boolean(cleanContentInput?.checked),
+    sendCookies: Boolean(sendCookiesInput?.checked),
+    metadataOnly: Boolean(metadataOnlyInput?.checked),
+    fullSnapshot: Boolean(fullSnapshotInput?.checked),
+    preset: presetInput?.value || '',
+    renderMode: presetRenderMode(),
   })
 }
 
+// Pairing trades a short code from the web UI for a capture token, so the
+// user never has to copy the token itself.
+const pairDevice = async () => {
+  const code = pairCodeInput?.value.trim()
+  if (!code) {
+    setStatus('请输入配对码')
+    return
+  }
+  const serverUrl = serverInput.value.trim() || 'http://localhost:8080'
+  pairBtn.disabled = true
+  try {
+    const res = await fetch(`${serverUrl}/api/pair`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ code, device: navigator.userAgent.includes('Firefox') ? 'Firefox 插件' : 'Chrome 插件' }),
+    })
+    const data = await res.json().catch(() => ({}))
+    if (!res.ok) throw new Error(data.error || `HTTP ${res.status}`)
+    if (tokenInput) tokenInput.value = data.token || ''
+    pairCodeInput.value = ''
+    await saveSettings()
+    await loadPresets(presetInput?.value || '')
+    setStatus(data.authEnabled === false ? '后端未启用登录，无需令牌' : '配对成功')
+  } catch (err) {
+    setStatus(`配对失败：${err.message}`)
+  } finally {
+    pairBtn.disabled = false
+  }
+}
+
+if (pairBtn) {
+  pairBtn.addEventListener('click', pairDevice)
+}
+
 captureBtn.addEventListener('click', async () => {
+  if (presetRenderMode() === 'selection') {
+    setStatus('预设为选区模式，点击页面元素')
+    await sendToBackground('start-select')
+    return
+  }
   setStatus('开始抓取，请稍候…')
   await sendToBackground('start-capture')
 })
@@ -108,6 +202,33 @@ if (cleanContentInput) {
   cleanContentInput.addEventListener('change', saveSettings)
 }
 
+if (metadataOnlyInput) {
+  metadataOnlyInput.addEventListener('change', saveSettings)
+}
+
+if (fullSnapshotInput) {
+  fullSnapshotInput.addEventListener('change', saveSettings)
+}
+
+if (presetInput) {
+  presetInput.addEventListener('change', saveSettings)
+}
+
+if (sendCookiesInput) {
+  // Reading cookies for arbitrary sites needs host access, which is only
+  // requested once the user opts in.
+  sendCookiesInput.addEventListener('change', async () => {
+    if (sendCookiesInput.checked) {
+      const granted = await chrome.permissions.request({ origins: ['<all_urls>'] })
+      if (!granted) {
+        sendCookiesInput.checked = false
+        setStatus('未授予站点访问权限，无法读取 Cookie')
+      }
+    }
+    saveSettings()
+  })
+}
+
 chrome.runtime.onMessage.addListener((msg) => {
   if (!msg || msg.type !== 'capture-status') return
   setStatus(msg.message || '')
@@ -1,8 +1,16 @@
 import React, { useEffect, useMemo, useRef, useState } from 'react'
 
-const API_BASE = import.meta.env.VITE_API_BASE || 'http://localhost:8080'
+export const API_BASE = import.meta.env.VITE_API_BASE || 'http://localhost:8080'
+
+// apiFetch sends the login cookie with API calls and announces a 401, which
+// the app answers with the login form.
+const apiFetch = async (url, options = {}) => {
+  const res = await fetch(url, { credentials: 'include', ...options })
+  if (res.status === 401) window.dispatchEvent(new Event('webarchive:unauthorized'))
+  return res
+}
 
-const formatDateTime = (value) => {
+export const formatDateTime = (value) => {
   if (!value) return ''
   const d = new Date(value)
   if (Number.isNaN(d.getTime())) return ''
@@ -20,13 +28,25 @@ const toTagList = (value) => {
   return []
 }
 
+const formatBytes = (bytes) => {
+  if (!bytes) return '0 B'
+  const units = ['B', 'KB', 'MB', 'GB']
+  let value = bytes
+  let unit = 0
+  while (value >= 1024 && unit < units.length - 1) {
+    value /= 1024
+    unit += 1
+  }
+  return `${value.toFixed(unit === 0 ? 0 : 1)} ${units[unit]}`
+}
+
 const splitHierarchyPaths = (value) =>
   value
     .split(/\n|;/)
     .map((line) => line.trim())
     .filter(Boolean)
 
-const GraphView = ({ data, onNodeClick }) => {
+export const GraphView = ({ data, onNodeClick }) => {
   const wrapRef = useRef(null)
   const graphRef = useRef(null)
 
@@ -167,6 +187,11 @@ const GraphView = ({ data, onNodeClick }) => {
         path: 3,
         taxonomy: 4.5,
         entity: 4,
+        person: 4,
+        organization: 4,
+        technology: 4,
+        concept: 4,
+        place: 4,
       }
       const getNodeSize = (node) => {
         const degree = adjacency.get(node?.id)?.size || 0
@@ -175,6 +200,9 @@ const GraphView = ({ data, onNodeClick }) => {
         return baseSize + boost
       }
       const getLinkColor = (link) => {
+        if (link.type === 'co-citation') return 'rgba(99, 102, 241, 0.18)'
+        if (link.type === 'supports') return 'rgba(22, 163, 74, 0.55)'
+        if (link.type === 'contradicts') return 'rgba(220, 38, 38, 0.6)'
         const sourceId = typeof link.source === 'object' ? link.source.id : link.source
         const targetId = typeof link.target === 'object' ? link.target.id : link.target
         const sourceNode = nodeIndex.get(sourceId)
@@ -187,7 +215,7 @@ const GraphView = ({ data, onNodeClick }) => {
         graphRef.current = ForceGraph3D()(wrapRef.current)
           .backgroundColor('#fafbfc')
           .linkOpacity(0.35)
-          .linkWidth((link) => (link.value || 1) * 0.7)
+          .linkWidth((link) => Math.min(link.value || 1, 6) * 0.7)
           .linkDirectionalParticles(0)
           .linkDirectionalParticleWidth(0)
           .linkDirectionalParticleSpeed(0)
@@ -439,16 +467,22 @@ export default function App() {
   const [taxonomyTree, setTaxonomyTree] = useState([])
   const [taxonomyDetail, setTaxonomyDetail] = useState(null)
   const [taxonomyLoading, setTaxonomyLoading] = useState(false)
-  const [query, setQuery] = useState('')
+  // Address-bar searches arrive as /?q= (see /search and /opensearch.xml).
+  const [query, setQuery] = useState(() => new URLSearchParams(window.location.search).get('q') || '')
   const [category, setCategory] = useState('')
   const [tag, setTag] = useState('')
   const [loading, setLoading] = useState(false)
   const [error, setError] = useState('')
-  const [form, setForm] = useState({ category: '', tags: '', hierarchy: '' })
+  const [form, setForm] = useState({ category: '', tags: '', hierarchy: '', published: false })
   const [saving, setSaving] = useState(false)
   const [metaOpen, setMetaOpen] = useState(false)
   const [aiLoading, setAiLoading] = useState(false)
   const [aiOpen, setAiOpen] = useState(false)
+  const [pairing, setPairing] = useState(null)
+  const [user, setUser] = useState(null)
+  const [loginOpen, setLoginOpen] = useState(false)
+  const [loginForm, setLoginForm] = useState({ username: '', password: '' })
+  const [loginError, setLoginError] = useState('')
   const [selectedIds, setSelectedIds] = useState([])
   const [batchMode, setBatchMode] = useState(false)
   const [immersiveMode, setImmersiveMode] = useState(false)
@@ -462,22 +496,43 @@ export default function App() {
     totalProcessed: 0,
   })
   const [analysisLoading, setAnalysisLoading] = useState(false)
+  const [live, setLive] = useState(false)
+  const liveHandler = useRef(null)
+  const liveRefresh = useRef({ timer: null, archives: false })
   const [aiConfig, setAiConfig] = useState({
     baseUrl: localStorage.getItem('aiBaseUrl') || 'https://api.openai.com/v1',
     model: localStorage.getItem('aiModel') || '',
     apiKey: localStorage.getItem('aiKey') || '',
   })
 
+  // Preferences are stored per user on the server so they follow the user
+  // across devices; the list order is kept in a ref because loadArchives is
+  // also called from handlers created before the preferences arrive.
+  const sortRef = useRef('newest')
+  const loadPreferences = async () => {
+    try {
+      const res = await apiFetch(`${API_BASE}/api/preferences`)
+      if (!res.ok) return
+      const prefs = await res.json()
+      sortRef.current = prefs.sort || 'newest'
+      document.documentElement.dataset.theme = prefs.theme || 'system'
+      document.documentElement.dataset.density = prefs.density || 'comfortable'
+    } catch (err) {
+      // fall back to the defaults
+    }
+  }
+
   const loadArchives = async () => {
     setLoading(true)
     setError('')
     try {
       const params = new URLSearchParams()
+      if (sortRef.current !== 'newest') params.set('sort', sortRef.current)
       if (query) params.set('q', query)
       if (category) params.set('category', category)
       if (tag) params.set('tag', tag)
       const qs = params.toString()
-      const res = await fetch(`${API_BASE}/api/archives${qs ? `?${qs}` : ''}`)
+      const res = await apiFetch(`${API_BASE}/api/archives${qs ? `?${qs}` : ''}`)
       if (!res.ok) throw new Error('加载失败')
       const data = await res.json()
       setItems(data)
@@ -502,7 +557,11 @@ export default function App() {
       } else if (mode) {
         query = `?mode=${mode}`
       }
-      const res = await fetch(`${API_BASE}/api/graph${query}`)
+      if (mode === 'knowledge' && window.ReadableStream) {
+        await streamGraph(`${API_BASE}/api/graph${query}&format=ndjson`)
+        return
+      }
+      const res = await apiFetch(`${API_BASE}/api/graph${query}`)
       if (!res.ok) throw new Error('图谱加载失败')
       const data = await res.json()
       setGraphData(data)
@@ -511,11 +570,48 @@ export default function App() {
     }
   }
 
+  // Large knowledge graphs arrive as NDJSON: draw once all nodes are in,
+  // then add links in batches instead of waiting for the whole document.
+  const streamGraph = async (url) => {
+    const res = await apiFetch(url)
+    if (!res.ok || !res.body) throw new Error('图谱加载失败')
+    const reader = res.body.getReader()
+    const decoder = new TextDecoder()
+    const nodes = []
+    const links = []
+    let buffer = ''
+    let rendered = 0
+    const render = () => {
+      rendered = links.length
+      setGraphData({ nodes: [...nodes], links: [...links] })
+    }
+    const handle = (line) => {
+      if (!line.trim()) return
+      const msg = JSON.parse(line)
+      if (msg.type === 'node') nodes.push(msg.data)
+      if (msg.type === 'link') {
+        if (links.length === 0) render()
+        links.push(msg.data)
+      }
+    }
+    for (;;) {
+      const { done, value } = await reader.read()
+      if (done) break
+      buffer += decoder.decode(value, { stream: true })
+      const lines = buffer.split('\n')
+      buffer = lines.pop()
+      lines.forEach(handle)
+      if (links.length - rendered >= 2000) render()
+    }
+    handle(buffer)
+    render()
+  }
+
   const loadTaxonomy = async () => {
     setTaxonomyLoading(true)
     setTaxonomyDetail(null)
     try {
-      const res = await fetch(`${API_BASE}/api/taxonomy`)
+      const res = await apiFetch(`${API_BASE}/api/taxonomy`)
       if (!res.ok) throw new Error('分类树加载失败')
       const data = await res.json()
       setTaxonomyTree(data)
@@ -528,7 +624,7 @@ export default function App() {
 
   const loadTaxonomyNode = async (id) => {
     try {
-      const res = await fetch(`${API_BASE}/api/taxonomy/${id}?desc=1`)
+      const res = await apiFetch(`${API_BASE}/api/taxonomy/${id}?desc=1`)
       if (!res.ok) throw new Error('节点加载失败')
       const data = await res.json()
       setTaxonomyDetail(data)
@@ -540,7 +636,7 @@ export default function App() {
   const loadAnalysisStatus = async (silent = false) => {
     if (!silent) setAnalysisLoading(true)
     try {
-      const res = await fetch(`${API_BASE}/api/ai/analyze/status`)
+      const res = await apiFetch(`${API_BASE}/api/ai/analyze/status`)
       if (!res.ok) throw new Error('后台分析状态获取失败')
       const data = await res.json()
       setAnalysis(data)
@@ -554,7 +650,7 @@ export default function App() {
   const startAnalysis = async (ids = []) => {
     setAnalysisLoading(true)
     try {
-      const res = await fetch(`${API_BASE}/api/ai/analyze/start`, {
+      const res = await apiFetch(`${API_BASE}/api/ai/analyze/start`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify({ ids }),
@@ -572,7 +668,7 @@ export default function App() {
   const stopAnalysis = async () => {
     setAnalysisLoading(true)
     try {
-      const res = await fetch(`${API_BASE}/api/ai/analyze/stop`, { method: 'POST' })
+      const res = await apiFetch(`${API_BASE}/api/ai/analyze/stop`, { method: 'POST' })
       if (!res.ok) throw new Error('停止后台分析失败')
       const data = await res.json()
       setAnalysis(data)
@@ -583,11 +679,65 @@ export default function App() {
     }
   }
 
-  useEffect(() => {
-    loadArchives()
+  const loadAll = () => {
+    loadPreferences().then(loadArchives)
     loadAnalysisStatus(true)
+  }
+
+  const loadUser = async () => {
+    try {
+      const res = await apiFetch(`${API_BASE}/api/auth/me`)
+      if (!res.ok) return
+      const data = await res.json()
+      setUser(data.authEnabled ? data.principal : null)
+    } catch (err) {
+      // backend unreachable; the data requests report it
+    }
+  }
+
+  useEffect(() => {
+    const onUnauthorized = () => {
+      setUser(null)
+      setLoginOpen(true)
+    }
+    window.addEventListener('webarchive:unauthorized', onUnauthorized)
+    loadUser()
+    loadAll()
+    return () => window.removeEventListener('webarchive:unauthorized', onUnauthorized)
   }, [])
 
+  // Logging in sets the session cookie, which the API, the archive frames
+  // and the live event socket all use from then on.
+  const login = async (e) => {
+    e.preventDefault()
+    setLoginError('')
+    try {
+      const res = await fetch(`${API_BASE}/api/auth/login`, {
+        method: 'POST',
+        credentials: 'include',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify(loginForm),
+      })
+      const data = await res.json().catch(() => ({}))
+      if (!res.ok) throw new Error(res.status === 401 ? '用户名或密码错误' : data.error || '登录失败')
+      setLoginOpen(false)
+      setLoginForm({ username: '', password: '' })
+      setError('')
+      loadUser()
+      loadAll()
+    } catch (err) {
+      setLoginError(err.message || '登录失败')
+    }
+  }
+
+  const logout = async () => {
+    await apiFetch(`${API_BASE}/api/auth/logout`, { method: 'POST' }).catch(() => {})
+    setUser(null)
+    setItems([])
+    setSelected(null)
+    setLoginOpen(true)
+  }
+
   useEffect(() => {
     const handleKeyDown = (e) => {
       if (e.key === 'Escape' && immersiveMode) {
@@ -599,12 +749,71 @@ export default function App() {
   }, [immersiveMode])
 
   useEffect(() => {
-    if (!analysis?.running) return
+    if (!analysis?.running || live) return
     const timer = setInterval(() => {
       loadAnalysisStatus(true)
     }, 5000)
     return () => clearInterval(timer)
-  }, [analysis?.running])
+  }, [analysis?.running, live])
+
+  // Server events arrive in bursts (bulk moves, imports), so list and graph
+  // reloads are coalesced.
+  liveHandler.current = (event) => {
+    if (event.type === 'analysis.progress') {
+      setAnalysis(event.data)
+      return
+    }
+    const isArchive = event.type.startsWith('archive.')
+    if (!isArchive && event.type !== 'taxonomy.changed') return
+    const pending = liveRefresh.current
+    pending.archives = pending.archives || isArchive
+    clearTimeout(pending.timer)
+    pending.timer = setTimeout(async () => {
+      if (pending.archives) loadArchives()
+      pending.archives = false
+      if (view !== 'graph') return
+      if (graphMode === 'taxonomy') {
+        const openId = taxonomyDetail?.node?.id
+        await loadTaxonomy()
+        if (openId) loadTaxonomyNode(openId)
+      } else {
+        loadGraph(graphMode === 'knowledge' ? 'knowledge' : '')
+      }
+    }, 500)
+  }
+
+  useEffect(() => {
+    let socket = null
+    let retry = null
+    let closed = false
+    let delay = 1000
+    const connect = () => {
+      socket = new WebSocket(`${API_BASE.replace(/^http/, 'ws')}/api/ws`)
+      socket.onopen = () => {
+        delay = 1000
+        setLive(true)
+      }
+      socket.onmessage = (msg) => {
+        try {
+          liveHandler.current?.(JSON.parse(msg.data))
+        } catch {
+          // ignore malformed frames
+        }
+      }
+      socket.onclose = () => {
+        setLive(false)
+        if (closed) return
+        retry = setTimeout(connect, delay)
+        delay = Math.min(delay * 2, 30000)
+      }
+    }
+    connect()
+    return () => {
+      closed = true
+      clearTimeout(retry)
+      socket?.close()
+    }
+  }, [])
 
   useEffect(() => {
     if (!analysis?.running && analysis?.lastRun && graphMode === 'taxonomy') {
@@ -623,7 +832,7 @@ export default function App() {
 
   useEffect(() => {
     if (!selected) {
-      setForm({ category: '', tags: '', hierarchy: '' })
+      setForm({ category: '', tags: '', hierarchy: '', published: false })
       setMetaOpen(false)
       return
     }
@@ -636,7 +845,12 @@ export default function App() {
     } else if (selected.hierarchyPath) {
       path = selected.hierarchyPath
     }
-    setForm({ category: selected.category || '', tags: tags.join(', '), hierarchy: path })
+    setForm({
+      category: selected.category || '',
+      tags: tags.join(', '),
+      hierarchy: path,
+      published: Boolean(selected.published),
+    })
     setMetaOpen(false)
   }, [selected])
 
@@ -657,10 +871,10 @@ export default function App() {
         .filter(Boolean)
       const hierarchyPaths = splitHierarchyPaths(form.hierarchy)
       const hierarchy = hierarchyPaths.length > 0 ? hierarchyPaths[0].split('/') : []
-      const res = await fetch(`${API_BASE}/api/archives/${selected.id}`, {
+      const res = await apiFetch(`${API_BASE}/api/archives/${selected.id}`, {
         method: 'PATCH',
         headers: { 'Content-Type': 'application/json' },
-        body: JSON.stringify({ category: form.category, tags, hierarchy, hierarchyPaths }),
+        body: JSON.stringify({ category: form.category, tags, hierarchy, hierarchyPaths, published: form.published }),
       })
       if (!res.ok) throw new Error('保存失败')
       const updated = await res.json()
@@ -678,7 +892,7 @@ export default function App() {
     if (!selected) return
     setAiLoading(true)
     try {
-      const res = await fetch(`${API_BASE}/api/archives/${selected.id}/ai-tag`, {
+      const res = await apiFetch(`${API_BASE}/api/archives/${selected.id}/ai-tag`, {
         method: 'POST',
       })
       if (!res.ok) throw new Error('AI 生成失败')
@@ -697,7 +911,7 @@ export default function App() {
     if (!selected) return
     if (!window.confirm('确定要删除该归档吗？')) return
     try {
-      const res = await fetch(`${API_BASE}/api/archives/${selected.id}`, {
+      const res = await apiFetch(`${API_BASE}/api/archives/${selected.id}`, {
         method: 'DELETE',
       })
       if (!res.ok) throw new Error('删除失败')
@@ -711,7 +925,7 @@ export default function App() {
 
   const saveAiConfig = async () => {
     try {
-      const res = await fetch(`${API_BASE}/api/ai/config`, {
+      const res = await apiFetch(`${API_BASE}/api/ai/config`, {
         method: 'POST',
         headers: { 'Content-Type': 'application/json' },
         body: JSON.stringify(aiConfig),
@@ -726,6 +940,17 @@ export default function App() {
     }
   }
 
+  const createPairingCode = async () => {
+    try {
+      const res = await apiFetch(`${API_BASE}/api/pair/codes`, { method: 'POST' })
+      const data = await res.json()
+      if (!res.ok) throw new Error(data.error || '生成配对码失败')
+      setPairing(data)
+    } catch (err) {
+      setError(err.message || '生成配对码失败')
+    }
+  }
+
   const toggleSelected = (id) => {
     setSelectedIds((prev) => (prev.includes(id) ? prev.filter((item) => item !== id) : [...prev, id]))
   }
@@ -742,6 +967,7 @@ export default function App() {
   const enterImmersiveMode = (item) => {
     setSelected(item)
     setImmersiveMode(true)
+    apiFetch(`${API_BASE}/api/archives/${item.id}/read`, { method: 'POST' }).catch(() => {})
   }
 
   const exitImmersiveMode = () => {
@@ -809,7 +1035,7 @@ export default function App() {
           </div>
           <iframe
             title="immersive-content"
-            src={`${API_BASE}/api/archives/${selected.id}/html`}
+            src={`${API_BASE}/api/archives/${selected.id}/html?annotations=1`}
             className="immersive-content"
           />
         </div>
@@ -906,6 +1132,14 @@ export default function App() {
           <button type="button" className="ghost" onClick={() => setAiOpen(true)}>
             ⚙️ AI 设置
           </button>
+          <button type="button" className="ghost" onClick={createPairingCode}>
+            🔗 配对插件
+          </button>
+          {user && (
+            <button type="button" className="ghost" onClick={logout} title={user.username}>
+              退出登录
+            </button>
+          )}
         </div>
       </div>
 
@@ -1200,6 +1434,11 @@ export default function App() {
                   <a href={selected.url} target="_blank" rel="noreferrer" className="ghost small">
                     原文
                   </a>
+                  {selected.documentPath && (
+                    <a href={`${API_BASE}/api/assets/${selected.id}/${selected.documentPath}`} target="_blank" rel="noreferrer" className="ghost small">
+                      PDF
+                    </a>
+                  )}
                 </div>
               )}
             </div>
@@ -1230,13 +1469,22 @@ export default function App() {
                     placeholder={'例如：计算机/网络/TCP\n计算机网络/UDP/HTTP3'}
                   />
                 </div>
+                <label className="publish-toggle">
+                  <input
+                    type="checkbox"
+                    checked={form.published}
+                    onChange={(e) => setForm((s) => ({ ...s, published: e.target.checked }))}
+                  />
+                  公开发布（开启 PUBLIC_ENABLED 后出现在 /garden）
+                </label>
                 <button type="button" className="primary" disabled={!selected || saving} onClick={saveMeta}>
                   {saving ? '保存中…' : '保存元信息'}
                 </button>
               </div>
             )}
-            {selected && (selected.category || toTagList(selected.tags).length > 0) && (
+            {selected && (selected.published || selected.category || toTagList(selected.tags).length > 0) && (
               <div className="chip-row">
+                {selected.published && <span className="chip chip-accent">已公开</span>}
                 {selected.category && <span className="chip chip-accent">{selected.category}</span>}
                 {toTagList(selected.tags).map((t) => (
                   <span key={t} className="chip">
@@ -1245,12 +1493,73 @@ export default function App() {
                 ))}
               </div>
             )}
+            {selected?.report && (
+              <div className="capture-report">
+                <span>
+                  资源 {selected.report.assetsDownloaded}/{selected.report.assetsFound}
+                  {selected.report.assetsFailed > 0 && ` · 失败 ${selected.report.assetsFailed}`}
+                  {selected.report.assetsSkipped > 0 && ` · 跳过 ${selected.report.assetsSkipped}`}
+                  {selected.report.trackersBlocked > 0 && ` · 拦截跟踪 ${selected.report.trackersBlocked}`}
+                  {selected.report.elementsRemoved > 0 && ` · 清理元素 ${selected.report.elementsRemoved}`}
+                  {` · ${formatBytes(selected.report.bytes)} · ${(selected.report.elapsedMs / 1000).toFixed(1)} 秒`}
+                </span>
+                {selected.report.warnings?.map((w) => (
+                  <span key={w} className="capture-warning">
+                    {w}
+                  </span>
+                ))}
+              </div>
+            )}
             {!selected && <div className="hint">选择左侧内容即可预览</div>}
-            {selected && <iframe title="archive-preview" src={`${API_BASE}/api/archives/${selected.id}/html`} />}
+            {selected && <iframe title="archive-preview" src={`${API_BASE}/api/archives/${selected.id}/html?annotations=1`} />}
           </section>
         </main>
       )}
 
+      {loginOpen && (
+        <div className="modal">
+          <form className="modal-card" onSubmit={login}>
+            <h3>登录</h3>
+            <p>后端已启用登录，请使用账号密码登录后继续。</p>
+            <label>用户名</label>
+            <input
+              value={loginForm.username}
+              onChange={(e) => setLoginForm((s) => ({ ...s, username: e.target.value }))}
+              autoComplete="username"
+              autoFocus
+            />
+            <label>密码</label>
+            <input
+              type="password"
+              value={loginForm.password}
+              onChange={(e) => setLoginForm((s) => ({ ...s, password: e.target.value }))}
+              autoComplete="current-password"
+            />
+            {loginError && <div className="error">{loginError}</div>}
+            <div className="modal-actions">
+              <button type="submit" className="primary" disabled={!loginForm.username || !loginForm.password}>
+                登录
+              </button>
+            </div>
+          </form>
+        </div>
+      )}
+
+      {pairing && (
+        <div className="modal">
+          <div className="modal-card">
+            <h3>配对插件</h3>
+            <p>在浏览器插件中输入以下配对码，10 分钟内有效，只能使用一次。</p>
+            <div className="pairing-code">{pairing.code}</div>
+            <div className="modal-actions">
+              <button type="button" className="primary" onClick={() => setPairing(null)}>
+                完成
+              </button>
+            </div>
+          </div>
+        </div>
+      )}
+
       {aiOpen && (
         <div className="modal">
           <div className="modal-card">
@@ -0,0 +1,193 @@
+import React, { useEffect, useState } from 'react'
+import { API_BASE, GraphView, formatDateTime } from './App.jsx'
+
+// Garden is the anonymous, read-only view of published archives served at
+// /garden. It only talks to /api/public.
+export default function Garden() {
+  const [items, setItems] = useState([])
+  const [selected, setSelected] = useState(null)
+  const [view, setView] = useState('list')
+  const [graphData, setGraphData] = useState(null)
+  const [query, setQuery] = useState('')
+  const [tag, setTag] = useState('')
+  const [loading, setLoading] = useState(false)
+  const [error, setError] = useState('')
+
+  const loadArchives = async (nextTag = tag) => {
+    setLoading(true)
+    setError('')
+    try {
+      const params = new URLSearchParams()
+      if (query) params.set('q', query)
+      if (nextTag) params.set('tag', nextTag)
+      const qs = params.toString()
+      const res = await fetch(`${API_BASE}/api/public/archives${qs ? `?${qs}` : ''}`)
+      if (res.status === 404) throw new Error('公开浏览未开启')
+      if (!res.ok) throw new Error('加载失败')
+      const data = await res.json()
+      setItems(data)
+      setSelected((prev) => data.find((item) => item.id === prev?.id) || data[0] || null)
+    } catch (err) {
+      setError(err.message || '加载失败')
+    } finally {
+      setLoading(false)
+    }
+  }
+
+  const loadGraph = async () => {
+    try {
+      const res = await fetch(`${API_BASE}/api/public/graph`)
+      if (!res.ok) throw new Error('图谱加载失败')
+      setGraphData(await res.json())
+    } catch (err) {
+      setError(err.message || '图谱加载失败')
+    }
+  }
+
+  useEffect(() => {
+    loadArchives()
+  }, [])
+
+  useEffect(() => {
+    if (view === 'graph' && !graphData) loadGraph()
+  }, [view])
+
+  const filterByTag = (value) => {
+    setTag(value)
+    setView('list')
+    loadArchives(value)
+  }
+
+  const handleNodeClick = (node) => {
+    if (node.group === 'archive' && node.refId) {
+      const found = items.find((item) => item.id === node.refId)
+      if (found) setSelected(found)
+      setView('list')
+      return
+    }
+    if (node.group === 'tag') filterByTag(node.label)
+  }
+
+  return (
+    <div className="app">
+      <header className="hero">
+        <div className="hero-brand">
+          <div className="brand-text">
+            <h1>WebArchive</h1>
+            <p className="brand-slogan">公开的数字花园 · 只读浏览</p>
+          </div>
+        </div>
+      </header>
+
+      <div className="toolbar">
+        <div className="tabs">
+          <button type="button" className={view === 'list' ? 'tab active' : 'tab'} onClick={() => setView('list')}>
+            📋 列表视图
+          </button>
+          <button type="button" className={view === 'graph' ? 'tab active' : 'tab'} onClick={() => setView('graph')}>
+            🌌 知识星球
+          </button>
+        </div>
+      </div>
+
+      {error && <div className="error">{error}</div>}
+
+      {view === 'graph' && (
+        <section className="panel graph-panel">
+          <div className="panel-header">
+            <div>
+              <h2>知识图谱</h2>
+              <p className="panel-sub">仅包含已公开的归档，点击文章或标签查看</p>
+            </div>
+          </div>
+          <GraphView data={graphData} onNodeClick={handleNodeClick} />
+        </section>
+      )}
+
+      {view === 'list' && (
+        <main className="layout">
+          <section className="panel list-panel">
+            <div className="panel-header">
+              <div>
+                <h2>公开归档</h2>
+                <p className="panel-sub">共 {items.length} 篇</p>
+              </div>
+            </div>
+            <div className="filters">
+              <input
+                value={query}
+                onChange={(e) => setQuery(e.target.value)}
+                onKeyDown={(e) => e.key === 'Enter' && loadArchives()}
+                placeholder="🔍 搜索标题、站点或正文..."
+              />
+              <input
+                value={tag}
+                onChange={(e) => setTag(e.target.value)}
+                onKeyDown={(e) => e.key === 'Enter' && loadArchives()}
+                placeholder="标签"
+              />
+              <button type="button" className="primary" onClick={() => loadArchives()}>
+                搜索
+              </button>
+            </div>
+            {loading && <div className="hint">加载中…</div>}
+            <div className="list">
+              {items.map((item) => (
+                <div
+                  key={item.id}
+                  className={`card ${selected?.id === item.id ? 'active' : ''}`}
+                  onClick={() => setSelected(item)}
+                >
+                  <div className="card-content">
+                    <div className="card-title">{item.title || '未命名页面'}</div>
+                    <div className="card-meta">
+                      <span>{item.siteName || item.domain || '未知站点'}</span>
+                      <span>·</span>
+                      <span>{formatDateTime(item.capturedAt || item.createdAt)}</span>
+                    </div>
+                    <div className="card-tags">
+                      {(item.tags || []).slice(0, 3).map((t) => (
+                        <span key={t} className="chip">
+                          {t}
+                        </span>
+                      ))}
+                    </div>
+                  </div>
+                </div>
+              ))}
+              {!loading && items.length === 0 && <div className="hint">暂无公开内容</div>}
+            </div>
+          </section>
+
+          <section className="panel preview-panel">
+            <div className="panel-header">
+              <div>
+                <h2>{selected?.title || '内容预览'}</h2>
+                <p className="panel-sub">{selected?.summary || selected?.excerpt || '选择左侧内容即可预览'}</p>
+              </div>
+              {selected && (
+                <div className="actions">
+                  <a href={selected.url} target="_blank" rel="noreferrer" className="ghost small">
+                    原文
+                  </a>
+                </div>
+              )}
+            </div>
+            {selected && (selected.tags || []).length > 0 && (
+              <div className="chip-row">
+                {selected.tags.map((t) => (
+                  <button key={t} type="button" className="chip chip-btn" onClick={() => filterByTag(t)}>
+                    {t}
+                  </button>
+                ))}
+              </div>
+            )}
+            {selected && selected.captureMode !== 'metadata' && (
+              <iframe title="archive-preview" src={`${API_BASE}/api/public/archives/${selected.id}/html`} />
+            )}
+          </section>
+        </main>
+      )}
+    </div>
+  )
+}
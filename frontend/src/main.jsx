@@ -1,10 +1,11 @@
 ﻿import React from 'react'
 import { createRoot } from 'react-dom/client'
 import App from './App.jsx'
+import Garden from './Garden.jsx'
 import './app.css'
 
 createRoot(document.getElementById('root')).render(
   <React.StrictMode>
-    <App />
+    {window.location.pathname.startsWith('/garden') ? <Garden /> : <App />}
   </React.StrictMode>
 )